@@ -0,0 +1,61 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Bundle writes a gzipped tarball of everything under dir to bundlePath
+func Bundle(dir string, bundlePath string) error {
+	out, err := os.Create(bundlePath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create bundle %s", bundlePath)
+	}
+	defer out.Close()
+
+	gzipWriter := gzip.NewWriter(out)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to bundle directory %s", dir)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return errors.Wrapf(err, "unable to close tar writer")
+	}
+	return errors.Wrapf(gzipWriter.Close(), "unable to close gzip writer")
+}
@@ -0,0 +1,175 @@
+package artifacts
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4DateFormat = "20060102T150405Z"
+)
+
+// s3Bucket is an S3-compatible bucket, along with the credentials to write to it
+type s3Bucket struct {
+	// Host serves the bucket's objects at /<key>
+	Host            string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only needed with temporary credentials
+	SessionToken string
+}
+
+// bucketFromEnv finds the endpoint and credentials for an s3:// or gs:// target in the environment
+func bucketFromEnv(target *url.URL) (*s3Bucket, error) {
+	switch target.Scheme {
+	case "s3":
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+		if region == "" {
+			region = "us-east-1"
+		}
+		bucket := &s3Bucket{
+			Host:            fmt.Sprintf("%s.s3.%s.amazonaws.com", target.Host, region),
+			Region:          region,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if bucket.AccessKeyID == "" || bucket.SecretAccessKey == "" {
+			return nil, errors.Errorf("uploading to %s requires $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY", target.String())
+		}
+		return bucket, nil
+	case "gs":
+		bucket := &s3Bucket{
+			Host:            fmt.Sprintf("%s.storage.googleapis.com", target.Host),
+			Region:          "auto",
+			AccessKeyID:     os.Getenv("GS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("GS_SECRET_ACCESS_KEY"),
+		}
+		if bucket.AccessKeyID == "" || bucket.SecretAccessKey == "" {
+			return nil, errors.Errorf("uploading to %s requires an HMAC key in $GS_ACCESS_KEY_ID and $GS_SECRET_ACCESS_KEY", target.String())
+		}
+		return bucket, nil
+	default:
+		return nil, errors.Errorf("no bucket for upload target scheme '%s'", target.Scheme)
+	}
+}
+
+func signedPut(localPath string, bucket *s3Bucket, key string, now time.Time) error {
+	body, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read %s", localPath)
+	}
+	request, err := http.NewRequest(http.MethodPut, "https://"+bucket.Host+"/"+uriEncode(key, false), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "unable to build request for %s/%s", bucket.Host, key)
+	}
+	request.Header.Set("Content-Type", "application/gzip")
+	bucket.sign(request, sha256Hex(body), now)
+
+	resp, err := (&http.Client{Timeout: uploadTimeout}).Do(request)
+	if err != nil {
+		return errors.Wrapf(err, "unable to PUT to %s", request.URL.String())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		response, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("bad status code for PUT to %s: %d, response %s", request.URL.String(), resp.StatusCode, response)
+	}
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to request, covering its host and every header already
+// set on it, along with the x-amz-* headers it adds
+func (b *s3Bucket) sign(request *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(sigV4DateFormat)
+	request.Header.Set("x-amz-date", amzDate)
+	request.Header.Set("x-amz-content-sha256", payloadHash)
+	if b.SessionToken != "" {
+		request.Header.Set("x-amz-security-token", b.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		uriEncode(request.URL.Path, false),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{amzDate[:8], b.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := []byte("AWS4" + b.SecretAccessKey)
+	for _, part := range []string{amzDate[:8], b.Region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", sigV4Algorithm, b.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters -- and, unless encodeSlash, '/'
+func uriEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(s) {
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || b == '-' || b == '.' || b == '_' || b == '~' || (b == '/' && !encodeSlash) {
+			encoded.WriteByte(b)
+		} else {
+			encoded.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return encoded.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,49 @@
+package artifacts
+
+import (
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// SonobuoyResultsDirEnv is set by Sonobuoy to the directory plugins write their results to
+	SonobuoyResultsDirEnv = "SONOBUOY_RESULTS_DIR"
+	// sonobuoyLegacyResultsDirEnv is what older versions of Sonobuoy, and our plugin script, use instead
+	sonobuoyLegacyResultsDirEnv = "RESULTS_DIR"
+	DefaultSonobuoyResultsDir   = "/tmp/sonobuoy/results"
+
+	// SonobuoyJUnitFileName is picked up by Sonobuoy's junit result format, which reports each test case
+	SonobuoyJUnitFileName  = "junit-cyclonus.xml"
+	sonobuoyBundleFileName = "results.tar.gz"
+	sonobuoyDoneFileName   = "done"
+)
+
+// SonobuoyResultsDir is where a Sonobuoy plugin should write its results, according to the environment
+func SonobuoyResultsDir() string {
+	for _, env := range []string{SonobuoyResultsDirEnv, sonobuoyLegacyResultsDirEnv} {
+		if dir := os.Getenv(env); dir != "" {
+			return dir
+		}
+	}
+	return DefaultSonobuoyResultsDir
+}
+
+// WriteSonobuoyResults bundles everything under dir into resultsDir, then writes the path of the bundle to the done
+// file, which tells the Sonobuoy worker that the plugin has finished and where to find its results.  It returns the
+// path of the bundle.
+func WriteSonobuoyResults(dir string, resultsDir string) (string, error) {
+	if err := os.MkdirAll(resultsDir, 0755); err != nil {
+		return "", errors.Wrapf(err, "unable to create sonobuoy results directory %s", resultsDir)
+	}
+	bundlePath, err := filepath.Abs(filepath.Join(resultsDir, sonobuoyBundleFileName))
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get absolute path of sonobuoy results bundle")
+	}
+	if err := Bundle(dir, bundlePath); err != nil {
+		return "", err
+	}
+	donePath := filepath.Join(resultsDir, sonobuoyDoneFileName)
+	return bundlePath, errors.Wrapf(ioutil.WriteFile(donePath, []byte(bundlePath), 0644), "unable to write sonobuoy done file %s", donePath)
+}
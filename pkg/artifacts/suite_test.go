@@ -0,0 +1,14 @@
+package artifacts
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestArtifacts(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunUploadTests()
+	RunSpecs(t, "artifacts suite")
+}
@@ -0,0 +1,73 @@
+package artifacts
+
+import (
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const uploadTimeout = 5 * time.Minute
+
+// Upload copies the file at localPath to target.  Supported targets:
+//   - s3://bucket/key -- credentials come from $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and, for temporary
+//     credentials, $AWS_SESSION_TOKEN; the bucket's region from $AWS_REGION or $AWS_DEFAULT_REGION
+//   - gs://bucket/key -- uses Cloud Storage's S3-compatible XML API, with an HMAC key from $GS_ACCESS_KEY_ID and
+//     $GS_SECRET_ACCESS_KEY
+//   - http(s)://... -- issues a PUT with the file as the body; works with presigned URLs
+//
+// If an s3 or gs target ends with '/', the file's base name is appended.  No cloud CLIs are needed: s3 and gs
+// uploads are PUTs signed with AWS Signature Version 4.
+func Upload(localPath string, target string) error {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse upload target %s", target)
+	}
+
+	logrus.Infof("uploading %s to %s", localPath, target)
+	switch parsed.Scheme {
+	case "s3", "gs":
+		object, err := url.Parse(objectTarget(target, localPath))
+		if err != nil {
+			return errors.Wrapf(err, "unable to parse upload target %s", target)
+		}
+		bucket, err := bucketFromEnv(object)
+		if err != nil {
+			return err
+		}
+		return signedPut(localPath, bucket, strings.TrimPrefix(object.Path, "/"), time.Now())
+	case "http", "https":
+		return httpPut(localPath, target)
+	default:
+		return errors.Errorf("unsupported upload target scheme '%s' in %s; expected one of s3, gs, http, https", parsed.Scheme, target)
+	}
+}
+
+func objectTarget(target string, localPath string) string {
+	if strings.HasSuffix(target, "/") {
+		return target + path.Base(localPath)
+	}
+	return target
+}
+
+func httpPut(localPath string, target string) error {
+	body, err := ioutil.ReadFile(localPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read %s", localPath)
+	}
+	resp, err := resty.New().SetTimeout(uploadTimeout).R().
+		SetHeader("Content-Type", "application/gzip").
+		SetBody(body).
+		Put(target)
+	if err != nil {
+		return errors.Wrapf(err, "unable to PUT to %s", target)
+	}
+	if !resp.IsSuccess() {
+		return errors.Errorf("bad status code for PUT to %s: %d, response %s", target, resp.StatusCode(), resp.String())
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package assertions
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+const (
+	ExpectAllow = "allow"
+	ExpectDeny  = "deny"
+)
+
+// Peer picks out pods: by exact namespace, namespace labels and pod labels.  Unset fields match everything.
+type Peer struct {
+	Namespace         string                `json:"namespace,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	PodSelector       *metav1.LabelSelector `json:"podSelector,omitempty"`
+}
+
+func (p *Peer) Matches(namespace string, namespaceLabels map[string]string, podLabels map[string]string) bool {
+	if p.Namespace != "" && p.Namespace != namespace {
+		return false
+	}
+	if p.NamespaceSelector != nil && !kube.IsLabelsMatchLabelSelector(namespaceLabels, *p.NamespaceSelector) {
+		return false
+	}
+	if p.PodSelector != nil && !kube.IsLabelsMatchLabelSelector(podLabels, *p.PodSelector) {
+		return false
+	}
+	return true
+}
+
+func (p *Peer) String() string {
+	var parts []string
+	if p.Namespace != "" {
+		parts = append(parts, "namespace "+p.Namespace)
+	}
+	if p.NamespaceSelector != nil {
+		parts = append(parts, "namespaces "+kube.SerializeLabelSelector(*p.NamespaceSelector))
+	}
+	if p.PodSelector != nil {
+		parts = append(parts, "pods "+kube.SerializeLabelSelector(*p.PodSelector))
+	}
+	if len(parts) == 0 {
+		return "all pods"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FlowAssertion says that traffic from every pod matching From to every pod matching To, on Port and Protocol, must
+// be allowed -- or denied
+type FlowAssertion struct {
+	Name     string             `json:"name"`
+	From     Peer               `json:"from"`
+	To       Peer               `json:"to"`
+	Port     intstr.IntOrString `json:"port"`
+	Protocol v1.Protocol        `json:"protocol,omitempty"`
+	Expect   string             `json:"expect"`
+}
+
+func (a *FlowAssertion) ExpectsAllowed() bool {
+	return a.Expect == ExpectAllow
+}
+
+func (a *FlowAssertion) protocol() v1.Protocol {
+	if a.Protocol == "" {
+		return v1.ProtocolTCP
+	}
+	return a.Protocol
+}
+
+func (a *FlowAssertion) Validate() error {
+	if a.Name == "" {
+		return errors.Errorf("assertion missing name")
+	}
+	if a.Expect != ExpectAllow && a.Expect != ExpectDeny {
+		return errors.Errorf("assertion '%s': expect must be %s or %s, found '%s'", a.Name, ExpectAllow, ExpectDeny, a.Expect)
+	}
+	if _, err := kube.ParseProtocol(string(a.protocol())); err != nil {
+		return errors.WithMessagef(err, "assertion '%s'", a.Name)
+	}
+	if a.Port.Type == intstr.Int && a.Port.IntVal <= 0 || a.Port.Type == intstr.String && a.Port.StrVal == "" {
+		return errors.Errorf("assertion '%s' missing port", a.Name)
+	}
+	return nil
+}
+
+// Jobs builds a probe job for each pair of pods matching the assertion's From and To.  Jobs go to pod IPs, since
+// existing workloads don't have cyclonus's services.
+func (a *FlowAssertion) Jobs(resources *probe.Resources) *probe.Jobs {
+	protocol, err := kube.ParseProtocol(string(a.protocol()))
+	if err != nil {
+		panic(errors.Wrapf(err, "invalid assertion '%s'", a.Name))
+	}
+	all := resources.GetJobsForNamedPortProtocol(a.Port, protocol, generator.ProbeModePodIP)
+	matches := func(jobs []*probe.Job) []*probe.Job {
+		var matched []*probe.Job
+		for _, job := range jobs {
+			if a.From.Matches(job.FromNamespace, job.FromNamespaceLabels, job.FromPodLabels) &&
+				a.To.Matches(job.ToNamespace, job.ToNamespaceLabels, job.ToPodLabels) &&
+				job.FromKey != job.ToKey {
+				matched = append(matched, job)
+			}
+		}
+		return matched
+	}
+	return &probe.Jobs{
+		Valid:           matches(all.Valid),
+		BadNamedPort:    matches(all.BadNamedPort),
+		BadPortProtocol: matches(all.BadPortProtocol),
+	}
+}
+
+func (a *FlowAssertion) String() string {
+	return fmt.Sprintf("%s: %s from %s to %s on %s/%s", a.Name, a.Expect, a.From.String(), a.To.String(), a.protocol(), a.Port.String())
+}
+
+type Assertions struct {
+	Assertions []*FlowAssertion `json:"assertions"`
+}
+
+// Namespaces returns the namespaces named by the assertions' peers, and false if any peer doesn't name a namespace,
+// in which case the assertions could involve pods in any namespace
+func (a *Assertions) Namespaces() ([]string, bool) {
+	namespaces := map[string]bool{}
+	for _, assertion := range a.Assertions {
+		for _, peer := range []Peer{assertion.From, assertion.To} {
+			if peer.Namespace == "" {
+				return nil, false
+			}
+			namespaces[peer.Namespace] = true
+		}
+	}
+	var sorted []string
+	for ns := range namespaces {
+		sorted = append(sorted, ns)
+	}
+	sort.Strings(sorted)
+	return sorted, true
+}
+
+// SourcePods returns the pods which traffic is sent from, by any of the assertions -- only these need a client
+func (a *Assertions) SourcePods(resources *probe.Resources) ([]*probe.Pod, error) {
+	var pods []*probe.Pod
+	seen := map[string]bool{}
+	for _, assertion := range a.Assertions {
+		jobs := assertion.Jobs(resources)
+		for _, job := range append(append(jobs.Valid, jobs.BadNamedPort...), jobs.BadPortProtocol...) {
+			if seen[job.FromKey] {
+				continue
+			}
+			seen[job.FromKey] = true
+			pod, err := resources.GetPod(job.FromNamespace, job.FromPod)
+			if err != nil {
+				return nil, err
+			}
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+func ParseAssertions(bytes []byte) (*Assertions, error) {
+	assertions := &Assertions{}
+	if err := yaml.UnmarshalStrict(bytes, assertions); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal assertions")
+	}
+	for _, assertion := range assertions.Assertions {
+		if err := assertion.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return assertions, nil
+}
+
+// ReadAssertions reads a yaml or json file of assertions, i.e.:
+//
+//	assertions:
+//	- name: frontend reaches backend
+//	  from: {namespace: web, podSelector: {matchLabels: {app: frontend}}}
+//	  to: {namespace: api}
+//	  port: 8080
+//	  expect: allow
+//	- name: nothing reaches the database from web
+//	  from: {namespace: web}
+//	  to: {namespace: db}
+//	  port: postgres
+//	  expect: deny
+func ReadAssertions(path string) (*Assertions, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read assertions from %s", path)
+	}
+	return ParseAssertions(bytes)
+}
@@ -0,0 +1,106 @@
+package assertions
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func RunAssertionsTests() {
+	Describe("Assertions", func() {
+		resources := &probe.Resources{
+			Namespaces: map[string]map[string]string{"web": {"ns": "web"}, "db": {"ns": "db"}},
+			Pods: []*probe.Pod{
+				probe.NewPod("web", "frontend", map[string]string{"app": "frontend"}, "10.0.0.1",
+					[]*probe.Container{{Name: "nginx", Port: 80, Protocol: v1.ProtocolTCP, PortName: "http"}}),
+				probe.NewPod("db", "postgres", map[string]string{"app": "postgres"}, "10.0.0.2",
+					[]*probe.Container{{Name: "postgres", Port: 5432, Protocol: v1.ProtocolTCP, PortName: "postgres"}}),
+			},
+		}
+		denyAllIngressToDB := &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "deny-all"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		}
+		runner := probe.NewSimulatedRunner(matcher.BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{denyAllIngressToDB}))
+
+		It("should parse and validate assertions", func() {
+			_, err := ParseAssertions([]byte(`
+assertions:
+- name: bad
+  from: {namespace: web}
+  to: {namespace: db}
+  port: 5432
+  expect: maybe
+`))
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("should report violations, and fail assertions which match no pods", func() {
+			assertions, err := ParseAssertions([]byte(`
+assertions:
+- name: web can't reach the database
+  from: {namespace: web}
+  to: {namespace: db}
+  port: postgres
+  expect: deny
+- name: web reaches the database
+  from: {namespace: web}
+  to: {namespaceSelector: {matchLabels: {ns: db}}}
+  port: 5432
+  expect: allow
+- name: the database reaches web
+  from: {podSelector: {matchLabels: {app: postgres}}}
+  to: {namespace: web}
+  port: http
+  expect: allow
+- name: typo
+  from: {namespace: wbe}
+  to: {namespace: db}
+  port: 5432
+  expect: deny
+`))
+			Expect(err).To(Succeed())
+
+			report := Check(assertions, resources, runner)
+			Expect(report.Results).To(HaveLen(4))
+			Expect(report.Results[0].Passed()).To(BeTrue())
+			Expect(report.Results[1].Passed()).To(BeFalse())
+			Expect(report.Results[1].Violations).To(HaveLen(1))
+			Expect(report.Results[2].Passed()).To(BeTrue())
+			Expect(report.Results[3].Passed()).To(BeFalse())
+			Expect(report.Results[3].JobResults).To(BeEmpty())
+			Expect(report.FailedCount()).To(Equal(2))
+		})
+
+		It("should find the namespaces and source pods of assertions", func() {
+			assertions, err := ParseAssertions([]byte(`
+assertions:
+- name: web reaches the database
+  from: {namespace: web}
+  to: {namespace: db}
+  port: 5432
+  expect: allow
+`))
+			Expect(err).To(Succeed())
+
+			namespaces, ok := assertions.Namespaces()
+			Expect(ok).To(BeTrue())
+			Expect(namespaces).To(Equal([]string{"db", "web"}))
+
+			sourcePods, err := assertions.SourcePods(resources)
+			Expect(err).To(Succeed())
+			Expect(sourcePods).To(HaveLen(1))
+			Expect(sourcePods[0].Name).To(Equal("frontend"))
+
+			assertions.Assertions[0].To = Peer{}
+			_, ok = assertions.Namespaces()
+			Expect(ok).To(BeFalse())
+		})
+	})
+}
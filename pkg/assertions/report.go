@@ -0,0 +1,115 @@
+package assertions
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/olekukonko/tablewriter"
+	"sort"
+	"strings"
+)
+
+// Result is how an assertion fared: the result for each pair of pods it covers, and which of those violate it
+type Result struct {
+	Assertion  *FlowAssertion
+	JobResults []*probe.JobResult
+	Violations []*probe.JobResult
+}
+
+// Passed is false if any pair violates the assertion, or if the assertion doesn't cover any pairs at all -- which
+// usually means its selectors are wrong, and it would otherwise pass without checking anything
+func (r *Result) Passed() bool {
+	return len(r.JobResults) > 0 && len(r.Violations) == 0
+}
+
+// isViolation: a must-allow flow has to be allowed; a must-deny flow must not be, but ports which aren't served
+// can't be reached anyway, so they don't violate it.  Probes which couldn't run violate either kind, since they
+// don't show anything.
+func isViolation(assertion *FlowAssertion, jobResult *probe.JobResult) bool {
+	switch jobResult.Combined {
+	case probe.ConnectivityAllowed:
+		return !assertion.ExpectsAllowed()
+	case probe.ConnectivityInvalidNamedPort, probe.ConnectivityInvalidPortProtocol, probe.ConnectivityBlocked:
+		return assertion.ExpectsAllowed()
+	default:
+		return true
+	}
+}
+
+type Report struct {
+	Results []*Result
+}
+
+// Check runs the jobs for every assertion against resources, with runner -- which may be simulated, to check
+// policies statically, or run against kube, to check the cluster's actual behavior
+func Check(assertions *Assertions, resources *probe.Resources, runner *probe.Runner) *Report {
+	report := &Report{}
+	for _, assertion := range assertions.Assertions {
+		result := &Result{Assertion: assertion, JobResults: runner.RunJobs(assertion.Jobs(resources))}
+		sort.Slice(result.JobResults, func(i, j int) bool {
+			return result.JobResults[i].Job.Key() < result.JobResults[j].Job.Key()
+		})
+		for _, jobResult := range result.JobResults {
+			if isViolation(assertion, jobResult) {
+				result.Violations = append(result.Violations, jobResult)
+			}
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+func (r *Report) Passed() bool {
+	for _, result := range r.Results {
+		if !result.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) FailedCount() int {
+	count := 0
+	for _, result := range r.Results {
+		if !result.Passed() {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *Report) Table() string {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetHeader([]string{"Assertion", "From", "To", "Port/Protocol", "Expect", "Pairs", "Result", "Violations"})
+	table.SetRowLine(true)
+	table.SetReflowDuringAutoWrap(false)
+	table.SetAutoWrapText(false)
+
+	for _, result := range r.Results {
+		status := "pass"
+		if !result.Passed() {
+			status = "FAIL"
+		}
+		var violations []string
+		if len(result.JobResults) == 0 {
+			violations = append(violations, "no pairs of pods matched")
+		}
+		for _, violation := range result.Violations {
+			violations = append(violations, fmt.Sprintf("%s -> %s: %s", violation.Job.FromKey, violation.Job.ToKey, violation.Combined))
+		}
+		assertion := result.Assertion
+		table.Append([]string{
+			assertion.Name,
+			assertion.From.String(),
+			assertion.To.String(),
+			fmt.Sprintf("%s/%s", assertion.Port.String(), assertion.protocol()),
+			assertion.Expect,
+			fmt.Sprintf("%d", len(result.JobResults)),
+			status,
+			strings.Join(violations, "\n"),
+		})
+	}
+
+	table.Render()
+	return str.String()
+}
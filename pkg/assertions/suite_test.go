@@ -0,0 +1,14 @@
+package assertions
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAssertions(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunAssertionsTests()
+	RunSpecs(t, "assertions suite")
+}
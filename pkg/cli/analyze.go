@@ -10,8 +10,10 @@ import (
 	"strings"
 
 	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
 	"github.com/mattfenwick/cyclonus/pkg/kube/netpol"
 	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/mattfenwick/cyclonus/pkg/mutation"
 	"github.com/mattfenwick/cyclonus/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -22,12 +24,15 @@ import (
 )
 
 const (
-	ParseMode        = "parse"
-	ExplainMode      = "explain"
-	LintMode         = "lint"
-	QueryTrafficMode = "query-traffic"
-	QueryTargetMode  = "query-target"
-	ProbeMode        = "probe"
+	ParseMode         = "parse"
+	ExplainMode       = "explain"
+	LintMode          = "lint"
+	QueryTrafficMode  = "query-traffic"
+	QueryExternalMode = "query-external"
+	QueryTargetMode   = "query-target"
+	QueryBatchMode    = "query-batch"
+	ProbeMode         = "probe"
+	MutateMode        = "mutate"
 )
 
 var AllModes = []string{
@@ -35,8 +40,11 @@ var AllModes = []string{
 	ExplainMode,
 	LintMode,
 	QueryTrafficMode,
+	QueryExternalMode,
 	QueryTargetMode,
+	QueryBatchMode,
 	ProbeMode,
+	MutateMode,
 }
 
 type AnalyzeArgs struct {
@@ -45,18 +53,41 @@ type AnalyzeArgs struct {
 	UseExamplePolicies bool
 	PolicyPath         string
 	Context            string
+	SnapshotDir        string
+	CiliumPolicyPath   string
+	CalicoPolicyPath   string
+	AntreaPolicyPath   string
 	SimplifyPolicies   bool
 
 	Modes []string
 
+	// lint
+	SARIFPath       string
+	AdminPolicyPath string
+	LintConfigPath  string
+
 	// traffic
 	TrafficPath string
+	TraceAsJSON bool
+
+	// traffic from outside the cluster
+	ExternalSourceIP     string
+	ExternalDestinations []string
+	ExternalPort         string
+	ExternalProtocol     string
 
 	// targets
 	TargetPodPath string
 
+	// batch of traffic queries
+	QueriesPath string
+
 	// synthetic probe
 	ProbePath string
+
+	// what-if analysis
+	WhatIfPath   string
+	WhatIfDelete []string
 }
 
 func SetupAnalyzeCommand() *cobra.Command {
@@ -67,32 +98,112 @@ func SetupAnalyzeCommand() *cobra.Command {
 		Short: "analyze network policies",
 		Args:  cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, as []string) {
+			if (args.WhatIfPath != "" || len(args.WhatIfDelete) > 0) && !cmd.Flags().Changed("mode") {
+				args.Modes = nil
+			}
 			RunAnalyzeCommand(args)
 		},
 	}
 
+	setupPolicySourceFlags(command, args)
+
+	command.Flags().StringSliceVar(&args.Modes, "mode", []string{ExplainMode}, "analysis modes to run; allowed values are "+strings.Join(AllModes, ","))
+
+	command.Flags().StringVar(&args.TargetPodPath, "target-pod-path", "", "path to json target pod file -- json array of dicts")
+	command.Flags().StringVar(&args.QueriesPath, "queries-path", "", "path to a yaml list, or csv file, of traffic queries -- each a source pod or IP, a destination pod, a port, and optionally a protocol and an expected verdict of allow or deny -- for "+QueryBatchMode+" mode; exits non-zero if any query doesn't get its expected verdict")
+	command.Flags().StringVar(&args.TrafficPath, "traffic-path", "", "path to json traffic file, containing of a list of traffic objects")
+	command.Flags().BoolVar(&args.TraceAsJSON, "trace-json", false, "if true, "+QueryTrafficMode+" mode prints each verdict's trace -- which policies, rules, peers and ports matched or didn't, and what decided the traffic -- as json, instead of as tables")
+	command.Flags().StringVar(&args.ProbePath, "probe-path", "", "path to json model file for synthetic probe; for "+MutateMode+" mode, its resources are used if no pods were read from a snapshot or kube")
+	command.Flags().StringVar(&args.WhatIfPath, "what-if", "", "file or directory of proposed policies: prints the probes whose simulated connectivity would change if they were added -- replacing any policies of the same namespace and name -- to the policies read; unless --mode is set, no other analysis is run.  Pods are read as for "+MutateMode+" mode")
+	command.Flags().StringSliceVar(&args.WhatIfDelete, "what-if-delete", []string{}, "policies, as 'namespace/name', to simulate deleting, along with adding those from --what-if")
+	command.Flags().StringVar(&args.AdminPolicyPath, "admin-policy-path", "", "file or directory of AdminNetworkPolicies for "+LintMode+" mode to check for rules which can never match")
+	command.Flags().StringVar(&args.LintConfigPath, "lint-config", "", "yaml file enabling or disabling "+LintMode+" mode's checks, and setting their severities -- info, warning or error; exits non-zero if any warnings have severity error")
+	command.Flags().StringVar(&args.SARIFPath, "sarif-file", "", "path to write "+LintMode+" mode's warnings to as SARIF, for code scanning annotations on the --policy-path files they're about; paths are as found from --policy-path, so run from the repository's root")
+
+	command.Flags().StringVar(&args.ExternalSourceIP, "external-source-ip", "", "IP outside the cluster to query ingress from, for "+QueryExternalMode+" mode")
+	command.Flags().StringSliceVar(&args.ExternalDestinations, "external-destination", []string{}, "pods, as 'namespace/name', to explain ingress from --external-source-ip to, for "+QueryExternalMode+" mode; if empty, summarizes which of all pods it can reach")
+	command.Flags().StringVar(&args.ExternalPort, "external-port", "80", "destination port -- numbered or named -- for "+QueryExternalMode+" mode")
+	command.Flags().StringVar(&args.ExternalProtocol, "external-protocol", "tcp", "destination protocol for "+QueryExternalMode+" mode")
+
+	return command
+}
+
+func setupPolicySourceFlags(command *cobra.Command, args *AnalyzeArgs) {
 	command.Flags().BoolVar(&args.UseExamplePolicies, "use-example-policies", false, "if true, reads example policies")
 	command.Flags().BoolVarP(&args.AllNamespaces, "all-namespaces", "A", false, "reads kube resources from all namespaces; same as kubectl's '--all-namespaces'/'-A' flag")
 	command.Flags().StringSliceVarP(&args.Namespaces, "namespace", "n", []string{}, "namespaces to read kube resources from; similar to kubectl's '--namespace'/'-n' flag, except that multiple namespaces may be passed in and is empty if not set explicitly (instead of 'default' as in kubectl)")
 	command.Flags().StringVar(&args.PolicyPath, "policy-path", "", "may be a file or a directory; if set, will attempt to read policies from the path")
 	command.Flags().StringVar(&args.Context, "context", "", "selects kube context to read policies from; only reads from kube if one or more namespaces or all namespaces are specified")
+	command.Flags().StringVar(&args.SnapshotDir, "snapshot-dir", "", "directory of yaml/json cluster dumps (such as from 'kubectl get -o yaml' or must-gather); if set, namespaces, pods, and policies are read from here instead of from kube.  Use namespace flags to restrict which namespaces are used")
+	command.Flags().StringVar(&args.CiliumPolicyPath, "cilium-policy-path", "", "file or directory of CiliumNetworkPolicies and CiliumClusterwideNetworkPolicies to simulate along with the network policies read; they're also read from a snapshot or kube")
+	command.Flags().StringVar(&args.CalicoPolicyPath, "calico-policy-path", "", "file or directory of Calico NetworkPolicies, GlobalNetworkPolicies and Tiers to simulate along with the network policies read; they're also read from a snapshot or kube")
+	command.Flags().StringVar(&args.AntreaPolicyPath, "antrea-policy-path", "", "file or directory of Antrea ClusterNetworkPolicies, NetworkPolicies and Tiers to simulate along with the network policies read; they're also read from a snapshot or kube")
 	command.Flags().BoolVar(&args.SimplifyPolicies, "simplify-policies", true, "if true, reduce policies to simpler form while preserving semantics")
+}
 
-	command.Flags().StringSliceVar(&args.Modes, "mode", []string{ExplainMode}, "analysis modes to run; allowed values are "+strings.Join(AllModes, ","))
+func RunAnalyzeCommand(args *AnalyzeArgs) {
+	kubePolicies, locations, kubePods, kubeNamespaces, cni := readPoliciesAndPods(args)
 
-	command.Flags().StringVar(&args.TargetPodPath, "target-pod-path", "", "path to json target pod file -- json array of dicts")
-	command.Flags().StringVar(&args.TrafficPath, "traffic-path", "", "path to json traffic file, containing of a list of traffic objects")
-	command.Flags().StringVar(&args.ProbePath, "probe-path", "", "path to json model file for synthetic probe")
+	logrus.Debugf("parsed policies:\n%s", utils.JsonString(kubePolicies))
+	policies := matcher.BuildNetworkPolicies(args.SimplifyPolicies, kubePolicies)
+	cni.addTo(policies)
 
-	return command
+	for _, mode := range args.Modes {
+		switch mode {
+		case ParseMode:
+			ParsePolicies(kubePolicies)
+		case ExplainMode:
+			ExplainPolicies(policies)
+		case LintMode:
+			Lint(kubePolicies, kubePods, locations, args)
+		case QueryTargetMode:
+			pods := make([]*QueryTargetPod, len(kubePods))
+			for i, p := range kubePods {
+				pods[i] = &QueryTargetPod{
+					Namespace: p.Namespace,
+					Labels:    p.Labels,
+				}
+			}
+			QueryTargets(policies, args.TargetPodPath, pods)
+		case QueryTrafficMode:
+			QueryTraffic(policies, args.TrafficPath, args.TraceAsJSON)
+		case QueryExternalMode:
+			QueryExternalTraffic(policies, kubePods, kubeNamespaces, args)
+		case QueryBatchMode:
+			QueryBatchTraffic(policies, kubePods, kubeNamespaces, args.QueriesPath)
+		case ProbeMode:
+			ProbeSyntheticConnectivity(policies, args.ProbePath, kubePods, kubeNamespaces)
+		case MutateMode:
+			MutatePolicies(kubePolicies, args.SimplifyPolicies, args.ProbePath, kubePods, kubeNamespaces)
+		default:
+			panic(errors.Errorf("unrecognized mode %s", mode))
+		}
+	}
+	if args.WhatIfPath != "" || len(args.WhatIfDelete) > 0 {
+		WhatIf(kubePolicies, cni, args, kubePods, kubeNamespaces)
+	}
 }
 
-func RunAnalyzeCommand(args *AnalyzeArgs) {
+// readPoliciesAndPods reads policies, pods, and namespaces from a snapshot or kube, and policies from a path and
+// the examples, as selected by args.  Policies from the path also have the locations they were read from.  CNI
+// policies -- Cilium's, Calico's and Antrea's -- are read from a snapshot or kube, and from their own paths.
+func readPoliciesAndPods(args *AnalyzeArgs) ([]*networkingv1.NetworkPolicy, map[*networkingv1.NetworkPolicy]*linter.SourceLocation, []v1.Pod, []v1.Namespace, *cniPolicies) {
 	// 1. read policies from kube
 	var kubePolicies []*networkingv1.NetworkPolicy
 	var kubePods []v1.Pod
 	var kubeNamespaces []v1.Namespace
-	if args.AllNamespaces || len(args.Namespaces) > 0 {
+	var cniSnapshots []*kube.Snapshot
+	if args.SnapshotDir != "" {
+		snapshot, err := kube.ReadSnapshot(args.SnapshotDir)
+		utils.DoOrDie(err)
+		if !args.AllNamespaces && len(args.Namespaces) > 0 {
+			snapshot = snapshot.InNamespaces(args.Namespaces)
+		}
+		kubeNamespaces = snapshot.Namespaces
+		kubePods = snapshot.Pods
+		kubePolicies = refNetpolList(snapshot.NetworkPolicies)
+		cniSnapshots = append(cniSnapshots, snapshot)
+	} else if args.AllNamespaces || len(args.Namespaces) > 0 {
 		kubeClient, err := kube.NewKubernetesForContext(args.Context)
 		utils.DoOrDie(err)
 
@@ -102,49 +213,43 @@ func RunAnalyzeCommand(args *AnalyzeArgs) {
 			utils.DoOrDie(err)
 			kubeNamespaces = nsList.Items
 			namespaces = []string{v1.NamespaceAll}
+		} else {
+			for _, ns := range namespaces {
+				kubeNamespace, err := kubeClient.GetNamespace(ns)
+				utils.DoOrDie(err)
+				kubeNamespaces = append(kubeNamespaces, *kubeNamespace)
+			}
 		}
 		kubePolicies, err = readPoliciesFromKube(kubeClient, namespaces)
 		kubePods, err = kube.GetPodsInNamespaces(kubeClient, namespaces)
+		cniSnapshot, err := readCNIPoliciesFromKube(kubeClient, namespaces)
+		utils.DoOrDie(err)
+		cniSnapshots = append(cniSnapshots, cniSnapshot)
 	}
 	// 2. read policies from file
+	var locations map[*networkingv1.NetworkPolicy]*linter.SourceLocation
 	if args.PolicyPath != "" {
-		policiesFromPath, err := readPoliciesFromPath(args.PolicyPath)
+		policiesFromPath, locationsFromPath, err := readPoliciesWithLocationsFromPath(args.PolicyPath)
 		utils.DoOrDie(err)
+		locations = locationsFromPath
 		kubePolicies = append(kubePolicies, policiesFromPath...)
 	}
+	for _, cniPolicyPath := range []string{args.CiliumPolicyPath, args.CalicoPolicyPath, args.AntreaPolicyPath} {
+		if cniPolicyPath != "" {
+			cniSnapshot, err := kube.ReadSnapshot(cniPolicyPath)
+			utils.DoOrDie(err)
+			cniSnapshots = append(cniSnapshots, cniSnapshot)
+		}
+	}
 	// 3. read example policies
 	if args.UseExamplePolicies {
 		kubePolicies = append(kubePolicies, netpol.AllExamples...)
 	}
 
-	logrus.Debugf("parsed policies:\n%s", utils.JsonString(kubePolicies))
-	policies := matcher.BuildNetworkPolicies(args.SimplifyPolicies, kubePolicies)
+	cni, err := buildCNIPolicies(cniSnapshots)
+	utils.DoOrDie(err)
 
-	for _, mode := range args.Modes {
-		switch mode {
-		case ParseMode:
-			ParsePolicies(kubePolicies)
-		case ExplainMode:
-			ExplainPolicies(policies)
-		case LintMode:
-			Lint(kubePolicies)
-		case QueryTargetMode:
-			pods := make([]*QueryTargetPod, len(kubePods))
-			for i, p := range kubePods {
-				pods[i] = &QueryTargetPod{
-					Namespace: p.Namespace,
-					Labels:    p.Labels,
-				}
-			}
-			QueryTargets(policies, args.TargetPodPath, pods)
-		case QueryTrafficMode:
-			QueryTraffic(policies, args.TrafficPath)
-		case ProbeMode:
-			ProbeSyntheticConnectivity(policies, args.ProbePath, kubePods, kubeNamespaces)
-		default:
-			panic(errors.Errorf("unrecognized mode %s", mode))
-		}
-	}
+	return kubePolicies, locations, kubePods, kubeNamespaces, cni
 }
 
 func ParsePolicies(kubePolicies []*networkingv1.NetworkPolicy) {
@@ -155,9 +260,34 @@ func ExplainPolicies(explainedPolicies *matcher.Policy) {
 	fmt.Printf("%s\n", explainedPolicies.ExplainTable())
 }
 
-func Lint(kubePolicies []*networkingv1.NetworkPolicy) {
-	warnings := linter.Lint(kubePolicies, map[linter.Check]bool{})
+func Lint(kubePolicies []*networkingv1.NetworkPolicy, kubePods []v1.Pod, locations map[*networkingv1.NetworkPolicy]*linter.SourceLocation, args *AnalyzeArgs) {
+	var adminPolicies []*anp.AdminNetworkPolicy
+	if args.AdminPolicyPath != "" {
+		var err error
+		adminPolicies, err = readAdminPoliciesFromPath(args.AdminPolicyPath)
+		utils.DoOrDie(err)
+	}
+	var config *linter.Config
+	if args.LintConfigPath != "" {
+		var err error
+		config, err = linter.LoadConfig(args.LintConfigPath)
+		utils.DoOrDie(err)
+	}
+	warnings := linter.Lint(&linter.Input{Policies: kubePolicies, AdminPolicies: adminPolicies, Pods: kubePods}, config)
 	fmt.Println(linter.WarningsTable(warnings))
+	if args.SARIFPath != "" {
+		utils.DoOrDie(linter.SARIF(warnings, locations, version).Write(args.SARIFPath))
+		logrus.Infof("wrote %d lint warnings to %s", len(warnings), args.SARIFPath)
+	}
+	errorCount := 0
+	for _, warning := range warnings {
+		if warning.Severity == linter.SeverityError {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		utils.DoOrDie(errors.Errorf("%d of %d lint warnings have severity %s", errorCount, len(warnings), linter.SeverityError))
+	}
 }
 
 // QueryTargetPod matches targets; targets exist in only a single namespace and can't be matched by namespace
@@ -206,7 +336,7 @@ func QueryTargetHelper(policies *matcher.Policy, pod *QueryTargetPod) (*matcher.
 	return matcher.NewPolicyWithTargets(ingressTargets, egressTargets), matcher.NewPolicyWithTargets(combinedIngresses, combinedEgresses)
 }
 
-func QueryTraffic(explainedPolicies *matcher.Policy, trafficPath string) {
+func QueryTraffic(explainedPolicies *matcher.Policy, trafficPath string, traceAsJSON bool) {
 	var allTraffics []*matcher.Traffic
 	if trafficPath == "" {
 		logrus.Fatalf("%+v", errors.Errorf("path to traffic file required for QueryTraffic command"))
@@ -216,11 +346,56 @@ func QueryTraffic(explainedPolicies *matcher.Policy, trafficPath string) {
 	err = json.Unmarshal(allTrafficBytes, &allTraffics)
 	utils.DoOrDie(err)
 
+	if traceAsJSON {
+		type tracedTraffic struct {
+			Traffic *matcher.Traffic
+			Trace   *matcher.TrafficTrace
+		}
+		var traced []*tracedTraffic
+		for _, traffic := range allTraffics {
+			traced = append(traced, &tracedTraffic{Traffic: traffic, Trace: explainedPolicies.TraceTraffic(traffic)})
+		}
+		fmt.Println(utils.JsonString(traced))
+		return
+	}
+
 	for _, traffic := range allTraffics {
 		fmt.Printf("Traffic:\n%s\n", traffic.Table())
 
 		result := explainedPolicies.IsTrafficAllowed(traffic)
-		fmt.Printf("Is traffic allowed?\n%s\n\n\n", result.Table())
+		fmt.Printf("Is traffic allowed?\n%s\n", result.Table())
+
+		fmt.Printf("Trace:\n%s\n\n\n", explainedPolicies.TraceTraffic(traffic).Table())
+	}
+}
+
+// QueryExternalTraffic answers whether an IP outside the cluster can reach pods, which only depends on the pods'
+// ingress policies
+func QueryExternalTraffic(explainedPolicies *matcher.Policy, kubePods []v1.Pod, kubeNamespaces []v1.Namespace, args *AnalyzeArgs) {
+	if args.ExternalSourceIP == "" {
+		utils.DoOrDie(errors.Errorf("--external-source-ip required for %s mode", QueryExternalMode))
+	}
+	shell := NewShell(explainedPolicies, kubePods, kubeNamespaces)
+	if len(args.ExternalDestinations) == 0 {
+		utils.DoOrDie(shell.fromExternal([]string{args.ExternalSourceIP, args.ExternalPort, args.ExternalProtocol}))
+		return
+	}
+	for _, destination := range args.ExternalDestinations {
+		utils.DoOrDie(shell.traffic([]string{args.ExternalSourceIP, destination, args.ExternalPort, args.ExternalProtocol}))
+		fmt.Println()
+	}
+}
+
+// QueryBatchTraffic answers each of a file's traffic queries, failing if any doesn't get its expected verdict --
+// so that connectivity requirements can be checked in CI
+func QueryBatchTraffic(explainedPolicies *matcher.Policy, kubePods []v1.Pod, kubeNamespaces []v1.Namespace, queriesPath string) {
+	if queriesPath == "" {
+		utils.DoOrDie(errors.Errorf("--queries-path required for %s mode", QueryBatchMode))
+	}
+	queries, err := readBatchQueries(queriesPath)
+	utils.DoOrDie(err)
+	if failed := QueryBatch(explainedPolicies, queries, NewShell(explainedPolicies, kubePods, kubeNamespaces)); failed > 0 {
+		utils.DoOrDie(errors.Errorf("%d of %d queries from %s failed", failed, len(queries), queriesPath))
 	}
 }
 
@@ -252,6 +427,15 @@ func ProbeSyntheticConnectivity(explainedPolicies *matcher.Policy, modelPath str
 		}
 	}
 
+	simRunner := probe.NewSimulatedRunner(explainedPolicies)
+	simulatedProbe := simRunner.RunProbeForConfig(generator.ProbeAllAvailable, syntheticResources(kubePods, kubeNamespaces))
+	fmt.Printf("Ingress:\n%s\n", simulatedProbe.RenderIngress())
+	fmt.Printf("Egress:\n%s\n", simulatedProbe.RenderEgress())
+	fmt.Printf("Combined:\n%s\n\n\n", simulatedProbe.RenderTable())
+}
+
+// syntheticResources models kubePods, probed on the first port of each of their containers, for simulation
+func syntheticResources(kubePods []v1.Pod, kubeNamespaces []v1.Namespace) *probe.Resources {
 	resources := &probe.Resources{
 		Namespaces: map[string]map[string]string{},
 		Pods:       []*probe.Pod{},
@@ -271,10 +455,15 @@ func ProbeSyntheticConnectivity(explainedPolicies *matcher.Policy, modelPath str
 				continue
 			}
 			port := cont.Ports[0]
+			protocol := port.Protocol
+			if protocol == "" {
+				// the API server defaults the protocol, but dumps edited by hand may not have it
+				protocol = v1.ProtocolTCP
+			}
 			containers = append(containers, &probe.Container{
 				Name:     cont.Name,
 				Port:     int(port.ContainerPort),
-				Protocol: port.Protocol,
+				Protocol: protocol,
 				PortName: port.Name,
 			})
 		}
@@ -290,10 +479,99 @@ func ProbeSyntheticConnectivity(explainedPolicies *matcher.Policy, modelPath str
 			Containers: containers,
 		})
 	}
+	return resources
+}
 
-	simRunner := probe.NewSimulatedRunner(explainedPolicies)
-	simulatedProbe := simRunner.RunProbeForConfig(generator.ProbeAllAvailable, resources)
-	fmt.Printf("Ingress:\n%s\n", simulatedProbe.RenderIngress())
-	fmt.Printf("Egress:\n%s\n", simulatedProbe.RenderEgress())
-	fmt.Printf("Combined:\n%s\n\n\n", simulatedProbe.RenderTable())
+// MutatePolicies reports which small edits to kubePolicies -- flipped selectors, removed rules, changed CIDRs --
+// change the simulated connectivity between the pods, which shows how sensitive the policies are to edits
+func MutatePolicies(kubePolicies []*networkingv1.NetworkPolicy, simplify bool, modelPath string, kubePods []v1.Pod, kubeNamespaces []v1.Namespace) {
+	resources := simulationResources(MutateMode+" mode", modelPath, kubePods, kubeNamespaces)
+	report := mutation.Analyze(kubePolicies, resources, simplify)
+	fmt.Printf("Mutants:\n%s\n", report.Table())
+}
+
+// simulationResources are the pods read from a snapshot or kube -- or, if there aren't any, those of the
+// --probe-path model -- to simulate connectivity between, for analysis
+func simulationResources(analysis string, modelPath string, kubePods []v1.Pod, kubeNamespaces []v1.Namespace) *probe.Resources {
+	resources := syntheticResources(kubePods, kubeNamespaces)
+	if len(resources.Pods) == 0 && modelPath != "" {
+		bs, err := ioutil.ReadFile(modelPath)
+		utils.DoOrDie(errors.Wrapf(err, "unable to read file %s", modelPath))
+		config := &SyntheticProbeConnectivityConfig{}
+		err = json.Unmarshal(bs, &config)
+		utils.DoOrDie(errors.Wrapf(err, "unable to unmarshal json"))
+		resources = config.Resources
+	}
+	if resources == nil || len(resources.Pods) == 0 {
+		utils.DoOrDie(errors.Errorf("%s needs pods to simulate connectivity between: read them with --snapshot-dir, --namespace or --all-namespaces, or model them with --probe-path", analysis))
+	}
+	return resources
+}
+
+// WhatIf prints the probes whose simulated connectivity changes if the --what-if policies are added to kubePolicies,
+// and the --what-if-delete policies are deleted
+func WhatIf(kubePolicies []*networkingv1.NetworkPolicy, cni *cniPolicies, args *AnalyzeArgs, kubePods []v1.Pod, kubeNamespaces []v1.Namespace) {
+	var proposed []*networkingv1.NetworkPolicy
+	if args.WhatIfPath != "" {
+		var err error
+		proposed, err = readPoliciesFromPath(args.WhatIfPath)
+		utils.DoOrDie(err)
+	}
+	after, changes, err := whatIfPolicies(kubePolicies, proposed, args.WhatIfDelete)
+	utils.DoOrDie(err)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+
+	resources := simulationResources("--what-if", args.ProbePath, kubePods, kubeNamespaces)
+	before := matcher.BuildNetworkPolicies(args.SimplifyPolicies, kubePolicies)
+	cni.addTo(before)
+	afterPolicies := matcher.BuildNetworkPolicies(args.SimplifyPolicies, after)
+	cni.addTo(afterPolicies)
+	diff := probe.NewSimulatedDiff(before, afterPolicies, resources)
+	fmt.Printf("Connectivity changes:\n%s\n", diff.Table())
+}
+
+// whatIfPolicies deletes the policies named by deleted from current, then adds proposed, each replacing the current
+// policy of the same namespace and name if there is one; it describes each change
+func whatIfPolicies(current []*networkingv1.NetworkPolicy, proposed []*networkingv1.NetworkPolicy, deleted []string) ([]*networkingv1.NetworkPolicy, []string, error) {
+	key := func(policy *networkingv1.NetworkPolicy) string {
+		namespace := policy.Namespace
+		if namespace == "" {
+			namespace = v1.NamespaceDefault
+		}
+		return namespace + "/" + policy.Name
+	}
+	var changes []string
+	isDeleted := map[string]bool{}
+	for _, name := range deleted {
+		isDeleted[name] = true
+	}
+	var after []*networkingv1.NetworkPolicy
+	indexes := map[string]int{}
+	for _, policy := range current {
+		if isDeleted[key(policy)] {
+			changes = append(changes, fmt.Sprintf("deleting policy %s", key(policy)))
+			delete(isDeleted, key(policy))
+			continue
+		}
+		indexes[key(policy)] = len(after)
+		after = append(after, policy)
+	}
+	for _, name := range deleted {
+		if isDeleted[name] {
+			return nil, nil, errors.Errorf("unable to delete policy %s: not found", name)
+		}
+	}
+	for _, policy := range proposed {
+		if i, ok := indexes[key(policy)]; ok {
+			changes = append(changes, fmt.Sprintf("replacing policy %s", key(policy)))
+			after[i] = policy
+		} else {
+			changes = append(changes, fmt.Sprintf("adding policy %s", key(policy)))
+			indexes[key(policy)] = len(after)
+			after = append(after, policy)
+		}
+	}
+	return after, changes, nil
 }
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// BatchQuery is traffic to get a verdict for: from a pod -- or an IP outside the cluster -- to a pod, on a numbered
+// or named port.  If Expect is set, to allow or deny, the verdict is checked against it.
+type BatchQuery struct {
+	Source      string
+	Destination string
+	Port        intstr.IntOrString
+	Protocol    string `json:",omitempty"`
+	Expect      string `json:",omitempty"`
+}
+
+// readBatchQueries reads queries from a yaml/json list, or -- if path ends in .csv -- from rows of
+// 'source,destination,port[,protocol[,expect]]'.  Blank lines, lines starting with '#', and a first row starting with
+// 'source' -- a header -- are skipped.
+func readBatchQueries(path string) ([]*BatchQuery, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read file %s", path)
+	}
+	if strings.ToLower(filepath.Ext(path)) != ".csv" {
+		var queries []*BatchQuery
+		err = yaml.Unmarshal(bs, &queries)
+		return queries, errors.Wrapf(err, "unable to unmarshal queries from %s", path)
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(bs)))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var queries []*BatchQuery
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return queries, nil
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "unable to read csv from %s", path)
+		}
+		if row == 1 && strings.EqualFold(record[0], "source") {
+			continue
+		}
+		if len(record) < 3 || len(record) > 5 {
+			return nil, errors.Errorf("%s row %d: expected 'source,destination,port[,protocol[,expect]]', found %d fields", path, row, len(record))
+		}
+		query := &BatchQuery{Source: record[0], Destination: record[1], Port: intstr.Parse(record[2])}
+		if len(record) > 3 {
+			query.Protocol = record[3]
+		}
+		if len(record) > 4 {
+			query.Expect = record[4]
+		}
+		queries = append(queries, query)
+	}
+}
+
+// QueryBatch gives a verdict for each query, in a table, and returns how many queries didn't get the verdict they
+// expected or couldn't be answered
+func QueryBatch(explainedPolicies *matcher.Policy, queries []*BatchQuery, shell *Shell) int {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Source", "Destination", "Port", "Ingress", "Egress", "Verdict", "Expected", "Result"})
+	failed := 0
+	for _, query := range queries {
+		row, ok := batchQueryRow(explainedPolicies, query, shell)
+		if !ok {
+			failed++
+		}
+		table.Append(row)
+	}
+	table.Render()
+	fmt.Printf("%s%d of %d queries failed\n", str.String(), failed, len(queries))
+	return failed
+}
+
+func batchQueryRow(explainedPolicies *matcher.Policy, query *BatchQuery, shell *Shell) ([]string, bool) {
+	row := []string{query.Source, query.Destination, query.Port.String()}
+	fail := func(err error) ([]string, bool) {
+		return append(row, "", "", "", query.Expect, "error: "+err.Error()), false
+	}
+
+	var protocolArgs []string
+	if query.Protocol != "" {
+		protocolArgs = []string{query.Protocol}
+	}
+	protocol, err := parseShellProtocol(protocolArgs)
+	if err != nil {
+		return fail(err)
+	}
+	row[2] = fmt.Sprintf("%s/%s", protocol, query.Port.String())
+	var expectAllowed bool
+	switch strings.ToLower(query.Expect) {
+	case "":
+	case "allow", "allowed":
+		expectAllowed = true
+	case "deny", "denied", "block", "blocked":
+		expectAllowed = false
+	default:
+		return fail(errors.Errorf("invalid expectation '%s'; expected allow or deny", query.Expect))
+	}
+	traffic, err := shell.buildTraffic(query.Source, query.Destination, query.Port.String(), protocol)
+	if err != nil {
+		return fail(err)
+	}
+
+	result := explainedPolicies.IsTrafficAllowed(traffic)
+	row = append(row, allowedString(result.Ingress.IsAllowed()), allowedString(result.Egress.IsAllowed()), allowedString(result.IsAllowed()), query.Expect)
+	if query.Expect == "" {
+		return append(row, ""), true
+	}
+	if result.IsAllowed() != expectAllowed {
+		return append(row, "FAIL"), false
+	}
+	return append(row, "ok"), true
+}
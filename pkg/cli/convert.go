@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/conversion"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ConvertArgs struct {
+	PolicyPath  string
+	Priority    int
+	OutputDir   string
+	SnapshotDir string
+}
+
+func SetupConvertCommand() *cobra.Command {
+	args := &ConvertArgs{}
+
+	command := &cobra.Command{
+		Use:   "convert",
+		Short: "convert network policies to admin network policies, reporting what can't be expressed, and verify them with the policy simulator",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			RunConvertCommand(args)
+		},
+	}
+
+	command.Flags().StringVar(&args.PolicyPath, "policy-path", "", "path to a directory or file of network policies to convert")
+	utils.DoOrDie(command.MarkFlagRequired("policy-path"))
+	command.Flags().IntVar(&args.Priority, "priority", 100, "priority of the first admin network policy; the rest count up from it")
+	command.Flags().StringVar(&args.OutputDir, "output-dir", "", "directory to write a yaml file per admin network policy to; if empty, policies are printed as yaml documents")
+	command.Flags().StringVar(&args.SnapshotDir, "snapshot-dir", "", "snapshot of pods and namespaces to verify the conversion against; if empty, sample pods are modeled from the policies' selectors")
+
+	return command
+}
+
+func RunConvertCommand(args *ConvertArgs) {
+	policies, err := readPoliciesFromPath(args.PolicyPath)
+	utils.DoOrDie(err)
+	converted, err := conversion.Convert(policies, int32(args.Priority))
+	utils.DoOrDie(err)
+
+	files := map[string]interface{}{}
+	var names []string
+	for _, adminPolicy := range converted.AdminPolicies {
+		name := fmt.Sprintf("anp-%s.yaml", adminPolicy.Name)
+		files[name] = adminPolicy
+		names = append(names, name)
+	}
+	if converted.BaselinePolicy != nil {
+		name := fmt.Sprintf("banp-%s.yaml", converted.BaselinePolicy.Name)
+		files[name] = converted.BaselinePolicy
+		names = append(names, name)
+	}
+
+	if args.OutputDir != "" {
+		utils.DoOrDie(errors.Wrapf(os.MkdirAll(args.OutputDir, 0755), "unable to make directory %s", args.OutputDir))
+		for _, name := range names {
+			path := filepath.Join(args.OutputDir, name)
+			utils.DoOrDie(errors.Wrapf(ioutil.WriteFile(path, []byte(utils.YamlString(files[name])), 0644), "unable to write file %s", path))
+		}
+		logrus.Infof("wrote %d policies to %s", len(names), args.OutputDir)
+	} else {
+		var documents []string
+		for _, name := range names {
+			documents = append(documents, utils.YamlString(files[name]))
+		}
+		fmt.Print(strings.Join(documents, "---\n"))
+	}
+
+	fmt.Fprint(os.Stderr, converted.IssuesTable())
+
+	var resources *probe.Resources
+	if args.SnapshotDir != "" {
+		snapshot, err := kube.ReadSnapshot(args.SnapshotDir)
+		utils.DoOrDie(err)
+		resources = probe.NewResourcesFromKubePods(append(snapshot.Pods, snapshot.WorkloadPods...), snapshot.Namespaces)
+	} else {
+		resources = conversion.SampleResources(policies)
+	}
+	diff := conversion.Verify(policies, converted, resources)
+	if len(diff.Changes) > 0 {
+		utils.DoOrDie(errors.Errorf("converted policies don't decide traffic between pods like the network policies do -- before is the network policies, after the conversion:\n%s", diff.Table()))
+	}
+	logrus.Infof("verified converted policies against the network policies: %d probes match", diff.Probes)
+}
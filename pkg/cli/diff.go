@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+type DiffArgs struct {
+	BeforePath string
+	AfterPath  string
+	JSON       bool
+}
+
+func SetupDiffCommand() *cobra.Command {
+	args := &DiffArgs{}
+
+	command := &cobra.Command{
+		Use:   "diff",
+		Short: "report connectivity added and removed between two reachability matrices, i.e. before and after a policy change or CNI upgrade",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			RunDiffCommand(args)
+		},
+	}
+
+	command.Flags().StringVar(&args.BeforePath, "before", "", "reachability matrix to compare from: the csv or json output of 'reachability', or a "+connectivity.ResultsDocumentFileName+" from 'generate'")
+	utils.DoOrDie(command.MarkFlagRequired("before"))
+	command.Flags().StringVar(&args.AfterPath, "after", "", "reachability matrix to compare to, in any of the same formats as --before")
+	utils.DoOrDie(command.MarkFlagRequired("after"))
+	command.Flags().BoolVar(&args.JSON, "json", false, "if true, print the diff as json instead of a table")
+
+	return command
+}
+
+func RunDiffCommand(args *DiffArgs) {
+	before, err := connectivity.ReadReachabilityMatrix(args.BeforePath)
+	utils.DoOrDie(err)
+	after, err := connectivity.ReadReachabilityMatrix(args.AfterPath)
+	utils.DoOrDie(err)
+
+	diff := connectivity.NewReachabilityDiff(before, after)
+	if args.JSON {
+		fmt.Println(utils.JsonString(diff))
+	} else {
+		fmt.Print(diff.Table())
+	}
+}
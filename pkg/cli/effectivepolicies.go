@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+type EffectivePoliciesArgs struct {
+	AnalyzeArgs
+	Pods []string
+}
+
+func SetupEffectivePoliciesCommand() *cobra.Command {
+	args := &EffectivePoliciesArgs{}
+
+	command := &cobra.Command{
+		Use:   "effective-policies",
+		Short: "for each pod, synthesize a single network policy combining all the ingress and egress rules which apply to it",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			RunEffectivePoliciesCommand(args)
+		},
+	}
+
+	setupPolicySourceFlags(command, &args.AnalyzeArgs)
+	command.Flags().StringSliceVar(&args.Pods, "pod", []string{}, "pods, as 'ns/name', to synthesize policies for; if empty, synthesizes policies for all pods")
+
+	return command
+}
+
+func RunEffectivePoliciesCommand(args *EffectivePoliciesArgs) {
+	kubePolicies, _, kubePods, _, _ := readPoliciesAndPods(&args.AnalyzeArgs)
+	if len(kubePods) == 0 {
+		utils.DoOrDie(errors.Errorf("effective policies are per pod, but no pods were read: read them with --snapshot-dir, --namespace or --all-namespaces"))
+	}
+	pods, err := selectPods(kubePods, args.Pods)
+	utils.DoOrDie(err)
+
+	var documents []string
+	for _, pod := range pods {
+		policy := kube.EffectivePolicy(pod, kubePolicies)
+		if policy == nil {
+			logrus.Infof("no policies select pod %s/%s; all traffic to and from it is allowed", pod.Namespace, pod.Name)
+			continue
+		}
+		documents = append(documents, fmt.Sprintf("# effective policy of pod %s/%s\n%s", pod.Namespace, pod.Name, utils.YamlString(policy)))
+	}
+	fmt.Print(strings.Join(documents, "---\n"))
+}
+
+// selectPods picks out the pods named, as 'ns/name', in the order they're named; if none are named, it's all of them
+func selectPods(kubePods []v1.Pod, names []string) ([]v1.Pod, error) {
+	if len(names) == 0 {
+		return kubePods, nil
+	}
+	podsByName := map[string]v1.Pod{}
+	for _, pod := range kubePods {
+		podsByName[fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)] = pod
+	}
+	var selected []v1.Pod
+	for _, name := range names {
+		pod, ok := podsByName[name]
+		if !ok {
+			return nil, errors.Errorf("pod %s not found; expected 'ns/name' of a pod which was read", name)
+		}
+		selected = append(selected, pod)
+	}
+	return selected, nil
+}
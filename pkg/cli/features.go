@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+	"time"
+)
+
+// the feature checks rely on the default x/y/z, a/b/c fixture
+var (
+	featuresNamespaces = []string{"x", "y", "z"}
+	featuresPods       = []string{"a", "b", "c"}
+)
+
+const anpGroupVersion = "policy.networking.k8s.io/v1alpha1"
+
+type FeaturesArgs struct {
+	Context                   string
+	IgnoreLoopback            bool
+	PerturbationWaitSeconds   int
+	PodCreationTimeoutSeconds int
+	Retries                   int
+	ThrottleRetries           int
+	ThrottleBackoffSeconds    int
+	LeftoverResources         string
+	ServiceMesh               string
+	CleanupNamespaces         bool
+}
+
+func SetupFeaturesCommand() *cobra.Command {
+	args := &FeaturesArgs{}
+
+	command := &cobra.Command{
+		Use:   "features",
+		Short: "probe a cluster for support of optional network policy features",
+		Long:  "empirically check whether the cluster's CNI supports SCTP, endPort, named ports, ipBlocks matching pod IPs, IPv6 and AdminNetworkPolicy, and print a support matrix",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			ctx, cancel := runContext(cmd)
+			defer cancel()
+			RunFeaturesCommand(ctx, args)
+		},
+	}
+
+	command.Flags().StringVar(&args.Context, "context", "", "kubernetes context to use; if empty, uses default context")
+	command.Flags().BoolVar(&args.IgnoreLoopback, "ignore-loopback", false, "if true, ignore loopback for truthtable correctness verification")
+	command.Flags().IntVar(&args.PerturbationWaitSeconds, "perturbation-wait-seconds", 5, "number of seconds to wait after creating a network policy before running probes, to give the CNI time to update the cluster state")
+	command.Flags().IntVar(&args.PodCreationTimeoutSeconds, "pod-creation-timeout-seconds", 60, "number of seconds to wait for pods to create, be running and have IP addresses")
+	command.Flags().IntVar(&args.Retries, "retries", 1, "number of kube probe retries to allow, if probe results don't match expected results")
+	command.Flags().IntVar(&args.ThrottleRetries, "throttle-retries", 5, "number of retries for kube API calls rejected due to API server throttling")
+	command.Flags().IntVar(&args.ThrottleBackoffSeconds, "throttle-backoff-seconds", 1, "number of seconds to wait before the first retry of a throttled kube API call; doubles with each further retry")
+	command.Flags().StringVar(&args.LeftoverResources, "leftover-resources", connectivity.LeftoverModeWarn, "what to do about policies, pods and namespaces left over from a previous run; one of "+strings.Join(connectivity.AllLeftoverModes, ", "))
+	command.Flags().StringVar(&args.ServiceMesh, "service-mesh", probe.MeshModeWarn, "what to do about Istio/Linkerd sidecar injection in the server namespaces; one of "+strings.Join(probe.AllMeshModes, ", "))
+	command.Flags().BoolVar(&args.CleanupNamespaces, "cleanup-namespaces", false, "if true, clean up namespaces after completion")
+
+	return command
+}
+
+func RunFeaturesCommand(ctx context.Context, args *FeaturesArgs) {
+	kubeClient, err := kube.NewKubernetesForContext(args.Context)
+	utils.DoOrDie(err)
+	kubeClient.Context = ctx
+	kubernetes := kube.NewThrottleRetryingKubernetes(kubeClient, kube.RetryPolicy{
+		Retries: args.ThrottleRetries,
+		Backoff: time.Duration(args.ThrottleBackoffSeconds) * time.Second,
+	})
+
+	utils.DoOrDie(connectivity.HandleLeftoverResources(kubernetes, featuresNamespaces, featuresPods, args.LeftoverResources))
+
+	serverPorts, podOptions, err := probe.HandleServiceMeshes(kubernetes, featuresNamespaces, []int{80, 81}, args.ServiceMesh)
+	utils.DoOrDie(err)
+
+	protocols := []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP, v1.ProtocolSCTP}
+	resources, err := probe.NewDefaultResources(kubernetes, featuresNamespaces, featuresPods, serverPorts, protocols, args.PodCreationTimeoutSeconds, false, podOptions)
+	utils.DoOrDie(err)
+
+	interpreter, err := connectivity.NewInterpreter(kubernetes, resources, &connectivity.InterpreterConfig{
+		ResetClusterBeforeTestCase:       true,
+		KubeProbeRetries:                 args.Retries,
+		PerturbationWaitSeconds:          args.PerturbationWaitSeconds,
+		VerifyClusterStateBeforeTestCase: true,
+		IgnoreLoopback:                   args.IgnoreLoopback,
+		Context:                          ctx,
+	})
+	utils.DoOrDie(err)
+	stopOnInterrupt(interpreter)
+
+	zcPod, err := resources.GetPod("z", "c")
+	utils.DoOrDie(err)
+
+	results := connectivity.RunFeatureChecks(interpreter, generator.FeatureCheckTestCases(zcPod.IP), args.IgnoreLoopback)
+	results = append(results, connectivity.CheckIPv6Support(kubernetes, featuresNamespaces), checkANPSupport(kubeClient))
+
+	// don't leave the last feature check's policy lying around
+	if err := kube.DeleteAllNetworkPoliciesInNamespaces(kubernetes, featuresNamespaces); err != nil {
+		logrus.Warnf("%+v", err)
+	}
+	if args.CleanupNamespaces {
+		for _, ns := range featuresNamespaces {
+			logrus.Infof("cleaning up namespace %s", ns)
+			if err := kubernetes.DeleteNamespace(ns); err != nil {
+				logrus.Warnf("%+v", err)
+			}
+		}
+	}
+
+	fmt.Printf("feature support:\n%s\n", connectivity.RenderFeatureMatrix(results))
+}
+
+func checkANPSupport(kubeClient *kube.Kubernetes) *connectivity.FeatureCheckResult {
+	served, err := kubeClient.IsGroupVersionServed(anpGroupVersion)
+	if err != nil {
+		return &connectivity.FeatureCheckResult{Feature: generator.FeatureANP, Support: connectivity.FeatureSupportUnknown, Details: err.Error()}
+	} else if !served {
+		return &connectivity.FeatureCheckResult{Feature: generator.FeatureANP, Support: connectivity.FeatureUnsupported, Details: anpGroupVersion + " is not served"}
+	}
+	return &connectivity.FeatureCheckResult{Feature: generator.FeatureANP, Support: connectivity.FeatureSupported, Details: anpGroupVersion + " is served"}
+}
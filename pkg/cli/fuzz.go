@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"path/filepath"
+	"time"
+)
+
+type FuzzArgs struct {
+	Seed                      int64
+	Count                     int
+	CorpusDir                 string
+	AllowDNS                  bool
+	Noisy                     bool
+	IgnoreLoopback            bool
+	KubeContext               string
+	Mock                      bool
+	Retries                   int
+	PerturbationWaitSeconds   int
+	PodCreationTimeoutSeconds int
+	CleanupNamespaces         bool
+
+	// server setup
+	ServerProtocols  []string
+	ServerPorts      []int
+	ServerNamespaces []string
+	ServerPods       []string
+}
+
+func SetupFuzzCommand() *cobra.Command {
+	args := &FuzzArgs{}
+
+	command := &cobra.Command{
+		Use:   "fuzz",
+		Short: "differential testing: run randomly generated network policies against kubernetes, saving the ones where the cluster and the policy simulator disagree",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			ctx, cancel := runContext(cmd)
+			defer cancel()
+			RunFuzzCommand(ctx, args)
+		},
+	}
+
+	command.Flags().Int64Var(&args.Seed, "seed", 0, "seed to generate test cases from, to reproduce a previous run; if 0, a seed is picked and printed")
+	command.Flags().IntVar(&args.Count, "count", 100, "number of test cases to generate and run")
+	command.Flags().StringVar(&args.CorpusDir, "corpus-dir", "", "directory to save test cases in whose probes diverged from what the policy simulator says the policies allow -- each as yaml, which 'generate --test-file' can read, along with its network policies and expected truth table -- with an index of them in "+connectivity.ExportIndexFileName)
+	utils.DoOrDie(command.MarkFlagRequired("corpus-dir"))
+
+	command.Flags().StringSliceVarP(&args.ServerNamespaces, "server-namespace", "n", []string{"x", "y", "z"}, "namespaces to create/use pods in")
+	command.Flags().StringSliceVar(&args.ServerPods, "server-pod", []string{"a", "b", "c"}, "pods to create in namespaces")
+	command.Flags().IntSliceVar(&args.ServerPorts, "server-port", []int{80, 81}, "ports to run server on")
+	command.Flags().StringSliceVar(&args.ServerProtocols, "server-protocol", []string{"TCP", "UDP", "SCTP"}, "protocols to run server on")
+
+	command.Flags().BoolVar(&args.AllowDNS, "allow-dns", true, "if using egress, allow udp over port 53 for DNS resolution")
+	command.Flags().BoolVar(&args.Noisy, "noisy", false, "if true, print all results")
+	command.Flags().BoolVar(&args.IgnoreLoopback, "ignore-loopback", false, "if true, ignore loopback for truthtable correctness verification")
+	command.Flags().StringVar(&args.KubeContext, "context", "", "kubernetes context to use; if empty, uses default context")
+	command.Flags().BoolVar(&args.Mock, "mock", false, "if true, use a mock kube runner (i.e. don't actually run tests against kubernetes; instead, product fake results")
+	command.Flags().IntVar(&args.Retries, "retries", 1, "number of kube probe retries to allow, if probe results don't match expected results")
+	command.Flags().IntVar(&args.PerturbationWaitSeconds, "perturbation-wait-seconds", 5, "number of seconds to wait after perturbing the cluster (i.e. create a network policy, modify a ns/pod label) before running probes, to give the CNI time to update the cluster state")
+	command.Flags().IntVar(&args.PodCreationTimeoutSeconds, "pod-creation-timeout-seconds", 60, "number of seconds to wait for pods to create, be running and have IP addresses")
+	command.Flags().BoolVar(&args.CleanupNamespaces, "cleanup-namespaces", false, "if true, clean up namespaces after completion")
+
+	return command
+}
+
+func RunFuzzCommand(ctx context.Context, args *FuzzArgs) {
+	if len(args.ServerNamespaces) == 0 || len(args.ServerPods) == 0 || len(args.ServerPorts) == 0 || len(args.ServerProtocols) == 0 {
+		utils.DoOrDie(errors.Errorf("found 0 namespaces, pods, ports or protocols; must have at least 1 of each"))
+	}
+
+	var kubernetes kube.IKubernetes
+	var realClient *kube.Kubernetes
+	if args.Mock {
+		kubernetes = kube.NewMockKubernetes(1.0)
+	} else {
+		var err error
+		realClient, err = kube.NewKubernetesForContext(args.KubeContext)
+		utils.DoOrDie(err)
+		realClient.Context = ctx
+		kubernetes = realClient
+	}
+
+	serverProtocols := parseProtocols(args.ServerProtocols)
+	resources, err := probe.NewDefaultResources(kubernetes, args.ServerNamespaces, args.ServerPods, args.ServerPorts, serverProtocols, args.PodCreationTimeoutSeconds, false, nil)
+	utils.DoOrDie(err)
+
+	interpreter, err := connectivity.NewInterpreter(kubernetes, resources, &connectivity.InterpreterConfig{
+		ResetClusterBeforeTestCase:       true,
+		KubeProbeRetries:                 args.Retries,
+		PerturbationWaitSeconds:          args.PerturbationWaitSeconds,
+		VerifyClusterStateBeforeTestCase: true,
+		IgnoreLoopback:                   args.IgnoreLoopback,
+		Context:                          ctx,
+	})
+	utils.DoOrDie(err)
+	printer := &connectivity.Printer{
+		Noisy:          args.Noisy,
+		IgnoreLoopback: args.IgnoreLoopback,
+	}
+
+	seed := args.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fmt.Printf("fuzzing %d test cases with seed %d; rerun with '--seed %d' to reproduce them\n", args.Count, seed, seed)
+	fuzzer := generator.NewPolicyFuzzer(seed, args.AllowDNS, args.ServerNamespaces, args.ServerPods, args.ServerPorts, serverProtocols)
+
+	corpus := &connectivity.ExportIndex{}
+	report := &connectivity.DivergenceReport{}
+	run := 0
+	for ; run < args.Count && !interpreter.IsStopped(); run++ {
+		testCase := fuzzer.Next()
+		fmt.Printf("starting fuzz test case #%d: %s\n", run+1, testCase.Description)
+		result := interpreter.ExecuteTestCase(testCase)
+		printer.PrintTestCaseResult(result)
+		// only divergences between the matcher and the cluster are interesting: errors are the cluster's, not the
+		// policies'
+		if result.Interrupted || result.Err != nil {
+			continue
+		}
+		divergences := report.Add(result, args.IgnoreLoopback)
+		if len(divergences) == 0 {
+			continue
+		}
+		fmt.Printf("%d probes diverged from what the matcher says the policies allow\n", len(divergences))
+		saved, err := connectivity.ExportResult(args.CorpusDir, run+1, result)
+		utils.DoOrDie(err)
+		corpus.TestCases = append(corpus.TestCases, saved)
+		fmt.Printf("saved divergent test case to %s\n\n", filepath.Join(args.CorpusDir, saved.Directory))
+	}
+	if len(corpus.TestCases) > 0 {
+		_, err = corpus.WriteToDirectory(args.CorpusDir)
+		utils.DoOrDie(err)
+		path, err := report.WriteToDirectory(args.CorpusDir)
+		utils.DoOrDie(err)
+		fmt.Printf("wrote divergences to %s\n", path)
+	}
+	fmt.Printf("divergences between the matcher and the cluster, over %d fuzz test cases:\n%s\n", run, report.Table())
+
+	if ctx.Err() != nil && realClient != nil {
+		// the run's context is done, but cleanup still has to get through
+		realClient.Context = nil
+	}
+	if interpreter.IsStopped() {
+		logrus.Infof("cleaning up network policies in namespaces %+v", args.ServerNamespaces)
+		if err := kube.DeleteAllNetworkPoliciesInNamespaces(kubernetes, args.ServerNamespaces); err != nil {
+			logrus.Warnf("%+v", err)
+		}
+	}
+	if args.CleanupNamespaces {
+		for _, ns := range args.ServerNamespaces {
+			logrus.Infof("cleaning up namespace %s", ns)
+			if err := kubernetes.DeleteNamespace(ns); err != nil {
+				logrus.Warnf("%+v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/assertions"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+)
+
+type GateArgs struct {
+	PolicyPath     string
+	AssertionsPath string
+	WorkloadsPath  string
+	Context        string
+	Namespaces     []string
+	AllNamespaces  bool
+}
+
+func SetupGateCommand() *cobra.Command {
+	args := &GateArgs{}
+
+	command := &cobra.Command{
+		Use:   "gate",
+		Short: "check a proposed set of network policies against required-allow and required-deny assertions",
+		Long:  "statically evaluate network policies against assertions about which traffic must be allowed or denied, using workloads from manifests or a cluster, and exit non-zero if any assertion is violated; meant for running in CI on a repository of policies",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			ctx, cancel := runContext(cmd)
+			defer cancel()
+			RunGateCommand(ctx, args)
+		},
+	}
+
+	command.Flags().StringVar(&args.PolicyPath, "policies", "", "file or directory of network policies to check; only these policies are used, not the cluster's")
+	command.Flags().StringVar(&args.AssertionsPath, "assertions", "", "path to a yaml file of assertions: a list of 'assertions', each with a 'name', 'from' and 'to' peers (any of 'namespace', 'namespaceSelector' and 'podSelector'), a 'port', an optional 'protocol', and 'expect' of allow or deny")
+	command.Flags().StringVar(&args.WorkloadsPath, "workloads", "", "file or directory of manifests to read namespaces and workloads from -- pods, or controllers such as deployments, whose pod templates are used -- instead of the cluster")
+	command.Flags().StringVar(&args.Context, "context", "", "kube context to read workloads from, if not using --workloads; if empty, uses default context")
+	command.Flags().StringSliceVarP(&args.Namespaces, "namespace", "n", []string{}, "namespaces to read workloads from")
+	command.Flags().BoolVarP(&args.AllNamespaces, "all-namespaces", "A", false, "reads workloads from all namespaces")
+
+	return command
+}
+
+func RunGateCommand(ctx context.Context, args *GateArgs) {
+	if args.PolicyPath == "" || args.AssertionsPath == "" {
+		utils.DoOrDie(errors.Errorf("--policies and --assertions are required"))
+	}
+	gateAssertions, err := assertions.ReadAssertions(args.AssertionsPath)
+	utils.DoOrDie(err)
+	kubePolicies, err := readPoliciesFromPath(args.PolicyPath)
+	utils.DoOrDie(err)
+
+	kubePods, kubeNamespaces := readGateWorkloads(ctx, args)
+	resources := probe.NewResourcesFromKubePods(kubePods, kubeNamespaces)
+	logrus.Infof("checking %d assertions against %d policies and %d pods", len(gateAssertions.Assertions), len(kubePolicies), len(resources.Pods))
+
+	runner := probe.NewSimulatedRunner(matcher.BuildNetworkPolicies(true, kubePolicies))
+	report := assertions.Check(gateAssertions, resources, runner)
+	fmt.Printf("%s\n", report.Table())
+
+	if !report.Passed() {
+		logrus.Errorf("%d of %d assertions failed", report.FailedCount(), len(report.Results))
+		// logrus.Exit, unlike os.Exit, runs registered exit handlers
+		logrus.Exit(1)
+	}
+	fmt.Printf("all %d assertions passed\n", len(report.Results))
+}
+
+func readGateWorkloads(ctx context.Context, args *GateArgs) ([]v1.Pod, []v1.Namespace) {
+	if args.WorkloadsPath != "" {
+		snapshot, err := kube.ReadSnapshot(args.WorkloadsPath)
+		utils.DoOrDie(err)
+		if !args.AllNamespaces && len(args.Namespaces) > 0 {
+			snapshot = snapshot.InNamespaces(args.Namespaces)
+		}
+		return append(snapshot.Pods, snapshot.WorkloadPods...), snapshot.Namespaces
+	}
+
+	if !args.AllNamespaces && len(args.Namespaces) == 0 {
+		utils.DoOrDie(errors.Errorf("workloads are needed from --workloads, or from the cluster with --namespace or --all-namespaces"))
+	}
+	kubeClient, err := kube.NewKubernetesForContext(args.Context)
+	utils.DoOrDie(err)
+	kubeClient.Context = ctx
+	var kubeNamespaces []v1.Namespace
+	namespaces := args.Namespaces
+	if args.AllNamespaces {
+		nsList, err := kubeClient.GetAllNamespaces()
+		utils.DoOrDie(err)
+		kubeNamespaces = nsList.Items
+		namespaces = []string{v1.NamespaceAll}
+	} else {
+		for _, ns := range namespaces {
+			kubeNamespace, err := kubeClient.GetNamespace(ns)
+			utils.DoOrDie(err)
+			kubeNamespaces = append(kubeNamespaces, *kubeNamespace)
+		}
+	}
+	kubePods, err := kube.GetPodsInNamespaces(kubeClient, namespaces)
+	utils.DoOrDie(err)
+	return kubePods, kubeNamespaces
+}
@@ -1,24 +1,45 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/artifacts"
 	"github.com/mattfenwick/cyclonus/pkg/connectivity"
 	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
 	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kind"
 	"github.com/mattfenwick/cyclonus/pkg/kube"
 	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 )
 
 type GenerateArgs struct {
 	AllowDNS                  bool
 	Noisy                     bool
+	FailuresOnly              bool
+	CombinedView              bool
+	SlowestCount              int
+	HeatmapCount              int
 	IgnoreLoopback            bool
 	PerturbationWaitSeconds   int
 	PodCreationTimeoutSeconds int
 	Retries                   int
+	RetryBackoffSeconds       int
+	ExecRetries               int
+	ExecRetryBackoffSeconds   int
+	ThrottleRetries           int
+	ThrottleBackoffSeconds    int
 	BatchJobs                 bool
 	Context                   string
 	ServerPorts               []int
@@ -28,13 +49,96 @@ type GenerateArgs struct {
 	CleanupNamespaces         bool
 	Include                   []string
 	Exclude                   []string
+	Filter                    string
+	IncludeNames              []string
+	ExcludeNames              []string
 	DestinationType           string
 	Mock                      bool
 	DryRun                    bool
+	ArtifactsDir              string
+	JUnitReportPath           string
+	ResultsFilePath           string
+	HTMLReportDir             string
+	MetricsAddress            string
+	Parallelism               int
+	UploadURLs                []string
+	LeftoverResources         string
+	CrossModeCheck            bool
+	TemplatePath              string
+	TemplateValuesPath        string
+	TestCasePath              string
+	TestFiles                 []string
+	ExitCodes                 bool
+	ClientCommandsPath        string
+	ServiceMesh               string
+	FromResultsPath           string
+	OnlyFailed                bool
+	CheckpointPath            string
+	Resume                    bool
+	Shuffle                   bool
+	Seed                      int64
+	Repeat                    int
+	RecordKubePath            string
+	ReplayKubePath            string
+	RecordProbesPath          string
+	ReplayProbesPath          string
+	CNINamespace              string
+	CNIDaemonSet              string
+	CNIRestartNodes           []string
+	CNIRecoverySeconds        int
+	AttachNetworks            []string
+	ProbeNetworks             []string
+	DualStack                 bool
+	IgnoreProtocols           []string
+	IgnorePorts               []int
+	SkipIgnored               bool
+	OpenShift                 bool
+	OpenShiftRoutePort        int
+	NodeLabels                map[string]string
+	NodeSelector              string
+	ExpectationOverridesPath  string
+	ExpectationOverridesCNI   string
+	CanonicalOutput           bool
+	PolicyCoverage            string
+	UDPBurstSize              int
+	HTTPCheck                 bool
+	TLSCheck                  bool
+	MeasureLatency            bool
+	WarmUp                    bool
+	ExpectConnections         string
+	DNSCheck                  bool
+	DNSNamespace              string
+	DNSPodSelector            string
+	EgressTarget              string
+	EgressProxy               string
+	EgressGateway             string
+	ExternalEndpoint          string
+	DeployExternalEndpoint    int
+	DeployWorkerDaemonSet     int
+	MultiTargetExec           bool
+	SourcePodParallelism      int
+	Corroborator              string
+	DataplaneCommandPath      string
+	ExportDir                 string
+	Sonobuoy                  bool
+	CNIProfile                string
+	WaiversPath               string
+	FailureArtifactsDir       string
+	PacketCapture             bool
+	PacketCaptureImage        string
+	PacketCaptureSeconds      int
+	PacketCaptureMax          int
+	MinimizeFailures          bool
+	MinimizeMaxAttempts       int
 }
 
+// packetCapturesDirName is where --packet-capture saves captures, in the artifacts directory
+const packetCapturesDirName = "packet-captures"
+
 func SetupGenerateCommand() *cobra.Command {
 	args := &GenerateArgs{}
+	provision := ""
+	kindArgs := &KindArgs{Generate: args}
 
 	command := &cobra.Command{
 		Use:   "generate",
@@ -42,116 +146,666 @@ func SetupGenerateCommand() *cobra.Command {
 		Long:  "generate network policies, create and probe against kubernetes, and compare to expected results",
 		Args:  cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, as []string) {
-			RunGenerateCommand(args)
+			if args.Filter != "" && (cmd.Flags().Changed("include") || cmd.Flags().Changed("exclude")) {
+				utils.DoOrDie(errors.Errorf("--filter can't be used with --include or --exclude"))
+			}
+			applyCNIProfileFlags(cmd, args)
+			ctx, cancel := runContext(cmd)
+			defer cancel()
+			if provision == "" {
+				RunGenerateCommand(ctx, args)
+				return
+			}
+			cluster, err := provisionedCluster(provision, kindArgs)
+			utils.DoOrDie(err)
+			runGenerateOnKindCluster(ctx, cluster, kindArgs.KeepCluster, args)
 		},
 	}
 
+	setupGenerateFlags(command, args)
+
+	command.Flags().StringVar(&provision, "provision", "", "if set to 'kind', create a throwaway kind cluster -- requires kind, kubectl and docker -- run against it, and delete it afterwards, instead of using an existing cluster")
+	command.Flags().StringVar(&kindArgs.CNI, "provision-cni", kind.CNICalico, "CNI to install in the provisioned cluster; one of "+strings.Join(kind.AllCNIs(), ", "))
+	command.Flags().StringVar(&kindArgs.CNIManifest, "provision-cni-manifest", "", "path or URL of a manifest to apply to install the provisioned cluster's CNI, instead of --provision-cni; the cluster's default CNI is disabled")
+	command.Flags().StringVar(&kindArgs.ClusterName, "provision-cluster-name", "", "name of the provisioned cluster; if empty, uses 'netpol-<cni>'")
+	command.Flags().StringVar(&kindArgs.NodeImage, "provision-node-image", "", "kind node image to provision the cluster with; if empty, uses kind's default")
+	command.Flags().BoolVar(&kindArgs.KeepCluster, "keep-cluster", false, "if true, don't delete the provisioned cluster after the run")
+
+	return command
+}
+
+func setupGenerateFlags(command *cobra.Command, args *GenerateArgs) {
 	command.Flags().StringSliceVar(&args.ServerProtocols, "server-protocol", []string{"TCP", "UDP", "SCTP"}, "protocols to run server on")
 	command.Flags().IntSliceVar(&args.ServerPorts, "server-port", []int{80, 81}, "ports to run server on")
 	command.Flags().StringSliceVar(&args.ServerNamespaces, "namespace", []string{"x", "y", "z"}, "namespaces to create/use pods in")
 	command.Flags().StringSliceVar(&args.ServerPods, "pod", []string{"a", "b", "c"}, "pods to create in namespaces")
 
 	command.Flags().BoolVar(&args.BatchJobs, "batch-jobs", false, "if true, run jobs in batches to avoid saturating the Kube APIServer with too many exec requests")
-	command.Flags().IntVar(&args.Retries, "retries", 1, "number of kube probe retries to allow, if probe fails")
+	command.Flags().IntVar(&args.DeployWorkerDaemonSet, "deploy-worker-daemonset", 0, "if non-zero, deploy a privileged worker on every node, serving on this port on the node's IP, with a token made for the run, in namespace "+probe.WorkerDaemonSetNamespace+", and run jobs in batches through them -- sent over the API server's pod proxy, and issued from inside each source pod's network namespace -- instead of exec'ing into pods; the workers are deleted at the end of the run.  Incompatible with --batch-jobs, --client-commands, --udp-burst-size, --http-check, --tls-check and --measure-latency")
+	command.Flags().BoolVar(&args.MultiTargetExec, "multi-target-exec", false, "if true, run all of a pod's probes -- against every target at once -- in a single exec, instead of one exec per probe, so that a full matrix takes one exec per pod without needing the worker image; probes with --client-commands are still run one by one.  Incompatible with --batch-jobs, --deploy-worker-daemonset, --udp-burst-size, --http-check, --tls-check and --measure-latency")
+	command.Flags().IntVar(&args.SourcePodParallelism, "source-pod-parallelism", 0, "if positive, probe up to this many source pods at once within a step, each pod's probes one after another -- or, with --batch-jobs or --deploy-worker-daemonset, send up to this many pods' batches at once -- so that execs are spread across pods; if 0, up to 15 probes run at once, whichever pods they're from")
+	command.Flags().StringVar(&args.ClientCommandsPath, "client-commands", "", "path to a yaml file mapping protocols to probe command templates (a 'command' list of go templates rendered with the probe job, and an optional 'successRegex' for stdout), to use instead of agnhost; incompatible with --batch-jobs")
+	command.Flags().IntVar(&args.UDPBurstSize, "udp-burst-size", 0, "if positive, each UDP probe sends this many sequenced datagrams instead of one, and the delivery rate of each pair is reported, so that allowed but lossy paths stand out; a probe is allowed if any datagram gets a response.  Incompatible with --batch-jobs")
+	command.Flags().BoolVar(&args.HTTPCheck, "http-check", false, "if true, TCP servers answer HTTP, and each TCP probe, once connected, requests / and is only allowed if the serving pod answers -- so that connections accepted by a proxy, or blackholed after the handshake, aren't mistaken for allowed ones.  Incompatible with --batch-jobs")
+	command.Flags().BoolVar(&args.TLSCheck, "tls-check", false, "if true, TCP servers terminate TLS with a self-signed certificate made for the run, and each TCP probe, once connected, does a TLS handshake trusting only that certificate and requests the serving pod's hostname, and is only allowed if both work -- so that connections accepted by a service mesh's proxies aren't mistaken for allowed ones.  Incompatible with --batch-jobs and --http-check")
+	command.Flags().BoolVar(&args.MeasureLatency, "measure-latency", false, "if true, time each probe in the client pod, and report latency percentiles of allowed probes, so that CNIs whose policy enforcement slows down the datapath stand out; the client image needs sh and a date supporting %N.  Incompatible with --batch-jobs")
+	command.Flags().IntVar(&args.Retries, "retries", 1, "number of kube probe retries to allow, if probe results don't match expected results")
+	command.Flags().IntVar(&args.RetryBackoffSeconds, "retry-backoff-seconds", 0, "number of seconds to wait before the first retry of a mismatched probe; doubles with each further retry")
+	command.Flags().IntVar(&args.ExecRetries, "exec-retries", 2, "number of retries for individual probe jobs which fail to execute (as opposed to being blocked); these don't count against --retries")
+	command.Flags().IntVar(&args.ExecRetryBackoffSeconds, "exec-retry-backoff-seconds", 1, "number of seconds to wait before the first retry of failed probe jobs; doubles with each further retry")
+	command.Flags().IntVar(&args.ThrottleRetries, "throttle-retries", 5, "number of retries for kube API calls rejected due to API server throttling")
+	command.Flags().IntVar(&args.ThrottleBackoffSeconds, "throttle-backoff-seconds", 1, "number of seconds to wait before the first retry of a throttled kube API call; doubles with each further retry, and a longer Retry-After from the server is respected")
 	command.Flags().BoolVar(&args.AllowDNS, "allow-dns", true, "if using egress, allow udp over port 53 for DNS resolution")
 	command.Flags().BoolVar(&args.Noisy, "noisy", false, "if true, print all results")
+	command.Flags().BoolVar(&args.FailuresOnly, "failures-only", false, "if true, tables for failed steps only show sources and destinations with at least one mismatch")
+	command.Flags().BoolVar(&args.CombinedView, "combined-view", false, "if true, print a single table per step whose cells summarize the results for every port and protocol, i.e. 'TCP80 ✓ / TCP81 ✗* / UDP80 ✓', instead of separate tables")
+	command.Flags().StringVar(&args.EgressTarget, "egress-target", "", "if set, an http or https URL outside the cluster which every pod additionally requests at every step, to check egress -- through --egress-proxy or --egress-gateway, if set.  Results are checked against policies if the first hop is an IP")
+	command.Flags().StringVar(&args.EgressProxy, "egress-proxy", "", "http, https, or socks5 proxy URL, i.e. 'http://10.0.0.5:3128', to request --egress-target through")
+	command.Flags().StringVar(&args.EgressGateway, "egress-gateway", "", "host:port of an egress gateway, to send requests for --egress-target to in place of the target's own address")
+	command.Flags().StringVar(&args.ExternalEndpoint, "external-endpoint", "", "IP:port of an HTTP server outside the pod network, such as an echo server, which every pod additionally requests at every step; results must match what the policies' ipBlocks allow, and test cases tagged "+generator.TagIPBlockExternalEndpoint+" are generated for an IPv4 endpoint")
+	command.Flags().IntVar(&args.DeployExternalEndpoint, "deploy-external-endpoint", 0, "if non-zero, deploy an HTTP echo server on this port in a node's network, in namespace "+probe.ExternalEndpointNamespace+", and use it as --external-endpoint; it's deleted at the end of the run")
+	command.Flags().BoolVar(&args.WarmUp, "warm-up", false, "if true, probe every pair once at the start of each test case, before creating any policies, and ignore the results; avoids first-packet artifacts (ARP, routes, eBPF map population) being reported as denials on some CNIs")
+	command.Flags().StringVar(&args.ExpectConnections, "expect-long-lived-connections", "", "what long-lived connections should do once a policy which would deny them is created -- one of "+strings.Join(probe.AllConnectionFates, ", ")+" -- for test cases tagged "+generator.TagLongLivedConnection+"; if empty, their fates are reported but not verified, since it's up to the CNI")
+	command.Flags().BoolVar(&args.DNSCheck, "dns-check", false, "if true, every pod additionally looks up "+probe.DNSCheckName+" with dig at every step, and lookups must work exactly where the policies allow egress to the cluster's DNS pods -- so that a broken --allow-dns carve-out isn't only seen as confusing failures elsewhere; required to run test cases tagged "+generator.TagDNS)
+	command.Flags().StringVar(&args.DNSNamespace, "dns-namespace", "kube-system", "namespace of the cluster's DNS pods, for --dns-check")
+	command.Flags().StringVar(&args.DNSPodSelector, "dns-pod-selector", "k8s-app=kube-dns", "label selector of the cluster's DNS pods, for --dns-check")
+	command.Flags().BoolVar(&args.CanonicalOutput, "canonical-output", false, "if true, print output which is the same from run to run, for golden-file tests and diffing runs: stable ordering, no timings or log timestamps, and IPs replaced by the names of their pods")
+	command.Flags().StringVar(&args.PolicyCoverage, "policy-coverage", "", "if set, report which ingress and egress rules, peers and ports of each test case's policies were exercised by at least one probe; one of "+strings.Join(connectivity.AllPolicyCoverageModes, ", ")+": '"+connectivity.PolicyCoverageUncovered+"' lists only the elements which never were")
+	command.Flags().IntVar(&args.HeatmapCount, "heatmap", 10, "if there are failures, report where they cluster: the sources, destinations, ports and protocols, and namespace pairs and protocols with the most wrong results, up to this many of each, and failures by step index; 0 to turn off")
+	command.Flags().IntVar(&args.SlowestCount, "slowest", 10, "number of slowest test cases to report in the summary, with time spent on setup, verification, actions, perturbation wait and probing; 0 to turn off")
 	command.Flags().BoolVar(&args.IgnoreLoopback, "ignore-loopback", false, "if true, ignore loopback for truthtable correctness verification")
 	command.Flags().IntVar(&args.PerturbationWaitSeconds, "perturbation-wait-seconds", 5, "number of seconds to wait after perturbing the cluster (i.e. create a network policy, modify a ns/pod label) before running probes, to give the CNI time to update the cluster state")
 	command.Flags().IntVar(&args.PodCreationTimeoutSeconds, "pod-creation-timeout-seconds", 60, "number of seconds to wait for pods to create, be running and have IP addresses")
 	command.Flags().StringVar(&args.Context, "context", "", "kubernetes context to use; if empty, uses default context")
 	command.Flags().BoolVar(&args.CleanupNamespaces, "cleanup-namespaces", false, "if true, clean up namespaces after completion")
-	command.Flags().StringVar(&args.DestinationType, "destination-type", "", "override to set what to direct requests at; if not specified, the tests will be left as-is; one of "+strings.Join(generator.AllProbeModes, ", "))
+	command.Flags().StringVar(&args.ServiceMesh, "service-mesh", probe.MeshModeWarn, "what to do about Istio/Linkerd sidecar injection in the server namespaces, which distorts results; one of "+strings.Join(probe.AllMeshModes, ", ")+".  '"+probe.MeshModeAdjust+"' opts cyclonus's pods out of injection and drops server ports reserved by mesh proxies")
+	command.Flags().StringVar(&args.LeftoverResources, "leftover-resources", connectivity.LeftoverModeWarn, "what to do about policies, pods and namespaces left over from a previous run; one of "+strings.Join(connectivity.AllLeftoverModes, ", "))
+	command.Flags().BoolVar(&args.CrossModeCheck, "cross-mode-check", false, "if true, additionally probe every step by both pod IP and service IP, and report cells where they disagree")
+	command.Flags().StringVar(&args.DestinationType, "destination-type", "", "override to set what to direct requests at; steps which pin their own destination type are left alone; if not specified, the tests will be left as-is; one of "+strings.Join(generator.AllProbeModes, ", "))
+
+	command.Flags().StringVar(&args.TemplatePath, "template-path", "", "path to a go template which renders yaml network policies; a test case is added for every combination of the values in --template-values, tagged '"+generator.TagTemplate+"'")
+	command.Flags().StringVar(&args.TemplateValuesPath, "template-values", "", "path to a yaml file with a 'matrix' of template variables to lists of values, used with --template-path")
+	command.Flags().StringVar(&args.ExpectationOverridesPath, "expectation-overrides", "", "path to a yaml file mapping CNI names to test case descriptions to one expected connectivity matrix per step, for test cases where a CNI's results legitimately differ from what the policies would allow; used with --expectation-overrides-cni")
+	command.Flags().StringVar(&args.WaiversPath, "waivers", "", "path to a yaml file of waivers: test cases, or probes of test cases -- by step, source, destination, port and protocol -- known to fail, i.e. because of a CNI bug, each with a reason, and for probes, the result to expect instead.  Waived failures are reported separately, and don't count as failures")
+	command.Flags().StringVar(&args.ExpectationOverridesCNI, "expectation-overrides-cni", "", "which CNI's overrides to use from --expectation-overrides")
+	command.Flags().StringSliceVar(&args.TestFiles, "test-file", []string{}, "yaml files of hand-written test cases, one per document, or directories of them; each step may include an 'expected' connectivity matrix, which takes precedence over what the policies would allow.  Tagged '"+generator.TagUserDefined+"', so '--include "+generator.TagUserDefined+"' runs just these")
+	command.Flags().StringVar(&args.TestCasePath, "test-case-path", "", "path to a yaml file of hand-written test cases")
+	utils.DoOrDie(command.Flags().MarkDeprecated("test-case-path", "use --test-file instead"))
 
 	command.Flags().StringSliceVar(&args.Include, "include", []string{}, "include tests with any of these tags; if empty, all tests will be included.  Valid tags:\n"+strings.Join(generator.TagSlice, "\n"))
-	command.Flags().StringSliceVar(&args.Exclude, "exclude", []string{generator.TagMultiPeer, generator.TagUpstreamE2E, generator.TagExample}, "exclude tests with any of these tags.  See 'include' field for valid tags")
+	command.Flags().StringVar(&args.FromResultsPath, "from-results", "", "path to a "+connectivity.ResultsDocumentFileName+" from a previous run, of this or an older version of cyclonus; only test cases recorded in it are run.  Test cases are matched by description, so use the same test case selection as the previous run")
+	command.Flags().BoolVar(&args.OnlyFailed, "only-failed", false, "if true, only run test cases which failed or were interrupted in the --from-results run")
+	command.Flags().StringVar(&args.CheckpointPath, "checkpoint-file", "", "path to a file to record progress in as each test case finishes -- a "+connectivity.ResultsDocumentFileName+", numbered by test case -- so that an interrupted run can be picked up with --resume")
+	command.Flags().BoolVar(&args.Resume, "resume", false, "if true, skip test cases which finished in a previous attempt at the run, according to --checkpoint-file, and report them along with the rest.  The test case selection, including --seed if shuffling, must be the same as the interrupted run's")
+	command.Flags().BoolVar(&args.Shuffle, "shuffle", false, "if true, run test cases in a random order, to flush out state leaking from one test case to the next")
+	command.Flags().Int64Var(&args.Seed, "seed", 0, "seed for --shuffle, to reproduce a previous order; if 0, a seed is picked and printed")
+	command.Flags().IntVar(&args.Repeat, "repeat", 1, "number of times to run each test case, one run after another; if more than 1, test cases are classified as stable-pass, stable-fail or flaky -- passing some runs and failing others, i.e. due to CNI convergence races -- and the flake rate is reported")
+	command.Flags().StringVar(&args.CNIProfile, "cni-profile", "", "built-in profile of the known deviations of the CNI under test -- one of "+strings.Join(generator.AllCNIProfileNames(), ", ")+" -- which excludes test cases known not to work on it, waives the failures of test cases known to fail, and, unless they're set explicitly, sets --server-protocol and --ignore-loopback to what the CNI supports")
+	command.Flags().StringVar(&args.Filter, "filter", "", "boolean expression of tags selecting the tests to run, in place of --include and --exclude -- including the default exclusions -- i.e. '(ingress && ip-block-with-except) || !udp'; '&&' binds tighter than '||', '!' negates, and parentheses group")
+	command.Flags().StringArrayVar(&args.IncludeNames, "include-name", []string{}, "regular expression matched against test case descriptions; if any are given, only tests matching one of them are run.  Applies on top of tag selection, and can be repeated")
+	command.Flags().StringArrayVar(&args.ExcludeNames, "exclude-name", []string{}, "regular expression matched against test case descriptions; tests matching any of them aren't run, i.e. to skip a flaky test.  Can be repeated")
+	command.Flags().StringSliceVar(&args.Exclude, "exclude", []string{generator.TagMultiPeer, generator.TagUpstreamE2E, generator.TagExample, generator.TagAdminNetworkPolicy, generator.TagBaselineAdminNetworkPolicy, generator.TagChaos, generator.TagLongLivedConnection, generator.TagDNS}, "exclude tests with any of these tags.  See 'include' field for valid tags")
 
 	command.Flags().BoolVar(&args.Mock, "mock", false, "if true, use a mock kube runner (i.e. don't actually run tests against kubernetes; instead, product fake results")
+	command.Flags().StringVar(&args.RecordKubePath, "record-kube", "", "path to write a recording of every kube API call and probe exec made during the run to, for replaying with --replay-kube")
+	command.Flags().StringVar(&args.ReplayKubePath, "replay-kube", "", "path to a recording made with --record-kube, by this or an older version of cyclonus; instead of talking to a cluster, kube API calls and probe execs are served from the recording, so that the recorded run's results can be re-analyzed and re-rendered offline.  The run must use the same flags as the recorded run, except for output flags and --perturbation-wait-seconds, which can be 0")
+	command.Flags().StringVar(&args.RecordProbesPath, "record-probes", "", "path to write a recording of every kube probe job and the connectivity it observed to, for replaying with --replay-probes; much smaller than --record-kube, and readable, for bug reports.  Incompatible with --parallelism greater than 1")
+	command.Flags().StringVar(&args.ReplayProbesPath, "replay-probes", "", "path to a recording made with --record-probes; instead of talking to a cluster, the cluster is mocked and probe results are served from the recording -- matched by source pod, target, protocol and port, in the order recorded -- so that the recorded run's results can be re-analyzed and re-rendered offline.  The run must select the same test cases as the recorded run.  Incompatible with --context, --mock, --record-kube, --replay-kube and --parallelism greater than 1")
+	command.Flags().StringVar(&args.CNINamespace, "cni-namespace", "kube-system", "namespace of the CNI daemonset, for chaos test cases")
+	command.Flags().StringVar(&args.CNIDaemonSet, "cni-daemonset", "", "name of the CNI daemonset (i.e. calico-node), which chaos test cases restart; required to run test cases tagged "+generator.TagChaos)
+	command.Flags().StringSliceVar(&args.CNIRestartNodes, "cni-restart-nodes", []string{}, "if non-empty, chaos test cases only restart the CNI daemonset's pods on these nodes")
+	command.Flags().IntVar(&args.CNIRecoverySeconds, "cni-recovery-timeout-seconds", 300, "number of seconds to wait for the CNI daemonset to recover after a restart")
+	command.Flags().StringVar(&args.Corroborator, "corroborator", "", "cross-check every step against the dataplane's state, read from the pods of --cni-daemonset: 'cilium' reads Cilium's endpoint policy enforcement, 'command' matches the output of --dataplane-command; if empty, no cross-checking is done")
+	command.Flags().StringVar(&args.DataplaneCommandPath, "dataplane-command", "", "path to a yaml file with a command, i.e. iptables-save, to read the dataplane's rules with, and patterns to match each pod's isolation in its output; for '--corroborator command'")
+
+	command.Flags().StringSliceVar(&args.AttachNetworks, "attach-networks", []string{}, "Multus NetworkAttachmentDefinitions to attach cyclonus's pods to, as secondary networks; these must exist in each server namespace")
+	command.Flags().StringSliceVar(&args.ProbeNetworks, "probe-networks", []string{}, "Multus secondary networks to also probe over by pod IP, reporting results per network; all pods must be attached to them")
+	command.Flags().BoolVar(&args.DualStack, "dual-stack", false, "if true, also probe every step by pod IP over both IPv4 and IPv6, verifying each family against the policies and reporting results per family; all pods must have both IPv4 and IPv6 addresses")
+
+	command.Flags().StringSliceVar(&args.IgnoreProtocols, "ignore-protocols", []string{}, "protocols to leave out of verification, i.e. because probing them is known to be unreliable on this cluster; they're still probed and reported, unless --skip-ignored is set")
+	command.Flags().IntSliceVar(&args.IgnorePorts, "ignore-ports", []int{}, "ports to leave out of verification; they're still probed and reported, unless --skip-ignored is set")
+	command.Flags().BoolVar(&args.SkipIgnored, "skip-ignored", false, "if true, don't probe the protocols and ports from --ignore-protocols and --ignore-ports at all")
+
+	command.Flags().BoolVar(&args.OpenShift, "openshift", false, "if true, create pods which comply with OpenShift's restricted SCCs, and create the server namespaces opted out of the default project node selector")
+	command.Flags().StringToStringVar(&args.NodeLabels, "node-label", map[string]string{}, "node labels, i.e. 'kubernetes.io/os=linux', which nodes must have for cyclonus's pods to be scheduled onto them; sets the pods' nodeSelector")
+	command.Flags().StringVar(&args.NodeSelector, "node-selector", "", "label selector, i.e. 'kubernetes.io/os=linux,cni-migrated notin (false)', picking the nodes cyclonus's pods may be scheduled onto; unlike --node-label, supports set-based requirements, and is applied as required node affinity")
+	command.Flags().IntVar(&args.OpenShiftRoutePort, "openshift-route-port", 0, "if non-zero, expose each pod's TCP server on this port through an OpenShift Route, and additionally probe every step through the Routes, as external destinations; results are reported but not verified.  Requires --openshift")
+
 	command.Flags().BoolVar(&args.DryRun, "dry-run", false, "if true, don't actually do anything: just print out what would be done")
+	command.Flags().StringVar(&args.ExportDir, "export-dir", "", "if set, don't touch a cluster: instead, write each selected test case -- as yaml, which --test-file can read -- along with each step's network policies and expected truth table to a directory under this one, and an index of them to "+connectivity.ExportIndexFileName+", for other harnesses to use")
+	command.Flags().BoolVar(&args.ExitCodes, "exit-codes", false, fmt.Sprintf("if true, exit with a code reflecting the most severe class of test failure: %d for %s, %d for %s, %d for %s",
+		connectivity.FailureClassVerification.ExitCode(), connectivity.FailureClassVerification,
+		connectivity.FailureClassSetupInvalid.ExitCode(), connectivity.FailureClassSetupInvalid,
+		connectivity.FailureClassInfrastructure.ExitCode(), connectivity.FailureClassInfrastructure))
 
-	return command
+	command.Flags().StringVar(&args.JUnitReportPath, "junit-report", "", "path to write a JUnit XML report to, with a test case for each test case run -- failed ones with the wrong results of each step -- for CI dashboards")
+	command.Flags().StringVar(&args.ResultsFilePath, "results-file", "", "path to write a json document to, with every test case run: its tags and verdict, and each step's counts of wrong results and expected vs. actual result of every probe -- the same as "+connectivity.ResultsDocumentFileName+" in --artifacts-dir")
+	command.Flags().IntVar(&args.Parallelism, "parallelism", 1, "number of sets of namespaces and pods to create, and run test cases in at the same time; sets after the first have their namespaces suffixed with the set's number, i.e. 'x-1'.  Test cases which affect the whole cluster -- AdminNetworkPolicies and chaos -- or use pod IPs in ipBlocks run one at a time in the first set")
+	command.Flags().StringVar(&args.MetricsAddress, "metrics-address", "", "address, such as ':9090', to serve Prometheus metrics on at /metrics while test cases run: test cases executed and failed, probes run, kube API errors, and test case durations; if empty, metrics aren't served")
+	command.Flags().StringVar(&args.HTMLReportDir, "html-report-dir", "", "directory to write "+connectivity.HTMLReportFileName+" to: a standalone page with each test case's expected and actual truth tables, wrong results highlighted, filterable by tag and by failures")
+	command.Flags().StringVar(&args.FailureArtifactsDir, "failure-artifacts-dir", "", "directory to collect artifacts for debugging failed test cases in, right after each fails: a directory per test case with the events, network policies -- as returned by the API server -- and pods in the fixture namespaces, the error if any, and the output of every probe")
+	command.Flags().BoolVar(&args.PacketCapture, "packet-capture", false, "if true, re-run each step's mismatched probes one at a time while capturing packets with tcpdump in the source and destination pods, and save the captures to "+packetCapturesDirName+" in --artifacts-dir, to tell whether packets were dropped at the source or the destination.  tcpdump runs in ephemeral containers with NET_ADMIN and NET_RAW, which the cluster must allow")
+	command.Flags().StringVar(&args.PacketCaptureImage, "packet-capture-image", connectivity.DefaultPacketCaptureImage, "image with tcpdump to run packet captures in, for --packet-capture")
+	command.Flags().IntVar(&args.PacketCaptureSeconds, "packet-capture-seconds", 5, "number of seconds to capture packets for, around each re-run probe, for --packet-capture")
+	command.Flags().IntVar(&args.PacketCaptureMax, "packet-capture-max", 3, "maximum number of each step's mismatched probes to capture packets of, for --packet-capture")
+	command.Flags().BoolVar(&args.MinimizeFailures, "minimize-failures", false, "if true, shrink the policies of each failed test case's last step -- deleting policies, and removing rules, peers and ports, one at a time -- to the smallest which still reproduce its mismatched probes, re-probing after each change, and report them")
+	command.Flags().IntVar(&args.MinimizeMaxAttempts, "minimize-max-attempts", 50, "maximum number of reductions to try per failed test case, for --minimize-failures")
+	command.Flags().StringVar(&args.ArtifactsDir, "artifacts-dir", "", "directory to write results and other artifacts to; if empty and uploads are requested, a temporary directory is used")
+	command.Flags().BoolVar(&args.Sonobuoy, "sonobuoy", false, "if true, run as a Sonobuoy plugin: at the end of the run -- even if it fails -- bundle a JUnit report and the artifacts into the results directory from $"+artifacts.SonobuoyResultsDirEnv+" (default "+artifacts.DefaultSonobuoyResultsDir+"), and write the done file")
+	command.Flags().StringSliceVar(&args.UploadURLs, "upload-url", []string{}, "upload a tarball of the artifacts directory to these targets at the end of the run; supports s3://bucket/key, with credentials from $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and optionally $AWS_SESSION_TOKEN, and the region from $AWS_REGION; gs://bucket/key, with an HMAC key from $GS_ACCESS_KEY_ID and $GS_SECRET_ACCESS_KEY (for both, a trailing '/' appends the bundle name); and http(s) URLs, which receive a PUT (e.g. presigned URLs).  No cloud CLIs are needed")
+}
+
+// applyCNIProfileFlags sets the flags which --cni-profile has opinions about, unless they were set explicitly
+func applyCNIProfileFlags(cmd *cobra.Command, args *GenerateArgs) {
+	if args.CNIProfile == "" {
+		return
+	}
+	profile, err := generator.GetCNIProfile(args.CNIProfile)
+	utils.DoOrDie(err)
+	if len(profile.ServerProtocols) > 0 && !cmd.Flags().Changed("server-protocol") {
+		args.ServerProtocols = nil
+		for _, protocol := range profile.ServerProtocols {
+			args.ServerProtocols = append(args.ServerProtocols, string(protocol))
+		}
+	}
+	if profile.IgnoreLoopback && !cmd.Flags().Changed("ignore-loopback") {
+		args.IgnoreLoopback = true
+	}
 }
 
-func RunGenerateCommand(args *GenerateArgs) {
+// provisionedCluster picks the cluster for --provision, which replaces the cluster flags of 'generate'
+func provisionedCluster(provision string, kindArgs *KindArgs) (*kind.Cluster, error) {
+	if provision != "kind" {
+		return nil, errors.Errorf("unsupported --provision '%s'; must be 'kind'", provision)
+	}
+	args := kindArgs.Generate
+	if args.Context != "" || args.Mock || args.DryRun || args.ExportDir != "" || args.ReplayKubePath != "" || args.ReplayProbesPath != "" {
+		return nil, errors.Errorf("--provision can't be used with --context, --mock, --dry-run, --export-dir, --replay-kube or --replay-probes")
+	}
+	return kindArgs.Cluster()
+}
+
+func RunGenerateCommand(ctx context.Context, args *GenerateArgs) {
+	if args.CanonicalOutput {
+		logrus.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	}
 	RunVersionCommand()
+	validatePolicyCoverageMode(args.PolicyCoverage)
+
+	// sonobuoyResults writes whatever results there are for the Sonobuoy worker -- which waits for them until it
+	// times out -- however the run ends, but only once
+	var sonobuoyResults func()
+	if args.Sonobuoy {
+		if args.ArtifactsDir == "" {
+			dir, err := ioutil.TempDir("", "cyclonus-artifacts-")
+			utils.DoOrDie(err)
+			args.ArtifactsDir = dir
+		}
+		written := false
+		sonobuoyResults = func() {
+			if written {
+				return
+			}
+			written = true
+			bundlePath, err := artifacts.WriteSonobuoyResults(args.ArtifactsDir, artifacts.SonobuoyResultsDir())
+			if err != nil {
+				logrus.Errorf("unable to write sonobuoy results: %+v", err)
+				return
+			}
+			logrus.Infof("wrote sonobuoy results to %s", bundlePath)
+		}
+		logrus.RegisterExitHandler(sonobuoyResults)
+		defer sonobuoyResults()
+	}
+
+	// fail on invalid flags before creating anything in the cluster
+	utils.DoOrDie(validateGenerateArgs(args))
 
 	utils.DoOrDie(generator.ValidateTags(append(args.Include, args.Exclude...)))
+	var filter generator.TagExpression
+	if args.Filter != "" {
+		var err error
+		filter, err = generator.ParseTagExpression(args.Filter)
+		utils.DoOrDie(err)
+		fmt.Printf("selecting tests matching %s\n", filter.String())
+	}
+	includedNames, err := generator.CompileNamePatterns(args.IncludeNames)
+	utils.DoOrDie(err)
+	excludedNames, err := generator.CompileNamePatterns(args.ExcludeNames)
+	utils.DoOrDie(err)
 
-	externalIPs := []string{} // "http://www.google.com"} // TODO make these be IPs?  or not?
+	metrics := connectivity.NewMetrics()
 
 	var kubernetes kube.IKubernetes
-	if args.Mock || args.DryRun {
-		kubernetes = kube.NewMockKubernetes(1.0)
-	} else {
-		kubeClient, err := kube.NewKubernetesForContext(args.Context)
+	var recorder *kube.RecordingKubernetes
+	var replayer *kube.ReplayKubernetes
+	var realClient *kube.Kubernetes
+	var probeReplayer *probe.ReplayJobRunner
+	if args.ReplayProbesPath != "" {
+		recording, err := probe.ReadProbeRecording(args.ReplayProbesPath)
 		utils.DoOrDie(err)
-		info, err := kubeClient.ClientSet.ServerVersion()
-		utils.DoOrDie(err)
-		fmt.Printf("Kubernetes server version: \n%s\n", utils.JsonString(info))
-		kubernetes = kubeClient
+		logrus.Infof("replaying %d probe results from %s", len(recording.Results), args.ReplayProbesPath)
+		probeReplayer = probe.NewReplayJobRunner(recording)
+	}
+	if args.Mock || args.DryRun || args.ExportDir != "" || probeReplayer != nil {
+		mock := kube.NewMockKubernetes(1.0)
+		mock.DualStack = args.DualStack
+		kubernetes = mock
+	} else {
+		var kubeClient kube.IKubernetes
+		if args.ReplayKubePath != "" {
+			cassette, err := kube.ReadCassette(args.ReplayKubePath)
+			utils.DoOrDie(err)
+			logrus.Infof("replaying %d kube interactions from %s", len(cassette.Interactions), args.ReplayKubePath)
+			replayer = kube.NewReplayKubernetes(cassette)
+			kubeClient = replayer
+		} else {
+			var err error
+			realClient, err = kube.NewKubernetesForContext(args.Context)
+			utils.DoOrDie(err)
+			realClient.Context = ctx
+			info, err := realClient.ClientSet.ServerVersion()
+			utils.DoOrDie(err)
+			fmt.Printf("Kubernetes server version: \n%s\n", utils.JsonString(info))
+			kubeClient = realClient
+		}
+		// record underneath the retry wrapper, so that a replay sees -- and retries -- the same throttling
+		if args.RecordKubePath != "" {
+			recorder = kube.NewRecordingKubernetes(kubeClient)
+			kubeClient = recorder
+		}
+		retrying := kube.NewThrottleRetryingKubernetes(kubeClient, kube.RetryPolicy{
+			Retries: args.ThrottleRetries,
+			Backoff: time.Duration(args.ThrottleBackoffSeconds) * time.Second,
+		})
+		retrying.OnError = metrics.RecordKubeAPIError
+		kubernetes = retrying
 	}
 
 	serverProtocols := parseProtocols(args.ServerProtocols)
 
-	resources, err := probe.NewDefaultResources(kubernetes, args.ServerNamespaces, args.ServerPods, args.ServerPorts, serverProtocols, externalIPs, args.PodCreationTimeoutSeconds, args.BatchJobs)
+	// namespaces of the extra sets, by set, then by original namespace
+	var namespaceSets []map[string]string
+	allNamespaces := append([]string{}, args.ServerNamespaces...)
+	for set := 1; set < args.Parallelism; set++ {
+		names := map[string]string{}
+		for _, ns := range args.ServerNamespaces {
+			names[ns] = connectivity.NamespaceSetName(ns, set)
+			allNamespaces = append(allNamespaces, names[ns])
+		}
+		namespaceSets = append(namespaceSets, names)
+	}
+
+	utils.DoOrDie(connectivity.HandleLeftoverResources(kubernetes, allNamespaces, args.ServerPods, args.LeftoverResources))
+
+	if args.OpenShift {
+		utils.DoOrDie(probe.PrepareOpenShiftNamespaces(kubernetes, allNamespaces))
+	}
+
+	var tlsCertificate *probe.TLSCertificate
+	if args.TLSCheck {
+		tlsCertificate, err = probe.NewTLSCertificate()
+		utils.DoOrDie(err)
+	}
+
+	serverPorts, podOptions, err := probe.HandleServiceMeshes(kubernetes, allNamespaces, args.ServerPorts, args.ServiceMesh)
 	utils.DoOrDie(err)
+	podOptions.Restricted = args.OpenShift
+	podOptions.ServeHTTP = args.HTTPCheck
+	podOptions.TLSCertificate = tlsCertificate
+	utils.DoOrDie(podOptions.SetNodeScheduling(args.NodeLabels, args.NodeSelector))
+	if len(args.AttachNetworks) > 0 {
+		if podOptions.Annotations == nil {
+			podOptions.Annotations = map[string]string{}
+		}
+		podOptions.Annotations[probe.MultusNetworksAnnotation] = strings.Join(args.AttachNetworks, ",")
+	}
+
+	resources, err := probe.NewDefaultResources(kubernetes, args.ServerNamespaces, args.ServerPods, serverPorts, serverProtocols, args.PodCreationTimeoutSeconds, args.BatchJobs, podOptions)
+	utils.DoOrDie(err)
+
+	if args.OpenShiftRoutePort != 0 {
+		utils.DoOrDie(resources.CreateRoutes(kubernetes, args.OpenShiftRoutePort, args.PodCreationTimeoutSeconds))
+	}
+
+	secondaryNetworks := resources.SecondaryNetworks()
+	if len(secondaryNetworks) > 0 {
+		fmt.Printf("found pods attached to Multus secondary networks %+v; policies usually only apply to the primary network\n", secondaryNetworks)
+	}
+	for _, network := range args.ProbeNetworks {
+		if _, err := resources.ForSecondaryNetwork(network); err != nil {
+			utils.DoOrDie(errors.WithMessagef(err, "unable to probe over secondary network %s", network))
+		}
+	}
+
+	if args.DualStack && len(resources.IPFamilies()) < 2 {
+		utils.DoOrDie(errors.Errorf("--dual-stack requires pods with both IPv4 and IPv6 addresses; is the cluster dual-stack?"))
+	}
+
+	var clientCommands *probe.ClientCommands
+	if args.ClientCommandsPath != "" {
+		clientCommands, err = probe.ReadClientCommands(args.ClientCommandsPath)
+		utils.DoOrDie(err)
+	}
+
+	var workerToken string
+	if args.DeployWorkerDaemonSet != 0 {
+		workerToken, err = probe.NewWorkerToken()
+		utils.DoOrDie(err)
+		utils.DoOrDie(probe.DeployWorkerDaemonSet(kubernetes, args.DeployWorkerDaemonSet, workerToken, args.PodCreationTimeoutSeconds))
+		fmt.Printf("deployed workers in namespace %s\n", probe.WorkerDaemonSetNamespace)
+	}
+
+	externalEndpoint := args.ExternalEndpoint
+	if args.DeployExternalEndpoint != 0 {
+		externalEndpoint, err = probe.DeployExternalEndpoint(kubernetes, args.DeployExternalEndpoint, args.PodCreationTimeoutSeconds)
+		utils.DoOrDie(err)
+		fmt.Printf("deployed external endpoint at %s\n", externalEndpoint)
+	}
+
+	var egressPath *probe.EgressPath
+	if externalEndpoint != "" {
+		egressPath, err = probe.NewExternalEndpointPath(externalEndpoint)
+		utils.DoOrDie(err)
+	} else if args.EgressTarget != "" {
+		egressPath, err = probe.NewEgressPath(args.EgressTarget, args.EgressProxy, args.EgressGateway)
+		utils.DoOrDie(err)
+	}
+
+	var dnsCheck *probe.DNSCheck
+	if args.DNSCheck {
+		dnsCheck, err = probe.NewDNSCheck(kubernetes, args.DNSNamespace, args.DNSPodSelector)
+		utils.DoOrDie(err)
+	}
 
 	interpreterConfig := &connectivity.InterpreterConfig{
 		ResetClusterBeforeTestCase:       true,
 		KubeProbeRetries:                 args.Retries,
+		KubeProbeRetryBackoff:            time.Duration(args.RetryBackoffSeconds) * time.Second,
 		PerturbationWaitSeconds:          args.PerturbationWaitSeconds,
 		VerifyClusterStateBeforeTestCase: true,
 		BatchJobs:                        args.BatchJobs,
+		WorkerDaemonSetPort:              args.DeployWorkerDaemonSet,
+		WorkerDaemonSetToken:             workerToken,
 		IgnoreLoopback:                   args.IgnoreLoopback,
+		CrossModeCheck:                   args.CrossModeCheck,
+		ExecFailureRetryPolicy: kube.RetryPolicy{
+			Retries: args.ExecRetries,
+			Backoff: time.Duration(args.ExecRetryBackoffSeconds) * time.Second,
+		},
+		ClientCommands:    clientCommands,
+		SecondaryNetworks: args.ProbeNetworks,
+		DualStack:         args.DualStack,
+		IgnoredJobs:       &probe.JobFilter{Protocols: parseProtocols(args.IgnoreProtocols), Ports: args.IgnorePorts},
+		SkipIgnoredJobs:   args.SkipIgnored,
+		RoutePort:         args.OpenShiftRoutePort,
+		CanonicalOutput:   args.CanonicalOutput,
+		UDPBurstSize:      args.UDPBurstSize,
+		HTTPCheck:         args.HTTPCheck,
+		TLSCertificate:    tlsCertificate,
+		MeasureLatency:    args.MeasureLatency,
+		MultiTargetExec:   args.MultiTargetExec,
+		WarmUp:            args.WarmUp,
+		EgressPath:        egressPath,
+		DNSCheck:          dnsCheck,
+		Context:           ctx,
+	}
+	interpreterConfig.SourcePodParallelism = args.SourcePodParallelism
+	if args.ExpectConnections != "" {
+		interpreterConfig.ExpectedConnectionFate, _ = probe.ParseConnectionFate(args.ExpectConnections)
+	}
+	if args.CNIDaemonSet != "" {
+		interpreterConfig.CNIRestarter = &connectivity.CNIRestarter{
+			Kubernetes:      kubernetes,
+			Namespace:       args.CNINamespace,
+			DaemonSet:       args.CNIDaemonSet,
+			Nodes:           args.CNIRestartNodes,
+			RecoveryTimeout: time.Duration(args.CNIRecoverySeconds) * time.Second,
+		}
+	}
+	interpreterConfig.ProbeReplay = probeReplayer
+	if args.RecordProbesPath != "" {
+		interpreterConfig.ProbeRecording = probe.NewProbeRecording()
+	}
+	interpreterConfig.Corroborator, err = setupCorroborator(args, kubernetes)
+	utils.DoOrDie(err)
+	if args.FailureArtifactsDir != "" {
+		interpreterConfig.FailureArtifacts = connectivity.NewFailureArtifacts(args.FailureArtifactsDir)
+	}
+	if args.PacketCapture {
+		dir := filepath.Join(args.ArtifactsDir, packetCapturesDirName)
+		interpreterConfig.PacketCapturer = connectivity.NewPacketCapturer(kubernetes, dir, args.PacketCaptureImage, args.PacketCaptureSeconds, args.PacketCaptureMax, args.PodCreationTimeoutSeconds)
+	}
+	if args.MinimizeFailures {
+		interpreterConfig.Minimizer = &connectivity.Minimizer{
+			MaxAttempts:      args.MinimizeMaxAttempts,
+			PerturbationWait: time.Duration(args.PerturbationWaitSeconds) * time.Second,
+		}
+	}
+	firstSetInterpreter, err := connectivity.NewInterpreter(kubernetes, resources, interpreterConfig)
+	utils.DoOrDie(err)
+	interpreter := connectivity.NewParallelInterpreter(firstSetInterpreter)
+	for _, names := range namespaceSets {
+		setResources, err := probe.NewRenamedDefaultResources(kubernetes, args.ServerNamespaces, names, args.ServerPods, serverPorts, serverProtocols, args.PodCreationTimeoutSeconds, args.BatchJobs, podOptions)
+		utils.DoOrDie(err)
+		if args.OpenShiftRoutePort != 0 {
+			utils.DoOrDie(setResources.CreateRoutes(kubernetes, args.OpenShiftRoutePort, args.PodCreationTimeoutSeconds))
+		}
+		setInterpreter, err := connectivity.NewInterpreter(kubernetes, setResources, interpreterConfig)
+		utils.DoOrDie(err)
+		interpreter.AddSet(setInterpreter, names)
 	}
-	interpreter := connectivity.NewInterpreter(kubernetes, resources, interpreterConfig)
 	printer := &connectivity.Printer{
 		Noisy:          args.Noisy,
 		IgnoreLoopback: args.IgnoreLoopback,
+		FailuresOnly:   args.FailuresOnly,
+		CombinedView:   args.CombinedView,
+		SlowestCount:   args.SlowestCount,
+		HeatmapCount:   args.HeatmapCount,
+		Canonical:      args.CanonicalOutput,
 	}
 
 	zcPod, err := resources.GetPod("z", "c")
 	utils.DoOrDie(err)
 
 	testCaseGenerator := generator.NewTestCaseGenerator(args.AllowDNS, zcPod.IP, args.ServerNamespaces, args.Include, args.Exclude)
+	testCaseGenerator.Filter = filter
+	testCaseGenerator.IncludedNames, testCaseGenerator.ExcludedNames = includedNames, excludedNames
+	if zcPod.NodeName != "" {
+		zcNode, err := kubernetes.GetNode(zcPod.NodeName)
+		if err != nil {
+			logrus.Warnf("unable to get node %s, skipping node IP ipBlock test cases: %+v", zcPod.NodeName, err)
+		} else {
+			testCaseGenerator.NodeIP = kube.NodeInternalIP(zcNode)
+		}
+	}
+	if egressPath != nil && egressPath.Required {
+		host, port := egressPath.FirstHop()
+		if probe.IPFamilyOf(host) == v1.IPv4Protocol {
+			testCaseGenerator.ExternalIP, testCaseGenerator.ExternalPort = host, port
+		} else {
+			logrus.Warnf("skipping external endpoint ipBlock test cases: %s isn't an IPv4 address", host)
+		}
+	}
 
-	testCases := testCaseGenerator.GenerateTestCases()
+	testCases := testCaseGenerator.GenerateAllTestCases()
+	if args.ExpectationOverridesPath != "" {
+		overrides, err := generator.LoadExpectationOverrides(args.ExpectationOverridesPath)
+		utils.DoOrDie(err)
+		utils.DoOrDie(overrides.Apply(testCases, args.ExpectationOverridesCNI))
+	}
+	if args.TemplatePath != "" {
+		templateCases, err := generator.LoadTemplateTestCases(args.TemplatePath, args.TemplateValuesPath)
+		utils.DoOrDie(err)
+		testCases = append(testCases, templateCases...)
+	}
+	testFiles := args.TestFiles
+	if args.TestCasePath != "" {
+		testFiles = append(testFiles, args.TestCasePath)
+	}
+	for _, path := range testFiles {
+		yamlCases, err := generator.LoadYamlTestSuite(path)
+		utils.DoOrDie(err)
+		testCases = append(testCases, yamlCases...)
+	}
+	if args.WaiversPath != "" {
+		waivers, err := generator.LoadWaivers(args.WaiversPath)
+		utils.DoOrDie(err)
+		utils.DoOrDie(waivers.Apply(testCases))
+	}
+	testCases = testCaseGenerator.FilterTestCases(testCases)
+	if args.CNIProfile != "" {
+		profile, err := generator.GetCNIProfile(args.CNIProfile)
+		utils.DoOrDie(err)
+		var excluded, waived []*generator.DeviationCount
+		testCases, excluded, waived, err = profile.Apply(testCases)
+		utils.DoOrDie(err)
+		fmt.Printf("applying cni profile %s\n", profile.Name)
+		for _, count := range excluded {
+			fmt.Printf("- excluding %d test cases matching %s: %s\n", count.Count, count.Deviation.Tags, count.Deviation.Reason)
+		}
+		for _, count := range waived {
+			fmt.Printf("- waiving failures of %d test cases matching %s: %s\n", count.Count, count.Deviation.Tags, count.Deviation.Reason)
+		}
+	}
+	if args.FromResultsPath != "" {
+		previousResults, err := connectivity.ReadResultsDocument(args.FromResultsPath)
+		utils.DoOrDie(err)
+		testCases = previousResults.FilterTestCases(testCases, args.OnlyFailed)
+	}
+	if args.Shuffle {
+		seed := args.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		fmt.Printf("shuffling test cases with seed %d; rerun with '--shuffle --seed %d' to reproduce this order\n", seed, seed)
+		testCases = generator.ShuffleTestCases(testCases, seed)
+	}
+	if args.DestinationType != "" {
+		mode, _ := generator.ParseProbeMode(args.DestinationType)
+		generator.OverrideProbeMode(testCases, mode)
+	}
+	if args.ExportDir != "" {
+		index, err := connectivity.ExportTestCases(kubernetes, resources, args.ExportDir, testCases)
+		utils.DoOrDie(err)
+		fmt.Printf("exported %d test cases to %s\n", len(index.TestCases), args.ExportDir)
+		return
+	}
+	if args.Repeat > 1 {
+		fmt.Printf("running each of %d test cases %d times\n", len(testCases), args.Repeat)
+		testCases = generator.RepeatTestCases(testCases, args.Repeat)
+	}
+	checkpoint := connectivity.NewCheckpoint(args.CheckpointPath, testCases, args.IgnoreLoopback)
+	if args.Resume {
+		resumed, err := checkpoint.Resume()
+		utils.DoOrDie(err)
+		fmt.Printf("resuming from %s: skipping %d test cases which already finished\n", args.CheckpointPath, resumed)
+	}
+	testCaseIndexes, testCases := checkpoint.Remaining()
+	if args.CNIDaemonSet == "" && generator.CountTestCasesByTag(testCases)[generator.TagChaos] > 0 {
+		utils.DoOrDie(errors.Errorf("test cases tagged %s require --cni-daemonset; or, exclude them with '--exclude %s'", generator.TagChaos, generator.TagChaos))
+	}
+	if !args.HTTPCheck && generator.CountTestCasesByTag(testCases)[generator.TagLongLivedConnection] > 0 {
+		utils.DoOrDie(errors.Errorf("test cases tagged %s require --http-check, so that servers answer requests over long-lived connections; or, exclude them with '--exclude %s'", generator.TagLongLivedConnection, generator.TagLongLivedConnection))
+	}
+	if !args.DNSCheck && generator.CountTestCasesByTag(testCases)[generator.TagDNS] > 0 {
+		utils.DoOrDie(errors.Errorf("test cases tagged %s require --dns-check, since their probes don't do DNS lookups; or, exclude them with '--exclude %s'", generator.TagDNS, generator.TagDNS))
+	}
 	fmt.Printf("test cases to run by tag:\n")
-	for tag, count := range generator.CountTestCasesByTag(testCases) {
-		fmt.Printf("- %s: %d\n", tag, count)
+	tagCounts := generator.CountTestCasesByTag(testCases)
+	for _, tag := range generator.TagSlice {
+		fmt.Printf("- %s: %d\n", tag, tagCounts[tag])
 	}
 	fmt.Printf("testing %d cases\n\n", len(testCases))
 	for i, testCase := range testCases {
-		fmt.Printf("test #%d: %s\n - tags: %+v\n", i+1, testCase.Description, strings.Join(testCase.Tags.Keys(), ", "))
+		fmt.Printf("test #%d: %s\n - tags: %+v\n", testCaseIndexes[i]+1, testCase.Description, strings.Join(testCase.Tags.Keys(), ", "))
 	}
 
 	if args.DryRun {
 		return
 	}
 
-	if args.DestinationType != "" {
-		mode, err := generator.ParseProbeMode(args.DestinationType)
-		utils.DoOrDie(err)
-		for _, testCase := range testCases {
-			for _, step := range testCase.Steps {
-				step.Probe.Mode = mode
-			}
+	stopOnInterrupt(interpreter)
+
+	metrics.SetTestCasesPlanned(len(testCases))
+	if args.MetricsAddress != "" {
+		serveMetrics(args.MetricsAddress, metrics)
+	}
+
+	interpreter.ExecuteTestCases(testCases, testCaseIndexes, func(i int, result *connectivity.Result) {
+		if result.Err != nil {
+			logrus.Errorf("test case #%d failed to execute (%s): %+v", testCaseIndexes[i]+1, connectivity.ClassOfError(result.Err), result.Err)
+		}
+
+		printer.PrintTestCaseResult(result)
+		metrics.RecordResult(result, printer.IgnoreLoopback)
+		if err := checkpoint.Record(testCaseIndexes[i], result); err != nil {
+			logrus.Errorf("unable to save checkpoint: %+v", err)
 		}
+		fmt.Printf("finished policy #%d\n", testCaseIndexes[i]+1)
+	})
+
+	printer.PrintSummary()
+	results := checkpoint.Document()
+	if args.Repeat > 1 {
+		fmt.Println(connectivity.NewFlakeReport(results.Tests).Table())
 	}
 
-	for i, testCase := range testCases {
-		fmt.Printf("starting test case #%d\n", i+1)
+	if args.PolicyCoverage != "" {
+		printPolicyCoverage(args.PolicyCoverage, printer)
+	}
+	if args.JUnitReportPath != "" {
+		writeJUnitReport(args.JUnitReportPath, "cyclonus generate", printer)
+	}
+	if args.ResultsFilePath != "" {
+		utils.DoOrDie(results.WriteToFile(args.ResultsFilePath))
+		logrus.Infof("wrote results to %s", args.ResultsFilePath)
+	}
+	if args.HTMLReportDir != "" {
+		path, err := results.WriteHTMLReport(args.HTMLReportDir)
+		utils.DoOrDie(err)
+		logrus.Infof("wrote html report to %s", path)
+	}
 
-		result := interpreter.ExecuteTestCase(testCase)
-		utils.DoOrDie(result.Err)
+	saveArtifacts(args.ArtifactsDir, args.UploadURLs, results)
+	if args.Sonobuoy {
+		writeJUnitReport(filepath.Join(args.ArtifactsDir, artifacts.SonobuoyJUnitFileName), "cyclonus generate", printer)
+	}
 
-		printer.PrintTestCaseResult(result)
-		fmt.Printf("finished policy #%d\n", i+1)
+	timedOut := ctx.Err() == context.DeadlineExceeded
+	if timedOut && realClient != nil {
+		// the run's context is done, but cleanup still has to get through
+		realClient.Context = nil
 	}
 
-	printer.PrintSummary()
+	if interpreter.IsStopped() {
+		// don't leave policies from a half-finished test case lying around
+		logrus.Infof("cleaning up network policies in namespaces %+v", allNamespaces)
+		if err := kube.DeleteAllNetworkPoliciesInNamespaces(kubernetes, allNamespaces); err != nil {
+			logrus.Warnf("%+v", err)
+		}
+		logrus.Infof("cleaning up cyclonus-managed admin network policies")
+		if err := connectivity.DeleteManagedAdminNetworkPolicies(kubernetes); err != nil {
+			logrus.Warnf("%+v", err)
+		}
+	}
+
+	if args.DeployExternalEndpoint != 0 {
+		logrus.Infof("cleaning up external endpoint in namespace %s", probe.ExternalEndpointNamespace)
+		if err := probe.DeleteExternalEndpoint(kubernetes); err != nil {
+			logrus.Warnf("%+v", err)
+		}
+	}
+
+	if args.DeployWorkerDaemonSet != 0 {
+		logrus.Infof("cleaning up workers in namespace %s", probe.WorkerDaemonSetNamespace)
+		if err := probe.DeleteWorkerDaemonSet(kubernetes); err != nil {
+			logrus.Warnf("%+v", err)
+		}
+	}
 
 	if args.CleanupNamespaces {
-		for _, ns := range args.ServerNamespaces {
+		for _, ns := range allNamespaces {
 			logrus.Infof("cleaning up namespace %s", ns)
 			err = kubernetes.DeleteNamespace(ns)
 			if err != nil {
@@ -159,4 +813,229 @@ func RunGenerateCommand(args *GenerateArgs) {
 			}
 		}
 	}
+	if recorder != nil {
+		utils.DoOrDie(recorder.Cassette().Write(args.RecordKubePath))
+		logrus.Infof("wrote kube recording to %s", args.RecordKubePath)
+	}
+	if interpreterConfig.ProbeRecording != nil {
+		utils.DoOrDie(interpreterConfig.ProbeRecording.Write(args.RecordProbesPath))
+		logrus.Infof("wrote probe recording to %s", args.RecordProbesPath)
+	}
+	if probeReplayer != nil {
+		if remaining := probeReplayer.Remaining(); remaining > 0 {
+			logrus.Warnf("%d recorded probe results weren't replayed; was the recorded run's test case selection or flags different?", remaining)
+		}
+	}
+	if replayer != nil {
+		if remaining := replayer.Remaining(); remaining > 0 {
+			logrus.Warnf("%d recorded kube interactions weren't replayed; was the recorded run's test case selection or flags different?", remaining)
+		}
+	}
+	if timedOut {
+		logrus.Errorf("run timed out after %d of %d test cases", len(printer.Results), len(testCases))
+		logrus.Exit(1)
+	}
+	if args.ExitCodes {
+		summary := (&connectivity.CombinedResults{Results: printer.Results}).Summary(printer.IgnoreLoopback)
+		if failureClass := connectivity.MostSevereFailureClass(summary.FailureClassCounts); failureClass != connectivity.FailureClassNone {
+			logrus.Warnf("exiting with code %d for %s failures", failureClass.ExitCode(), failureClass)
+			// logrus.Exit, unlike os.Exit, runs registered exit handlers
+			logrus.Exit(failureClass.ExitCode())
+		}
+	}
+}
+
+// stopOnInterrupt lets the first SIGINT/SIGTERM stop the run gracefully after the current probe finishes; a second
+// signal exits immediately.
+func stopOnInterrupt(interpreter interface{ Stop() }) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logrus.Warnf("received %s: stopping after the current probe; signal again to exit immediately", sig)
+		interpreter.Stop()
+		sig = <-signals
+		logrus.Fatalf("received %s again: exiting immediately", sig)
+	}()
+}
+
+// serveMetrics serves metrics in the background for the rest of the run; failing to serve them isn't worth
+// stopping the run for
+func serveMetrics(address string, metrics *connectivity.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	go func() {
+		logrus.Infof("serving metrics on %s/metrics", address)
+		if err := http.ListenAndServe(address, mux); err != nil {
+			logrus.Errorf("unable to serve metrics on %s: %+v", address, err)
+		}
+	}()
+}
+
+// validateGenerateArgs checks the flags which can be checked without a cluster -- that they're valid, and compatible
+// with each other -- so that a run with bad flags fails before creating any namespaces or pods
+func validateGenerateArgs(args *GenerateArgs) error {
+	if err := probe.ValidateMeshMode(args.ServiceMesh); err != nil {
+		return err
+	}
+	if args.DestinationType != "" {
+		if _, err := generator.ParseProbeMode(args.DestinationType); err != nil {
+			return err
+		}
+	}
+	if err := connectivity.ValidateLeftoverMode(args.LeftoverResources); err != nil {
+		return err
+	}
+	if args.ClientCommandsPath != "" && args.BatchJobs {
+		return errors.Errorf("--client-commands can't be used with --batch-jobs")
+	}
+	if args.OnlyFailed && args.FromResultsPath == "" {
+		return errors.Errorf("--only-failed requires --from-results")
+	}
+	if args.OpenShiftRoutePort != 0 && !args.OpenShift {
+		return errors.Errorf("--openshift-route-port requires --openshift")
+	}
+	if args.UDPBurstSize > 0 && args.BatchJobs {
+		return errors.Errorf("--udp-burst-size can't be used with --batch-jobs")
+	}
+	if args.EgressTarget == "" && (args.EgressProxy != "" || args.EgressGateway != "") {
+		return errors.Errorf("--egress-proxy and --egress-gateway require --egress-target")
+	}
+	if args.Corroborator != "" {
+		if args.Corroborator != "cilium" && args.Corroborator != "command" {
+			return errors.Errorf("invalid corroborator '%s': must be cilium or command", args.Corroborator)
+		}
+		if args.CNIDaemonSet == "" {
+			return errors.Errorf("--corroborator requires --cni-daemonset")
+		}
+		if args.Corroborator == "command" && args.DataplaneCommandPath == "" {
+			return errors.Errorf("--corroborator command requires --dataplane-command")
+		}
+	}
+	if args.Parallelism < 1 {
+		return errors.Errorf("--parallelism must be at least 1, got %d", args.Parallelism)
+	}
+	if args.Resume && args.CheckpointPath == "" {
+		return errors.Errorf("--resume requires --checkpoint-file")
+	}
+	if args.ExternalEndpoint != "" && args.DeployExternalEndpoint != 0 {
+		return errors.Errorf("--external-endpoint can't be used with --deploy-external-endpoint")
+	}
+	if (args.ExternalEndpoint != "" || args.DeployExternalEndpoint != 0) && args.EgressTarget != "" {
+		return errors.Errorf("--egress-target can't be used with an external endpoint")
+	}
+	if args.Repeat < 1 {
+		return errors.Errorf("--repeat must be at least 1, got %d", args.Repeat)
+	}
+	if args.ReplayProbesPath != "" && (args.Context != "" || args.Mock || args.RecordKubePath != "" || args.ReplayKubePath != "") {
+		return errors.Errorf("--replay-probes can't be used with --context, --mock, --record-kube or --replay-kube")
+	}
+	// results are matched up by namespace, and which set a test case runs in isn't deterministic
+	if (args.RecordProbesPath != "" || args.ReplayProbesPath != "") && args.Parallelism > 1 {
+		return errors.Errorf("--record-probes and --replay-probes can't be used with --parallelism greater than 1")
+	}
+	// --sonobuoy picks an artifacts directory if there isn't one
+	if args.PacketCapture && args.ArtifactsDir == "" && !args.Sonobuoy {
+		return errors.Errorf("--packet-capture requires --artifacts-dir")
+	}
+	if args.HTTPCheck && args.BatchJobs {
+		return errors.Errorf("--http-check can't be used with --batch-jobs")
+	}
+	if args.MeasureLatency && args.BatchJobs {
+		return errors.Errorf("--measure-latency can't be used with --batch-jobs")
+	}
+	if args.ExpectConnections != "" {
+		if _, err := probe.ParseConnectionFate(args.ExpectConnections); err != nil {
+			return err
+		}
+	}
+	if args.TLSCheck && (args.BatchJobs || args.HTTPCheck) {
+		return errors.Errorf("--tls-check can't be used with --batch-jobs or --http-check")
+	}
+	if args.DeployWorkerDaemonSet != 0 && (args.BatchJobs || args.ClientCommandsPath != "" || args.UDPBurstSize > 0 || args.HTTPCheck || args.TLSCheck || args.MeasureLatency) {
+		return errors.Errorf("--deploy-worker-daemonset can't be used with --batch-jobs, --client-commands, --udp-burst-size, --http-check, --tls-check or --measure-latency")
+	}
+	if args.MultiTargetExec && (args.BatchJobs || args.DeployWorkerDaemonSet != 0 || args.UDPBurstSize > 0 || args.HTTPCheck || args.TLSCheck || args.MeasureLatency) {
+		return errors.Errorf("--multi-target-exec can't be used with --batch-jobs, --deploy-worker-daemonset, --udp-burst-size, --http-check, --tls-check or --measure-latency")
+	}
+	if args.SourcePodParallelism < 0 {
+		return errors.Errorf("--source-pod-parallelism must not be negative, got %d", args.SourcePodParallelism)
+	}
+	return nil
+}
+
+func validatePolicyCoverageMode(mode string) {
+	if mode != "" && mode != connectivity.PolicyCoverageUncovered && mode != connectivity.PolicyCoverageAll {
+		utils.DoOrDie(errors.Errorf("invalid policy coverage mode %s; expected one of %+v", mode, connectivity.AllPolicyCoverageModes))
+	}
+}
+
+// printPolicyCoverage reports which elements of the policies of the printer's results were exercised by their
+// probes: all of them, or only those which weren't
+func printPolicyCoverage(mode string, printer *connectivity.Printer) {
+	coverage := (&connectivity.CombinedResults{Results: printer.Results}).PolicyCoverage()
+	if mode == connectivity.PolicyCoverageAll {
+		fmt.Printf("Policy coverage:\n%s\n", coverage.Table(false))
+	} else {
+		fmt.Printf("Policy elements never exercised by a probe:\n%s\n", coverage.Table(true))
+	}
+}
+
+func writeJUnitReport(path string, suiteName string, printer *connectivity.Printer) {
+	report := (&connectivity.CombinedResults{Results: printer.Results}).JUnitReport(suiteName, printer.IgnoreLoopback)
+	utils.DoOrDie(report.Write(path))
+	logrus.Infof("wrote junit report to %s", path)
+}
+
+func saveArtifacts(artifactsDir string, uploadURLs []string, results *connectivity.ResultsDocument) {
+	if artifactsDir == "" && len(uploadURLs) == 0 {
+		return
+	}
+
+	if artifactsDir == "" {
+		dir, err := ioutil.TempDir("", "cyclonus-artifacts-")
+		utils.DoOrDie(err)
+		artifactsDir = dir
+	} else {
+		utils.DoOrDie(os.MkdirAll(artifactsDir, 0755))
+	}
+
+	resultsPath, err := results.WriteToDirectory(artifactsDir)
+	utils.DoOrDie(err)
+	logrus.Infof("wrote results to %s", resultsPath)
+
+	if len(uploadURLs) == 0 {
+		return
+	}
+
+	bundleDir, err := ioutil.TempDir("", "cyclonus-bundle-")
+	utils.DoOrDie(err)
+	bundlePath := filepath.Join(bundleDir, fmt.Sprintf("cyclonus-results-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z")))
+	utils.DoOrDie(artifacts.Bundle(artifactsDir, bundlePath))
+
+	// don't let one bad target prevent uploading to the others
+	for _, target := range uploadURLs {
+		if err := artifacts.Upload(bundlePath, target); err != nil {
+			logrus.Errorf("unable to upload results bundle: %+v", err)
+		}
+	}
+}
+
+func setupCorroborator(args *GenerateArgs, kubernetes kube.IKubernetes) (connectivity.Corroborator, error) {
+	if args.Corroborator == "" {
+		return nil, nil
+	}
+	switch args.Corroborator {
+	case "cilium":
+		agents := &connectivity.CNIAgents{Kubernetes: kubernetes, Namespace: args.CNINamespace, DaemonSet: args.CNIDaemonSet, Container: "cilium-agent"}
+		return &connectivity.CiliumCorroborator{Agents: agents}, nil
+	case "command":
+		command, err := connectivity.ReadDataplaneCommand(args.DataplaneCommandPath)
+		if err != nil {
+			return nil, err
+		}
+		return connectivity.NewCommandCorroborator(kubernetes, args.CNINamespace, args.CNIDaemonSet, command), nil
+	default:
+		panic(errors.Errorf("unreachable: invalid corroborator '%s'", args.Corroborator))
+	}
 }
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"github.com/mattfenwick/cyclonus/pkg/kind"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+type KindArgs struct {
+	CNI         string
+	CNIManifest string
+	ClusterName string
+	NodeImage   string
+	KeepCluster bool
+	Generate    *GenerateArgs
+}
+
+func SetupKindCommand() *cobra.Command {
+	args := &KindArgs{Generate: &GenerateArgs{}}
+
+	command := &cobra.Command{
+		Use:   "kind",
+		Short: "create a kind cluster with a CNI, run the generate suite against it, and tear it down",
+		Long:  "create a kind cluster with the requested CNI, run the generate suite against it, and tear it down.  Accepts all of the flags of 'generate'; its --context is ignored in favor of the kind cluster's context.",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			applyCNIProfileFlags(cmd, args.Generate)
+			ctx, cancel := runContext(cmd)
+			defer cancel()
+			RunKindCommand(ctx, args)
+		},
+	}
+
+	command.Flags().StringVar(&args.CNI, "cni", kind.CNICalico, "CNI to install in the kind cluster; one of "+strings.Join(kind.AllCNIs(), ", "))
+	command.Flags().StringVar(&args.CNIManifest, "cni-manifest", "", "path or URL of a manifest to apply to install the CNI, instead of --cni, i.e. for a CNI cyclonus doesn't know how to install; the cluster's default CNI is disabled")
+	command.Flags().StringVar(&args.ClusterName, "cluster-name", "", "name of the kind cluster; if empty, uses 'netpol-<cni>'")
+	command.Flags().StringVar(&args.NodeImage, "node-image", "", "kind node image to use; if empty, uses kind's default")
+	command.Flags().BoolVar(&args.KeepCluster, "keep-cluster", false, "if true, don't delete the kind cluster after the run")
+
+	setupGenerateFlags(command, args.Generate)
+
+	return command
+}
+
+func RunKindCommand(ctx context.Context, args *KindArgs) {
+	cluster, err := args.Cluster()
+	utils.DoOrDie(err)
+	runGenerateOnKindCluster(ctx, cluster, args.KeepCluster, args.Generate)
+}
+
+func (k *KindArgs) Cluster() (*kind.Cluster, error) {
+	cni := kind.CanonicalCNI(k.CNI)
+	if k.CNIManifest != "" {
+		cni = kind.CNIManifest
+	}
+	clusterName := k.ClusterName
+	if clusterName == "" {
+		clusterName = "netpol-" + cni
+	}
+	if k.CNIManifest != "" {
+		return kind.NewManifestCluster(clusterName, k.CNIManifest, k.NodeImage), nil
+	}
+	return kind.NewCluster(clusterName, cni, k.NodeImage)
+}
+
+// runGenerateOnKindCluster creates the cluster, runs the generate suite against it, and -- unless keepCluster is set
+// -- deletes it, even if the run fails
+func runGenerateOnKindCluster(ctx context.Context, cluster *kind.Cluster, keepCluster bool, args *GenerateArgs) {
+	// don't spend minutes creating a cluster for a run whose flags are invalid
+	utils.DoOrDie(validateGenerateArgs(args))
+
+	teardown := func() {
+		if keepCluster {
+			logrus.Infof("keeping kind cluster %s", cluster.Name)
+			return
+		}
+		if err := cluster.Delete(); err != nil {
+			logrus.Errorf("unable to delete kind cluster %s: %+v", cluster.Name, err)
+		}
+	}
+	// RunGenerateCommand bails out via logrus.Fatal on errors, so make sure the cluster still gets cleaned up
+	logrus.RegisterExitHandler(teardown)
+
+	utils.DoOrDie(cluster.Create())
+
+	args.Context = cluster.KubeContext()
+	RunGenerateCommand(ctx, args)
+
+	teardown()
+}
@@ -1,16 +1,19 @@
 package cli
 
 import (
+	"context"
 	"github.com/mattfenwick/cyclonus/pkg/connectivity"
 	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
 	"github.com/mattfenwick/cyclonus/pkg/generator"
 	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
 	"github.com/mattfenwick/cyclonus/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"io/ioutil"
 	v1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/yaml"
 	"strings"
@@ -18,12 +21,26 @@ import (
 
 type ProbeArgs struct {
 	Noisy                     bool
+	FailuresOnly              bool
+	CombinedView              bool
 	IgnoreLoopback            bool
 	KubeContext               string
 	PerturbationWaitSeconds   int
 	PodCreationTimeoutSeconds int
 	PolicyPath                string
+	JUnitReportPath           string
 	ProbeMode                 string
+	CrossModeCheck            bool
+	ClientCommandsPath        string
+	ServiceMesh               string
+	OpenShift                 bool
+	UDPBurstSize              int
+	HTTPCheck                 bool
+	TLSCheck                  bool
+	MeasureLatency            bool
+	NodeLabels                map[string]string
+	NodeSelector              string
+	PolicyCoverage            string
 
 	// what to probe on
 	ProbeAllAvailable bool
@@ -35,6 +52,10 @@ type ProbeArgs struct {
 	ServerPorts      []int
 	ServerNamespaces []string
 	ServerPods       []string
+
+	// bring your own pods
+	ExistingPods bool
+	PodSelector  string
 }
 
 func SetupProbeCommand() *cobra.Command {
@@ -45,7 +66,9 @@ func SetupProbeCommand() *cobra.Command {
 		Short: "run a connectivity probe against kubernetes pods",
 		Args:  cobra.ExactArgs(0),
 		Run: func(cmd *cobra.Command, as []string) {
-			RunProbeCommand(args)
+			ctx, cancel := runContext(cmd)
+			defer cancel()
+			RunProbeCommand(ctx, args)
 		},
 	}
 
@@ -54,37 +77,95 @@ func SetupProbeCommand() *cobra.Command {
 	command.Flags().IntSliceVar(&args.ServerPorts, "server-port", []int{80, 81}, "ports to run server on")
 	command.Flags().StringSliceVar(&args.ServerProtocols, "server-protocol", []string{"TCP", "UDP", "SCTP"}, "protocols to run server on")
 
+	command.Flags().BoolVar(&args.ExistingPods, "existing-pods", false, "if true, don't create server pods: probe between already-running pods in the server namespaces instead, using their declared container ports as destinations and an injected ephemeral container as the client.  Requires ephemeral containers to be enabled in the cluster, and probe mode "+generator.ProbeModePodIP)
+	command.Flags().StringVar(&args.PodSelector, "pod-selector", "", "label selector to pick pods with, if using --existing-pods; if empty, all pods in the server namespaces are used")
+
 	command.Flags().BoolVar(&args.ProbeAllAvailable, "all-available", true, "if true, probe all available ports and protocols on each pod")
 	command.Flags().StringSliceVar(&args.Ports, "port", []string{"80"}, "ports to run probes on; may be named port or numbered port")
 	command.Flags().StringSliceVar(&args.Protocols, "protocol", []string{"tcp"}, "protocols to run probes on")
 
+	command.Flags().StringVar(&args.ServiceMesh, "service-mesh", probe.MeshModeWarn, "what to do about Istio/Linkerd sidecar injection in the server namespaces, which distorts results; one of "+strings.Join(probe.AllMeshModes, ", ")+".  '"+probe.MeshModeAdjust+"' opts cyclonus's pods out of injection and drops server ports reserved by mesh proxies")
+	command.Flags().StringVar(&args.ClientCommandsPath, "client-commands", "", "path to a yaml file mapping protocols to probe command templates (a 'command' list of go templates rendered with the probe job, and an optional 'successRegex' for stdout), to use instead of agnhost")
+	command.Flags().BoolVar(&args.OpenShift, "openshift", false, "if true, create pods which comply with OpenShift's restricted SCCs, and create the server namespaces opted out of the default project node selector")
+	command.Flags().StringToStringVar(&args.NodeLabels, "node-label", map[string]string{}, "node labels, i.e. 'kubernetes.io/os=linux', which nodes must have for cyclonus's pods to be scheduled onto them; sets the pods' nodeSelector")
+	command.Flags().StringVar(&args.NodeSelector, "node-selector", "", "label selector, i.e. 'kubernetes.io/os=linux,cni-migrated notin (false)', picking the nodes cyclonus's pods may be scheduled onto; unlike --node-label, supports set-based requirements, and is applied as required node affinity")
+	command.Flags().IntVar(&args.UDPBurstSize, "udp-burst-size", 0, "if positive, each UDP probe sends this many sequenced datagrams instead of one, and the delivery rate of each pair is reported, so that allowed but lossy paths stand out")
+	command.Flags().BoolVar(&args.HTTPCheck, "http-check", false, "if true, TCP servers answer HTTP, and each TCP probe, once connected, requests / and is only allowed if the serving pod answers -- so that connections accepted by a proxy, or blackholed after the handshake, aren't mistaken for allowed ones")
+	command.Flags().BoolVar(&args.TLSCheck, "tls-check", false, "if true, TCP servers terminate TLS with a self-signed certificate made for the run, and each TCP probe, once connected, does a TLS handshake trusting only that certificate and requests the serving pod's hostname, and is only allowed if both work -- so that connections accepted by a service mesh's proxies aren't mistaken for allowed ones.  Incompatible with --http-check")
+	command.Flags().BoolVar(&args.MeasureLatency, "measure-latency", false, "if true, time each probe in the client pod, and report latency percentiles of allowed probes; the client image needs sh and a date supporting %N")
+	command.Flags().BoolVar(&args.CrossModeCheck, "cross-mode-check", false, "if true, additionally probe by both pod IP and service IP, and report cells where they disagree")
 	command.Flags().StringVar(&args.ProbeMode, "probe-mode", generator.ProbeModeServiceName, "probe mode to use, must be one of "+strings.Join(generator.AllProbeModes, ", "))
 
+	command.Flags().StringVar(&args.PolicyCoverage, "policy-coverage", "", "if set, report which ingress and egress rules, peers and ports of the namespaces' policies were exercised by at least one probe; one of "+strings.Join(connectivity.AllPolicyCoverageModes, ", ")+": '"+connectivity.PolicyCoverageUncovered+"' lists only the elements which never were")
 	command.Flags().BoolVar(&args.Noisy, "noisy", false, "if true, print all results")
+	command.Flags().BoolVar(&args.FailuresOnly, "failures-only", false, "if true, tables for failed steps only show sources and destinations with at least one mismatch")
+	command.Flags().BoolVar(&args.CombinedView, "combined-view", false, "if true, print a single table per step whose cells summarize the results for every port and protocol, i.e. 'TCP80 ✓ / TCP81 ✗* / UDP80 ✓', instead of separate tables")
 	command.Flags().BoolVar(&args.IgnoreLoopback, "ignore-loopback", false, "if true, ignore loopback for truthtable correctness verification")
 	command.Flags().StringVar(&args.KubeContext, "context", "", "kubernetes context to use; if empty, uses default context")
 	command.Flags().IntVar(&args.PerturbationWaitSeconds, "perturbation-wait-seconds", 5, "number of seconds to wait after perturbing the cluster (i.e. create a network policy, modify a ns/pod label) before running probes, to give the CNI time to update the cluster state")
 	command.Flags().IntVar(&args.PodCreationTimeoutSeconds, "pod-creation-timeout-seconds", 60, "number of seconds to wait for pods to create, be running and have IP addresses")
-	command.Flags().StringVar(&args.PolicyPath, "policy-path", "", "path to yaml network policy to create in kube; if empty, will not create any policies")
+	command.Flags().StringVar(&args.JUnitReportPath, "junit-report", "", "path to write a JUnit XML report to, with a test case for each probe")
+	command.Flags().StringVar(&args.PolicyPath, "policy-path", "", "path to yaml network policy, AdminNetworkPolicy or BaselineAdminNetworkPolicy to create in kube; if empty, will not create any policies")
 
 	return command
 }
 
-func RunProbeCommand(args *ProbeArgs) {
-	externalIPs := []string{"http://www.google.com"} // TODO make these be IPs?  or not?
+func RunProbeCommand(ctx context.Context, args *ProbeArgs) {
+	validatePolicyCoverageMode(args.PolicyCoverage)
 	if len(args.ServerNamespaces) == 0 || len(args.ServerPods) == 0 {
 		panic(errors.Errorf("found 0 namespaces or pods, must have at least 1 of each"))
 	}
 
 	kubernetes, err := kube.NewKubernetesForContext(args.KubeContext)
 	utils.DoOrDie(err)
+	kubernetes.Context = ctx
 
 	protocols := parseProtocols(args.Protocols)
 	serverProtocols := parseProtocols(args.ServerProtocols)
 
-	resources, err := probe.NewDefaultResources(kubernetes, args.ServerNamespaces, args.ServerPods, args.ServerPorts, serverProtocols, externalIPs, args.PodCreationTimeoutSeconds, false)
+	var tlsCertificate *probe.TLSCertificate
+	if args.TLSCheck {
+		if args.HTTPCheck {
+			utils.DoOrDie(errors.Errorf("--tls-check can't be used with --http-check"))
+		}
+		tlsCertificate, err = probe.NewTLSCertificate()
+		utils.DoOrDie(err)
+	}
+
+	var resources *probe.Resources
+	if args.ExistingPods {
+		if args.ProbeMode != generator.ProbeModePodIP {
+			utils.DoOrDie(errors.Errorf("--existing-pods requires --probe-mode=%s, since there are no cyclonus services for existing pods", generator.ProbeModePodIP))
+		}
+		if args.HTTPCheck {
+			utils.DoOrDie(errors.Errorf("--http-check can't be used with --existing-pods, which may not answer HTTP"))
+		}
+		if args.TLSCheck {
+			utils.DoOrDie(errors.Errorf("--tls-check can't be used with --existing-pods, which don't serve cyclonus's certificate"))
+		}
+		resources, err = probe.NewResourcesFromExistingPods(kubernetes, args.ServerNamespaces, args.PodSelector, args.PodCreationTimeoutSeconds)
+	} else {
+		var serverPorts []int
+		var podOptions *probe.PodOptions
+		if args.OpenShift {
+			utils.DoOrDie(probe.PrepareOpenShiftNamespaces(kubernetes, args.ServerNamespaces))
+		}
+		serverPorts, podOptions, err = probe.HandleServiceMeshes(kubernetes, args.ServerNamespaces, args.ServerPorts, args.ServiceMesh)
+		utils.DoOrDie(err)
+		podOptions.Restricted = args.OpenShift
+		podOptions.ServeHTTP = args.HTTPCheck
+		podOptions.TLSCertificate = tlsCertificate
+		utils.DoOrDie(podOptions.SetNodeScheduling(args.NodeLabels, args.NodeSelector))
+		resources, err = probe.NewDefaultResources(kubernetes, args.ServerNamespaces, args.ServerPods, serverPorts, serverProtocols, args.PodCreationTimeoutSeconds, false, podOptions)
+	}
 	utils.DoOrDie(err)
 
+	var clientCommands *probe.ClientCommands
+	if args.ClientCommandsPath != "" {
+		clientCommands, err = probe.ReadClientCommands(args.ClientCommandsPath)
+		utils.DoOrDie(err)
+	}
+
 	interpreterConfig := &connectivity.InterpreterConfig{
 		ResetClusterBeforeTestCase:       false,
 		KubeProbeRetries:                 0,
@@ -92,8 +173,16 @@ func RunProbeCommand(args *ProbeArgs) {
 		VerifyClusterStateBeforeTestCase: false,
 		BatchJobs:                        false,
 		IgnoreLoopback:                   args.IgnoreLoopback,
+		CrossModeCheck:                   args.CrossModeCheck,
+		ClientCommands:                   clientCommands,
+		UDPBurstSize:                     args.UDPBurstSize,
+		HTTPCheck:                        args.HTTPCheck,
+		TLSCertificate:                   tlsCertificate,
+		MeasureLatency:                   args.MeasureLatency,
+		Context:                          ctx,
 	}
-	interpreter := connectivity.NewInterpreter(kubernetes, resources, interpreterConfig)
+	interpreter, err := connectivity.NewInterpreter(kubernetes, resources, interpreterConfig)
+	utils.DoOrDie(err)
 
 	actions := []*generator.Action{generator.ReadNetworkPolicies(args.ServerNamespaces)}
 
@@ -101,16 +190,35 @@ func RunProbeCommand(args *ProbeArgs) {
 		policyBytes, err := ioutil.ReadFile(args.PolicyPath)
 		utils.DoOrDie(err)
 
-		var kubePolicy networkingv1.NetworkPolicy
-		err = yaml.Unmarshal(policyBytes, &kubePolicy)
-		utils.DoOrDie(err)
+		var typeMeta metav1.TypeMeta
+		utils.DoOrDie(yaml.Unmarshal(policyBytes, &typeMeta))
+		switch typeMeta.Kind {
+		case anp.AdminNetworkPolicyKind:
+			var adminPolicy anp.AdminNetworkPolicy
+			err = yaml.Unmarshal(policyBytes, &adminPolicy)
+			utils.DoOrDie(err)
 
-		actions = append(actions, generator.CreatePolicy(&kubePolicy))
+			actions = append(actions, generator.CreateAdminNetworkPolicy(&adminPolicy))
+		case anp.BaselineAdminNetworkPolicyKind:
+			var baselinePolicy anp.BaselineAdminNetworkPolicy
+			err = yaml.Unmarshal(policyBytes, &baselinePolicy)
+			utils.DoOrDie(err)
+
+			actions = append(actions, generator.CreateBaselineAdminNetworkPolicy(&baselinePolicy))
+		default:
+			var kubePolicy networkingv1.NetworkPolicy
+			err = yaml.Unmarshal(policyBytes, &kubePolicy)
+			utils.DoOrDie(err)
+
+			actions = append(actions, generator.CreatePolicy(&kubePolicy))
+		}
 	}
 
 	printer := connectivity.Printer{
 		Noisy:          args.Noisy,
 		IgnoreLoopback: args.IgnoreLoopback,
+		FailuresOnly:   args.FailuresOnly,
+		CombinedView:   args.CombinedView,
 	}
 
 	mode, err := generator.ParseProbeMode(args.ProbeMode)
@@ -129,6 +237,13 @@ func RunProbeCommand(args *ProbeArgs) {
 			}
 		}
 	}
+
+	if args.PolicyCoverage != "" {
+		printPolicyCoverage(args.PolicyCoverage, &printer)
+	}
+	if args.JUnitReportPath != "" {
+		writeJUnitReport(args.JUnitReportPath, "cyclonus probe", &printer)
+	}
 }
 
 func parseProtocols(strs []string) []v1.Protocol {
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ReachabilityOutputTable = "table"
+	ReachabilityOutputCSV   = "csv"
+	ReachabilityOutputJSON  = "json"
+)
+
+var AllReachabilityOutputs = []string{ReachabilityOutputTable, ReachabilityOutputCSV, ReachabilityOutputJSON}
+
+type ReachabilityArgs struct {
+	AnalyzeArgs
+	Output string
+}
+
+func SetupReachabilityCommand() *cobra.Command {
+	args := &ReachabilityArgs{}
+
+	command := &cobra.Command{
+		Use:   "reachability",
+		Short: "simulate the reachability matrix between all pods -- on each of their containers' ports -- from the cluster's policies, without probing",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			RunReachabilityCommand(args)
+		},
+	}
+
+	setupPolicySourceFlags(command, &args.AnalyzeArgs)
+	command.Flags().StringVarP(&args.Output, "output", "o", ReachabilityOutputTable, fmt.Sprintf("output format; one of %+v", AllReachabilityOutputs))
+
+	return command
+}
+
+func RunReachabilityCommand(args *ReachabilityArgs) {
+	isValidOutput := false
+	for _, output := range AllReachabilityOutputs {
+		isValidOutput = isValidOutput || args.Output == output
+	}
+	if !isValidOutput {
+		utils.DoOrDie(errors.Errorf("invalid output format %s; expected one of %+v", args.Output, AllReachabilityOutputs))
+	}
+
+	kubePolicies, _, kubePods, kubeNamespaces, cni := readPoliciesAndPods(&args.AnalyzeArgs)
+	resources := syntheticResources(kubePods, kubeNamespaces)
+	if len(resources.Pods) == 0 {
+		utils.DoOrDie(errors.Errorf("found no pods with container ports to simulate reachability between: read them with --namespace, --all-namespaces or --snapshot-dir"))
+	}
+	policies := matcher.BuildNetworkPolicies(args.SimplifyPolicies, kubePolicies)
+	cni.addTo(policies)
+	table := probe.NewSimulatedRunner(policies).RunProbeForConfig(generator.ProbeAllAvailable, resources)
+
+	switch args.Output {
+	case ReachabilityOutputTable:
+		fmt.Printf("Ingress:\n%s\n", table.RenderIngress())
+		fmt.Printf("Egress:\n%s\n", table.RenderEgress())
+		fmt.Printf("Combined:\n%s\n", table.RenderTable())
+		fmt.Printf("%d of %d simulated probes allowed\n", table.CountConnectivity(probe.ConnectivityAllowed), table.CountJobResults())
+	case ReachabilityOutputCSV:
+		csv, err := table.RenderCSV()
+		utils.DoOrDie(err)
+		fmt.Print(csv)
+	case ReachabilityOutputJSON:
+		fmt.Println(utils.JsonString(table.Reachability()))
+	}
+}
@@ -1,11 +1,13 @@
 package cli
 
 import (
+	"context"
 	"github.com/mattfenwick/cyclonus/pkg/utils"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"os"
+	"time"
 )
 
 func RunRootCommand() {
@@ -18,6 +20,7 @@ func RunRootCommand() {
 
 type RootFlags struct {
 	Verbosity string
+	Timeout   time.Duration
 }
 
 func SetupRootCommand() *cobra.Command {
@@ -31,11 +34,24 @@ func SetupRootCommand() *cobra.Command {
 	}
 
 	command.PersistentFlags().StringVarP(&flags.Verbosity, "verbosity", "v", "info", "log level; one of [info, debug, trace, warn, error, fatal, panic]")
+	command.PersistentFlags().DurationVar(&flags.Timeout, "timeout", 0, "time limit for the whole run, i.e. '2h'; once it's up, kube API calls and probes in flight are cancelled, and commands which run test cases stop and report what they've got.  0 means no limit")
 
 	command.AddCommand(SetupAnalyzeCommand())
 	command.AddCommand(SetupCompareCommand())
+	command.AddCommand(SetupConvertCommand())
+	command.AddCommand(SetupDiffCommand())
+	command.AddCommand(SetupEffectivePoliciesCommand())
+	command.AddCommand(SetupFeaturesCommand())
+	command.AddCommand(SetupGateCommand())
+	command.AddCommand(SetupFuzzCommand())
 	command.AddCommand(SetupGenerateCommand())
+	command.AddCommand(SetupKindCommand())
 	command.AddCommand(SetupProbeCommand())
+	command.AddCommand(SetupReachabilityCommand())
+	command.AddCommand(SetupShellCommand())
+	command.AddCommand(SetupSynthesizeCommand())
+	command.AddCommand(SetupTestPoliciesCommand())
+	command.AddCommand(SetupVerifyCommand())
 	command.AddCommand(SetupVersionCommand())
 
 	// TODO
@@ -43,3 +59,13 @@ func SetupRootCommand() *cobra.Command {
 
 	return command
 }
+
+// runContext is the context for a command's whole run, which is done once the global --timeout is up
+func runContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	utils.DoOrDie(err)
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
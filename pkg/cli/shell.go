@@ -0,0 +1,488 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"io"
+	v1 "k8s.io/api/core/v1"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func SetupShellCommand() *cobra.Command {
+	args := &AnalyzeArgs{}
+
+	command := &cobra.Command{
+		Use:   "shell",
+		Short: "interactively query network policies, reading policies and cluster state only once",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			RunShellCommand(args)
+		},
+	}
+
+	setupPolicySourceFlags(command, args)
+
+	return command
+}
+
+func RunShellCommand(args *AnalyzeArgs) {
+	kubePolicies, _, kubePods, kubeNamespaces, cni := readPoliciesAndPods(args)
+	policies := matcher.BuildNetworkPolicies(args.SimplifyPolicies, kubePolicies)
+	cni.addTo(policies)
+	shell := NewShell(policies, kubePods, kubeNamespaces)
+	utils.DoOrDie(shell.Run(os.Stdin, os.Stdout))
+}
+
+const (
+	shellArgPod      = "pod"
+	shellArgIP       = "ip"
+	shellArgPort     = "port"
+	shellArgProtocol = "protocol"
+)
+
+type shellCommand struct {
+	Name string
+	// Args are the kinds of the command's arguments, which determine how they're tab-completed
+	Args  []string
+	Usage string
+	Help  string
+}
+
+var shellCommands = []*shellCommand{
+	{Name: "help", Help: "list commands"},
+	{Name: "pods", Help: "list pods, with their labels and IPs"},
+	{Name: "policies", Help: "explain all policies"},
+	{Name: "explain", Args: []string{shellArgPod}, Usage: "<ns/pod>", Help: "explain the policies which apply to a pod"},
+	{Name: "traffic", Args: []string{shellArgPod, shellArgPod, shellArgPort, shellArgProtocol}, Usage: "<from ns/pod or IP> <to ns/pod> <port> [protocol]", Help: "whether traffic from a pod, or from an IP outside the cluster, to a pod is allowed, and why -- rule by rule"},
+	{Name: "who-can-reach", Args: []string{shellArgPod, shellArgPort, shellArgProtocol}, Usage: "<ns/pod> <port> [protocol]", Help: "which pods are allowed to reach a pod"},
+	{Name: "from-external", Args: []string{shellArgIP, shellArgPort, shellArgProtocol}, Usage: "<IP> <port> [protocol]", Help: "which pods an IP outside the cluster is allowed to reach"},
+	{Name: "exit", Help: "leave the shell"},
+}
+
+// Shell answers queries about policies and pods, which are read once up front
+type Shell struct {
+	Policies        *matcher.Policy
+	Pods            map[string]v1.Pod
+	NamespaceLabels map[string]map[string]string
+	Out             io.Writer
+	podNames        []string
+}
+
+func NewShell(policies *matcher.Policy, kubePods []v1.Pod, kubeNamespaces []v1.Namespace) *Shell {
+	shell := &Shell{
+		Policies:        policies,
+		Pods:            map[string]v1.Pod{},
+		NamespaceLabels: map[string]map[string]string{},
+		Out:             os.Stdout,
+	}
+	for _, ns := range kubeNamespaces {
+		shell.NamespaceLabels[ns.Name] = ns.Labels
+	}
+	for _, pod := range kubePods {
+		name := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+		shell.Pods[name] = pod
+		shell.podNames = append(shell.podNames, name)
+	}
+	sort.Strings(shell.podNames)
+	return shell
+}
+
+// Run reads commands until 'exit' or the end of the input.  Tab completion is only available if in is a terminal;
+// otherwise, commands are read line by line, so that they can be piped in.
+func (s *Shell) Run(in *os.File, out io.Writer) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		s.Out = out
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			if s.Execute(scanner.Text()) {
+				return nil
+			}
+		}
+		return errors.Wrapf(scanner.Err(), "unable to read commands")
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return errors.Wrapf(err, "unable to set up terminal")
+	}
+	defer term.Restore(fd, oldState)
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{in, out}, "cyclonus> ")
+	terminal.AutoCompleteCallback = s.complete
+	s.Out = terminal
+	fmt.Fprintf(s.Out, "loaded %d pods; type 'help' for commands, and press tab to complete\n", len(s.podNames))
+	for {
+		line, err := terminal.ReadLine()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "unable to read command")
+		}
+		if s.Execute(line) {
+			return nil
+		}
+	}
+}
+
+// Execute runs a single command, printing its result or error, and returns true if the shell should exit
+func (s *Shell) Execute(line string) bool {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return false
+	}
+	var err error
+	switch words[0] {
+	case "exit", "quit":
+		return true
+	case "help":
+		s.help()
+	case "pods":
+		s.pods()
+	case "policies":
+		fmt.Fprintf(s.Out, "%s\n", s.Policies.ExplainTable())
+	case "explain":
+		err = s.explain(words[1:])
+	case "traffic":
+		err = s.traffic(words[1:])
+	case "who-can-reach":
+		err = s.whoCanReach(words[1:])
+	case "from-external":
+		err = s.fromExternal(words[1:])
+	default:
+		err = errors.Errorf("unknown command '%s'; type 'help' for commands", words[0])
+	}
+	if err != nil {
+		fmt.Fprintf(s.Out, "error: %s\n", err)
+	}
+	return false
+}
+
+func (s *Shell) help() {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Command", "Description"})
+	for _, command := range shellCommands {
+		table.Append([]string{strings.TrimSpace(command.Name + " " + command.Usage), command.Help})
+	}
+	table.Render()
+	fmt.Fprint(s.Out, str.String())
+}
+
+func (s *Shell) pods() {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Pod", "Labels", "IP"})
+	for _, name := range s.podNames {
+		pod := s.Pods[name]
+		table.Append([]string{name, labelsString(pod.Labels), pod.Status.PodIP})
+	}
+	table.Render()
+	fmt.Fprint(s.Out, str.String())
+}
+
+func labelsString(labels map[string]string) string {
+	var pairs []string
+	for key, value := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (s *Shell) explain(args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("usage: explain <ns/pod>")
+	}
+	pod, err := s.getPod(args[0])
+	if err != nil {
+		return err
+	}
+	targets, combinedRules := QueryTargetHelper(s.Policies, &QueryTargetPod{Namespace: pod.Namespace, Labels: pod.Labels})
+	fmt.Fprintf(s.Out, "Matching targets:\n%s\n", targets.ExplainTable())
+	fmt.Fprintf(s.Out, "Combined rules:\n%s\n", combinedRules.ExplainTable())
+	return nil
+}
+
+func (s *Shell) traffic(args []string) error {
+	if len(args) != 3 && len(args) != 4 {
+		return errors.Errorf("usage: traffic <from ns/pod or IP> <to ns/pod> <port> [protocol]")
+	}
+	protocol, err := parseShellProtocol(args[3:])
+	if err != nil {
+		return err
+	}
+	traffic, err := s.buildTraffic(args[0], args[1], args[2], protocol)
+	if err != nil {
+		return err
+	}
+	result := s.Policies.IsTrafficAllowed(traffic)
+	fmt.Fprintf(s.Out, "Traffic:\n%s\n", traffic.Table())
+	fmt.Fprintf(s.Out, "Is traffic allowed?\n%s\n", result.Table())
+	fmt.Fprintf(s.Out, "Trace:\n%s\n", s.Policies.TraceTraffic(traffic).Table())
+	return nil
+}
+
+func (s *Shell) whoCanReach(args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errors.Errorf("usage: who-can-reach <ns/pod> <port> [protocol]")
+	}
+	protocol, err := parseShellProtocol(args[2:])
+	if err != nil {
+		return err
+	}
+
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Source", "Ingress", "Egress", "Allowed"})
+	allowed := 0
+	for _, from := range s.podNames {
+		traffic, err := s.buildTraffic(from, args[0], args[1], protocol)
+		if err != nil {
+			return err
+		}
+		result := s.Policies.IsTrafficAllowed(traffic)
+		if result.IsAllowed() {
+			allowed++
+		}
+		table.Append([]string{from, allowedString(result.Ingress.IsAllowed()), allowedString(result.Egress.IsAllowed()), allowedString(result.IsAllowed())})
+	}
+	table.Render()
+	fmt.Fprintf(s.Out, "%s%d of %d pods can reach %s on %s/%s\n", str.String(), allowed, len(s.podNames), args[0], protocol, args[1])
+	return nil
+}
+
+// fromExternal is the ingress-from-outside question: since traffic from outside the cluster isn't subject to egress
+// policies, only the destinations' ingress policies matter
+func (s *Shell) fromExternal(args []string) error {
+	if len(args) != 2 && len(args) != 3 {
+		return errors.Errorf("usage: from-external <IP> <port> [protocol]")
+	}
+	protocol, err := parseShellProtocol(args[2:])
+	if err != nil {
+		return err
+	}
+
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Destination", "Ingress"})
+	if net.ParseIP(args[0]) == nil {
+		return errors.Errorf("invalid IP '%s'", args[0])
+	}
+	allowed, destinations := 0, 0
+	for _, to := range s.podNames {
+		// a named port only applies to the pods which declare it
+		if _, _, err := resolveShellPort(s.Pods[to], args[1], protocol); err != nil {
+			continue
+		}
+		traffic, err := s.buildTraffic(args[0], to, args[1], protocol)
+		if err != nil {
+			return err
+		}
+		destinations++
+		isAllowed := s.Policies.IsTrafficAllowed(traffic).Ingress.IsAllowed()
+		if isAllowed {
+			allowed++
+		}
+		table.Append([]string{to, allowedString(isAllowed)})
+	}
+	table.Render()
+	fmt.Fprintf(s.Out, "%s%s can reach %d of %d pods on %s/%s\n", str.String(), args[0], allowed, destinations, protocol, args[1])
+	return nil
+}
+
+func allowedString(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "blocked"
+}
+
+func parseShellProtocol(args []string) (v1.Protocol, error) {
+	if len(args) == 0 {
+		return v1.ProtocolTCP, nil
+	}
+	protocol := v1.Protocol(strings.ToUpper(args[0]))
+	switch protocol {
+	case v1.ProtocolTCP, v1.ProtocolUDP, v1.ProtocolSCTP:
+		return protocol, nil
+	default:
+		return "", errors.Errorf("invalid protocol '%s'; expected one of TCP, UDP, SCTP", args[0])
+	}
+}
+
+func (s *Shell) getPod(name string) (v1.Pod, error) {
+	pod, ok := s.Pods[name]
+	if !ok {
+		return pod, errors.Errorf("pod '%s' not found; pods are named as 'namespace/name'", name)
+	}
+	return pod, nil
+}
+
+// buildTraffic builds traffic from a pod -- or, if fromName is an IP, from outside the cluster -- to a pod
+func (s *Shell) buildTraffic(fromName string, toName string, port string, protocol v1.Protocol) (*matcher.Traffic, error) {
+	source, err := s.sourcePeer(fromName)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.getPod(toName)
+	if err != nil {
+		return nil, err
+	}
+	resolvedPort, resolvedPortName, err := resolveShellPort(to, port, protocol)
+	if err != nil {
+		return nil, err
+	}
+	return &matcher.Traffic{
+		Source:           source,
+		Destination:      s.trafficPeer(to),
+		ResolvedPort:     resolvedPort,
+		ResolvedPortName: resolvedPortName,
+		Protocol:         protocol,
+	}, nil
+}
+
+func (s *Shell) sourcePeer(name string) (*matcher.TrafficPeer, error) {
+	if net.ParseIP(name) != nil {
+		return &matcher.TrafficPeer{IP: name}, nil
+	}
+	pod, err := s.getPod(name)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "sources may also be IPs")
+	}
+	return s.trafficPeer(pod), nil
+}
+
+func (s *Shell) trafficPeer(pod v1.Pod) *matcher.TrafficPeer {
+	return &matcher.TrafficPeer{
+		Internal: &matcher.InternalPeer{
+			PodLabels:       pod.Labels,
+			NamespaceLabels: s.NamespaceLabels[pod.Namespace],
+			Namespace:       pod.Namespace,
+		},
+		IP: pod.Status.PodIP,
+	}
+}
+
+// resolveShellPort finds the number and name of a pod's port, given either; a port number which the pod doesn't
+// declare is still valid, but has no name
+func resolveShellPort(pod v1.Pod, port string, protocol v1.Protocol) (int, string, error) {
+	number, err := strconv.Atoi(port)
+	isNumber := err == nil
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			portProtocol := containerPort.Protocol
+			if portProtocol == "" {
+				portProtocol = v1.ProtocolTCP
+			}
+			if portProtocol != protocol {
+				continue
+			}
+			if (isNumber && int(containerPort.ContainerPort) == number) || (!isNumber && containerPort.Name == port) {
+				return int(containerPort.ContainerPort), containerPort.Name, nil
+			}
+		}
+	}
+	if isNumber {
+		return number, "", nil
+	}
+	return 0, "", errors.Errorf("pod %s/%s has no %s port named '%s'", pod.Namespace, pod.Name, protocol, port)
+}
+
+// complete is a term.Terminal AutoCompleteCallback: on tab, it completes the word before the cursor -- if it's at
+// the end of the line -- as far as all the candidates agree
+func (s *Shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || pos != len(line) {
+		return "", 0, false
+	}
+	words := strings.Fields(line)
+	prefix := ""
+	if len(words) > 0 && !strings.HasSuffix(line, " ") {
+		prefix = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	var matches []string
+	for _, candidate := range s.completionCandidates(words) {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	completed := commonPrefix(matches)
+	if len(matches) == 1 {
+		completed += " "
+	}
+	newLine := line[:len(line)-len(prefix)] + completed
+	return newLine, len(newLine), true
+}
+
+// completionCandidates lists the possible values of the word following words
+func (s *Shell) completionCandidates(words []string) []string {
+	if len(words) == 0 {
+		var names []string
+		for _, command := range shellCommands {
+			names = append(names, command.Name)
+		}
+		return names
+	}
+	for _, command := range shellCommands {
+		if command.Name != words[0] || len(words)-1 >= len(command.Args) {
+			continue
+		}
+		switch command.Args[len(words)-1] {
+		case shellArgPod:
+			return s.podNames
+		case shellArgProtocol:
+			return []string{string(v1.ProtocolTCP), string(v1.ProtocolUDP), string(v1.ProtocolSCTP)}
+		case shellArgPort:
+			// the destination pod is the argument just before the port
+			if pod, ok := s.Pods[words[len(words)-1]]; ok {
+				return podPortCandidates(pod)
+			}
+		}
+	}
+	return nil
+}
+
+func podPortCandidates(pod v1.Pod) []string {
+	var ports []string
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			ports = append(ports, fmt.Sprintf("%d", port.ContainerPort))
+			if port.Name != "" {
+				ports = append(ports, port.Name)
+			}
+		}
+	}
+	return ports
+}
+
+func commonPrefix(strs []string) string {
+	prefix := strs[0]
+	for _, str := range strs[1:] {
+		for !strings.HasPrefix(str, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
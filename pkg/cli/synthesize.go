@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/synthesis"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type SynthesizeArgs struct {
+	SpecPath  string
+	OutputDir string
+}
+
+func SetupSynthesizeCommand() *cobra.Command {
+	args := &SynthesizeArgs{}
+
+	command := &cobra.Command{
+		Use:   "synthesize",
+		Short: "generate network policies realizing a desired connectivity matrix between workloads, and verify them with the policy simulator",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			RunSynthesizeCommand(args)
+		},
+	}
+
+	command.Flags().StringVar(&args.SpecPath, "spec-path", "", "path to a yaml or json file of workloads -- by namespace and labels -- and the traffic allowed between them")
+	utils.DoOrDie(command.MarkFlagRequired("spec-path"))
+	command.Flags().StringVar(&args.OutputDir, "output-dir", "", "directory to write a yaml file per policy to; if empty, policies are printed as yaml documents")
+
+	return command
+}
+
+func RunSynthesizeCommand(args *SynthesizeArgs) {
+	spec, err := synthesis.ReadSpec(args.SpecPath)
+	utils.DoOrDie(err)
+	policies := synthesis.Synthesize(spec)
+
+	if args.OutputDir != "" {
+		utils.DoOrDie(errors.Wrapf(os.MkdirAll(args.OutputDir, 0755), "unable to make directory %s", args.OutputDir))
+		for _, policy := range policies {
+			path := filepath.Join(args.OutputDir, fmt.Sprintf("%s-%s.yaml", policy.Namespace, policy.Name))
+			utils.DoOrDie(errors.Wrapf(ioutil.WriteFile(path, []byte(utils.YamlString(policy)), 0644), "unable to write file %s", path))
+		}
+		logrus.Infof("wrote %d policies to %s", len(policies), args.OutputDir)
+	} else {
+		var documents []string
+		for _, policy := range policies {
+			documents = append(documents, utils.YamlString(policy))
+		}
+		fmt.Print(strings.Join(documents, "---\n"))
+	}
+
+	verification := synthesis.Verify(spec, policies)
+	if len(verification.Mismatches) > 0 {
+		utils.DoOrDie(errors.Errorf("synthesized policies don't realize the spec -- are some workloads' labels a subset of others'?\n%s", verification.Table()))
+	}
+	logrus.Infof("verified synthesized policies against the spec: %d workload pairs and ports match", verification.Checked)
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/policytest"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type TestPoliciesArgs struct {
+	Explain bool
+}
+
+func SetupTestPoliciesCommand() *cobra.Command {
+	args := &TestPoliciesArgs{}
+
+	command := &cobra.Command{
+		Use:   "test-policies PATH...",
+		Short: "run table-driven tests of policy semantics against the matcher, without a cluster",
+		Long:  "run test files -- each with namespaces, network policies, admin network policies, and cases of traffic with their expected verdicts -- against cyclonus's policy matcher, and exit non-zero if any verdict is wrong.  Paths may be files, or directories which are walked for .yaml, .yml and .json files",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, paths []string) {
+			RunTestPoliciesCommand(args, paths)
+		},
+	}
+
+	command.Flags().BoolVar(&args.Explain, "explain", true, "if true, print the traffic and the policy rules deciding it for each failed case")
+
+	return command
+}
+
+func RunTestPoliciesCommand(args *TestPoliciesArgs, paths []string) {
+	testFiles, err := policytest.ReadTestFiles(paths)
+	utils.DoOrDie(err)
+
+	report := policytest.Run(testFiles)
+	fmt.Printf("%s\n", report.Table())
+
+	failures := report.Failures()
+	if args.Explain {
+		for _, failure := range failures {
+			fmt.Printf("%s: %s\n%s\n%s\n", failure.TestFile.Path, failure.Case.Name, failure.Traffic.Table(), failure.Allowed.Table())
+		}
+	}
+
+	if len(failures) > 0 {
+		logrus.Errorf("%d of %d cases failed", len(failures), len(report.Results))
+		logrus.Exit(1)
+	}
+	fmt.Printf("all %d cases in %d files passed\n", len(report.Results), len(testFiles))
+}
@@ -2,17 +2,32 @@ package cli
 
 import (
 	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/kube/antrea"
+	"github.com/mattfenwick/cyclonus/pkg/kube/calico"
+	"github.com/mattfenwick/cyclonus/pkg/kube/cilium"
+	"github.com/mattfenwick/cyclonus/pkg/linter"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	networkingv1 "k8s.io/api/networking/v1"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sigs.k8s.io/yaml"
+	"strings"
 )
 
 func readPoliciesFromPath(policyPath string) ([]*networkingv1.NetworkPolicy, error) {
+	policies, _, err := readPoliciesWithLocationsFromPath(policyPath)
+	return policies, err
+}
+
+// readPoliciesWithLocationsFromPath also returns where each policy was read from: its file, and the line of its name
+func readPoliciesWithLocationsFromPath(policyPath string) ([]*networkingv1.NetworkPolicy, map[*networkingv1.NetworkPolicy]*linter.SourceLocation, error) {
 	var allPolicies []*networkingv1.NetworkPolicy
+	locations := map[*networkingv1.NetworkPolicy]*linter.SourceLocation{}
 	err := filepath.Walk(policyPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return errors.Wrapf(err, "unable to walk path %s", path)
@@ -32,31 +47,189 @@ func readPoliciesFromPath(policyPath string) ([]*networkingv1.NetworkPolicy, err
 		err = yaml.Unmarshal(bytes, &policies)
 		if err == nil {
 			log.Debugf("parsed %d policies from %s", len(policies), path)
-			allPolicies = append(allPolicies, policies...)
-			return nil
+		} else {
+			log.Debugf("failed to parse list from %s, falling back to parsing single policy", path)
+			var policy *networkingv1.NetworkPolicy
+			err = yaml.UnmarshalStrict(bytes, &policy)
+			if err != nil {
+				return errors.Wrapf(err, "unable to unmarshal single policy from yaml at %s", path)
+			}
+			log.Debugf("parsed single policy from %s: %+v", path, policy)
+			policies = []*networkingv1.NetworkPolicy{policy}
 		}
 
-		log.Debugf("failed to parse list from %s, falling back to parsing single policy", path)
-		var policy *networkingv1.NetworkPolicy
-		err = yaml.UnmarshalStrict(bytes, &policy)
-		if err != nil {
-			return errors.Wrapf(err, "unable to unmarshal single policy from yaml at %s", path)
+		lines := strings.Split(string(bytes), "\n")
+		line := 0
+		for _, policy := range policies {
+			line = findNameLine(lines, policy.Name, line)
+			locations[policy] = &linter.SourceLocation{Path: path, Line: line}
 		}
-
-		log.Debugf("parsed single policy from %s: %+v", path, policy)
-		allPolicies = append(allPolicies, policy)
+		allPolicies = append(allPolicies, policies...)
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 		//return nil, errors.Wrapf(err, "unable to walk filesystem from %s", policyPath)
 	}
 	for _, p := range allPolicies {
 		if len(p.Spec.PolicyTypes) == 0 {
-			return nil, errors.Errorf("missing spec.policyTypes from network policy %s/%s", p.Namespace, p.Name)
+			return nil, nil, errors.Errorf("missing spec.policyTypes from network policy %s/%s", p.Namespace, p.Name)
+		}
+	}
+	return allPolicies, locations, nil
+}
+
+// findNameLine finds the 1-based line of a yaml or json name field with the given value, looking first after the
+// previous policy's line, since lists are usually in order; if there isn't one, it's the first line
+func findNameLine(lines []string, name string, previous int) int {
+	nameField := regexp.MustCompile(`^\s*(- )?"?name"?\s*:\s*["']?` + regexp.QuoteMeta(name) + `["']?\s*,?\s*$`)
+	for _, start := range []int{previous, 0} {
+		for i := start; i < len(lines); i++ {
+			if nameField.MatchString(lines[i]) {
+				return i + 1
+			}
+		}
+	}
+	return 1
+}
+
+// readAdminPoliciesFromPath reads AdminNetworkPolicies from a file -- of a single policy or a list -- or from each
+// file under a directory
+func readAdminPoliciesFromPath(policyPath string) ([]*anp.AdminNetworkPolicy, error) {
+	var allPolicies []*anp.AdminNetworkPolicy
+	err := filepath.Walk(policyPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrapf(err, "unable to walk path %s", path)
 		}
+		if info.IsDir() {
+			return nil
+		}
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read file %s", path)
+		}
+
+		var policies []*anp.AdminNetworkPolicy
+		if err := yaml.Unmarshal(bytes, &policies); err != nil {
+			var policy *anp.AdminNetworkPolicy
+			if err := yaml.UnmarshalStrict(bytes, &policy); err != nil {
+				return errors.Wrapf(err, "unable to unmarshal admin network policy from yaml at %s", path)
+			}
+			policies = []*anp.AdminNetworkPolicy{policy}
+		}
+		for _, policy := range policies {
+			if policy.Kind != anp.AdminNetworkPolicyKind {
+				return errors.Errorf("expected kind %s at %s, found '%s'", anp.AdminNetworkPolicyKind, path, policy.Kind)
+			}
+		}
+		allPolicies = append(allPolicies, policies...)
+		return nil
+	})
+	return allPolicies, err
+}
+
+// cniPolicies are policies of CNIs' own APIs, which are simulated along with NetworkPolicies
+type cniPolicies struct {
+	cilium []*matcher.CiliumPolicy
+	calico []*matcher.CalicoTier
+	antrea []*matcher.AntreaPolicy
+}
+
+func (c *cniPolicies) addTo(policies *matcher.Policy) {
+	policies.AddCiliumPolicies(c.cilium)
+	policies.AddCalicoTiers(c.calico)
+	policies.AddAntreaPolicies(c.antrea)
+}
+
+// buildCNIPolicies models the CNI policies of snapshots -- read from a cluster dump, kube, or policy paths
+func buildCNIPolicies(snapshots []*kube.Snapshot) (*cniPolicies, error) {
+	var ciliumPolicies []*cilium.CiliumNetworkPolicy
+	var ciliumClusterwidePolicies []*cilium.CiliumClusterwideNetworkPolicy
+	var calicoPolicies []*calico.NetworkPolicy
+	var calicoGlobalPolicies []*calico.GlobalNetworkPolicy
+	var calicoTiers []*calico.Tier
+	var antreaClusterPolicies []*antrea.ClusterNetworkPolicy
+	var antreaPolicies []*antrea.NetworkPolicy
+	var antreaTiers []*antrea.Tier
+	for _, snapshot := range snapshots {
+		for i := range snapshot.CiliumNetworkPolicies {
+			ciliumPolicies = append(ciliumPolicies, &snapshot.CiliumNetworkPolicies[i])
+		}
+		for i := range snapshot.CiliumClusterwideNetworkPolicies {
+			ciliumClusterwidePolicies = append(ciliumClusterwidePolicies, &snapshot.CiliumClusterwideNetworkPolicies[i])
+		}
+		for i := range snapshot.CalicoNetworkPolicies {
+			calicoPolicies = append(calicoPolicies, &snapshot.CalicoNetworkPolicies[i])
+		}
+		for i := range snapshot.CalicoGlobalNetworkPolicies {
+			calicoGlobalPolicies = append(calicoGlobalPolicies, &snapshot.CalicoGlobalNetworkPolicies[i])
+		}
+		for i := range snapshot.CalicoTiers {
+			calicoTiers = append(calicoTiers, &snapshot.CalicoTiers[i])
+		}
+		for i := range snapshot.AntreaClusterNetworkPolicies {
+			antreaClusterPolicies = append(antreaClusterPolicies, &snapshot.AntreaClusterNetworkPolicies[i])
+		}
+		for i := range snapshot.AntreaNetworkPolicies {
+			antreaPolicies = append(antreaPolicies, &snapshot.AntreaNetworkPolicies[i])
+		}
+		for i := range snapshot.AntreaTiers {
+			antreaTiers = append(antreaTiers, &snapshot.AntreaTiers[i])
+		}
+	}
+
+	policies := &cniPolicies{
+		cilium: matcher.BuildCiliumPolicies(ciliumPolicies, ciliumClusterwidePolicies),
+		antrea: matcher.BuildAntreaPolicies(antreaClusterPolicies, antreaPolicies, antreaTiers),
+	}
+	// without Calico policies, NetworkPolicies are evaluated on their own, rather than in Calico's default tier
+	if len(calicoPolicies) > 0 || len(calicoGlobalPolicies) > 0 {
+		var err error
+		policies.calico, err = matcher.BuildCalicoTiers(calicoPolicies, calicoGlobalPolicies, calicoTiers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return policies, nil
+}
+
+// readCNIPoliciesFromKube reads the namespaced CNI policies in namespaces, and all cluster-scoped ones
+func readCNIPoliciesFromKube(kubeClient *kube.Kubernetes, namespaces []string) (*kube.Snapshot, error) {
+	snapshot := &kube.Snapshot{}
+	for _, ns := range namespaces {
+		ciliumPolicies, err := kubeClient.GetCiliumNetworkPolicies(ns)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.CiliumNetworkPolicies = append(snapshot.CiliumNetworkPolicies, ciliumPolicies...)
+		calicoPolicies, err := kubeClient.GetCalicoNetworkPolicies(ns)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.CalicoNetworkPolicies = append(snapshot.CalicoNetworkPolicies, calicoPolicies...)
+		antreaPolicies, err := kubeClient.GetAntreaNetworkPolicies(ns)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.AntreaNetworkPolicies = append(snapshot.AntreaNetworkPolicies, antreaPolicies...)
+	}
+	var err error
+	if snapshot.CiliumClusterwideNetworkPolicies, err = kubeClient.GetCiliumClusterwideNetworkPolicies(); err != nil {
+		return nil, err
+	}
+	if snapshot.CalicoGlobalNetworkPolicies, err = kubeClient.GetCalicoGlobalNetworkPolicies(); err != nil {
+		return nil, err
+	}
+	if snapshot.CalicoTiers, err = kubeClient.GetCalicoTiers(); err != nil {
+		return nil, err
+	}
+	if snapshot.AntreaClusterNetworkPolicies, err = kubeClient.GetAntreaClusterNetworkPolicies(); err != nil {
+		return nil, err
+	}
+	if snapshot.AntreaTiers, err = kubeClient.GetAntreaTiers(); err != nil {
+		return nil, err
 	}
-	return allPolicies, nil
+	return snapshot, nil
 }
 
 func readPoliciesFromKube(kubeClient *kube.Kubernetes, namespaces []string) ([]*networkingv1.NetworkPolicy, error) {
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/assertions"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+type VerifyArgs struct {
+	AssertionsPath          string
+	Context                 string
+	Namespaces              []string
+	AllNamespaces           bool
+	PodSelector             string
+	ClientCommandsPath      string
+	Workers                 int
+	ProberTimeoutSeconds    int
+	ExecRetries             int
+	ExecRetryBackoffSeconds int
+}
+
+func SetupVerifyCommand() *cobra.Command {
+	args := &VerifyArgs{}
+
+	command := &cobra.Command{
+		Use:   "verify",
+		Short: "probe existing workloads to verify required-allow and required-deny assertions",
+		Long:  "verify assertions about which traffic must be allowed or denied by probing between the cluster's existing pods -- no fixture pods or policies are created -- and exit non-zero if any assertion is violated.  Probes are sent by pod IP from an ephemeral agnhost container, which is injected into each pod that an assertion sends traffic from; this requires ephemeral containers to be enabled in the cluster",
+		Args:  cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, as []string) {
+			ctx, cancel := runContext(cmd)
+			defer cancel()
+			RunVerifyCommand(ctx, args)
+		},
+	}
+
+	command.Flags().StringVar(&args.AssertionsPath, "assertions", "", "path to a yaml file of assertions, in the same format as for 'gate'")
+	utils.DoOrDie(command.MarkFlagRequired("assertions"))
+	command.Flags().StringVar(&args.Context, "context", "", "kube context to use; if empty, uses default context")
+	command.Flags().StringSliceVarP(&args.Namespaces, "namespace", "n", []string{}, "namespaces to read pods from; if empty, the namespaces named by the assertions are used")
+	command.Flags().BoolVarP(&args.AllNamespaces, "all-namespaces", "A", false, "reads pods from all namespaces")
+	command.Flags().StringVar(&args.PodSelector, "pod-selector", "", "label selector to narrow down the pods to probe between; if empty, all running pods are used")
+	command.Flags().StringVar(&args.ClientCommandsPath, "client-commands", "", "path to a yaml file mapping protocols to probe command templates, to use instead of agnhost")
+	command.Flags().IntVar(&args.Workers, "workers", 15, "number of probes to run concurrently")
+	command.Flags().IntVar(&args.ProberTimeoutSeconds, "prober-timeout-seconds", 60, "number of seconds to wait for injected prober containers to be running")
+	command.Flags().IntVar(&args.ExecRetries, "exec-retries", 2, "number of retries for individual probe jobs which fail to execute (as opposed to being blocked)")
+	command.Flags().IntVar(&args.ExecRetryBackoffSeconds, "exec-retry-backoff-seconds", 1, "number of seconds to wait before the first retry of failed probe jobs; doubles with each further retry")
+
+	return command
+}
+
+func RunVerifyCommand(ctx context.Context, args *VerifyArgs) {
+	verifyAssertions, err := assertions.ReadAssertions(args.AssertionsPath)
+	utils.DoOrDie(err)
+
+	kubernetes, err := kube.NewKubernetesForContext(args.Context)
+	utils.DoOrDie(err)
+	kubernetes.Context = ctx
+
+	namespaces, err := verifyNamespaces(args, kubernetes, verifyAssertions)
+	utils.DoOrDie(err)
+
+	resources, err := probe.ReadExistingPods(kubernetes, namespaces, args.PodSelector)
+	utils.DoOrDie(err)
+	sourcePods, err := verifyAssertions.SourcePods(resources)
+	utils.DoOrDie(err)
+	logrus.Infof("verifying %d assertions with probes from %d of %d pods", len(verifyAssertions.Assertions), len(sourcePods), len(resources.Pods))
+	utils.DoOrDie(probe.EnsureProberContainers(kubernetes, sourcePods, args.ProberTimeoutSeconds))
+
+	var clientCommands *probe.ClientCommands
+	if args.ClientCommandsPath != "" {
+		clientCommands, err = probe.ReadClientCommands(args.ClientCommandsPath)
+		utils.DoOrDie(err)
+	}
+	runner := probe.NewKubeRunner(kubernetes, args.Workers, clientCommands)
+	runner.Context = ctx
+	runner.CheckFailedRetryPolicy = kube.RetryPolicy{
+		Retries: args.ExecRetries,
+		Backoff: time.Duration(args.ExecRetryBackoffSeconds) * time.Second,
+	}
+
+	report := assertions.Check(verifyAssertions, resources, runner)
+	fmt.Printf("%s\n", report.Table())
+
+	if !report.Passed() {
+		logrus.Errorf("%d of %d assertions failed", report.FailedCount(), len(report.Results))
+		logrus.Exit(1)
+	}
+	fmt.Printf("all %d assertions passed\n", len(report.Results))
+}
+
+func verifyNamespaces(args *VerifyArgs, kubernetes kube.IKubernetes, verifyAssertions *assertions.Assertions) ([]string, error) {
+	if args.AllNamespaces {
+		nsList, err := kubernetes.GetAllNamespaces()
+		if err != nil {
+			return nil, err
+		}
+		var namespaces []string
+		for _, ns := range nsList.Items {
+			namespaces = append(namespaces, ns.Name)
+		}
+		return namespaces, nil
+	}
+	if len(args.Namespaces) > 0 {
+		return args.Namespaces, nil
+	}
+	namespaces, ok := verifyAssertions.Namespaces()
+	if !ok {
+		return nil, errors.Errorf("some assertions don't name a namespace for both peers; use --namespace or --all-namespaces")
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,34 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"regexp"
+)
+
+const canonicalIPPlaceholder = "<ip>"
+
+var ipv4Pattern = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`)
+
+// CanonicalizeIPs replaces IPv4 addresses -- which change from run to run, as pods are recreated -- with the names
+// of the pods they belong to, i.e. 'ip(x/a)' and 'service-ip(x/a)'.  Any other address is replaced with a
+// placeholder, so CIDRs derived from pod IPs come out the same, too.
+func CanonicalizeIPs(text string, resources *probe.Resources) string {
+	names := map[string]string{}
+	if resources != nil {
+		for _, pod := range resources.Pods {
+			podString := pod.PodString().String()
+			if pod.ServiceIP != "" {
+				names[pod.ServiceIP] = "service-ip(" + podString + ")"
+			}
+			if pod.IP != "" {
+				names[pod.IP] = "ip(" + podString + ")"
+			}
+		}
+	}
+	return ipv4Pattern.ReplaceAllStringFunc(text, func(ip string) string {
+		if name, ok := names[ip]; ok {
+			return name
+		}
+		return canonicalIPPlaceholder
+	})
+}
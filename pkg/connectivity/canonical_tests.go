@@ -0,0 +1,23 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunCanonicalTests() {
+	Describe("Canonical output", func() {
+		It("should replace IPs with pod names or a placeholder", func() {
+			resources := &probe.Resources{
+				Pods: []*probe.Pod{
+					{Namespace: "x", Name: "a", IP: "10.244.1.5", ServiceIP: "10.96.0.12"},
+					{Namespace: "z", Name: "c", IP: "10.244.2.7"},
+				},
+			}
+			text := "cidr: 10.244.2.7/32, other: 10.244.2.0/24, service 10.96.0.12, pod 10.244.1.5, port 80"
+			Expect(CanonicalizeIPs(text, resources)).To(Equal("cidr: ip(z/c)/32, other: <ip>/24, service service-ip(x/a), pod ip(x/a), port 80"))
+			Expect(CanonicalizeIPs("1.2.3.4", nil)).To(Equal("<ip>"))
+		})
+	})
+}
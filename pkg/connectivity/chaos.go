@@ -0,0 +1,104 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"time"
+)
+
+// CNIRestarter restarts a CNI daemonset by deleting its pods -- on all nodes, or only on Nodes -- so that test cases
+// can check that policies are still enforced while the CNI recovers, and after
+type CNIRestarter struct {
+	Kubernetes kube.IKubernetes
+	Namespace  string
+	DaemonSet  string
+	// Nodes restricts restarts to the daemonset's pods on these nodes; if empty, all its pods are restarted
+	Nodes           []string
+	RecoveryTimeout time.Duration
+	// restartedPods are the pods deleted by the last restart, which must be gone for the daemonset to have recovered
+	restartedPods []string
+}
+
+func (c *CNIRestarter) isSelectedNode(node string) bool {
+	if len(c.Nodes) == 0 {
+		return true
+	}
+	for _, selected := range c.Nodes {
+		if selected == node {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CNIRestarter) getDaemonSetPods() ([]v1.Pod, error) {
+	return getDaemonSetPods(c.Kubernetes, c.Namespace, c.DaemonSet)
+}
+
+// Restart deletes the daemonset's pods on the selected nodes, and doesn't wait for them to be replaced
+func (c *CNIRestarter) Restart() error {
+	pods, err := c.getDaemonSetPods()
+	if err != nil {
+		return err
+	}
+	c.restartedPods = nil
+	for _, pod := range pods {
+		if !c.isSelectedNode(pod.Spec.NodeName) {
+			continue
+		}
+		logrus.Infof("restarting CNI: deleting pod %s/%s on node %s", pod.Namespace, pod.Name, pod.Spec.NodeName)
+		if err := c.Kubernetes.DeletePod(pod.Namespace, pod.Name); err != nil {
+			return err
+		}
+		c.restartedPods = append(c.restartedPods, pod.Name)
+	}
+	if len(c.restartedPods) == 0 {
+		return errors.Errorf("unable to restart CNI: no pods of daemonset %s/%s found on nodes %+v", c.Namespace, c.DaemonSet, c.Nodes)
+	}
+	return nil
+}
+
+// WaitForRecovery waits for the pods deleted by the last restart to be gone, and for the daemonset to be fully ready
+func (c *CNIRestarter) WaitForRecovery() error {
+	sleep := 5 * time.Second
+	for waited := time.Duration(0); waited < c.RecoveryTimeout; waited += sleep {
+		recovered, err := c.isRecovered()
+		if err != nil {
+			return err
+		}
+		if recovered {
+			logrus.Infof("CNI daemonset %s/%s recovered", c.Namespace, c.DaemonSet)
+			return nil
+		}
+		logrus.Infof("waiting for CNI daemonset %s/%s to recover", c.Namespace, c.DaemonSet)
+		time.Sleep(sleep)
+	}
+	return errors.Errorf("CNI daemonset %s/%s did not recover within %s", c.Namespace, c.DaemonSet, c.RecoveryTimeout)
+}
+
+func (c *CNIRestarter) isRecovered() (bool, error) {
+	pods, err := c.getDaemonSetPods()
+	if err != nil {
+		return false, err
+	}
+	restarted := map[string]bool{}
+	for _, name := range c.restartedPods {
+		restarted[name] = true
+	}
+	for _, pod := range pods {
+		if restarted[pod.Name] {
+			return false, nil
+		}
+	}
+
+	ds, err := c.Kubernetes.GetDaemonSet(c.Namespace, c.DaemonSet)
+	if err != nil {
+		return false, err
+	}
+	status := ds.Status
+	return status.ObservedGeneration >= ds.Generation &&
+		status.NumberReady == status.DesiredNumberScheduled &&
+		status.NumberUnavailable == 0, nil
+}
@@ -0,0 +1,56 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+func RunChaosTests() {
+	Describe("CNIRestarter", func() {
+		cniLabels := map[string]string{"k8s-app": "cni"}
+
+		setup := func() *kube.MockKubernetes {
+			mock := kube.NewMockKubernetes(1.0)
+			_, err := mock.CreateNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}})
+			utils.DoOrDie(err)
+			mock.Namespaces["kube-system"].DaemonSets["cni"] = &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "cni", Namespace: "kube-system"},
+				Spec:       appsv1.DaemonSetSpec{Selector: &metav1.LabelSelector{MatchLabels: cniLabels}},
+				Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 2, NumberReady: 2},
+			}
+			for _, pod := range []*v1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: "cni-1", Namespace: "kube-system", Labels: cniLabels}, Spec: v1.PodSpec{NodeName: "node-1"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "cni-2", Namespace: "kube-system", Labels: cniLabels}, Spec: v1.PodSpec{NodeName: "node-2"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "kube-system"}, Spec: v1.PodSpec{NodeName: "node-1"}},
+			} {
+				_, err = mock.CreatePod(pod)
+				utils.DoOrDie(err)
+			}
+			return mock
+		}
+
+		It("should only restart the daemonset's pods on the selected nodes", func() {
+			mock := setup()
+			restarter := &CNIRestarter{Kubernetes: mock, Namespace: "kube-system", DaemonSet: "cni", Nodes: []string{"node-1"}, RecoveryTimeout: time.Minute}
+
+			Expect(restarter.Restart()).To(Succeed())
+			pods := mock.Namespaces["kube-system"].Pods
+			Expect(pods).ToNot(HaveKey("cni-1"))
+			Expect(pods).To(HaveKey("cni-2"))
+			Expect(pods).To(HaveKey("other"))
+
+			Expect(restarter.WaitForRecovery()).To(Succeed())
+		})
+
+		It("should fail if there are no pods to restart", func() {
+			restarter := &CNIRestarter{Kubernetes: setup(), Namespace: "kube-system", DaemonSet: "cni", Nodes: []string{"node-3"}}
+			Expect(restarter.Restart()).ToNot(Succeed())
+		})
+	})
+}
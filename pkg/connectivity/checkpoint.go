@@ -0,0 +1,103 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/pkg/errors"
+	"os"
+)
+
+// Checkpoint tracks which of a run's test cases have finished, and how.  If it has a path, it's saved there as a
+// results document -- numbered by test case, rather than by the order test cases finished in -- every time a test case
+// finishes, so that an interrupted run can be resumed without running the finished test cases again.
+type Checkpoint struct {
+	Path           string
+	TestCases      []*generator.TestCase
+	IgnoreLoopback bool
+	// Records are the records of finished test cases, by index in TestCases; nil for those which haven't finished
+	Records []*TestCaseRecord
+}
+
+func NewCheckpoint(path string, testCases []*generator.TestCase, ignoreLoopback bool) *Checkpoint {
+	return &Checkpoint{
+		Path:           path,
+		TestCases:      testCases,
+		IgnoreLoopback: ignoreLoopback,
+		Records:        make([]*TestCaseRecord, len(testCases)),
+	}
+}
+
+// Resume picks up the test cases finished by a previous attempt at the run from the checkpoint's file, returning how
+// many there were; if there's no file yet, there's nothing to resume.  Test cases which were interrupted are run
+// again.  Test cases are matched by number and description, so the previous attempt must have used the same test
+// case selection and order.
+func (c *Checkpoint) Resume() (int, error) {
+	if _, err := os.Stat(c.Path); os.IsNotExist(err) {
+		return 0, nil
+	}
+	doc, err := ReadResultsDocument(c.Path)
+	if err != nil {
+		return 0, err
+	}
+	resumed := 0
+	for _, record := range doc.Tests {
+		index := record.Number - 1
+		if index < 0 || index >= len(c.TestCases) || c.TestCases[index].Description != record.Description {
+			return 0, errors.Errorf("checkpoint %s doesn't match this run's test cases at test case #%d (%s); resume with the same test case selection as the interrupted run", c.Path, record.Number, record.Description)
+		}
+		if record.Interrupted {
+			continue
+		}
+		c.Records[index] = record
+		resumed++
+	}
+	return resumed, nil
+}
+
+// Remaining returns the test cases which haven't finished, along with their indexes in TestCases
+func (c *Checkpoint) Remaining() ([]int, []*generator.TestCase) {
+	var indexes []int
+	var testCases []*generator.TestCase
+	for i, testCase := range c.TestCases {
+		if c.Records[i] == nil {
+			indexes = append(indexes, i)
+			testCases = append(testCases, testCase)
+		}
+	}
+	return indexes, testCases
+}
+
+// Record records the result of the test case at index, and saves the checkpoint if it has a path
+func (c *Checkpoint) Record(index int, result *Result) error {
+	c.Records[index] = newTestCaseRecord(index+1, result, c.IgnoreLoopback)
+	if c.Path == "" {
+		return nil
+	}
+	// write to a temporary file first, so that being killed mid-write doesn't clobber the previous checkpoint
+	temporaryPath := c.Path + ".tmp"
+	if err := c.Document().WriteToFile(temporaryPath); err != nil {
+		return err
+	}
+	return errors.Wrapf(os.Rename(temporaryPath, c.Path), "unable to move checkpoint to %s", c.Path)
+}
+
+// Document is a results document of the finished test cases, in test case order.  It's partial if any test case
+// hasn't finished, or was interrupted.
+func (c *Checkpoint) Document() *ResultsDocument {
+	doc := &ResultsDocument{SchemaVersion: ResultsDocumentSchemaVersion}
+	for _, record := range c.Records {
+		if record == nil {
+			doc.Partial = true
+			continue
+		}
+		if record.Interrupted {
+			doc.Partial = true
+		}
+		if record.Passed {
+			doc.Passed++
+		} else {
+			doc.Failed++
+		}
+		doc.Tests = append(doc.Tests, record)
+	}
+	return doc
+}
@@ -0,0 +1,75 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func RunCheckpointTests() {
+	Describe("Checkpoint", func() {
+		testCase := func(description string) *generator.TestCase {
+			return generator.NewTestCase(description, generator.NewStringSet(generator.TagIngress))
+		}
+
+		It("should resume from the test cases a previous attempt finished", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-checkpoint")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "checkpoint.json")
+			testCases := []*generator.TestCase{testCase("a"), testCase("b"), testCase("c"), testCase("d")}
+
+			first := NewCheckpoint(path, testCases, false)
+			resumed, err := first.Resume()
+			Expect(err).To(Succeed())
+			Expect(resumed).To(Equal(0))
+			Expect(first.Record(0, &Result{TestCase: testCases[0]})).To(Succeed())
+			Expect(first.Record(2, &Result{TestCase: testCases[2], Err: errors.Errorf("oops")})).To(Succeed())
+			Expect(first.Record(1, &Result{TestCase: testCases[1], Interrupted: true})).To(Succeed())
+
+			second := NewCheckpoint(path, testCases, false)
+			resumed, err = second.Resume()
+			Expect(err).To(Succeed())
+			Expect(resumed).To(Equal(2))
+			indexes, remaining := second.Remaining()
+			Expect(indexes).To(Equal([]int{1, 3}))
+			Expect(remaining).To(Equal([]*generator.TestCase{testCases[1], testCases[3]}))
+			Expect(second.Document().Partial).To(BeTrue())
+
+			Expect(second.Record(3, &Result{TestCase: testCases[3]})).To(Succeed())
+			Expect(second.Record(1, &Result{TestCase: testCases[1]})).To(Succeed())
+			doc := second.Document()
+			Expect(doc.Partial).To(BeFalse())
+			Expect(doc.Passed).To(Equal(3))
+			Expect(doc.Failed).To(Equal(1))
+			var numbers []int
+			var descriptions []string
+			for _, record := range doc.Tests {
+				numbers = append(numbers, record.Number)
+				descriptions = append(descriptions, record.Description)
+			}
+			Expect(numbers).To(Equal([]int{1, 2, 3, 4}))
+			Expect(descriptions).To(Equal([]string{"a", "b", "c", "d"}))
+			Expect(doc.Tests[2].Error).To(Equal("oops"))
+		})
+
+		It("should refuse to resume a run with different test cases", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-checkpoint")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "checkpoint.json")
+
+			first := NewCheckpoint(path, []*generator.TestCase{testCase("a"), testCase("b")}, false)
+			Expect(first.Record(1, &Result{TestCase: first.TestCases[1]})).To(Succeed())
+
+			_, err = NewCheckpoint(path, []*generator.TestCase{testCase("b"), testCase("a")}, false).Resume()
+			Expect(err).To(HaveOccurred())
+			_, err = NewCheckpoint(path, []*generator.TestCase{testCase("a")}, false).Resume()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+}
@@ -1,9 +1,13 @@
 package connectivity
 
 import (
+	"fmt"
 	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/olekukonko/tablewriter"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	"sort"
+	"strings"
 )
 
 type Item struct {
@@ -122,6 +126,80 @@ func (c *ComparisonTable) ValueCounts(ignoreLoopback bool) map[Comparison]int {
 	return counts
 }
 
+// ValueCountsByZonePair is ValueCounts, broken down by whether each pair's pods are in the same zone; zones maps
+// pods to zones, as from Resources.Zones
+func (c *ComparisonTable) ValueCountsByZonePair(ignoreLoopback bool, zones map[string]string) map[probe.ZonePair]map[Comparison]int {
+	counts := map[probe.ZonePair]map[Comparison]int{}
+	for _, zonePair := range probe.AllZonePairs {
+		counts[zonePair] = map[Comparison]int{}
+	}
+	for _, key := range c.Wrapped.Keys() {
+		zonePair := probe.ClassifyZonePair(zones, key.From, key.To)
+		if ignoreLoopback && key.From == key.To {
+			counts[zonePair][IgnoredComparison] += 1
+		} else if c.Get(key.From, key.To).IsSuccess() {
+			counts[zonePair][SameComparison] += 1
+		} else {
+			counts[zonePair][DifferentComparison] += 1
+		}
+	}
+	return counts
+}
+
+// MismatchedFromsAndTos returns the froms and tos -- in table order -- which are involved in at least one mismatch
+func (c *ComparisonTable) MismatchedFromsAndTos(ignoreLoopback bool) ([]string, []string) {
+	fromSet, toSet := map[string]bool{}, map[string]bool{}
+	for _, key := range c.Wrapped.Keys() {
+		if ignoreLoopback && key.From == key.To {
+			continue
+		}
+		if !c.Get(key.From, key.To).IsSuccess() {
+			fromSet[key.From] = true
+			toSet[key.To] = true
+		}
+	}
+	var froms, tos []string
+	for _, from := range c.Wrapped.Froms {
+		if fromSet[from] {
+			froms = append(froms, from)
+		}
+	}
+	for _, to := range c.Wrapped.Tos {
+		if toSet[to] {
+			tos = append(tos, to)
+		}
+	}
+	return froms, tos
+}
+
+// MismatchedJobResults returns the kube results of the jobs whose results don't match, in table order, then job order
+func (c *ComparisonTable) MismatchedJobResults(ignoreLoopback bool) []*probe.JobResult {
+	var mismatched []*probe.JobResult
+	for _, key := range c.Wrapped.Keys() {
+		if ignoreLoopback && key.From == key.To {
+			continue
+		}
+		item := c.Get(key.From, key.To)
+		var jobKeys []string
+		for jobKey := range item.Kube.JobResults {
+			jobKeys = append(jobKeys, jobKey)
+		}
+		sort.Strings(jobKeys)
+		for _, jobKey := range jobKeys {
+			kubeResult := item.Kube.JobResults[jobKey]
+			if simulated, ok := item.Simulated.JobResults[jobKey]; !ok || simulated.Combined != kubeResult.Combined {
+				mismatched = append(mismatched, kubeResult)
+			}
+		}
+	}
+	return mismatched
+}
+
+// Restrict returns a table with only the given froms and tos
+func (c *ComparisonTable) Restrict(froms []string, tos []string) *ComparisonTable {
+	return &ComparisonTable{Wrapped: c.Wrapped.Restrict(froms, tos)}
+}
+
 func (c *ComparisonTable) RenderSuccessTable() string {
 	return c.Wrapped.Table("", false, func(fr, to string, i interface{}) string {
 		item := c.Get(fr, to)
@@ -133,6 +211,61 @@ func (c *ComparisonTable) RenderSuccessTable() string {
 	})
 }
 
+// CombinedTableLegend explains the symbols used by RenderCombinedTable
+const CombinedTableLegend = "✓: allowed, ✗: blocked, *: differs from expected"
+
+// RenderCombinedTable renders a single matrix whose cells summarize the kube results for every port and protocol,
+// i.e. "TCP80 ✓ / TCP81 ✗* / UDP80 ✓", so that they don't need to be cross-referenced between separate tables
+func (c *ComparisonTable) RenderCombinedTable() string {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetRowLine(true)
+	table.SetHeader(append([]string{""}, c.Wrapped.Tos...))
+	for _, from := range c.Wrapped.Froms {
+		line := []string{from}
+		for _, to := range c.Wrapped.Tos {
+			line = append(line, c.Get(from, to).combinedString())
+		}
+		table.Append(line)
+	}
+	table.Render()
+	return str.String()
+}
+
+func (i *Item) combinedString() string {
+	var kubeResults []*probe.JobResult
+	for _, jobResult := range i.Kube.JobResults {
+		kubeResults = append(kubeResults, jobResult)
+	}
+	sort.Slice(kubeResults, func(a, b int) bool {
+		if kubeResults[a].Job.Protocol != kubeResults[b].Job.Protocol {
+			return kubeResults[a].Job.Protocol < kubeResults[b].Job.Protocol
+		}
+		return kubeResults[a].Job.ResolvedPort < kubeResults[b].Job.ResolvedPort
+	})
+	var pieces []string
+	for _, kubeResult := range kubeResults {
+		piece := fmt.Sprintf("%s%d %s", kubeResult.Job.Protocol, kubeResult.Job.ResolvedPort, connectivitySymbol(kubeResult.Combined))
+		if simulated, ok := i.Simulated.JobResults[kubeResult.Key()]; !ok || simulated.Combined != kubeResult.Combined {
+			piece += "*"
+		}
+		pieces = append(pieces, piece)
+	}
+	return strings.Join(pieces, " / ")
+}
+
+func connectivitySymbol(connectivity probe.Connectivity) string {
+	switch connectivity {
+	case probe.ConnectivityAllowed:
+		return "✓"
+	case probe.ConnectivityBlocked:
+		return "✗"
+	default:
+		return connectivity.ShortString()
+	}
+}
+
 type Comparison string
 
 const (
@@ -0,0 +1,65 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunComparisonTableTests() {
+	Describe("ComparisonTable", func() {
+		It("should restrict to the sources and destinations with mismatches", func() {
+			items := []string{"x/a", "x/b", "y/a", "y/b"}
+			kubeProbe, simulatedProbe := probe.NewTable(items), probe.NewTable(items)
+			for _, fr := range items {
+				for _, to := range items {
+					kubeConnectivity, simulatedConnectivity := probe.ConnectivityAllowed, probe.ConnectivityAllowed
+					if (fr == "x/b" && to == "y/a") || fr == to {
+						kubeConnectivity = probe.ConnectivityBlocked
+					}
+					job := &probe.Job{FromKey: fr, ToKey: to, Protocol: v1.ProtocolTCP, ResolvedPort: 80}
+					Expect(kubeProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: kubeConnectivity})).To(Succeed())
+					Expect(simulatedProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: simulatedConnectivity})).To(Succeed())
+				}
+			}
+			comparison := NewComparisonTableFrom(kubeProbe, simulatedProbe)
+
+			froms, tos := comparison.MismatchedFromsAndTos(true)
+			Expect(froms).To(Equal([]string{"x/b"}))
+			Expect(tos).To(Equal([]string{"y/a"}))
+
+			froms, tos = comparison.MismatchedFromsAndTos(false)
+			Expect(froms).To(Equal(items))
+			Expect(tos).To(Equal(items))
+
+			restricted := comparison.Restrict([]string{"x/b"}, []string{"x/a", "y/a"})
+			Expect(restricted.ValueCounts(false)).To(Equal(map[Comparison]int{SameComparison: 1, DifferentComparison: 1}))
+
+			mismatched := comparison.MismatchedJobResults(true)
+			Expect(mismatched).To(HaveLen(1))
+			Expect(mismatched[0].Job.FromKey).To(Equal("x/b"))
+			Expect(mismatched[0].Job.ToKey).To(Equal("y/a"))
+			Expect(comparison.MismatchedJobResults(false)).To(HaveLen(5))
+		})
+
+		It("should summarize all ports and protocols in a single cell", func() {
+			kube, simulated := &probe.Item{JobResults: map[string]*probe.JobResult{}}, &probe.Item{JobResults: map[string]*probe.JobResult{}}
+			for _, result := range []struct {
+				Protocol  v1.Protocol
+				Port      int
+				Kube      probe.Connectivity
+				Simulated probe.Connectivity
+			}{
+				{Protocol: v1.ProtocolUDP, Port: 80, Kube: probe.ConnectivityAllowed, Simulated: probe.ConnectivityAllowed},
+				{Protocol: v1.ProtocolTCP, Port: 81, Kube: probe.ConnectivityBlocked, Simulated: probe.ConnectivityAllowed},
+				{Protocol: v1.ProtocolTCP, Port: 80, Kube: probe.ConnectivityAllowed, Simulated: probe.ConnectivityAllowed},
+			} {
+				job := &probe.Job{Protocol: result.Protocol, ResolvedPort: result.Port}
+				Expect(kube.AddJobResult(&probe.JobResult{Job: job, Combined: result.Kube})).To(Succeed())
+				Expect(simulated.AddJobResult(&probe.JobResult{Job: job, Combined: result.Simulated})).To(Succeed())
+			}
+			Expect((&Item{Kube: kube, Simulated: simulated}).combinedString()).To(Equal("TCP80 ✓ / TCP81 ✗* / UDP80 ✓"))
+		})
+	})
+}
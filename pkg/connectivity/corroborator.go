@@ -0,0 +1,365 @@
+package connectivity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// DataplaneIsolation is whether a CNI's dataplane is enforcing policies on a pod's ingress and egress
+type DataplaneIsolation struct {
+	Ingress bool
+	Egress  bool
+}
+
+// Corroborator reads a CNI's own view of which pods it's enforcing policies on, so that it can be cross-checked
+// against the policies and against what probes saw
+type Corroborator interface {
+	Name() string
+	// Isolation returns the dataplane's isolation of pods, by pod key (i.e. x/a); pods the CNI doesn't know about
+	// are left out
+	Isolation(resources *probe.Resources) (map[string]*DataplaneIsolation, error)
+}
+
+// CorroborationFinding is a direction of a pod where the dataplane disagrees with the policies, or with the probes
+type CorroborationFinding struct {
+	Pod       string
+	Direction string
+	// DataplaneEnforcing is what the CNI reports; PoliciesIsolate is whether any policy applies to the pod
+	DataplaneEnforcing bool
+	PoliciesIsolate    bool
+	// Blocked counts jobs blocked in this direction of the pod, although the policies allow the other end's
+	// direction -- so that the block must have happened here
+	Blocked int
+	Reasons []string
+}
+
+// Corroborate compares the dataplane's isolation of each pod to the step's policies, and to the last kube probe
+func Corroborate(isolation map[string]*DataplaneIsolation, resources *probe.Resources, stepResult *StepResult, ignoreLoopback bool) []*CorroborationFinding {
+	blockedIngress, blockedEgress := map[string]int{}, map[string]int{}
+	comparison := stepResult.LastComparison()
+	for _, key := range comparison.Wrapped.Keys() {
+		if ignoreLoopback && key.From == key.To {
+			continue
+		}
+		item := comparison.Get(key.From, key.To)
+		for jobKey, kubeResult := range item.Kube.JobResults {
+			simulated, ok := item.Simulated.JobResults[jobKey]
+			if !ok || kubeResult.Combined != probe.ConnectivityBlocked {
+				continue
+			}
+			if simulated.Egress != nil && *simulated.Egress == probe.ConnectivityAllowed {
+				blockedIngress[key.To]++
+			}
+			if simulated.Ingress != nil && *simulated.Ingress == probe.ConnectivityAllowed {
+				blockedEgress[key.From]++
+			}
+		}
+	}
+
+	var findings []*CorroborationFinding
+	for _, pod := range resources.Pods {
+		podKey := pod.PodString().String()
+		dataplane, ok := isolation[podKey]
+		if !ok {
+			continue
+		}
+		for _, direction := range []struct {
+			Name      string
+			IsIngress bool
+			Enforcing bool
+			Blocked   int
+		}{
+			{Name: "ingress", IsIngress: true, Enforcing: dataplane.Ingress, Blocked: blockedIngress[podKey]},
+			{Name: "egress", IsIngress: false, Enforcing: dataplane.Egress, Blocked: blockedEgress[podKey]},
+		} {
+			finding := &CorroborationFinding{
+				Pod:                podKey,
+				Direction:          direction.Name,
+				DataplaneEnforcing: direction.Enforcing,
+				PoliciesIsolate:    len(stepResult.Policy.TargetsApplyingToPod(direction.IsIngress, pod.Namespace, pod.Labels)) > 0,
+				Blocked:            direction.Blocked,
+			}
+			if !finding.DataplaneEnforcing && finding.PoliciesIsolate {
+				finding.Reasons = append(finding.Reasons, "policies isolate the pod, but the dataplane isn't enforcing them")
+			}
+			if !finding.DataplaneEnforcing && finding.Blocked > 0 {
+				finding.Reasons = append(finding.Reasons, "traffic was blocked, but the dataplane isn't enforcing policies")
+			}
+			if finding.DataplaneEnforcing && !finding.PoliciesIsolate {
+				finding.Reasons = append(finding.Reasons, "the dataplane is enforcing policies, but none apply to the pod")
+			}
+			if len(finding.Reasons) > 0 {
+				findings = append(findings, finding)
+			}
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Pod != findings[j].Pod {
+			return findings[i].Pod < findings[j].Pod
+		}
+		return findings[i].Direction < findings[j].Direction
+	})
+	return findings
+}
+
+func CorroborationTable(findings []*CorroborationFinding) string {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetRowLine(true)
+	table.SetHeader([]string{"Pod", "Direction", "Dataplane enforcing", "Policies isolate", "Blocked", "Disagreement"})
+	for _, finding := range findings {
+		table.Append([]string{
+			finding.Pod,
+			finding.Direction,
+			fmt.Sprintf("%t", finding.DataplaneEnforcing),
+			fmt.Sprintf("%t", finding.PoliciesIsolate),
+			intToString(finding.Blocked),
+			strings.Join(finding.Reasons, "\n"),
+		})
+	}
+	table.Render()
+	return str.String()
+}
+
+// CNIAgents are the pods of a CNI's daemonset, which corroborators run commands in to read the dataplane's state
+type CNIAgents struct {
+	Kubernetes kube.IKubernetes
+	Namespace  string
+	DaemonSet  string
+	Container  string
+}
+
+// Exec runs command in every agent, and returns the stdout of each by the agent's node
+func (a *CNIAgents) Exec(command []string) (map[string]string, error) {
+	pods, err := getDaemonSetPods(a.Kubernetes, a.Namespace, a.DaemonSet)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) == 0 {
+		return nil, errors.Errorf("no pods of daemonset %s/%s found", a.Namespace, a.DaemonSet)
+	}
+	outputs := map[string]string{}
+	for _, pod := range pods {
+		out, errOut, commandErr, err := a.Kubernetes.ExecuteRemoteCommand(pod.Namespace, pod.Name, a.Container, command)
+		if err != nil {
+			return nil, err
+		}
+		if commandErr != nil {
+			return nil, errors.Wrapf(commandErr, "command %+v failed in %s/%s: %s", command, pod.Namespace, pod.Name, errOut)
+		}
+		outputs[pod.Spec.NodeName] = out
+	}
+	return outputs, nil
+}
+
+// ciliumEndpointListCommand falls back to the agent's older cli name
+var ciliumEndpointListCommand = []string{"sh", "-c", "cilium-dbg endpoint list -o json 2>/dev/null || cilium endpoint list -o json"}
+
+// CiliumCorroborator reads the policy enforcement of each of Cilium's endpoints
+type CiliumCorroborator struct {
+	Agents *CNIAgents
+}
+
+func (c *CiliumCorroborator) Name() string {
+	return "cilium"
+}
+
+type ciliumEndpoint struct {
+	Status struct {
+		Networking struct {
+			Addressing []struct {
+				IPv4 string `json:"ipv4"`
+				IPv6 string `json:"ipv6"`
+			} `json:"addressing"`
+		} `json:"networking"`
+		Policy struct {
+			Realized struct {
+				PolicyEnabled string `json:"policy-enabled"`
+			} `json:"realized"`
+		} `json:"policy"`
+	} `json:"status"`
+}
+
+// parseCiliumEndpoints maps endpoints' IPs to their isolation.  Audit modes -- i.e. audit-ingress -- don't drop
+// anything, so they don't count as enforcing.
+func parseCiliumEndpoints(out string) (map[string]*DataplaneIsolation, error) {
+	var endpoints []*ciliumEndpoint
+	if err := json.Unmarshal([]byte(out), &endpoints); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal cilium endpoints")
+	}
+	isolation := map[string]*DataplaneIsolation{}
+	for _, endpoint := range endpoints {
+		enabled := endpoint.Status.Policy.Realized.PolicyEnabled
+		endpointIsolation := &DataplaneIsolation{
+			Ingress: enabled == "ingress" || enabled == "both",
+			Egress:  enabled == "egress" || enabled == "both",
+		}
+		for _, address := range endpoint.Status.Networking.Addressing {
+			for _, ip := range []string{address.IPv4, address.IPv6} {
+				if ip != "" {
+					isolation[ip] = endpointIsolation
+				}
+			}
+		}
+	}
+	return isolation, nil
+}
+
+func (c *CiliumCorroborator) Isolation(resources *probe.Resources) (map[string]*DataplaneIsolation, error) {
+	outputs, err := c.Agents.Exec(ciliumEndpointListCommand)
+	if err != nil {
+		return nil, err
+	}
+	byIP := map[string]*DataplaneIsolation{}
+	for node, out := range outputs {
+		nodeIsolation, err := parseCiliumEndpoints(out)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "cilium agent on node %s", node)
+		}
+		for ip, isolation := range nodeIsolation {
+			byIP[ip] = isolation
+		}
+	}
+	isolation := map[string]*DataplaneIsolation{}
+	for _, pod := range resources.Pods {
+		if podIsolation, ok := byIP[pod.IP]; ok {
+			isolation[pod.PodString().String()] = podIsolation
+		}
+	}
+	return isolation, nil
+}
+
+// DataplaneCommand describes how to read a CNI's rules from its agents, for CNIs without a policy API: Command is run
+// in Container of each agent, i.e. `iptables-save` or `nft list ruleset`, and a pod on the agent's node counts as
+// isolated in a direction if the output matches that direction's pattern.  Patterns are go templates rendered with
+// the probe.Pod, and then compiled as regular expressions; `{{.IP | quote}}` escapes the pod's IP.
+type DataplaneCommand struct {
+	Container      string   `json:"container"`
+	Command        []string `json:"command"`
+	IngressPattern string   `json:"ingressPattern"`
+	EgressPattern  string   `json:"egressPattern"`
+}
+
+var dataplaneCommandFuncs = template.FuncMap{
+	"quote": regexp.QuoteMeta,
+}
+
+// ReadDataplaneCommand reads a yaml or json DataplaneCommand, i.e. for kube-router:
+//
+//	container: kube-router
+//	command: ["iptables-save", "-t", "filter"]
+//	ingressPattern: '-d {{.IP | quote}}/32 .*-j KUBE-POD-FW-'
+//	egressPattern: '-s {{.IP | quote}}/32 .*-j KUBE-POD-FW-'
+func ReadDataplaneCommand(path string) (*DataplaneCommand, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read dataplane command from %s", path)
+	}
+	command := &DataplaneCommand{}
+	if err := yaml.UnmarshalStrict(bs, command); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal dataplane command from %s", path)
+	}
+	if len(command.Command) == 0 || command.IngressPattern == "" || command.EgressPattern == "" {
+		return nil, errors.Errorf("dataplane command from %s needs a command, an ingressPattern and an egressPattern", path)
+	}
+	return command, nil
+}
+
+// CommandCorroborator matches the output of a DataplaneCommand for each pod
+type CommandCorroborator struct {
+	Agents  *CNIAgents
+	Command *DataplaneCommand
+}
+
+func NewCommandCorroborator(kubernetes kube.IKubernetes, namespace string, daemonSet string, command *DataplaneCommand) *CommandCorroborator {
+	return &CommandCorroborator{
+		Agents:  &CNIAgents{Kubernetes: kubernetes, Namespace: namespace, DaemonSet: daemonSet, Container: command.Container},
+		Command: command,
+	}
+}
+
+func (c *CommandCorroborator) Name() string {
+	return strings.Join(c.Command.Command, " ")
+}
+
+func (c *CommandCorroborator) Isolation(resources *probe.Resources) (map[string]*DataplaneIsolation, error) {
+	outputs, err := c.Agents.Exec(c.Command.Command)
+	if err != nil {
+		return nil, err
+	}
+	isolation := map[string]*DataplaneIsolation{}
+	for _, pod := range resources.Pods {
+		out, ok := outputs[pod.NodeName]
+		if !ok {
+			continue
+		}
+		podIsolation, err := c.Command.matchPod(pod, out)
+		if err != nil {
+			return nil, err
+		}
+		isolation[pod.PodString().String()] = podIsolation
+	}
+	return isolation, nil
+}
+
+func (d *DataplaneCommand) matchPod(pod *probe.Pod, out string) (*DataplaneIsolation, error) {
+	ingress, err := matchPattern(d.IngressPattern, pod, out)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "ingress pattern")
+	}
+	egress, err := matchPattern(d.EgressPattern, pod, out)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "egress pattern")
+	}
+	return &DataplaneIsolation{Ingress: ingress, Egress: egress}, nil
+}
+
+func matchPattern(pattern string, pod *probe.Pod, out string) (bool, error) {
+	tmpl, err := template.New("pattern").Funcs(dataplaneCommandFuncs).Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to parse pattern '%s'", pattern)
+	}
+	rendered := &bytes.Buffer{}
+	if err := tmpl.Execute(rendered, pod); err != nil {
+		return false, errors.Wrapf(err, "unable to render pattern '%s' for pod %s", pattern, pod.PodString().String())
+	}
+	regex, err := regexp.Compile("(?m)" + rendered.String())
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to compile pattern '%s' for pod %s", rendered.String(), pod.PodString().String())
+	}
+	return regex.MatchString(out), nil
+}
+
+func getDaemonSetPods(kubernetes kube.IKubernetes, namespace string, daemonSet string) ([]v1.Pod, error) {
+	ds, err := kubernetes.GetDaemonSet(namespace, daemonSet)
+	if err != nil {
+		return nil, err
+	}
+	if ds.Spec.Selector == nil {
+		return nil, errors.Errorf("daemonset %s/%s has no selector", namespace, daemonSet)
+	}
+	pods, err := kubernetes.GetPodsInNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	var dsPods []v1.Pod
+	for _, pod := range pods {
+		if kube.IsLabelsMatchLabelSelector(pod.Labels, *ds.Spec.Selector) {
+			dsPods = append(dsPods, pod)
+		}
+	}
+	return dsPods, nil
+}
@@ -0,0 +1,98 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func RunCorroboratorTests() {
+	Describe("Corroborate", func() {
+		It("should find where the dataplane disagrees with the policies and the probes", func() {
+			xa := &probe.Pod{Namespace: "x", Name: "a", Labels: map[string]string{"pod": "a"}, IP: "10.0.0.1"}
+			xb := &probe.Pod{Namespace: "x", Name: "b", Labels: map[string]string{"pod": "b"}, IP: "10.0.0.2"}
+			resources := &probe.Resources{Namespaces: map[string]map[string]string{"x": {"ns": "x"}}, Pods: []*probe.Pod{xa, xb}}
+			policy := matcher.BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "deny-ingress-x-a"},
+				Spec: networkingv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"pod": "a"}},
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				},
+			}})
+
+			items := []string{"x/a", "x/b"}
+			kubeProbe, simulatedProbe := probe.NewTable(items), probe.NewTable(items)
+			allowed, blocked := probe.ConnectivityAllowed, probe.ConnectivityBlocked
+			for _, c := range []struct {
+				From, To        string
+				Ingress, Egress probe.Connectivity
+			}{
+				{From: "x/b", To: "x/a", Ingress: blocked, Egress: allowed},
+				{From: "x/a", To: "x/b", Ingress: allowed, Egress: allowed},
+			} {
+				ingress, egress := c.Ingress, c.Egress
+				job := &probe.Job{FromKey: c.From, ToKey: c.To, Protocol: v1.ProtocolTCP, ResolvedPort: 80}
+				simulated := &probe.JobResult{Job: job, Ingress: &ingress, Egress: &egress, Combined: allowed}
+				if ingress == blocked {
+					simulated.Combined = blocked
+				}
+				Expect(simulatedProbe.Get(c.From, c.To).AddJobResult(simulated)).To(Succeed())
+				// everything's blocked in the cluster
+				Expect(kubeProbe.Get(c.From, c.To).AddJobResult(&probe.JobResult{Job: job, Combined: blocked})).To(Succeed())
+			}
+			stepResult := NewStepResult(simulatedProbe, policy, nil)
+			stepResult.AddKubeProbe(kubeProbe)
+
+			findings := Corroborate(map[string]*DataplaneIsolation{
+				"x/a": {Ingress: true},
+				"x/b": {Egress: true},
+			}, resources, stepResult, true)
+
+			Expect(findings).To(Equal([]*CorroborationFinding{
+				{Pod: "x/a", Direction: "egress", Blocked: 1, Reasons: []string{"traffic was blocked, but the dataplane isn't enforcing policies"}},
+				{Pod: "x/b", Direction: "egress", DataplaneEnforcing: true, Reasons: []string{"the dataplane is enforcing policies, but none apply to the pod"}},
+				{Pod: "x/b", Direction: "ingress", Blocked: 1, Reasons: []string{"traffic was blocked, but the dataplane isn't enforcing policies"}},
+			}))
+		})
+	})
+
+	Describe("Cilium endpoints", func() {
+		It("should read policy enforcement by IP, not counting audit modes", func() {
+			isolation, err := parseCiliumEndpoints(`[
+				{"status": {"networking": {"addressing": [{"ipv4": "10.0.0.1", "ipv6": "fd00::1"}]}, "policy": {"realized": {"policy-enabled": "both"}}}},
+				{"status": {"networking": {"addressing": [{"ipv4": "10.0.0.2"}]}, "policy": {"realized": {"policy-enabled": "ingress"}}}},
+				{"status": {"networking": {"addressing": [{"ipv4": "10.0.0.3"}]}, "policy": {"realized": {"policy-enabled": "audit-both"}}}}
+			]`)
+			Expect(err).To(Succeed())
+			Expect(isolation).To(Equal(map[string]*DataplaneIsolation{
+				"10.0.0.1": {Ingress: true, Egress: true},
+				"fd00::1":  {Ingress: true, Egress: true},
+				"10.0.0.2": {Ingress: true},
+				"10.0.0.3": {},
+			}))
+		})
+	})
+
+	Describe("DataplaneCommand", func() {
+		It("should match patterns rendered with the pod", func() {
+			command := &DataplaneCommand{
+				IngressPattern: `-d {{.IP | quote}}/32 .*-j KUBE-POD-FW-`,
+				EgressPattern:  `-s {{.IP | quote}}/32 .*-j KUBE-POD-FW-`,
+			}
+			out := "-A KUBE-ROUTER-FORWARD -d 10.0.0.1/32 -m comment --comment \"rule to jump traffic destined to POD name:a namespace: x\" -j KUBE-POD-FW-ABC\n" +
+				"-A KUBE-ROUTER-FORWARD -s 10.0.0.12/32 -j KUBE-POD-FW-DEF\n"
+
+			isolation, err := command.matchPod(&probe.Pod{Namespace: "x", Name: "a", IP: "10.0.0.1"}, out)
+			Expect(err).To(Succeed())
+			Expect(isolation).To(Equal(&DataplaneIsolation{Ingress: true}))
+
+			isolation, err = command.matchPod(&probe.Pod{Namespace: "x", Name: "b", IP: "10.0.0.12"}, out)
+			Expect(err).To(Succeed())
+			Expect(isolation).To(Equal(&DataplaneIsolation{Egress: true}))
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/olekukonko/tablewriter"
+	v1 "k8s.io/api/core/v1"
+	"path/filepath"
+	"strings"
+)
+
+const DivergenceReportFileName = "divergences.json"
+
+// DivergenceKind is which way the cluster and pkg/matcher disagreed
+type DivergenceKind string
+
+const (
+	// DivergenceClusterBlocked is traffic the matcher says the policies allow, but the cluster blocked
+	DivergenceClusterBlocked DivergenceKind = "cluster-blocked"
+	// DivergenceClusterAllowed is traffic the matcher says the policies block, but the cluster allowed
+	DivergenceClusterAllowed DivergenceKind = "cluster-allowed"
+)
+
+var AllDivergenceKinds = []DivergenceKind{DivergenceClusterBlocked, DivergenceClusterAllowed}
+
+// Divergence is a probe whose result in the cluster differs from what pkg/matcher says the policies allow.  Either
+// one may be wrong: the matcher's ingress and egress verdicts are kept, to help tell a simulator bug from a CNI bug.
+type Divergence struct {
+	TestCase       string
+	Step           int
+	From           string
+	To             string
+	Job            string
+	Kind           DivergenceKind
+	Matcher        probe.Connectivity
+	MatcherIngress probe.Connectivity `json:",omitempty"`
+	MatcherEgress  probe.Connectivity `json:",omitempty"`
+	Cluster        probe.Connectivity
+}
+
+// DivergenceReport collects the divergences between pkg/matcher and the cluster, over many test cases -- i.e. of a
+// fuzzing run.  Probes which failed to execute aren't divergences, since they say nothing about the policies, so
+// they're only counted.
+type DivergenceReport struct {
+	TestCases          int
+	DivergentTestCases int
+	CheckFailed        int
+	Divergences        []*Divergence
+}
+
+// Add compares the last try of each of a test case's steps to the matcher's simulation -- not to expectations or
+// waivers, which would hide the matcher's own verdict -- returning the test case's divergences
+func (d *DivergenceReport) Add(result *Result, ignoreLoopback bool) []*Divergence {
+	d.TestCases++
+	var divergences []*Divergence
+	for i, step := range result.Steps {
+		comparison := NewComparisonTableFrom(step.LastKubeProbe().Without(step.IgnoredJobs), step.SimulatedProbe.Without(step.IgnoredJobs))
+		for _, kubeResult := range comparison.MismatchedJobResults(ignoreLoopback) {
+			if kubeResult.Combined == probe.ConnectivityCheckFailed {
+				d.CheckFailed++
+				continue
+			}
+			job := kubeResult.Job
+			divergence := &Divergence{
+				TestCase: result.TestCase.Description,
+				Step:     i + 1,
+				From:     job.FromKey,
+				To:       job.ToKey,
+				Job:      kubeResult.Key(),
+				Kind:     DivergenceClusterAllowed,
+				Matcher:  probe.ConnectivityUnknown,
+				Cluster:  kubeResult.Combined,
+			}
+			if kubeResult.Combined == probe.ConnectivityBlocked {
+				divergence.Kind = DivergenceClusterBlocked
+			}
+			if simulated, ok := comparison.Get(job.FromKey, job.ToKey).Simulated.JobResults[kubeResult.Key()]; ok {
+				divergence.Matcher = simulated.Combined
+				if simulated.Ingress != nil {
+					divergence.MatcherIngress = *simulated.Ingress
+				}
+				if simulated.Egress != nil {
+					divergence.MatcherEgress = *simulated.Egress
+				}
+			}
+			divergences = append(divergences, divergence)
+		}
+	}
+	if len(divergences) > 0 {
+		d.DivergentTestCases++
+		d.Divergences = append(d.Divergences, divergences...)
+	}
+	return divergences
+}
+
+// Counts are by kind, then by protocol
+func (d *DivergenceReport) Counts() map[DivergenceKind]map[string]int {
+	counts := map[DivergenceKind]map[string]int{}
+	for _, kind := range AllDivergenceKinds {
+		counts[kind] = map[string]int{}
+	}
+	for _, divergence := range d.Divergences {
+		counts[divergence.Kind][strings.SplitN(divergence.Job, "/", 2)[0]]++
+	}
+	return counts
+}
+
+// Table counts divergences by kind and protocol, followed by how many test cases diverged
+func (d *DivergenceReport) Table() string {
+	str := &strings.Builder{}
+	counts := d.Counts()
+	protocols := []string{string(v1.ProtocolTCP), string(v1.ProtocolUDP), string(v1.ProtocolSCTP)}
+	table := tablewriter.NewWriter(str)
+	table.SetHeader(append([]string{"Divergence"}, protocols...))
+	for _, kind := range AllDivergenceKinds {
+		row := []string{string(kind)}
+		for _, protocol := range protocols {
+			row = append(row, fmt.Sprintf("%d", counts[kind][protocol]))
+		}
+		table.Append(row)
+	}
+	table.Render()
+	str.WriteString(fmt.Sprintf("%d of %d test cases diverged; %d probes failed to execute\n", d.DivergentTestCases, d.TestCases, d.CheckFailed))
+	return str.String()
+}
+
+func (d *DivergenceReport) WriteToDirectory(dir string) (string, error) {
+	path := filepath.Join(dir, DivergenceReportFileName)
+	return path, writeJsonFile(path, d)
+}
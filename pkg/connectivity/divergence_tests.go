@@ -0,0 +1,56 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunDivergenceTests() {
+	Describe("DivergenceReport", func() {
+		It("should collect probes where the cluster and the matcher disagree", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP}, 5, false, nil)
+			Expect(err).To(Succeed())
+			interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true})
+			Expect(err).To(Succeed())
+			report := &DivergenceReport{}
+
+			// the mock allows everything, so only the test case with a policy diverges
+			agreeing := generator.NewSingleStepTestCase("no policies", generator.NewStringSet(), generator.ProbeAllAvailable)
+			Expect(report.Add(interpreter.ExecuteTestCase(agreeing), true)).To(BeEmpty())
+
+			// x/a may only receive TCP/80 from b and c in x and y, and only send TCP/80 to a and b in y and z
+			policy := generator.BuildPolicy().NetworkPolicy()
+			diverging := generator.NewSingleStepTestCase("base policy", generator.NewStringSet(), generator.ProbeAllAvailable, generator.CreatePolicy(policy))
+			divergences := report.Add(interpreter.ExecuteTestCase(diverging), true)
+			Expect(divergences).To(HaveLen(3))
+			Expect(divergences[0]).To(Equal(&Divergence{
+				TestCase:       "base policy",
+				Step:           1,
+				From:           "x/a",
+				To:             "y/a",
+				Job:            "UDP/80",
+				Kind:           DivergenceClusterAllowed,
+				Matcher:        probe.ConnectivityBlocked,
+				MatcherIngress: probe.ConnectivityAllowed,
+				MatcherEgress:  probe.ConnectivityBlocked,
+				Cluster:        probe.ConnectivityAllowed,
+			}))
+			Expect(divergences[1].From).To(Equal("y/a"))
+			Expect(divergences[1].Job).To(Equal("TCP/80"))
+			Expect(divergences[1].MatcherIngress).To(Equal(probe.ConnectivityBlocked))
+
+			Expect(report.TestCases).To(Equal(2))
+			Expect(report.DivergentTestCases).To(Equal(1))
+			Expect(report.Counts()).To(Equal(map[DivergenceKind]map[string]int{
+				DivergenceClusterBlocked: {},
+				DivergenceClusterAllowed: {"TCP": 1, "UDP": 2},
+			}))
+			Expect(report.Table()).To(ContainSubstring("1 of 2 test cases diverged; 0 probes failed to execute"))
+		})
+	})
+}
@@ -0,0 +1,210 @@
+package connectivity
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+const ExportIndexFileName = "index.json"
+
+// ExportIndex lists the test cases written by an export, so that other harnesses can find them
+type ExportIndex struct {
+	TestCases []*ExportedTestCase
+}
+
+// ExportedTestCase is a test case's entry in an ExportIndex.  Its Directory, relative to the export's, holds:
+//   - testcase.yaml: the test case, which can be run again with --test-file
+//   - step-N-policies.yaml: the network policies in effect at step N, one per document
+//   - step-N-expected.json: the expected result of every probe of step N
+//
+// Test cases which couldn't be simulated have an Error, and only testcase.yaml.
+type ExportedTestCase struct {
+	Number      int
+	Description string
+	Tags        []string
+	Directory   string
+	Steps       int
+	Error       string `json:",omitempty"`
+}
+
+// ExpectedProbe is one cell of a step's expected truth table
+type ExpectedProbe struct {
+	From     string
+	To       string
+	Protocol string
+	Port     int
+	PortName string `json:",omitempty"`
+	Expected probe.Connectivity
+	Ingress  probe.Connectivity `json:",omitempty"`
+	Egress   probe.Connectivity `json:",omitempty"`
+}
+
+var exportDirectoryUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// testCaseSlug is as much of a test case's description as fits in a readable directory name
+func testCaseSlug(description string) string {
+	slug := strings.Trim(exportDirectoryUnsafe.ReplaceAllString(strings.ToLower(description), "-"), "-")
+	if len(slug) > 60 {
+		slug = strings.Trim(slug[:60], "-")
+	}
+	return slug
+}
+
+// exportDirectoryName is the test case's number, followed by its slug
+func exportDirectoryName(number int, description string) string {
+	return fmt.Sprintf("%04d-%s", number, testCaseSlug(description))
+}
+
+// exportedYamlTestCase converts a test case back to the yaml test case format.  Primary tags, which are derived,
+// are left out, since yaml test cases can't have them.
+func exportedYamlTestCase(testCase *generator.TestCase) *generator.YamlTestCase {
+	yamlCase := &generator.YamlTestCase{Description: testCase.Description}
+	for _, tag := range testCase.Tags.Keys() {
+		if _, ok := generator.TagSubToPrimary[tag]; ok {
+			yamlCase.Tags = append(yamlCase.Tags, tag)
+		}
+	}
+	sort.Strings(yamlCase.Tags)
+	for _, step := range testCase.Steps {
+		yamlCase.Steps = append(yamlCase.Steps, &generator.YamlTestStep{Probe: step.Probe, Actions: step.Actions, Expected: step.Expected})
+	}
+	return yamlCase
+}
+
+// ExpectedProbes flattens a step's simulated truth table, sorted by source, destination, protocol and port
+func ExpectedProbes(table *probe.Table) []*ExpectedProbe {
+	var probes []*ExpectedProbe
+	for _, key := range table.Wrapped.Keys() {
+		for _, jobResult := range table.Get(key.From, key.To).JobResults {
+			expected := &ExpectedProbe{
+				From:     key.From,
+				To:       key.To,
+				Protocol: string(jobResult.Job.Protocol),
+				Port:     jobResult.Job.ResolvedPort,
+				PortName: jobResult.Job.ResolvedPortName,
+				Expected: jobResult.Combined,
+			}
+			if jobResult.Ingress != nil {
+				expected.Ingress = *jobResult.Ingress
+			}
+			if jobResult.Egress != nil {
+				expected.Egress = *jobResult.Egress
+			}
+			probes = append(probes, expected)
+		}
+	}
+	sort.SliceStable(probes, func(i, j int) bool {
+		left, right := probes[i], probes[j]
+		if left.From != right.From {
+			return left.From < right.From
+		}
+		if left.To != right.To {
+			return left.To < right.To
+		}
+		if left.Protocol != right.Protocol {
+			return left.Protocol < right.Protocol
+		}
+		return left.Port < right.Port
+	})
+	return probes
+}
+
+// ExportResult writes a test case, and the policies and expected results of each of its steps, from a result of
+// simulating it, to a directory under dir
+func ExportResult(dir string, number int, result *Result) (*ExportedTestCase, error) {
+	exported := &ExportedTestCase{
+		Number:      number,
+		Description: result.TestCase.Description,
+		Tags:        result.TestCase.Tags.Keys(),
+		Directory:   exportDirectoryName(number, result.TestCase.Description),
+		Steps:       len(result.TestCase.Steps),
+	}
+	sort.Strings(exported.Tags)
+	testCaseDir := filepath.Join(dir, exported.Directory)
+	if err := os.MkdirAll(testCaseDir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "unable to create export directory %s", testCaseDir)
+	}
+
+	if err := writeYamlFile(filepath.Join(testCaseDir, "testcase.yaml"), exportedYamlTestCase(result.TestCase)); err != nil {
+		return nil, err
+	}
+	if result.Err != nil {
+		exported.Error = result.Err.Error()
+		return exported, nil
+	}
+
+	for i, step := range result.Steps {
+		var policies []string
+		for _, policy := range step.KubePolicies {
+			bytes, err := yaml.Marshal(policy)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to marshal policy %s/%s", policy.Namespace, policy.Name)
+			}
+			policies = append(policies, string(bytes))
+		}
+		policiesPath := filepath.Join(testCaseDir, fmt.Sprintf("step-%d-policies.yaml", i+1))
+		if err := ioutil.WriteFile(policiesPath, []byte(strings.Join(policies, "---\n")), 0644); err != nil {
+			return nil, errors.Wrapf(err, "unable to write %s", policiesPath)
+		}
+		if err := writeJsonFile(filepath.Join(testCaseDir, fmt.Sprintf("step-%d-expected.json", i+1)), ExpectedProbes(step.SimulatedProbe)); err != nil {
+			return nil, err
+		}
+	}
+	return exported, nil
+}
+
+func (e *ExportIndex) WriteToDirectory(dir string) (string, error) {
+	path := filepath.Join(dir, ExportIndexFileName)
+	return path, writeJsonFile(path, e)
+}
+
+func writeYamlFile(path string, obj interface{}) error {
+	bytes, err := yaml.Marshal(obj)
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal %s", path)
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, bytes, 0644), "unable to write %s", path)
+}
+
+func writeJsonFile(path string, obj interface{}) error {
+	bytes, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal %s", path)
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, bytes, 0644), "unable to write %s", path)
+}
+
+// ExportTestCases simulates each test case against kubernetes -- which should be a mock, since nothing is verified --
+// and writes the test cases, their policies, and their expected results to dir, along with an index of them
+func ExportTestCases(kubernetes kube.IKubernetes, resources *probe.Resources, dir string, testCases []*generator.TestCase) (*ExportIndex, error) {
+	interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{
+		ResetClusterBeforeTestCase: true,
+		PerturbationWaitSeconds:    0,
+	})
+	if err != nil {
+		return nil, err
+	}
+	index := &ExportIndex{}
+	for i, testCase := range testCases {
+		exported, err := ExportResult(dir, i+1, interpreter.ExecuteTestCase(testCase))
+		if err != nil {
+			return nil, err
+		}
+		index.TestCases = append(index.TestCases, exported)
+	}
+	if _, err := index.WriteToDirectory(dir); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
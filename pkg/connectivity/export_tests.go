@@ -0,0 +1,73 @@
+package connectivity
+
+import (
+	"encoding/json"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+)
+
+func RunExportTests() {
+	Describe("Export", func() {
+		It("should write test cases, with their policies and expected results, without verifying anything", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-export")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+
+			policy := generator.BuildPolicy(generator.SetNamespace("x")).NetworkPolicy()
+			denyAll := generator.NewSingleStepTestCase("deny all ingress: in x", generator.NewStringSet(generator.TagDenyAll), generator.ProbeAllAvailable, generator.CreatePolicy(policy))
+			chaos := generator.NewSingleStepTestCase("restart the CNI", generator.NewStringSet(generator.TagRestartCNI), generator.ProbeAllAvailable, generator.RestartCNI())
+
+			index, err := ExportTestCases(kubernetes, resources, dir, []*generator.TestCase{denyAll, chaos})
+			Expect(err).To(Succeed())
+			Expect(index.TestCases).To(HaveLen(2))
+
+			exported := index.TestCases[0]
+			Expect(exported.Directory).To(Equal("0001-deny-all-ingress-in-x"))
+			Expect(exported.Steps).To(Equal(1))
+			Expect(exported.Error).To(Equal(""))
+			Expect(index.TestCases[1].Error).ToNot(Equal(""))
+
+			indexBytes, err := ioutil.ReadFile(filepath.Join(dir, ExportIndexFileName))
+			Expect(err).To(Succeed())
+			readIndex := &ExportIndex{}
+			Expect(json.Unmarshal(indexBytes, readIndex)).To(Succeed())
+			Expect(readIndex).To(Equal(index))
+
+			policyBytes, err := ioutil.ReadFile(filepath.Join(dir, exported.Directory, "step-1-policies.yaml"))
+			Expect(err).To(Succeed())
+			Expect(string(policyBytes)).To(Equal(utils.YamlString(policy)))
+
+			expectedBytes, err := ioutil.ReadFile(filepath.Join(dir, exported.Directory, "step-1-expected.json"))
+			Expect(err).To(Succeed())
+			var probes []*ExpectedProbe
+			Expect(json.Unmarshal(expectedBytes, &probes)).To(Succeed())
+			Expect(probes).To(HaveLen(4))
+			for _, expected := range probes {
+				if expected.To == "x/a" {
+					Expect(expected.Expected).To(Equal(probe.ConnectivityBlocked))
+				} else {
+					Expect(expected.Expected).To(Equal(probe.ConnectivityAllowed))
+				}
+			}
+
+			reloaded, err := generator.LoadYamlTestCases(filepath.Join(dir, exported.Directory, "testcase.yaml"))
+			Expect(err).To(Succeed())
+			Expect(reloaded).To(HaveLen(1))
+			Expect(reloaded[0].Description).To(Equal(denyAll.Description))
+			Expect(reloaded[0].Tags.ContainsAny([]string{generator.TagDenyAll})).To(BeTrue())
+			Expect(reloaded[0].Steps[0].Actions[0].CreatePolicy.Policy).To(Equal(policy))
+		})
+	})
+}
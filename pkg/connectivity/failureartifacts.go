@@ -0,0 +1,128 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FailureArtifacts collects what's needed to debug failed test cases, each into a directory of its own under Dir:
+//   - error.txt: the error the test case hit, if any
+//   - events.yaml, networkpolicies.yaml and pods.yaml: the events, network policies and pods in the test case's
+//     namespaces, as returned by the API server
+//   - step-N-probes.txt: the output of every probe job of every try of step N
+//   - reproduction: a script, and the resources and policies it applies, to reproduce the first step with mismatched
+//     probes outside of cyclonus -- see writeReproduction
+//
+// Test cases have to be collected right after they fail, before the next test case resets the cluster.
+type FailureArtifacts struct {
+	Dir   string
+	namer *directoryNamer
+}
+
+func NewFailureArtifacts(dir string) *FailureArtifacts {
+	return &FailureArtifacts{Dir: dir, namer: newDirectoryNamer()}
+}
+
+// directoryNamer names a directory per test case, after its slug; test cases with the same description -- i.e. one
+// run repeatedly -- are numbered after the first
+type directoryNamer struct {
+	lock  sync.Mutex
+	names map[string]int
+}
+
+func newDirectoryNamer() *directoryNamer {
+	return &directoryNamer{names: map[string]int{}}
+}
+
+func (d *directoryNamer) name(description string) string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	slug := testCaseSlug(description)
+	d.names[slug]++
+	if count := d.names[slug]; count > 1 {
+		return fmt.Sprintf("%s-%d", slug, count)
+	}
+	return slug
+}
+
+// Collect writes the artifacts of a failed test case, whose fixture namespaces are namespaces, returning the
+// directory they were written to.  Kube resources which can't be fetched are skipped, so that one failing API call
+// doesn't lose the rest.
+func (f *FailureArtifacts) Collect(kubernetes kube.IKubernetes, namespaces []string, result *Result, ignoreLoopback bool) (string, error) {
+	dir := filepath.Join(f.Dir, f.namer.name(result.TestCase.Description))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "unable to create failure artifacts directory %s", dir)
+	}
+	namespaces = append([]string{}, namespaces...)
+	sort.Strings(namespaces)
+
+	if result.Err != nil {
+		path := filepath.Join(dir, "error.txt")
+		if err := ioutil.WriteFile(path, []byte(fmt.Sprintf("%+v\n", result.Err)), 0644); err != nil {
+			return "", errors.Wrapf(err, "unable to write %s", path)
+		}
+	}
+
+	if events, err := kube.GetEventsInNamespaces(kubernetes, namespaces); err != nil {
+		logrus.Warnf("unable to collect events for failure artifacts: %+v", err)
+	} else if err := writeYamlFile(filepath.Join(dir, "events.yaml"), events); err != nil {
+		return "", err
+	}
+	if policies, err := kube.GetNetworkPoliciesInNamespaces(kubernetes, namespaces); err != nil {
+		logrus.Warnf("unable to collect network policies for failure artifacts: %+v", err)
+	} else if err := writeYamlFile(filepath.Join(dir, "networkpolicies.yaml"), policies); err != nil {
+		return "", err
+	}
+	if pods, err := kube.GetPodsInNamespaces(kubernetes, namespaces); err != nil {
+		logrus.Warnf("unable to collect pods for failure artifacts: %+v", err)
+	} else if err := writeYamlFile(filepath.Join(dir, "pods.yaml"), pods); err != nil {
+		return "", err
+	}
+
+	for i, step := range result.Steps {
+		path := filepath.Join(dir, fmt.Sprintf("step-%d-probes.txt", i+1))
+		if err := ioutil.WriteFile(path, []byte(probeOutputs(step)), 0644); err != nil {
+			return "", errors.Wrapf(err, "unable to write %s", path)
+		}
+	}
+	if _, err := writeReproduction(dir, result, ignoreLoopback); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// probeOutputs renders the result and output of every job of every try of a step, next to the expected result
+func probeOutputs(step *StepResult) string {
+	str := &strings.Builder{}
+	expected := step.ExpectedProbe()
+	for try, kubeProbe := range step.KubeProbes {
+		for _, key := range kubeProbe.Wrapped.Keys() {
+			expectedResults := expected.Get(key.From, key.To).JobResults
+			jobResults := kubeProbe.Get(key.From, key.To).JobResults
+			var jobKeys []string
+			for jobKey := range jobResults {
+				jobKeys = append(jobKeys, jobKey)
+			}
+			sort.Strings(jobKeys)
+			for _, jobKey := range jobKeys {
+				expectedConnectivity := probe.ConnectivityUnknown
+				if expectedResult, ok := expectedResults[jobKey]; ok {
+					expectedConnectivity = expectedResult.Combined
+				}
+				jobResult := jobResults[jobKey]
+				str.WriteString(fmt.Sprintf("try %d: %s -> %s %s: expected %s, got %s\n%s\n\n",
+					try+1, key.From, key.To, jobKey, expectedConnectivity, jobResult.Combined, jobResult.Output))
+			}
+		}
+	}
+	return str.String()
+}
@@ -0,0 +1,53 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+)
+
+func RunFailureArtifactsTests() {
+	Describe("FailureArtifacts", func() {
+		It("should collect artifacts of failed test cases, right after they fail", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-failure-artifacts")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+			interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true, FailureArtifacts: NewFailureArtifacts(dir)})
+			Expect(err).To(Succeed())
+
+			// the mock allows everything, so only the test case with a policy fails
+			passing := generator.NewSingleStepTestCase("no policies", generator.NewStringSet(generator.TagDenyAll), generator.ProbeAllAvailable)
+			Expect(interpreter.ExecuteTestCase(passing).FailureArtifacts).To(Equal(""))
+
+			policy := generator.BuildPolicy(generator.SetNamespace("x")).NetworkPolicy()
+			failing := generator.NewSingleStepTestCase("deny all ingress", generator.NewStringSet(generator.TagDenyAll), generator.ProbeAllAvailable, generator.CreatePolicy(policy))
+			result := interpreter.ExecuteTestCase(failing)
+			Expect(result.Passed(false)).To(BeFalse())
+			Expect(result.FailureArtifacts).To(Equal(filepath.Join(dir, "deny-all-ingress")))
+
+			for _, file := range []string{"events.yaml", "networkpolicies.yaml", "pods.yaml"} {
+				Expect(filepath.Join(result.FailureArtifacts, file)).To(BeAnExistingFile())
+			}
+			Expect(filepath.Join(result.FailureArtifacts, "error.txt")).ToNot(BeAnExistingFile())
+			policies, err := ioutil.ReadFile(filepath.Join(result.FailureArtifacts, "networkpolicies.yaml"))
+			Expect(err).To(Succeed())
+			Expect(string(policies)).To(ContainSubstring("namespace: x"))
+			probes, err := ioutil.ReadFile(filepath.Join(result.FailureArtifacts, "step-1-probes.txt"))
+			Expect(err).To(Succeed())
+			Expect(string(probes)).To(ContainSubstring("try 1: y/a -> x/a TCP/80: expected blocked, got allowed"))
+			Expect(string(probes)).To(ContainSubstring("stdout:"))
+
+			Expect(interpreter.ExecuteTestCase(failing).FailureArtifacts).To(Equal(filepath.Join(dir, "deny-all-ingress-2")))
+		})
+	})
+}
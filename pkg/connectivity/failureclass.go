@@ -0,0 +1,111 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// FailureClass distinguishes why a test case failed, so that infrastructure flakes can be retried without
+// masking real policy failures
+type FailureClass string
+
+const (
+	FailureClassNone FailureClass = "none"
+	// FailureClassInfrastructure: exec failed, pod not ready, API error, etc.
+	FailureClassInfrastructure FailureClass = "infrastructure"
+	// FailureClassVerification: the CNI's verdict didn't match the expected verdict
+	FailureClassVerification FailureClass = "verification"
+	// FailureClassSetupInvalid: the test case itself couldn't be set up, i.e. the API server rejected a policy
+	FailureClassSetupInvalid FailureClass = "setup-invalid"
+)
+
+// AllFailureClasses is ordered from most to least severe
+var AllFailureClasses = []FailureClass{
+	FailureClassVerification,
+	FailureClassSetupInvalid,
+	FailureClassInfrastructure,
+}
+
+// ExitCode is meant for CI: 1 is left for crashes, so that each failure class can be told apart
+func (f FailureClass) ExitCode() int {
+	switch f {
+	case FailureClassNone:
+		return 0
+	case FailureClassVerification:
+		return 2
+	case FailureClassSetupInvalid:
+		return 3
+	case FailureClassInfrastructure:
+		return 4
+	default:
+		panic(errors.Errorf("invalid FailureClass value %+v", f))
+	}
+}
+
+// MostSevereFailureClass picks the most severe class with a non-zero count, so that a real verification failure
+// is never hidden behind an infrastructure flake
+func MostSevereFailureClass(counts map[FailureClass]int) FailureClass {
+	for _, class := range AllFailureClasses {
+		if counts[class] > 0 {
+			return class
+		}
+	}
+	return FailureClassNone
+}
+
+type ClassifiedError struct {
+	Class FailureClass
+	Err   error
+}
+
+func (c *ClassifiedError) Error() string {
+	return fmt.Sprintf("%s error: %s", c.Class, c.Err.Error())
+}
+
+func (c *ClassifiedError) Unwrap() error {
+	return c.Err
+}
+
+func NewInfrastructureError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{Class: FailureClassInfrastructure, Err: err}
+}
+
+func NewSetupInvalidError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{Class: FailureClassSetupInvalid, Err: err}
+}
+
+// classifyKubeError treats the API server rejecting a request as a problem with the test case, and anything else
+// as a problem with the infrastructure.  Errors which are already classified are left alone.
+func classifyKubeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return err
+	}
+	cause := errors.Cause(err)
+	if kerrors.IsInvalid(cause) || kerrors.IsBadRequest(cause) || kerrors.IsAlreadyExists(cause) {
+		return NewSetupInvalidError(err)
+	}
+	return NewInfrastructureError(err)
+}
+
+// ClassOfError defaults to infrastructure for errors which haven't been explicitly classified
+func ClassOfError(err error) FailureClass {
+	if err == nil {
+		return FailureClassNone
+	}
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+	return FailureClassInfrastructure
+}
@@ -0,0 +1,34 @@
+package connectivity
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func RunFailureClassTests() {
+	Describe("FailureClass", func() {
+		It("should classify errors", func() {
+			Expect(ClassOfError(nil)).To(Equal(FailureClassNone))
+			Expect(ClassOfError(errors.Errorf("unclassified"))).To(Equal(FailureClassInfrastructure))
+			Expect(ClassOfError(errors.Wrapf(NewSetupInvalidError(errors.Errorf("bad policy")), "wrapped"))).To(Equal(FailureClassSetupInvalid))
+
+			invalid := kerrors.NewInvalid(schema.GroupKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"}, "abc", field.ErrorList{})
+			Expect(ClassOfError(classifyKubeError(errors.Wrapf(invalid, "unable to create")))).To(Equal(FailureClassSetupInvalid))
+
+			timeout := kerrors.NewServerTimeout(schema.GroupResource{Resource: "pods"}, "exec", 1)
+			Expect(ClassOfError(classifyKubeError(timeout))).To(Equal(FailureClassInfrastructure))
+
+			Expect(ClassOfError(classifyKubeError(NewSetupInvalidError(errors.Errorf("already classified"))))).To(Equal(FailureClassSetupInvalid))
+		})
+
+		It("should pick the most severe failure class", func() {
+			Expect(MostSevereFailureClass(map[FailureClass]int{})).To(Equal(FailureClassNone))
+			Expect(MostSevereFailureClass(map[FailureClass]int{FailureClassInfrastructure: 3, FailureClassSetupInvalid: 1})).To(Equal(FailureClassSetupInvalid))
+			Expect(MostSevereFailureClass(map[FailureClass]int{FailureClassInfrastructure: 3, FailureClassVerification: 1})).To(Equal(FailureClassVerification))
+		})
+	})
+}
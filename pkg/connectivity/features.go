@@ -0,0 +1,95 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/olekukonko/tablewriter"
+	"github.com/sirupsen/logrus"
+	"net"
+	"strings"
+)
+
+type FeatureSupport string
+
+const (
+	FeatureSupported      FeatureSupport = "yes"
+	FeatureUnsupported    FeatureSupport = "no"
+	FeatureSupportUnknown FeatureSupport = "unknown"
+)
+
+type FeatureCheckResult struct {
+	Feature string
+	Support FeatureSupport
+	Details string
+}
+
+// FeatureSupportOfResult interprets a feature check's test case result: a pass means the feature is supported, and a
+// verification failure means it isn't.  Anything else -- a rejected policy, a broken probe -- says nothing about the
+// CNI, so it's unknown.
+func FeatureSupportOfResult(result *Result, ignoreLoopback bool) (FeatureSupport, string) {
+	switch class := result.FailureClass(ignoreLoopback); class {
+	case FailureClassNone:
+		return FeatureSupported, "probe results matched expected results"
+	case FailureClassVerification:
+		wrong := 0
+		for _, step := range result.Steps {
+			wrong += step.LastComparison().ValueCounts(ignoreLoopback)[DifferentComparison]
+		}
+		return FeatureUnsupported, fmt.Sprintf("%d probe results didn't match expected results", wrong)
+	default:
+		if result.Err != nil {
+			return FeatureSupportUnknown, fmt.Sprintf("%s failure: %s", class, result.Err.Error())
+		}
+		return FeatureSupportUnknown, fmt.Sprintf("%s failure: probes failed to execute", class)
+	}
+}
+
+// RunFeatureChecks runs each feature check's test case in order
+func RunFeatureChecks(interpreter *Interpreter, checks []*generator.FeatureCheck, ignoreLoopback bool) []*FeatureCheckResult {
+	var results []*FeatureCheckResult
+	for _, check := range checks {
+		if interpreter.IsStopped() {
+			results = append(results, &FeatureCheckResult{Feature: check.Feature, Support: FeatureSupportUnknown, Details: "interrupted"})
+			continue
+		}
+		logrus.Infof("checking support for %s", check.Feature)
+		support, details := FeatureSupportOfResult(interpreter.ExecuteTestCase(check.TestCase), ignoreLoopback)
+		results = append(results, &FeatureCheckResult{Feature: check.Feature, Support: support, Details: details})
+	}
+	return results
+}
+
+// CheckIPv6Support looks for IPv6 addresses on the pods in the given namespaces
+func CheckIPv6Support(kubernetes kube.IKubernetes, namespaces []string) *FeatureCheckResult {
+	var v6IPs []string
+	for _, ns := range namespaces {
+		pods, err := kubernetes.GetPodsInNamespace(ns)
+		if err != nil {
+			return &FeatureCheckResult{Feature: generator.FeatureIPv6, Support: FeatureSupportUnknown, Details: err.Error()}
+		}
+		for _, pod := range pods {
+			for _, podIP := range pod.Status.PodIPs {
+				if ip := net.ParseIP(podIP.IP); ip != nil && ip.To4() == nil {
+					v6IPs = append(v6IPs, fmt.Sprintf("%s/%s: %s", ns, pod.Name, podIP.IP))
+				}
+			}
+		}
+	}
+	if len(v6IPs) == 0 {
+		return &FeatureCheckResult{Feature: generator.FeatureIPv6, Support: FeatureUnsupported, Details: "no pods have IPv6 addresses"}
+	}
+	return &FeatureCheckResult{Feature: generator.FeatureIPv6, Support: FeatureSupported, Details: "pods have IPv6 addresses, i.e. " + v6IPs[0]}
+}
+
+func RenderFeatureMatrix(results []*FeatureCheckResult) string {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Feature", "Supported", "Details"})
+	for _, result := range results {
+		table.Append([]string{result.Feature, string(result.Support), result.Details})
+	}
+	table.Render()
+	return str.String()
+}
@@ -0,0 +1,33 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+func RunFeaturesTests() {
+	Describe("Feature checks", func() {
+		It("should map test case outcomes to feature support", func() {
+			checks := generator.FeatureCheckTestCases("1.2.3.4")
+			Expect(checks).To(HaveLen(4))
+
+			passed := &Result{TestCase: checks[0].TestCase}
+			support, _ := FeatureSupportOfResult(passed, false)
+			Expect(support).To(Equal(FeatureSupported))
+
+			rejected := &Result{TestCase: checks[1].TestCase, Err: NewSetupInvalidError(errors.Errorf("endPort not allowed"))}
+			support, details := FeatureSupportOfResult(rejected, false)
+			Expect(support).To(Equal(FeatureSupportUnknown))
+			Expect(details).To(ContainSubstring("endPort not allowed"))
+		})
+
+		It("should build ipBlock feature checks from the pod IP", func() {
+			check := generator.FeatureCheckTestCases("10.0.1.7")[3]
+			Expect(check.Feature).To(Equal(generator.FeatureIPBlockForPodIP))
+			policy := check.TestCase.Steps[0].Actions[0].CreatePolicy.Policy
+			Expect(policy.Spec.Ingress[0].From[0].IPBlock.CIDR).To(Equal("10.0.1.7/32"))
+		})
+	})
+}
@@ -0,0 +1,106 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"strings"
+)
+
+// Stability classifies a test case which was run repeatedly by whether its runs agreed
+type Stability string
+
+const (
+	StabilityStablePass Stability = "stable-pass"
+	StabilityStableFail Stability = "stable-fail"
+	// StabilityFlaky test cases passed some runs and failed others -- i.e. because the CNI was still converging when
+	// probes ran -- as opposed to failing every run, which points to a real enforcement bug
+	StabilityFlaky Stability = "flaky"
+)
+
+var AllStabilities = []Stability{StabilityStablePass, StabilityStableFail, StabilityFlaky}
+
+// FlakeRecord is how many times a test case was run, and how many of those runs passed
+type FlakeRecord struct {
+	Description string
+	Runs        int
+	Passed      int
+}
+
+func (f *FlakeRecord) Stability() Stability {
+	switch f.Passed {
+	case f.Runs:
+		return StabilityStablePass
+	case 0:
+		return StabilityStableFail
+	default:
+		return StabilityFlaky
+	}
+}
+
+// FlakeReport classifies test cases which were run repeatedly -- see generator.RepeatTestCases -- as stable or flaky
+type FlakeReport struct {
+	TestCases []*FlakeRecord
+}
+
+// NewFlakeReport groups the runs of each test case by description, in the order test cases were first run.  Runs
+// which were interrupted are left out, since they didn't get to pass or fail.
+func NewFlakeReport(records []*TestCaseRecord) *FlakeReport {
+	report := &FlakeReport{}
+	byDescription := map[string]*FlakeRecord{}
+	for _, record := range records {
+		if record.Interrupted {
+			continue
+		}
+		flakeRecord, ok := byDescription[record.Description]
+		if !ok {
+			flakeRecord = &FlakeRecord{Description: record.Description}
+			byDescription[record.Description] = flakeRecord
+			report.TestCases = append(report.TestCases, flakeRecord)
+		}
+		flakeRecord.Runs++
+		if record.Passed {
+			flakeRecord.Passed++
+		}
+	}
+	return report
+}
+
+func (f *FlakeReport) Counts() map[Stability]int {
+	counts := map[Stability]int{}
+	for _, testCase := range f.TestCases {
+		counts[testCase.Stability()]++
+	}
+	return counts
+}
+
+// FlakeRate is the percentage of test cases which were flaky
+func (f *FlakeReport) FlakeRate() float64 {
+	return percentage(f.Counts()[StabilityFlaky], len(f.TestCases))
+}
+
+// Table lists the flaky test cases, with how often each passed, followed by counts of test cases by stability
+func (f *FlakeReport) Table() string {
+	str := &strings.Builder{}
+	counts := f.Counts()
+	if counts[StabilityFlaky] > 0 {
+		table := tablewriter.NewWriter(str)
+		table.SetAutoWrapText(false)
+		str.WriteString("Flaky tests:\n")
+		table.SetHeader([]string{"Test", "Passed", "Failed"})
+		for _, testCase := range f.TestCases {
+			if testCase.Stability() == StabilityFlaky {
+				table.Append([]string{
+					testCase.Description,
+					fmt.Sprintf("%d / %d", testCase.Passed, testCase.Runs),
+					fmt.Sprintf("%d / %d", testCase.Runs-testCase.Passed, testCase.Runs),
+				})
+			}
+		}
+		table.Render()
+	}
+	for _, stability := range AllStabilities {
+		str.WriteString(fmt.Sprintf("%s: %d\n", stability, counts[stability]))
+	}
+	str.WriteString(fmt.Sprintf("flake rate: %.0f%% of %d tests\n", f.FlakeRate(), len(f.TestCases)))
+	return str.String()
+}
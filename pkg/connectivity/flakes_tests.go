@@ -0,0 +1,37 @@
+package connectivity
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunFlakeTests() {
+	Describe("FlakeReport", func() {
+		It("should classify repeated test cases by whether their runs agreed", func() {
+			report := NewFlakeReport([]*TestCaseRecord{
+				{Number: 1, Description: "a", Passed: true},
+				{Number: 2, Description: "a", Passed: true},
+				{Number: 3, Description: "b", Passed: true},
+				{Number: 4, Description: "b", Passed: false},
+				{Number: 5, Description: "c", Passed: false},
+				{Number: 6, Description: "c", Passed: false},
+				{Number: 7, Description: "d", Passed: true},
+				{Number: 8, Description: "d", Passed: false, Interrupted: true},
+			})
+
+			Expect(report.TestCases).To(Equal([]*FlakeRecord{
+				{Description: "a", Runs: 2, Passed: 2},
+				{Description: "b", Runs: 2, Passed: 1},
+				{Description: "c", Runs: 2, Passed: 0},
+				{Description: "d", Runs: 1, Passed: 1},
+			}))
+			Expect(report.Counts()).To(Equal(map[Stability]int{StabilityStablePass: 2, StabilityFlaky: 1, StabilityStableFail: 1}))
+			Expect(report.FlakeRate()).To(Equal(25.0))
+
+			table := report.Table()
+			Expect(table).To(ContainSubstring("Flaky tests:"))
+			Expect(table).To(ContainSubstring("1 / 2"))
+			Expect(table).To(ContainSubstring("flake rate: 25% of 4 tests"))
+		})
+	})
+}
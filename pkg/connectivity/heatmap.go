@@ -0,0 +1,135 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"sort"
+	"strings"
+)
+
+const (
+	HeatmapSource             = "source"
+	HeatmapDestination        = "destination"
+	HeatmapPortProtocol       = "port/protocol"
+	HeatmapNamespacesProtocol = "namespaces/protocol"
+)
+
+var AllHeatmapDimensions = []string{
+	HeatmapSource,
+	HeatmapDestination,
+	HeatmapPortProtocol,
+	HeatmapNamespacesProtocol,
+}
+
+// FailureHeatmap compares every job of the last try of every step to its expected result, and counts the results by
+// where the job went -- so that failures which cluster, i.e. everything to namespace z on UDP, stand out -- and by
+// the index of the step, to show whether failures pile up in later steps of test cases.
+type FailureHeatmap struct {
+	// Counts are by dimension, and then by value, i.e. Counts["namespaces/protocol"]["x -> z, UDP"]
+	Counts map[string]map[string]map[Comparison]int
+	// StepCounts are by step index, counting from 0
+	StepCounts []map[Comparison]int
+}
+
+func NewFailureHeatmap() *FailureHeatmap {
+	counts := map[string]map[string]map[Comparison]int{}
+	for _, dimension := range AllHeatmapDimensions {
+		counts[dimension] = map[string]map[Comparison]int{}
+	}
+	return &FailureHeatmap{Counts: counts}
+}
+
+func (h *FailureHeatmap) increment(dimension string, value string, comparison Comparison) {
+	if _, ok := h.Counts[dimension][value]; !ok {
+		h.Counts[dimension][value] = map[Comparison]int{}
+	}
+	h.Counts[dimension][value][comparison]++
+}
+
+// AddStep adds the jobs of a step's comparison table
+func (h *FailureHeatmap) AddStep(stepIndex int, comparison *ComparisonTable, ignoreLoopback bool) {
+	for len(h.StepCounts) <= stepIndex {
+		h.StepCounts = append(h.StepCounts, map[Comparison]int{})
+	}
+	for _, key := range comparison.Wrapped.Keys() {
+		if ignoreLoopback && key.From == key.To {
+			continue
+		}
+		item := comparison.Get(key.From, key.To)
+		for jobKey, kubeResult := range item.Kube.JobResults {
+			c := SameComparison
+			if simulated, ok := item.Simulated.JobResults[jobKey]; !ok || simulated.Combined != kubeResult.Combined {
+				c = DifferentComparison
+			}
+			job := kubeResult.Job
+			h.StepCounts[stepIndex][c]++
+			h.increment(HeatmapSource, key.From, c)
+			h.increment(HeatmapDestination, key.To, c)
+			h.increment(HeatmapPortProtocol, jobKey, c)
+			h.increment(HeatmapNamespacesProtocol, fmt.Sprintf("%s -> %s, %s", job.FromNamespace, job.ToNamespace, job.Protocol), c)
+		}
+	}
+}
+
+func (h *FailureHeatmap) FailedCount() int {
+	count := 0
+	for _, counts := range h.StepCounts {
+		count += counts[DifferentComparison]
+	}
+	return count
+}
+
+// HottestValues returns the values of a dimension with at least one failure, most failures first, and at most n
+func (h *FailureHeatmap) HottestValues(dimension string, n int) []string {
+	var values []string
+	for value, counts := range h.Counts[dimension] {
+		if counts[DifferentComparison] > 0 {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		left, right := h.Counts[dimension][values[i]], h.Counts[dimension][values[j]]
+		if left[DifferentComparison] != right[DifferentComparison] {
+			return left[DifferentComparison] > right[DifferentComparison]
+		}
+		return values[i] < values[j]
+	})
+	if n < len(values) {
+		values = values[:n]
+	}
+	return values
+}
+
+func failedPercentage(counts map[Comparison]int) string {
+	total := counts[SameComparison] + counts[DifferentComparison]
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", 100*float64(counts[DifferentComparison])/float64(total))
+}
+
+// RenderTables renders a table of the n hottest values of each dimension, and a table of step trends
+func (h *FailureHeatmap) RenderTables(n int) string {
+	str := &strings.Builder{}
+	str.WriteString(fmt.Sprintf("Failure heatmap: where %d wrong results were found, compared to all results in the same place:\n", h.FailedCount()))
+	for _, dimension := range AllHeatmapDimensions {
+		table := tablewriter.NewWriter(str)
+		table.SetAutoWrapText(false)
+		table.SetHeader([]string{"By " + dimension, "Failed", "Total", "Failed %"})
+		for _, value := range h.HottestValues(dimension, n) {
+			counts := h.Counts[dimension][value]
+			table.Append([]string{value, intToString(counts[DifferentComparison]), intToString(counts[SameComparison] + counts[DifferentComparison]), failedPercentage(counts)})
+		}
+		table.Render()
+	}
+
+	str.WriteString("Failures by step:\n")
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Step", "Failed", "Total", "Failed %"})
+	for i, counts := range h.StepCounts {
+		table.Append([]string{intToString(i + 1), intToString(counts[DifferentComparison]), intToString(counts[SameComparison] + counts[DifferentComparison]), failedPercentage(counts)})
+	}
+	table.Render()
+	return str.String()
+}
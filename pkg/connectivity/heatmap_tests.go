@@ -0,0 +1,51 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunHeatmapTests() {
+	Describe("FailureHeatmap", func() {
+		It("should count failures by where they happened, and by step", func() {
+			items := []string{"x/a", "z/a", "z/b"}
+			comparison := func(isFailure func(fr string, to string, protocol v1.Protocol) bool) *ComparisonTable {
+				kubeProbe, simulatedProbe := probe.NewTable(items), probe.NewTable(items)
+				for _, fr := range items {
+					for _, to := range items {
+						for _, protocol := range []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP} {
+							job := &probe.Job{FromKey: fr, ToKey: to, FromNamespace: fr[:1], ToNamespace: to[:1], Protocol: protocol, ResolvedPort: 80}
+							kubeConnectivity := probe.ConnectivityAllowed
+							if isFailure(fr, to, protocol) {
+								kubeConnectivity = probe.ConnectivityBlocked
+							}
+							Expect(kubeProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: kubeConnectivity})).To(Succeed())
+							Expect(simulatedProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: probe.ConnectivityAllowed})).To(Succeed())
+						}
+					}
+				}
+				return NewComparisonTableFrom(kubeProbe, simulatedProbe)
+			}
+
+			heatmap := NewFailureHeatmap()
+			heatmap.AddStep(0, comparison(func(fr string, to string, protocol v1.Protocol) bool {
+				return false
+			}), true)
+			heatmap.AddStep(1, comparison(func(fr string, to string, protocol v1.Protocol) bool {
+				return to[:1] == "z" && protocol == v1.ProtocolUDP
+			}), true)
+
+			// x/a, z/a and z/b to z/a and z/b, minus loopback
+			Expect(heatmap.FailedCount()).To(Equal(4))
+			Expect(heatmap.StepCounts).To(Equal([]map[Comparison]int{
+				{SameComparison: 12},
+				{SameComparison: 8, DifferentComparison: 4},
+			}))
+			Expect(heatmap.HottestValues(HeatmapNamespacesProtocol, 10)).To(Equal([]string{"x -> z, UDP", "z -> z, UDP"}))
+			Expect(heatmap.HottestValues(HeatmapPortProtocol, 10)).To(Equal([]string{"UDP/80"}))
+			Expect(heatmap.HottestValues(HeatmapDestination, 1)).To(Equal([]string{"z/a"}))
+		})
+	})
+}
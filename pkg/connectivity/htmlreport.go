@@ -0,0 +1,204 @@
+package connectivity
+
+import (
+	"github.com/pkg/errors"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const HTMLReportFileName = "report.html"
+
+// htmlReport is what the html template renders: the results document, with each step's probes arranged into one
+// truth table per job
+type htmlReport struct {
+	Passed  int
+	Failed  int
+	Partial bool
+	Tags    []string
+	Tests   []*htmlTestCase
+}
+
+type htmlTestCase struct {
+	*TestCaseRecord
+	Steps []*htmlStep
+}
+
+type htmlStep struct {
+	*StepRecord
+	Tables []*htmlTruthTable
+}
+
+type htmlTruthTable struct {
+	Job   string
+	Tos   []string
+	Rows  []*htmlTruthTableRow
+	Wrong int
+}
+
+type htmlTruthTableRow struct {
+	From  string
+	Cells []*htmlCell
+}
+
+type htmlCell struct {
+	Expected string
+	Actual   string
+	Title    string
+	Wrong    bool
+	Ignored  bool
+}
+
+// truthTables arranges probe records into a table per job, keeping the records' order of sources and destinations
+func truthTables(records []*ProbeRecord) []*htmlTruthTable {
+	tables := map[string]*htmlTruthTable{}
+	rows := map[string]map[string]*htmlTruthTableRow{}
+	var jobs []string
+	for _, record := range records {
+		table, ok := tables[record.Job]
+		if !ok {
+			table = &htmlTruthTable{Job: record.Job}
+			tables[record.Job] = table
+			rows[record.Job] = map[string]*htmlTruthTableRow{}
+			jobs = append(jobs, record.Job)
+		}
+		if len(table.Rows) == 0 || table.Rows[0].From == record.From {
+			table.Tos = append(table.Tos, record.To)
+		}
+		row, ok := rows[record.Job][record.From]
+		if !ok {
+			row = &htmlTruthTableRow{From: record.From}
+			rows[record.Job][record.From] = row
+			table.Rows = append(table.Rows, row)
+		}
+		row.Cells = append(row.Cells, &htmlCell{
+			Expected: connectivitySymbol(record.Expected),
+			Actual:   connectivitySymbol(record.Actual),
+			Title:    record.From + " -> " + record.To + ": expected " + string(record.Expected) + ", actual " + string(record.Actual),
+			Wrong:    record.Wrong,
+			Ignored:  record.Ignored,
+		})
+		if record.Wrong {
+			table.Wrong++
+		}
+	}
+	sort.Strings(jobs)
+	var sorted []*htmlTruthTable
+	for _, job := range jobs {
+		sorted = append(sorted, tables[job])
+	}
+	return sorted
+}
+
+func newHTMLReport(doc *ResultsDocument) *htmlReport {
+	report := &htmlReport{Passed: doc.Passed, Failed: doc.Failed, Partial: doc.Partial}
+	tags := map[string]bool{}
+	for _, test := range doc.Tests {
+		testCase := &htmlTestCase{TestCaseRecord: test}
+		for _, step := range test.Steps {
+			testCase.Steps = append(testCase.Steps, &htmlStep{StepRecord: step, Tables: truthTables(step.Probes)})
+		}
+		for _, tag := range test.Tags {
+			tags[tag] = true
+		}
+		report.Tests = append(report.Tests, testCase)
+	}
+	for tag := range tags {
+		report.Tags = append(report.Tags, tag)
+	}
+	sort.Strings(report.Tags)
+	return report
+}
+
+// WriteHTMLReport writes a standalone html page to the directory, with each test case's truth tables, which can
+// be switched between expected and actual results, and filtered by tag and by whether they failed
+func (r *ResultsDocument) WriteHTMLReport(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrapf(err, "unable to create html report directory %s", dir)
+	}
+	path := filepath.Join(dir, HTMLReportFileName)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to create html report %s", path)
+	}
+	defer file.Close()
+	err = htmlReportTemplate.Execute(file, newHTMLReport(r))
+	return path, errors.Wrapf(err, "unable to render html report %s", path)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>cyclonus results</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.test { border: 1px solid #ccc; border-radius: 4px; margin: 1em 0; padding: 0.5em 1em; }
+.test.failed > summary { color: #b00; }
+.tag { background: #eee; border-radius: 3px; font-size: 0.8em; margin-right: 0.3em; padding: 0 0.3em; }
+table.truth { border-collapse: collapse; display: inline-table; margin: 0.5em 1em 0.5em 0; vertical-align: top; }
+table.truth th, table.truth td { border: 1px solid #ccc; font-family: monospace; padding: 0.1em 0.4em; text-align: center; }
+table.truth td.wrong { background: #f8c0c0; font-weight: bold; }
+table.truth td.ignored { color: #aaa; }
+body.show-expected .actual, body.show-actual .expected { display: none; }
+</style>
+</head>
+<body class="show-actual">
+<h1>cyclonus results</h1>
+<p>{{.Passed}} passed, {{.Failed}} failed{{if .Partial}}; the run was interrupted, so not all test cases were run{{end}}</p>
+<p>
+<label>Show
+<select id="view">
+<option value="show-actual">actual results</option>
+<option value="show-expected">expected results</option>
+<option value="show-both">expected / actual</option>
+</select></label>
+<label>Tag
+<select id="tag">
+<option value="">all</option>
+{{- range .Tags}}
+<option value="{{.}}">{{.}}</option>
+{{- end}}
+</select></label>
+<label><input type="checkbox" id="failures-only"> failures only</label>
+</p>
+{{- range .Tests}}
+<details class="test{{if not .Passed}} failed{{end}}" data-tags=" {{range .Tags}}{{.}} {{end}}"{{if not .Passed}} open{{end}}>
+<summary>#{{.Number}} {{.Description}}: {{if .Passed}}passed{{else}}failed ({{.FailureClass}}){{end}}{{if .Interrupted}}, interrupted{{end}}</summary>
+<p>{{range .Tags}}<span class="tag">{{.}}</span>{{end}}</p>
+{{- if .Error}}
+<pre>{{.Error}}</pre>
+{{- end}}
+{{- range $i, $step := .Steps}}
+<h4>Step {{add $i 1}}: {{$step.Wrong}} wrong, {{$step.Ignored}} ignored, {{$step.Right}} correct, after {{$step.Tries}} tries</h4>
+{{- range $step.Tables}}
+<table class="truth">
+<tr><th>{{.Job}}</th>{{range .Tos}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr><th>{{.From}}</th>{{range .Cells}}<td class="{{if .Wrong}}wrong{{end}}{{if .Ignored}} ignored{{end}}" title="{{.Title}}"><span class="expected">{{.Expected}}</span><span class="both expected actual">/</span><span class="actual">{{.Actual}}</span></td>{{end}}</tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+</details>
+{{- end}}
+<script>
+function update() {
+  document.body.className = document.getElementById("view").value;
+  var tag = document.getElementById("tag").value;
+  var failuresOnly = document.getElementById("failures-only").checked;
+  document.querySelectorAll(".test").forEach(function (test) {
+    var shown = (tag === "" || test.dataset.tags.indexOf(" " + tag + " ") >= 0) &&
+      (!failuresOnly || test.classList.contains("failed"));
+    test.style.display = shown ? "" : "none";
+  });
+}
+document.querySelectorAll("select, input").forEach(function (input) { input.addEventListener("change", update); });
+update();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,69 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func RunHTMLReportTests() {
+	Describe("HTML report", func() {
+		records := []*ProbeRecord{
+			{From: "x/a", To: "x/a", Job: "TCP/80", Expected: probe.ConnectivityAllowed, Actual: probe.ConnectivityAllowed, Ignored: true},
+			{From: "x/a", To: "y/a", Job: "TCP/80", Expected: probe.ConnectivityAllowed, Actual: probe.ConnectivityAllowed},
+			{From: "x/a", To: "x/a", Job: "SCTP/80", Expected: probe.ConnectivityAllowed, Actual: probe.ConnectivityUnknown},
+			{From: "x/a", To: "y/a", Job: "SCTP/80", Expected: probe.ConnectivityAllowed, Actual: probe.ConnectivityAllowed},
+			{From: "y/a", To: "x/a", Job: "TCP/80", Expected: probe.ConnectivityBlocked, Actual: probe.ConnectivityAllowed, Wrong: true},
+			{From: "y/a", To: "y/a", Job: "TCP/80", Expected: probe.ConnectivityBlocked, Actual: probe.ConnectivityBlocked},
+		}
+
+		It("should arrange probes into a truth table per job", func() {
+			tables := truthTables(records)
+			Expect(tables).To(HaveLen(2))
+
+			Expect(tables[0].Job).To(Equal("SCTP/80"))
+			Expect(tables[0].Tos).To(Equal([]string{"x/a", "y/a"}))
+			Expect(tables[0].Rows).To(HaveLen(1))
+			Expect(tables[0].Rows[0].Cells[0].Actual).To(Equal("?"))
+
+			Expect(tables[1].Job).To(Equal("TCP/80"))
+			Expect(tables[1].Tos).To(Equal([]string{"x/a", "y/a"}))
+			Expect(tables[1].Wrong).To(Equal(1))
+			Expect(tables[1].Rows[1].From).To(Equal("y/a"))
+			Expect(tables[1].Rows[1].Cells[0]).To(Equal(&htmlCell{
+				Expected: "✗",
+				Actual:   "✓",
+				Title:    "y/a -> x/a: expected blocked, actual allowed",
+				Wrong:    true,
+			}))
+			Expect(tables[1].Rows[1].Cells[1].Wrong).To(BeFalse())
+		})
+
+		It("should write a page with tag filters and highlighted failures", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-html-report")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+
+			doc := &ResultsDocument{Passed: 1, Failed: 1, Tests: []*TestCaseRecord{
+				{Number: 1, Description: "passes <ok>", Tags: []string{"ingress"}, Passed: true, Steps: []*StepRecord{{Tries: 1, Right: 4}}},
+				{Number: 2, Description: "fails", Tags: []string{"egress", "deny-all"}, FailureClass: FailureClassVerification, Steps: []*StepRecord{{Tries: 3, Wrong: 1, Right: 3, Probes: records}}},
+			}}
+			path, err := doc.WriteHTMLReport(filepath.Join(dir, "report"))
+			Expect(err).To(Succeed())
+			Expect(path).To(Equal(filepath.Join(dir, "report", HTMLReportFileName)))
+
+			bytes, err := ioutil.ReadFile(path)
+			Expect(err).To(Succeed())
+			page := string(bytes)
+			Expect(page).To(ContainSubstring(`<option value="deny-all">deny-all</option>`))
+			Expect(page).To(ContainSubstring(`data-tags=" egress deny-all "`))
+			Expect(page).To(ContainSubstring(`#1 passes &lt;ok&gt;: passed`))
+			Expect(page).To(ContainSubstring(`<details class="test failed"`))
+			Expect(page).To(ContainSubstring(`<td class="wrong" title="y/a -&gt; x/a: expected blocked, actual allowed">`))
+			Expect(page).To(ContainSubstring(`Step 1: 1 wrong, 0 ignored, 3 correct, after 3 tries`))
+		})
+	})
+}
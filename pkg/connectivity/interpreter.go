@@ -1,14 +1,20 @@
 package connectivity
 
 import (
+	"context"
 	"fmt"
 	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
 	"github.com/mattfenwick/cyclonus/pkg/generator"
 	"github.com/mattfenwick/cyclonus/pkg/kube"
 	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sort"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,74 +32,326 @@ type InterpreterConfig struct {
 	VerifyClusterStateBeforeTestCase bool
 	BatchJobs                        bool
 	IgnoreLoopback                   bool
+	CrossModeCheck                   bool
+	// WorkerDaemonSetPort, if non-zero, runs jobs in batches like BatchJobs, but through the workers deployed on
+	// every node -- see probe.DeployWorkerDaemonSet -- which serve on this port; the same options aren't supported
+	WorkerDaemonSetPort int
+	// WorkerDaemonSetToken is the token the workers were deployed with
+	WorkerDaemonSetToken string
+	// KubeProbeRetryBackoff is how long to wait before the first re-run of a probe whose results don't match the
+	// expected results; it doubles after that
+	KubeProbeRetryBackoff time.Duration
+	// ExecFailureRetryPolicy is for re-running individual probe jobs which failed to execute
+	ExecFailureRetryPolicy kube.RetryPolicy
+	// ClientCommands overrides the agnhost probe commands; not supported with BatchJobs
+	ClientCommands *probe.ClientCommands
+	// CNIRestarter carries out chaos actions; test cases with chaos actions can't be run without it
+	CNIRestarter *CNIRestarter
+	// SecondaryNetworks are Multus networks to probe over by pod IP, in addition to the primary network
+	SecondaryNetworks []string
+	// DualStack probes every step by pod IP over both IPv4 and IPv6, verifying each family against the policies
+	DualStack bool
+	// IgnoredJobs picks out probe jobs -- by protocol or port -- which aren't verified; they're still run and
+	// reported, unless SkipIgnoredJobs is set, in which case they aren't run at all
+	IgnoredJobs     *probe.JobFilter
+	SkipIgnoredJobs bool
+	// CanonicalOutput replaces IPs in printed output with pod names, so that it's the same from run to run
+	CanonicalOutput bool
+	// RoutePort is the port to additionally probe every step on through the pods' OpenShift Routes; 0 to turn off
+	RoutePort int
+	// UDPBurstSize, if positive, is how many sequenced datagrams each UDP probe sends, so that delivery rates can be
+	// reported; not supported with BatchJobs
+	UDPBurstSize int
+	// HTTPCheck makes each TCP probe request / over HTTP once it's connected, and only allows it if the serving pod
+	// answers; the pods must serve HTTP.  Not supported with BatchJobs
+	HTTPCheck bool
+	// TLSCertificate, if set, makes each TCP probe do a TLS handshake, trusting only this certificate, and request the
+	// serving pod's hostname once it's connected, and only allows it if both work; the pods must serve HTTPS with it.
+	// Not supported with BatchJobs
+	TLSCertificate *probe.TLSCertificate
+	// MeasureLatency times each probe, so that latency percentiles of allowed probes can be reported.  Not supported
+	// with BatchJobs
+	MeasureLatency bool
+	// MultiTargetExec runs all of a pod's probes in a single exec, rather than one exec per probe.  Not supported with
+	// BatchJobs, UDP bursts, HTTP and TLS checks, or latency
+	MultiTargetExec bool
+	// SourcePodParallelism, if positive, bounds how many source pods are probed at once, each pod's probes running one
+	// after another -- or, with BatchJobs or WorkerDaemonSetPort, how many pods' batches are in flight at once
+	SourcePodParallelism int
+	// WarmUp, if set, probes every pair once at the start of each test case, before any actions, and throws the
+	// results away -- so that first-packet artifacts, such as ARP resolution or eBPF map population, don't show up
+	// as denials in the first step
+	WarmUp bool
+	// ProbeRecording, if set, records the result of every kube probe job
+	ProbeRecording *probe.ProbeRecording
+	// ProbeReplay, if set, serves kube probe results from a recording, instead of probing the cluster
+	ProbeReplay *probe.ReplayJobRunner
+	// ExpectedConnectionFate is what long-lived connections are expected to do, once a policy which would deny them is
+	// created; if empty, their fates are reported but not verified
+	ExpectedConnectionFate probe.ConnectionFate
+	// EgressPath, if set, is additionally probed from every pod at every step, i.e. through a proxy or gateway
+	EgressPath *probe.EgressPath
+	// DNSCheck, if set, looks up a name from every pod at every step, and checks that lookups work exactly where the
+	// policies allow egress to the cluster's DNS
+	DNSCheck *probe.DNSCheck
+	// Corroborator, if set, cross-checks every step against the CNI's view of which pods it's enforcing policies on
+	Corroborator Corroborator
+	// FailureArtifacts, if set, collects artifacts for debugging each test case which fails, right after it fails
+	FailureArtifacts *FailureArtifacts
+	// PacketCapturer, if set, re-runs each step's mismatched probes while capturing packets at their ends
+	PacketCapturer *PacketCapturer
+	// Minimizer, if set, shrinks the policies of each failed test case's last step to the smallest which still
+	// reproduce its mismatched probes
+	Minimizer *Minimizer
+	// Context, if set, bounds the whole run: once it's done, probes stop starting new jobs, waits are cut short, and
+	// the interpreter stops as if Stop had been called
+	Context context.Context
 }
 
 type Interpreter struct {
 	kubernetes                       kube.IKubernetes
 	resources                        *probe.Resources
-	kubeProbeRetries                 int
+	kubeProbeRetryPolicy             kube.RetryPolicy
 	perturbationWaitDuration         time.Duration
 	resetClusterBeforeTestCase       bool
 	verifyClusterStateBeforeTestCase bool
 	kubeRunner                       *probe.Runner
 	ignoreLoopback                   bool
+	crossModeCheck                   bool
+	cniRestarter                     *CNIRestarter
+	secondaryNetworks                []string
+	dualStack                        bool
+	ignoredJobs                      *probe.JobFilter
+	skipIgnoredJobs                  bool
+	routePort                        int
+	warmUp                           bool
+	expectedConnectionFate           probe.ConnectionFate
+	egressPath                       *probe.EgressPath
+	egressPathRunner                 *probe.Runner
+	dnsCheck                         *probe.DNSCheck
+	dnsRunner                        *probe.Runner
+	corroborator                     Corroborator
+	failureArtifacts                 *FailureArtifacts
+	packetCapturer                   *PacketCapturer
+	minimizer                        *Minimizer
+	ctx                              context.Context
+	stopped                          int32
 }
 
-func NewInterpreter(kubernetes kube.IKubernetes, resources *probe.Resources, config *InterpreterConfig) *Interpreter {
-	fmt.Printf("resources:\n%s\n", resources.RenderTable())
+func NewInterpreter(kubernetes kube.IKubernetes, resources *probe.Resources, config *InterpreterConfig) (*Interpreter, error) {
+	if config.CanonicalOutput {
+		fmt.Printf("resources:\n%s\n", CanonicalizeIPs(resources.RenderTable(), resources))
+	} else {
+		fmt.Printf("resources:\n%s\n", resources.RenderTable())
+	}
 
+	batchWorkers := defaultBatchWorkersCount
+	if config.SourcePodParallelism > 0 {
+		batchWorkers = config.SourcePodParallelism
+	}
 	var kubeRunner *probe.Runner
-	if config.BatchJobs {
-		kubeRunner = probe.NewKubeBatchRunner(kubernetes, defaultBatchWorkersCount)
+	if config.WorkerDaemonSetPort != 0 {
+		batchJobRunner := probe.NewWorkerDaemonSetJobRunner(kubernetes, config.WorkerDaemonSetPort, config.WorkerDaemonSetToken, batchWorkers)
+		batchJobRunner.RecordOutput = config.FailureArtifacts != nil
+		kubeRunner = &probe.Runner{JobRunner: batchJobRunner}
+	} else if config.BatchJobs {
+		batchJobRunner := probe.NewKubeBatchJobRunner(kubernetes, batchWorkers)
+		batchJobRunner.RecordOutput = config.FailureArtifacts != nil
+		kubeRunner = &probe.Runner{JobRunner: batchJobRunner}
 	} else {
-		kubeRunner = probe.NewKubeRunner(kubernetes, defaultWorkersCount)
+		kubeRunner = &probe.Runner{JobRunner: &probe.KubeJobRunner{
+			Kubernetes:           kubernetes,
+			Workers:              defaultWorkersCount,
+			ClientCommands:       config.ClientCommands,
+			UDPBurstSize:         config.UDPBurstSize,
+			HTTPCheck:            config.HTTPCheck,
+			TLSCertificate:       config.TLSCertificate,
+			MeasureLatency:       config.MeasureLatency,
+			RecordOutput:         config.FailureArtifacts != nil,
+			MultiTarget:          config.MultiTargetExec,
+			SourcePodParallelism: config.SourcePodParallelism,
+		}}
+	}
+	if config.ProbeReplay != nil {
+		kubeRunner.JobRunner = config.ProbeReplay
+	}
+	if config.ProbeRecording != nil {
+		kubeRunner.JobRunner = &probe.RecordingJobRunner{JobRunner: kubeRunner.JobRunner, Recording: config.ProbeRecording}
+	}
+	ctx := config.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	kubeRunner.CheckFailedRetryPolicy = config.ExecFailureRetryPolicy
+	kubeRunner.Context = ctx
+	if config.SkipIgnoredJobs {
+		kubeRunner.Exclude = config.IgnoredJobs
+	}
+
+	var egressPathRunner *probe.Runner
+	if config.EgressPath != nil {
+		var err error
+		egressPathRunner, err = config.EgressPath.Runner(kubernetes, defaultWorkersCount)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unable to set up egress path probes")
+		}
+		egressPathRunner.CheckFailedRetryPolicy = config.ExecFailureRetryPolicy
+		egressPathRunner.Context = ctx
+	}
+
+	var dnsRunner *probe.Runner
+	if config.DNSCheck != nil {
+		var err error
+		dnsRunner, err = config.DNSCheck.Runner(kubernetes, defaultWorkersCount)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unable to set up DNS checks")
+		}
+		dnsRunner.CheckFailedRetryPolicy = config.ExecFailureRetryPolicy
+		dnsRunner.Context = ctx
 	}
 
 	return &Interpreter{
 		kubernetes:                       kubernetes,
 		resources:                        resources,
-		kubeProbeRetries:                 config.KubeProbeRetries,
+		kubeProbeRetryPolicy:             kube.RetryPolicy{Retries: config.KubeProbeRetries, Backoff: config.KubeProbeRetryBackoff},
 		perturbationWaitDuration:         time.Duration(config.PerturbationWaitSeconds) * time.Second,
 		resetClusterBeforeTestCase:       config.ResetClusterBeforeTestCase,
 		verifyClusterStateBeforeTestCase: config.VerifyClusterStateBeforeTestCase,
 		kubeRunner:                       kubeRunner,
 		ignoreLoopback:                   config.IgnoreLoopback,
-	}
+		crossModeCheck:                   config.CrossModeCheck,
+		cniRestarter:                     config.CNIRestarter,
+		secondaryNetworks:                config.SecondaryNetworks,
+		dualStack:                        config.DualStack,
+		ignoredJobs:                      config.IgnoredJobs,
+		skipIgnoredJobs:                  config.SkipIgnoredJobs,
+		routePort:                        config.RoutePort,
+		warmUp:                           config.WarmUp,
+		expectedConnectionFate:           config.ExpectedConnectionFate,
+		egressPath:                       config.EgressPath,
+		egressPathRunner:                 egressPathRunner,
+		dnsCheck:                         config.DNSCheck,
+		dnsRunner:                        dnsRunner,
+		corroborator:                     config.Corroborator,
+		failureArtifacts:                 config.FailureArtifacts,
+		packetCapturer:                   config.PacketCapturer,
+		minimizer:                        config.Minimizer,
+		ctx:                              ctx,
+	}, nil
 }
 
-func (t *Interpreter) ExecuteTestCase(testCase *generator.TestCase) *Result {
-	result := &Result{InitialResources: t.resources, TestCase: testCase}
-	var err error
+// Stop asks the interpreter to finish the probe that's currently running, and then stop executing any further steps.
+// It's safe to call from a different goroutine, i.e. a signal handler.
+func (t *Interpreter) Stop() {
+	atomic.StoreInt32(&t.stopped, 1)
+}
+
+// IsStopped is true if Stop was called, or the interpreter's context is done
+func (t *Interpreter) IsStopped() bool {
+	return atomic.LoadInt32(&t.stopped) == 1 || t.ctx.Err() != nil
+}
 
+func (t *Interpreter) ExecuteTestCase(testCase *generator.TestCase) *Result {
 	// keep track of what's in the cluster, so that we can correctly simulate expected results
 	testCaseState := &TestCaseState{
 		Kubernetes: t.kubernetes,
 		Resources:  t.resources,
 		Policies:   []*networkingv1.NetworkPolicy{},
 	}
+	result := t.executeTestCase(testCase, testCaseState)
+	testCaseState.CloseAllConnections()
+
+	// collect before returning, since the next test case starts by resetting the cluster
+	if t.failureArtifacts != nil && !result.Interrupted && !result.Passed(t.ignoreLoopback) {
+		dir, err := t.failureArtifacts.Collect(t.kubernetes, testCaseState.Resources.NamespacesSlice(), result, t.ignoreLoopback)
+		if err != nil {
+			logrus.Errorf("unable to collect failure artifacts: %+v", err)
+		} else {
+			result.FailureArtifacts = dir
+		}
+	}
+	// minimize after collecting failure artifacts, which should have the policies the test case actually failed with
+	if t.minimizer != nil && !result.Interrupted && result.Err == nil && !result.Passed(t.ignoreLoopback) {
+		result.MinimizedPolicies = t.minimizePolicies(testCase, testCaseState, result)
+	}
+	return result
+}
+
+// minimizePolicies minimizes the policies of the test case's last step -- which are the ones still in the cluster --
+// if its probes were mismatched.  Steps with explicit expectations or waivers are skipped, since the minimizer can
+// only compare to what the policies allow.
+func (t *Interpreter) minimizePolicies(testCase *generator.TestCase, testCaseState *TestCaseState, result *Result) *MinimizedPolicies {
+	stepIndex := len(result.Steps) - 1
+	if step := testCase.Steps[stepIndex]; step.Expected != nil || len(step.Waivers) > 0 {
+		logrus.Infof("not minimizing policies of step %d: it has expectations or waivers", stepIndex+1)
+		return nil
+	}
+	mismatched := result.Steps[stepIndex].LastComparison().MismatchedJobResults(t.ignoreLoopback)
+	if len(mismatched) == 0 {
+		logrus.Infof("not minimizing policies of step %d: its probes weren't mismatched", stepIndex+1)
+		return nil
+	}
+	var jobs []*probe.Job
+	for _, jobResult := range mismatched {
+		jobs = append(jobs, jobResult.Job)
+	}
+	logrus.Infof("minimizing policies of step %d, with %d mismatched probes", stepIndex+1, len(jobs))
+	return t.minimizer.Minimize(t.ctx, testCaseState, t.kubeRunner, stepIndex+1, jobs)
+}
+
+func (t *Interpreter) executeTestCase(testCase *generator.TestCase, testCaseState *TestCaseState) *Result {
+	result := &Result{InitialResources: t.resources, TestCase: testCase}
+	var err error
+
+	start := time.Now()
+	defer func() {
+		result.Timing.Total = time.Since(start)
+	}()
+	// packetCaptureDir is picked at the first step with mismatches
+	packetCaptureDir := ""
 
 	if t.resetClusterBeforeTestCase {
+		setupStart := time.Now()
 		err = testCaseState.ResetClusterState()
+		result.Timing.Setup = time.Since(setupStart)
 		if err != nil {
-			result.Err = err
+			result.Err = NewInfrastructureError(err)
 			return result
 		}
 		logrus.Info("cluster state reset")
 	}
 
 	if t.verifyClusterStateBeforeTestCase {
+		verificationStart := time.Now()
 		err = testCaseState.VerifyClusterState()
+		result.Timing.Verification = time.Since(verificationStart)
 		if err != nil {
-			result.Err = err
+			result.Err = NewInfrastructureError(err)
 			return result
 		}
 		logrus.Info("cluster state verified")
 	}
 
+	if t.warmUp && len(testCase.Steps) > 0 {
+		warmUpStart := time.Now()
+		t.runWarmUp(testCaseState, testCase.Steps)
+		result.Timing.WarmUp = time.Since(warmUpStart)
+	}
+
 	// perform perturbations one at a time, and run a probe after each change
 	for stepIndex, step := range testCase.Steps {
+		if t.IsStopped() {
+			logrus.Infof("interpreter stopped: skipping remaining %d steps", len(testCase.Steps)-stepIndex)
+			result.Interrupted = true
+			return result
+		}
+
 		// TODO grab actual netpols from kube and record in results, for extra debugging/sanity checks
 
+		timing := StepTiming{}
+		var connectionResults []*ConnectionResult
+		actionsStart := time.Now()
 		for actionIndex, action := range step.Actions {
 			if action.CreatePolicy != nil {
 				err = testCaseState.CreatePolicy(action.CreatePolicy.Policy)
@@ -110,51 +368,333 @@ func (t *Interpreter) ExecuteTestCase(testCase *generator.TestCase) *Result {
 			} else if action.ReadNetworkPolicies != nil {
 				err = testCaseState.ReadPolicies(action.ReadNetworkPolicies.Namespaces)
 			} else if action.CreatePod != nil {
-				err = testCaseState.CreatePod(action.CreatePod.Namespace, action.CreatePod.Pod, action.CreatePod.Labels)
+				err = testCaseState.CreatePod(action.CreatePod.Namespace, action.CreatePod.Pod, action.CreatePod.Labels, action.CreatePod.PortNames)
 			} else if action.SetPodLabels != nil {
 				ns, pod, labels := action.SetPodLabels.Namespace, action.SetPodLabels.Pod, action.SetPodLabels.Labels
 				err = testCaseState.SetPodLabels(ns, pod, labels)
 			} else if action.DeletePod != nil {
 				err = testCaseState.DeletePod(action.DeletePod.Namespace, action.DeletePod.Pod)
+			} else if action.CreateAdminNetworkPolicy != nil {
+				err = testCaseState.CreateAdminNetworkPolicy(action.CreateAdminNetworkPolicy.Policy)
+			} else if action.DeleteAdminNetworkPolicy != nil {
+				err = testCaseState.DeleteAdminNetworkPolicy(action.DeleteAdminNetworkPolicy.Name)
+			} else if action.CreateBaselineAdminNetworkPolicy != nil {
+				err = testCaseState.CreateBaselineAdminNetworkPolicy(action.CreateBaselineAdminNetworkPolicy.Policy)
+			} else if action.DeleteBaselineAdminNetworkPolicy != nil {
+				err = testCaseState.DeleteBaselineAdminNetworkPolicy()
+			} else if action.RestartCNI != nil || action.WaitForCNIRecovery != nil {
+				if t.cniRestarter == nil {
+					result.Err = NewSetupInvalidError(errors.Errorf("unable to run chaos action at step %d, action %d: no CNI daemonset configured", stepIndex, actionIndex))
+					return result
+				}
+				if action.RestartCNI != nil {
+					err = t.cniRestarter.Restart()
+				} else {
+					err = t.cniRestarter.WaitForRecovery()
+				}
+			} else if action.OpenConnection != nil {
+				c := action.OpenConnection
+				err = testCaseState.OpenConnection(c.FromNamespace, c.FromPod, c.ToNamespace, c.ToPod, c.Port)
+			} else if action.CloseConnection != nil {
+				c := action.CloseConnection
+				var connectionResult *probe.LongLivedConnectionResult
+				connectionResult, err = testCaseState.CloseConnection(c.FromNamespace, c.FromPod, c.ToNamespace, c.ToPod, c.Port)
+				if err == nil {
+					connectionResults = append(connectionResults, &ConnectionResult{Result: connectionResult, Expected: t.expectedConnectionFate})
+				}
 			} else {
-				err = errors.Errorf("invalid Action at step %d, action %d", stepIndex, actionIndex)
+				result.Err = NewSetupInvalidError(errors.Errorf("invalid Action at step %d, action %d", stepIndex, actionIndex))
+				return result
 			}
 			if err != nil {
-				result.Err = err
+				result.Err = classifyKubeError(err)
 				return result
 			}
 		}
 
+		timing.Actions = time.Since(actionsStart)
+
 		logrus.Infof("step %d: waiting %f seconds for perturbation to take effect", stepIndex+1, t.perturbationWaitDuration.Seconds())
-		time.Sleep(t.perturbationWaitDuration)
+		waitStart := time.Now()
+		err = utils.Sleep(t.ctx, t.perturbationWaitDuration)
+		timing.PerturbationWait = time.Since(waitStart)
+		if err != nil {
+			logrus.Infof("interpreter stopped during perturbation wait of step %d: %+v", stepIndex+1, err)
+			result.Interrupted = true
+			return result
+		}
+
+		if err := step.Expected.CheckPods(testCaseState.Resources.SortedPodNames()); err != nil {
+			result.Err = NewSetupInvalidError(errors.WithMessagef(err, "invalid expected connectivity at step %d", stepIndex))
+			return result
+		}
+
+		probeStart := time.Now()
+		stepResult := t.runProbe(testCaseState, step.Probe, step.Expected, step.Waivers)
+		timing.Probing = time.Since(probeStart)
+		stepResult.Timing = timing
+		stepResult.ConnectionResults = connectionResults
+		result.Steps = append(result.Steps, stepResult)
 
-		result.Steps = append(result.Steps, t.runProbe(testCaseState, step.Probe))
+		if t.packetCapturer != nil {
+			if mismatched := stepResult.LastComparison().MismatchedJobResults(t.ignoreLoopback); len(mismatched) > 0 {
+				if packetCaptureDir == "" {
+					packetCaptureDir = t.packetCapturer.TestCaseDir(testCase.Description)
+				}
+				stepResult.PacketCaptures = t.packetCapturer.Capture(packetCaptureDir, stepIndex+1, mismatched, t.kubeRunner)
+			}
+		}
 	}
 
 	return result
 }
 
-func (t *Interpreter) runProbe(testCaseState *TestCaseState, probeConfig *generator.ProbeConfig) *StepResult {
+// runProbe compares a kube probe to what the policies would allow, or, for pairs with an expectation, to expected;
+// waivers then override the expected results of the probes they match
+func (t *Interpreter) runProbe(testCaseState *TestCaseState, probeConfig *generator.ProbeConfig, expected *generator.ConnectivityMatrix, waivers []*generator.Waiver) *StepResult {
 	parsedPolicy := matcher.BuildNetworkPolicies(true, testCaseState.Policies)
+	parsedPolicy.AddAdminPolicies(matcher.BuildAdminNetworkPolicies(testCaseState.AdminPolicies))
+	if testCaseState.BaselinePolicy != nil {
+		parsedPolicy.BaselinePolicy = matcher.BuildBaselineAdminNetworkPolicy(testCaseState.BaselinePolicy)
+	}
 
 	logrus.Infof("running probe %+v", probeConfig)
 	logrus.Debugf("with resources:\n%s", testCaseState.Resources.RenderTable())
 
 	simRunner := probe.NewSimulatedRunner(parsedPolicy)
+	if t.skipIgnoredJobs {
+		simRunner.Exclude = t.ignoredJobs
+	}
+	simulated := simRunner.RunProbeForConfig(probeConfig, testCaseState.Resources)
+	if expected != nil {
+		simulated = simulated.WithExpectations(expected)
+	}
 
 	stepResult := NewStepResult(
-		simRunner.RunProbeForConfig(probeConfig, testCaseState.Resources),
+		simulated,
 		parsedPolicy,
 		append([]*networkingv1.NetworkPolicy{}, testCaseState.Policies...)) // this looks weird, but just making a new copy to avoid accidentally mutating it elsewhere
+	stepResult.IgnoredJobs = t.ignoredJobs
+	stepResult.Resources = testCaseState.Resources
+	if len(waivers) > 0 {
+		stepResult.WaivedProbe = simulated.WithWaivers(waivers)
+	}
 
-	for i := 0; i <= t.kubeProbeRetries; i++ {
+	for _, kubeProbe := range t.runKubeProbes(probeConfig, testCaseState.Resources, stepResult.ExpectedProbe()) {
+		stepResult.AddKubeProbe(kubeProbe)
+	}
+
+	if t.crossModeCheck {
+		t.runCrossModeProbes(testCaseState, probeConfig, stepResult)
+	}
+
+	if len(t.secondaryNetworks) > 0 {
+		t.runNetworkProbes(testCaseState, probeConfig, stepResult)
+	}
+
+	if t.dualStack {
+		t.runFamilyProbes(testCaseState, simRunner, probeConfig, expected, waivers, stepResult)
+	}
+
+	if t.routePort != 0 {
+		t.runRouteProbe(testCaseState, stepResult)
+	}
+
+	if t.egressPath != nil {
+		t.runEgressPathProbe(testCaseState, parsedPolicy, stepResult)
+	}
+
+	if t.dnsCheck != nil {
+		t.runDNSCheck(testCaseState, parsedPolicy, stepResult)
+	}
+
+	if t.corroborator != nil {
+		t.runCorroborator(testCaseState, stepResult)
+	}
+
+	return stepResult
+}
+
+// runKubeProbes runs a kube probe, and re-runs it -- backing off in between -- until its results match simulated, or
+// retries run out; it returns the probe of every try
+func (t *Interpreter) runKubeProbes(probeConfig *generator.ProbeConfig, resources *probe.Resources, simulated *probe.Table) []*probe.Table {
+	var kubeProbes []*probe.Table
+	for i := 0; i <= t.kubeProbeRetryPolicy.Retries; i++ {
+		if backoff := t.kubeProbeRetryPolicy.BackoffForRetry(i); backoff > 0 {
+			logrus.Infof("waiting %s before retrying kube probe", backoff)
+			if err := utils.Sleep(t.ctx, backoff); err != nil {
+				logrus.Warnf("not retrying kube probe: %+v", err)
+				break
+			}
+		}
 		logrus.Infof("running kube probe on try %d", i+1)
-		stepResult.AddKubeProbe(t.kubeRunner.RunProbeForConfig(probeConfig, testCaseState.Resources))
+		kubeProbe := t.kubeRunner.RunProbeForConfig(probeConfig, resources)
+		kubeProbes = append(kubeProbes, kubeProbe)
 		// no differences between synthetic and kube probes?  then we can stop
-		if stepResult.LastComparison().ValueCounts(t.ignoreLoopback)[DifferentComparison] == 0 {
+		comparison := NewComparisonTableFrom(kubeProbe.Without(t.ignoredJobs), simulated.Without(t.ignoredJobs))
+		if comparison.ValueCounts(t.ignoreLoopback)[DifferentComparison] == 0 {
 			break
 		}
 	}
+	return kubeProbes
+}
 
-	return stepResult
+// runWarmUp runs a single exchange for each job of any step's probe, ignoring the results: nothing's been perturbed
+// yet, so this only gets the data path between each pair going.  Steps may probe different ports, protocols or
+// modes, so each step's jobs are warmed up, but jobs shared by several steps only once.
+func (t *Interpreter) runWarmUp(testCaseState *TestCaseState, steps []*generator.TestStep) {
+	var jobs []*probe.Job
+	seen := map[string]bool{}
+	for _, step := range steps {
+		warmUpConfig := &generator.ProbeConfig{AllAvailable: step.Probe.AllAvailable, PortProtocol: step.Probe.PortProtocol, Mode: step.Probe.Mode}
+		for _, job := range testCaseState.Resources.GetJobsForProbeConfig(warmUpConfig).Valid {
+			// the same pair, port and protocol is a different job when probed by another host, i.e. by service IP
+			key := job.Key() + "/" + job.ToHost
+			if !seen[key] {
+				seen[key] = true
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	logrus.Infof("warming up %d pairs", len(jobs))
+	results := t.kubeRunner.RunJobs(&probe.Jobs{Valid: jobs})
+	connected := 0
+	for _, jobResult := range results {
+		if jobResult.Combined == probe.ConnectivityAllowed {
+			connected++
+		}
+	}
+	logrus.Infof("warm-up: %d of %d pairs connected", connected, len(results))
+}
+
+// runCrossModeProbes probes the same step by both pod IP and service IP, so that discrepancies between the two --
+// which the simulation can't see, since it always says they're the same -- can be reported.  The step's own probe
+// is reused for whichever of the two modes it was run with.
+func (t *Interpreter) runCrossModeProbes(testCaseState *TestCaseState, probeConfig *generator.ProbeConfig, stepResult *StepResult) {
+	stepResult.CrossModeProbes = map[generator.ProbeMode]*probe.Table{}
+	for _, mode := range []generator.ProbeMode{generator.ProbeModePodIP, generator.ProbeModeServiceIP} {
+		if mode == probeConfig.Mode {
+			stepResult.CrossModeProbes[mode] = stepResult.LastKubeProbe()
+			continue
+		}
+		logrus.Infof("running cross-mode kube probe with destination type %s", mode)
+		crossModeConfig := &generator.ProbeConfig{AllAvailable: probeConfig.AllAvailable, PortProtocol: probeConfig.PortProtocol, Mode: mode, Exchanges: probeConfig.Exchanges}
+		stepResult.CrossModeProbes[mode] = t.kubeRunner.RunProbeForConfig(crossModeConfig, testCaseState.Resources)
+	}
+}
+
+// runNetworkProbes probes the step over each secondary network, by the pods' IPs on that network.  A network which
+// some pods -- i.e. ones created by the test case -- aren't attached to is skipped.
+func (t *Interpreter) runNetworkProbes(testCaseState *TestCaseState, probeConfig *generator.ProbeConfig, stepResult *StepResult) {
+	stepResult.NetworkProbes = map[string]*probe.Table{}
+	networkConfig := &generator.ProbeConfig{AllAvailable: probeConfig.AllAvailable, PortProtocol: probeConfig.PortProtocol, Mode: generator.ProbeModePodIP, Exchanges: probeConfig.Exchanges}
+	for _, network := range t.secondaryNetworks {
+		networkResources, err := testCaseState.Resources.ForSecondaryNetwork(network)
+		if err != nil {
+			logrus.Warnf("skipping probe over secondary network %s: %+v", network, err)
+			continue
+		}
+		logrus.Infof("running kube probe over secondary network %s", network)
+		stepResult.NetworkProbes[network] = t.kubeRunner.RunProbeForConfig(networkConfig, networkResources)
+	}
+}
+
+// runFamilyProbes probes the step by pod IP over each IP family, comparing each to a simulation using the pods'
+// addresses of that family.  The step's own probe is reused for the pods' primary family, if it was by pod IP.  It's
+// skipped if some pods -- i.e. ones created by the test case -- don't have both IPv4 and IPv6 addresses.
+func (t *Interpreter) runFamilyProbes(testCaseState *TestCaseState, simRunner *probe.Runner, probeConfig *generator.ProbeConfig, expected *generator.ConnectivityMatrix, waivers []*generator.Waiver, stepResult *StepResult) {
+	families := testCaseState.Resources.IPFamilies()
+	if len(families) < 2 {
+		logrus.Warnf("skipping dual-stack probes: not all pods have both IPv4 and IPv6 addresses")
+		return
+	}
+	stepResult.FamilyProbes = map[v1.IPFamily]*FamilyProbe{}
+	familyConfig := &generator.ProbeConfig{AllAvailable: probeConfig.AllAvailable, PortProtocol: probeConfig.PortProtocol, Mode: generator.ProbeModePodIP, Exchanges: probeConfig.Exchanges}
+	for _, family := range families {
+		if probeConfig.Mode == generator.ProbeModePodIP && testCaseState.Resources.IsPrimaryIPFamily(family) {
+			stepResult.FamilyProbes[family] = &FamilyProbe{SimulatedProbe: stepResult.ExpectedProbe(), KubeProbes: stepResult.KubeProbes}
+			continue
+		}
+		familyResources, err := testCaseState.Resources.ForIPFamily(family)
+		if err != nil {
+			logrus.Warnf("skipping %s probe: %+v", family, err)
+			continue
+		}
+		simulated := simRunner.RunProbeForConfig(familyConfig, familyResources)
+		if expected != nil {
+			simulated = simulated.WithExpectations(expected)
+		}
+		if len(waivers) > 0 {
+			simulated = simulated.WithWaivers(waivers)
+		}
+		logrus.Infof("running %s kube probe", family)
+		stepResult.FamilyProbes[family] = &FamilyProbe{
+			SimulatedProbe: simulated,
+			KubeProbes:     t.runKubeProbes(familyConfig, familyResources, simulated),
+		}
+	}
+}
+
+// runRouteProbe probes every pod through its OpenShift Route, as an external destination: traffic goes by way of the
+// cluster's ingress routers, so the results are reported but not verified.  It's skipped if some pods -- i.e. ones
+// created by the test case -- don't have Routes.
+func (t *Interpreter) runRouteProbe(testCaseState *TestCaseState, stepResult *StepResult) {
+	if !testCaseState.Resources.HasRoutes() {
+		logrus.Warnf("skipping route probe: not all pods have routes")
+		return
+	}
+	logrus.Infof("running kube probe through routes on port %d", t.routePort)
+	routeConfig := generator.NewProbeConfig(intstr.FromInt(t.routePort), v1.ProtocolTCP, generator.ProbeModeRoute)
+	stepResult.RouteProbe = t.kubeRunner.RunProbeForConfig(routeConfig, testCaseState.Resources)
+}
+
+// runEgressPathProbe probes the egress path from every pod.  If the path's first hop is an IP, each result is
+// checked against what the policies allow for egress to that IP -- and, if the path is required, differences fail the
+// test case; otherwise, results are reported but not verified.
+func (t *Interpreter) runEgressPathProbe(testCaseState *TestCaseState, parsedPolicy *matcher.Policy, stepResult *StepResult) {
+	logrus.Infof("running kube probe of egress path %s", t.egressPath.String())
+	jobs := t.egressPath.Jobs(testCaseState.Resources)
+	stepResult.EgressPathRequired = t.egressPath.Required && t.egressPath.IsVerifiable()
+	for _, jobResult := range t.egressPathRunner.RunJobs(&probe.Jobs{Valid: jobs}) {
+		result := &EgressPathResult{JobResult: jobResult}
+		if t.egressPath.IsVerifiable() {
+			result.Expected = probe.ConnectivityBlocked
+			if parsedPolicy.IsTrafficAllowed(t.egressPath.Traffic(jobResult.Job)).IsAllowed() {
+				result.Expected = probe.ConnectivityAllowed
+			}
+		}
+		stepResult.EgressPathResults = append(stepResult.EgressPathResults, result)
+	}
+	sort.Slice(stepResult.EgressPathResults, func(i, j int) bool {
+		return stepResult.EgressPathResults[i].JobResult.Job.FromKey < stepResult.EgressPathResults[j].JobResult.Job.FromKey
+	})
+}
+
+// runDNSCheck looks up a name from every pod, and checks each lookup against what the policies allow for egress to
+// the cluster's DNS pods
+func (t *Interpreter) runDNSCheck(testCaseState *TestCaseState, parsedPolicy *matcher.Policy, stepResult *StepResult) {
+	logrus.Infof("running kube probe of DNS lookups")
+	jobs := t.dnsCheck.Jobs(testCaseState.Resources)
+	for _, jobResult := range t.dnsRunner.RunJobs(&probe.Jobs{Valid: jobs}) {
+		stepResult.DNSResults = append(stepResult.DNSResults, &DNSResult{
+			JobResult: jobResult,
+			Expected:  t.dnsCheck.Expected(parsedPolicy, jobResult.Job),
+		})
+	}
+	sort.Slice(stepResult.DNSResults, func(i, j int) bool {
+		return stepResult.DNSResults[i].JobResult.Job.FromKey < stepResult.DNSResults[j].JobResult.Job.FromKey
+	})
+}
+
+// runCorroborator reads the dataplane's state once probing is done, and compares it to the policies and to the last
+// kube probe.  Failing to read the dataplane isn't a test failure, so it's only logged.
+func (t *Interpreter) runCorroborator(testCaseState *TestCaseState, stepResult *StepResult) {
+	logrus.Infof("corroborating probe with dataplane state from %s", t.corroborator.Name())
+	isolation, err := t.corroborator.Isolation(testCaseState.Resources)
+	if err != nil {
+		logrus.Warnf("unable to read dataplane state from %s: %+v", t.corroborator.Name(), err)
+		return
+	}
+	stepResult.CorroboratedBy = t.corroborator.Name()
+	stepResult.CorroborationFindings = Corroborate(isolation, testCaseState.Resources, stepResult, t.ignoreLoopback)
 }
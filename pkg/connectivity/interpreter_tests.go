@@ -0,0 +1,50 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"net/url"
+)
+
+func RunInterpreterTests() {
+	Describe("NewInterpreter", func() {
+		It("should return an error instead of panicking when the egress path probes can't be set up", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+			target, err := url.Parse("http://www.example.com")
+			Expect(err).To(Succeed())
+			// the gateway ends up in a client command template, which it breaks
+			_, err = NewInterpreter(kubernetes, resources, &InterpreterConfig{EgressPath: &probe.EgressPath{Target: target, Gateway: "{{"}})
+			Expect(err).NotTo(Succeed())
+		})
+	})
+	Describe("Warm-up", func() {
+		It("should warm up the probes of every step, not just the first", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP}, 5, false, nil)
+			Expect(err).To(Succeed())
+			recording := probe.NewProbeRecording()
+			interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true, WarmUp: true, ProbeRecording: recording})
+			Expect(err).To(Succeed())
+
+			tcp := generator.NewProbeConfig(intstr.FromInt(80), v1.ProtocolTCP, generator.ProbeModePodIP)
+			udp := generator.NewProbeConfig(intstr.FromInt(80), v1.ProtocolUDP, generator.ProbeModePodIP)
+			testCase := generator.NewTestCase("tcp, then udp, then tcp again", generator.NewStringSet(),
+				generator.NewTestStep(tcp), generator.NewTestStep(udp), generator.NewTestStep(tcp))
+			Expect(interpreter.ExecuteTestCase(testCase).Err).To(Succeed())
+
+			// 4 pairs: each protocol is warmed up once, then probed by its steps
+			counts := map[v1.Protocol]int{}
+			for _, result := range recording.Results {
+				counts[result.Job.Protocol]++
+			}
+			Expect(counts).To(Equal(map[v1.Protocol]int{v1.ProtocolTCP: 4 * 3, v1.ProtocolUDP: 4 * 2}))
+		})
+	})
+}
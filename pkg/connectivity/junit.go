@@ -0,0 +1,122 @@
+package connectivity
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"strings"
+)
+
+// JUnitTestSuites is a JUnit XML report, in the shape Jenkins, Prow and GitLab read: one suite, with one test case
+// per cyclonus test case
+type JUnitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Errors   int               `xml:"errors,attr"`
+	Time     float64           `xml:"time,attr"`
+	Suites   []*JUnitTestSuite `xml:"testsuite"`
+}
+
+type JUnitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Errors    int              `xml:"errors,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      float64          `xml:"time,attr"`
+	TestCases []*JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	Error     *JUnitFailure `xml:"error,omitempty"`
+	Skipped   *JUnitSkipped `xml:"skipped,omitempty"`
+}
+
+// JUnitFailure is used for both failures -- wrong results -- and errors, i.e. the test case couldn't be set up
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Details string `xml:",chardata"`
+}
+
+type JUnitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// JUnitReport reports each test case as passed; failed, with the wrong results of each step; errored, with the
+// error; or skipped, if it was interrupted before any results were wrong.  Names are numbered, since descriptions
+// aren't unique.
+func (c *CombinedResults) JUnitReport(suiteName string, ignoreLoopback bool) *JUnitTestSuites {
+	suite := &JUnitTestSuite{Name: suiteName}
+	for i, result := range c.Results {
+		testCase := &JUnitTestCase{
+			Name:      fmt.Sprintf("%d: %s", i+1, result.TestCase.Description),
+			Classname: suiteName,
+			Time:      result.Timing.Total.Seconds(),
+		}
+		switch {
+		case result.Waived(ignoreLoopback):
+			testCase.Skipped = &JUnitSkipped{Message: fmt.Sprintf("failure waived: %s", result.TestCase.Waiver)}
+			suite.Skipped++
+		case result.Err != nil:
+			testCase.Error = &JUnitFailure{
+				Message: result.Err.Error(),
+				Type:    string(ClassOfError(result.Err)),
+				Details: fmt.Sprintf("%+v", result.Err),
+			}
+			suite.Errors++
+		case !result.Passed(ignoreLoopback):
+			testCase.Failure = &JUnitFailure{
+				Message: "probe results differ from expected results",
+				Type:    string(FailureClassVerification),
+				Details: junitFailureDiff(result, ignoreLoopback),
+			}
+			suite.Failures++
+		case result.Interrupted:
+			testCase.Skipped = &JUnitSkipped{Message: fmt.Sprintf("interrupted after %d of %d steps", len(result.Steps), len(result.TestCase.Steps))}
+			suite.Skipped++
+		}
+		suite.Tests++
+		suite.Time += testCase.Time
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	return &JUnitTestSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suites:   []*JUnitTestSuite{suite},
+	}
+}
+
+// junitFailureDiff shows, for each step with wrong results, actual vs. expected for just the sources and
+// destinations with mismatches
+func junitFailureDiff(result *Result, ignoreLoopback bool) string {
+	str := &strings.Builder{}
+	for i, step := range result.Steps {
+		comparison := step.LastComparison()
+		counts := comparison.ValueCounts(ignoreLoopback)
+		if counts[DifferentComparison] == 0 {
+			continue
+		}
+		froms, tos := comparison.MismatchedFromsAndTos(ignoreLoopback)
+		str.WriteString(fmt.Sprintf("step %d: %d wrong, %d ignored, %d correct, after %d tries\n", i+1, counts[DifferentComparison], counts[IgnoredComparison], counts[SameComparison], len(step.KubeProbes)))
+		str.WriteString(fmt.Sprintf("actual vs expected (last round):\n%s\n", comparison.Restrict(froms, tos).RenderSuccessTable()))
+	}
+	return str.String()
+}
+
+func (j *JUnitTestSuites) Write(path string) error {
+	bytes, err := xml.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal junit report")
+	}
+	bytes = append([]byte(xml.Header), bytes...)
+	return errors.Wrapf(ioutil.WriteFile(path, bytes, 0644), "unable to write junit report to %s", path)
+}
@@ -0,0 +1,98 @@
+package connectivity
+
+import (
+	"encoding/xml"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"time"
+)
+
+func RunJUnitTests() {
+	Describe("JUnit report", func() {
+		stepResult := func(blocked string) *StepResult {
+			items := []string{"x/a", "y/a"}
+			kubeProbe, simulatedProbe := probe.NewTable(items), probe.NewTable(items)
+			for _, fr := range items {
+				for _, to := range items {
+					job := &probe.Job{FromKey: fr, ToKey: to, Protocol: v1.ProtocolTCP, ResolvedPort: 80}
+					kubeConnectivity := probe.ConnectivityAllowed
+					if fr+" -> "+to == blocked {
+						kubeConnectivity = probe.ConnectivityBlocked
+					}
+					Expect(kubeProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: kubeConnectivity})).To(Succeed())
+					Expect(simulatedProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: probe.ConnectivityAllowed})).To(Succeed())
+				}
+			}
+			step := NewStepResult(simulatedProbe, nil, nil)
+			step.AddKubeProbe(kubeProbe)
+			return step
+		}
+		testCase := func(description string, steps int) *generator.TestCase {
+			var testSteps []*generator.TestStep
+			for i := 0; i < steps; i++ {
+				testSteps = append(testSteps, generator.NewTestStep(generator.ProbeAllAvailable))
+			}
+			return generator.NewTestCase(description, generator.NewStringSet(generator.TagIngress), testSteps...)
+		}
+
+		It("should report passed, failed, errored and interrupted test cases", func() {
+			results := &CombinedResults{Results: []*Result{
+				{TestCase: testCase("passes", 1), Steps: []*StepResult{stepResult("")}, Timing: TestCaseTiming{Total: 2 * time.Second}},
+				{TestCase: testCase("fails", 2), Steps: []*StepResult{stepResult(""), stepResult("y/a -> x/a")}, Timing: TestCaseTiming{Total: 3 * time.Second}},
+				{TestCase: testCase("errors", 1), Err: NewSetupInvalidError(errors.Errorf("policy rejected"))},
+				{TestCase: testCase("interrupted", 2), Steps: []*StepResult{stepResult("")}, Interrupted: true},
+			}}
+
+			report := results.JUnitReport("cyclonus generate", true)
+			Expect(report.Tests).To(Equal(4))
+			Expect(report.Failures).To(Equal(1))
+			Expect(report.Errors).To(Equal(1))
+			Expect(report.Time).To(Equal(5.0))
+
+			suite := report.Suites[0]
+			Expect(suite.Skipped).To(Equal(1))
+			Expect(suite.TestCases[0]).To(Equal(&JUnitTestCase{Name: "1: passes", Classname: "cyclonus generate", Time: 2}))
+
+			failure := suite.TestCases[1].Failure
+			Expect(failure.Type).To(Equal(string(FailureClassVerification)))
+			Expect(failure.Details).To(HavePrefix("step 2: 1 wrong, 2 ignored, 1 correct, after 1 tries\n"))
+			Expect(failure.Details).NotTo(ContainSubstring("step 1"))
+
+			Expect(suite.TestCases[2].Error.Type).To(Equal(string(FailureClassSetupInvalid)))
+			Expect(suite.TestCases[2].Error.Message).To(ContainSubstring("policy rejected"))
+			Expect(suite.TestCases[3].Skipped.Message).To(Equal("interrupted after 1 of 2 steps"))
+
+			bytes, err := xml.Marshal(report)
+			Expect(err).To(Succeed())
+			Expect(string(bytes)).To(HavePrefix(`<testsuites tests="4" failures="1" errors="1" time="5"><testsuite name="cyclonus generate"`))
+		})
+
+		It("should report waived failures as skipped, and leave them out of the failures in the summary", func() {
+			waived := testCase("waived", 1)
+			waived.Waiver = "example-cni: known to fail"
+			waivedButPasses := testCase("waived but passes", 1)
+			waivedButPasses.Waiver = "example-cni: known to fail"
+			results := &CombinedResults{Results: []*Result{
+				{TestCase: waived, Steps: []*StepResult{stepResult("y/a -> x/a")}},
+				{TestCase: waivedButPasses, Steps: []*StepResult{stepResult("")}},
+			}}
+
+			suite := results.JUnitReport("cyclonus generate", true).Suites[0]
+			Expect(suite.Failures).To(Equal(0))
+			Expect(suite.Skipped).To(Equal(1))
+			Expect(suite.TestCases[0].Skipped.Message).To(Equal("failure waived: example-cni: known to fail"))
+			Expect(suite.TestCases[1].Skipped).To(BeNil())
+
+			summary := results.Summary(true)
+			Expect(summary.Passed).To(Equal(1))
+			Expect(summary.Failed).To(Equal(0))
+			Expect(summary.Waived).To(Equal(map[string]int{"example-cni: known to fail": 1}))
+			Expect(summary.FailureClassCounts).To(BeEmpty())
+			Expect(results.ResultsDocument(true).Tests[0].Waiver).To(Equal("example-cni: known to fail"))
+		})
+	})
+}
@@ -0,0 +1,170 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+const (
+	LeftoverModeWarn  = "warn"
+	LeftoverModeFail  = "fail"
+	LeftoverModeClean = "clean"
+)
+
+var AllLeftoverModes = []string{LeftoverModeWarn, LeftoverModeFail, LeftoverModeClean}
+
+// LeftoverResources are things found in the cluster, probably from an earlier interrupted run, that would
+// interfere with a run against the given fixture namespaces and pods
+type LeftoverResources struct {
+	// NetworkPolicies are namespace/name strings
+	NetworkPolicies []string
+	// Pods are namespace/name strings, in fixture namespaces, that aren't part of the fixture
+	Pods []probe.PodString
+	// Namespaces are namespaces that cyclonus created, but which aren't part of the fixture
+	Namespaces []string
+}
+
+func FindLeftoverResources(kubernetes kube.IKubernetes, namespaces []string, podNames []string) (*LeftoverResources, error) {
+	leftovers := &LeftoverResources{}
+
+	fixtureNamespaces := map[string]bool{}
+	for _, ns := range namespaces {
+		fixtureNamespaces[ns] = true
+	}
+	fixturePods := map[string]bool{}
+	for _, podName := range podNames {
+		fixturePods[podName] = true
+	}
+
+	nsList, err := kubernetes.GetAllNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	var namespacesToCheck []string
+	for _, ns := range nsList.Items {
+		if fixtureNamespaces[ns.Name] {
+			namespacesToCheck = append(namespacesToCheck, ns.Name)
+		} else if ns.Annotations[probe.ManagedByAnnotation] == probe.ManagedByValue {
+			leftovers.Namespaces = append(leftovers.Namespaces, ns.Name)
+			namespacesToCheck = append(namespacesToCheck, ns.Name)
+		}
+	}
+	sort.Strings(leftovers.Namespaces)
+
+	policies, err := kube.GetNetworkPoliciesInNamespaces(kubernetes, namespacesToCheck)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		leftovers.NetworkPolicies = append(leftovers.NetworkPolicies, fmt.Sprintf("%s/%s", policy.Namespace, policy.Name))
+	}
+	sort.Strings(leftovers.NetworkPolicies)
+
+	for _, ns := range namespacesToCheck {
+		if !fixtureNamespaces[ns] {
+			// the whole namespace is already a leftover
+			continue
+		}
+		pods, err := kubernetes.GetPodsInNamespace(ns)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			if !fixturePods[pod.Name] {
+				leftovers.Pods = append(leftovers.Pods, probe.NewPodString(pod.Namespace, pod.Name))
+			}
+		}
+	}
+
+	return leftovers, nil
+}
+
+func (l *LeftoverResources) IsEmpty() bool {
+	return len(l.NetworkPolicies) == 0 && len(l.Pods) == 0 && len(l.Namespaces) == 0
+}
+
+func (l *LeftoverResources) String() string {
+	var lines []string
+	for _, policy := range l.NetworkPolicies {
+		lines = append(lines, fmt.Sprintf(" - network policy %s", policy))
+	}
+	for _, pod := range l.Pods {
+		lines = append(lines, fmt.Sprintf(" - pod %s", pod.String()))
+	}
+	for _, ns := range l.Namespaces {
+		lines = append(lines, fmt.Sprintf(" - namespace %s", ns))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Clean deletes the leftovers: policies first, then extra pods and their services, then extra namespaces
+func (l *LeftoverResources) Clean(kubernetes kube.IKubernetes) error {
+	for _, policy := range l.NetworkPolicies {
+		pieces := strings.SplitN(policy, "/", 2)
+		logrus.Infof("deleting leftover network policy %s", policy)
+		if err := kubernetes.DeleteNetworkPolicy(pieces[0], pieces[1]); err != nil {
+			return err
+		}
+	}
+	for _, podString := range l.Pods {
+		ns, name := podString.Namespace(), podString.PodName()
+		logrus.Infof("deleting leftover pod %s", podString.String())
+		if err := kubernetes.DeletePod(ns, name); err != nil {
+			return err
+		}
+		serviceName := (&probe.Pod{Namespace: ns, Name: name}).ServiceName()
+		if _, err := kubernetes.GetService(ns, serviceName); err == nil {
+			if err := kubernetes.DeleteService(ns, serviceName); err != nil {
+				return err
+			}
+		}
+	}
+	for _, ns := range l.Namespaces {
+		logrus.Infof("deleting leftover namespace %s", ns)
+		if err := kubernetes.DeleteNamespace(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateLeftoverMode checks that mode is one of AllLeftoverModes
+func ValidateLeftoverMode(mode string) error {
+	if mode != LeftoverModeWarn && mode != LeftoverModeFail && mode != LeftoverModeClean {
+		return errors.Errorf("invalid leftover resources mode '%s'; must be one of %+v", mode, AllLeftoverModes)
+	}
+	return nil
+}
+
+// HandleLeftoverResources checks for leftovers, and then warns, fails, or cleans up depending on mode
+func HandleLeftoverResources(kubernetes kube.IKubernetes, namespaces []string, podNames []string, mode string) error {
+	if err := ValidateLeftoverMode(mode); err != nil {
+		return err
+	}
+
+	leftovers, err := FindLeftoverResources(kubernetes, namespaces, podNames)
+	if err != nil {
+		return err
+	}
+	if leftovers.IsEmpty() {
+		return nil
+	}
+
+	switch mode {
+	case LeftoverModeWarn:
+		logrus.Warnf("found leftover resources from a previous run, which may skew results:\n%s", leftovers.String())
+		return nil
+	case LeftoverModeFail:
+		return errors.Errorf("found leftover resources from a previous run:\n%s", leftovers.String())
+	case LeftoverModeClean:
+		logrus.Infof("cleaning up leftover resources from a previous run:\n%s", leftovers.String())
+		return leftovers.Clean(kubernetes)
+	default:
+		panic(errors.Errorf("unreachable: invalid leftover resources mode '%s'", mode))
+	}
+}
@@ -0,0 +1,67 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func RunLeftoverResourcesTests() {
+	Describe("LeftoverResources", func() {
+		setup := func() *kube.MockKubernetes {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			for _, ns := range []string{"x", "y-2"} {
+				_, err := kubernetes.CreateNamespace(probe.KubeNamespace(ns, map[string]string{"ns": ns}))
+				Expect(err).To(Succeed())
+			}
+			_, err := kubernetes.CreateNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unrelated"}})
+			Expect(err).To(Succeed())
+			for _, name := range []string{"a", "d"} {
+				_, err := kubernetes.CreatePod(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: name}})
+				Expect(err).To(Succeed())
+			}
+			_, err = kubernetes.CreateNetworkPolicy(&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "stale"}})
+			Expect(err).To(Succeed())
+			_, err = kubernetes.CreateNetworkPolicy(&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "unrelated", Name: "theirs"}})
+			Expect(err).To(Succeed())
+			return kubernetes
+		}
+
+		It("should find stale policies, extra pods, and extra cyclonus namespaces", func() {
+			leftovers, err := FindLeftoverResources(setup(), []string{"x"}, []string{"a"})
+			Expect(err).To(Succeed())
+
+			Expect(leftovers.NetworkPolicies).To(Equal([]string{"x/stale"}))
+			Expect(leftovers.Pods).To(Equal([]probe.PodString{"x/d"}))
+			Expect(leftovers.Namespaces).To(Equal([]string{"y-2"}))
+		})
+
+		It("should clean up leftovers", func() {
+			kubernetes := setup()
+			Expect(HandleLeftoverResources(kubernetes, []string{"x"}, []string{"a"}, LeftoverModeClean)).To(Succeed())
+
+			leftovers, err := FindLeftoverResources(kubernetes, []string{"x"}, []string{"a"})
+			Expect(err).To(Succeed())
+			Expect(leftovers.IsEmpty()).To(BeTrue())
+
+			policies, err := kubernetes.GetNetworkPoliciesInNamespace("unrelated")
+			Expect(err).To(Succeed())
+			Expect(policies).To(HaveLen(1))
+		})
+
+		It("should fail on leftovers in fail mode", func() {
+			Expect(HandleLeftoverResources(setup(), []string{"x"}, []string{"a"}, LeftoverModeFail)).NotTo(Succeed())
+		})
+
+		It("should reject invalid modes", func() {
+			for _, mode := range AllLeftoverModes {
+				Expect(ValidateLeftoverMode(mode)).To(Succeed())
+			}
+			Expect(ValidateLeftoverMode("ignore")).NotTo(Succeed())
+		})
+	})
+}
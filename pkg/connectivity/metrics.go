@@ -0,0 +1,141 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TestCaseDurationBuckets are the upper bounds, in seconds, of the test case duration histogram's buckets
+var TestCaseDurationBuckets = []float64{5, 10, 30, 60, 120, 300, 600, 1800}
+
+// Metrics counts what a generate run has done so far, for scraping by Prometheus during long runs.  It's written
+// in Prometheus's text format by hand, rather than pulling in a client library for a handful of counters.
+type Metrics struct {
+	lock             sync.Mutex
+	testCasesPlanned int
+	testCases        int
+	failures         map[FailureClass]int
+	probes           int
+	wrongProbes      int
+	kubeAPIErrors    map[string]int
+	durationBuckets  []int
+	durationCount    int
+	durationSum      float64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		failures:        map[FailureClass]int{},
+		kubeAPIErrors:   map[string]int{},
+		durationBuckets: make([]int, len(TestCaseDurationBuckets)),
+	}
+}
+
+func (m *Metrics) SetTestCasesPlanned(count int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.testCasesPlanned = count
+}
+
+// RecordResult counts a finished test case, its probes across every try of every step, and its duration
+func (m *Metrics) RecordResult(result *Result, ignoreLoopback bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.testCases++
+	if !result.Passed(ignoreLoopback) {
+		m.failures[result.FailureClass(ignoreLoopback)]++
+	}
+	for _, step := range result.Steps {
+		for _, kubeProbe := range step.KubeProbes {
+			m.probes += kubeProbe.CountJobResults()
+		}
+		if len(step.KubeProbes) > 0 {
+			m.wrongProbes += step.LastComparison().ValueCounts(ignoreLoopback)[DifferentComparison]
+		}
+	}
+	seconds := result.Timing.Total.Seconds()
+	for i, bound := range TestCaseDurationBuckets {
+		if seconds <= bound {
+			m.durationBuckets[i]++
+		}
+	}
+	m.durationCount++
+	m.durationSum += seconds
+}
+
+// RecordKubeAPIError counts a failed kube API call, by whether the API server was throttling
+func (m *Metrics) RecordKubeAPIError(err error) {
+	reason := "other"
+	if kube.IsThrottlingError(err) {
+		reason = "throttled"
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.kubeAPIErrors[reason]++
+}
+
+func writeMetric(w io.Writer, name string, metricType string, help string, samples map[string]float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+	var keys []string
+	for key := range samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %v\n", name, key, samples[key])
+	}
+}
+
+func labelsFor(counts map[string]int, label string) map[string]float64 {
+	samples := map[string]float64{}
+	for value, count := range counts {
+		samples[fmt.Sprintf(`{%s="%s"}`, label, value)] = float64(count)
+	}
+	return samples
+}
+
+// Write writes the metrics in Prometheus's text exposition format
+func (m *Metrics) Write(w io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	writeMetric(w, "cyclonus_test_cases_planned", "gauge", "Number of test cases the run will execute.",
+		map[string]float64{"": float64(m.testCasesPlanned)})
+	writeMetric(w, "cyclonus_test_cases_total", "counter", "Number of test cases executed.",
+		map[string]float64{"": float64(m.testCases)})
+	failures := map[string]int{}
+	for class, count := range m.failures {
+		failures[string(class)] = count
+	}
+	writeMetric(w, "cyclonus_test_case_failures_total", "counter", "Number of test cases which failed, by failure class.",
+		labelsFor(failures, "class"))
+	writeMetric(w, "cyclonus_probes_total", "counter", "Number of probes run, across every try of every step.",
+		map[string]float64{"": float64(m.probes)})
+	writeMetric(w, "cyclonus_wrong_probes_total", "counter", "Number of probes whose last try differed from the expected result.",
+		map[string]float64{"": float64(m.wrongProbes)})
+	writeMetric(w, "cyclonus_kube_api_errors_total", "counter", "Number of failed kube API calls, by whether the API server was throttling.",
+		labelsFor(m.kubeAPIErrors, "reason"))
+
+	name := "cyclonus_test_case_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Duration of each test case.\n# TYPE %s histogram\n", name, name)
+	for i, bound := range TestCaseDurationBuckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%v\"} %d\n", name, bound, m.durationBuckets[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %v\n%s_count %d\n", name, m.durationCount, name, m.durationSum, name, m.durationCount)
+}
+
+func (m *Metrics) String() string {
+	str := &strings.Builder{}
+	m.Write(str)
+	return str.String()
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.Write(w)
+}
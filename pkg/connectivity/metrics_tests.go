@@ -0,0 +1,66 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"net/http/httptest"
+	"time"
+)
+
+func RunMetricsTests() {
+	Describe("Metrics", func() {
+		stepResult := func(wrong bool) *StepResult {
+			items := []string{"x/a", "y/a"}
+			kubeProbe, simulatedProbe := probe.NewTable(items), probe.NewTable(items)
+			for _, fr := range items {
+				for _, to := range items {
+					job := &probe.Job{FromKey: fr, ToKey: to, Protocol: v1.ProtocolTCP, ResolvedPort: 80}
+					kubeConnectivity := probe.ConnectivityAllowed
+					if wrong && fr == "y/a" && to == "x/a" {
+						kubeConnectivity = probe.ConnectivityBlocked
+					}
+					Expect(kubeProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: kubeConnectivity})).To(Succeed())
+					Expect(simulatedProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: probe.ConnectivityAllowed})).To(Succeed())
+				}
+			}
+			step := NewStepResult(simulatedProbe, nil, nil)
+			step.AddKubeProbe(kubeProbe)
+			return step
+		}
+
+		It("should count test cases, failures, probes and kube API errors", func() {
+			metrics := NewMetrics()
+			metrics.SetTestCasesPlanned(3)
+			metrics.RecordResult(&Result{Steps: []*StepResult{stepResult(false)}, Timing: TestCaseTiming{Total: 7 * time.Second}}, true)
+			metrics.RecordResult(&Result{Steps: []*StepResult{stepResult(true)}, Timing: TestCaseTiming{Total: 70 * time.Second}}, true)
+			metrics.RecordKubeAPIError(kerrors.NewTooManyRequests("slow down", 0))
+			metrics.RecordKubeAPIError(errors.Errorf("connection refused"))
+			metrics.RecordKubeAPIError(errors.Errorf("connection refused"))
+
+			text := metrics.String()
+			Expect(text).To(ContainSubstring("cyclonus_test_cases_planned 3\n"))
+			Expect(text).To(ContainSubstring("cyclonus_test_cases_total 2\n"))
+			Expect(text).To(ContainSubstring(`cyclonus_test_case_failures_total{class="verification"} 1` + "\n"))
+			Expect(text).To(ContainSubstring("cyclonus_probes_total 8\n"))
+			Expect(text).To(ContainSubstring("cyclonus_wrong_probes_total 1\n"))
+			Expect(text).To(ContainSubstring(`cyclonus_kube_api_errors_total{reason="other"} 2` + "\n"))
+			Expect(text).To(ContainSubstring(`cyclonus_kube_api_errors_total{reason="throttled"} 1` + "\n"))
+			Expect(text).To(ContainSubstring(`cyclonus_test_case_duration_seconds_bucket{le="5"} 0` + "\n"))
+			Expect(text).To(ContainSubstring(`cyclonus_test_case_duration_seconds_bucket{le="10"} 1` + "\n"))
+			Expect(text).To(ContainSubstring(`cyclonus_test_case_duration_seconds_bucket{le="120"} 2` + "\n"))
+			Expect(text).To(ContainSubstring("cyclonus_test_case_duration_seconds_sum 77\ncyclonus_test_case_duration_seconds_count 2\n"))
+		})
+
+		It("should serve the metrics over http", func() {
+			recorder := httptest.NewRecorder()
+			NewMetrics().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+			Expect(recorder.Code).To(Equal(200))
+			Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+			Expect(recorder.Body.String()).To(ContainSubstring("# TYPE cyclonus_test_cases_total counter\n"))
+		})
+	})
+}
@@ -0,0 +1,220 @@
+package connectivity
+
+import (
+	"context"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	networkingv1 "k8s.io/api/networking/v1"
+	"time"
+)
+
+// Minimizer shrinks the network policies of a failed test case's last step, one reduction at a time -- deleting a
+// policy, or removing a rule, peer or port -- keeping each reduction if the step's mismatched probes are still
+// mismatched against what the reduced policies allow.  Each reduction is applied to the cluster and re-probed, so
+// only the mismatched probes are re-run.
+type Minimizer struct {
+	// MaxAttempts is how many reductions to try, in total
+	MaxAttempts int
+	// PerturbationWait is how long to wait for each reduction to take effect before re-probing
+	PerturbationWait time.Duration
+}
+
+// MinimizedPolicies are what's left of a step's policies after minimizing, along with the reductions that got there
+type MinimizedPolicies struct {
+	Step       int
+	Attempts   int
+	Reductions []string
+	Policies   []*networkingv1.NetworkPolicy
+	// Mismatches are the probes which were still mismatched against the minimized policies
+	Mismatches []string
+	// Error is why minimizing stopped early, if it did; the policies are as small as they got
+	Error string `json:",omitempty"`
+}
+
+// policyReduction replaces the policy at Index with Policy, or deletes it, if Policy is nil
+type policyReduction struct {
+	Description string
+	Index       int
+	Policy      *networkingv1.NetworkPolicy
+}
+
+// policyReductions lists every way to shrink policies by one step, biggest first: deleting a whole policy, then
+// removing a rule, then removing one of a rule's peers or ports.  Removing a rule's only peer or port would widen
+// the rule to all peers or ports, so that's not a reduction.
+func policyReductions(policies []*networkingv1.NetworkPolicy) []*policyReduction {
+	var reductions []*policyReduction
+	for i, policy := range policies {
+		reductions = append(reductions, &policyReduction{Description: fmt.Sprintf("deleted policy %s/%s", policy.Namespace, policy.Name), Index: i})
+	}
+	for i, policy := range policies {
+		for j := range policy.Spec.Ingress {
+			reduced := policy.DeepCopy()
+			reduced.Spec.Ingress = append(reduced.Spec.Ingress[:j], reduced.Spec.Ingress[j+1:]...)
+			reductions = append(reductions, &policyReduction{Description: fmt.Sprintf("removed ingress rule %d of %s/%s", j+1, policy.Namespace, policy.Name), Index: i, Policy: reduced})
+		}
+		for j := range policy.Spec.Egress {
+			reduced := policy.DeepCopy()
+			reduced.Spec.Egress = append(reduced.Spec.Egress[:j], reduced.Spec.Egress[j+1:]...)
+			reductions = append(reductions, &policyReduction{Description: fmt.Sprintf("removed egress rule %d of %s/%s", j+1, policy.Namespace, policy.Name), Index: i, Policy: reduced})
+		}
+	}
+	for i, policy := range policies {
+		for j, rule := range policy.Spec.Ingress {
+			for k := range rule.From {
+				if len(rule.From) > 1 {
+					reduced := policy.DeepCopy()
+					reduced.Spec.Ingress[j].From = append(reduced.Spec.Ingress[j].From[:k], reduced.Spec.Ingress[j].From[k+1:]...)
+					reductions = append(reductions, &policyReduction{Description: fmt.Sprintf("removed peer %d of ingress rule %d of %s/%s", k+1, j+1, policy.Namespace, policy.Name), Index: i, Policy: reduced})
+				}
+			}
+			for k := range rule.Ports {
+				if len(rule.Ports) > 1 {
+					reduced := policy.DeepCopy()
+					reduced.Spec.Ingress[j].Ports = append(reduced.Spec.Ingress[j].Ports[:k], reduced.Spec.Ingress[j].Ports[k+1:]...)
+					reductions = append(reductions, &policyReduction{Description: fmt.Sprintf("removed port %d of ingress rule %d of %s/%s", k+1, j+1, policy.Namespace, policy.Name), Index: i, Policy: reduced})
+				}
+			}
+		}
+		for j, rule := range policy.Spec.Egress {
+			for k := range rule.To {
+				if len(rule.To) > 1 {
+					reduced := policy.DeepCopy()
+					reduced.Spec.Egress[j].To = append(reduced.Spec.Egress[j].To[:k], reduced.Spec.Egress[j].To[k+1:]...)
+					reductions = append(reductions, &policyReduction{Description: fmt.Sprintf("removed peer %d of egress rule %d of %s/%s", k+1, j+1, policy.Namespace, policy.Name), Index: i, Policy: reduced})
+				}
+			}
+			for k := range rule.Ports {
+				if len(rule.Ports) > 1 {
+					reduced := policy.DeepCopy()
+					reduced.Spec.Egress[j].Ports = append(reduced.Spec.Egress[j].Ports[:k], reduced.Spec.Egress[j].Ports[k+1:]...)
+					reductions = append(reductions, &policyReduction{Description: fmt.Sprintf("removed port %d of egress rule %d of %s/%s", k+1, j+1, policy.Namespace, policy.Name), Index: i, Policy: reduced})
+				}
+			}
+		}
+	}
+	return reductions
+}
+
+// Minimize shrinks testCaseState's policies while jobs -- the mismatched probes of the step numbered stepNumber --
+// stay mismatched, then puts the original policies back.  Reductions are tried in order, starting over after each
+// one that's kept, until none can be kept or MaxAttempts run out.
+func (m *Minimizer) Minimize(ctx context.Context, testCaseState *TestCaseState, runner *probe.Runner, stepNumber int, jobs []*probe.Job) *MinimizedPolicies {
+	original := append([]*networkingv1.NetworkPolicy{}, testCaseState.Policies...)
+	minimized := &MinimizedPolicies{Step: stepNumber}
+
+	mismatches, err := m.mismatches(ctx, testCaseState, runner, jobs)
+	if err == nil && len(mismatches) == 0 {
+		err = errors.Errorf("mismatches of step %d didn't reproduce", stepNumber)
+	}
+	progress := err == nil
+	for progress && err == nil {
+		progress = false
+		for _, reduction := range policyReductions(testCaseState.Policies) {
+			if minimized.Attempts >= m.MaxAttempts {
+				logrus.Infof("minimizer: stopping after %d attempts", minimized.Attempts)
+				break
+			}
+			minimized.Attempts++
+			previous := testCaseState.Policies[reduction.Index]
+			if err = applyPolicyReduction(testCaseState, reduction); err != nil {
+				break
+			}
+			var reducedMismatches []string
+			reducedMismatches, err = m.mismatches(ctx, testCaseState, runner, jobs)
+			if err != nil {
+				break
+			}
+			if len(reducedMismatches) > 0 {
+				logrus.Infof("minimizer: %s, and probes are still mismatched", reduction.Description)
+				minimized.Reductions = append(minimized.Reductions, reduction.Description)
+				mismatches = reducedMismatches
+				progress = true
+				break
+			}
+			logrus.Debugf("minimizer: %s, and probes are no longer mismatched; putting it back", reduction.Description)
+			if err = revertPolicyReduction(testCaseState, reduction, previous); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		logrus.Warnf("unable to finish minimizing policies: %+v", err)
+		minimized.Error = err.Error()
+	}
+	minimized.Policies = append([]*networkingv1.NetworkPolicy{}, testCaseState.Policies...)
+	minimized.Mismatches = mismatches
+
+	if err := restorePolicies(testCaseState, original); err != nil {
+		logrus.Warnf("unable to restore policies after minimizing: %+v", err)
+	}
+	return minimized
+}
+
+// mismatches waits for the current policies to take effect, then re-runs jobs and describes each whose result
+// differs from what the policies allow
+func (m *Minimizer) mismatches(ctx context.Context, testCaseState *TestCaseState, runner *probe.Runner, jobs []*probe.Job) ([]string, error) {
+	if err := utils.Sleep(ctx, m.PerturbationWait); err != nil {
+		return nil, err
+	}
+	parsedPolicy := matcher.BuildNetworkPolicies(true, testCaseState.Policies)
+	parsedPolicy.AddAdminPolicies(matcher.BuildAdminNetworkPolicies(testCaseState.AdminPolicies))
+	if testCaseState.BaselinePolicy != nil {
+		parsedPolicy.BaselinePolicy = matcher.BuildBaselineAdminNetworkPolicy(testCaseState.BaselinePolicy)
+	}
+	simulated := &probe.SimulatedJobRunner{Policies: parsedPolicy}
+
+	var mismatches []string
+	for _, kubeResult := range runner.RunJobs(&probe.Jobs{Valid: jobs}) {
+		expected := simulated.RunJob(kubeResult.Job).Combined
+		if kubeResult.Combined != expected {
+			mismatches = append(mismatches, fmt.Sprintf("%s -> %s %s: expected %s, got %s", kubeResult.Job.FromKey, kubeResult.Job.ToKey, kubeResult.Key(), expected, kubeResult.Combined))
+		}
+	}
+	return mismatches, nil
+}
+
+func applyPolicyReduction(testCaseState *TestCaseState, reduction *policyReduction) error {
+	if reduction.Policy == nil {
+		policy := testCaseState.Policies[reduction.Index]
+		return testCaseState.DeletePolicy(policy.Namespace, policy.Name)
+	}
+	return testCaseState.UpdatePolicy(reduction.Policy)
+}
+
+// revertPolicyReduction puts back previous, keeping its place among the policies
+func revertPolicyReduction(testCaseState *TestCaseState, reduction *policyReduction, previous *networkingv1.NetworkPolicy) error {
+	if reduction.Policy != nil {
+		return testCaseState.UpdatePolicy(previous)
+	}
+	if err := testCaseState.CreatePolicy(previous); err != nil {
+		return err
+	}
+	policies := testCaseState.Policies[:len(testCaseState.Policies)-1]
+	testCaseState.Policies = append(policies[:reduction.Index], append([]*networkingv1.NetworkPolicy{previous}, policies[reduction.Index:]...)...)
+	return nil
+}
+
+// restorePolicies makes testCaseState's policies original again, re-creating deleted policies and updating the rest
+func restorePolicies(testCaseState *TestCaseState, original []*networkingv1.NetworkPolicy) error {
+	current := map[string]bool{}
+	for _, policy := range testCaseState.Policies {
+		current[policy.Namespace+"/"+policy.Name] = true
+	}
+	for _, policy := range original {
+		var err error
+		if current[policy.Namespace+"/"+policy.Name] {
+			err = testCaseState.UpdatePolicy(policy)
+		} else {
+			err = testCaseState.CreatePolicy(policy)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	testCaseState.Policies = original
+	return nil
+}
@@ -0,0 +1,101 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func RunMinimizerTests() {
+	Describe("Minimizer", func() {
+		tcp := v1.ProtocolTCP
+		port80, port81 := intstr.FromInt(80), intstr.FromInt(81)
+		peer := func(pod string) networkingv1.NetworkPolicyPeer {
+			return networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pod": pod}}}
+		}
+		rule := networkingv1.NetworkPolicyIngressRule{
+			From:  []networkingv1.NetworkPolicyPeer{peer("b"), peer("c")},
+			Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}, {Protocol: &tcp, Port: &port81}},
+		}
+		policy := &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "two-rules"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{rule, rule},
+			},
+		}
+		testCase := generator.NewSingleStepTestCase("two rules", generator.NewStringSet(generator.TagIngress), generator.ProbeAllAvailable, generator.CreatePolicy(policy))
+
+		var kubernetes *kube.MockKubernetes
+		var resources *probe.Resources
+		BeforeEach(func() {
+			kubernetes = kube.NewMockKubernetes(1.0)
+			var err error
+			resources, err = probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a", "b"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+		})
+
+		It("should shrink policies while their mismatches still reproduce", func() {
+			interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true, Minimizer: &Minimizer{MaxAttempts: 50}})
+			Expect(err).To(Succeed())
+			result := interpreter.ExecuteTestCase(testCase)
+
+			// the mock allows everything: deleting the policy makes the mismatches go away, but removing its rules
+			// doesn't
+			minimized := result.MinimizedPolicies
+			Expect(minimized).ToNot(BeNil())
+			Expect(minimized.Error).To(Equal(""))
+			Expect(minimized.Step).To(Equal(1))
+			Expect(minimized.Reductions).To(Equal([]string{"removed ingress rule 1 of x/two-rules", "removed ingress rule 1 of x/two-rules"}))
+			Expect(minimized.Policies).To(HaveLen(1))
+			Expect(minimized.Policies[0].Spec.Ingress).To(BeEmpty())
+			Expect(minimized.Mismatches).To(ContainElement("y/a -> x/a TCP/80: expected blocked, got allowed"))
+			// deleting the policy is tried before removing each rule, and once more at the end
+			Expect(minimized.Attempts).To(Equal(5))
+
+			// the original policy is put back
+			kubePolicies, err := kubernetes.GetNetworkPoliciesInNamespace("x")
+			Expect(err).To(Succeed())
+			Expect(kubePolicies).To(HaveLen(1))
+			Expect(kubePolicies[0].Spec.Ingress).To(HaveLen(2))
+		})
+
+		It("should stop after MaxAttempts", func() {
+			interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true, Minimizer: &Minimizer{MaxAttempts: 1}})
+			Expect(err).To(Succeed())
+			minimized := interpreter.ExecuteTestCase(testCase).MinimizedPolicies
+			Expect(minimized.Attempts).To(Equal(1))
+			Expect(minimized.Reductions).To(BeEmpty())
+			Expect(minimized.Policies[0].Spec.Ingress).To(HaveLen(2))
+		})
+
+		It("should list reductions of policies, biggest first", func() {
+			var descriptions []string
+			for _, reduction := range policyReductions([]*networkingv1.NetworkPolicy{policy}) {
+				descriptions = append(descriptions, reduction.Description)
+			}
+			Expect(descriptions).To(Equal([]string{
+				"deleted policy x/two-rules",
+				"removed ingress rule 1 of x/two-rules",
+				"removed ingress rule 2 of x/two-rules",
+				"removed peer 1 of ingress rule 1 of x/two-rules",
+				"removed peer 2 of ingress rule 1 of x/two-rules",
+				"removed port 1 of ingress rule 1 of x/two-rules",
+				"removed port 2 of ingress rule 1 of x/two-rules",
+				"removed peer 1 of ingress rule 2 of x/two-rules",
+				"removed peer 2 of ingress rule 2 of x/two-rules",
+				"removed port 1 of ingress rule 2 of x/two-rules",
+				"removed port 2 of ingress rule 2 of x/two-rules",
+			}))
+			// reductions don't touch the original
+			Expect(policy.Spec.Ingress).To(HaveLen(2))
+			Expect(policy.Spec.Ingress[0].From).To(HaveLen(2))
+		})
+	})
+}
@@ -0,0 +1,175 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PacketCaptureContainerName = "cyclonus-capture"
+	DefaultPacketCaptureImage  = "docker.io/nicolaka/netshoot:v0.11"
+)
+
+// PacketCapturer re-runs mismatched probes one at a time, with tcpdump running in the source and destination pods --
+// in ephemeral containers, since the pods' own images can't be assumed to have it -- so that it's possible to tell
+// whether packets were dropped on the way out of the source, or on the way into the destination.  Captures are saved
+// to a directory per test case under Dir.
+type PacketCapturer struct {
+	Kubernetes kube.IKubernetes
+	Dir        string
+	// Image must have tcpdump and timeout
+	Image string
+	// Seconds is how long tcpdump runs for, around the re-run probe
+	Seconds int
+	// MaxPerStep is how many of a step's mismatched probes to capture; the rest aren't
+	MaxPerStep int
+	// ContainerTimeoutSeconds is how long to wait for the capture containers to start
+	ContainerTimeoutSeconds int
+	// StartDelay is how long to give tcpdump to start before re-running the probe
+	StartDelay time.Duration
+	namer      *directoryNamer
+}
+
+func NewPacketCapturer(kubernetes kube.IKubernetes, dir string, image string, seconds int, maxPerStep int, containerTimeoutSeconds int) *PacketCapturer {
+	return &PacketCapturer{
+		Kubernetes:              kubernetes,
+		Dir:                     dir,
+		Image:                   image,
+		Seconds:                 seconds,
+		MaxPerStep:              maxPerStep,
+		ContainerTimeoutSeconds: containerTimeoutSeconds,
+		StartDelay:              time.Second,
+		namer:                   newDirectoryNamer(),
+	}
+}
+
+// PacketCapture is a capture of a re-run of a mismatched probe
+type PacketCapture struct {
+	From string
+	To   string
+	Job  string
+	// Rerun is the re-run probe's result, which may differ from the original's if the CNI was still converging
+	Rerun probe.Connectivity
+	// SourcePath and DestinationPath are the captures' files; the destination isn't captured if it isn't a pod
+	SourcePath      string `json:",omitempty"`
+	DestinationPath string `json:",omitempty"`
+	Error           string `json:",omitempty"`
+}
+
+// TestCaseDir picks the directory a test case's captures are saved to
+func (p *PacketCapturer) TestCaseDir(description string) string {
+	return filepath.Join(p.Dir, p.namer.name(description))
+}
+
+// Capture captures up to MaxPerStep of mismatched, re-running each with runner, into dir
+func (p *PacketCapturer) Capture(dir string, stepNumber int, mismatched []*probe.JobResult, runner *probe.Runner) []*PacketCapture {
+	if len(mismatched) > p.MaxPerStep {
+		logrus.Infof("capturing packets of %d of %d mismatched probes of step %d", p.MaxPerStep, len(mismatched), stepNumber)
+		mismatched = mismatched[:p.MaxPerStep]
+	}
+	var captures []*PacketCapture
+	for _, jobResult := range mismatched {
+		capture, err := p.capture(dir, stepNumber, jobResult.Job, runner)
+		if err != nil {
+			logrus.Warnf("unable to capture packets of probe from %s to %s %s: %+v", jobResult.Job.FromKey, jobResult.Job.ToKey, jobResult.Key(), err)
+			capture.Error = err.Error()
+		}
+		captures = append(captures, capture)
+	}
+	return captures
+}
+
+var packetCaptureFileUnsafe = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+func (p *PacketCapturer) capture(dir string, stepNumber int, job *probe.Job, runner *probe.Runner) (*PacketCapture, error) {
+	jobKey := fmt.Sprintf("%s/%d", job.Protocol, job.ResolvedPort)
+	capture := &PacketCapture{From: job.FromKey, To: job.ToKey, Job: jobKey, Rerun: probe.ConnectivityUnknown}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return capture, errors.Wrapf(err, "unable to create packet capture directory %s", dir)
+	}
+
+	type pod struct {
+		namespace string
+		name      string
+		side      string
+		path      *string
+	}
+	prefix := packetCaptureFileUnsafe.ReplaceAllString(fmt.Sprintf("step-%d-%s-to-%s-%s-%d", stepNumber, job.FromKey, job.ToKey, job.Protocol, job.ResolvedPort), "-")
+	pods := []*pod{{namespace: job.FromNamespace, name: job.FromPod, side: "source", path: &capture.SourcePath}}
+	// destinations outside the cluster, or reached through something other than a pod, can only be captured at the
+	// source
+	if to := strings.SplitN(job.ToKey, "/", 2); len(to) == 2 && to[0] == job.ToNamespace {
+		pods = append(pods, &pod{namespace: to[0], name: to[1], side: "destination", path: &capture.DestinationPath})
+	}
+	for _, pod := range pods {
+		if err := probe.EnsureEphemeralContainer(p.Kubernetes, pod.namespace, pod.name, p.container(), p.ContainerTimeoutSeconds); err != nil {
+			return capture, err
+		}
+	}
+
+	command := append([]string{"timeout", fmt.Sprintf("%d", p.Seconds), "tcpdump", "-i", "any", "-U", "-w", "-"}, packetCaptureFilter(job)...)
+	wg := &sync.WaitGroup{}
+	outputs := make([]string, len(pods))
+	errs := make([]error, len(pods))
+	for i, pod := range pods {
+		wg.Add(1)
+		go func(i int, namespace string, name string) {
+			defer wg.Done()
+			// timeout stops tcpdump with a non-zero exit code, so only failing to exec counts as an error
+			stdout, _, _, err := p.Kubernetes.ExecuteRemoteCommand(namespace, name, PacketCaptureContainerName, command)
+			outputs[i], errs[i] = stdout, err
+		}(i, pod.namespace, pod.name)
+	}
+	time.Sleep(p.StartDelay)
+	capture.Rerun = runner.RunJobs(&probe.Jobs{Valid: []*probe.Job{job}})[0].Combined
+	wg.Wait()
+
+	for i, pod := range pods {
+		if errs[i] != nil {
+			return capture, errors.WithMessagef(errs[i], "unable to run tcpdump in pod %s/%s", pod.namespace, pod.name)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.pcap", prefix, pod.side))
+		if err := ioutil.WriteFile(path, []byte(outputs[i]), 0644); err != nil {
+			return capture, errors.Wrapf(err, "unable to write %s", path)
+		}
+		*pod.path = path
+	}
+	return capture, nil
+}
+
+func (p *PacketCapturer) container() v1.EphemeralContainer {
+	return v1.EphemeralContainer{
+		EphemeralContainerCommon: v1.EphemeralContainerCommon{
+			Name:            PacketCaptureContainerName,
+			Image:           p.Image,
+			ImagePullPolicy: v1.PullIfNotPresent,
+			Command:         []string{"sleep", "infinity"},
+			SecurityContext: &v1.SecurityContext{
+				Capabilities: &v1.Capabilities{Add: []v1.Capability{"NET_ADMIN", "NET_RAW"}},
+			},
+		},
+	}
+}
+
+// packetCaptureFilter picks out a job's traffic; tcpdump doesn't support filtering SCTP by port
+func packetCaptureFilter(job *probe.Job) []string {
+	switch job.Protocol {
+	case v1.ProtocolTCP:
+		return []string{"tcp", "port", fmt.Sprintf("%d", job.ResolvedPort)}
+	case v1.ProtocolUDP:
+		return []string{"udp", "port", fmt.Sprintf("%d", job.ResolvedPort)}
+	default:
+		return []string{"sctp"}
+	}
+}
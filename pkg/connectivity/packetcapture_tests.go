@@ -0,0 +1,61 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+)
+
+func RunPacketCaptureTests() {
+	Describe("PacketCapturer", func() {
+		It("should capture packets of re-runs of mismatched probes at both ends", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-packet-captures")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a", "b"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+			capturer := NewPacketCapturer(kubernetes, dir, DefaultPacketCaptureImage, 1, 2, 5)
+			capturer.StartDelay = 0
+			interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true, PacketCapturer: capturer})
+			Expect(err).To(Succeed())
+
+			// the mock allows everything, but the policy denies all ingress to x/a and x/b -- including from themselves
+			policy := generator.BuildPolicy(generator.SetNamespace("x")).NetworkPolicy()
+			testCase := generator.NewSingleStepTestCase("deny all ingress", generator.NewStringSet(generator.TagDenyAll), generator.ProbeAllAvailable, generator.CreatePolicy(policy))
+			result := interpreter.ExecuteTestCase(testCase)
+			Expect(result.Err).To(Succeed())
+
+			captures := result.Steps[0].PacketCaptures
+			Expect(captures).To(HaveLen(2))
+			capture := captures[0]
+			Expect(capture.Error).To(Equal(""))
+			Expect(capture.From).To(Equal("x/a"))
+			Expect(capture.To).To(Equal("x/a"))
+			Expect(capture.Job).To(Equal("TCP/80"))
+			Expect(capture.Rerun).To(Equal(probe.ConnectivityAllowed))
+			Expect(capture.SourcePath).To(Equal(filepath.Join(dir, "deny-all-ingress", "step-1-x-a-to-x-a-TCP-80-source.pcap")))
+			Expect(capture.SourcePath).To(BeAnExistingFile())
+			Expect(capture.DestinationPath).To(BeAnExistingFile())
+
+			for _, pod := range []string{"a", "b"} {
+				kubePod, err := kubernetes.GetPod("x", pod)
+				Expect(err).To(Succeed())
+				Expect(kubePod.Spec.EphemeralContainers).To(HaveLen(1))
+				Expect(kubePod.Spec.EphemeralContainers[0].Name).To(Equal(PacketCaptureContainerName))
+			}
+
+			// capture containers are reused
+			result = interpreter.ExecuteTestCase(testCase)
+			Expect(result.Steps[0].PacketCaptures[0].Error).To(Equal(""))
+			Expect(result.Steps[0].PacketCaptures[0].SourcePath).To(HavePrefix(filepath.Join(dir, "deny-all-ingress-2")))
+		})
+	})
+}
@@ -0,0 +1,125 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/sirupsen/logrus"
+	"sync"
+)
+
+// NamespaceSetName is the name of namespace ns in the index'th extra set of fixture namespaces, counting from 1
+func NamespaceSetName(ns string, index int) string {
+	return fmt.Sprintf("%s-%d", ns, index)
+}
+
+// NamespaceSet is a copy of the fixture namespaces and pods which test cases can run in alongside the other sets: its
+// interpreter, and the names it gives the original namespaces.  The original set doesn't rename anything.
+type NamespaceSet struct {
+	Interpreter *Interpreter
+	Names       map[string]string
+}
+
+func (n *NamespaceSet) execute(testCase *generator.TestCase) *Result {
+	if len(n.Names) > 0 {
+		testCase = testCase.RenameNamespaces(n.Names)
+	}
+	return n.Interpreter.ExecuteTestCase(testCase)
+}
+
+// ParallelInterpreter runs test cases concurrently across sets of namespaces.  The first set must be the original
+// namespaces: test cases which can't run in renamed namespaces run there, one at a time, once every test case before
+// them has finished.
+//
+// The sets' interpreters can -- and should -- share their FailureArtifacts, PacketCapturer and ProbeRecording: each
+// is safe to use from several test cases at once, and sharing them keeps one directory per test case across every
+// set, and one recording of the whole run.  A ProbeReplay is safe to share too, but recorded results are matched up
+// by namespace, and which set runs which test case isn't deterministic.
+type ParallelInterpreter struct {
+	Sets []*NamespaceSet
+}
+
+func NewParallelInterpreter(interpreter *Interpreter) *ParallelInterpreter {
+	return &ParallelInterpreter{Sets: []*NamespaceSet{{Interpreter: interpreter}}}
+}
+
+func (p *ParallelInterpreter) AddSet(interpreter *Interpreter, names map[string]string) {
+	p.Sets = append(p.Sets, &NamespaceSet{Interpreter: interpreter, Names: names})
+}
+
+// Stop stops every set's interpreter; see Interpreter.Stop
+func (p *ParallelInterpreter) Stop() {
+	for _, set := range p.Sets {
+		set.Interpreter.Stop()
+	}
+}
+
+func (p *ParallelInterpreter) IsStopped() bool {
+	return p.Sets[0].Interpreter.IsStopped()
+}
+
+// ExecuteTestCases runs the test cases, handing each result -- along with its index in testCases -- to onResult in
+// test case order, from one goroutine at a time.  Once stopped, no more test cases are started; any which were skipped
+// while others were still running are left out.  testCaseIndexes are the test cases' indexes in the whole run, which
+// differ from their indexes in testCases when only some are left to run; they're what's logged.
+func (p *ParallelInterpreter) ExecuteTestCases(testCases []*generator.TestCase, testCaseIndexes []int, onResult func(index int, result *Result)) {
+	results := make([]*Result, len(testCases))
+	next := 0
+	lock := &sync.Mutex{}
+	finish := func(index int, result *Result) {
+		lock.Lock()
+		defer lock.Unlock()
+		results[index] = result
+		for next < len(results) && results[next] != nil {
+			onResult(next, results[next])
+			next++
+		}
+	}
+
+	for start := 0; start < len(testCases); {
+		if p.IsStopped() {
+			logrus.Warnf("interrupted: skipping remaining %d test cases", len(testCases)-start)
+			break
+		}
+		if len(p.Sets) == 1 || !testCases[start].CanRunInRenamedNamespaces() {
+			logrus.Infof("starting test case #%d", testCaseIndexes[start]+1)
+			finish(start, p.Sets[0].execute(testCases[start]))
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(testCases) && testCases[end].CanRunInRenamedNamespaces() {
+			end++
+		}
+		indexes := make(chan int)
+		wg := &sync.WaitGroup{}
+		for _, set := range p.Sets {
+			wg.Add(1)
+			go func(set *NamespaceSet) {
+				defer wg.Done()
+				for index := range indexes {
+					if p.IsStopped() {
+						continue
+					}
+					logrus.Infof("starting test case #%d", testCaseIndexes[index]+1)
+					finish(index, set.execute(testCases[index]))
+				}
+			}(set)
+		}
+		for index := start; index < end && !p.IsStopped(); index++ {
+			indexes <- index
+		}
+		close(indexes)
+		wg.Wait()
+		start = end
+	}
+
+	// hand over results stranded behind test cases which were skipped after stopping
+	lock.Lock()
+	defer lock.Unlock()
+	for ; next < len(results); next++ {
+		if results[next] != nil {
+			onResult(next, results[next])
+		}
+	}
+}
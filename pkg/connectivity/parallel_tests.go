@@ -0,0 +1,119 @@
+package connectivity
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+func RunParallelTests() {
+	Describe("ParallelInterpreter", func() {
+		It("should spread test cases across namespace sets, and hand over results in order", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			namespaces, pods := []string{"x", "y"}, []string{"a"}
+			newInterpreter := func(names map[string]string) *Interpreter {
+				resources, err := probe.NewRenamedDefaultResources(kubernetes, namespaces, names, pods, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+				Expect(err).To(Succeed())
+				interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true})
+				Expect(err).To(Succeed())
+				return interpreter
+			}
+			interpreter := NewParallelInterpreter(newInterpreter(nil))
+			renamed := map[string]string{"x": NamespaceSetName("x", 1), "y": NamespaceSetName("y", 1)}
+			interpreter.AddSet(newInterpreter(renamed), renamed)
+
+			Expect(kubernetes.Namespaces["x-1"].NamespaceObject.Labels).To(Equal(map[string]string{"ns": "x"}))
+
+			denyAll := func(description string, tags ...string) *generator.TestCase {
+				policy := (&generator.Netpol{
+					Name:    "deny-all",
+					Target:  &generator.NetpolTarget{Namespace: "x"},
+					Ingress: generator.DenyAll,
+				}).NetworkPolicy()
+				return generator.NewSingleStepTestCase(description, generator.NewStringSet(tags...), generator.ProbeAllAvailable, generator.CreatePolicy(policy))
+			}
+			testCases := []*generator.TestCase{
+				denyAll("1", generator.TagDenyAll),
+				denyAll("2", generator.TagDenyAll),
+				denyAll("3", generator.TagDenyAll),
+				denyAll("4", generator.TagRestartCNI),
+				denyAll("5", generator.TagDenyAll),
+			}
+
+			lock := &sync.Mutex{}
+			var descriptions []string
+			namespacesByDescription := map[string]string{}
+			interpreter.ExecuteTestCases(testCases, []int{0, 1, 2, 3, 4}, func(index int, result *Result) {
+				lock.Lock()
+				defer lock.Unlock()
+				Expect(result.Err).To(Succeed())
+				descriptions = append(descriptions, result.TestCase.Description)
+				namespacesByDescription[result.TestCase.Description] = result.TestCase.Steps[0].Actions[0].CreatePolicy.Policy.Namespace
+			})
+
+			Expect(descriptions).To(Equal([]string{"1", "2", "3", "4", "5"}))
+			Expect(namespacesByDescription["4"]).To(Equal("x"))
+			Expect(namespacesByDescription).To(ContainElement("x-1"))
+			Expect(testCases[0].Steps[0].Actions[0].CreatePolicy.Policy.Namespace).To(Equal("x"))
+		})
+
+		It("should share failure artifacts and probe recordings safely across namespace sets, and log the run's test case numbers", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-parallel")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+			logs := &bytes.Buffer{}
+			logrus.SetOutput(logs)
+			defer logrus.SetOutput(os.Stderr)
+
+			kubernetes := kube.NewMockKubernetes(1.0)
+			failureArtifacts, recording := NewFailureArtifacts(dir), probe.NewProbeRecording()
+			newInterpreter := func(names map[string]string) *Interpreter {
+				resources, err := probe.NewRenamedDefaultResources(kubernetes, []string{"x"}, names, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+				Expect(err).To(Succeed())
+				interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true, FailureArtifacts: failureArtifacts, ProbeRecording: recording})
+				Expect(err).To(Succeed())
+				return interpreter
+			}
+			interpreter := NewParallelInterpreter(newInterpreter(nil))
+			for set := 1; set <= 2; set++ {
+				renamed := map[string]string{"x": NamespaceSetName("x", set)}
+				interpreter.AddSet(newInterpreter(renamed), renamed)
+			}
+
+			// the mock allows everything, so every run of the same test case fails
+			policy := generator.BuildPolicy(generator.SetNamespace("x")).NetworkPolicy()
+			var testCases []*generator.TestCase
+			for i := 0; i < 6; i++ {
+				testCases = append(testCases, generator.NewSingleStepTestCase("deny all ingress", generator.NewStringSet(generator.TagDenyAll), generator.ProbeAllAvailable, generator.CreatePolicy(policy)))
+			}
+			var artifacts []string
+			interpreter.ExecuteTestCases(testCases, []int{10, 11, 12, 13, 14, 15}, func(index int, result *Result) {
+				artifacts = append(artifacts, result.FailureArtifacts)
+			})
+
+			Expect(artifacts).To(ConsistOf(
+				filepath.Join(dir, "deny-all-ingress"),
+				filepath.Join(dir, "deny-all-ingress-2"),
+				filepath.Join(dir, "deny-all-ingress-3"),
+				filepath.Join(dir, "deny-all-ingress-4"),
+				filepath.Join(dir, "deny-all-ingress-5"),
+				filepath.Join(dir, "deny-all-ingress-6")))
+			// a single probe from the one pod to itself, per test case
+			Expect(recording.Results).To(HaveLen(6))
+			for i := 11; i <= 16; i++ {
+				Expect(logs.String()).To(ContainSubstring(fmt.Sprintf("starting test case #%d\"", i)))
+			}
+			Expect(logs.String()).NotTo(ContainSubstring("starting test case #1\""))
+		})
+	})
+}
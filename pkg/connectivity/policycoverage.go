@@ -0,0 +1,39 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+)
+
+const (
+	// PolicyCoverageUncovered reports the policy elements which weren't exercised
+	PolicyCoverageUncovered = "uncovered"
+	// PolicyCoverageAll reports every policy element, and how often it was exercised
+	PolicyCoverageAll = "all"
+)
+
+var AllPolicyCoverageModes = []string{PolicyCoverageUncovered, PolicyCoverageAll}
+
+// PolicyCoverage traces the jobs of the last try of each step through the step's network policies, to find which
+// of their rules, peers and ports were exercised.  Only jobs which were actually allowed or blocked count: those
+// which failed to execute, or couldn't be run at all, didn't exercise anything.
+func (c *CombinedResults) PolicyCoverage() *matcher.Coverage {
+	coverage := matcher.NewCoverage()
+	for _, result := range c.Results {
+		for _, step := range result.Steps {
+			coverage.AddPolicies(step.KubePolicies)
+			if len(step.KubeProbes) == 0 {
+				continue
+			}
+			kubeProbe := step.LastKubeProbe()
+			for _, key := range kubeProbe.Wrapped.Keys() {
+				for _, jobResult := range kubeProbe.Get(key.From, key.To).JobResults {
+					if jobResult.Combined == probe.ConnectivityAllowed || jobResult.Combined == probe.ConnectivityBlocked {
+						coverage.AddTraffic(step.Policy, jobResult.Job.Traffic())
+					}
+				}
+			}
+		}
+	}
+	return coverage
+}
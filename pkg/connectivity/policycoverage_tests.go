@@ -0,0 +1,36 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunPolicyCoverageTests() {
+	Describe("PolicyCoverage", func() {
+		It("should count the policy elements exercised by each step's probes", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+			interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true})
+			Expect(err).To(Succeed())
+
+			policy := generator.BuildPolicy().NetworkPolicy()
+			testCase := generator.NewSingleStepTestCase("base policy", generator.NewStringSet(), generator.ProbeAllAvailable, generator.CreatePolicy(policy))
+			results := &CombinedResults{Results: []*Result{interpreter.ExecuteTestCase(testCase)}}
+			coverage := results.PolicyCoverage()
+
+			Expect(coverage.Elements).ToNot(BeEmpty())
+			for _, element := range coverage.Elements {
+				Expect(element.Policy).To(Equal("x/base"))
+			}
+			// x/a is the only pod the policy selects, and it's probed to and from
+			Expect(coverage.Elements[0].Element).To(Equal("selects"))
+			Expect(coverage.Elements[0].Hits).To(BeNumerically(">", 0))
+			Expect(coverage.Table(false)).To(ContainSubstring("policy elements were exercised by at least one probe"))
+		})
+	})
+}
@@ -2,6 +2,7 @@ package connectivity
 
 import (
 	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
 	"github.com/mattfenwick/cyclonus/pkg/generator"
 	"github.com/mattfenwick/cyclonus/pkg/utils"
 	"github.com/olekukonko/tablewriter"
@@ -13,25 +14,190 @@ import (
 	"sigs.k8s.io/yaml"
 	"sort"
 	"strings"
+	"time"
 )
 
 type Printer struct {
 	Noisy          bool
 	IgnoreLoopback bool
-	Results        []*Result
+	// FailuresOnly drops rows and columns without any mismatches from the tables printed for a failed step
+	FailuresOnly bool
+	// CombinedView prints a single matrix summarizing all ports and protocols for each step, instead of a table
+	// for each of expected ingress, egress, and combined results and each kube try
+	CombinedView bool
+	// SlowestCount is how many of the slowest tests to report in the summary; 0 turns the report off
+	SlowestCount int
+	// HeatmapCount is how many of the places with the most failures to report for each dimension of the failure
+	// heatmap; 0 turns the heatmap off
+	HeatmapCount int
+	// Canonical prints the same output from run to run, given the same results -- for golden-file tests and diffing
+	// runs: timings are left out, and IPs are replaced by the names of their pods
+	Canonical bool
+	Results   []*Result
+
+	// resources are the initial resources of the test case being printed, for canonicalizing IPs
+	resources *probe.Resources
 }
 
 func (t *Printer) PrintSummary() {
 	summary := (&CombinedResults{Results: t.Results}).Summary(t.IgnoreLoopback)
 
 	t.printTestSummary(summary.Tests)
-	for primary, counts := range summary.TagCounts {
-		fmt.Println(passFailTable(primary, counts, nil, nil))
+	var primaries []string
+	for primary := range summary.TagCounts {
+		primaries = append(primaries, primary)
+	}
+	sort.Strings(primaries)
+	for _, primary := range primaries {
+		fmt.Println(passFailTable(primary, summary.TagCounts[primary], nil, nil))
 	}
 	fmt.Println(protocolPassFailTable(summary.ProtocolCounts))
+	for _, class := range AllFailureClasses {
+		if count := summary.FailureClassCounts[class]; count > 0 {
+			fmt.Printf("%d tests failed with %s failures\n", count, class)
+		}
+	}
+	var waivers []string
+	for waiver := range summary.Waived {
+		waivers = append(waivers, waiver)
+	}
+	sort.Strings(waivers)
+	for _, waiver := range waivers {
+		fmt.Printf("%d failed tests waived -- %s\n", summary.Waived[waiver], waiver)
+	}
+	if summary.WaivedDifferences > 0 {
+		fmt.Printf("%d results differed from what the policies allow, but were waived\n", summary.WaivedDifferences)
+	}
+	if summary.CrossModeDiscrepancies > 0 {
+		fmt.Printf("found %d cross-mode discrepancies between probing by %s and by %s\n\n", summary.CrossModeDiscrepancies, generator.ProbeModePodIP, generator.ProbeModeServiceIP)
+	}
+	if len(summary.NetworkCounts) > 0 {
+		fmt.Println(networkTable(summary.NetworkCounts))
+	}
+	if len(summary.FamilyCounts) > 0 {
+		fmt.Println(familyTable(summary.FamilyCounts))
+	}
+	if len(summary.EgressPathCounts) > 0 {
+		fmt.Printf("egress path results: %d as expected, %d different, %d not verified\n\n", summary.EgressPathCounts[SameComparison], summary.EgressPathCounts[DifferentComparison], summary.EgressPathCounts[IgnoredComparison])
+	}
+	if len(summary.DNSCounts) > 0 {
+		fmt.Printf("DNS checks: %d as expected, %d different, %d not verified\n\n", summary.DNSCounts[SameComparison], summary.DNSCounts[DifferentComparison], summary.DNSCounts[IgnoredComparison])
+	}
+	if len(summary.ConnectionCounts) > 0 {
+		fmt.Printf("long-lived connections: %d survived, %d severed; %d as expected, %d different, %d not verified\n\n",
+			summary.ConnectionFates[probe.ConnectionSurvived], summary.ConnectionFates[probe.ConnectionSevered],
+			summary.ConnectionCounts[SameComparison], summary.ConnectionCounts[DifferentComparison], summary.ConnectionCounts[IgnoredComparison])
+	}
+	if summary.CorroborationFindings > 0 {
+		fmt.Printf("found %d disagreements between the dataplane's state and the policies or probes\n\n", summary.CorroborationFindings)
+	}
+	if summary.HasZones {
+		fmt.Println(zonePairTable(summary.ZonePairCounts))
+	}
+	if t.HeatmapCount > 0 && summary.Heatmap.FailedCount() > 0 {
+		fmt.Println(summary.Heatmap.RenderTables(t.HeatmapCount))
+	}
 
 	fmt.Printf("Feature results:\n%s\n\n", t.printMarkdownFeatureTable(summary.FeaturePrimaryCounts, summary.FeatureCounts))
 	fmt.Printf("Tag results:\n%s\n", t.printMarkdownFeatureTable(summary.TagPrimaryCounts, summary.TagCounts))
+
+	if t.SlowestCount > 0 && !t.Canonical {
+		fmt.Println(slowestTestsTable(t.Results, t.SlowestCount))
+	}
+}
+
+func networkTable(networkCounts map[string]map[Comparison]int) string {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	str.WriteString("Results over secondary networks, compared to the policies' expected results -- policies usually only apply to the primary network:\n")
+
+	table.SetHeader([]string{"Network", "As expected", "Different", "As expected %"})
+	var networks []string
+	for network := range networkCounts {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	for _, network := range networks {
+		row := &passFailRow{Feature: network, Passed: networkCounts[network][SameComparison], Failed: networkCounts[network][DifferentComparison]}
+		table.Append([]string{row.Feature, intToString(row.Passed), intToString(row.Failed), fmt.Sprintf("%.0f", row.PassedPercentage())})
+	}
+
+	table.Render()
+	return str.String()
+}
+
+func familyTable(familyCounts map[v1.IPFamily]map[Comparison]int) string {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	str.WriteString("Results by IP family, compared to what the policies allow over that family:\n")
+
+	table.SetHeader([]string{"IP family", "As expected", "Different", "As expected %"})
+	for _, family := range []v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol} {
+		counts, ok := familyCounts[family]
+		if !ok {
+			continue
+		}
+		row := &passFailRow{Feature: string(family), Passed: counts[SameComparison], Failed: counts[DifferentComparison]}
+		table.Append([]string{row.Feature, intToString(row.Passed), intToString(row.Failed), fmt.Sprintf("%.0f", row.PassedPercentage())})
+	}
+
+	table.Render()
+	return str.String()
+}
+
+func zonePairTable(zonePairCounts map[probe.ZonePair]map[Comparison]int) string {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	str.WriteString("Results by whether source and destination pods are in the same zone (" + probe.ZoneLabel + "):\n")
+
+	table.SetHeader([]string{"Zone pair", "Passed", "Failed", "Passed %"})
+	for _, zonePair := range probe.AllZonePairs {
+		row := &passFailRow{Feature: string(zonePair), Passed: zonePairCounts[zonePair][SameComparison], Failed: zonePairCounts[zonePair][DifferentComparison]}
+		if row.Passed+row.Failed == 0 {
+			continue
+		}
+		table.Append([]string{row.Feature, intToString(row.Passed), intToString(row.Failed), fmt.Sprintf("%.0f", row.PassedPercentage())})
+	}
+
+	table.Render()
+	return str.String()
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func slowestTestsTable(results []*Result, n int) string {
+	numbers := map[*Result]int{}
+	for i, result := range results {
+		numbers[result] = i + 1
+	}
+
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	str.WriteString(fmt.Sprintf("Slowest %d tests:\n", n))
+
+	table.SetHeader([]string{"Test", "Total", "Setup", "Verification", "Warm-up", "Actions", "Perturbation wait", "Probing"})
+	for _, result := range (&CombinedResults{Results: results}).SlowestResults(n) {
+		steps := result.StepTimings()
+		table.Append([]string{
+			fmt.Sprintf("%d: %s", numbers[result], result.TestCase.Description),
+			formatDuration(result.Timing.Total),
+			formatDuration(result.Timing.Setup),
+			formatDuration(result.Timing.Verification),
+			formatDuration(result.Timing.WarmUp),
+			formatDuration(steps.Actions),
+			formatDuration(steps.PerturbationWait),
+			formatDuration(steps.Probing),
+		})
+	}
+
+	table.Render()
+	return str.String()
 }
 
 const (
@@ -131,8 +297,13 @@ func passFailTable(caption string, passFailCounts map[string]map[bool]int, passe
 
 	table.SetHeader([]string{"Feature", "Passed", "Failed", "Passed %"})
 
-	var rows []*passFailRow
+	var features []string
 	for feature := range passFailCounts {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	var rows []*passFailRow
+	for _, feature := range features {
 		rows = append(rows, &passFailRow{
 			Feature: feature,
 			Passed:  passFailCounts[feature][true],
@@ -140,7 +311,8 @@ func passFailTable(caption string, passFailCounts map[string]map[bool]int, passe
 		})
 	}
 
-	sort.Slice(rows, func(i, j int) bool {
+	// ties are left in order of name
+	sort.SliceStable(rows, func(i, j int) bool {
 		return rows[i].PassedPercentage() < rows[j].PassedPercentage()
 	})
 	if passedTotal != nil || failedTotal != nil {
@@ -164,7 +336,11 @@ func protocolPassFailTable(protocolCounts map[v1.Protocol]map[Comparison]int) st
 	table.SetHeader([]string{"Protocol", "Passed", "Failed", "Passed %"})
 
 	var rows []*passFailRow
-	for protocol, counts := range protocolCounts {
+	for _, protocol := range []v1.Protocol{v1.ProtocolTCP, v1.ProtocolSCTP, v1.ProtocolUDP} {
+		counts, ok := protocolCounts[protocol]
+		if !ok {
+			continue
+		}
 		rows = append(rows, &passFailRow{
 			Feature: fmt.Sprintf("probe on %s", protocol),
 			Passed:  counts[SameComparison],
@@ -193,22 +369,34 @@ func intToString(i int) string {
 
 func (t *Printer) PrintTestCaseResult(result *Result) {
 	t.Results = append(t.Results, result)
+	t.resources = result.InitialResources
+	if result.FailureArtifacts != "" {
+		defer fmt.Printf("collected failure artifacts in %s\n\n", result.FailureArtifacts)
+	}
 
 	if result.Err != nil {
-		fmt.Printf("test case failed to execute for %s %+v: %+v", result.TestCase.Description, result.TestCase, result.Err)
+		if t.Canonical {
+			// leave out the test case's and error's details, which include pointers and stack traces
+			fmt.Printf("test case failed to execute (%s) for %s: %s\n", ClassOfError(result.Err), result.TestCase.Description, t.canonical(result.Err.Error()))
+		} else {
+			fmt.Printf("test case failed to execute (%s) for %s %+v: %+v\n", ClassOfError(result.Err), result.TestCase.Description, result.TestCase, result.Err)
+		}
 		return
 	}
 
 	fmt.Printf("evaluating test case: %s\n", result.TestCase.Description)
 	stepCount := len(result.TestCase.Steps)
 	resultCount := len(result.Steps)
-	if stepCount != resultCount {
+	if result.Interrupted {
+		fmt.Printf("test case was interrupted: only %d of %d steps were run\n", resultCount, stepCount)
+	} else if stepCount != resultCount {
 		panic(errors.Errorf("found %d test steps, but %d result steps", stepCount, resultCount))
 	}
 
 	for i := range result.Steps {
 		t.PrintStep(i+1, result.TestCase.Steps[i], result.Steps[i])
 	}
+	t.printMinimizedPolicies(result.MinimizedPolicies)
 	//fmt.Println("features:")
 	//for feature := range result.TestCase.GetFeatures() {
 	//	fmt.Printf(" - %s\n", feature)
@@ -219,18 +407,18 @@ func (t *Printer) PrintTestCaseResult(result *Result) {
 
 func (t *Printer) PrintStep(i int, step *generator.TestStep, stepResult *StepResult) {
 	if step.Probe.PortProtocol != nil {
-		fmt.Printf("step %d on port %s, protocol %s:\n", i, step.Probe.PortProtocol.Port.String(), step.Probe.PortProtocol.Protocol)
+		fmt.Printf("step %d on port %s, protocol %s, destination type %s:\n", i, step.Probe.PortProtocol.Port.String(), step.Probe.PortProtocol.Protocol, step.Probe.Mode)
 	} else {
-		fmt.Printf("step %d on all available ports/protocols:\n", i)
+		fmt.Printf("step %d on all available ports/protocols, destination type %s:\n", i, step.Probe.Mode)
 	}
 	policy := stepResult.Policy
 
-	fmt.Printf("Policy explanation:\n%s\n", policy.ExplainTable())
+	fmt.Printf("Policy explanation:\n%s\n", t.canonical(policy.ExplainTable()))
 
 	fmt.Printf("\n\nResults for network policies:\n")
 	if len(stepResult.KubePolicies) > 0 {
 		for _, p := range stepResult.KubePolicies {
-			fmt.Printf("Network policy:\n\n%s\n", PrintNetworkPolicy(p))
+			fmt.Printf("Network policy:\n\n%s\n", t.canonical(PrintNetworkPolicy(p)))
 		}
 	} else {
 		fmt.Println("no network policies")
@@ -246,22 +434,299 @@ func (t *Printer) PrintStep(i int, step *generator.TestStep, stepResult *StepRes
 		fmt.Printf("Discrepancy found:")
 	}
 	fmt.Printf("%d wrong, %d ignored, %d correct\n", counts[DifferentComparison], counts[IgnoredComparison], counts[SameComparison])
+	if waived := stepResult.WaivedDifferences(t.IgnoreLoopback); waived > 0 {
+		fmt.Printf("%d results differ from what the policies allow, but are waived\n", waived)
+	}
 
 	if counts[DifferentComparison] > 0 || t.Noisy {
-		fmt.Printf("Expected ingress:\n%s\n", stepResult.SimulatedProbe.RenderIngress())
+		simulatedProbe, kubeProbes := stepResult.ExpectedProbe(), stepResult.KubeProbes
+		if t.FailuresOnly && counts[DifferentComparison] > 0 {
+			froms, tos := comparison.MismatchedFromsAndTos(t.IgnoreLoopback)
+			fmt.Printf("showing only the %d sources and %d destinations with mismatches\n", len(froms), len(tos))
+			simulatedProbe = simulatedProbe.Restrict(froms, tos)
+			var restrictedKubeProbes []*probe.Table
+			for _, kubeResult := range kubeProbes {
+				restrictedKubeProbes = append(restrictedKubeProbes, kubeResult.Restrict(froms, tos))
+			}
+			kubeProbes = restrictedKubeProbes
+			comparison = comparison.Restrict(froms, tos)
+		}
 
-		fmt.Printf("Expected egress:\n%s\n", stepResult.SimulatedProbe.RenderEgress())
+		if t.CombinedView {
+			fmt.Printf("Actual results (last round), by port and protocol -- %s:\n%s\n", CombinedTableLegend, comparison.RenderCombinedTable())
+		} else {
+			fmt.Printf("Expected ingress:\n%s\n", simulatedProbe.RenderIngress())
 
-		fmt.Printf("Expected combined:\n%s\n", stepResult.SimulatedProbe.RenderTable())
+			fmt.Printf("Expected egress:\n%s\n", simulatedProbe.RenderEgress())
 
-		for i, kubeResult := range stepResult.KubeProbes {
-			fmt.Printf("kube results, try %d:\n%s\n", i, kubeResult.RenderTable())
-		}
+			fmt.Printf("Expected combined:\n%s\n", simulatedProbe.RenderTable())
+
+			for i, kubeResult := range kubeProbes {
+				fmt.Printf("kube results, try %d:\n%s\n", i, kubeResult.RenderTable())
+			}
 
-		fmt.Printf("\nActual vs expected (last round):\n%s\n", comparison.RenderSuccessTable())
+			fmt.Printf("\nActual vs expected (last round):\n%s\n", comparison.RenderSuccessTable())
+		}
+	} else if t.CombinedView {
+		fmt.Printf("%s\n", comparison.RenderCombinedTable())
 	} else {
 		fmt.Printf("%s\n", stepResult.LastKubeProbe().RenderTable())
 	}
+
+	t.printCrossModeComparison(stepResult)
+	t.printNetworkProbes(stepResult)
+	t.printFamilyProbes(stepResult)
+	t.printRouteProbe(stepResult)
+	t.printEgressPath(stepResult)
+	t.printDNSChecks(stepResult)
+	t.printConnections(stepResult)
+	t.printCorroboration(stepResult)
+	t.printUDPDelivery(stepResult)
+	t.printHTTPChecks(stepResult)
+	t.printTLSChecks(stepResult)
+	t.printLatency(stepResult)
+	t.printPacketCaptures(stepResult)
+}
+
+// canonical replaces IPs in text, if printing canonical output
+func (t *Printer) canonical(text string) string {
+	if !t.Canonical {
+		return text
+	}
+	return CanonicalizeIPs(text, t.resources)
+}
+
+func (t *Printer) printCrossModeComparison(stepResult *StepResult) {
+	crossMode := stepResult.CrossModeComparison()
+	if crossMode == nil {
+		return
+	}
+	discrepancies := crossMode.ValueCounts(t.IgnoreLoopback)[DifferentComparison]
+	fmt.Printf("cross-mode check, %s vs %s: %d discrepancies\n", generator.ProbeModePodIP, generator.ProbeModeServiceIP, discrepancies)
+	if discrepancies > 0 || t.Noisy {
+		podIPProbe, serviceIPProbe := stepResult.CrossModeProbes[generator.ProbeModePodIP], stepResult.CrossModeProbes[generator.ProbeModeServiceIP]
+		if t.FailuresOnly && discrepancies > 0 {
+			froms, tos := crossMode.MismatchedFromsAndTos(t.IgnoreLoopback)
+			podIPProbe, serviceIPProbe = podIPProbe.Restrict(froms, tos), serviceIPProbe.Restrict(froms, tos)
+			crossMode = crossMode.Restrict(froms, tos)
+		}
+		fmt.Printf("kube results by %s:\n%s\n", generator.ProbeModePodIP, podIPProbe.RenderTable())
+		fmt.Printf("kube results by %s:\n%s\n", generator.ProbeModeServiceIP, serviceIPProbe.RenderTable())
+		fmt.Printf("%s vs %s:\n%s\n", generator.ProbeModePodIP, generator.ProbeModeServiceIP, crossMode.RenderSuccessTable())
+	}
+}
+
+func (t *Printer) printNetworkProbes(stepResult *StepResult) {
+	for _, network := range stepResult.Networks() {
+		comparison := stepResult.NetworkComparison(network)
+		differences := comparison.ValueCounts(t.IgnoreLoopback)[DifferentComparison]
+		fmt.Printf("secondary network %s: %d results differ from the policies' expected results\n", network, differences)
+		if differences > 0 || t.Noisy {
+			networkProbe := stepResult.NetworkProbes[network]
+			if t.FailuresOnly && differences > 0 {
+				froms, tos := comparison.MismatchedFromsAndTos(t.IgnoreLoopback)
+				networkProbe, comparison = networkProbe.Restrict(froms, tos), comparison.Restrict(froms, tos)
+			}
+			fmt.Printf("kube results over secondary network %s:\n%s\n", network, networkProbe.RenderTable())
+			fmt.Printf("secondary network %s vs expected:\n%s\n", network, comparison.RenderSuccessTable())
+		}
+	}
+}
+
+func (t *Printer) printFamilyProbes(stepResult *StepResult) {
+	for _, family := range stepResult.Families() {
+		comparison := stepResult.FamilyComparison(family)
+		differences := comparison.ValueCounts(t.IgnoreLoopback)[DifferentComparison]
+		fmt.Printf("%s: %d results differ from the policies' expected results\n", family, differences)
+		if differences > 0 || t.Noisy {
+			familyProbe := stepResult.FamilyProbes[family].LastKubeProbe()
+			if t.FailuresOnly && differences > 0 {
+				froms, tos := comparison.MismatchedFromsAndTos(t.IgnoreLoopback)
+				familyProbe, comparison = familyProbe.Restrict(froms, tos), comparison.Restrict(froms, tos)
+			}
+			fmt.Printf("kube results over %s:\n%s\n", family, familyProbe.RenderTable())
+			fmt.Printf("%s vs expected:\n%s\n", family, comparison.RenderSuccessTable())
+		}
+	}
+}
+
+func (t *Printer) printRouteProbe(stepResult *StepResult) {
+	if stepResult.RouteProbe == nil {
+		return
+	}
+	fmt.Printf("kube results through routes (not verified):\n%s\n", stepResult.RouteProbe.RenderTable())
+}
+
+func (t *Printer) printEgressPath(stepResult *StepResult) {
+	if len(stepResult.EgressPathResults) == 0 {
+		return
+	}
+	counts := stepResult.EgressPathCounts()
+	fmt.Printf("egress path: %d as expected, %d different, %d not verified\n", counts[SameComparison], counts[DifferentComparison], counts[IgnoredComparison])
+	if counts[DifferentComparison] == 0 && !t.Noisy {
+		return
+	}
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Source", "Expected", "Actual", "Result"})
+	for _, result := range stepResult.EgressPathResults {
+		if t.FailuresOnly && result.Comparison() != DifferentComparison {
+			continue
+		}
+		expected := string(result.Expected)
+		if expected == "" {
+			expected = "-"
+		}
+		table.Append([]string{result.JobResult.Job.FromKey, expected, string(result.JobResult.Combined), string(result.Comparison())})
+	}
+	table.Render()
+	fmt.Printf("kube results of egress path:\n%s\n", t.canonical(str.String()))
+}
+
+func (t *Printer) printDNSChecks(stepResult *StepResult) {
+	if len(stepResult.DNSResults) == 0 {
+		return
+	}
+	counts := stepResult.DNSCounts()
+	fmt.Printf("DNS checks: %d as expected, %d different, %d not verified\n", counts[SameComparison], counts[DifferentComparison], counts[IgnoredComparison])
+	if counts[DifferentComparison] == 0 && !t.Noisy {
+		return
+	}
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Source", "Expected", "Actual", "Result"})
+	for _, result := range stepResult.DNSResults {
+		if t.FailuresOnly && result.Comparison() != DifferentComparison {
+			continue
+		}
+		expected := string(result.Expected)
+		if expected == "" {
+			expected = "-"
+		}
+		table.Append([]string{result.JobResult.Job.FromKey, expected, string(result.JobResult.Combined), string(result.Comparison())})
+	}
+	table.Render()
+	fmt.Printf("kube results of DNS lookups of %s:\n%s\n", probe.DNSCheckName, t.canonical(str.String()))
+}
+
+func (t *Printer) printConnections(stepResult *StepResult) {
+	if len(stepResult.ConnectionResults) == 0 {
+		return
+	}
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Connection", "Answered", "Fate", "Expected", "Result", "Failure"})
+	for _, result := range stepResult.ConnectionResults {
+		expected := string(result.Expected)
+		if expected == "" {
+			expected = "-"
+		}
+		table.Append([]string{
+			fmt.Sprintf("%s -> %s:%d", result.Result.From, result.Result.To, result.Result.Port),
+			fmt.Sprintf("%d/%d", result.Result.Answered, result.Result.Requests),
+			string(result.Result.Fate()),
+			expected,
+			string(result.Comparison()),
+			result.Result.Failure,
+		})
+	}
+	table.Render()
+	fmt.Printf("long-lived connections:\n%s\n", str.String())
+}
+
+func (t *Printer) printCorroboration(stepResult *StepResult) {
+	if stepResult.CorroboratedBy == "" {
+		return
+	}
+	fmt.Printf("dataplane corroboration by %s: %d disagreements\n", stepResult.CorroboratedBy, len(stepResult.CorroborationFindings))
+	if len(stepResult.CorroborationFindings) > 0 {
+		fmt.Printf("%s\n", CorroborationTable(stepResult.CorroborationFindings))
+	}
+}
+
+func (t *Printer) printPacketCaptures(stepResult *StepResult) {
+	if len(stepResult.PacketCaptures) == 0 {
+		return
+	}
+	fmt.Printf("packet captures of mismatched probes:\n")
+	for _, capture := range stepResult.PacketCaptures {
+		if capture.Error != "" {
+			fmt.Printf("- %s -> %s %s: unable to capture: %s\n", capture.From, capture.To, capture.Job, capture.Error)
+			continue
+		}
+		fmt.Printf("- %s -> %s %s: re-run %s; source %s", capture.From, capture.To, capture.Job, capture.Rerun, capture.SourcePath)
+		if capture.DestinationPath != "" {
+			fmt.Printf(", destination %s", capture.DestinationPath)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+func (t *Printer) printMinimizedPolicies(minimized *MinimizedPolicies) {
+	if minimized == nil {
+		return
+	}
+	fmt.Printf("minimized policies of step %d, with %d reductions in %d attempts:\n", minimized.Step, len(minimized.Reductions), minimized.Attempts)
+	for _, reduction := range minimized.Reductions {
+		fmt.Printf("- %s\n", reduction)
+	}
+	if minimized.Error != "" {
+		fmt.Printf("minimizing stopped early: %s\n", minimized.Error)
+	}
+	for _, p := range minimized.Policies {
+		fmt.Printf("Minimized network policy:\n\n%s\n", t.canonical(PrintNetworkPolicy(p)))
+	}
+	fmt.Printf("still mismatched:\n")
+	for _, mismatch := range minimized.Mismatches {
+		fmt.Printf("- %s\n", t.canonical(mismatch))
+	}
+	fmt.Println()
+}
+
+func (t *Printer) printUDPDelivery(stepResult *StepResult) {
+	kubeProbe := stepResult.LastKubeProbe()
+	if !kubeProbe.HasUDPDelivery() {
+		return
+	}
+	lossy := kubeProbe.CountLossyUDP()
+	fmt.Printf("UDP delivery: %d allowed but lossy paths\n", lossy)
+	if lossy > 0 || t.Noisy {
+		fmt.Printf("UDP datagrams delivered (lossy paths marked with '!'):\n%s\n", kubeProbe.RenderUDPDelivery())
+	}
+}
+
+func (t *Printer) printHTTPChecks(stepResult *StepResult) {
+	kubeProbe := stepResult.LastKubeProbe()
+	if !kubeProbe.HasHTTPChecks() {
+		return
+	}
+	partial := kubeProbe.CountPartialHTTP()
+	fmt.Printf("HTTP checks: %d connected but unanswered paths\n", partial)
+	if partial > 0 || t.Noisy {
+		fmt.Printf("HTTP checks (status codes of connected but unanswered paths marked with '!'):\n%s\n", kubeProbe.RenderHTTPChecks())
+	}
+}
+
+func (t *Printer) printTLSChecks(stepResult *StepResult) {
+	kubeProbe := stepResult.LastKubeProbe()
+	if !kubeProbe.HasTLSChecks() {
+		return
+	}
+	partial := kubeProbe.CountPartialTLS()
+	fmt.Printf("TLS checks: %d connected but unverified paths\n", partial)
+	if partial > 0 || t.Noisy {
+		fmt.Printf("TLS checks (failed handshakes marked with 'T!', and responses from other than the serving pod with 'B!'):\n%s\n", kubeProbe.RenderTLSChecks())
+	}
+}
+
+func (t *Printer) printLatency(stepResult *StepResult) {
+	if summary := stepResult.LastKubeProbe().LatencySummary(); summary != nil {
+		fmt.Printf("Probe latency: %s\n", summary.String())
+	}
 }
 
 func PrintNetworkPolicy(p *networkingv1.NetworkPolicy) string {
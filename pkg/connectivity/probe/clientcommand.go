@@ -0,0 +1,112 @@
+package probe
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"strings"
+	"text/template"
+)
+
+// ClientCommandTemplate describes how to issue a probe from a client container, for environments where agnhost
+// isn't available or a different client is wanted.  Each element of Command is a go template, rendered with the
+// Job -- so i.e. `{{.ToHost}}`, `{{.ResolvedPort}}`, `{{.ToAddress}}` and `{{.Protocol | lower}}` are available.
+//
+// A probe is allowed if the command exits successfully and, if SuccessRegex is set, its stdout matches SuccessRegex.
+type ClientCommandTemplate struct {
+	Command      []string `json:"command"`
+	SuccessRegex string   `json:"successRegex,omitempty"`
+}
+
+type compiledClientCommand struct {
+	args         []*template.Template
+	successRegex *regexp.Regexp
+}
+
+// ClientCommands maps protocols to client command templates.  Protocols without a template use agnhost.  Protocol
+// keys are case-insensitive.
+type ClientCommands struct {
+	commands map[v1.Protocol]*compiledClientCommand
+}
+
+var clientCommandFuncs = template.FuncMap{
+	"lower": func(protocol v1.Protocol) string { return strings.ToLower(string(protocol)) },
+}
+
+func NewClientCommands(templates map[v1.Protocol]*ClientCommandTemplate) (*ClientCommands, error) {
+	commands := map[v1.Protocol]*compiledClientCommand{}
+	for protocol, tmpl := range templates {
+		if len(tmpl.Command) == 0 {
+			return nil, errors.Errorf("empty client command for protocol %s", protocol)
+		}
+		compiled := &compiledClientCommand{}
+		for i, arg := range tmpl.Command {
+			parsed, err := template.New(string(protocol)).Funcs(clientCommandFuncs).Option("missingkey=error").Parse(arg)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to parse client command arg %d for protocol %s", i, protocol)
+			}
+			compiled.args = append(compiled.args, parsed)
+		}
+		if tmpl.SuccessRegex != "" {
+			regex, err := regexp.Compile(tmpl.SuccessRegex)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to compile success regex for protocol %s", protocol)
+			}
+			compiled.successRegex = regex
+		}
+		commands[v1.Protocol(strings.ToUpper(string(protocol)))] = compiled
+	}
+	return &ClientCommands{commands: commands}, nil
+}
+
+// ReadClientCommands reads a yaml or json file mapping protocols to ClientCommandTemplates, i.e.:
+//
+//	TCP:
+//	  command: ["nc", "-z", "-w", "1", "{{.ToHost}}", "{{.ResolvedPort}}"]
+//	UDP:
+//	  command: ["sh", "-c", "echo hostname | nc -u -w 1 {{.ToHost}} {{.ResolvedPort}}"]
+//	  successRegex: ".+"
+func ReadClientCommands(path string) (*ClientCommands, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read client commands from %s", path)
+	}
+	var templates map[v1.Protocol]*ClientCommandTemplate
+	if err := yaml.UnmarshalStrict(bs, &templates); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal client commands from %s", path)
+	}
+	return NewClientCommands(templates)
+}
+
+// HasCommand is true if protocol has a client command, instead of using agnhost
+func (c *ClientCommands) HasCommand(protocol v1.Protocol) bool {
+	if c == nil {
+		return false
+	}
+	_, ok := c.commands[protocol]
+	return ok
+}
+
+// Command returns the command to run for a job, and the regex its output must match -- which may be nil.  A nil
+// ClientCommands always uses agnhost.
+func (c *ClientCommands) Command(job *Job) ([]string, *regexp.Regexp, error) {
+	if c == nil {
+		return job.ClientCommand(), nil, nil
+	}
+	compiled, ok := c.commands[job.Protocol]
+	if !ok {
+		return job.ClientCommand(), nil, nil
+	}
+	var command []string
+	for _, arg := range compiled.args {
+		rendered := &bytes.Buffer{}
+		if err := arg.Execute(rendered, job); err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to render client command for job %s", job.Key())
+		}
+		command = append(command, rendered.String())
+	}
+	return command, compiled.successRegex, nil
+}
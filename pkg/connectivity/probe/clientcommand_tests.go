@@ -0,0 +1,43 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunClientCommandTests() {
+	Describe("ClientCommands", func() {
+		job := &Job{ToHost: "s-x-a.x.svc.cluster.local", ResolvedPort: 80, Protocol: v1.ProtocolUDP}
+
+		It("Should render templates for configured protocols", func() {
+			clientCommands, err := NewClientCommands(map[v1.Protocol]*ClientCommandTemplate{
+				"udp": {Command: []string{"nc", "-{{.Protocol | lower}}", "{{.ToAddress}}"}, SuccessRegex: "^ok"},
+			})
+			Expect(err).To(Succeed())
+
+			command, regex, err := clientCommands.Command(job)
+			Expect(err).To(Succeed())
+			Expect(command).To(Equal([]string{"nc", "-udp", "s-x-a.x.svc.cluster.local:80"}))
+			Expect(regex.MatchString("ok\n")).To(BeTrue())
+		})
+
+		It("Should fall back to agnhost", func() {
+			clientCommands, err := NewClientCommands(map[v1.Protocol]*ClientCommandTemplate{
+				v1.ProtocolTCP: {Command: []string{"curl", "{{.ToAddress}}"}},
+			})
+			Expect(err).To(Succeed())
+
+			command, regex, err := clientCommands.Command(job)
+			Expect(err).To(Succeed())
+			Expect(command).To(Equal(job.ClientCommand()))
+			Expect(regex).To(BeNil())
+		})
+
+		It("Should reject bad templates", func() {
+			_, err := NewClientCommands(map[v1.Protocol]*ClientCommandTemplate{v1.ProtocolTCP: {Command: []string{"{{.ToHost"}}})
+			Expect(err).NotTo(Succeed())
+		})
+	})
+
+}
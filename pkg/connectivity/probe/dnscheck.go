@@ -0,0 +1,135 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// DNSKey is the destination key of DNS check jobs, which all go to the cluster's DNS
+	DNSKey = "dns"
+	// DNSCheckName is looked up by DNS checks; it's found through the pods' search domains, whatever the cluster's
+	// domain is
+	DNSCheckName = "kubernetes.default"
+)
+
+// DNSServer is one of the pods answering the cluster's DNS.  Lookups go to the DNS service's IP, but that's
+// translated to one of these pods before policies see it.
+type DNSServer struct {
+	Namespace       string
+	NamespaceLabels map[string]string
+	Labels          map[string]string
+	IP              string
+	// PortName is the name of the pod's UDP port 53, if it's named
+	PortName string
+}
+
+// DNSCheck looks up DNSCheckName from every pod, with dig -- which is in the agnhost image -- so that a broken DNS
+// carve-out in egress policies shows up as such, rather than as confusing failures of whatever needed DNS
+type DNSCheck struct {
+	Servers []*DNSServer
+}
+
+// NewDNSCheck finds the DNS servers: the pods in namespace matching podSelector, i.e. 'k8s-app=kube-dns'
+func NewDNSCheck(kubernetes kube.IKubernetes, namespace string, podSelector string) (*DNSCheck, error) {
+	selector, err := labels.Parse(podSelector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse DNS pod selector '%s'", podSelector)
+	}
+	ns, err := kubernetes.GetNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := kubernetes.GetPodsInNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	check := &DNSCheck{}
+	for _, pod := range pods {
+		if !selector.Matches(labels.Set(pod.Labels)) || pod.Status.PodIP == "" {
+			continue
+		}
+		server := &DNSServer{Namespace: namespace, NamespaceLabels: ns.Labels, Labels: pod.Labels, IP: pod.Status.PodIP}
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.ContainerPort == 53 && port.Protocol == v1.ProtocolUDP {
+					server.PortName = port.Name
+				}
+			}
+		}
+		check.Servers = append(check.Servers, server)
+	}
+	if len(check.Servers) == 0 {
+		return nil, errors.Errorf("no running DNS pods in namespace %s matching '%s'", namespace, podSelector)
+	}
+	return check, nil
+}
+
+func (d *DNSCheck) clientCommand() []string {
+	return []string{"dig", "+search", "+short", "+time=2", "+tries=1", DNSCheckName}
+}
+
+// Runner returns a kube runner which looks up the name, instead of running agnhost, for UDP jobs.  A lookup is only
+// allowed if it gets an address back.
+func (d *DNSCheck) Runner(kubernetes kube.IKubernetes, workers int) (*Runner, error) {
+	clientCommands, err := NewClientCommands(map[v1.Protocol]*ClientCommandTemplate{
+		v1.ProtocolUDP: {Command: d.clientCommand(), SuccessRegex: `(?m)^[0-9a-fA-F.:]+$`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewKubeRunner(kubernetes, workers, clientCommands), nil
+}
+
+// Jobs returns a UDP job on port 53 from every pod to the cluster's DNS
+func (d *DNSCheck) Jobs(resources *Resources) []*Job {
+	var jobs []*Job
+	for _, pod := range resources.Pods {
+		jobs = append(jobs, &Job{
+			FromKey:             pod.PodString().String(),
+			FromNamespace:       pod.Namespace,
+			FromNamespaceLabels: resources.Namespaces[pod.Namespace],
+			FromPod:             pod.Name,
+			FromPodLabels:       pod.Labels,
+			FromContainer:       pod.ClientContainer(),
+			FromIP:              pod.IP,
+			ToKey:               DNSKey,
+			ToHost:              DNSCheckName,
+			ResolvedPort:        53,
+			Protocol:            v1.ProtocolUDP,
+		})
+	}
+	return jobs
+}
+
+// Expected is what policies allow for a job's lookups: allowed if they allow traffic to every DNS server, blocked if
+// they allow it to none, and empty -- since it's up to which server the lookup goes to -- otherwise
+func (d *DNSCheck) Expected(policy *matcher.Policy, job *Job) Connectivity {
+	allowed := 0
+	for _, server := range d.Servers {
+		traffic := job.Traffic()
+		traffic.Destination = &matcher.TrafficPeer{
+			Internal: &matcher.InternalPeer{
+				PodLabels:       server.Labels,
+				NamespaceLabels: server.NamespaceLabels,
+				Namespace:       server.Namespace,
+			},
+			IP: server.IP,
+		}
+		traffic.ResolvedPortName = server.PortName
+		if policy.IsTrafficAllowed(traffic).IsAllowed() {
+			allowed++
+		}
+	}
+	switch allowed {
+	case len(d.Servers):
+		return ConnectivityAllowed
+	case 0:
+		return ConnectivityBlocked
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,69 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func RunDNSCheckTests() {
+	Describe("DNS checks", func() {
+		It("Should look up a name from every pod, expecting it to work where policies allow egress to the DNS pods", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			_, err := kubernetes.CreateNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", Labels: map[string]string{"ns": "kube-system"}}})
+			Expect(err).To(Succeed())
+			for _, name := range []string{"dns-1", "dns-2"} {
+				_, err = kubernetes.CreatePod(&v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: name, Labels: map[string]string{"k8s-app": "kube-dns", "instance": name}},
+					Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "dns", Ports: []v1.ContainerPort{{Name: "dns", ContainerPort: 53, Protocol: v1.ProtocolUDP}}}}},
+				})
+				Expect(err).To(Succeed())
+			}
+
+			_, err = NewDNSCheck(kubernetes, "kube-system", "k8s-app in (")
+			Expect(err).NotTo(Succeed())
+			_, err = NewDNSCheck(kubernetes, "kube-system", "k8s-app=coredns")
+			Expect(err).NotTo(Succeed())
+			dnsCheck, err := NewDNSCheck(kubernetes, "kube-system", "k8s-app=kube-dns")
+			Expect(err).To(Succeed())
+			Expect(dnsCheck.Servers).To(HaveLen(2))
+			Expect(dnsCheck.Servers[0].PortName).To(Equal("dns"))
+			Expect(dnsCheck.clientCommand()).To(ContainElement(DNSCheckName))
+
+			resources := &Resources{
+				Namespaces: map[string]map[string]string{"x": {"ns": "x"}},
+				Pods:       []*Pod{NewPod("x", "a", map[string]string{"pod": "a"}, "10.1.0.1", []*Container{{Name: "cont-80-tcp", Port: 80, Protocol: v1.ProtocolTCP}})},
+			}
+			jobs := dnsCheck.Jobs(resources)
+			Expect(jobs).To(HaveLen(1))
+			Expect(jobs[0].ToKey).To(Equal(DNSKey))
+			Expect(jobs[0].ResolvedPort).To(Equal(53))
+			Expect(jobs[0].Protocol).To(Equal(v1.ProtocolUDP))
+
+			xa := &generator.NetpolTarget{Namespace: "x", PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"pod": "a"}}}
+			denyEgress := (&generator.Netpol{Name: "deny-egress-x-a", Target: xa, Egress: generator.DenyAll}).NetworkPolicy()
+			allowDNS1 := (&generator.Netpol{Name: "allow-dns-1", Target: xa, Egress: &generator.NetpolPeers{Rules: []*generator.Rule{{
+				Peers: []networkingv1.NetworkPolicyPeer{{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "kube-system"}},
+					PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"instance": "dns-1"}},
+				}},
+			}}}}).NetworkPolicy()
+			for _, policies := range []struct {
+				Netpols  []*networkingv1.NetworkPolicy
+				Expected Connectivity
+			}{
+				{Netpols: nil, Expected: ConnectivityAllowed},
+				{Netpols: []*networkingv1.NetworkPolicy{denyEgress}, Expected: ConnectivityBlocked},
+				{Netpols: []*networkingv1.NetworkPolicy{denyEgress, generator.AllowDNSPolicy(xa).NetworkPolicy()}, Expected: ConnectivityAllowed},
+				{Netpols: []*networkingv1.NetworkPolicy{denyEgress, allowDNS1}, Expected: ""},
+			} {
+				Expect(dnsCheck.Expected(matcher.BuildNetworkPolicies(true, policies.Netpols), jobs[0])).To(Equal(policies.Expected))
+			}
+		})
+	})
+}
@@ -0,0 +1,83 @@
+package probe
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"net"
+	"sort"
+)
+
+// IPFamilyOf is IPv6 for IPv6 addresses, and IPv4 for anything else
+func IPFamilyOf(ip string) v1.IPFamily {
+	parsed := net.ParseIP(ip)
+	if parsed != nil && parsed.To4() == nil {
+		return v1.IPv6Protocol
+	}
+	return v1.IPv4Protocol
+}
+
+// FamilyIPs finds a pod's first IP of each family, by family; it's empty unless the pod has IPs of both families, as
+// on dual-stack clusters
+func FamilyIPs(podIPs []v1.PodIP) map[v1.IPFamily]string {
+	ips := map[v1.IPFamily]string{}
+	for _, podIP := range podIPs {
+		family := IPFamilyOf(podIP.IP)
+		if _, ok := ips[family]; !ok {
+			ips[family] = podIP.IP
+		}
+	}
+	if len(ips) < 2 {
+		return map[v1.IPFamily]string{}
+	}
+	return ips
+}
+
+// IPFamilies lists the IP families all the pods have addresses of; it's empty unless all the pods are dual-stack
+func (r *Resources) IPFamilies() []v1.IPFamily {
+	counts := map[v1.IPFamily]int{}
+	for _, pod := range r.Pods {
+		for family := range pod.FamilyIPs {
+			counts[family]++
+		}
+	}
+	var families []v1.IPFamily
+	for family, count := range counts {
+		if count == len(r.Pods) {
+			families = append(families, family)
+		}
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i] < families[j]
+	})
+	return families
+}
+
+// ForIPFamily returns a copy of the resources where each pod's IP is its IP of the family, so that probing by pod IP
+// -- and simulating the probe, i.e. matching ipBlocks -- uses that family.  It should not affect the original Resources
+// object.
+func (r *Resources) ForIPFamily(family v1.IPFamily) (*Resources, error) {
+	var pods []*Pod
+	for _, pod := range r.Pods {
+		ip, ok := pod.FamilyIPs[family]
+		if !ok {
+			return nil, errors.Errorf("pod %s/%s has no %s address", pod.Namespace, pod.Name, family)
+		}
+		podCopy := *pod
+		podCopy.IP = ip
+		pods = append(pods, &podCopy)
+	}
+	return &Resources{
+		Namespaces: r.Namespaces,
+		Pods:       pods,
+	}, nil
+}
+
+// IsPrimaryIPFamily is true if every pod's IP -- the one probes by pod IP go to -- is of the family
+func (r *Resources) IsPrimaryIPFamily(family v1.IPFamily) bool {
+	for _, pod := range r.Pods {
+		if IPFamilyOf(pod.IP) != family {
+			return false
+		}
+	}
+	return len(r.Pods) > 0
+}
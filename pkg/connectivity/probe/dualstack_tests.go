@@ -0,0 +1,49 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunDualStackTests() {
+	Describe("Dual-stack", func() {
+		It("Should find pod IPs by family, only for pods with both", func() {
+			Expect(FamilyIPs([]v1.PodIP{{IP: "10.244.0.5"}, {IP: "fd00:10:244::5"}})).To(Equal(map[v1.IPFamily]string{
+				v1.IPv4Protocol: "10.244.0.5",
+				v1.IPv6Protocol: "fd00:10:244::5",
+			}))
+			Expect(FamilyIPs([]v1.PodIP{{IP: "10.244.0.5"}})).To(BeEmpty())
+			Expect(IPFamilyOf("::ffff:10.244.0.5")).To(Equal(v1.IPv4Protocol))
+		})
+
+		It("Should switch pod IPs to a family nondestructively", func() {
+			r := &Resources{
+				Namespaces: map[string]map[string]string{"x": {}},
+				Pods: []*Pod{
+					{Namespace: "x", Name: "a", IP: "10.244.0.5", FamilyIPs: map[v1.IPFamily]string{v1.IPv4Protocol: "10.244.0.5", v1.IPv6Protocol: "fd00::5"}},
+					{Namespace: "x", Name: "b", IP: "10.244.0.6", FamilyIPs: map[v1.IPFamily]string{v1.IPv4Protocol: "10.244.0.6", v1.IPv6Protocol: "fd00::6"}},
+				},
+			}
+			Expect(r.IPFamilies()).To(Equal([]v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}))
+			Expect(r.IsPrimaryIPFamily(v1.IPv4Protocol)).To(BeTrue())
+
+			r6, err := r.ForIPFamily(v1.IPv6Protocol)
+			Expect(err).To(Succeed())
+			Expect(r6.Pods[1].IP).To(Equal("fd00::6"))
+			Expect(r6.IsPrimaryIPFamily(v1.IPv6Protocol)).To(BeTrue())
+			Expect(r.Pods[1].IP).To(Equal("10.244.0.6"))
+
+			r.Pods = append(r.Pods, &Pod{Namespace: "x", Name: "c", IP: "10.244.0.7"})
+			Expect(r.IPFamilies()).To(BeEmpty())
+			_, err = r.ForIPFamily(v1.IPv6Protocol)
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("Should bracket IPv6 destinations", func() {
+			job := &Job{ToHost: "fd00::6", ResolvedPort: 80, Protocol: v1.ProtocolTCP}
+			Expect(job.ToAddress()).To(Equal("[fd00::6]:80"))
+		})
+	})
+
+}
@@ -0,0 +1,155 @@
+package probe
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// EgressPathKey is the destination key of egress path jobs, which all go to the same place outside the cluster
+const EgressPathKey = "egress"
+
+// EgressPath is a way out of the cluster to probe, for clusters which force egress through a proxy or gateway:
+// Target, an http or https URL outside the cluster, is requested through Proxy -- an http, https or socks5 proxy
+// URL -- or by way of Gateway, an egress gateway's host:port which connections go to in place of the target's own
+// address.  If neither is set, Target is requested directly.
+//
+// Requests are made with curl, which is in the agnhost image.  Since the first hop is all that pods' policies see,
+// jobs are to the proxy or gateway, and can only be checked against policies if it's given by IP.
+type EgressPath struct {
+	Target  *url.URL
+	Proxy   *url.URL
+	Gateway string
+	// Required means that results which differ from what policies allow fail the test case, rather than only being
+	// reported; it's only meaningful if the path is verifiable
+	Required bool
+}
+
+func NewEgressPath(target string, proxy string, gateway string) (*EgressPath, error) {
+	if proxy != "" && gateway != "" {
+		return nil, errors.Errorf("egress path can't have both a proxy and a gateway")
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse egress target '%s'", target)
+	}
+	if targetURL.Scheme != "http" && targetURL.Scheme != "https" || targetURL.Host == "" {
+		return nil, errors.Errorf("egress target '%s' must be an http or https URL", target)
+	}
+	path := &EgressPath{Target: targetURL, Gateway: gateway}
+	if proxy != "" {
+		path.Proxy, err = url.Parse(proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse egress proxy '%s'", proxy)
+		}
+		switch path.Proxy.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, errors.Errorf("egress proxy '%s' must be an http, https, socks5 or socks5h URL", proxy)
+		}
+		if path.Proxy.Port() == "" {
+			return nil, errors.Errorf("egress proxy '%s' must have a port", proxy)
+		}
+	}
+	if gateway != "" {
+		if _, _, err := net.SplitHostPort(gateway); err != nil {
+			return nil, errors.Wrapf(err, "egress gateway '%s' must be host:port", gateway)
+		}
+	}
+	return path, nil
+}
+
+// FirstHop is the address pods connect to: the proxy, the gateway, or the target
+func (e *EgressPath) FirstHop() (string, int) {
+	var host, port string
+	switch {
+	case e.Proxy != nil:
+		host, port = e.Proxy.Hostname(), e.Proxy.Port()
+	case e.Gateway != "":
+		host, port, _ = net.SplitHostPort(e.Gateway)
+	default:
+		host, port = e.Target.Hostname(), e.Target.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443"}[e.Target.Scheme]
+		}
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		panic(errors.Wrapf(err, "invalid port '%s' for egress path %s", port, e.String()))
+	}
+	return host, portNumber
+}
+
+// IsVerifiable is true if the first hop is an IP, which policies' ipBlocks can be checked against
+func (e *EgressPath) IsVerifiable() bool {
+	host, _ := e.FirstHop()
+	return net.ParseIP(host) != nil
+}
+
+func (e *EgressPath) clientCommand() []string {
+	command := []string{"curl", "-sS", "-o", "/dev/null", "--connect-timeout", "1", "--max-time", "3"}
+	switch {
+	case e.Proxy != nil:
+		command = append(command, "--proxy", e.Proxy.String())
+	case e.Gateway != "":
+		command = append(command, "--connect-to", "::"+e.Gateway)
+	}
+	return append(command, e.Target.String())
+}
+
+// Runner returns a kube runner which requests the target, instead of running agnhost, for TCP jobs
+func (e *EgressPath) Runner(kubernetes kube.IKubernetes, workers int) (*Runner, error) {
+	clientCommands, err := NewClientCommands(map[v1.Protocol]*ClientCommandTemplate{
+		v1.ProtocolTCP: {Command: e.clientCommand()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewKubeRunner(kubernetes, workers, clientCommands), nil
+}
+
+// Jobs returns a TCP job from every pod to the first hop
+func (e *EgressPath) Jobs(resources *Resources) []*Job {
+	host, port := e.FirstHop()
+	var jobs []*Job
+	for _, pod := range resources.Pods {
+		jobs = append(jobs, &Job{
+			FromKey:             pod.PodString().String(),
+			FromNamespace:       pod.Namespace,
+			FromNamespaceLabels: resources.Namespaces[pod.Namespace],
+			FromPod:             pod.Name,
+			FromPodLabels:       pod.Labels,
+			FromContainer:       pod.ClientContainer(),
+			FromIP:              pod.IP,
+			ToKey:               EgressPathKey,
+			ToHost:              host,
+			ToIP:                host,
+			ResolvedPort:        port,
+			Protocol:            v1.ProtocolTCP,
+		})
+	}
+	return jobs
+}
+
+// Traffic is a job's traffic to the first hop, as an IP outside the cluster
+func (e *EgressPath) Traffic(job *Job) *matcher.Traffic {
+	traffic := job.Traffic()
+	traffic.Destination = &matcher.TrafficPeer{IP: job.ToIP}
+	return traffic
+}
+
+func (e *EgressPath) String() string {
+	switch {
+	case e.Proxy != nil:
+		return fmt.Sprintf("%s through proxy %s", e.Target.String(), e.Proxy.Redacted())
+	case e.Gateway != "":
+		return fmt.Sprintf("%s through gateway %s", e.Target.String(), e.Gateway)
+	default:
+		return e.Target.String()
+	}
+}
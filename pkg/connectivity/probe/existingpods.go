@@ -0,0 +1,178 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"time"
+)
+
+const (
+	proberContainerName = "cyclonus-prober"
+	namespaceNameLabel  = "kubernetes.io/metadata.name"
+)
+
+// NewResourcesFromExistingPods builds Resources out of pods that are already running in the cluster, instead of
+// creating cyclonus's own server pods.  Every declared container port becomes a probe destination, and probes are
+// issued from an ephemeral agnhost container injected into each pod, since the pods' own images can't be assumed
+// to have a client.  Since cyclonus doesn't create services for these pods, they can only be probed by pod IP.
+func NewResourcesFromExistingPods(kubernetes kube.IKubernetes, namespaces []string, podSelector string, timeoutSeconds int) (*Resources, error) {
+	r, err := ReadExistingPods(kubernetes, namespaces, podSelector)
+	if err != nil {
+		return nil, err
+	}
+	if err := EnsureProberContainers(kubernetes, r.Pods, timeoutSeconds); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ReadExistingPods is NewResourcesFromExistingPods without injecting prober containers, for callers which only
+// probe from some of the pods; those need EnsureProberContainers before probing.
+func ReadExistingPods(kubernetes kube.IKubernetes, namespaces []string, podSelector string) (*Resources, error) {
+	selector, err := labels.Parse(podSelector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse pod selector '%s'", podSelector)
+	}
+
+	r := &Resources{Namespaces: map[string]map[string]string{}}
+	zones := &nodeZones{kubernetes: kubernetes, zones: map[string]string{}}
+	for _, ns := range namespaces {
+		kubeNamespace, err := kubernetes.GetNamespace(ns)
+		if err != nil {
+			return nil, err
+		}
+		r.Namespaces[ns] = kubeNamespace.Labels
+
+		kubePods, err := kubernetes.GetPodsInNamespace(ns)
+		if err != nil {
+			return nil, err
+		}
+		for _, kubePod := range kubePods {
+			if !selector.Matches(labels.Set(kubePod.Labels)) {
+				continue
+			}
+			if kubePod.Status.Phase != v1.PodRunning || kubePod.Status.PodIP == "" || kubePod.Spec.HostNetwork {
+				logrus.Warnf("skipping pod %s/%s: not running, no IP, or on the host network", ns, kubePod.Name)
+				continue
+			}
+			pod := existingPod(kubePod)
+			pod.Zone, err = zones.zone(pod.NodeName)
+			if err != nil {
+				return nil, err
+			}
+			r.Pods = append(r.Pods, pod)
+		}
+	}
+	if len(r.Pods) == 0 {
+		return nil, errors.Errorf("no running pods matching '%s' found in namespaces %+v", podSelector, namespaces)
+	}
+	return r, nil
+}
+
+func EnsureProberContainers(kubernetes kube.IKubernetes, pods []*Pod, timeoutSeconds int) error {
+	for _, pod := range pods {
+		if err := ensureProberContainer(kubernetes, pod.Namespace, pod.Name, timeoutSeconds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewResourcesFromKubePods builds Resources out of pods and namespaces which were read from kube or from manifests,
+// for simulated probes: unlike NewResourcesFromExistingPods, nothing is done to the pods.  Namespaces which the pods
+// are in, but which weren't read, only get the automatic kubernetes.io/metadata.name label.
+func NewResourcesFromKubePods(kubePods []v1.Pod, kubeNamespaces []v1.Namespace) *Resources {
+	r := &Resources{Namespaces: map[string]map[string]string{}}
+	for _, ns := range kubeNamespaces {
+		r.Namespaces[ns.Name] = ns.Labels
+	}
+	for _, kubePod := range kubePods {
+		if _, ok := r.Namespaces[kubePod.Namespace]; !ok {
+			r.Namespaces[kubePod.Namespace] = map[string]string{namespaceNameLabel: kubePod.Namespace}
+		}
+		r.Pods = append(r.Pods, existingPod(kubePod))
+	}
+	return r
+}
+
+func existingPod(kubePod v1.Pod) *Pod {
+	var containers []*Container
+	for _, kubeContainer := range kubePod.Spec.Containers {
+		if IsMeshSidecar(kubeContainer.Name) {
+			logrus.Warnf("ignoring service mesh sidecar %s in pod %s/%s", kubeContainer.Name, kubePod.Namespace, kubePod.Name)
+			continue
+		}
+		for _, port := range kubeContainer.Ports {
+			protocol := port.Protocol
+			if protocol == "" {
+				protocol = v1.ProtocolTCP
+			}
+			containers = append(containers, &Container{
+				Name:     kubeContainer.Name,
+				Port:     int(port.ContainerPort),
+				Protocol: protocol,
+				PortName: port.Name,
+			})
+		}
+	}
+	return &Pod{
+		Namespace:      kubePod.Namespace,
+		Name:           kubePod.Name,
+		Labels:         kubePod.Labels,
+		IP:             kubePod.Status.PodIP,
+		NodeName:       kubePod.Spec.NodeName,
+		Containers:     containers,
+		ProbeContainer: proberContainerName,
+	}
+}
+
+func ensureProberContainer(kubernetes kube.IKubernetes, ns string, podName string, timeoutSeconds int) error {
+	return EnsureEphemeralContainer(kubernetes, ns, podName, v1.EphemeralContainer{
+		EphemeralContainerCommon: v1.EphemeralContainerCommon{
+			Name:            proberContainerName,
+			Image:           agnhostImage,
+			ImagePullPolicy: v1.PullIfNotPresent,
+			Command:         []string{"/agnhost", "pause"},
+		},
+	}, timeoutSeconds)
+}
+
+// EnsureEphemeralContainer adds container to a pod, unless the pod already has an ephemeral container by that name --
+// ephemeral containers can't be removed -- and waits for it to be running
+func EnsureEphemeralContainer(kubernetes kube.IKubernetes, ns string, podName string, container v1.EphemeralContainer, timeoutSeconds int) error {
+	kubePod, err := kubernetes.GetPod(ns, podName)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, cont := range kubePod.Spec.EphemeralContainers {
+		if cont.Name == container.Name {
+			found = true
+		}
+	}
+	if !found {
+		err = kubernetes.CreateEphemeralContainer(ns, podName, container)
+		if err != nil {
+			return err
+		}
+	}
+
+	sleep := 2
+	for i := 0; i <= timeoutSeconds; i += sleep {
+		kubePod, err = kubernetes.GetPod(ns, podName)
+		if err != nil {
+			return err
+		}
+		for _, status := range kubePod.Status.EphemeralContainerStatuses {
+			if status.Name == container.Name && status.State.Running != nil {
+				return nil
+			}
+		}
+		logrus.Infof("waiting for %s container in pod %s/%s", container.Name, ns, podName)
+		time.Sleep(time.Duration(sleep) * time.Second)
+	}
+	return errors.Errorf("%s container in pod %s/%s not running after %d seconds", container.Name, ns, podName, timeoutSeconds)
+}
@@ -0,0 +1,93 @@
+package probe
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	ExternalEndpointNamespace = "cyclonus-external"
+	ExternalEndpointPod       = "echo"
+)
+
+// NewExternalEndpointPath is an egress path straight to an HTTP server outside the pod network at endpoint, an
+// IP:port.  Since it's addressed by IP, results can be checked against ipBlocks, and they're required to match.
+func NewExternalEndpointPath(endpoint string) (*EgressPath, error) {
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "external endpoint '%s' must be IP:port", endpoint)
+	}
+	if net.ParseIP(host) == nil {
+		return nil, errors.Errorf("external endpoint '%s' must be IP:port", endpoint)
+	}
+	path, err := NewEgressPath(fmt.Sprintf("http://%s/", endpoint), "", "")
+	if err != nil {
+		return nil, err
+	}
+	path.Required = true
+	return path, nil
+}
+
+func externalEndpointKubePod(port int) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ExternalEndpointPod,
+			Namespace: ExternalEndpointNamespace,
+			Labels:    map[string]string{"pod": ExternalEndpointPod},
+		},
+		Spec: v1.PodSpec{
+			// the host's network is outside the pod network, so its IP is one which only ipBlocks can match
+			HostNetwork: true,
+			Containers: []v1.Container{
+				{
+					Name:            ExternalEndpointPod,
+					ImagePullPolicy: v1.PullIfNotPresent,
+					Image:           agnhostImage,
+					Command:         []string{"/agnhost", "netexec", "--http-port", strconv.Itoa(port), "--udp-port", "-1"},
+					Ports:           []v1.ContainerPort{{ContainerPort: int32(port), Protocol: v1.ProtocolTCP}},
+				},
+			},
+		},
+	}
+}
+
+// DeployExternalEndpoint runs an HTTP echo server on port in the network of one of the cluster's nodes, in its own
+// namespace, and returns its IP:port once it's running.  An endpoint which is already deployed is reused.
+func DeployExternalEndpoint(kubernetes kube.IKubernetes, port int, timeoutSeconds int) (string, error) {
+	if _, err := kubernetes.GetNamespace(ExternalEndpointNamespace); err != nil {
+		if _, err := kubernetes.CreateNamespace(KubeNamespace(ExternalEndpointNamespace, map[string]string{"ns": ExternalEndpointNamespace})); err != nil {
+			return "", err
+		}
+	}
+	if _, err := kubernetes.GetPod(ExternalEndpointNamespace, ExternalEndpointPod); err != nil {
+		if _, err := kubernetes.CreatePod(externalEndpointKubePod(port)); err != nil {
+			return "", err
+		}
+	}
+
+	sleep := 5
+	for i := 0; i < timeoutSeconds; i += sleep {
+		pod, err := kubernetes.GetPod(ExternalEndpointNamespace, ExternalEndpointPod)
+		if err != nil {
+			return "", err
+		}
+		if pod.Status.Phase == v1.PodRunning && pod.Status.PodIP != "" {
+			return net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(port)), nil
+		}
+		logrus.Infof("waiting for external endpoint %s/%s to be running", ExternalEndpointNamespace, ExternalEndpointPod)
+		time.Sleep(time.Duration(sleep) * time.Second)
+	}
+	return "", errors.Errorf("external endpoint %s/%s not ready", ExternalEndpointNamespace, ExternalEndpointPod)
+}
+
+// DeleteExternalEndpoint cleans up a deployed external endpoint, along with its namespace
+func DeleteExternalEndpoint(kubernetes kube.IKubernetes) error {
+	return kubernetes.DeleteNamespace(ExternalEndpointNamespace)
+}
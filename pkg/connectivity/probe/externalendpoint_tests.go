@@ -0,0 +1,45 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunExternalEndpointTests() {
+	Describe("External endpoint", func() {
+		It("Should require results for an external endpoint to match", func() {
+			_, err := NewExternalEndpointPath("www.example.com:80")
+			Expect(err).NotTo(Succeed())
+			_, err = NewExternalEndpointPath("10.0.0.7")
+			Expect(err).NotTo(Succeed())
+
+			endpoint, err := NewExternalEndpointPath("10.0.0.7:8080")
+			Expect(err).To(Succeed())
+			Expect(endpoint.Required).To(BeTrue())
+			Expect(endpoint.IsVerifiable()).To(BeTrue())
+			host, port := endpoint.FirstHop()
+			Expect(host).To(Equal("10.0.0.7"))
+			Expect(port).To(Equal(8080))
+
+			ipv6, err := NewExternalEndpointPath("[fd00::7]:8080")
+			Expect(err).To(Succeed())
+			Expect(ipv6.clientCommand()).To(ContainElement("http://[fd00::7]:8080/"))
+		})
+
+		It("Should deploy an external endpoint in the host network", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			endpoint, err := DeployExternalEndpoint(kubernetes, 8080, 5)
+			Expect(err).To(Succeed())
+			pod, err := kubernetes.GetPod(ExternalEndpointNamespace, ExternalEndpointPod)
+			Expect(err).To(Succeed())
+			Expect(pod.Spec.HostNetwork).To(BeTrue())
+			Expect(endpoint).To(Equal(pod.Status.PodIP + ":8080"))
+
+			again, err := DeployExternalEndpoint(kubernetes, 8080, 5)
+			Expect(err).To(Succeed())
+			Expect(again).To(Equal(endpoint))
+			Expect(DeleteExternalEndpoint(kubernetes)).To(Succeed())
+		})
+	})
+}
@@ -0,0 +1,162 @@
+package probe
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HTTPCheck records what a TCP probe got when, after connecting, it made an HTTP request: a connection can be
+// accepted -- by a proxy, or by a CNI which then blackholes the rest of the traffic -- without the serving pod ever
+// answering
+type HTTPCheck struct {
+	Connected  bool
+	StatusCode int
+	Body       string
+	// ExpectedBody is the serving pod's hostname, which agnhost answers with; if empty, any body is accepted
+	ExpectedBody string
+}
+
+// IsVerified is true if the serving pod answered the request
+func (h *HTTPCheck) IsVerified() bool {
+	return h.Connected && h.StatusCode == http.StatusOK && (h.ExpectedBody == "" || h.Body == h.ExpectedBody)
+}
+
+// IsPartial is true for connections which were accepted, but whose request the serving pod didn't answer
+func (h *HTTPCheck) IsPartial() bool {
+	return h.Connected && !h.IsVerified()
+}
+
+// ShortString is the status code of partial connections -- 000 if there was no response -- marked with '!'
+func (h *HTTPCheck) ShortString() string {
+	switch {
+	case !h.Connected:
+		return ConnectivityBlocked.ShortString()
+	case h.IsVerified():
+		return ConnectivityAllowed.ShortString()
+	default:
+		return fmt.Sprintf("%03d!", h.StatusCode)
+	}
+}
+
+var httpCheckStatusRegex = regexp.MustCompile(`(?m)^status (\d{3})$`)
+
+// HTTPCheckCommand connects, like agnhost connect does for TCP, and only if that works requests / with curl; it prints
+// whether it connected, then the response body and status code
+func (j *Job) HTTPCheckCommand() []string {
+	script := fmt.Sprintf(
+		`if /agnhost connect %s --timeout=1s --protocol=tcp >/dev/null 2>&1; then echo connected; curl -sg --max-time 3 -w '\nstatus %%{http_code}\n' http://%s/ || true; else echo "not connected"; fi`,
+		j.ToAddress(), j.ToAddress())
+	return []string{"sh", "-c", script}
+}
+
+func parseHTTPCheckOutput(stdout string, expectedBody string) (*HTTPCheck, error) {
+	lines := strings.SplitN(stdout, "\n", 2)
+	switch strings.TrimSpace(lines[0]) {
+	case "not connected":
+		return &HTTPCheck{ExpectedBody: expectedBody}, nil
+	case "connected":
+	default:
+		return nil, errors.Errorf("unable to find connection status in output '%s'", stdout)
+	}
+	rest := ""
+	if len(lines) > 1 {
+		rest = lines[1]
+	}
+	matches := httpCheckStatusRegex.FindAllStringSubmatchIndex(rest, -1)
+	if len(matches) == 0 {
+		return nil, errors.Errorf("unable to find status code in output '%s'", stdout)
+	}
+	last := matches[len(matches)-1]
+	statusCode, err := strconv.Atoi(rest[last[2]:last[3]])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse status code %s", rest[last[2]:last[3]])
+	}
+	return &HTTPCheck{
+		Connected:    true,
+		StatusCode:   statusCode,
+		Body:         strings.TrimSpace(rest[:last[0]]),
+		ExpectedBody: expectedBody,
+	}, nil
+}
+
+// probeHTTP is allowed only if the serving pod answered the request; partial connections are blocked
+func probeHTTP(k8s kube.IKubernetes, job *Job) (Connectivity, *HTTPCheck, string) {
+	command := job.HTTPCheckCommand()
+	commandDebugString := strings.Join(job.kubeExecCommand(command), " ")
+	stdout, stderr, commandErr, err := k8s.ExecuteRemoteCommand(job.FromNamespace, job.FromPod, job.FromContainer, command)
+	logrus.Debugf("stdout, stderr from %s: \n%s\n%s", commandDebugString, stdout, stderr)
+	output := fmt.Sprintf("%s\nstdout:\n%s\nstderr:\n%s", commandDebugString, stdout, stderr)
+	if err != nil {
+		logrus.Errorf("unable to set up command %s: %+v", commandDebugString, err)
+		return ConnectivityCheckFailed, nil, fmt.Sprintf("%s\nunable to set up command: %+v", output, err)
+	}
+	if commandErr != nil {
+		logrus.Errorf("unable to run command %s: %+v", commandDebugString, commandErr)
+		return ConnectivityCheckFailed, nil, fmt.Sprintf("%s\ncommand error: %+v", output, commandErr)
+	}
+	check, err := parseHTTPCheckOutput(stdout, job.ToPod)
+	if err != nil {
+		logrus.Errorf("unable to get HTTP response from command %s: %+v", commandDebugString, err)
+		return ConnectivityCheckFailed, nil, fmt.Sprintf("%s\n%+v", output, err)
+	}
+	if !check.IsVerified() {
+		if check.IsPartial() {
+			logrus.Debugf("command %s connected, but got status %d and body '%s' instead of the serving pod's response", commandDebugString, check.StatusCode, check.Body)
+		}
+		return ConnectivityBlocked, check, output
+	}
+	return ConnectivityAllowed, check, output
+}
+
+// HasHTTPChecks is true if any job result has an HTTP check
+func (t *Table) HasHTTPChecks() bool {
+	for _, key := range t.Wrapped.Keys() {
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.HTTPCheck != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CountPartialHTTP counts the job results, across all cells, which connected but whose request wasn't answered by
+// the serving pod
+func (t *Table) CountPartialHTTP() int {
+	count := 0
+	for _, key := range t.Wrapped.Keys() {
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.HTTPCheck != nil && jobResult.HTTPCheck.IsPartial() {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// RenderHTTPChecks renders TCP job results, with the status codes of partial connections marked with '!'
+func (t *Table) RenderHTTPChecks() string {
+	table := NewTable(t.Wrapped.Froms)
+	for _, key := range t.Wrapped.Keys() {
+		for jobKey, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.Job.Protocol == v1.ProtocolTCP {
+				table.Get(key.From, key.To).JobResults[jobKey] = jobResult
+			}
+		}
+	}
+	return table.renderTableHelper(getHTTPCheck)
+}
+
+func getHTTPCheck(result *JobResult) string {
+	if result.HTTPCheck == nil {
+		return result.Combined.ShortString()
+	}
+	return result.HTTPCheck.ShortString()
+}
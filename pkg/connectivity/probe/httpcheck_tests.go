@@ -0,0 +1,46 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunHTTPCheckTests() {
+	Describe("HTTP checks", func() {
+		It("Should tell answered, unanswered and refused connections apart", func() {
+			answered, err := parseHTTPCheckOutput("connected\nb\nstatus 200\n", "b")
+			Expect(err).To(Succeed())
+			Expect(answered.IsVerified()).To(BeTrue())
+			Expect(answered.ShortString()).To(Equal("."))
+
+			// i.e. blackholed after the handshake
+			unanswered, err := parseHTTPCheckOutput("connected\n\nstatus 000\n", "b")
+			Expect(err).To(Succeed())
+			Expect(unanswered.IsPartial()).To(BeTrue())
+			Expect(unanswered.ShortString()).To(Equal("000!"))
+
+			// i.e. answered by a proxy
+			proxied, err := parseHTTPCheckOutput("connected\n<html>bad gateway</html>\nstatus 200\n", "b")
+			Expect(err).To(Succeed())
+			Expect(proxied.Body).To(Equal("<html>bad gateway</html>"))
+			Expect(proxied.IsPartial()).To(BeTrue())
+
+			refused, err := parseHTTPCheckOutput("not connected\n", "b")
+			Expect(err).To(Succeed())
+			Expect(refused.IsPartial()).To(BeFalse())
+			Expect(refused.ShortString()).To(Equal("X"))
+
+			_, err = parseHTTPCheckOutput("connected\n", "b")
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("Should serve HTTP from TCP containers", func() {
+			pod := NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP}, false, &PodOptions{ServeHTTP: true})
+			containers := pod.KubeContainers()
+			Expect(containers[0].Command).To(Equal([]string{"/agnhost", "serve-hostname", "--http", "--port", "80"}))
+			Expect(containers[1].Command).To(ContainElement("--udp"))
+		})
+	})
+
+}
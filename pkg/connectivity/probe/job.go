@@ -5,6 +5,9 @@ import (
 	"github.com/mattfenwick/cyclonus/pkg/matcher"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	"net"
+	"strconv"
+	"time"
 )
 
 type Jobs struct {
@@ -18,6 +21,16 @@ type JobResult struct {
 	Ingress  *Connectivity
 	Egress   *Connectivity
 	Combined Connectivity
+	// UDPDelivery is only set for UDP jobs which were probed with a burst of datagrams
+	UDPDelivery *UDPDelivery
+	// HTTPCheck is only set for TCP jobs which were probed with an HTTP request
+	HTTPCheck *HTTPCheck
+	// TLSCheck is only set for TCP jobs which were probed with a TLS handshake
+	TLSCheck *TLSCheck
+	// Latency is how long an allowed job's probe command took in the client pod; only set when measuring latency
+	Latency time.Duration
+	// Output is the probe command and what it printed, for debugging; only set by kube runners which record output
+	Output string
 }
 
 func (jr *JobResult) Key() string {
@@ -40,18 +53,24 @@ type Job struct {
 	ToPodLabels       map[string]string
 	ToContainer       string
 	ToIP              string
+	// ToPod is the serving pod's name, which agnhost answers HTTP requests with; empty if the target isn't a pod
+	ToPod string
 
 	ResolvedPort     int
 	ResolvedPortName string
 	Protocol         v1.Protocol
+
+	// Exchanges is how many times the client command is run, one after another; 0 is the same as 1
+	Exchanges int
 }
 
 func (j *Job) Key() string {
 	return fmt.Sprintf("%s/%s/%s/%s/%s/%d", j.FromKey, j.FromContainer, j.ToKey, j.ToContainer, j.Protocol, j.ResolvedPort)
 }
 
+// ToAddress brackets IPv6 hosts, i.e. [fd00::1]:80
 func (j *Job) ToAddress() string {
-	return fmt.Sprintf("%s:%d", j.ToHost, j.ResolvedPort)
+	return net.JoinHostPort(j.ToHost, strconv.Itoa(j.ResolvedPort))
 }
 
 func (j *Job) ClientCommand() []string {
@@ -68,6 +87,10 @@ func (j *Job) ClientCommand() []string {
 }
 
 func (j *Job) KubeExecCommand() []string {
+	return j.kubeExecCommand(j.ClientCommand())
+}
+
+func (j *Job) kubeExecCommand(command []string) []string {
 	return append([]string{
 		"kubectl", "exec",
 		j.FromPod,
@@ -75,7 +98,7 @@ func (j *Job) KubeExecCommand() []string {
 		"-n", j.FromNamespace,
 		"--",
 	},
-		j.ClientCommand()...)
+		command...)
 }
 
 func (j *Job) Traffic() *matcher.Traffic {
@@ -0,0 +1,72 @@
+package probe
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// JobFilter picks out probe jobs by protocol or by port: a job matching either is picked out.  A nil JobFilter
+// picks out nothing.
+type JobFilter struct {
+	Protocols []v1.Protocol
+	Ports     []int
+}
+
+func (f *JobFilter) IsEmpty() bool {
+	return f == nil || (len(f.Protocols) == 0 && len(f.Ports) == 0)
+}
+
+func (f *JobFilter) Matches(job *Job) bool {
+	if f == nil {
+		return false
+	}
+	for _, protocol := range f.Protocols {
+		if job.Protocol == protocol {
+			return true
+		}
+	}
+	for _, port := range f.Ports {
+		if job.ResolvedPort == port {
+			return true
+		}
+	}
+	return false
+}
+
+func filterJobs(jobs []*Job, filter *JobFilter) []*Job {
+	var kept []*Job
+	for _, job := range jobs {
+		if !filter.Matches(job) {
+			kept = append(kept, job)
+		}
+	}
+	return kept
+}
+
+// Without returns the jobs which filter doesn't pick out
+func (j *Jobs) Without(filter *JobFilter) *Jobs {
+	if filter.IsEmpty() {
+		return j
+	}
+	return &Jobs{
+		Valid:           filterJobs(j.Valid, filter),
+		BadNamedPort:    filterJobs(j.BadNamedPort, filter),
+		BadPortProtocol: filterJobs(j.BadPortProtocol, filter),
+	}
+}
+
+// Without returns a copy of the table without the job results which filter picks out.  It shares job results with
+// the original table.
+func (t *Table) Without(filter *JobFilter) *Table {
+	if filter.IsEmpty() {
+		return t
+	}
+	table := NewTable(t.Wrapped.Froms)
+	for _, key := range t.Wrapped.Keys() {
+		for jobKey, jobResult := range t.Get(key.From, key.To).JobResults {
+			if !filter.Matches(jobResult.Job) {
+				table.Get(key.From, key.To).JobResults[jobKey] = jobResult
+			}
+		}
+	}
+	return table
+}
@@ -0,0 +1,32 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunJobFilterTests() {
+	Describe("Job filters", func() {
+		It("Should leave out jobs and job results by protocol or port", func() {
+			filter := &JobFilter{Protocols: []v1.Protocol{v1.ProtocolUDP}, Ports: []int{81}}
+			tcp80 := &Job{FromKey: "x/a", ToKey: "x/b", Protocol: v1.ProtocolTCP, ResolvedPort: 80}
+			tcp81 := &Job{FromKey: "x/a", ToKey: "x/b", Protocol: v1.ProtocolTCP, ResolvedPort: 81}
+			udp80 := &Job{FromKey: "x/a", ToKey: "x/b", Protocol: v1.ProtocolUDP, ResolvedPort: 80}
+
+			jobs := (&Jobs{Valid: []*Job{tcp80, tcp81, udp80}}).Without(filter)
+			Expect(jobs.Valid).To(Equal([]*Job{tcp80}))
+			Expect((&Jobs{Valid: []*Job{tcp81}}).Without(nil).Valid).To(Equal([]*Job{tcp81}))
+
+			table := NewTable([]string{"x/a", "x/b"})
+			for _, job := range []*Job{tcp80, tcp81, udp80} {
+				Expect(table.Get("x/a", "x/b").AddJobResult(&JobResult{Job: job, Combined: ConnectivityAllowed})).To(Succeed())
+			}
+			filtered := table.Without(filter)
+			Expect(filtered.Get("x/a", "x/b").JobResults).To(HaveLen(1))
+			Expect(filtered.Get("x/a", "x/b").JobResults).To(HaveKey("TCP/80"))
+			Expect(table.Get("x/a", "x/b").JobResults).To(HaveLen(3))
+		})
+	})
+
+}
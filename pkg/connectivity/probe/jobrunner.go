@@ -1,25 +1,45 @@
 package probe
 
 import (
+	"context"
+	"fmt"
 	"github.com/mattfenwick/cyclonus/pkg/generator"
 	"github.com/mattfenwick/cyclonus/pkg/kube"
 	"github.com/mattfenwick/cyclonus/pkg/matcher"
 	"github.com/mattfenwick/cyclonus/pkg/utils"
 	"github.com/mattfenwick/cyclonus/pkg/worker"
 	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"regexp"
 	"strings"
+	"time"
 )
 
 type Runner struct {
 	JobRunner JobRunner
+	// CheckFailedRetryPolicy is for re-running jobs which couldn't be executed at all -- i.e. exec failures -- as
+	// opposed to jobs whose connection attempt was blocked
+	CheckFailedRetryPolicy kube.RetryPolicy
+	// Exclude picks out jobs which aren't run at all, and are left out of the results
+	Exclude *JobFilter
+	// Context, if set, keeps jobs from being started or retried once it's done; jobs which aren't run are unknown
+	Context context.Context
+}
+
+func (p *Runner) ctx() context.Context {
+	if p.Context == nil {
+		return context.Background()
+	}
+	return p.Context
 }
 
 func NewSimulatedRunner(policies *matcher.Policy) *Runner {
 	return &Runner{JobRunner: &SimulatedJobRunner{Policies: policies}}
 }
 
-func NewKubeRunner(kubernetes kube.IKubernetes, workers int) *Runner {
-	return &Runner{JobRunner: &KubeJobRunner{Kubernetes: kubernetes, Workers: workers}}
+// NewKubeRunner uses clientCommands to build probe commands; if nil, agnhost is used
+func NewKubeRunner(kubernetes kube.IKubernetes, workers int, clientCommands *ClientCommands) *Runner {
+	return &Runner{JobRunner: &KubeJobRunner{Kubernetes: kubernetes, Workers: workers, ClientCommands: clientCommands}}
 }
 
 func NewKubeBatchRunner(kubernetes kube.IKubernetes, workers int) *Runner {
@@ -27,11 +47,16 @@ func NewKubeBatchRunner(kubernetes kube.IKubernetes, workers int) *Runner {
 }
 
 func (p *Runner) RunProbeForConfig(probeConfig *generator.ProbeConfig, resources *Resources) *Table {
-	return NewTableFromJobResults(resources, p.runProbe(resources.GetJobsForProbeConfig(probeConfig)))
+	return NewTableFromJobResults(resources, p.runProbe(resources.GetJobsForProbeConfig(probeConfig).Without(p.Exclude)))
+}
+
+// RunJobs runs jobs which were built some other way than from a ProbeConfig
+func (p *Runner) RunJobs(jobs *Jobs) []*JobResult {
+	return p.runProbe(jobs.Without(p.Exclude))
 }
 
 func (p *Runner) runProbe(jobs *Jobs) []*JobResult {
-	resultSlice := p.JobRunner.RunJobs(jobs.Valid)
+	resultSlice := p.runJobsRetryingCheckFailures(jobs.Valid)
 
 	invalidPP := ConnectivityInvalidPortProtocol
 	unknown := ConnectivityUnknown
@@ -57,6 +82,40 @@ func (p *Runner) runProbe(jobs *Jobs) []*JobResult {
 	return resultSlice
 }
 
+func (p *Runner) runJobsRetryingCheckFailures(jobs []*Job) []*JobResult {
+	if p.ctx().Err() != nil {
+		logrus.Warnf("not running %d jobs: %+v", len(jobs), p.ctx().Err())
+		var results []*JobResult
+		for _, job := range jobs {
+			results = append(results, &JobResult{Job: job, Combined: ConnectivityUnknown})
+		}
+		return results
+	}
+	results := p.JobRunner.RunJobs(jobs)
+	for retry := 1; retry <= p.CheckFailedRetryPolicy.Retries; retry++ {
+		var succeeded []*JobResult
+		var failedJobs []*Job
+		for _, result := range results {
+			if result.Combined == ConnectivityCheckFailed {
+				failedJobs = append(failedJobs, result.Job)
+			} else {
+				succeeded = append(succeeded, result)
+			}
+		}
+		if len(failedJobs) == 0 {
+			break
+		}
+		backoff := p.CheckFailedRetryPolicy.BackoffForRetry(retry)
+		logrus.Warnf("%d jobs failed to execute, retry %d of %d in %s", len(failedJobs), retry, p.CheckFailedRetryPolicy.Retries, backoff)
+		if err := utils.Sleep(p.ctx(), backoff); err != nil {
+			logrus.Warnf("not retrying %d jobs: %+v", len(failedJobs), err)
+			break
+		}
+		results = append(succeeded, p.JobRunner.RunJobs(failedJobs)...)
+	}
+	return results
+}
+
 type JobRunner interface {
 	RunJobs(job []*Job) []*JobResult
 }
@@ -94,11 +153,46 @@ func (s *SimulatedJobRunner) RunJob(job *Job) *JobResult {
 }
 
 type KubeJobRunner struct {
-	Kubernetes kube.IKubernetes
-	Workers    int
+	Kubernetes     kube.IKubernetes
+	Workers        int
+	ClientCommands *ClientCommands
+	// UDPBurstSize, if positive, is how many sequenced datagrams to send for each UDP job, instead of just one, so that
+	// delivery rates can be reported.  Not used for protocols with client commands.
+	UDPBurstSize int
+	// HTTPCheck, if set, makes each TCP job request / over HTTP once it's connected, and only allows it if the serving
+	// pod answers, so that accepted but unanswered connections stand out.  Not used for protocols with client
+	// commands.
+	HTTPCheck bool
+	// TLSCertificate, if set, makes each TCP job do a TLS handshake -- trusting only this certificate -- and request
+	// the serving pod's hostname once it's connected, and only allows it if both work, so that connections accepted
+	// by a mesh proxy stand out.  Not used for protocols with client commands.
+	TLSCertificate *TLSCertificate
+	// MeasureLatency times each probe command in the client pod, and records how long allowed probes took.  UDP
+	// bursts and HTTP checks aren't timed.
+	MeasureLatency bool
+	// RecordOutput keeps each job's command output in its result
+	RecordOutput bool
+	// MultiTarget runs all of a source container's jobs in a single exec, instead of one exec per job, so that a full
+	// matrix takes one exec per pod.  Not used for protocols with client commands, and incompatible with UDP bursts,
+	// HTTP and TLS checks, and latency.
+	MultiTarget bool
+	// SourcePodParallelism, if positive, groups jobs up by source pod, and runs up to this many pods' jobs at once --
+	// each pod's one after another -- instead of Workers jobs at once, whichever pods they're from.  Execs are then
+	// spread across pods, rather than piling up on the first ones.
+	SourcePodParallelism int
 }
 
 func (k *KubeJobRunner) RunJobs(jobs []*Job) []*JobResult {
+	if k.MultiTarget {
+		return k.runMultiTargetJobs(jobs)
+	}
+	return k.runSingleTargetJobs(jobs)
+}
+
+func (k *KubeJobRunner) runSingleTargetJobs(jobs []*Job) []*JobResult {
+	if k.SourcePodParallelism > 0 {
+		return k.runJobsBySourcePod(jobs)
+	}
 	size := len(jobs)
 	jobsChan := make(chan *Job, size)
 	resultsChan := make(chan *JobResult, size)
@@ -123,32 +217,200 @@ func (k *KubeJobRunner) RunJobs(jobs []*Job) []*JobResult {
 // it only writes pass/fail status to a channel and has no failure side effects, this is by design since we do not want to fail inside a goroutine.
 func (k *KubeJobRunner) worker(jobs <-chan *Job, results chan<- *JobResult) {
 	for job := range jobs {
-		connectivity, _ := probeConnectivity(k.Kubernetes, job)
-		results <- &JobResult{
+		if k.UDPBurstSize > 0 && job.Protocol == v1.ProtocolUDP && !k.ClientCommands.HasCommand(job.Protocol) {
+			connectivity, delivery := probeUDPBurst(k.Kubernetes, job, k.UDPBurstSize)
+			results <- &JobResult{
+				Job:         job,
+				Combined:    connectivity,
+				UDPDelivery: delivery,
+			}
+			continue
+		}
+		if k.HTTPCheck && job.Protocol == v1.ProtocolTCP && !k.ClientCommands.HasCommand(job.Protocol) {
+			connectivity, check, output := probeHTTP(k.Kubernetes, job)
+			result := &JobResult{
+				Job:       job,
+				Combined:  connectivity,
+				HTTPCheck: check,
+			}
+			if k.RecordOutput {
+				result.Output = output
+			}
+			results <- result
+			continue
+		}
+		if k.TLSCertificate != nil && job.Protocol == v1.ProtocolTCP && !k.ClientCommands.HasCommand(job.Protocol) {
+			connectivity, check, output := probeTLS(k.Kubernetes, job, k.TLSCertificate)
+			result := &JobResult{
+				Job:      job,
+				Combined: connectivity,
+				TLSCheck: check,
+			}
+			if k.RecordOutput {
+				result.Output = output
+			}
+			results <- result
+			continue
+		}
+		connectivity, latency, output := probeConnectivity(k.Kubernetes, k.ClientCommands, job, k.MeasureLatency)
+		result := &JobResult{
 			Job:      job,
 			Combined: connectivity,
+			Latency:  latency,
+		}
+		if k.RecordOutput {
+			result.Output = output
+		}
+		results <- result
+	}
+}
+
+// groupJobsBySource groups up jobs by source container, in the order the sources first show up
+func groupJobsBySource(jobs []*Job) [][]*Job {
+	var groups [][]*Job
+	indexes := map[string]int{}
+	for _, job := range jobs {
+		source := job.FromKey + "/" + job.FromContainer
+		index, ok := indexes[source]
+		if !ok {
+			index = len(groups)
+			indexes[source] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], job)
+	}
+	return groups
+}
+
+// sourceWorkers is how many sources' jobs are run at once, when they're grouped by source
+func (k *KubeJobRunner) sourceWorkers() int {
+	if k.SourcePodParallelism > 0 {
+		return k.SourcePodParallelism
+	}
+	return k.Workers
+}
+
+// runJobsBySourcePod runs up to SourcePodParallelism sources' jobs at once, each source's one after another
+func (k *KubeJobRunner) runJobsBySourcePod(jobs []*Job) []*JobResult {
+	groups := groupJobsBySource(jobs)
+	groupsChan := make(chan []*Job, len(groups))
+	resultsChan := make(chan *JobResult, len(jobs))
+	for i := 0; i < k.sourceWorkers(); i++ {
+		go func() {
+			for group := range groupsChan {
+				jobsChan := make(chan *Job, len(group))
+				for _, job := range group {
+					jobsChan <- job
+				}
+				close(jobsChan)
+				k.worker(jobsChan, resultsChan)
+			}
+		}()
+	}
+	for _, group := range groups {
+		groupsChan <- group
+	}
+	close(groupsChan)
+
+	var resultSlice []*JobResult
+	for range jobs {
+		resultSlice = append(resultSlice, <-resultsChan)
+	}
+	return resultSlice
+}
+
+// runMultiTargetJobs groups up jobs by source container, and runs each group in a single exec, with groups run by
+// concurrent workers
+func (k *KubeJobRunner) runMultiTargetJobs(jobs []*Job) []*JobResult {
+	var singleTargetJobs []*Job
+	var multiTargetJobs []*Job
+	for _, job := range jobs {
+		if k.ClientCommands.HasCommand(job.Protocol) {
+			singleTargetJobs = append(singleTargetJobs, job)
+		} else {
+			multiTargetJobs = append(multiTargetJobs, job)
+		}
+	}
+	groups := groupJobsBySource(multiTargetJobs)
+
+	groupsChan := make(chan []*Job, len(groups))
+	resultsChan := make(chan []*JobResult, len(groups))
+	for i := 0; i < k.sourceWorkers(); i++ {
+		go func() {
+			for group := range groupsChan {
+				resultsChan <- probeMultiTarget(k.Kubernetes, group, k.RecordOutput)
+			}
+		}()
+	}
+	for _, group := range groups {
+		groupsChan <- group
+	}
+	close(groupsChan)
+
+	resultSlice := k.runSingleTargetJobs(singleTargetJobs)
+	for range groups {
+		resultSlice = append(resultSlice, <-resultsChan...)
+	}
+	return resultSlice
+}
+
+// probeConnectivity returns the job's connectivity, along with the output of the last command run, and -- if
+// measuring latency -- how long it took
+func probeConnectivity(k8s kube.IKubernetes, clientCommands *ClientCommands, job *Job, measureLatency bool) (Connectivity, time.Duration, string) {
+	command, successRegex, err := clientCommands.Command(job)
+	if err != nil {
+		logrus.Errorf("unable to build client command: %+v", err)
+		return ConnectivityCheckFailed, 0, fmt.Sprintf("unable to build client command: %+v", err)
+	}
+	if measureLatency {
+		command = timedCommand(command)
+	}
+	commandDebugString := strings.Join(job.kubeExecCommand(command), " ")
+	// every exchange has to get through: a CNI which loses track of a connection's return traffic partway through
+	// will fail the later ones
+	for i := 1; i < job.Exchanges; i++ {
+		if connectivity, latency, output := runClientCommand(k8s, job, command, successRegex, commandDebugString, measureLatency); connectivity != ConnectivityAllowed {
+			return connectivity, latency, output
 		}
 	}
+	return runClientCommand(k8s, job, command, successRegex, commandDebugString, measureLatency)
 }
 
-func probeConnectivity(k8s kube.IKubernetes, job *Job) (Connectivity, string) {
-	commandDebugString := strings.Join(job.KubeExecCommand(), " ")
-	stdout, stderr, commandErr, err := k8s.ExecuteRemoteCommand(job.FromNamespace, job.FromPod, job.FromContainer, job.ClientCommand())
+func runClientCommand(k8s kube.IKubernetes, job *Job, command []string, successRegex *regexp.Regexp, commandDebugString string, measureLatency bool) (Connectivity, time.Duration, string) {
+	stdout, stderr, commandErr, err := k8s.ExecuteRemoteCommand(job.FromNamespace, job.FromPod, job.FromContainer, command)
 	logrus.Debugf("stdout, stderr from %s: \n%s\n%s", commandDebugString, stdout, stderr)
+	output := fmt.Sprintf("%s\nstdout:\n%s\nstderr:\n%s", commandDebugString, stdout, stderr)
 	if err != nil {
 		logrus.Errorf("unable to set up command %s: %+v", commandDebugString, err)
-		return ConnectivityCheckFailed, commandDebugString
+		return ConnectivityCheckFailed, 0, fmt.Sprintf("%s\nunable to set up command: %+v", output, err)
+	}
+	var latency time.Duration
+	if measureLatency {
+		if stdout, latency, err = parseTimedOutput(stdout); err != nil {
+			logrus.Warnf("unable to get latency of command %s: %+v", commandDebugString, err)
+		}
 	}
 	if commandErr != nil {
 		logrus.Debugf("unable to run command %s: %+v", commandDebugString, commandErr)
-		return ConnectivityBlocked, commandDebugString
+		return ConnectivityBlocked, 0, fmt.Sprintf("%s\ncommand error: %+v", output, commandErr)
+	}
+	if successRegex != nil && !successRegex.MatchString(stdout) {
+		logrus.Debugf("output of command %s doesn't match %s", commandDebugString, successRegex.String())
+		return ConnectivityBlocked, 0, fmt.Sprintf("%s\nstdout doesn't match %s", output, successRegex.String())
 	}
-	return ConnectivityAllowed, commandDebugString
+	return ConnectivityAllowed, latency, output
+}
+
+// BatchClient issues a batch of requests from its pod, returning a result for each
+type BatchClient interface {
+	Batch(b *worker.Batch) ([]*worker.Result, error)
 }
 
 type KubeBatchJobRunner struct {
-	Client  *worker.Client
+	Client  BatchClient
 	Workers int
+	// RecordOutput keeps each job's output from the worker in its result
+	RecordOutput bool
 }
 
 func NewKubeBatchJobRunner(k8s kube.IKubernetes, workers int) *KubeBatchJobRunner {
@@ -167,10 +429,11 @@ func (k *KubeBatchJobRunner) RunJobs(jobs []*Job) []*JobResult {
 		}
 		batch := batches[job.FromKey]
 		batch.Requests = append(batch.Requests, &worker.Request{
-			Key:      job.Key(),
-			Protocol: job.Protocol,
-			Host:     job.ToHost,
-			Port:     job.ResolvedPort,
+			Key:       job.Key(),
+			Protocol:  job.Protocol,
+			Host:      job.ToHost,
+			Port:      job.ResolvedPort,
+			Exchanges: job.Exchanges,
 		})
 
 		jobMap[job.Key()] = job
@@ -217,10 +480,17 @@ func (k *KubeBatchJobRunner) worker(jobMap map[string]*Job, batches <-chan *work
 					logrus.Debugf("request to %s failed: %s", r.Request.Key, r.Error)
 					c = ConnectivityBlocked
 				}
-				jobResults <- &JobResult{
+				jobResult := &JobResult{
 					Job:      jobMap[r.Request.Key],
 					Combined: c,
 				}
+				if k.RecordOutput {
+					jobResult.Output = r.Output
+					if r.Error != "" {
+						jobResult.Output += "\nerror: " + r.Error
+					}
+				}
+				jobResults <- jobResult
 			}
 		}
 	}
@@ -0,0 +1,77 @@
+package probe
+
+import (
+	"context"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"sync"
+	"time"
+)
+
+func RunJobRunnerTests() {
+	Describe("Runner context", func() {
+		It("Should not run jobs once its context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			runner := NewKubeRunner(kube.NewMockKubernetes(1.0), 1, nil)
+			runner.Context = ctx
+			job := &Job{FromKey: "x/a", FromNamespace: "x", FromPod: "a", FromContainer: "cont-80-tcp", ToKey: "x/b", Protocol: v1.ProtocolTCP, ResolvedPort: 80}
+
+			// the mock has no pods, so running the job would fail to execute
+			Expect(runner.RunJobs(&Jobs{Valid: []*Job{job}})[0].Combined).To(Equal(ConnectivityCheckFailed))
+			cancel()
+			Expect(runner.RunJobs(&Jobs{Valid: []*Job{job}})).To(Equal([]*JobResult{{Job: job, Combined: ConnectivityUnknown}}))
+		})
+	})
+	Describe("Source pod parallelism", func() {
+		It("Should probe a bounded number of source pods at once, each pod's jobs one after another", func() {
+			kubernetes := &concurrencyTrackingKubernetes{MockKubernetes: kube.NewMockKubernetes(1.0), inFlight: map[string]int{}}
+			var jobs []*Job
+			for _, from := range []string{"a", "b", "c", "d"} {
+				for _, port := range []int{80, 81, 82} {
+					jobs = append(jobs, &Job{FromKey: "x/" + from, FromNamespace: "x", FromPod: from, FromContainer: "cont-80-tcp", ToKey: "x/c", ToHost: "192.168.1.9", ResolvedPort: port, Protocol: v1.ProtocolTCP})
+				}
+			}
+			runner := &KubeJobRunner{Kubernetes: kubernetes, Workers: 15, SourcePodParallelism: 2}
+			results := runner.RunJobs(jobs)
+			Expect(results).To(HaveLen(12))
+			for _, result := range results {
+				Expect(result.Combined).To(Equal(ConnectivityAllowed))
+			}
+			Expect(kubernetes.maxPodsInFlight).To(Equal(2))
+			Expect(kubernetes.maxPerPodInFlight).To(Equal(1))
+		})
+	})
+}
+
+// concurrencyTrackingKubernetes tracks how many pods have execs in flight at once, and how many each has
+type concurrencyTrackingKubernetes struct {
+	*kube.MockKubernetes
+	lock              sync.Mutex
+	inFlight          map[string]int
+	maxPodsInFlight   int
+	maxPerPodInFlight int
+}
+
+func (c *concurrencyTrackingKubernetes) ExecuteRemoteCommand(namespace string, pod string, container string, command []string) (string, string, error, error) {
+	c.lock.Lock()
+	c.inFlight[pod]++
+	if c.inFlight[pod] > c.maxPerPodInFlight {
+		c.maxPerPodInFlight = c.inFlight[pod]
+	}
+	if len(c.inFlight) > c.maxPodsInFlight {
+		c.maxPodsInFlight = len(c.inFlight)
+	}
+	c.lock.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	c.lock.Lock()
+	c.inFlight[pod]--
+	if c.inFlight[pod] == 0 {
+		delete(c.inFlight, pod)
+	}
+	c.lock.Unlock()
+	return "", "", nil, nil
+}
@@ -0,0 +1,85 @@
+package probe
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var latencyRegex = regexp.MustCompile(`(?m)^latency-ns (\d+)\n?`)
+
+// timedCommand runs command under sh, printing how long it took -- in nanoseconds -- on its own line after its
+// output, and exiting with its exit code
+func timedCommand(command []string) []string {
+	script := `start=$(date +%s%N); "$@"; code=$?; end=$(date +%s%N); echo; echo "latency-ns $((end-start))"; exit $code`
+	return append([]string{"sh", "-c", script, "sh"}, command...)
+}
+
+// parseTimedOutput strips the latency line, which timedCommand adds, from stdout
+func parseTimedOutput(stdout string) (string, time.Duration, error) {
+	matches := latencyRegex.FindAllStringSubmatchIndex(stdout, -1)
+	if len(matches) == 0 {
+		return stdout, 0, errors.Errorf("unable to find latency in output '%s'", stdout)
+	}
+	last := matches[len(matches)-1]
+	nanos, err := strconv.ParseInt(stdout[last[2]:last[3]], 10, 64)
+	if err != nil {
+		return stdout, 0, errors.Wrapf(err, "unable to parse latency %s", stdout[last[2]:last[3]])
+	}
+	return stdout[:last[0]] + stdout[last[1]:], time.Duration(nanos), nil
+}
+
+// LatencySummary describes the distribution of probe latencies, using nearest-rank percentiles
+type LatencySummary struct {
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// NewLatencySummary returns nil if there are no latencies
+func NewLatencySummary(latencies []time.Duration) *LatencySummary {
+	if len(latencies) == 0 {
+		return nil
+	}
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p int) time.Duration {
+		// nearest rank: the smallest value which at least p% of the values are less than or equal to
+		rank := (p*len(sorted) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		return sorted[rank-1]
+	}
+	return &LatencySummary{
+		Count: len(sorted),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+func (l *LatencySummary) String() string {
+	return fmt.Sprintf("p50 %s, p90 %s, p99 %s, max %s over %d probes",
+		l.P50.Round(time.Microsecond), l.P90.Round(time.Microsecond), l.P99.Round(time.Microsecond), l.Max.Round(time.Microsecond), l.Count)
+}
+
+// LatencySummary summarizes the latencies of allowed job results, across all cells; it's nil if no latencies were
+// measured
+func (t *Table) LatencySummary() *LatencySummary {
+	var latencies []time.Duration
+	for _, key := range t.Wrapped.Keys() {
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.Combined == ConnectivityAllowed && jobResult.Latency > 0 {
+				latencies = append(latencies, jobResult.Latency)
+			}
+		}
+	}
+	return NewLatencySummary(latencies)
+}
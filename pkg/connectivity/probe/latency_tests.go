@@ -0,0 +1,45 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+func RunLatencyTests() {
+	Describe("Latency", func() {
+		It("Should strip the latency from the timed command's output", func() {
+			stdout, latency, err := parseTimedOutput("\nlatency-ns 2500000\n")
+			Expect(err).To(Succeed())
+			Expect(stdout).To(Equal("\n"))
+			Expect(latency).To(Equal(2500 * time.Microsecond))
+
+			stdout, _, err = parseTimedOutput("hello\n\nlatency-ns 1000\n")
+			Expect(err).To(Succeed())
+			Expect(stdout).To(Equal("hello\n\n"))
+
+			_, _, err = parseTimedOutput("hello\n")
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("Should summarize latencies with nearest-rank percentiles", func() {
+			Expect(NewLatencySummary(nil)).To(BeNil())
+
+			var latencies []time.Duration
+			for i := 100; i >= 1; i-- {
+				latencies = append(latencies, time.Duration(i)*time.Millisecond)
+			}
+			summary := NewLatencySummary(latencies)
+			Expect(summary.Count).To(Equal(100))
+			Expect(summary.P50).To(Equal(50 * time.Millisecond))
+			Expect(summary.P90).To(Equal(90 * time.Millisecond))
+			Expect(summary.P99).To(Equal(99 * time.Millisecond))
+			Expect(summary.Max).To(Equal(100 * time.Millisecond))
+
+			single := NewLatencySummary([]time.Duration{3 * time.Millisecond})
+			Expect(single.P50).To(Equal(3 * time.Millisecond))
+			Expect(single.P99).To(Equal(3 * time.Millisecond))
+		})
+	})
+
+}
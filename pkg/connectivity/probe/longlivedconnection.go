@@ -0,0 +1,194 @@
+package probe
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// ConnectionFate is what happened to a long-lived connection by the time it was closed
+type ConnectionFate string
+
+const (
+	ConnectionSurvived ConnectionFate = "survived"
+	ConnectionSevered  ConnectionFate = "severed"
+)
+
+var AllConnectionFates = []string{string(ConnectionSurvived), string(ConnectionSevered)}
+
+func ParseConnectionFate(fate string) (ConnectionFate, error) {
+	for _, f := range AllConnectionFates {
+		if fate == f {
+			return ConnectionFate(fate), nil
+		}
+	}
+	return "", errors.Errorf("invalid connection fate %s; must be one of %+v", fate, AllConnectionFates)
+}
+
+const (
+	// longLivedConnectionRequests bounds how long a connection is kept open -- at about one request a second -- in
+	// case it's never closed, i.e. because its test case hit an error
+	longLivedConnectionRequests = 900
+	// longLivedConnectionScript opens a TCP connection with bash, and then, once a second, makes an HTTP request over
+	// it and reads the response.  It prints 'answered' for each response, and 'failed: <reason>' -- and gives up --
+	// once the connection stops working.  SIGPIPE is ignored, so that writing to a reset connection fails the write
+	// instead of killing bash.
+	longLivedConnectionScript = `trap '' PIPE
+exec 3<>"/dev/tcp/$1/$2" || { echo "failed: not connected"; exit; }
+i=0
+while [ $i -lt $3 ]; do
+  i=$((i+1))
+  printf 'GET / HTTP/1.1\r\nHost: cyclonus\r\n\r\n' >&3 || { echo "failed: unable to send request"; exit; }
+  IFS= read -r -t 3 status <&3 || { echo "failed: no response"; exit; }
+  length=0
+  while IFS= read -r -t 3 header <&3; do
+    header=${header%$'\r'}
+    [ -z "$header" ] && break
+    case "$header" in [Cc]ontent-[Ll]ength:*) length=${header#*: };; esac
+  done
+  if [ "$length" -gt 0 ]; then
+    IFS= read -r -N "$length" -t 3 body <&3 || { echo "failed: incomplete response"; exit; }
+  fi
+  case "$status" in "HTTP/1.1 200"*) echo answered;; *) echo "failed: ${status%$'\r'}"; exit;; esac
+  sleep 1
+done`
+)
+
+// LongLivedConnection is a single TCP connection from one pod to another's HTTP server, which a background process in
+// the client pod keeps making requests over, so that what happens to an established connection -- when policies
+// change underneath it -- can be checked.  The server must answer HTTP, i.e. with PodOptions.ServeHTTP, and the
+// client image needs bash.
+type LongLivedConnection struct {
+	FromKey       string
+	FromNamespace string
+	FromPod       string
+	FromContainer string
+	ToKey         string
+	ToIP          string
+	Port          int
+}
+
+func NewLongLivedConnection(from *Pod, to *Pod, port int) *LongLivedConnection {
+	return &LongLivedConnection{
+		FromKey:       from.PodString().String(),
+		FromNamespace: from.Namespace,
+		FromPod:       from.Name,
+		FromContainer: from.ClientContainer(),
+		ToKey:         to.PodString().String(),
+		ToIP:          to.IP,
+		Port:          port,
+	}
+}
+
+func (c *LongLivedConnection) String() string {
+	return fmt.Sprintf("%s -> %s:%d", c.FromKey, c.ToKey, c.Port)
+}
+
+// logPath is where the client pod's background process writes how each request went, and its pid goes next to it
+func (c *LongLivedConnection) logPath() string {
+	return fmt.Sprintf("/tmp/cyclonus-connection-%s-%d.log", strings.ReplaceAll(c.ToKey, "/", "-"), c.Port)
+}
+
+func (c *LongLivedConnection) StartCommand() []string {
+	return []string{"sh", "-c", `rm -f "$4" "$4.pid"; nohup bash -c "$5" bash "$1" "$2" "$3" >"$4" 2>&1 </dev/null & echo $! >"$4.pid"`,
+		"sh", c.ToIP, fmt.Sprintf("%d", c.Port), fmt.Sprintf("%d", longLivedConnectionRequests), c.logPath(), longLivedConnectionScript}
+}
+
+// ReadCommand prints the log, if the background process has started writing it
+func (c *LongLivedConnection) ReadCommand() []string {
+	return []string{"sh", "-c", `cat "$1" 2>/dev/null || true`, "sh", c.logPath()}
+}
+
+// StopCommand kills the background process, and prints its log
+func (c *LongLivedConnection) StopCommand() []string {
+	return []string{"sh", "-c", `kill $(cat "$1.pid") 2>/dev/null; cat "$1"; rm -f "$1" "$1.pid"`, "sh", c.logPath()}
+}
+
+func (c *LongLivedConnection) execute(kubernetes kube.IKubernetes, command []string) (string, error) {
+	stdout, stderr, commandErr, err := kubernetes.ExecuteRemoteCommand(c.FromNamespace, c.FromPod, c.FromContainer, command)
+	logrus.Debugf("stdout, stderr from connection %s: \n%s\n%s", c.String(), stdout, stderr)
+	if err != nil {
+		return "", errors.WithMessagef(err, "unable to set up command for connection %s", c.String())
+	}
+	if commandErr != nil {
+		return "", errors.Wrapf(commandErr, "unable to run command for connection %s: %s", c.String(), stderr)
+	}
+	return stdout, nil
+}
+
+// Open starts the connection, and waits for its first request to be answered
+func (c *LongLivedConnection) Open(kubernetes kube.IKubernetes, timeout time.Duration) error {
+	logrus.Infof("opening long-lived connection %s", c.String())
+	if _, err := c.execute(kubernetes, c.StartCommand()); err != nil {
+		return err
+	}
+	start := time.Now()
+	for {
+		stdout, err := c.execute(kubernetes, c.ReadCommand())
+		if err != nil {
+			return err
+		}
+		if result := parseLongLivedConnectionLog(c, stdout); result.Requests > 0 {
+			if result.Answered == 0 {
+				return errors.Errorf("unable to open connection %s: %s", c.String(), result.Failure)
+			}
+			return nil
+		}
+		if time.Since(start) > timeout {
+			return errors.Errorf("unable to open connection %s: no response after %s", c.String(), timeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Close stops the connection, and reports whether it was still working
+func (c *LongLivedConnection) Close(kubernetes kube.IKubernetes) (*LongLivedConnectionResult, error) {
+	logrus.Infof("closing long-lived connection %s", c.String())
+	stdout, err := c.execute(kubernetes, c.StopCommand())
+	if err != nil {
+		return nil, err
+	}
+	result := parseLongLivedConnectionLog(c, stdout)
+	if result.Answered == 0 {
+		return nil, errors.Errorf("connection %s was never established: %s", c.String(), result.Failure)
+	}
+	return result, nil
+}
+
+// LongLivedConnectionResult is how many requests were made over a long-lived connection, and how many of those were
+// answered, before it was closed -- or failed, in which case Failure says how
+type LongLivedConnectionResult struct {
+	From     string
+	To       string
+	Port     int
+	Requests int
+	Answered int
+	Failure  string
+}
+
+// Fate is severed if the connection stopped working before it was closed
+func (r *LongLivedConnectionResult) Fate() ConnectionFate {
+	if r.Failure != "" {
+		return ConnectionSevered
+	}
+	return ConnectionSurvived
+}
+
+func parseLongLivedConnectionLog(c *LongLivedConnection, stdout string) *LongLivedConnectionResult {
+	result := &LongLivedConnectionResult{From: c.FromKey, To: c.ToKey, Port: c.Port}
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "answered":
+			result.Requests++
+			result.Answered++
+		case strings.HasPrefix(line, "failed: "):
+			result.Requests++
+			result.Failure = strings.TrimPrefix(line, "failed: ")
+		}
+	}
+	return result
+}
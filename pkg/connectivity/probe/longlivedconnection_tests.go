@@ -0,0 +1,40 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunLongLivedConnectionTests() {
+	Describe("Long-lived connections", func() {
+		connection := &LongLivedConnection{FromKey: "x/b", ToKey: "x/a", Port: 80}
+
+		It("Should tell severed connections from ones which survived", func() {
+			survived := parseLongLivedConnectionLog(connection, "answered\nanswered\nanswered\n")
+			Expect(survived).To(Equal(&LongLivedConnectionResult{From: "x/b", To: "x/a", Port: 80, Requests: 3, Answered: 3}))
+			Expect(survived.Fate()).To(Equal(ConnectionSurvived))
+
+			severed := parseLongLivedConnectionLog(connection, "answered\nanswered\nfailed: no response\n")
+			Expect(severed.Requests).To(Equal(3))
+			Expect(severed.Answered).To(Equal(2))
+			Expect(severed.Failure).To(Equal("no response"))
+			Expect(severed.Fate()).To(Equal(ConnectionSevered))
+
+			// i.e. bash's complaint about the reset connection
+			reset := parseLongLivedConnectionLog(connection, "answered\nbash: line 6: printf: write error: Connection reset by peer\nfailed: unable to send request\n")
+			Expect(reset.Requests).To(Equal(2))
+			Expect(reset.Fate()).To(Equal(ConnectionSevered))
+
+			Expect(parseLongLivedConnectionLog(connection, "").Requests).To(Equal(0))
+		})
+
+		It("Should parse connection fates", func() {
+			fate, err := ParseConnectionFate("severed")
+			Expect(err).To(Succeed())
+			Expect(fate).To(Equal(ConnectionSevered))
+			_, err = ParseConnectionFate("dropped")
+			Expect(err).NotTo(Succeed())
+		})
+	})
+
+}
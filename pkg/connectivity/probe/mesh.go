@@ -0,0 +1,175 @@
+package probe
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+type ServiceMesh string
+
+const (
+	ServiceMeshIstio   ServiceMesh = "istio"
+	ServiceMeshLinkerd ServiceMesh = "linkerd"
+)
+
+const (
+	MeshModeWarn   = "warn"
+	MeshModeFail   = "fail"
+	MeshModeAdjust = "adjust"
+)
+
+var AllMeshModes = []string{MeshModeWarn, MeshModeFail, MeshModeAdjust}
+
+var meshSidecars = map[string]ServiceMesh{
+	"istio-proxy":   ServiceMeshIstio,
+	"linkerd-proxy": ServiceMeshLinkerd,
+}
+
+// meshOptOutAnnotations ask each mesh's injector to leave a pod alone
+var meshOptOutAnnotations = map[string]string{
+	"sidecar.istio.io/inject": "false",
+	"linkerd.io/inject":       "disabled",
+}
+
+// meshPortRanges are ports the meshes' proxies reserve for themselves, as [low, high] inclusive
+var meshPortRanges = map[ServiceMesh][][2]int{
+	ServiceMeshIstio:   {{15000, 15090}},
+	ServiceMeshLinkerd: {{4140, 4143}, {4190, 4191}},
+}
+
+func IsMeshSidecar(containerName string) bool {
+	_, ok := meshSidecars[containerName]
+	return ok
+}
+
+// MeshFinding is a reason to think a namespace is part of a service mesh
+type MeshFinding struct {
+	Namespace string
+	Mesh      ServiceMesh
+	Reason    string
+}
+
+func (m *MeshFinding) String() string {
+	return fmt.Sprintf("namespace %s: %s (%s)", m.Namespace, m.Mesh, m.Reason)
+}
+
+// DetectServiceMeshes looks for sidecar injection enabled on the given namespaces -- those which exist -- and for
+// sidecars already injected into pods in them
+func DetectServiceMeshes(kubernetes kube.IKubernetes, namespaces []string) ([]*MeshFinding, error) {
+	wanted := map[string]bool{}
+	for _, ns := range namespaces {
+		wanted[ns] = true
+	}
+	nsList, err := kubernetes.GetAllNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []*MeshFinding
+	for _, ns := range nsList.Items {
+		if !wanted[ns.Name] {
+			continue
+		}
+		if ns.Labels["istio-injection"] == "enabled" {
+			findings = append(findings, &MeshFinding{Namespace: ns.Name, Mesh: ServiceMeshIstio, Reason: "label istio-injection=enabled"})
+		} else if rev, ok := ns.Labels["istio.io/rev"]; ok {
+			findings = append(findings, &MeshFinding{Namespace: ns.Name, Mesh: ServiceMeshIstio, Reason: "label istio.io/rev=" + rev})
+		}
+		if ns.Annotations["linkerd.io/inject"] == "enabled" {
+			findings = append(findings, &MeshFinding{Namespace: ns.Name, Mesh: ServiceMeshLinkerd, Reason: "annotation linkerd.io/inject=enabled"})
+		}
+
+		pods, err := kubernetes.GetPodsInNamespace(ns.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			for _, cont := range pod.Spec.Containers {
+				if mesh, ok := meshSidecars[cont.Name]; ok {
+					findings = append(findings, &MeshFinding{Namespace: ns.Name, Mesh: mesh, Reason: fmt.Sprintf("pod %s has sidecar %s", pod.Name, cont.Name)})
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+// excludeMeshPorts drops ports reserved by the given meshes
+func excludeMeshPorts(ports []int, meshes map[ServiceMesh]bool) []int {
+	var kept []int
+	for _, port := range ports {
+		reserved := false
+		for mesh := range meshes {
+			for _, portRange := range meshPortRanges[mesh] {
+				if port >= portRange[0] && port <= portRange[1] {
+					reserved = true
+				}
+			}
+		}
+		if reserved {
+			logrus.Warnf("excluding port %d, which is reserved by a service mesh proxy", port)
+		} else {
+			kept = append(kept, port)
+		}
+	}
+	return kept
+}
+
+// ValidateMeshMode checks that mode is one of AllMeshModes
+func ValidateMeshMode(mode string) error {
+	if mode != MeshModeWarn && mode != MeshModeFail && mode != MeshModeAdjust {
+		return errors.Errorf("invalid service mesh mode '%s'; must be one of %+v", mode, AllMeshModes)
+	}
+	return nil
+}
+
+// HandleServiceMeshes checks the fixture namespaces for service meshes, whose mTLS and traffic interception would
+// distort probe results, and then warns, fails, or adjusts depending on mode.  Adjusting opts cyclonus's pods out
+// of sidecar injection and drops server ports reserved by the mesh; the returned ports and pod options reflect that.
+func HandleServiceMeshes(kubernetes kube.IKubernetes, namespaces []string, ports []int, mode string) ([]int, *PodOptions, error) {
+	if err := ValidateMeshMode(mode); err != nil {
+		return nil, nil, err
+	}
+
+	findings, err := DetectServiceMeshes(kubernetes, namespaces)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(findings) == 0 {
+		return ports, &PodOptions{}, nil
+	}
+
+	var lines []string
+	meshes := map[ServiceMesh]bool{}
+	for _, finding := range findings {
+		lines = append(lines, " - "+finding.String())
+		meshes[finding.Mesh] = true
+	}
+	sort.Strings(lines)
+	description := strings.Join(lines, "\n")
+
+	switch mode {
+	case MeshModeWarn:
+		logrus.Warnf("found service mesh sidecar injection, which will likely distort results:\n%s", description)
+		return ports, &PodOptions{}, nil
+	case MeshModeFail:
+		return nil, nil, errors.Errorf("found service mesh sidecar injection:\n%s", description)
+	case MeshModeAdjust:
+		logrus.Infof("found service mesh sidecar injection; opting pods out of injection and excluding mesh ports:\n%s", description)
+		keptPorts := excludeMeshPorts(ports, meshes)
+		if len(keptPorts) == 0 {
+			return nil, nil, errors.Errorf("no server ports left after excluding service mesh ports from %+v", ports)
+		}
+		annotations := map[string]string{}
+		for key, value := range meshOptOutAnnotations {
+			annotations[key] = value
+		}
+		return keptPorts, &PodOptions{Annotations: annotations}, nil
+	default:
+		panic(errors.Errorf("unreachable: invalid service mesh mode '%s'", mode))
+	}
+}
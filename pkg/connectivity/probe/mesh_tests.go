@@ -0,0 +1,60 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func RunMeshTests() {
+	Describe("Service meshes", func() {
+		setup := func() *kube.MockKubernetes {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			_, err := kubernetes.CreateNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "x", Labels: map[string]string{"istio-injection": "enabled"}}})
+			Expect(err).To(Succeed())
+			_, err = kubernetes.CreateNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Labels: map[string]string{"istio-injection": "enabled"}}})
+			Expect(err).To(Succeed())
+			return kubernetes
+		}
+
+		It("Should detect sidecar injection in fixture namespaces only", func() {
+			findings, err := DetectServiceMeshes(setup(), []string{"x", "y"})
+			Expect(err).To(Succeed())
+			Expect(findings).To(HaveLen(1))
+			Expect(findings[0].Namespace).To(Equal("x"))
+			Expect(findings[0].Mesh).To(Equal(ServiceMeshIstio))
+		})
+
+		It("Should opt out of injection and drop mesh ports when adjusting", func() {
+			ports, podOptions, err := HandleServiceMeshes(setup(), []string{"x"}, []int{80, 15001}, MeshModeAdjust)
+			Expect(err).To(Succeed())
+			Expect(ports).To(Equal([]int{80}))
+			Expect(podOptions.Annotations).To(HaveKeyWithValue("sidecar.istio.io/inject", "false"))
+
+			pod := NewDefaultPod("x", "a", ports, []v1.Protocol{v1.ProtocolTCP}, false, podOptions)
+			Expect(pod.KubePod().Annotations).To(HaveKeyWithValue("sidecar.istio.io/inject", "false"))
+		})
+
+		It("Should fail in fail mode", func() {
+			_, _, err := HandleServiceMeshes(setup(), []string{"x"}, []int{80}, MeshModeFail)
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("Should reject invalid modes", func() {
+			for _, mode := range AllMeshModes {
+				Expect(ValidateMeshMode(mode)).To(Succeed())
+			}
+			Expect(ValidateMeshMode("ignore")).NotTo(Succeed())
+		})
+
+		It("Should ignore sidecars when comparing to kube pods", func() {
+			pod := NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, nil)
+			kubePod := pod.KubePod()
+			kubePod.Spec.Containers = append(kubePod.Spec.Containers, v1.Container{Name: "istio-proxy", Ports: []v1.ContainerPort{{ContainerPort: 15090}}})
+			Expect(pod.IsEqualToKubePod(*kubePod)).To(BeTrue())
+		})
+	})
+
+}
@@ -0,0 +1,107 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+// multiTargetScript connects to every target -- given as protocol, address and exchanges arguments -- at once, with
+// agnhost, and prints a JSON result line for each, with the target's index and the exit code of its last connection
+// attempt
+const multiTargetScript = `i=0
+while [ $# -ge 3 ]; do
+  (
+    n=0
+    code=0
+    while [ $n -lt "$3" ]; do
+      /agnhost connect "$2" --timeout=1s --protocol="$1" >/dev/null 2>&1
+      code=$?
+      [ $code -eq 0 ] || break
+      n=$((n+1))
+    done
+    printf '{"Index":%d,"ExitCode":%d}\n' $i $code
+  ) &
+  i=$((i+1))
+  shift 3
+done
+wait`
+
+// multiTargetResult is the result line printed for each target
+type multiTargetResult struct {
+	Index    int
+	ExitCode int
+}
+
+// MultiTargetCommand connects to every job's target in a single command, which must be run in the jobs' source
+// container; each target's exchanges are made one after another, stopping at the first failure
+func MultiTargetCommand(jobs []*Job) []string {
+	command := []string{"sh", "-c", multiTargetScript, "sh"}
+	for _, job := range jobs {
+		exchanges := job.Exchanges
+		if exchanges < 1 {
+			exchanges = 1
+		}
+		command = append(command, strings.ToLower(string(job.Protocol)), job.ToAddress(), strconv.Itoa(exchanges))
+	}
+	return command
+}
+
+// parseMultiTargetOutput returns the exit code of each target, by index; targets without a result line are left out
+func parseMultiTargetOutput(stdout string) map[int]int {
+	exitCodes := map[int]int{}
+	for _, line := range strings.Split(stdout, "\n") {
+		var result multiTargetResult
+		if err := json.Unmarshal([]byte(strings.TrimSpace(line)), &result); err != nil {
+			continue
+		}
+		exitCodes[result.Index] = result.ExitCode
+	}
+	return exitCodes
+}
+
+// probeMultiTarget runs jobs -- which must all have the same source container -- in a single exec.  Jobs whose
+// target has no result are check failures, like every job if the exec itself fails.
+func probeMultiTarget(k8s kube.IKubernetes, jobs []*Job, recordOutput bool) []*JobResult {
+	from := jobs[0]
+	command := MultiTargetCommand(jobs)
+	commandDebugString := fmt.Sprintf("%s (%d targets)", strings.Join(from.kubeExecCommand(command[:3]), " "), len(jobs))
+	stdout, stderr, commandErr, err := k8s.ExecuteRemoteCommand(from.FromNamespace, from.FromPod, from.FromContainer, command)
+	logrus.Debugf("stdout, stderr from %s: \n%s\n%s", commandDebugString, stdout, stderr)
+	output := fmt.Sprintf("%s\nstdout:\n%s\nstderr:\n%s", commandDebugString, stdout, stderr)
+
+	var exitCodes map[int]int
+	if err != nil {
+		logrus.Errorf("unable to set up command %s: %+v", commandDebugString, err)
+		output = fmt.Sprintf("%s\nunable to set up command: %+v", output, err)
+	} else if commandErr != nil {
+		logrus.Errorf("unable to run command %s: %+v", commandDebugString, commandErr)
+		output = fmt.Sprintf("%s\ncommand error: %+v", output, commandErr)
+	} else {
+		exitCodes = parseMultiTargetOutput(stdout)
+	}
+
+	results := make([]*JobResult, len(jobs))
+	for i, job := range jobs {
+		result := &JobResult{Job: job}
+		jobOutput := output
+		if exitCode, ok := exitCodes[i]; !ok {
+			result.Combined = ConnectivityCheckFailed
+			jobOutput = fmt.Sprintf("%s\nno result for target %d, %s", output, i, job.ToAddress())
+		} else if exitCode != 0 {
+			logrus.Debugf("connection to %s from %s failed with exit code %d", job.ToAddress(), job.FromKey, exitCode)
+			result.Combined = ConnectivityBlocked
+			jobOutput = fmt.Sprintf("%s\ntarget %d, %s: exit code %d", output, i, job.ToAddress(), exitCode)
+		} else {
+			result.Combined = ConnectivityAllowed
+		}
+		if recordOutput {
+			result.Output = jobOutput
+		}
+		results[i] = result
+	}
+	return results
+}
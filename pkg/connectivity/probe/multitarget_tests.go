@@ -0,0 +1,61 @@
+package probe
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+	"sync/atomic"
+)
+
+func RunMultiTargetTests() {
+	Describe("Multi-target exec", func() {
+		It("Should run all of a source container's jobs in one exec", func() {
+			job := func(from string, port int, exchanges int) *Job {
+				return &Job{FromKey: "x/" + from, FromNamespace: "x", FromPod: from, FromContainer: "cont-80-tcp", ToKey: "x/c", ToHost: "192.168.1.9", ResolvedPort: port, Protocol: v1.ProtocolTCP, Exchanges: exchanges}
+			}
+			Expect(MultiTargetCommand([]*Job{job("a", 80, 0), job("a", 81, 3)})[4:]).To(Equal([]string{"tcp", "192.168.1.9:80", "1", "tcp", "192.168.1.9:81", "3"}))
+			Expect(parseMultiTargetOutput("{\"Index\":1,\"ExitCode\":1}\r\nnoise\r\n{\"Index\":0,\"ExitCode\":0}\r\n")).To(Equal(map[int]int{0: 0, 1: 1}))
+
+			kubernetes := &multiTargetKubernetes{MockKubernetes: kube.NewMockKubernetes(1.0)}
+			runner := &KubeJobRunner{Kubernetes: kubernetes, Workers: 2, MultiTarget: true}
+			results := runner.RunJobs([]*Job{job("a", 80, 0), job("a", 81, 0), job("a", 82, 0), job("b", 80, 0)})
+			Expect(results).To(HaveLen(4))
+			combined := map[string]Connectivity{}
+			for _, result := range results {
+				combined[result.Job.Key()] = result.Combined
+			}
+			Expect(combined).To(Equal(map[string]Connectivity{
+				job("a", 80, 0).Key(): ConnectivityAllowed,
+				job("a", 81, 0).Key(): ConnectivityBlocked,
+				// the exec didn't print a result for it
+				job("a", 82, 0).Key(): ConnectivityCheckFailed,
+				job("b", 80, 0).Key(): ConnectivityAllowed,
+			}))
+			Expect(kubernetes.execs).To(Equal(int32(2)))
+		})
+	})
+}
+
+// multiTargetKubernetes answers multi-target execs as if targets on port 80 were allowed, and those on port 81
+// blocked; others get no result
+type multiTargetKubernetes struct {
+	*kube.MockKubernetes
+	execs int32
+}
+
+func (m *multiTargetKubernetes) ExecuteRemoteCommand(namespace string, pod string, container string, command []string) (string, string, error, error) {
+	atomic.AddInt32(&m.execs, 1)
+	stdout := ""
+	for i := 0; 4+3*i < len(command); i++ {
+		switch {
+		case strings.HasSuffix(command[5+3*i], ":80"):
+			stdout += fmt.Sprintf("{\"Index\":%d,\"ExitCode\":0}\n", i)
+		case strings.HasSuffix(command[5+3*i], ":81"):
+			stdout += fmt.Sprintf("{\"Index\":%d,\"ExitCode\":1}\n", i)
+		}
+	}
+	return stdout, "", nil, nil
+}
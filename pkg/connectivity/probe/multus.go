@@ -0,0 +1,91 @@
+package probe
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+const (
+	// MultusNetworksAnnotation asks Multus to attach a pod to secondary networks, i.e. "macvlan-conf,sriov-conf"
+	MultusNetworksAnnotation = "k8s.v1.cni.cncf.io/networks"
+	// MultusNetworkStatusAnnotation is written by Multus: it lists all of a pod's networks and their interfaces
+	MultusNetworkStatusAnnotation = "k8s.v1.cni.cncf.io/network-status"
+)
+
+// NetworkStatus is an entry from the Multus network status annotation
+type NetworkStatus struct {
+	Name      string   `json:"name"`
+	Interface string   `json:"interface,omitempty"`
+	IPs       []string `json:"ips,omitempty"`
+	Default   bool     `json:"default,omitempty"`
+}
+
+func ParseNetworkStatuses(annotations map[string]string) ([]*NetworkStatus, error) {
+	value, ok := annotations[MultusNetworkStatusAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var statuses []*NetworkStatus
+	err := json.Unmarshal([]byte(value), &statuses)
+	return statuses, errors.Wrapf(err, "unable to parse %s annotation", MultusNetworkStatusAnnotation)
+}
+
+// SecondaryNetworkName drops the namespace from a NetworkAttachmentDefinition's name, so that networks with the same
+// name in different namespaces -- i.e. one per test namespace -- are treated as one network
+func SecondaryNetworkName(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// SecondaryNetworkIPs finds the first IP of each of a pod's non-default networks, by network name
+func SecondaryNetworkIPs(annotations map[string]string) (map[string]string, error) {
+	statuses, err := ParseNetworkStatuses(annotations)
+	if err != nil {
+		return nil, err
+	}
+	ips := map[string]string{}
+	for _, status := range statuses {
+		if !status.Default && len(status.IPs) > 0 {
+			ips[SecondaryNetworkName(status.Name)] = status.IPs[0]
+		}
+	}
+	return ips, nil
+}
+
+// SecondaryNetworks lists the networks all the pods are attached to
+func (r *Resources) SecondaryNetworks() []string {
+	counts := map[string]int{}
+	for _, pod := range r.Pods {
+		for network := range pod.SecondaryIPs {
+			counts[network]++
+		}
+	}
+	var networks []string
+	for network, count := range counts {
+		if count == len(r.Pods) {
+			networks = append(networks, network)
+		}
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+// ForSecondaryNetwork returns a copy of the resources where each pod's IP is its IP on the secondary network, so that
+// probing by pod IP goes over that network.  It should not affect the original Resources object.
+func (r *Resources) ForSecondaryNetwork(network string) (*Resources, error) {
+	var pods []*Pod
+	for _, pod := range r.Pods {
+		ip, ok := pod.SecondaryIPs[network]
+		if !ok {
+			return nil, errors.Errorf("pod %s/%s isn't attached to network %s", pod.Namespace, pod.Name, network)
+		}
+		podCopy := *pod
+		podCopy.IP = ip
+		pods = append(pods, &podCopy)
+	}
+	return &Resources{
+		Namespaces: r.Namespaces,
+		Pods:       pods,
+	}, nil
+}
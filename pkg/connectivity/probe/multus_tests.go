@@ -0,0 +1,51 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunMultusTests() {
+	Describe("Multus secondary networks", func() {
+		networkStatus := func(ip string) map[string]string {
+			return map[string]string{MultusNetworkStatusAnnotation: `[
+  {"name": "kindnet", "interface": "eth0", "ips": ["10.244.0.5"], "default": true},
+  {"name": "x/macvlan-conf", "interface": "net1", "ips": ["` + ip + `"]}
+]`}
+		}
+
+		It("Should parse secondary network IPs, ignoring namespaces and the default network", func() {
+			ips, err := SecondaryNetworkIPs(networkStatus("192.168.100.2"))
+			Expect(err).To(Succeed())
+			Expect(ips).To(Equal(map[string]string{"macvlan-conf": "192.168.100.2"}))
+
+			ips, err = SecondaryNetworkIPs(map[string]string{})
+			Expect(err).To(Succeed())
+			Expect(ips).To(BeEmpty())
+
+			_, err = SecondaryNetworkIPs(map[string]string{MultusNetworkStatusAnnotation: "{"})
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("Should switch pod IPs to a secondary network nondestructively", func() {
+			r := &Resources{
+				Namespaces: map[string]map[string]string{"x": {}},
+				Pods: []*Pod{
+					{Namespace: "x", Name: "a", IP: "10.244.0.5", SecondaryIPs: map[string]string{"macvlan-conf": "192.168.100.2"}},
+					{Namespace: "x", Name: "b", IP: "10.244.0.6", SecondaryIPs: map[string]string{"macvlan-conf": "192.168.100.3", "sriov": "192.168.200.3"}},
+				},
+			}
+			Expect(r.SecondaryNetworks()).To(Equal([]string{"macvlan-conf"}))
+
+			r2, err := r.ForSecondaryNetwork("macvlan-conf")
+			Expect(err).To(Succeed())
+			Expect(r2.Pods[0].IP).To(Equal("192.168.100.2"))
+			Expect(r2.Pods[1].IP).To(Equal("192.168.100.3"))
+			Expect(r.Pods[0].IP).To(Equal("10.244.0.5"))
+
+			_, err = r.ForSecondaryNetwork("sriov")
+			Expect(err).NotTo(Succeed())
+		})
+	})
+
+}
@@ -0,0 +1,123 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/openshift"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"time"
+)
+
+// PrepareOpenShiftNamespaces creates the server namespaces up front, opted out of the cluster's default project node
+// selector, so that cyclonus's pods can be scheduled on every node -- including infra nodes.  Namespaces which
+// already exist are left alone, but a warning is printed if they're still subject to the default node selector.
+func PrepareOpenShiftNamespaces(kubernetes kube.IKubernetes, namespaces []string) error {
+	for _, ns := range namespaces {
+		kubeNs, err := kubernetes.GetNamespace(ns)
+		if err == nil {
+			if _, ok := kubeNs.Annotations[openshift.NodeSelectorAnnotation]; !ok {
+				logrus.Warnf("namespace %s has no %s annotation, so pods may only be scheduled on nodes matching the default project node selector", ns, openshift.NodeSelectorAnnotation)
+			}
+			continue
+		}
+		namespace := KubeNamespace(ns, map[string]string{"ns": ns})
+		namespace.Annotations[openshift.NodeSelectorAnnotation] = ""
+		if _, err := kubernetes.CreateNamespace(namespace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restrictedPodSecurityContext satisfies the restricted SCCs: it doesn't pick a user ID, so that the one allocated
+// to the namespace is used, and lets the non-root servers bind to ports below 1024
+func restrictedPodSecurityContext() *v1.PodSecurityContext {
+	nonRoot := true
+	return &v1.PodSecurityContext{
+		RunAsNonRoot:   &nonRoot,
+		SeccompProfile: &v1.SeccompProfile{Type: v1.SeccompProfileTypeRuntimeDefault},
+		Sysctls:        []v1.Sysctl{{Name: "net.ipv4.ip_unprivileged_port_start", Value: "0"}},
+	}
+}
+
+func restrictedSecurityContext() *v1.SecurityContext {
+	allowPrivilegeEscalation := false
+	return &v1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities:             &v1.Capabilities{Drop: []v1.Capability{"ALL"}},
+	}
+}
+
+func (p *Pod) KubeRoute(port int) *openshift.Route {
+	return &openshift.Route{
+		TypeMeta: metav1.TypeMeta{APIVersion: openshift.RouteGroupVersion.String(), Kind: openshift.RouteKind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.ServiceName(),
+			Namespace: p.Namespace,
+		},
+		Spec: openshift.RouteSpec{
+			To:   openshift.RouteTargetReference{Kind: "Service", Name: p.ServiceName()},
+			Port: &openshift.RoutePort{TargetPort: intstr.FromInt(port)},
+		},
+	}
+}
+
+// CreateRoutes exposes every pod's service on port through a Route, and records the Routes' hosts, so that pods can
+// be probed as external destinations by way of the ingress routers
+func (r *Resources) CreateRoutes(kubernetes kube.IKubernetes, port int, timeoutSeconds int) error {
+	for _, pod := range r.Pods {
+		if !pod.IsServingPortProtocol(port, v1.ProtocolTCP) {
+			return errors.Errorf("unable to create route for pod %s/%s: not serving TCP on port %d", pod.Namespace, pod.Name, port)
+		}
+		route := pod.KubeRoute(port)
+		if _, err := kubernetes.GetRoute(route.Namespace, route.Name); err != nil {
+			if _, err := kubernetes.CreateRoute(route); err != nil {
+				return err
+			}
+		}
+	}
+
+	sleep := 5
+	for i := 0; i < timeoutSeconds; i += sleep {
+		ready := 0
+		for _, pod := range r.Pods {
+			route, err := kubernetes.GetRoute(pod.Namespace, pod.ServiceName())
+			if err != nil {
+				return err
+			}
+			pod.RouteHost = RouteHost(route)
+			if pod.RouteHost != "" {
+				ready++
+			}
+		}
+		if ready == len(r.Pods) {
+			return nil
+		}
+		logrus.Infof("waiting for %d routes to be admitted; currently %d are ready", len(r.Pods), ready)
+		time.Sleep(time.Duration(sleep) * time.Second)
+	}
+	return errors.Errorf("routes not ready")
+}
+
+// RouteHost finds the host a Route is reachable at: the host it was admitted with, falling back to its spec
+func RouteHost(route *openshift.Route) string {
+	for _, ingress := range route.Status.Ingress {
+		if ingress.Host != "" {
+			return ingress.Host
+		}
+	}
+	return route.Spec.Host
+}
+
+// HasRoutes is true if every pod is reachable through a Route
+func (r *Resources) HasRoutes() bool {
+	for _, pod := range r.Pods {
+		if pod.RouteHost == "" {
+			return false
+		}
+	}
+	return len(r.Pods) > 0
+}
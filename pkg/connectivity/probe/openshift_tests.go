@@ -0,0 +1,53 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/openshift"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunOpenShiftTests() {
+	Describe("OpenShift", func() {
+		It("Should create restricted pods", func() {
+			pod := NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, &PodOptions{Restricted: true})
+			kubePod := pod.KubePod()
+			Expect(*kubePod.Spec.SecurityContext.RunAsNonRoot).To(BeTrue())
+			Expect(kubePod.Spec.SecurityContext.RunAsUser).To(BeNil())
+			Expect(*kubePod.Spec.Containers[0].SecurityContext.AllowPrivilegeEscalation).To(BeFalse())
+			Expect(pod.SetLabels(map[string]string{}).Restricted).To(BeTrue())
+
+			Expect(NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, nil).KubePod().Spec.SecurityContext).To(BeNil())
+		})
+
+		It("Should opt new namespaces out of the default node selector", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			Expect(PrepareOpenShiftNamespaces(kubernetes, []string{"x"})).To(Succeed())
+			ns, err := kubernetes.GetNamespace("x")
+			Expect(err).To(Succeed())
+			Expect(ns.Annotations).To(HaveKeyWithValue(openshift.NodeSelectorAnnotation, ""))
+			Expect(ns.Labels).To(Equal(map[string]string{"ns": "x"}))
+		})
+
+		It("Should expose pods through routes", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			Expect(PrepareOpenShiftNamespaces(kubernetes, []string{"x"})).To(Succeed())
+			r := &Resources{
+				Namespaces: map[string]map[string]string{"x": {"ns": "x"}},
+				Pods:       []*Pod{NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, nil)},
+			}
+			Expect(r.HasRoutes()).To(BeFalse())
+			Expect(r.CreateRoutes(kubernetes, 81, 10)).NotTo(Succeed())
+
+			Expect(r.CreateRoutes(kubernetes, 80, 10)).To(Succeed())
+			Expect(r.HasRoutes()).To(BeTrue())
+			Expect(r.Pods[0].Host(generator.ProbeModeRoute)).To(Equal(r.Pods[0].RouteHost))
+			Expect(RouteHost(&openshift.Route{
+				Spec:   openshift.RouteSpec{Host: "a.example.com"},
+				Status: openshift.RouteStatus{Ingress: []openshift.RouteIngress{{Host: "b.example.com"}}},
+			})).To(Equal("b.example.com"))
+		})
+	})
+}
@@ -25,29 +25,92 @@ func NewPod(ns string, name string, labels map[string]string, ip string, contain
 	}
 }
 
-func NewDefaultPod(ns string, name string, ports []int, protocols []v1.Protocol, batchJobs bool) *Pod {
+// PodOptions are extra settings for the pods that cyclonus creates
+type PodOptions struct {
+	Annotations map[string]string
+	// Restricted pods run without root or extra privileges, to satisfy OpenShift's restricted SCCs
+	Restricted bool
+	// NodeSelector and NodeAffinity limit which nodes pods are scheduled onto; either may be nil
+	NodeSelector map[string]string
+	NodeAffinity *v1.NodeSelector
+	// ServeHTTP makes TCP containers serve HTTP, for HTTP checks
+	ServeHTTP bool
+	// TLSCertificate, if set, makes TCP containers serve HTTPS with it, for TLS checks
+	TLSCertificate *TLSCertificate
+}
+
+// SetNodeScheduling schedules pods onto nodes with all of nodeLabels, and matching nodeSelector, a label selector
+// which may be empty
+func (o *PodOptions) SetNodeScheduling(nodeLabels map[string]string, nodeSelector string) error {
+	if len(nodeLabels) > 0 {
+		o.NodeSelector = nodeLabels
+	}
+	if nodeSelector != "" {
+		nodeAffinity, err := kube.ParseNodeSelector(nodeSelector)
+		if err != nil {
+			return err
+		}
+		o.NodeAffinity = nodeAffinity
+	}
+	return nil
+}
+
+func NewDefaultPod(ns string, name string, ports []int, protocols []v1.Protocol, batchJobs bool, options *PodOptions) *Pod {
 	var containers []*Container
 	for _, port := range ports {
 		for _, protocol := range protocols {
 			containers = append(containers, NewDefaultContainer(port, protocol, batchJobs))
 		}
 	}
-	return &Pod{
+	pod := &Pod{
 		Namespace:  ns,
 		Name:       name,
 		Labels:     map[string]string{"pod": name},
 		IP:         "TODO",
 		Containers: containers,
 	}
+	if options != nil {
+		pod.Annotations = options.Annotations
+		pod.Restricted = options.Restricted
+		pod.NodeSelector = options.NodeSelector
+		pod.NodeAffinity = options.NodeAffinity
+		for _, container := range containers {
+			container.ServeHTTP = options.ServeHTTP
+			container.TLSCertificate = options.TLSCertificate
+		}
+	}
+	return pod
 }
 
 type Pod struct {
-	Namespace  string
-	Name       string
-	Labels     map[string]string
-	ServiceIP  string
-	IP         string
+	Namespace   string
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+	ServiceIP   string
+	IP          string
+	// SecondaryIPs are the pod's IPs on Multus secondary networks, by network name
+	SecondaryIPs map[string]string
+	// FamilyIPs are the pod's IPs by family, if it has both IPv4 and IPv6 addresses; IP is one of them
+	FamilyIPs map[v1.IPFamily]string
+	// NodeName and Zone are where the pod was scheduled; Zone is empty if the node has no zone label
+	NodeName   string
+	Zone       string
 	Containers []*Container
+	// ProbeContainer is the container to run probes from; if empty, the first container is used
+	ProbeContainer string
+	Restricted     bool
+	NodeSelector   map[string]string
+	NodeAffinity   *v1.NodeSelector
+	// RouteHost is the host of the OpenShift Route exposing the pod's service; empty if there's no Route
+	RouteHost string
+}
+
+func (p *Pod) ClientContainer() string {
+	if p.ProbeContainer != "" {
+		return p.ProbeContainer
+	}
+	return p.Containers[0].Name
 }
 
 func (p *Pod) Host(probeMode generator.ProbeMode) string {
@@ -58,13 +121,21 @@ func (p *Pod) Host(probeMode generator.ProbeMode) string {
 		return p.IP
 	case generator.ProbeModeServiceIP:
 		return p.ServiceIP
+	case generator.ProbeModeRoute:
+		return p.RouteHost
 	default:
 		panic(errors.Errorf("invalid mode %s", probeMode))
 	}
 }
 
 func (p *Pod) IsEqualToKubePod(kubePod v1.Pod) bool {
-	kubeConts := kubePod.Spec.Containers
+	// injected service mesh sidecars aren't part of cyclonus's pod model
+	var kubeConts []v1.Container
+	for _, kubeCont := range kubePod.Spec.Containers {
+		if !IsMeshSidecar(kubeCont.Name) {
+			kubeConts = append(kubeConts, kubeCont)
+		}
+	}
 	if len(kubeConts) != len(p.Containers) {
 		return false
 	}
@@ -90,17 +161,29 @@ func (p *Pod) ServiceName() string {
 
 func (p *Pod) KubePod() *v1.Pod {
 	zero := int64(0)
-	return &v1.Pod{
+	pod := &v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      p.Name,
-			Labels:    p.Labels,
-			Namespace: p.Namespace,
+			Name:        p.Name,
+			Labels:      p.Labels,
+			Annotations: p.Annotations,
+			Namespace:   p.Namespace,
 		},
 		Spec: v1.PodSpec{
 			TerminationGracePeriodSeconds: &zero,
 			Containers:                    p.KubeContainers(),
+			NodeSelector:                  p.NodeSelector,
 		},
 	}
+	if p.NodeAffinity != nil {
+		pod.Spec.Affinity = &v1.Affinity{NodeAffinity: &v1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: p.NodeAffinity}}
+	}
+	if p.Restricted {
+		pod.Spec.SecurityContext = restrictedPodSecurityContext()
+		for i := range pod.Spec.Containers {
+			pod.Spec.Containers[i].SecurityContext = restrictedSecurityContext()
+		}
+	}
+	return pod
 }
 
 func (p *Pod) KubeService() *v1.Service {
@@ -158,11 +241,22 @@ func (p *Pod) IsServingPortProtocol(port int, protocol v1.Protocol) bool {
 
 func (p *Pod) SetLabels(labels map[string]string) *Pod {
 	return &Pod{
-		Namespace:  p.Namespace,
-		Name:       p.Name,
-		Labels:     labels,
-		IP:         p.IP,
-		Containers: p.Containers,
+		Namespace:      p.Namespace,
+		Name:           p.Name,
+		Labels:         labels,
+		Annotations:    p.Annotations,
+		ServiceIP:      p.ServiceIP,
+		IP:             p.IP,
+		SecondaryIPs:   p.SecondaryIPs,
+		FamilyIPs:      p.FamilyIPs,
+		NodeName:       p.NodeName,
+		Zone:           p.Zone,
+		Containers:     p.Containers,
+		ProbeContainer: p.ProbeContainer,
+		Restricted:     p.Restricted,
+		NodeSelector:   p.NodeSelector,
+		NodeAffinity:   p.NodeAffinity,
+		RouteHost:      p.RouteHost,
 	}
 }
 
@@ -176,6 +270,12 @@ type Container struct {
 	Protocol  v1.Protocol
 	PortName  string
 	BatchJobs bool
+	// ServeHTTP answers TCP connections over HTTP, with the pod's hostname, instead of writing it raw; TCP connect
+	// probes can't tell the difference
+	ServeHTTP bool
+	// TLSCertificate, if set, makes TCP containers serve HTTPS with it, answering with the pod's hostname; it takes
+	// precedence over ServeHTTP
+	TLSCertificate *TLSCertificate
 }
 
 func NewDefaultContainer(port int, protocol v1.Protocol, batchJobs bool) *Container {
@@ -209,7 +309,14 @@ func (c *Container) KubeContainer() v1.Container {
 
 	switch c.Protocol {
 	case v1.ProtocolTCP:
-		cmd = []string{"/agnhost", "serve-hostname", "--tcp", "--http=false", "--port", fmt.Sprintf("%d", c.Port)}
+		if c.TLSCertificate != nil {
+			cmd = c.TLSCertificate.ServerCommand(c.Port)
+			env = append(env, c.TLSCertificate.ServerEnv()...)
+		} else if c.ServeHTTP {
+			cmd = []string{"/agnhost", "serve-hostname", "--http", "--port", fmt.Sprintf("%d", c.Port)}
+		} else {
+			cmd = []string{"/agnhost", "serve-hostname", "--tcp", "--http=false", "--port", fmt.Sprintf("%d", c.Port)}
+		}
 	case v1.ProtocolUDP:
 		cmd = []string{"/agnhost", "serve-hostname", "--udp", "--http=false", "--port", fmt.Sprintf("%d", c.Port)}
 	case v1.ProtocolSCTP:
@@ -0,0 +1,28 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunPodTests() {
+	Describe("Node scheduling", func() {
+		It("Should schedule pods onto the chosen nodes", func() {
+			options := &PodOptions{}
+			Expect(options.SetNodeScheduling(map[string]string{"kubernetes.io/os": "linux"}, "cni notin (legacy)")).To(Succeed())
+			pod := NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, options)
+			kubePod := pod.KubePod()
+			Expect(kubePod.Spec.NodeSelector).To(Equal(map[string]string{"kubernetes.io/os": "linux"}))
+			terms := kubePod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+			Expect(terms[0].MatchExpressions[0].Operator).To(Equal(v1.NodeSelectorOpNotIn))
+
+			r := &Resources{Namespaces: map[string]map[string]string{"x": {}}, Pods: []*Pod{pod}}
+			r2, err := r.CreatePod("x", "b", map[string]string{}, nil)
+			Expect(err).To(Succeed())
+			Expect(r2.Pods[1].KubePod().Spec.NodeSelector).To(Equal(kubePod.Spec.NodeSelector))
+
+			Expect(NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, nil).KubePod().Spec.Affinity).To(BeNil())
+		})
+	})
+}
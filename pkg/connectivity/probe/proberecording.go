@@ -0,0 +1,122 @@
+package probe
+
+import (
+	"encoding/json"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"sync"
+)
+
+// ProbeRecordingSchemaVersion is bumped whenever a change to ProbeRecording or JobResult would keep older recordings
+// from being replayed correctly, along with a migration from the previous version in probeRecordingMigrations
+const ProbeRecordingSchemaVersion = 1
+
+// probeRecordingMigrations upgrade recordings by version
+var probeRecordingMigrations = map[int]utils.SchemaMigration{}
+
+// ProbeRecording is every kube probe job run during a run, along with the connectivity it observed, in the order run.
+// It's safe to share between runners.
+type ProbeRecording struct {
+	SchemaVersion int
+	Results       []*JobResult
+	lock          sync.Mutex
+}
+
+func NewProbeRecording() *ProbeRecording {
+	return &ProbeRecording{SchemaVersion: ProbeRecordingSchemaVersion}
+}
+
+func ReadProbeRecording(path string) (*ProbeRecording, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read probe recording %s", path)
+	}
+	bytes, err = utils.MigrateSchema(bytes, ProbeRecordingSchemaVersion, probeRecordingMigrations)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to migrate probe recording %s", path)
+	}
+	recording := &ProbeRecording{}
+	if err := json.Unmarshal(bytes, recording); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal probe recording %s", path)
+	}
+	return recording, nil
+}
+
+func (p *ProbeRecording) Add(results []*JobResult) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.Results = append(p.Results, results...)
+}
+
+func (p *ProbeRecording) Write(path string) error {
+	p.lock.Lock()
+	bytes, err := json.MarshalIndent(p, "", "  ")
+	p.lock.Unlock()
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal probe recording")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, bytes, 0644), "unable to write probe recording %s", path)
+}
+
+// RecordingJobRunner passes jobs through to the wrapped JobRunner, and records their results
+type RecordingJobRunner struct {
+	JobRunner JobRunner
+	Recording *ProbeRecording
+}
+
+func (r *RecordingJobRunner) RunJobs(jobs []*Job) []*JobResult {
+	results := r.JobRunner.RunJobs(jobs)
+	r.Recording.Add(results)
+	return results
+}
+
+// ReplayJobRunner serves the results from a ProbeRecording instead of probing.  Jobs are matched to results by key --
+// source pod and container, target, protocol and port -- and each result is served once, in the order recorded, so
+// that the same job gets the result it got at the same point of the recorded run.  Jobs with no result left are
+// reported as failing to execute.
+type ReplayJobRunner struct {
+	lock    sync.Mutex
+	results map[string][]*JobResult
+}
+
+func NewReplayJobRunner(recording *ProbeRecording) *ReplayJobRunner {
+	results := map[string][]*JobResult{}
+	for _, result := range recording.Results {
+		key := result.Job.Key()
+		results[key] = append(results[key], result)
+	}
+	return &ReplayJobRunner{results: results}
+}
+
+func (r *ReplayJobRunner) RunJobs(jobs []*Job) []*JobResult {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	results := make([]*JobResult, len(jobs))
+	for i, job := range jobs {
+		key := job.Key()
+		recorded := r.results[key]
+		if len(recorded) == 0 {
+			logrus.Warnf("no recorded result left for probe job %s", key)
+			results[i] = &JobResult{Job: job, Combined: ConnectivityCheckFailed}
+			continue
+		}
+		r.results[key] = recorded[1:]
+		result := *recorded[0]
+		result.Job = job
+		results[i] = &result
+	}
+	return results
+}
+
+// Remaining counts the recorded results which haven't been served
+func (r *ReplayJobRunner) Remaining() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	count := 0
+	for _, results := range r.results {
+		count += len(results)
+	}
+	return count
+}
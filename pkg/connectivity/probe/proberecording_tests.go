@@ -0,0 +1,60 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"path/filepath"
+)
+
+// runJobsFunc is a JobRunner which runs jobs with f
+type runJobsFunc struct {
+	f func(jobs []*Job) []*JobResult
+}
+
+func (r *runJobsFunc) RunJobs(jobs []*Job) []*JobResult {
+	return r.f(jobs)
+}
+
+func RunProbeRecordingTests() {
+	Describe("Probe recording", func() {
+		It("Should replay recorded results by job, in the order recorded", func() {
+			tcp80 := &Job{FromKey: "x/a", FromContainer: "cont-80-tcp", ToKey: "x/b", ToContainer: "cont-80-tcp", Protocol: v1.ProtocolTCP, ResolvedPort: 80}
+			udp80 := &Job{FromKey: "x/a", FromContainer: "cont-80-tcp", ToKey: "x/b", ToContainer: "cont-80-udp", Protocol: v1.ProtocolUDP, ResolvedPort: 80}
+			combined := []Connectivity{ConnectivityAllowed, ConnectivityBlocked, ConnectivityBlocked}
+			recorded := &runJobsFunc{f: func(jobs []*Job) []*JobResult {
+				var results []*JobResult
+				for _, job := range jobs {
+					results = append(results, &JobResult{Job: job, Combined: combined[0]})
+					combined = combined[1:]
+				}
+				return results
+			}}
+			recording := NewProbeRecording()
+			runner := &RecordingJobRunner{JobRunner: recorded, Recording: recording}
+			runner.RunJobs([]*Job{tcp80, udp80})
+			runner.RunJobs([]*Job{tcp80})
+
+			dir, err := ioutil.TempDir("", "cyclonus-probe-recording")
+			Expect(err).To(Succeed())
+			path := filepath.Join(dir, "probes.json")
+			Expect(recording.Write(path)).To(Succeed())
+			read, err := ReadProbeRecording(path)
+			Expect(err).To(Succeed())
+			Expect(read.SchemaVersion).To(Equal(ProbeRecordingSchemaVersion))
+			Expect(read.Results).To(HaveLen(3))
+
+			replay := NewReplayJobRunner(read)
+			Expect(replay.Remaining()).To(Equal(3))
+			results := replay.RunJobs([]*Job{udp80, tcp80})
+			Expect(results[0].Job).To(BeIdenticalTo(udp80))
+			Expect(results[0].Combined).To(Equal(ConnectivityBlocked))
+			Expect(results[1].Job).To(BeIdenticalTo(tcp80))
+			Expect(results[1].Combined).To(Equal(ConnectivityAllowed))
+			Expect(replay.RunJobs([]*Job{tcp80})[0].Combined).To(Equal(ConnectivityBlocked))
+			Expect(replay.Remaining()).To(Equal(0))
+			Expect(replay.RunJobs([]*Job{tcp80})[0].Combined).To(Equal(ConnectivityCheckFailed))
+		})
+	})
+}
@@ -0,0 +1,84 @@
+package probe
+
+import (
+	"encoding/csv"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+var reachabilityCSVHeader = []string{"from", "to", "port", "ingress", "egress", "combined"}
+
+// Reachability is a single job result of a table, flattened: whether traffic from one pod to another, on one
+// protocol and port, is allowed
+type Reachability struct {
+	From     string
+	To       string
+	Port     string
+	Ingress  Connectivity `json:",omitempty"`
+	Egress   Connectivity `json:",omitempty"`
+	Combined Connectivity
+}
+
+// Reachability flattens the table's job results, ordered by source, destination, and then protocol and port
+func (t *Table) Reachability() []*Reachability {
+	var reachability []*Reachability
+	for _, key := range t.Wrapped.Keys() {
+		jobResults := t.Get(key.From, key.To).JobResults
+		var ports []string
+		for port := range jobResults {
+			ports = append(ports, port)
+		}
+		sort.Strings(ports)
+		for _, port := range ports {
+			jobResult := jobResults[port]
+			entry := &Reachability{From: key.From, To: key.To, Port: port, Combined: jobResult.Combined}
+			if jobResult.Ingress != nil {
+				entry.Ingress = *jobResult.Ingress
+			}
+			if jobResult.Egress != nil {
+				entry.Egress = *jobResult.Egress
+			}
+			reachability = append(reachability, entry)
+		}
+	}
+	return reachability
+}
+
+// RenderCSV renders the table's flattened job results as csv, one row per job result, with a header
+func (t *Table) RenderCSV() (string, error) {
+	str := &strings.Builder{}
+	writer := csv.NewWriter(str)
+	records := [][]string{reachabilityCSVHeader}
+	for _, entry := range t.Reachability() {
+		records = append(records, []string{entry.From, entry.To, entry.Port, string(entry.Ingress), string(entry.Egress), string(entry.Combined)})
+	}
+	if err := writer.WriteAll(records); err != nil {
+		return "", err
+	}
+	return str.String(), nil
+}
+
+// ParseReachabilityCSV reads reachability rendered by RenderCSV
+func ParseReachabilityCSV(reader io.Reader) ([]*Reachability, error) {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read reachability csv")
+	}
+	if len(records) == 0 || strings.Join(records[0], ",") != strings.Join(reachabilityCSVHeader, ",") {
+		return nil, errors.Errorf("expected reachability csv header '%s'", strings.Join(reachabilityCSVHeader, ","))
+	}
+	var reachability []*Reachability
+	for _, record := range records[1:] {
+		reachability = append(reachability, &Reachability{
+			From:     record[0],
+			To:       record[1],
+			Port:     record[2],
+			Ingress:  Connectivity(record[3]),
+			Egress:   Connectivity(record[4]),
+			Combined: Connectivity(record[5]),
+		})
+	}
+	return reachability, nil
+}
@@ -0,0 +1,62 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+func RunReachabilityTests() {
+	Describe("Reachability", func() {
+		resources := &Resources{
+			Namespaces: map[string]map[string]string{"x": {"ns": "x"}},
+			Pods: []*Pod{
+				NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, nil),
+				NewDefaultPod("x", "b", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, nil),
+			},
+		}
+		denyToA := &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "deny-to-a"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"pod": "a"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		}
+		table := NewSimulatedRunner(matcher.BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{denyToA})).
+			RunProbeForConfig(generator.ProbeAllAvailable, resources)
+
+		It("Should flatten job results in order", func() {
+			Expect(table.Reachability()).To(Equal([]*Reachability{
+				{From: "x/a", To: "x/a", Port: "TCP/80", Ingress: ConnectivityBlocked, Egress: ConnectivityAllowed, Combined: ConnectivityBlocked},
+				{From: "x/a", To: "x/b", Port: "TCP/80", Ingress: ConnectivityAllowed, Egress: ConnectivityAllowed, Combined: ConnectivityAllowed},
+				{From: "x/b", To: "x/a", Port: "TCP/80", Ingress: ConnectivityBlocked, Egress: ConnectivityAllowed, Combined: ConnectivityBlocked},
+				{From: "x/b", To: "x/b", Port: "TCP/80", Ingress: ConnectivityAllowed, Egress: ConnectivityAllowed, Combined: ConnectivityAllowed},
+			}))
+		})
+
+		It("Should render csv with a header", func() {
+			csv, err := table.RenderCSV()
+			Expect(err).To(Succeed())
+			Expect(csv).To(Equal(`from,to,port,ingress,egress,combined
+x/a,x/a,TCP/80,blocked,allowed,blocked
+x/a,x/b,TCP/80,allowed,allowed,allowed
+x/b,x/a,TCP/80,blocked,allowed,blocked
+x/b,x/b,TCP/80,allowed,allowed,allowed
+`))
+
+			parsed, err := ParseReachabilityCSV(strings.NewReader(csv))
+			Expect(err).To(Succeed())
+			Expect(parsed).To(Equal(table.Reachability()))
+		})
+
+		It("Should reject csv without the header", func() {
+			_, err := ParseReachabilityCSV(strings.NewReader("x/a,x/b,TCP/80,allowed,allowed,allowed\n"))
+			Expect(err).ToNot(Succeed())
+		})
+	})
+}
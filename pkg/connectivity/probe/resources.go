@@ -18,18 +18,26 @@ type Resources struct {
 	//ExternalIPs []string
 }
 
-func NewDefaultResources(kubernetes kube.IKubernetes, namespaces []string, podNames []string, ports []int, protocols []v1.Protocol, externalIPs []string, podCreationTimeoutSeconds int, batchJobs bool) (*Resources, error) {
-	sort.Strings(externalIPs)
+func NewDefaultResources(kubernetes kube.IKubernetes, namespaces []string, podNames []string, ports []int, protocols []v1.Protocol, podCreationTimeoutSeconds int, batchJobs bool, podOptions *PodOptions) (*Resources, error) {
+	return NewRenamedDefaultResources(kubernetes, namespaces, nil, podNames, ports, protocols, podCreationTimeoutSeconds, batchJobs, podOptions)
+}
+
+// NewRenamedDefaultResources creates the default resources with each namespace under the name namespaceNames maps
+// it to, if any, but labeled with its original name -- so that test cases renamed the same way select the same pods.
+func NewRenamedDefaultResources(kubernetes kube.IKubernetes, namespaces []string, namespaceNames map[string]string, podNames []string, ports []int, protocols []v1.Protocol, podCreationTimeoutSeconds int, batchJobs bool, podOptions *PodOptions) (*Resources, error) {
 	r := &Resources{
 		Namespaces: map[string]map[string]string{},
-		//ExternalIPs: externalIPs,
 	}
 
 	for _, ns := range namespaces {
+		name := ns
+		if renamed, ok := namespaceNames[ns]; ok {
+			name = renamed
+		}
 		for _, podName := range podNames {
-			r.Pods = append(r.Pods, NewDefaultPod(ns, podName, ports, protocols, batchJobs))
+			r.Pods = append(r.Pods, NewDefaultPod(name, podName, ports, protocols, batchJobs, podOptions))
 		}
-		r.Namespaces[ns] = map[string]string{"ns": ns}
+		r.Namespaces[name] = map[string]string{"ns": ns}
 	}
 
 	if err := r.CreateResourcesInKube(kubernetes); err != nil {
@@ -75,6 +83,7 @@ func (r *Resources) getPodIPsFromKube(kubernetes kube.IKubernetes) error {
 		return err
 	}
 
+	zones := &nodeZones{kubernetes: kubernetes, zones: map[string]string{}}
 	for _, kubePod := range podList {
 		if kubePod.Status.PodIP == "" {
 			return errors.Errorf("no ip found for pod %s/%s", kubePod.Namespace, kubePod.Name)
@@ -85,6 +94,16 @@ func (r *Resources) getPodIPsFromKube(kubernetes kube.IKubernetes) error {
 			return errors.Errorf("unable to find pod %s/%s in resources", kubePod.Namespace, kubePod.Name)
 		}
 		pod.IP = kubePod.Status.PodIP
+		pod.FamilyIPs = FamilyIPs(kubePod.Status.PodIPs)
+		pod.SecondaryIPs, err = SecondaryNetworkIPs(kubePod.Annotations)
+		if err != nil {
+			return errors.WithMessagef(err, "pod %s/%s", kubePod.Namespace, kubePod.Name)
+		}
+		pod.NodeName = kubePod.Spec.NodeName
+		pod.Zone, err = zones.zone(pod.NodeName)
+		if err != nil {
+			return err
+		}
 		kubeService, err := kubernetes.GetService(pod.Namespace, pod.ServiceName())
 		if err != nil {
 			return err
@@ -164,19 +183,60 @@ func (r *Resources) DeleteNamespace(ns string) (*Resources, error) {
 }
 
 // CreatePod returns a new object with a new pod.  It should not affect the original Resources object.
-func (r *Resources) CreatePod(ns string, podName string, labels map[string]string) (*Resources, error) {
+// CreatePod returns a new object with a new pod, whose container ports are renamed by portNames.  It should not affect
+// the original Resources object.
+func (r *Resources) CreatePod(ns string, podName string, labels map[string]string, portNames map[string]string) (*Resources, error) {
 	// TODO this needs to be improved
 	//   for now, let's assume all pods have the same containers and just copy the containers from the first pod
 	if _, ok := r.Namespaces[ns]; !ok {
 		return nil, errors.Errorf("can't find namespace %s", ns)
 	}
+	containers, err := renamePorts(r.Pods[0].Containers, portNames)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to create pod %s/%s", ns, podName)
+	}
+	newPod := NewPod(ns, podName, labels, "TODO", containers)
+	newPod.Annotations = r.Pods[0].Annotations
+	newPod.Restricted = r.Pods[0].Restricted
+	newPod.NodeSelector = r.Pods[0].NodeSelector
+	newPod.NodeAffinity = r.Pods[0].NodeAffinity
 	return &Resources{
 		Namespaces: r.Namespaces,
-		Pods:       append(append([]*Pod{}, r.Pods...), NewPod(ns, podName, labels, "TODO", r.Pods[0].Containers)),
+		Pods:       append(append([]*Pod{}, r.Pods...), newPod),
 		//ExternalIPs: r.ExternalIPs,
 	}, nil
 }
 
+// renamePorts copies the containers, renaming their ports by portNames, which map existing names to new ones
+func renamePorts(containers []*Container, portNames map[string]string) ([]*Container, error) {
+	if len(portNames) == 0 {
+		return containers, nil
+	}
+	renamed := map[string]bool{}
+	var copies []*Container
+	for _, container := range containers {
+		containerCopy := *container
+		if name, ok := portNames[container.PortName]; ok {
+			containerCopy.PortName = name
+			renamed[container.PortName] = true
+		}
+		copies = append(copies, &containerCopy)
+	}
+	for oldName := range portNames {
+		if !renamed[oldName] {
+			return nil, errors.Errorf("no container port named %s to rename", oldName)
+		}
+	}
+	names := map[string]bool{}
+	for _, container := range copies {
+		if names[container.PortName] {
+			return nil, errors.Errorf("more than one container port named %s", container.PortName)
+		}
+		names[container.PortName] = true
+	}
+	return copies, nil
+}
+
 // UpdatePodLabel returns a new object with an updated pod.  It should not affect the original Resources object.
 func (r *Resources) SetPodLabels(ns string, podName string, labels map[string]string) (*Resources, error) {
 	var pods []*Pod
@@ -267,18 +327,36 @@ func (r *Resources) CreateResourcesInKube(kubernetes kube.IKubernetes) error {
 	return nil
 }
 
+const (
+	// ManagedByAnnotation marks namespaces created by cyclonus, so that leftovers from earlier runs can be found.
+	// An annotation is used instead of a label so that it can't influence label selectors in policies.
+	ManagedByAnnotation = "cyclonus.mattfenwick.github.io/managed-by"
+	ManagedByValue      = "cyclonus"
+)
+
 func KubeNamespace(ns string, labels map[string]string) *v1.Namespace {
-	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns, Labels: labels}}
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        ns,
+		Labels:      labels,
+		Annotations: map[string]string{ManagedByAnnotation: ManagedByValue},
+	}}
 }
 
 func (r *Resources) GetJobsForProbeConfig(config *generator.ProbeConfig) *Jobs {
+	var jobs *Jobs
 	if config.AllAvailable {
-		return r.GetJobsAllAvailableServers(config.Mode)
+		jobs = r.GetJobsAllAvailableServers(config.Mode)
 	} else if config.PortProtocol != nil {
-		return r.GetJobsForNamedPortProtocol(config.PortProtocol.Port, config.PortProtocol.Protocol, config.Mode)
+		jobs = r.GetJobsForNamedPortProtocol(config.PortProtocol.Port, config.PortProtocol.Protocol, config.Mode)
 	} else {
 		panic(errors.Errorf("invalid ProbeConfig %+v", config))
 	}
+	for _, jobSlice := range [][]*Job{jobs.Valid, jobs.BadNamedPort, jobs.BadPortProtocol} {
+		for _, job := range jobSlice {
+			job.Exchanges = config.Exchanges
+		}
+	}
+	return jobs
 }
 
 func (r *Resources) GetJobsForNamedPortProtocol(port intstr.IntOrString, protocol v1.Protocol, mode generator.ProbeMode) *Jobs {
@@ -291,10 +369,11 @@ func (r *Resources) GetJobsForNamedPortProtocol(port intstr.IntOrString, protoco
 				FromNamespaceLabels: r.Namespaces[podFrom.Namespace],
 				FromPod:             podFrom.Name,
 				FromPodLabels:       podFrom.Labels,
-				FromContainer:       podFrom.Containers[0].Name,
+				FromContainer:       podFrom.ClientContainer(),
 				FromIP:              podFrom.IP,
 				ToKey:               podTo.PodString().String(),
 				ToHost:              podTo.Host(mode),
+				ToPod:               podTo.Name,
 				ToNamespace:         podTo.Namespace,
 				ToNamespaceLabels:   r.Namespaces[podTo.Namespace],
 				ToPodLabels:         podTo.Labels,
@@ -344,10 +423,11 @@ func (r *Resources) GetJobsAllAvailableServers(mode generator.ProbeMode) *Jobs {
 					FromNamespaceLabels: r.Namespaces[podFrom.Namespace],
 					FromPod:             podFrom.Name,
 					FromPodLabels:       podFrom.Labels,
-					FromContainer:       podFrom.Containers[0].Name,
+					FromContainer:       podFrom.ClientContainer(),
 					FromIP:              podFrom.IP,
 					ToKey:               podTo.PodString().String(),
 					ToHost:              podTo.Host(mode),
+					ToPod:               podTo.Name,
 					ToNamespace:         podTo.Namespace,
 					ToNamespaceLabels:   r.Namespaces[podTo.Namespace],
 					ToPodLabels:         podTo.Labels,
@@ -1,8 +1,11 @@
 package probe
 
 import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func RunResourcesTests() {
@@ -28,13 +31,36 @@ func RunResourcesTests() {
 				},
 				Pods: []*Pod{{Namespace: "x", Name: "a"}},
 			}
-			r2, err := r.CreatePod("x", "b", map[string]string{})
+			r2, err := r.CreatePod("x", "b", map[string]string{}, nil)
 			Expect(err).To(Succeed())
 
 			Expect(r.Pods).To(HaveLen(1))
 			Expect(r2.Pods).To(HaveLen(2))
 		})
 
+		It("Should rename a new pod's ports, leaving the other pods' alone", func() {
+			r := &Resources{
+				Namespaces: map[string]map[string]string{"x": {}},
+				Pods:       []*Pod{NewDefaultPod("x", "a", []int{80, 81}, []v1.Protocol{v1.ProtocolTCP}, false, nil)},
+			}
+			r2, err := r.CreatePod("x", "b", map[string]string{}, map[string]string{"serve-81-tcp": "web"})
+			Expect(err).To(Succeed())
+
+			port, err := r2.Pods[1].ResolveNamedPort("web")
+			Expect(err).To(Succeed())
+			Expect(port).To(Equal(81))
+			name, err := r2.Pods[1].ResolveNumberedPort(80)
+			Expect(err).To(Succeed())
+			Expect(name).To(Equal("serve-80-tcp"))
+			_, err = r2.Pods[0].ResolveNamedPort("web")
+			Expect(err).NotTo(Succeed())
+
+			_, err = r.CreatePod("x", "b", map[string]string{}, map[string]string{"serve-82-tcp": "web"})
+			Expect(err).NotTo(Succeed())
+			_, err = r.CreatePod("x", "b", map[string]string{}, map[string]string{"serve-81-tcp": "serve-80-tcp"})
+			Expect(err).NotTo(Succeed())
+		})
+
 		It("Should set pod labels nondestructively", func() {
 			labels := map[string]string{"pod": "b"}
 			r := &Resources{
@@ -49,5 +75,37 @@ func RunResourcesTests() {
 			Expect(r.Pods[0].Labels).To(Equal(labels))
 			Expect(r2.Pods[0].Labels).To(Equal(map[string]string{}))
 		})
+
+		It("Should build resources from existing pods", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			_, err := kubernetes.CreateNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"team": "a"}}})
+			Expect(err).To(Succeed())
+			for _, name := range []string{"web", "db", "other"} {
+				tier := name
+				if name == "other" {
+					tier = ""
+				}
+				_, err = kubernetes.CreatePod(&v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: name, Labels: map[string]string{"tier": tier}},
+					Spec: v1.PodSpec{Containers: []v1.Container{{
+						Name:  "main",
+						Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+					}}},
+				})
+				Expect(err).To(Succeed())
+			}
+
+			r, err := NewResourcesFromExistingPods(kubernetes, []string{"app"}, "tier in (web, db)", 10)
+			Expect(err).To(Succeed())
+
+			Expect(r.Namespaces).To(Equal(map[string]map[string]string{"app": {"team": "a"}}))
+			Expect(r.Pods).To(HaveLen(2))
+			Expect(r.Pods[0].Containers).To(Equal([]*Container{{Name: "main", Port: 8080, Protocol: v1.ProtocolTCP, PortName: "http"}}))
+			Expect(r.Pods[0].ClientContainer()).To(Equal(proberContainerName))
+
+			kubePod, err := kubernetes.GetPod("app", "web")
+			Expect(err).To(Succeed())
+			Expect(kubePod.Spec.EphemeralContainers).To(HaveLen(1))
+		})
 	})
 }
@@ -0,0 +1,74 @@
+package probe
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/olekukonko/tablewriter"
+	"strings"
+)
+
+// ConnectivityChange is a probe whose simulated result differs between two sets of policies
+type ConnectivityChange struct {
+	From   string
+	To     string
+	Port   string
+	Before Connectivity
+	After  Connectivity
+}
+
+// SimulatedDiff is what changes, between two sets of policies, in the simulated connectivity between resources' pods
+// on all their ports
+type SimulatedDiff struct {
+	Probes  int
+	Changes []*ConnectivityChange
+}
+
+func NewSimulatedDiff(before *matcher.Policy, after *matcher.Policy, resources *Resources) *SimulatedDiff {
+	jobs := resources.GetJobsForProbeConfig(generator.ProbeAllAvailable).Valid
+	beforeRunner, afterRunner := &SimulatedJobRunner{Policies: before}, &SimulatedJobRunner{Policies: after}
+	diff := &SimulatedDiff{Probes: len(jobs)}
+	for _, job := range jobs {
+		beforeResult, afterResult := beforeRunner.RunJob(job), afterRunner.RunJob(job)
+		if beforeResult.Combined != afterResult.Combined {
+			diff.Changes = append(diff.Changes, &ConnectivityChange{
+				From:   job.FromKey,
+				To:     job.ToKey,
+				Port:   afterResult.Key(),
+				Before: beforeResult.Combined,
+				After:  afterResult.Combined,
+			})
+		}
+	}
+	return diff
+}
+
+// CountChanges is how many probes are allowed after, but not before -- and the other way around
+func (d *SimulatedDiff) CountChanges() (int, int) {
+	nowAllowed, nowBlocked := 0, 0
+	for _, change := range d.Changes {
+		if change.After == ConnectivityAllowed {
+			nowAllowed++
+		} else {
+			nowBlocked++
+		}
+	}
+	return nowAllowed, nowBlocked
+}
+
+// Table lists only the probes which changed
+func (d *SimulatedDiff) Table() string {
+	str := &strings.Builder{}
+	nowAllowed, nowBlocked := d.CountChanges()
+	if len(d.Changes) > 0 {
+		table := tablewriter.NewWriter(str)
+		table.SetAutoWrapText(false)
+		table.SetHeader([]string{"From", "To", "Port", "Before", "After"})
+		for _, change := range d.Changes {
+			table.Append([]string{change.From, change.To, change.Port, string(change.Before), string(change.After)})
+		}
+		table.Render()
+	}
+	str.WriteString(fmt.Sprintf("%d of %d probes changed: %d now allowed, %d now blocked\n", len(d.Changes), d.Probes, nowAllowed, nowBlocked))
+	return str.String()
+}
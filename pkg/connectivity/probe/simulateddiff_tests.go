@@ -0,0 +1,50 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func RunSimulatedDiffTests() {
+	Describe("SimulatedDiff", func() {
+		resources := &Resources{
+			Namespaces: map[string]map[string]string{"x": {"ns": "x"}},
+			Pods: []*Pod{
+				NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, nil),
+				NewDefaultPod("x", "b", []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, nil),
+			},
+		}
+		denyToA := &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "deny-to-a"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"pod": "a"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		}
+
+		It("Should list only the probes whose connectivity changes", func() {
+			diff := NewSimulatedDiff(matcher.BuildNetworkPolicies(true, nil), matcher.BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{denyToA}), resources)
+
+			Expect(diff.Probes).To(Equal(4))
+			Expect(diff.Changes).To(Equal([]*ConnectivityChange{
+				{From: "x/a", To: "x/a", Port: "TCP/80", Before: ConnectivityAllowed, After: ConnectivityBlocked},
+				{From: "x/b", To: "x/a", Port: "TCP/80", Before: ConnectivityAllowed, After: ConnectivityBlocked},
+			}))
+			nowAllowed, nowBlocked := diff.CountChanges()
+			Expect([]int{nowAllowed, nowBlocked}).To(Equal([]int{0, 2}))
+			Expect(diff.Table()).To(ContainSubstring("2 of 4 probes changed: 0 now allowed, 2 now blocked"))
+		})
+
+		It("Should have no changes for the same policies", func() {
+			policies := matcher.BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{denyToA})
+			diff := NewSimulatedDiff(policies, policies, resources)
+
+			Expect(diff.Changes).To(BeEmpty())
+			Expect(diff.Table()).To(Equal("0 of 4 probes changed: 0 now allowed, 0 now blocked\n"))
+		})
+	})
+}
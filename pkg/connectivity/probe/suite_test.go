@@ -10,5 +10,26 @@ import (
 func TestProbe(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunResourcesTests()
+	RunClientCommandTests()
+	RunMeshTests()
+	RunMultusTests()
+	RunDualStackTests()
+	RunZonesTests()
+	RunJobFilterTests()
+	RunHTTPCheckTests()
+	RunTLSCheckTests()
+	RunLongLivedConnectionTests()
+	RunLatencyTests()
+	RunJobRunnerTests()
+	RunEgressPathTests()
+	RunExternalEndpointTests()
+	RunWorkerDaemonSetTests()
+	RunMultiTargetTests()
+	RunDNSCheckTests()
+	RunPodTests()
+	RunOpenShiftTests()
+	RunProbeRecordingTests()
+	RunSimulatedDiffTests()
+	RunReachabilityTests()
 	RunSpecs(t, "generator suite")
 }
@@ -1,6 +1,7 @@
 package probe
 
 import (
+	"github.com/mattfenwick/cyclonus/pkg/generator"
 	"github.com/mattfenwick/cyclonus/pkg/utils"
 	"github.com/pkg/errors"
 	"sort"
@@ -55,6 +56,78 @@ func (t *Table) Get(from string, to string) *Item {
 	return t.Wrapped.Get(from, to).(*Item)
 }
 
+// Restrict returns a table with only the given froms and tos
+func (t *Table) Restrict(froms []string, tos []string) *Table {
+	return &Table{Wrapped: t.Wrapped.Restrict(froms, tos)}
+}
+
+// WithExpectations returns a copy of the table, where the combined connectivity of each pair with an expectation in
+// the matrix is replaced by that expectation.  Job results which are neither allowed nor blocked -- such as invalid
+// named ports, which aren't probed -- are left alone.
+func (t *Table) WithExpectations(matrix *generator.ConnectivityMatrix) *Table {
+	table := NewTable(t.Wrapped.Froms)
+	for _, key := range t.Wrapped.Keys() {
+		allowed, ok := matrix.Expectation(key.From, key.To)
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			copied := *jobResult
+			if ok && (copied.Combined == ConnectivityAllowed || copied.Combined == ConnectivityBlocked) {
+				if allowed {
+					copied.Combined = ConnectivityAllowed
+				} else {
+					copied.Combined = ConnectivityBlocked
+				}
+			}
+			utils.DoOrDie(table.Get(key.From, key.To).AddJobResult(&copied))
+		}
+	}
+	return table
+}
+
+// WithWaivers returns a copy of the table, where the combined connectivity of each job result matched by a waiver is
+// replaced by the waiver's expected result -- the last matching waiver's, if more than one match.  As with
+// WithExpectations, job results which are neither allowed nor blocked are left alone.
+func (t *Table) WithWaivers(waivers []*generator.Waiver) *Table {
+	table := NewTable(t.Wrapped.Froms)
+	for _, key := range t.Wrapped.Keys() {
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			copied := *jobResult
+			for _, waiver := range waivers {
+				if (copied.Combined == ConnectivityAllowed || copied.Combined == ConnectivityBlocked) && waiver.Matches(key.From, key.To, copied.Job.ResolvedPort, copied.Job.Protocol) {
+					if waiver.IsAllowed() {
+						copied.Combined = ConnectivityAllowed
+					} else {
+						copied.Combined = ConnectivityBlocked
+					}
+				}
+			}
+			utils.DoOrDie(table.Get(key.From, key.To).AddJobResult(&copied))
+		}
+	}
+	return table
+}
+
+// CountJobResults counts the job results across all cells
+func (t *Table) CountJobResults() int {
+	count := 0
+	for _, key := range t.Wrapped.Keys() {
+		count += len(t.Get(key.From, key.To).JobResults)
+	}
+	return count
+}
+
+// CountConnectivity counts the job results, across all cells, with the given combined connectivity
+func (t *Table) CountConnectivity(connectivity Connectivity) int {
+	count := 0
+	for _, key := range t.Wrapped.Keys() {
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.Combined == connectivity {
+				count++
+			}
+		}
+	}
+	return count
+}
+
 func (t *Table) RenderIngress() string {
 	return t.renderTableHelper(getIngress)
 }
@@ -0,0 +1,232 @@
+package probe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// tlsServerName is the name in the servers' certificate.  Clients check the certificate against it, rather than
+// against the address they connect to, so that one certificate works for service names, service IPs and pod IPs.
+const tlsServerName = "cyclonus-server"
+
+// TLSCertificate is the self-signed certificate -- and its key -- which servers terminate TLS with, and which clients
+// trust, and nothing else.  It's made fresh for each run.
+type TLSCertificate struct {
+	CertPEM string
+	KeyPEM  string
+}
+
+func NewTLSCertificate() (*TLSCertificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to generate TLS key")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to generate TLS certificate serial number")
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: tlsServerName},
+		DNSNames:              []string{tlsServerName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create TLS certificate")
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to marshal TLS key")
+	}
+	return &TLSCertificate{
+		CertPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		KeyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}, nil
+}
+
+// ServerCommand serves HTTPS on port with agnhost netexec, which answers /hostname with the pod's hostname.  The
+// certificate and key are passed in through the TLS_CERT and TLS_KEY environment variables, and written out for
+// netexec to read.
+func (c *TLSCertificate) ServerCommand(port int) []string {
+	script := fmt.Sprintf(
+		`printf '%%s' "$TLS_CERT" >/tmp/tls.crt && printf '%%s' "$TLS_KEY" >/tmp/tls.key && exec /agnhost netexec --http-port=%d --udp-port=-1 --tls-cert-file=/tmp/tls.crt --tls-private-key-file=/tmp/tls.key`,
+		port)
+	return []string{"sh", "-c", script}
+}
+
+func (c *TLSCertificate) ServerEnv() []v1.EnvVar {
+	return []v1.EnvVar{
+		{Name: "TLS_CERT", Value: c.CertPEM},
+		{Name: "TLS_KEY", Value: c.KeyPEM},
+	}
+}
+
+// TLSCheck records what a TCP probe got when, after connecting, it did a TLS handshake and requested the serving
+// pod's hostname: in a service mesh, connections are accepted -- and TLS is terminated -- by proxies, so connecting
+// doesn't show that the serving pod was reached
+type TLSCheck struct {
+	Connected bool
+	// Error is curl's error, if the handshake -- or the request made over it -- failed, i.e. because something other
+	// than the serving pod answered, with a certificate of its own
+	Error string
+	Body  string
+	// ExpectedBody is the serving pod's hostname, which netexec answers with; if empty, any body is accepted
+	ExpectedBody string
+}
+
+// IsVerified is true if the handshake worked, and the serving pod answered the request
+func (t *TLSCheck) IsVerified() bool {
+	return t.Connected && t.Error == "" && (t.ExpectedBody == "" || t.Body == t.ExpectedBody)
+}
+
+// IsPartial is true for connections which were accepted, but whose handshake failed, or whose request the serving pod
+// didn't answer
+func (t *TLSCheck) IsPartial() bool {
+	return t.Connected && !t.IsVerified()
+}
+
+// ShortString marks partial connections with 'T!' if the handshake failed, and 'B!' if something other than the
+// serving pod answered
+func (t *TLSCheck) ShortString() string {
+	switch {
+	case !t.Connected:
+		return ConnectivityBlocked.ShortString()
+	case t.IsVerified():
+		return ConnectivityAllowed.ShortString()
+	case t.Error != "":
+		return "T!"
+	default:
+		return "B!"
+	}
+}
+
+const tlsCheckSuccess = "tls ok"
+
+// TLSCheckCommand connects, like agnhost connect does for TCP, and only if that works requests /hostname over TLS
+// with curl, trusting only certPEM; it prints whether it connected, then the response body and a success line, or
+// curl's error
+func (j *Job) TLSCheckCommand(certPEM string) []string {
+	script := fmt.Sprintf(
+		`printf '%%s' "$1" >/tmp/cyclonus-tls.crt; if /agnhost connect %s --timeout=1s --protocol=tcp >/dev/null 2>&1; then echo connected; curl -fsS --max-time 3 --cacert /tmp/cyclonus-tls.crt --connect-to %s:%d:%s https://%s:%d/hostname 2>&1 && printf '\n%s\n' || true; else echo "not connected"; fi`,
+		j.ToAddress(), tlsServerName, j.ResolvedPort, j.ToAddress(), tlsServerName, j.ResolvedPort, tlsCheckSuccess)
+	return []string{"sh", "-c", script, "sh", certPEM}
+}
+
+func parseTLSCheckOutput(stdout string, expectedBody string) (*TLSCheck, error) {
+	lines := strings.SplitN(stdout, "\n", 2)
+	switch strings.TrimSpace(lines[0]) {
+	case "not connected":
+		return &TLSCheck{ExpectedBody: expectedBody}, nil
+	case "connected":
+	default:
+		return nil, errors.Errorf("unable to find connection status in output '%s'", stdout)
+	}
+	rest := ""
+	if len(lines) > 1 {
+		rest = strings.TrimSpace(lines[1])
+	}
+	check := &TLSCheck{Connected: true, ExpectedBody: expectedBody}
+	if body := strings.TrimSuffix(rest, tlsCheckSuccess); body != rest {
+		check.Body = strings.TrimSpace(body)
+	} else if rest != "" {
+		// curl explains certificate problems at length, after a first line saying what went wrong
+		check.Error = strings.SplitN(rest, "\n", 2)[0]
+	} else {
+		check.Error = "no output from curl"
+	}
+	return check, nil
+}
+
+// probeTLS is allowed only if the handshake worked and the serving pod answered the request; partial connections are
+// blocked
+func probeTLS(k8s kube.IKubernetes, job *Job, certificate *TLSCertificate) (Connectivity, *TLSCheck, string) {
+	command := job.TLSCheckCommand(certificate.CertPEM)
+	commandDebugString := strings.Join(job.kubeExecCommand(command[:3]), " ")
+	stdout, stderr, commandErr, err := k8s.ExecuteRemoteCommand(job.FromNamespace, job.FromPod, job.FromContainer, command)
+	logrus.Debugf("stdout, stderr from %s: \n%s\n%s", commandDebugString, stdout, stderr)
+	output := fmt.Sprintf("%s\nstdout:\n%s\nstderr:\n%s", commandDebugString, stdout, stderr)
+	if err != nil {
+		logrus.Errorf("unable to set up command %s: %+v", commandDebugString, err)
+		return ConnectivityCheckFailed, nil, fmt.Sprintf("%s\nunable to set up command: %+v", output, err)
+	}
+	if commandErr != nil {
+		logrus.Errorf("unable to run command %s: %+v", commandDebugString, commandErr)
+		return ConnectivityCheckFailed, nil, fmt.Sprintf("%s\ncommand error: %+v", output, commandErr)
+	}
+	check, err := parseTLSCheckOutput(stdout, job.ToPod)
+	if err != nil {
+		logrus.Errorf("unable to get TLS check from command %s: %+v", commandDebugString, err)
+		return ConnectivityCheckFailed, nil, fmt.Sprintf("%s\n%+v", output, err)
+	}
+	if !check.IsVerified() {
+		if check.IsPartial() {
+			logrus.Debugf("command %s connected, but got error '%s' and body '%s' instead of the serving pod's response", commandDebugString, check.Error, check.Body)
+		}
+		return ConnectivityBlocked, check, output
+	}
+	return ConnectivityAllowed, check, output
+}
+
+// HasTLSChecks is true if any job result has a TLS check
+func (t *Table) HasTLSChecks() bool {
+	for _, key := range t.Wrapped.Keys() {
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.TLSCheck != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CountPartialTLS counts the job results, across all cells, which connected but whose handshake failed, or whose
+// request wasn't answered by the serving pod
+func (t *Table) CountPartialTLS() int {
+	count := 0
+	for _, key := range t.Wrapped.Keys() {
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.TLSCheck != nil && jobResult.TLSCheck.IsPartial() {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// RenderTLSChecks renders TCP job results, with partial connections marked with 'T!' or 'B!'
+func (t *Table) RenderTLSChecks() string {
+	table := NewTable(t.Wrapped.Froms)
+	for _, key := range t.Wrapped.Keys() {
+		for jobKey, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.Job.Protocol == v1.ProtocolTCP {
+				table.Get(key.From, key.To).JobResults[jobKey] = jobResult
+			}
+		}
+	}
+	return table.renderTableHelper(getTLSCheck)
+}
+
+func getTLSCheck(result *JobResult) string {
+	if result.TLSCheck == nil {
+		return result.Combined.ShortString()
+	}
+	return result.TLSCheck.ShortString()
+}
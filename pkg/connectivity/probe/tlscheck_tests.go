@@ -0,0 +1,65 @@
+package probe
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunTLSCheckTests() {
+	Describe("TLS checks", func() {
+		It("Should tell verified, failed and misanswered handshakes apart", func() {
+			verified, err := parseTLSCheckOutput("connected\nb\ntls ok\n", "b")
+			Expect(err).To(Succeed())
+			Expect(verified.IsVerified()).To(BeTrue())
+			Expect(verified.ShortString()).To(Equal("."))
+
+			// i.e. terminated by a mesh proxy, with its own certificate
+			failed, err := parseTLSCheckOutput("connected\ncurl: (60) SSL certificate problem: self-signed certificate\nMore details here: https://curl.se/docs/sslcerts.html\n", "b")
+			Expect(err).To(Succeed())
+			Expect(failed.Error).To(Equal("curl: (60) SSL certificate problem: self-signed certificate"))
+			Expect(failed.IsPartial()).To(BeTrue())
+			Expect(failed.ShortString()).To(Equal("T!"))
+
+			misanswered, err := parseTLSCheckOutput("connected\nc\ntls ok\n", "b")
+			Expect(err).To(Succeed())
+			Expect(misanswered.IsPartial()).To(BeTrue())
+			Expect(misanswered.ShortString()).To(Equal("B!"))
+
+			refused, err := parseTLSCheckOutput("not connected\n", "b")
+			Expect(err).To(Succeed())
+			Expect(refused.IsPartial()).To(BeFalse())
+			Expect(refused.ShortString()).To(Equal("X"))
+
+			_, err = parseTLSCheckOutput("", "b")
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("Should serve HTTPS from TCP containers, with a certificate for the server name", func() {
+			certificate, err := NewTLSCertificate()
+			Expect(err).To(Succeed())
+			_, err = tls.X509KeyPair([]byte(certificate.CertPEM), []byte(certificate.KeyPEM))
+			Expect(err).To(Succeed())
+			block, _ := pem.Decode([]byte(certificate.CertPEM))
+			cert, err := x509.ParseCertificate(block.Bytes)
+			Expect(err).To(Succeed())
+			Expect(cert.VerifyHostname(tlsServerName)).To(Succeed())
+
+			pod := NewDefaultPod("x", "a", []int{80}, []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP}, false, &PodOptions{ServeHTTP: true, TLSCertificate: certificate})
+			containers := pod.KubeContainers()
+			Expect(containers[0].Command).To(Equal(certificate.ServerCommand(80)))
+			Expect(containers[0].Env).To(Equal(certificate.ServerEnv()))
+			Expect(containers[1].Command).To(ContainElement("--udp"))
+			Expect(containers[1].Env).To(BeEmpty())
+
+			job := &Job{ToHost: "10.0.0.1", ResolvedPort: 80, Protocol: v1.ProtocolTCP}
+			command := job.TLSCheckCommand(certificate.CertPEM)
+			Expect(command[2]).To(ContainSubstring("--connect-to cyclonus-server:80:10.0.0.1:80 https://cyclonus-server:80/hostname"))
+			Expect(command[4]).To(Equal(certificate.CertPEM))
+		})
+	})
+
+}
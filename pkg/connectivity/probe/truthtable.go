@@ -98,6 +98,17 @@ func (tt *TruthTable) Keys() []*TableKey {
 	return keys
 }
 
+// Restrict returns a table with only the given froms and tos, sharing values with the original
+func (tt *TruthTable) Restrict(froms []string, tos []string) *TruthTable {
+	restricted := NewTruthTable(froms, tos, nil)
+	for _, from := range froms {
+		for _, to := range tos {
+			restricted.Set(from, to, tt.Get(from, to))
+		}
+	}
+	return restricted
+}
+
 func (tt *TruthTable) Table(schema string, rowLine bool, printElement func(string, string, interface{}) string) string {
 	tableString := &strings.Builder{}
 	table := tablewriter.NewWriter(tableString)
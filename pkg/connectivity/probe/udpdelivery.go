@@ -0,0 +1,134 @@
+package probe
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// UDPDelivery records how many of a burst of sequenced UDP datagrams got a response
+type UDPDelivery struct {
+	Sent      int
+	Delivered int
+}
+
+func (d *UDPDelivery) Rate() float64 {
+	if d.Sent == 0 {
+		return 0
+	}
+	return float64(d.Delivered) / float64(d.Sent)
+}
+
+// IsLossy is true for paths which are allowed -- some datagrams got through -- but which dropped some of them
+func (d *UDPDelivery) IsLossy() bool {
+	return d.Delivered > 0 && d.Delivered < d.Sent
+}
+
+func (d *UDPDelivery) ShortString() string {
+	if d.IsLossy() {
+		return fmt.Sprintf("%d/%d!", d.Delivered, d.Sent)
+	}
+	return fmt.Sprintf("%d/%d", d.Delivered, d.Sent)
+}
+
+var udpBurstOutputRegex = regexp.MustCompile(`delivered (\d+) of (\d+)`)
+
+// UDPBurstCommand sends size datagrams, one after another, each of which waits for the server's response; it prints
+// how many got one
+func (j *Job) UDPBurstCommand(size int) []string {
+	script := fmt.Sprintf(
+		`delivered=0; for seq in $(seq 1 %d); do /agnhost connect %s --timeout=1s --protocol=udp >/dev/null 2>&1 && delivered=$((delivered+1)); done; echo "delivered $delivered of %d"`,
+		size, j.ToAddress(), size)
+	return []string{"sh", "-c", script}
+}
+
+func parseUDPBurstOutput(stdout string) (*UDPDelivery, error) {
+	matches := udpBurstOutputRegex.FindStringSubmatch(stdout)
+	if matches == nil {
+		return nil, errors.Errorf("unable to find delivery count in output '%s'", stdout)
+	}
+	delivered, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse delivered count %s", matches[1])
+	}
+	sent, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse sent count %s", matches[2])
+	}
+	return &UDPDelivery{Sent: sent, Delivered: delivered}, nil
+}
+
+// probeUDPBurst is allowed if any of the burst's datagrams got a response
+func probeUDPBurst(k8s kube.IKubernetes, job *Job, size int) (Connectivity, *UDPDelivery) {
+	command := job.UDPBurstCommand(size)
+	commandDebugString := strings.Join(job.kubeExecCommand(command), " ")
+	stdout, stderr, commandErr, err := k8s.ExecuteRemoteCommand(job.FromNamespace, job.FromPod, job.FromContainer, command)
+	logrus.Debugf("stdout, stderr from %s: \n%s\n%s", commandDebugString, stdout, stderr)
+	if err != nil {
+		logrus.Errorf("unable to set up command %s: %+v", commandDebugString, err)
+		return ConnectivityCheckFailed, nil
+	}
+	if commandErr != nil {
+		logrus.Errorf("unable to run command %s: %+v", commandDebugString, commandErr)
+		return ConnectivityCheckFailed, nil
+	}
+	delivery, err := parseUDPBurstOutput(stdout)
+	if err != nil {
+		logrus.Errorf("unable to get UDP delivery from command %s: %+v", commandDebugString, err)
+		return ConnectivityCheckFailed, nil
+	}
+	if delivery.Delivered == 0 {
+		return ConnectivityBlocked, delivery
+	}
+	return ConnectivityAllowed, delivery
+}
+
+// HasUDPDelivery is true if any job result has UDP delivery counts
+func (t *Table) HasUDPDelivery() bool {
+	for _, key := range t.Wrapped.Keys() {
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.UDPDelivery != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CountLossyUDP counts the job results, across all cells, whose UDP burst was partly delivered
+func (t *Table) CountLossyUDP() int {
+	count := 0
+	for _, key := range t.Wrapped.Keys() {
+		for _, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.UDPDelivery != nil && jobResult.UDPDelivery.IsLossy() {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// RenderUDPDelivery renders delivered/sent counts for UDP job results, marking lossy ones with '!'
+func (t *Table) RenderUDPDelivery() string {
+	table := NewTable(t.Wrapped.Froms)
+	for _, key := range t.Wrapped.Keys() {
+		for jobKey, jobResult := range t.Get(key.From, key.To).JobResults {
+			if jobResult.Job.Protocol == v1.ProtocolUDP {
+				table.Get(key.From, key.To).JobResults[jobKey] = jobResult
+			}
+		}
+	}
+	return table.renderTableHelper(getUDPDelivery)
+}
+
+func getUDPDelivery(result *JobResult) string {
+	if result.UDPDelivery == nil {
+		return result.Combined.ShortString()
+	}
+	return result.UDPDelivery.ShortString()
+}
@@ -0,0 +1,202 @@
+package probe
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/worker"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const (
+	WorkerDaemonSetNamespace = "cyclonus-worker"
+	WorkerDaemonSetName      = "worker"
+	// WorkerTokenSecretName is the secret the workers get their token from
+	WorkerTokenSecretName = "worker-token"
+	workerTokenSecretKey  = "token"
+)
+
+func workerKubeTokenSecret(token string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      WorkerTokenSecretName,
+			Namespace: WorkerDaemonSetNamespace,
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{workerTokenSecretKey: []byte(token)},
+	}
+}
+
+func workerKubeDaemonSet(port int) *appsv1.DaemonSet {
+	labels := map[string]string{"app": WorkerDaemonSetName}
+	privileged := true
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      WorkerDaemonSetName,
+			Namespace: WorkerDaemonSetNamespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: v1.PodSpec{
+					// the host's network keeps the workers out of the way of the policies being tested, and the
+					// host's pids -- along with privileges -- let them find and enter the network namespaces of pods
+					HostNetwork: true,
+					HostPID:     true,
+					// service names are resolved from inside the pods' network namespaces, but with the workers'
+					// resolv.conf
+					DNSPolicy:   v1.DNSClusterFirstWithHostNet,
+					Tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}},
+					Containers: []v1.Container{
+						{
+							Name:            WorkerDaemonSetName,
+							ImagePullPolicy: v1.PullIfNotPresent,
+							Image:           cyclonusWorkerImage,
+							// only serve on the node's IP, which is where the API server's pod proxy connects to
+							Command: []string{"/worker", "--port", strconv.Itoa(port), "--bind-address", "$(POD_IP)"},
+							Env: []v1.EnvVar{
+								{Name: "POD_IP", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+								// the token stays out of the daemonset's spec, where anyone allowed to read daemonsets would see it
+								{Name: worker.TokenEnvVar, ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+									LocalObjectReference: v1.LocalObjectReference{Name: WorkerTokenSecretName},
+									Key:                  workerTokenSecretKey,
+								}}},
+							},
+							SecurityContext: &v1.SecurityContext{Privileged: &privileged},
+							Ports:           []v1.ContainerPort{{ContainerPort: int32(port), Protocol: v1.ProtocolTCP}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// isWorkerDaemonSetUpToDate checks whether a deployed daemonset runs the same image, command, env and ports as the
+// expected one
+func isWorkerDaemonSetUpToDate(deployed *appsv1.DaemonSet, expected *appsv1.DaemonSet) bool {
+	deployedContainers, expectedContainers := deployed.Spec.Template.Spec.Containers, expected.Spec.Template.Spec.Containers
+	if len(deployedContainers) != len(expectedContainers) {
+		return false
+	}
+	for i, container := range expectedContainers {
+		deployedContainer := deployedContainers[i]
+		if deployedContainer.Image != container.Image ||
+			!reflect.DeepEqual(deployedContainer.Command, container.Command) ||
+			!reflect.DeepEqual(deployedContainer.Env, container.Env) ||
+			!reflect.DeepEqual(deployedContainer.Ports, container.Ports) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewWorkerToken makes a random token, for the workers of a single run to authenticate batches with
+func NewWorkerToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", errors.Wrapf(err, "unable to generate worker token")
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// deployWorkerTokenSecret creates the secret holding token, replacing one left over from an earlier run with a
+// different token, and reports whether it was already up to date
+func deployWorkerTokenSecret(kubernetes kube.IKubernetes, token string) (bool, error) {
+	if deployed, err := kubernetes.GetSecret(WorkerDaemonSetNamespace, WorkerTokenSecretName); err == nil {
+		if string(deployed.Data[workerTokenSecretKey]) == token {
+			return true, nil
+		}
+		if err := kubernetes.DeleteSecret(WorkerDaemonSetNamespace, WorkerTokenSecretName); err != nil {
+			return false, err
+		}
+	}
+	if _, err := kubernetes.CreateSecret(workerKubeTokenSecret(token)); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// DeployWorkerDaemonSet runs a worker serving on port in the network of every node, in its own namespace, and waits
+// for all of them to be ready.  The workers read token from a secret.  Workers which are already deployed, but with
+// a different image, port or token -- i.e. left over from an earlier run -- are replaced.
+func DeployWorkerDaemonSet(kubernetes kube.IKubernetes, port int, token string, timeoutSeconds int) error {
+	if _, err := kubernetes.GetNamespace(WorkerDaemonSetNamespace); err != nil {
+		if _, err := kubernetes.CreateNamespace(KubeNamespace(WorkerDaemonSetNamespace, map[string]string{"ns": WorkerDaemonSetNamespace})); err != nil {
+			return err
+		}
+	}
+	// running workers only read the secret when they start, so a new token means new workers
+	isTokenUpToDate, err := deployWorkerTokenSecret(kubernetes, token)
+	if err != nil {
+		return err
+	}
+
+	sleep := 5
+	expected := workerKubeDaemonSet(port)
+	if deployed, err := kubernetes.GetDaemonSet(WorkerDaemonSetNamespace, WorkerDaemonSetName); err == nil {
+		if isTokenUpToDate && isWorkerDaemonSetUpToDate(deployed, expected) {
+			logrus.Infof("reusing workers %s/%s", WorkerDaemonSetNamespace, WorkerDaemonSetName)
+		} else {
+			logrus.Infof("replacing out-of-date workers %s/%s", WorkerDaemonSetNamespace, WorkerDaemonSetName)
+			if err := kubernetes.DeleteDaemonSet(WorkerDaemonSetNamespace, WorkerDaemonSetName); err != nil {
+				return err
+			}
+			// deletion isn't done until the old workers are gone
+			for i := 0; ; i += sleep {
+				if _, err := kubernetes.GetDaemonSet(WorkerDaemonSetNamespace, WorkerDaemonSetName); err != nil {
+					break
+				}
+				if i >= timeoutSeconds {
+					return errors.Errorf("out-of-date workers %s/%s not deleted", WorkerDaemonSetNamespace, WorkerDaemonSetName)
+				}
+				time.Sleep(time.Duration(sleep) * time.Second)
+			}
+			if _, err := kubernetes.CreateDaemonSet(expected); err != nil {
+				return err
+			}
+		}
+	} else if _, err := kubernetes.CreateDaemonSet(expected); err != nil {
+		return err
+	}
+
+	for i := 0; i < timeoutSeconds; i += sleep {
+		ds, err := kubernetes.GetDaemonSet(WorkerDaemonSetNamespace, WorkerDaemonSetName)
+		if err != nil {
+			return err
+		}
+		if ds.Status.DesiredNumberScheduled > 0 && ds.Status.NumberReady == ds.Status.DesiredNumberScheduled {
+			return nil
+		}
+		logrus.Infof("waiting for workers %s/%s to be ready: %d of %d", WorkerDaemonSetNamespace, WorkerDaemonSetName, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled)
+		time.Sleep(time.Duration(sleep) * time.Second)
+	}
+	return errors.Errorf("workers %s/%s not ready", WorkerDaemonSetNamespace, WorkerDaemonSetName)
+}
+
+// DeleteWorkerDaemonSet cleans up deployed workers, along with their token and namespace
+func DeleteWorkerDaemonSet(kubernetes kube.IKubernetes) error {
+	// the namespace would take the secret with it, but can take a while to go away
+	if err := kubernetes.DeleteSecret(WorkerDaemonSetNamespace, WorkerTokenSecretName); err != nil {
+		return err
+	}
+	return kubernetes.DeleteNamespace(WorkerDaemonSetNamespace)
+}
+
+// NewWorkerDaemonSetJobRunner batches up jobs like a KubeBatchJobRunner, but sends each batch to the worker on its
+// pod's node, rather than exec'ing it in the pod
+func NewWorkerDaemonSetJobRunner(kubernetes kube.IKubernetes, port int, token string, workers int) *KubeBatchJobRunner {
+	return &KubeBatchJobRunner{
+		Client:  &worker.DaemonSetClient{Kubernetes: kubernetes, Namespace: WorkerDaemonSetNamespace, Port: port, Token: token},
+		Workers: workers,
+	}
+}
@@ -0,0 +1,130 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/worker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync"
+)
+
+func RunWorkerDaemonSetTests() {
+	Describe("Worker daemonset", func() {
+		It("Should deploy privileged workers in the host's network and pid namespace, and wait for them", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			Expect(DeployWorkerDaemonSet(kubernetes, 8090, "token-1", 0)).NotTo(Succeed())
+			ds, err := kubernetes.GetDaemonSet(WorkerDaemonSetNamespace, WorkerDaemonSetName)
+			Expect(err).To(Succeed())
+			spec := ds.Spec.Template.Spec
+			Expect(spec.HostNetwork).To(BeTrue())
+			Expect(spec.HostPID).To(BeTrue())
+			Expect(*spec.Containers[0].SecurityContext.Privileged).To(BeTrue())
+			Expect(spec.Containers[0].Command).To(Equal([]string{"/worker", "--port", "8090", "--bind-address", "$(POD_IP)"}))
+			Expect(spec.Containers[0].Env).To(ContainElement(v1.EnvVar{Name: worker.TokenEnvVar, ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: WorkerTokenSecretName},
+				Key:                  "token",
+			}}}))
+			for _, env := range spec.Containers[0].Env {
+				Expect(env.Value).NotTo(ContainSubstring("token-1"))
+			}
+			secret, err := kubernetes.GetSecret(WorkerDaemonSetNamespace, WorkerTokenSecretName)
+			Expect(err).To(Succeed())
+			Expect(string(secret.Data["token"])).To(Equal("token-1"))
+
+			ds.Status.DesiredNumberScheduled = 2
+			ds.Status.NumberReady = 2
+			Expect(DeployWorkerDaemonSet(kubernetes, 8090, "token-1", 5)).To(Succeed())
+			reused, err := kubernetes.GetDaemonSet(WorkerDaemonSetNamespace, WorkerDaemonSetName)
+			Expect(err).To(Succeed())
+			Expect(reused).To(BeIdenticalTo(ds))
+			Expect(DeleteWorkerDaemonSet(kubernetes)).To(Succeed())
+			_, err = kubernetes.GetSecret(WorkerDaemonSetNamespace, WorkerTokenSecretName)
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("Should replace workers deployed with a different port, image or token", func() {
+			expected := workerKubeDaemonSet(8090)
+			Expect(isWorkerDaemonSetUpToDate(workerKubeDaemonSet(8090), expected)).To(BeTrue())
+			Expect(isWorkerDaemonSetUpToDate(workerKubeDaemonSet(8091), expected)).To(BeFalse())
+			otherImage := workerKubeDaemonSet(8090)
+			otherImage.Spec.Template.Spec.Containers[0].Image = "cyclonus-worker:old"
+			Expect(isWorkerDaemonSetUpToDate(otherImage, expected)).To(BeFalse())
+
+			kubernetes := kube.NewMockKubernetes(1.0)
+			Expect(DeployWorkerDaemonSet(kubernetes, 8091, "token-1", 0)).NotTo(Succeed())
+			Expect(DeployWorkerDaemonSet(kubernetes, 8090, "token-1", 0)).NotTo(Succeed())
+			ds, err := kubernetes.GetDaemonSet(WorkerDaemonSetNamespace, WorkerDaemonSetName)
+			Expect(err).To(Succeed())
+			Expect(isWorkerDaemonSetUpToDate(ds, expected)).To(BeTrue())
+
+			// same daemonset, new token: the running workers would keep the old one
+			Expect(DeployWorkerDaemonSet(kubernetes, 8090, "token-2", 0)).NotTo(Succeed())
+			replaced, err := kubernetes.GetDaemonSet(WorkerDaemonSetNamespace, WorkerDaemonSetName)
+			Expect(err).To(Succeed())
+			Expect(replaced).NotTo(BeIdenticalTo(ds))
+			secret, err := kubernetes.GetSecret(WorkerDaemonSetNamespace, WorkerTokenSecretName)
+			Expect(err).To(Succeed())
+			Expect(string(secret.Data["token"])).To(Equal("token-2"))
+		})
+
+		It("Should send each batch to the worker on its pod's node", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			for _, ns := range []string{"x", WorkerDaemonSetNamespace} {
+				_, err := kubernetes.CreateNamespace(KubeNamespace(ns, map[string]string{"ns": ns}))
+				Expect(err).To(Succeed())
+			}
+			for _, pod := range []*v1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "a", UID: "uid-a"}, Spec: v1.PodSpec{NodeName: "node-1"}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "b", UID: "uid-b"}, Spec: v1.PodSpec{NodeName: "node-2"}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: WorkerDaemonSetNamespace, Name: "worker-1"}, Spec: v1.PodSpec{NodeName: "node-1"}},
+			} {
+				_, err := kubernetes.CreatePod(pod)
+				Expect(err).To(Succeed())
+			}
+			var issued []*worker.Batch
+			var lock sync.Mutex
+			kubernetes.ProxyHandler = &worker.Server{Concurrency: 1, Token: "token-1", Issue: func(batch *worker.Batch, concurrency int) ([]*worker.Result, error) {
+				lock.Lock()
+				issued = append(issued, batch)
+				lock.Unlock()
+				var results []*worker.Result
+				for _, r := range batch.Requests {
+					result := &worker.Result{Request: r}
+					if r.Port != 80 {
+						result.Error = "connection refused"
+					}
+					results = append(results, result)
+				}
+				return results, nil
+			}}
+
+			job := func(from string, port int) *Job {
+				return &Job{FromKey: "x/" + from, FromNamespace: "x", FromPod: from, FromContainer: "cont-80-tcp", ToKey: "x/c", ToHost: "192.168.1.9", ResolvedPort: port, Protocol: v1.ProtocolTCP}
+			}
+			results := NewWorkerDaemonSetJobRunner(kubernetes, 8090, "token-1", 2).RunJobs([]*Job{job("a", 80), job("a", 81), job("b", 80)})
+			Expect(results).To(HaveLen(3))
+			combined := map[string]Connectivity{}
+			for _, result := range results {
+				combined[result.Job.Key()] = result.Combined
+			}
+			Expect(combined).To(Equal(map[string]Connectivity{
+				job("a", 80).Key(): ConnectivityAllowed,
+				job("a", 81).Key(): ConnectivityBlocked,
+				// there's no worker on x/b's node
+				job("b", 80).Key(): ConnectivityCheckFailed,
+			}))
+			Expect(issued).To(HaveLen(1))
+			Expect(issued[0].PodUID).To(Equal("uid-a"))
+
+			Expect(NewWorkerDaemonSetJobRunner(kubernetes, 8090, "token-2", 1).RunJobs([]*Job{job("a", 80)})[0].Combined).To(Equal(ConnectivityCheckFailed))
+			Expect(issued).To(HaveLen(1))
+
+			_, err := kubernetes.ProxyPodRequest(WorkerDaemonSetNamespace, "worker-1", 8090, worker.BatchPath, []byte(`{"Namespace": "x", "Pod": "a", "Token": "token-1"}`))
+			Expect(err).NotTo(Succeed())
+			_, err = kubernetes.ProxyPodRequest(WorkerDaemonSetNamespace, "worker-1", 8090, "/other", []byte(`{}`))
+			Expect(err).NotTo(Succeed())
+		})
+	})
+}
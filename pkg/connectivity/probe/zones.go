@@ -0,0 +1,60 @@
+package probe
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+)
+
+const ZoneLabel = "topology.kubernetes.io/zone"
+
+// ZonePair classifies a source/destination pair by whether the pods' nodes are in the same zone
+type ZonePair string
+
+const (
+	ZonePairSame    ZonePair = "same-zone"
+	ZonePairCross   ZonePair = "cross-zone"
+	ZonePairUnknown ZonePair = "unknown-zone"
+)
+
+var AllZonePairs = []ZonePair{ZonePairSame, ZonePairCross, ZonePairUnknown}
+
+// nodeZones looks up the zones of nodes, only asking kube about each node once
+type nodeZones struct {
+	kubernetes kube.IKubernetes
+	zones      map[string]string
+}
+
+func (n *nodeZones) zone(nodeName string) (string, error) {
+	if nodeName == "" {
+		return "", nil
+	}
+	if zone, ok := n.zones[nodeName]; ok {
+		return zone, nil
+	}
+	node, err := n.kubernetes.GetNode(nodeName)
+	if err != nil {
+		return "", err
+	}
+	n.zones[nodeName] = node.Labels[ZoneLabel]
+	return n.zones[nodeName], nil
+}
+
+// Zones maps pods -- by their PodString -- to their nodes' zones; pods with unknown zones are left out
+func (r *Resources) Zones() map[string]string {
+	zones := map[string]string{}
+	for _, pod := range r.Pods {
+		if pod.Zone != "" {
+			zones[pod.PodString().String()] = pod.Zone
+		}
+	}
+	return zones
+}
+
+func ClassifyZonePair(zones map[string]string, from string, to string) ZonePair {
+	fromZone, toZone := zones[from], zones[to]
+	if fromZone == "" || toZone == "" {
+		return ZonePairUnknown
+	} else if fromZone == toZone {
+		return ZonePairSame
+	}
+	return ZonePairCross
+}
@@ -0,0 +1,27 @@
+package probe
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunZonesTests() {
+	Describe("Zones", func() {
+		It("Should classify pod pairs by zone", func() {
+			r := &Resources{
+				Pods: []*Pod{
+					{Namespace: "x", Name: "a", Zone: "zone-1"},
+					{Namespace: "x", Name: "b", Zone: "zone-1"},
+					{Namespace: "x", Name: "c", Zone: "zone-2"},
+					{Namespace: "x", Name: "d"},
+				},
+			}
+			zones := r.Zones()
+			Expect(zones).To(Equal(map[string]string{"x/a": "zone-1", "x/b": "zone-1", "x/c": "zone-2"}))
+			Expect(ClassifyZonePair(zones, "x/a", "x/b")).To(Equal(ZonePairSame))
+			Expect(ClassifyZonePair(zones, "x/a", "x/c")).To(Equal(ZonePairCross))
+			Expect(ClassifyZonePair(zones, "x/a", "x/d")).To(Equal(ZonePairUnknown))
+		})
+	})
+
+}
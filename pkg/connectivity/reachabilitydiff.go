@@ -0,0 +1,186 @@
+package connectivity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReachabilityKey is a single probe of a reachability matrix.  Scope is the test case and step of matrices read from
+// a results document, and empty for simulated ones.
+type ReachabilityKey struct {
+	Scope string `json:",omitempty"`
+	From  string
+	To    string
+	Port  string
+}
+
+// ReachabilityMatrix is the combined connectivity of each probe
+type ReachabilityMatrix map[ReachabilityKey]probe.Connectivity
+
+func NewReachabilityMatrix(reachability []*probe.Reachability) ReachabilityMatrix {
+	matrix := ReachabilityMatrix{}
+	for _, entry := range reachability {
+		matrix[ReachabilityKey{From: entry.From, To: entry.To, Port: entry.Port}] = entry.Combined
+	}
+	return matrix
+}
+
+// NewReachabilityMatrixFromResults is the actual connectivity of every probe of the last try of each step of a run,
+// scoped by test case number and step
+func NewReachabilityMatrixFromResults(doc *ResultsDocument) ReachabilityMatrix {
+	matrix := ReachabilityMatrix{}
+	for _, test := range doc.Tests {
+		for i, step := range test.Steps {
+			scope := fmt.Sprintf("test case %d step %d", test.Number, i+1)
+			for _, record := range step.Probes {
+				matrix[ReachabilityKey{Scope: scope, From: record.From, To: record.To, Port: record.Job}] = record.Actual
+			}
+		}
+	}
+	return matrix
+}
+
+// ReadReachabilityMatrix reads a matrix from the csv or json output of 'reachability', or from a results document
+func ReadReachabilityMatrix(path string) (ReachabilityMatrix, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read reachability matrix %s", path)
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		reachability, err := probe.ParseReachabilityCSV(bytes.NewReader(bs))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unable to parse reachability matrix %s", path)
+		}
+		return NewReachabilityMatrix(reachability), nil
+	}
+	// 'reachability' writes a json list; results documents are objects
+	if bytes.HasPrefix(bytes.TrimSpace(bs), []byte("[")) {
+		var reachability []*probe.Reachability
+		if err := json.Unmarshal(bs, &reachability); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmarshal reachability matrix %s", path)
+		}
+		return NewReachabilityMatrix(reachability), nil
+	}
+	doc, err := ParseResultsDocument(bs, path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReachabilityMatrixFromResults(doc), nil
+}
+
+// ReachabilityChangeKind is how a probe's connectivity changed from one matrix to another
+type ReachabilityChangeKind string
+
+const (
+	// ReachabilityAdded is connectivity which is allowed after, but wasn't before
+	ReachabilityAdded ReachabilityChangeKind = "added"
+	// ReachabilityRemoved is connectivity which was allowed before, but isn't after
+	ReachabilityRemoved ReachabilityChangeKind = "removed"
+	// ReachabilityChanged is any other change, such as from blocked to a failed check
+	ReachabilityChanged ReachabilityChangeKind = "changed"
+)
+
+type ReachabilityChange struct {
+	ReachabilityKey
+	Kind   ReachabilityChangeKind
+	Before probe.Connectivity
+	After  probe.Connectivity
+}
+
+// ReachabilityDiff is how connectivity changed between two matrices, over the probes in both.  Probes in only one of
+// them -- pods which were added or removed -- are only counted.
+type ReachabilityDiff struct {
+	Compared   int
+	OnlyBefore int
+	OnlyAfter  int
+	Changes    []*ReachabilityChange
+}
+
+func NewReachabilityDiff(before ReachabilityMatrix, after ReachabilityMatrix) *ReachabilityDiff {
+	diff := &ReachabilityDiff{}
+	for key, beforeConnectivity := range before {
+		afterConnectivity, ok := after[key]
+		if !ok {
+			diff.OnlyBefore++
+			continue
+		}
+		diff.Compared++
+		if beforeConnectivity == afterConnectivity {
+			continue
+		}
+		change := &ReachabilityChange{ReachabilityKey: key, Kind: ReachabilityChanged, Before: beforeConnectivity, After: afterConnectivity}
+		if afterConnectivity == probe.ConnectivityAllowed {
+			change.Kind = ReachabilityAdded
+		} else if beforeConnectivity == probe.ConnectivityAllowed {
+			change.Kind = ReachabilityRemoved
+		}
+		diff.Changes = append(diff.Changes, change)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			diff.OnlyAfter++
+		}
+	}
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		a, b := diff.Changes[i].ReachabilityKey, diff.Changes[j].ReachabilityKey
+		if a.Scope != b.Scope {
+			return a.Scope < b.Scope
+		} else if a.From != b.From {
+			return a.From < b.From
+		} else if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Port < b.Port
+	})
+	return diff
+}
+
+// CountChanges counts changes by kind
+func (d *ReachabilityDiff) CountChanges() map[ReachabilityChangeKind]int {
+	counts := map[ReachabilityChangeKind]int{}
+	for _, change := range d.Changes {
+		counts[change.Kind]++
+	}
+	return counts
+}
+
+// Table lists the changes -- with their test case and step, for matrices from results documents -- followed by a
+// summary
+func (d *ReachabilityDiff) Table() string {
+	str := &strings.Builder{}
+	if len(d.Changes) > 0 {
+		isScoped := false
+		for _, change := range d.Changes {
+			isScoped = isScoped || change.Scope != ""
+		}
+		header := []string{"From", "To", "Port", "Change", "Before", "After"}
+		if isScoped {
+			header = append([]string{"Test case"}, header...)
+		}
+		table := tablewriter.NewWriter(str)
+		table.SetHeader(header)
+		table.SetAutoWrapText(false)
+		for _, change := range d.Changes {
+			row := []string{change.From, change.To, change.Port, string(change.Kind), string(change.Before), string(change.After)}
+			if isScoped {
+				row = append([]string{change.Scope}, row...)
+			}
+			table.Append(row)
+		}
+		table.Render()
+	}
+	counts := d.CountChanges()
+	str.WriteString(fmt.Sprintf("%d of %d probes changed: %d added, %d removed, %d otherwise changed\n", len(d.Changes), d.Compared, counts[ReachabilityAdded], counts[ReachabilityRemoved], counts[ReachabilityChanged]))
+	if d.OnlyBefore > 0 || d.OnlyAfter > 0 {
+		str.WriteString(fmt.Sprintf("not compared: %d probes only before, %d probes only after\n", d.OnlyBefore, d.OnlyAfter))
+	}
+	return str.String()
+}
@@ -0,0 +1,82 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func RunReachabilityDiffTests() {
+	Describe("ReachabilityDiff", func() {
+		key := func(from string, to string) ReachabilityKey {
+			return ReachabilityKey{From: from, To: to, Port: "TCP/80"}
+		}
+
+		It("should classify changes over the probes in both matrices", func() {
+			before := ReachabilityMatrix{
+				key("x/a", "x/a"): probe.ConnectivityAllowed,
+				key("x/a", "x/b"): probe.ConnectivityBlocked,
+				key("x/b", "x/a"): probe.ConnectivityAllowed,
+				key("x/b", "x/b"): probe.ConnectivityBlocked,
+				key("x/c", "x/a"): probe.ConnectivityAllowed,
+			}
+			after := ReachabilityMatrix{
+				key("x/a", "x/a"): probe.ConnectivityAllowed,
+				key("x/a", "x/b"): probe.ConnectivityAllowed,
+				key("x/b", "x/a"): probe.ConnectivityBlocked,
+				key("x/b", "x/b"): probe.ConnectivityCheckFailed,
+				key("x/d", "x/a"): probe.ConnectivityAllowed,
+				key("x/d", "x/b"): probe.ConnectivityAllowed,
+			}
+			diff := NewReachabilityDiff(before, after)
+
+			Expect(diff.Compared).To(Equal(4))
+			Expect(diff.OnlyBefore).To(Equal(1))
+			Expect(diff.OnlyAfter).To(Equal(2))
+			Expect(diff.Changes).To(Equal([]*ReachabilityChange{
+				{ReachabilityKey: key("x/a", "x/b"), Kind: ReachabilityAdded, Before: probe.ConnectivityBlocked, After: probe.ConnectivityAllowed},
+				{ReachabilityKey: key("x/b", "x/a"), Kind: ReachabilityRemoved, Before: probe.ConnectivityAllowed, After: probe.ConnectivityBlocked},
+				{ReachabilityKey: key("x/b", "x/b"), Kind: ReachabilityChanged, Before: probe.ConnectivityBlocked, After: probe.ConnectivityCheckFailed},
+			}))
+			table := diff.Table()
+			Expect(table).To(ContainSubstring("3 of 4 probes changed: 1 added, 1 removed, 1 otherwise changed"))
+			Expect(table).To(ContainSubstring("not compared: 1 probes only before, 2 probes only after"))
+			Expect(table).ToNot(ContainSubstring("TEST CASE"))
+		})
+
+		It("should read matrices from reachability csv and json, and from results documents", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-reachability-diff")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+
+			csvPath := filepath.Join(dir, "before.csv")
+			Expect(ioutil.WriteFile(csvPath, []byte("from,to,port,ingress,egress,combined\nx/a,x/b,TCP/80,blocked,allowed,blocked\n"), 0644)).To(Succeed())
+			fromCSV, err := ReadReachabilityMatrix(csvPath)
+			Expect(err).To(Succeed())
+			Expect(fromCSV).To(Equal(ReachabilityMatrix{key("x/a", "x/b"): probe.ConnectivityBlocked}))
+
+			jsonPath := filepath.Join(dir, "after.json")
+			Expect(ioutil.WriteFile(jsonPath, []byte(`[{"From": "x/a", "To": "x/b", "Port": "TCP/80", "Combined": "allowed"}]`), 0644)).To(Succeed())
+			fromJSON, err := ReadReachabilityMatrix(jsonPath)
+			Expect(err).To(Succeed())
+			Expect(fromJSON).To(Equal(ReachabilityMatrix{key("x/a", "x/b"): probe.ConnectivityAllowed}))
+
+			doc := &ResultsDocument{SchemaVersion: ResultsDocumentSchemaVersion, Tests: []*TestCaseRecord{{
+				Number: 3,
+				Steps: []*StepRecord{{Probes: []*ProbeRecord{
+					{From: "x/a", To: "x/b", Job: "TCP/80", Expected: probe.ConnectivityAllowed, Actual: probe.ConnectivityBlocked},
+				}}},
+			}}}
+			resultsPath := filepath.Join(dir, ResultsDocumentFileName)
+			Expect(doc.WriteToFile(resultsPath)).To(Succeed())
+			fromResults, err := ReadReachabilityMatrix(resultsPath)
+			Expect(err).To(Succeed())
+			Expect(fromResults).To(Equal(ReachabilityMatrix{
+				{Scope: "test case 3 step 1", From: "x/a", To: "x/b", Port: "TCP/80"}: probe.ConnectivityBlocked,
+			}))
+		})
+	})
+}
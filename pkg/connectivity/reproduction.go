@@ -0,0 +1,202 @@
+package connectivity
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+const (
+	ReproductionDirName    = "reproduction"
+	ReproductionScriptName = "reproduce.sh"
+	// reproductionPolicyWaitSeconds is how long the script waits for policies to take effect, unless overridden by
+	// POLICY_WAIT_SECONDS
+	reproductionPolicyWaitSeconds = 5
+)
+
+// writeReproduction writes a reproduction of the first step of result whose probes didn't all get the expected
+// result, which needs nothing but kubectl -- so that it can be handed to a CNI's maintainers:
+//   - resources.yaml: the namespaces, pods and services the step was probed with
+//   - policies.yaml: the network policies in effect at the step
+//   - reproduce.sh: applies both, waits for the pods to be ready and the policies to take effect, and re-runs the
+//     mismatched probes, printing what each was expected to get and got
+//
+// It returns the directory the reproduction was written to, or "" if no step's probes were mismatched -- i.e. the
+// test case hit an error, or only its supplementary probes failed.
+func writeReproduction(dir string, result *Result, ignoreLoopback bool) (string, error) {
+	for i, step := range result.Steps {
+		if step.Resources == nil {
+			continue
+		}
+		if mismatched := step.LastComparison().MismatchedJobResults(ignoreLoopback); len(mismatched) > 0 {
+			reproductionDir := filepath.Join(dir, ReproductionDirName)
+			if err := os.MkdirAll(reproductionDir, 0755); err != nil {
+				return "", errors.Wrapf(err, "unable to create reproduction directory %s", reproductionDir)
+			}
+			if err := writeYamlDocuments(filepath.Join(reproductionDir, "resources.yaml"), reproductionResources(step.Resources)); err != nil {
+				return "", err
+			}
+			if err := writeYamlDocuments(filepath.Join(reproductionDir, "policies.yaml"), reproductionPolicies(step.KubePolicies)); err != nil {
+				return "", err
+			}
+			path := filepath.Join(reproductionDir, ReproductionScriptName)
+			script := reproductionScript(result.TestCase.Description, i+1, step, mismatched)
+			if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+				return "", errors.Wrapf(err, "unable to write %s", path)
+			}
+			return reproductionDir, nil
+		}
+	}
+	return "", nil
+}
+
+func writeYamlDocuments(path string, objs []interface{}) error {
+	var documents []string
+	for _, obj := range objs {
+		bytes, err := yaml.Marshal(obj)
+		if err != nil {
+			return errors.Wrapf(err, "unable to marshal %s", path)
+		}
+		documents = append(documents, string(bytes))
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, []byte(strings.Join(documents, "---\n")), 0644), "unable to write %s", path)
+}
+
+// reproductionResources are the namespaces, then the pods and services, with the types kubectl needs to apply them
+func reproductionResources(resources *probe.Resources) []interface{} {
+	var objs []interface{}
+	for _, ns := range sortedNamespaces(resources) {
+		namespace := probe.KubeNamespace(ns, resources.Namespaces[ns])
+		namespace.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"}
+		objs = append(objs, namespace)
+	}
+	for _, pod := range resources.Pods {
+		kubePod := pod.KubePod()
+		kubePod.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}
+		kubeService := pod.KubeService()
+		kubeService.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Service"}
+		objs = append(objs, kubePod, kubeService)
+	}
+	return objs
+}
+
+// reproductionPolicies are copies of policies without anything the API server filled in
+func reproductionPolicies(policies []*networkingv1.NetworkPolicy) []interface{} {
+	var objs []interface{}
+	for _, policy := range policies {
+		policy := policy.DeepCopy()
+		policy.TypeMeta = metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"}
+		policy.ObjectMeta = metav1.ObjectMeta{Name: policy.Name, Namespace: policy.Namespace, Labels: policy.Labels, Annotations: policy.Annotations}
+		objs = append(objs, policy)
+	}
+	return objs
+}
+
+func sortedNamespaces(resources *probe.Resources) []string {
+	namespaces := resources.NamespacesSlice()
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+var shellSafe = regexp.MustCompile(`^[a-zA-Z0-9./:=_,@%+-]+$`)
+
+// shellQuote single-quotes a word, unless it's made only of characters which don't need it
+func shellQuote(word string) string {
+	if shellSafe.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+func reproductionScript(description string, stepNumber int, step *StepResult, mismatched []*probe.JobResult) string {
+	str := &strings.Builder{}
+	namespaces := sortedNamespaces(step.Resources)
+	str.WriteString("#!/usr/bin/env bash\n\n")
+	str.WriteString(fmt.Sprintf("# Reproduces step %d of cyclonus test case:\n#   %s\n", stepNumber, description))
+	str.WriteString("# by creating the namespaces, pods and services of resources.yaml, and the network policies of policies.yaml,\n")
+	str.WriteString("# then re-running the probes which didn't get the expected result.  Clean up with:\n")
+	str.WriteString(fmt.Sprintf("#   kubectl delete namespace %s\n\n", strings.Join(namespaces, " ")))
+	str.WriteString("set -o errexit -o nounset -o pipefail\n")
+	str.WriteString("cd \"$(dirname \"$0\")\"\n\n")
+	str.WriteString("kubectl apply -f resources.yaml\n")
+	for _, ns := range namespaces {
+		str.WriteString(fmt.Sprintf("kubectl wait --for=condition=Ready pod --all -n %s --timeout=300s\n", shellQuote(ns)))
+	}
+	if len(step.KubePolicies) > 0 {
+		str.WriteString("kubectl apply -f policies.yaml\n")
+	}
+	str.WriteString(fmt.Sprintf("sleep \"${POLICY_WAIT_SECONDS:-%d}\"\n\n", reproductionPolicyWaitSeconds))
+	str.WriteString(`mismatches=0
+probe() {
+  local description="$1" expected="$2"
+  shift 2
+  local actual=blocked
+  if "$@"; then
+    actual=allowed
+  fi
+  echo "${description}: expected ${expected}, got ${actual}"
+  if [[ "${actual}" != "${expected}" ]]; then
+    mismatches=$((mismatches + 1))
+  fi
+}
+
+`)
+	expected := step.ExpectedProbe()
+	for _, jobResult := range mismatched {
+		job := jobResult.Job
+		expectedConnectivity := probe.ConnectivityUnknown
+		if expectedResult, ok := expected.Get(job.FromKey, job.ToKey).JobResults[jobResult.Key()]; ok {
+			expectedConnectivity = expectedResult.Combined
+		}
+		words := []string{shellQuote(fmt.Sprintf("%s -> %s %s", job.FromKey, job.ToKey, jobResult.Key())), shellQuote(string(expectedConnectivity))}
+		address := reproductionAddress(job, step.Resources)
+		for _, word := range job.KubeExecCommand() {
+			if word == job.ToAddress() && address != "" {
+				words = append(words, address)
+			} else {
+				words = append(words, shellQuote(word))
+			}
+		}
+		str.WriteString(fmt.Sprintf("probe %s\n", strings.Join(words, " ")))
+	}
+	str.WriteString(fmt.Sprintf("\necho \"${mismatches} of %d probes didn't get the expected result\"\n", len(mismatched)))
+	str.WriteString("[[ \"${mismatches}\" == 0 ]]\n")
+	return str.String()
+}
+
+// reproductionAddress looks up the destination's IP when the script runs, if it was probed by pod or service IP,
+// since those won't be the same in the reproduction; it's "" for destinations which are used as is
+func reproductionAddress(job *probe.Job, resources *probe.Resources) string {
+	to := strings.SplitN(job.ToKey, "/", 2)
+	if len(to) != 2 {
+		return ""
+	}
+	pod, err := resources.GetPod(to[0], to[1])
+	if err != nil {
+		return ""
+	}
+	var lookup string
+	switch job.ToHost {
+	case "":
+		return ""
+	case pod.IP:
+		lookup = fmt.Sprintf("kubectl get pod %s -n %s -o jsonpath='{.status.podIP}'", shellQuote(pod.Name), shellQuote(pod.Namespace))
+	case pod.ServiceIP:
+		lookup = fmt.Sprintf("kubectl get service %s -n %s -o jsonpath='{.spec.clusterIP}'", shellQuote(pod.ServiceName()), shellQuote(pod.Namespace))
+	default:
+		return ""
+	}
+	if strings.Contains(job.ToHost, ":") {
+		return fmt.Sprintf("\"[$(%s)]:%d\"", lookup, job.ResolvedPort)
+	}
+	return fmt.Sprintf("\"$(%s):%d\"", lookup, job.ResolvedPort)
+}
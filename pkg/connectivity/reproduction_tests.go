@@ -0,0 +1,73 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+)
+
+func RunReproductionTests() {
+	Describe("Reproduction", func() {
+		var dir string
+		var interpreter *Interpreter
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "cyclonus-reproduction")
+			Expect(err).To(Succeed())
+
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+			interpreter, err = NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true, IgnoreLoopback: true, FailureArtifacts: NewFailureArtifacts(dir)})
+			Expect(err).To(Succeed())
+		})
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		policy := generator.BuildPolicy(generator.SetNamespace("x")).NetworkPolicy()
+
+		It("should reproduce the mismatched probes of the first failed step", func() {
+			// the mock allows everything, so the first step passes, and only y/a -> x/a fails the second
+			testCase := generator.NewTestCase("deny all ingress", generator.NewStringSet(generator.TagDenyAll),
+				generator.NewTestStep(generator.ProbeAllAvailable),
+				generator.NewTestStep(generator.ProbeAllAvailable, generator.CreatePolicy(policy)))
+			result := interpreter.ExecuteTestCase(testCase)
+			Expect(result.FailureArtifacts).ToNot(Equal(""))
+
+			reproductionDir := filepath.Join(result.FailureArtifacts, ReproductionDirName)
+			resources, err := ioutil.ReadFile(filepath.Join(reproductionDir, "resources.yaml"))
+			Expect(err).To(Succeed())
+			Expect(string(resources)).To(ContainSubstring("kind: Namespace"))
+			Expect(string(resources)).To(ContainSubstring("kind: Pod"))
+			Expect(string(resources)).To(ContainSubstring("kind: Service"))
+			policies, err := ioutil.ReadFile(filepath.Join(reproductionDir, "policies.yaml"))
+			Expect(err).To(Succeed())
+			Expect(string(policies)).To(ContainSubstring("kind: NetworkPolicy"))
+
+			script, err := ioutil.ReadFile(filepath.Join(reproductionDir, ReproductionScriptName))
+			Expect(err).To(Succeed())
+			Expect(string(script)).To(ContainSubstring("# Reproduces step 2 of cyclonus test case:\n#   deny all ingress\n"))
+			Expect(string(script)).To(ContainSubstring("kubectl apply -f policies.yaml\n"))
+			Expect(string(script)).To(ContainSubstring("\nprobe 'y/a -> x/a TCP/80' blocked kubectl exec a -c cont-80-tcp -n y -- /agnhost connect s-x-a.x.svc.cluster.local:80 --timeout=1s --protocol=tcp\n"))
+			// loopback is ignored
+			Expect(string(script)).ToNot(ContainSubstring("'x/a -> x/a TCP/80'"))
+		})
+
+		It("should look up pod IPs when the reproduction runs", func() {
+			probeConfig := &generator.ProbeConfig{AllAvailable: true, Mode: generator.ProbeModePodIP}
+			testCase := generator.NewSingleStepTestCase("deny all ingress by pod ip", generator.NewStringSet(generator.TagDenyAll), probeConfig, generator.CreatePolicy(policy))
+			result := interpreter.ExecuteTestCase(testCase)
+
+			script, err := ioutil.ReadFile(filepath.Join(result.FailureArtifacts, ReproductionDirName, ReproductionScriptName))
+			Expect(err).To(Succeed())
+			Expect(string(script)).To(ContainSubstring(`-- /agnhost connect "$(kubectl get pod a -n x -o jsonpath='{.status.podIP}'):80" --timeout=1s`))
+		})
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
 	"github.com/mattfenwick/cyclonus/pkg/generator"
 	v1 "k8s.io/api/core/v1"
+	"sort"
+	"time"
 )
 
 type Result struct {
@@ -13,6 +15,44 @@ type Result struct {
 	TestCase         *generator.TestCase
 	Steps            []*StepResult
 	Err              error
+	// Interrupted is true if the interpreter was stopped before all steps were run
+	Interrupted bool
+	Timing      TestCaseTiming
+	// FailureArtifacts is the directory the failed test's artifacts were collected in, if any -- see FailureArtifacts
+	FailureArtifacts string
+	// MinimizedPolicies are the smallest policies which still reproduced the failed test's mismatches, if it was
+	// minimized -- see Minimizer
+	MinimizedPolicies *MinimizedPolicies
+}
+
+// TestCaseTiming is how long a test case took in total, and in the phases before its first step
+type TestCaseTiming struct {
+	Total time.Duration
+	// Setup is resetting the cluster state
+	Setup time.Duration
+	// Verification is checking the cluster state
+	Verification time.Duration
+	// WarmUp is probing every pair once before the first step
+	WarmUp time.Duration
+}
+
+// StepTiming is how long each phase of a step took
+type StepTiming struct {
+	Actions          time.Duration
+	PerturbationWait time.Duration
+	// Probing includes retries and cross-mode probes
+	Probing time.Duration
+}
+
+// StepTimings adds up the timings of all the test case's steps
+func (r *Result) StepTimings() StepTiming {
+	total := StepTiming{}
+	for _, step := range r.Steps {
+		total.Actions += step.Timing.Actions
+		total.PerturbationWait += step.Timing.PerturbationWait
+		total.Probing += step.Timing.Probing
+	}
+	return total
 }
 
 func (r *Result) ResultsByProtocol() map[bool]map[v1.Protocol]int {
@@ -27,6 +67,71 @@ func (r *Result) ResultsByProtocol() map[bool]map[v1.Protocol]int {
 	return counts
 }
 
+func (r *Result) Passed(ignoreLoopback bool) bool {
+	if r.Err != nil {
+		return false
+	}
+	for _, step := range r.Steps {
+		if step.LastComparison().ValueCounts(ignoreLoopback)[DifferentComparison] > 0 {
+			return false
+		}
+		if step.FamilyDifferences(ignoreLoopback) > 0 {
+			return false
+		}
+		if step.EgressPathDifferences() > 0 {
+			return false
+		}
+		if step.ConnectionCounts()[DifferentComparison] > 0 {
+			return false
+		}
+		if step.DNSCounts()[DifferentComparison] > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Waived is true for a failed test whose failures are waived, because it's known to fail -- see
+// generator.CNIProfile
+func (r *Result) Waived(ignoreLoopback bool) bool {
+	return r.TestCase.Waiver != "" && !r.Passed(ignoreLoopback)
+}
+
+// FailureClass is none for a passed test.  Otherwise, a test which hit an error is classified by that error; and
+// a test which ran to completion is an infrastructure failure if any probe failed to execute (as opposed to being
+// blocked), and a verification failure otherwise.
+func (r *Result) FailureClass(ignoreLoopback bool) FailureClass {
+	if r.Err != nil {
+		return ClassOfError(r.Err)
+	}
+	if r.Passed(ignoreLoopback) {
+		return FailureClassNone
+	}
+	for _, step := range r.Steps {
+		if step.LastKubeProbe().CountConnectivity(probe.ConnectivityCheckFailed) > 0 {
+			return FailureClassInfrastructure
+		}
+		for _, family := range step.Families() {
+			if step.FamilyProbes[family].LastKubeProbe().CountConnectivity(probe.ConnectivityCheckFailed) > 0 {
+				return FailureClassInfrastructure
+			}
+		}
+		if step.EgressPathRequired {
+			for _, result := range step.EgressPathResults {
+				if result.JobResult.Combined == probe.ConnectivityCheckFailed {
+					return FailureClassInfrastructure
+				}
+			}
+		}
+		for _, result := range step.DNSResults {
+			if result.JobResult.Combined == probe.ConnectivityCheckFailed {
+				return FailureClassInfrastructure
+			}
+		}
+	}
+	return FailureClassVerification
+}
+
 func (r *Result) Features() map[string][]string {
 	return r.TestCase.GetFeatures()
 }
@@ -35,6 +140,18 @@ type CombinedResults struct {
 	Results []*Result
 }
 
+// SlowestResults returns the n results which took the longest, slowest first
+func (c *CombinedResults) SlowestResults(n int) []*Result {
+	results := append([]*Result{}, c.Results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Timing.Total > results[j].Timing.Total
+	})
+	if n < len(results) {
+		results = results[:n]
+	}
+	return results
+}
+
 type Summary struct {
 	Tests                [][]string
 	Passed               int
@@ -44,6 +161,35 @@ type Summary struct {
 	TagPrimaryCounts     map[string]map[bool]int
 	FeatureCounts        map[string]map[string]map[bool]int
 	FeaturePrimaryCounts map[string]map[bool]int
+	// Waived counts failed tests whose failures are waived, which aren't counted in Failed, by waiver
+	Waived map[string]int
+	// WaivedDifferences counts results which differ from what the policies allow, but are waived
+	WaivedDifferences int
+	// CrossModeDiscrepancies counts cells where probing by pod IP and by service IP disagreed
+	CrossModeDiscrepancies int
+	// FailureClassCounts counts failed tests by FailureClass
+	FailureClassCounts map[FailureClass]int
+	// NetworkCounts compares results over secondary networks to expected results, by network
+	NetworkCounts map[string]map[Comparison]int
+	// FamilyCounts compares results over each IP family to what the policies allow over that family, by family
+	FamilyCounts map[v1.IPFamily]map[Comparison]int
+	// ZonePairCounts compares the last try of each step to expected results, by whether each pair's pods were
+	// in the same zone
+	ZonePairCounts map[probe.ZonePair]map[Comparison]int
+	// HasZones is true if the zone of at least one pod was known
+	HasZones bool
+	// EgressPathCounts compares egress path probes to expected results
+	EgressPathCounts map[Comparison]int
+	// DNSCounts compares DNS lookups to what the policies allow
+	DNSCounts map[Comparison]int
+	// ConnectionFates counts long-lived connections by whether they survived
+	ConnectionFates map[probe.ConnectionFate]int
+	// ConnectionCounts compares long-lived connections' fates to what was expected
+	ConnectionCounts map[Comparison]int
+	// CorroborationFindings counts disagreements found by dataplane corroboration
+	CorroborationFindings int
+	// Heatmap breaks down the last try of every step by where the jobs went, and by step index
+	Heatmap *FailureHeatmap
 }
 
 func (c *CombinedResults) Summary(ignoreLoopback bool) *Summary {
@@ -51,22 +197,30 @@ func (c *CombinedResults) Summary(ignoreLoopback bool) *Summary {
 		Tests:                nil,
 		Passed:               0,
 		Failed:               0,
+		Waived:               map[string]int{},
 		ProtocolCounts:       map[v1.Protocol]map[Comparison]int{v1.ProtocolTCP: {}, v1.ProtocolSCTP: {}, v1.ProtocolUDP: {}},
 		TagCounts:            map[string]map[string]map[bool]int{},
 		TagPrimaryCounts:     map[string]map[bool]int{},
 		FeatureCounts:        map[string]map[string]map[bool]int{},
 		FeaturePrimaryCounts: map[string]map[bool]int{},
+		FailureClassCounts:   map[FailureClass]int{},
+		NetworkCounts:        map[string]map[Comparison]int{},
+		FamilyCounts:         map[v1.IPFamily]map[Comparison]int{},
+		ZonePairCounts:       map[probe.ZonePair]map[Comparison]int{},
+		EgressPathCounts:     map[Comparison]int{},
+		DNSCounts:            map[Comparison]int{},
+		ConnectionFates:      map[probe.ConnectionFate]int{},
+		ConnectionCounts:     map[Comparison]int{},
+		Heatmap:              NewFailureHeatmap(),
+	}
+	for _, zonePair := range probe.AllZonePairs {
+		summary.ZonePairCounts[zonePair] = map[Comparison]int{}
 	}
 	passedTotal, failedTotal := 0, 0
 
 	for testNumber, result := range c.Results {
 		// preprocess to figure out whether it passed or failed
-		passed := true
-		for _, step := range result.Steps {
-			if step.LastComparison().ValueCounts(ignoreLoopback)[DifferentComparison] > 0 {
-				passed = false
-			}
-		}
+		passed := result.Passed(ignoreLoopback)
 
 		for primary, subs := range result.Features() {
 			if _, ok := summary.FeatureCounts[primary]; !ok {
@@ -89,8 +243,13 @@ func (c *CombinedResults) Summary(ignoreLoopback bool) *Summary {
 		if passed {
 			testResult = "passed"
 			passedTotal++
+		} else if result.Waived(ignoreLoopback) {
+			testResult = fmt.Sprintf("waived (%s)", result.FailureClass(ignoreLoopback))
+			summary.Waived[result.TestCase.Waiver]++
 		} else {
-			testResult = "failed"
+			failureClass := result.FailureClass(ignoreLoopback)
+			testResult = fmt.Sprintf("failed (%s)", failureClass)
+			summary.FailureClassCounts[failureClass]++
 			failedTotal++
 		}
 
@@ -100,7 +259,52 @@ func (c *CombinedResults) Summary(ignoreLoopback bool) *Summary {
 			"", "", "",
 		})
 
+		var zones map[string]string
+		if result.InitialResources != nil {
+			zones = result.InitialResources.Zones()
+		}
+		if len(zones) > 0 {
+			summary.HasZones = true
+		}
+
 		for stepNumber, step := range result.Steps {
+			summary.Heatmap.AddStep(stepNumber, step.LastComparison(), ignoreLoopback)
+			for comparison, count := range step.EgressPathCounts() {
+				summary.EgressPathCounts[comparison] += count
+			}
+			for comparison, count := range step.DNSCounts() {
+				summary.DNSCounts[comparison] += count
+			}
+			for _, connectionResult := range step.ConnectionResults {
+				summary.ConnectionFates[connectionResult.Result.Fate()]++
+				summary.ConnectionCounts[connectionResult.Comparison()]++
+			}
+			summary.CorroborationFindings += len(step.CorroborationFindings)
+			summary.WaivedDifferences += step.WaivedDifferences(ignoreLoopback)
+			for zonePair, counts := range step.LastComparison().ValueCountsByZonePair(ignoreLoopback, zones) {
+				for comparison, count := range counts {
+					summary.ZonePairCounts[zonePair][comparison] += count
+				}
+			}
+			if crossMode := step.CrossModeComparison(); crossMode != nil {
+				summary.CrossModeDiscrepancies += crossMode.ValueCounts(ignoreLoopback)[DifferentComparison]
+			}
+			for _, network := range step.Networks() {
+				if _, ok := summary.NetworkCounts[network]; !ok {
+					summary.NetworkCounts[network] = map[Comparison]int{}
+				}
+				for comparison, count := range step.NetworkComparison(network).ValueCounts(ignoreLoopback) {
+					summary.NetworkCounts[network][comparison] += count
+				}
+			}
+			for _, family := range step.Families() {
+				if _, ok := summary.FamilyCounts[family]; !ok {
+					summary.FamilyCounts[family] = map[Comparison]int{}
+				}
+				for comparison, count := range step.FamilyComparison(family).ValueCounts(ignoreLoopback) {
+					summary.FamilyCounts[family][comparison] += count
+				}
+			}
 			for tryNumber := range step.KubeProbes {
 				counts := step.Comparison(tryNumber).ValueCounts(ignoreLoopback)
 				tryProtocolCounts := step.Comparison(tryNumber).ValueCountsByProtocol(ignoreLoopback)
@@ -0,0 +1,161 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+func RunResultTests() {
+	Describe("Result timing", func() {
+		It("should add up step timings", func() {
+			result := &Result{Steps: []*StepResult{
+				{Timing: StepTiming{Actions: time.Second, PerturbationWait: 5 * time.Second, Probing: 10 * time.Second}},
+				{Timing: StepTiming{Actions: 2 * time.Second, PerturbationWait: 5 * time.Second, Probing: 20 * time.Second}},
+			}}
+			Expect(result.StepTimings()).To(Equal(StepTiming{Actions: 3 * time.Second, PerturbationWait: 10 * time.Second, Probing: 30 * time.Second}))
+		})
+
+		It("should pick the slowest results, slowest first", func() {
+			fast := &Result{Timing: TestCaseTiming{Total: time.Second}}
+			slow := &Result{Timing: TestCaseTiming{Total: time.Minute}}
+			medium := &Result{Timing: TestCaseTiming{Total: 10 * time.Second}}
+			results := &CombinedResults{Results: []*Result{fast, slow, medium}}
+
+			Expect(results.SlowestResults(2)).To(Equal([]*Result{slow, medium}))
+			Expect(results.SlowestResults(5)).To(Equal([]*Result{slow, medium, fast}))
+			Expect(results.Results).To(Equal([]*Result{fast, slow, medium}))
+		})
+	})
+
+	Describe("Zone pair breakdown", func() {
+		It("should count results by whether pods are in the same zone", func() {
+			items := []string{"x/a", "x/b", "x/c"}
+			job := &probe.Job{Protocol: v1.ProtocolTCP, ResolvedPort: 80}
+			jobResults := func(connectivity probe.Connectivity) map[string]*probe.JobResult {
+				jr := &probe.JobResult{Job: job, Combined: connectivity}
+				return map[string]*probe.JobResult{jr.Key(): jr}
+			}
+			table := NewComparisonTable(items)
+			for _, from := range items {
+				for _, to := range items {
+					kube := probe.ConnectivityAllowed
+					if from == "x/a" && to == "x/b" {
+						kube = probe.ConnectivityBlocked
+					}
+					table.Set(from, to, &Item{
+						Kube:      &probe.Item{From: from, To: to, JobResults: jobResults(kube)},
+						Simulated: &probe.Item{From: from, To: to, JobResults: jobResults(probe.ConnectivityAllowed)},
+					})
+				}
+			}
+
+			counts := table.ValueCountsByZonePair(true, map[string]string{"x/a": "zone-1", "x/b": "zone-2"})
+			Expect(counts[probe.ZonePairCross]).To(Equal(map[Comparison]int{DifferentComparison: 1, SameComparison: 1}))
+			Expect(counts[probe.ZonePairSame]).To(Equal(map[Comparison]int{IgnoredComparison: 2}))
+			Expect(counts[probe.ZonePairUnknown]).To(Equal(map[Comparison]int{SameComparison: 4, IgnoredComparison: 1}))
+		})
+	})
+
+	Describe("Dual-stack", func() {
+		It("should verify each IP family against what the policies allow over it", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			kubernetes.DualStack = true
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+			Expect(resources.IPFamilies()).To(Equal([]v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}))
+			interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true, DualStack: true})
+			Expect(err).To(Succeed())
+
+			// the mock allows everything, and an IPv4 ipBlock allows everything over IPv4, but nothing over IPv6
+			policy := generator.BuildPolicy(
+				generator.SetNamespace("x"),
+				generator.SetPeers(true, []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0"}}})).NetworkPolicy()
+			testCase := generator.NewSingleStepTestCase("ipv4 ipBlock", generator.NewStringSet(generator.TagIPBlockNoExcept), &generator.ProbeConfig{AllAvailable: true, Mode: generator.ProbeModePodIP}, generator.CreatePolicy(policy))
+			result := interpreter.ExecuteTestCase(testCase)
+			Expect(result.Err).To(Succeed())
+
+			step := result.Steps[0]
+			Expect(step.Families()).To(Equal([]v1.IPFamily{v1.IPv4Protocol, v1.IPv6Protocol}))
+			Expect(step.FamilyProbes[v1.IPv4Protocol].SimulatedProbe).To(BeIdenticalTo(step.SimulatedProbe))
+			Expect(step.FamilyComparison(v1.IPv4Protocol).ValueCounts(true)[DifferentComparison]).To(Equal(0))
+			Expect(step.FamilyComparison(v1.IPv6Protocol).ValueCounts(true)[DifferentComparison]).To(Equal(1))
+			Expect(step.LastComparison().ValueCounts(true)[DifferentComparison]).To(Equal(0))
+			Expect(result.Passed(true)).To(BeFalse())
+			Expect(result.FailureClass(true)).To(Equal(FailureClassVerification))
+
+			summary := (&CombinedResults{Results: []*Result{result}}).Summary(true)
+			Expect(summary.FamilyCounts[v1.IPv6Protocol]).To(Equal(map[Comparison]int{SameComparison: 1, DifferentComparison: 1, IgnoredComparison: 2}))
+		})
+
+		It("should fail test cases whose external endpoint results differ from what the policies allow", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+
+			// the mock allows everything, but egress from x is only allowed to pods, not to the endpoint
+			policy := (&generator.Netpol{
+				Name:   "egress-to-pods",
+				Target: &generator.NetpolTarget{Namespace: "x"},
+				Egress: &generator.NetpolPeers{Rules: []*generator.Rule{{Peers: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}, NamespaceSelector: &metav1.LabelSelector{}}}}}},
+			}).NetworkPolicy()
+			testCase := generator.NewSingleStepTestCase("egress to pods", generator.NewStringSet(generator.TagEgress), generator.ProbeAllAvailable, generator.CreatePolicy(policy))
+
+			for _, required := range []bool{false, true} {
+				endpoint, err := probe.NewExternalEndpointPath("172.18.0.5:8080")
+				Expect(err).To(Succeed())
+				endpoint.Required = required
+				interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true, EgressPath: endpoint})
+				Expect(err).To(Succeed())
+				result := interpreter.ExecuteTestCase(testCase)
+				Expect(result.Err).To(Succeed())
+
+				step := result.Steps[0]
+				Expect(step.LastComparison().ValueCounts(false)[DifferentComparison]).To(Equal(0))
+				Expect(step.EgressPathCounts()).To(Equal(map[Comparison]int{SameComparison: 1, DifferentComparison: 1}))
+				Expect(step.EgressPathRequired).To(Equal(required))
+				Expect(result.Passed(false)).To(Equal(!required))
+				if required {
+					Expect(step.EgressPathDifferences()).To(Equal(1))
+					Expect(result.FailureClass(false)).To(Equal(FailureClassVerification))
+				}
+			}
+		})
+	})
+
+	Describe("Waivers", func() {
+		It("should compare waived probes to the waiver's expected result, and count them separately", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			resources, err := probe.NewDefaultResources(kubernetes, []string{"x", "y"}, []string{"a"}, []int{80}, []v1.Protocol{v1.ProtocolTCP}, 5, false, nil)
+			Expect(err).To(Succeed())
+			interpreter, err := NewInterpreter(kubernetes, resources, &InterpreterConfig{ResetClusterBeforeTestCase: true})
+			Expect(err).To(Succeed())
+
+			// the mock allows everything, but the policy denies ingress to x/a
+			policy := generator.BuildPolicy(generator.SetNamespace("x")).NetworkPolicy()
+			testCase := generator.NewSingleStepTestCase("deny all ingress", generator.NewStringSet(generator.TagDenyAll), generator.ProbeAllAvailable, generator.CreatePolicy(policy))
+			result := interpreter.ExecuteTestCase(testCase)
+			Expect(result.Err).To(Succeed())
+			Expect(result.Passed(true)).To(BeFalse())
+			Expect(result.Steps[0].WaivedDifferences(true)).To(Equal(0))
+
+			testCase.Steps[0].Waivers = []*generator.Waiver{{TestCase: testCase.Description, To: "x/a", Expected: "allowed", Reason: "example-cni#1: ingress isn't enforced"}}
+			result = interpreter.ExecuteTestCase(testCase)
+			Expect(result.Err).To(Succeed())
+			Expect(result.Passed(true)).To(BeTrue())
+			step := result.Steps[0]
+			Expect(step.WaivedDifferences(true)).To(Equal(1))
+
+			summary := (&CombinedResults{Results: []*Result{result}}).Summary(true)
+			Expect(summary.Failed).To(Equal(0))
+			Expect(summary.WaivedDifferences).To(Equal(1))
+			Expect((&CombinedResults{Results: []*Result{result}}).ResultsDocument(true).Tests[0].Steps[0].WaivedDifferences).To(Equal(1))
+		})
+	})
+}
@@ -0,0 +1,444 @@
+package connectivity
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const ResultsDocumentFileName = "results.json"
+
+// ResultsDocumentSchemaVersion is bumped whenever a change to ResultsDocument would keep older documents from being
+// read correctly, along with a migration from the previous version in resultsDocumentMigrations
+const ResultsDocumentSchemaVersion = 2
+
+// resultsDocumentMigrations upgrade results documents by version
+var resultsDocumentMigrations = map[int]utils.SchemaMigration{
+	// version 1 documents -- from before versioning -- have the same fields as version 2
+	1: func(doc map[string]interface{}) error { return nil },
+}
+
+// ResultsDocument is a machine-readable record of a run, meant to be written to disk and consumed by other tools
+type ResultsDocument struct {
+	SchemaVersion int
+	Passed        int
+	Failed        int
+	// Partial is true if the run was interrupted before all tests were run
+	Partial bool
+	Tests   []*TestCaseRecord
+}
+
+type TestCaseRecord struct {
+	Number      int
+	Description string
+	Tags        []string
+	Passed      bool
+	// FailureClass is omitted for passed tests
+	FailureClass FailureClass `json:",omitempty"`
+	Error        string       `json:",omitempty"`
+	Interrupted  bool         `json:",omitempty"`
+	// Waiver is why a failed test's failures are waived; omitted for tests which passed or weren't waived
+	Waiver string `json:",omitempty"`
+	// DurationSeconds is the test case's wall-clock time
+	DurationSeconds float64 `json:",omitempty"`
+	// FailureArtifacts is the directory a failed test's artifacts were collected in; omitted if they weren't
+	FailureArtifacts string `json:",omitempty"`
+	// MinimizedPolicies are the smallest policies which still reproduced a failed test's mismatches; omitted unless
+	// failures were minimized
+	MinimizedPolicies *MinimizedPolicies `json:",omitempty"`
+	Steps             []*StepRecord
+}
+
+type StepRecord struct {
+	Tries                  int
+	Wrong                  int
+	Right                  int
+	Ignored                int
+	CrossModeDiscrepancies int `json:",omitempty"`
+	// NetworkDifferences counts results over each secondary network which differ from the expected results
+	NetworkDifferences map[string]int `json:",omitempty"`
+	// FamilyDifferences counts results over each IP family which differ from what the policies allow over that
+	// family; omitted unless dual-stack probing was enabled
+	FamilyDifferences map[v1.IPFamily]int `json:",omitempty"`
+	// ExternalEndpointDifferences counts probes of the external endpoint which differ from what the policies allow;
+	// omitted unless an external endpoint was probed
+	ExternalEndpointDifferences int `json:",omitempty"`
+	// DNSDifferences counts DNS lookups which differ from what the policies allow; omitted unless DNS checks were
+	// enabled
+	DNSDifferences int `json:",omitempty"`
+	// WaivedDifferences counts results which differ from what the policies allow, but are waived, so aren't counted
+	// as wrong
+	WaivedDifferences int `json:",omitempty"`
+	// ZonePairDifferences counts results which differ from the expected results, by whether the pods were in the
+	// same zone; omitted if no zones were known
+	ZonePairDifferences map[probe.ZonePair]int `json:",omitempty"`
+	// UDPDelivery is the delivery rate of each UDP probe of the last try; omitted unless UDP probes sent bursts
+	UDPDelivery []*UDPDeliveryRecord `json:",omitempty"`
+	// HTTPChecks are the TCP probes of the last try which connected, but whose HTTP request the serving pod didn't
+	// answer; omitted unless there are any
+	HTTPChecks []*HTTPCheckRecord `json:",omitempty"`
+	// TLSChecks are the TCP probes of the last try which connected, but whose TLS handshake failed, or whose request
+	// the serving pod didn't answer; omitted unless there are any
+	TLSChecks []*TLSCheckRecord `json:",omitempty"`
+	// Latency summarizes how long the allowed probes of the last try took; omitted unless latency was measured
+	Latency *LatencyRecord `json:",omitempty"`
+	// Connections are what happened to the long-lived connections the step closed; omitted unless it closed any
+	Connections []*ConnectionRecord `json:",omitempty"`
+	// PacketCaptures are captures of re-runs of mismatched probes; omitted unless packet capture was enabled
+	PacketCaptures []*PacketCapture `json:",omitempty"`
+	// Probes are the expected and actual results of every job of the last try, by source, destination and job
+	Probes []*ProbeRecord `json:",omitempty"`
+}
+
+// ProbeRecord is one cell of a step's truth table.  Ignored jobs -- loopback, if ignored -- aren't counted as wrong
+// even if Expected and Actual differ.
+type ProbeRecord struct {
+	From     string
+	To       string
+	Job      string
+	Expected probe.Connectivity
+	Actual   probe.Connectivity
+	Wrong    bool `json:",omitempty"`
+	Ignored  bool `json:",omitempty"`
+}
+
+type UDPDeliveryRecord struct {
+	From      string
+	To        string
+	Port      int
+	Sent      int
+	Delivered int
+	Rate      float64
+}
+
+type HTTPCheckRecord struct {
+	From       string
+	To         string
+	Port       int
+	StatusCode int
+	Body       string
+}
+
+type TLSCheckRecord struct {
+	From  string
+	To    string
+	Port  int
+	Error string `json:",omitempty"`
+	Body  string
+}
+
+type ConnectionRecord struct {
+	From     string
+	To       string
+	Port     int
+	Fate     probe.ConnectionFate
+	Expected probe.ConnectionFate `json:",omitempty"`
+	Requests int
+	Answered int
+	Failure  string `json:",omitempty"`
+}
+
+// LatencyRecord holds nearest-rank percentiles of probe latencies, in milliseconds
+type LatencyRecord struct {
+	Probes int
+	P50Ms  float64
+	P90Ms  float64
+	P99Ms  float64
+	MaxMs  float64
+}
+
+func (c *CombinedResults) ResultsDocument(ignoreLoopback bool) *ResultsDocument {
+	doc := &ResultsDocument{SchemaVersion: ResultsDocumentSchemaVersion}
+	for i, result := range c.Results {
+		record := newTestCaseRecord(i+1, result, ignoreLoopback)
+		if result.Interrupted {
+			doc.Partial = true
+		}
+		if record.Passed {
+			doc.Passed++
+		} else {
+			doc.Failed++
+		}
+		doc.Tests = append(doc.Tests, record)
+	}
+	return doc
+}
+
+func newTestCaseRecord(number int, result *Result, ignoreLoopback bool) *TestCaseRecord {
+	record := &TestCaseRecord{
+		Number:            number,
+		Description:       result.TestCase.Description,
+		Tags:              result.TestCase.Tags.Keys(),
+		Passed:            result.Passed(ignoreLoopback),
+		Interrupted:       result.Interrupted,
+		DurationSeconds:   result.Timing.Total.Seconds(),
+		FailureArtifacts:  result.FailureArtifacts,
+		MinimizedPolicies: result.MinimizedPolicies,
+	}
+	if !record.Passed {
+		record.FailureClass = result.FailureClass(ignoreLoopback)
+		record.Waiver = result.TestCase.Waiver
+	}
+	if result.Err != nil {
+		record.Error = result.Err.Error()
+	}
+	var zones map[string]string
+	if result.InitialResources != nil {
+		zones = result.InitialResources.Zones()
+	}
+	for _, step := range result.Steps {
+		counts := step.LastComparison().ValueCounts(ignoreLoopback)
+		stepRecord := &StepRecord{
+			Tries:   len(step.KubeProbes),
+			Wrong:   counts[DifferentComparison],
+			Right:   counts[SameComparison],
+			Ignored: counts[IgnoredComparison],
+		}
+		if crossMode := step.CrossModeComparison(); crossMode != nil {
+			stepRecord.CrossModeDiscrepancies = crossMode.ValueCounts(ignoreLoopback)[DifferentComparison]
+		}
+		if len(zones) > 0 {
+			stepRecord.ZonePairDifferences = map[probe.ZonePair]int{}
+			for zonePair, zonePairCounts := range step.LastComparison().ValueCountsByZonePair(ignoreLoopback, zones) {
+				stepRecord.ZonePairDifferences[zonePair] = zonePairCounts[DifferentComparison]
+			}
+		}
+		stepRecord.UDPDelivery = udpDeliveryRecords(step.LastKubeProbe())
+		stepRecord.HTTPChecks = partialHTTPCheckRecords(step.LastKubeProbe())
+		stepRecord.TLSChecks = partialTLSCheckRecords(step.LastKubeProbe())
+		stepRecord.Latency = latencyRecord(step.LastKubeProbe())
+		stepRecord.Connections = connectionRecords(step.ConnectionResults)
+		stepRecord.PacketCaptures = step.PacketCaptures
+		stepRecord.Probes = probeRecords(step.LastComparison(), ignoreLoopback)
+		for _, network := range step.Networks() {
+			if stepRecord.NetworkDifferences == nil {
+				stepRecord.NetworkDifferences = map[string]int{}
+			}
+			stepRecord.NetworkDifferences[network] = step.NetworkComparison(network).ValueCounts(ignoreLoopback)[DifferentComparison]
+		}
+		for _, family := range step.Families() {
+			if stepRecord.FamilyDifferences == nil {
+				stepRecord.FamilyDifferences = map[v1.IPFamily]int{}
+			}
+			stepRecord.FamilyDifferences[family] = step.FamilyComparison(family).ValueCounts(ignoreLoopback)[DifferentComparison]
+		}
+		stepRecord.ExternalEndpointDifferences = step.EgressPathDifferences()
+		stepRecord.DNSDifferences = step.DNSCounts()[DifferentComparison]
+		stepRecord.WaivedDifferences = step.WaivedDifferences(ignoreLoopback)
+		record.Steps = append(record.Steps, stepRecord)
+	}
+	return record
+}
+
+func probeRecords(comparison *ComparisonTable, ignoreLoopback bool) []*ProbeRecord {
+	var records []*ProbeRecord
+	for _, key := range comparison.Wrapped.Keys() {
+		item := comparison.Get(key.From, key.To)
+		var jobKeys []string
+		for jobKey := range item.Kube.JobResults {
+			jobKeys = append(jobKeys, jobKey)
+		}
+		sort.Strings(jobKeys)
+		for _, jobKey := range jobKeys {
+			record := &ProbeRecord{
+				From:     key.From,
+				To:       key.To,
+				Job:      jobKey,
+				Expected: probe.ConnectivityUnknown,
+				Actual:   item.Kube.JobResults[jobKey].Combined,
+				Ignored:  ignoreLoopback && key.From == key.To,
+			}
+			if simulated, ok := item.Simulated.JobResults[jobKey]; ok {
+				record.Expected = simulated.Combined
+			}
+			record.Wrong = !record.Ignored && record.Expected != record.Actual
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+func udpDeliveryRecords(table *probe.Table) []*UDPDeliveryRecord {
+	var records []*UDPDeliveryRecord
+	for _, key := range table.Wrapped.Keys() {
+		item := table.Get(key.From, key.To)
+		var jobKeys []string
+		for jobKey := range item.JobResults {
+			jobKeys = append(jobKeys, jobKey)
+		}
+		sort.Strings(jobKeys)
+		for _, jobKey := range jobKeys {
+			jobResult := item.JobResults[jobKey]
+			if jobResult.UDPDelivery == nil {
+				continue
+			}
+			records = append(records, &UDPDeliveryRecord{
+				From:      key.From,
+				To:        key.To,
+				Port:      jobResult.Job.ResolvedPort,
+				Sent:      jobResult.UDPDelivery.Sent,
+				Delivered: jobResult.UDPDelivery.Delivered,
+				Rate:      jobResult.UDPDelivery.Rate(),
+			})
+		}
+	}
+	return records
+}
+
+func (r *ResultsDocument) WriteToDirectory(dir string) (string, error) {
+	path := filepath.Join(dir, ResultsDocumentFileName)
+	return path, r.WriteToFile(path)
+}
+
+func (r *ResultsDocument) WriteToFile(path string) error {
+	bytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal results document")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, bytes, 0644), "unable to write results document to %s", path)
+}
+
+// ReadResultsDocument reads a results document written by this or any older version of cyclonus
+func ReadResultsDocument(path string) (*ResultsDocument, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read results document %s", path)
+	}
+	return ParseResultsDocument(bytes, path)
+}
+
+func ParseResultsDocument(bytes []byte, path string) (*ResultsDocument, error) {
+	bytes, err := utils.MigrateSchema(bytes, ResultsDocumentSchemaVersion, resultsDocumentMigrations)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to migrate results document %s", path)
+	}
+	doc := &ResultsDocument{}
+	if err := json.Unmarshal(bytes, doc); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal results document %s", path)
+	}
+	return doc, nil
+}
+
+// testCaseKey identifies a test case across runs by its description.  Descriptions aren't unique -- i.e. those
+// generated from tags -- so repeats are told apart by how many times the description has already been seen.
+func testCaseKey(description string, seen map[string]int) string {
+	key := fmt.Sprintf("%s #%d", description, seen[description])
+	seen[description]++
+	return key
+}
+
+// FilterTestCases keeps the test cases which were recorded in the document -- or, if onlyFailed, only those which
+// were recorded as failed or interrupted.  Test cases are matched by description, so the document should come from a run with
+// the same test case selection.
+func (r *ResultsDocument) FilterTestCases(testCases []*generator.TestCase, onlyFailed bool) []*generator.TestCase {
+	wanted := map[string]bool{}
+	seen := map[string]int{}
+	for _, record := range r.Tests {
+		key := testCaseKey(record.Description, seen)
+		if !onlyFailed || !record.Passed || record.Interrupted {
+			wanted[key] = true
+		}
+	}
+	var filtered []*generator.TestCase
+	seen = map[string]int{}
+	for _, testCase := range testCases {
+		if wanted[testCaseKey(testCase.Description, seen)] {
+			filtered = append(filtered, testCase)
+		}
+	}
+	return filtered
+}
+
+func partialHTTPCheckRecords(table *probe.Table) []*HTTPCheckRecord {
+	var records []*HTTPCheckRecord
+	for _, key := range table.Wrapped.Keys() {
+		item := table.Get(key.From, key.To)
+		var jobKeys []string
+		for jobKey := range item.JobResults {
+			jobKeys = append(jobKeys, jobKey)
+		}
+		sort.Strings(jobKeys)
+		for _, jobKey := range jobKeys {
+			jobResult := item.JobResults[jobKey]
+			if jobResult.HTTPCheck == nil || !jobResult.HTTPCheck.IsPartial() {
+				continue
+			}
+			records = append(records, &HTTPCheckRecord{
+				From:       key.From,
+				To:         key.To,
+				Port:       jobResult.Job.ResolvedPort,
+				StatusCode: jobResult.HTTPCheck.StatusCode,
+				Body:       jobResult.HTTPCheck.Body,
+			})
+		}
+	}
+	return records
+}
+
+func partialTLSCheckRecords(table *probe.Table) []*TLSCheckRecord {
+	var records []*TLSCheckRecord
+	for _, key := range table.Wrapped.Keys() {
+		item := table.Get(key.From, key.To)
+		var jobKeys []string
+		for jobKey := range item.JobResults {
+			jobKeys = append(jobKeys, jobKey)
+		}
+		sort.Strings(jobKeys)
+		for _, jobKey := range jobKeys {
+			jobResult := item.JobResults[jobKey]
+			if jobResult.TLSCheck == nil || !jobResult.TLSCheck.IsPartial() {
+				continue
+			}
+			records = append(records, &TLSCheckRecord{
+				From:  key.From,
+				To:    key.To,
+				Port:  jobResult.Job.ResolvedPort,
+				Error: jobResult.TLSCheck.Error,
+				Body:  jobResult.TLSCheck.Body,
+			})
+		}
+	}
+	return records
+}
+
+func latencyRecord(table *probe.Table) *LatencyRecord {
+	summary := table.LatencySummary()
+	if summary == nil {
+		return nil
+	}
+	milliseconds := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	return &LatencyRecord{
+		Probes: summary.Count,
+		P50Ms:  milliseconds(summary.P50),
+		P90Ms:  milliseconds(summary.P90),
+		P99Ms:  milliseconds(summary.P99),
+		MaxMs:  milliseconds(summary.Max),
+	}
+}
+
+func connectionRecords(results []*ConnectionResult) []*ConnectionRecord {
+	var records []*ConnectionRecord
+	for _, result := range results {
+		records = append(records, &ConnectionRecord{
+			From:     result.Result.From,
+			To:       result.Result.To,
+			Port:     result.Result.Port,
+			Fate:     result.Result.Fate(),
+			Expected: result.Expected,
+			Requests: result.Result.Requests,
+			Answered: result.Result.Answered,
+			Failure:  result.Result.Failure,
+		})
+	}
+	return records
+}
@@ -0,0 +1,185 @@
+package connectivity
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"time"
+)
+
+func RunResultsDocumentTests() {
+	Describe("ResultsDocument", func() {
+		testCase := func(description string) *generator.TestCase {
+			return generator.NewTestCase(description, generator.NewStringSet(generator.TagIngress))
+		}
+
+		It("should filter test cases by a previous run's results", func() {
+			doc := &ResultsDocument{Tests: []*TestCaseRecord{
+				{Description: "a", Passed: true},
+				{Description: "dup", Passed: true},
+				{Description: "dup", Passed: false},
+				{Description: "b", Passed: true, Interrupted: true},
+			}}
+			testCases := []*generator.TestCase{testCase("a"), testCase("dup"), testCase("dup"), testCase("b"), testCase("new")}
+
+			failed := doc.FilterTestCases(testCases, true)
+			Expect(failed).To(Equal([]*generator.TestCase{testCases[2], testCases[3]}))
+
+			recorded := doc.FilterTestCases(testCases, false)
+			Expect(recorded).To(Equal(testCases[:4]))
+		})
+
+		It("should record UDP delivery rates, skipping probes without bursts", func() {
+			table := probe.NewTable([]string{"x/a", "y/b"})
+			utils.DoOrDie(table.Get("x/a", "y/b").AddJobResult(&probe.JobResult{
+				Job:         &probe.Job{Protocol: v1.ProtocolUDP, ResolvedPort: 80},
+				Combined:    probe.ConnectivityAllowed,
+				UDPDelivery: &probe.UDPDelivery{Sent: 20, Delivered: 15},
+			}))
+			utils.DoOrDie(table.Get("x/a", "y/b").AddJobResult(&probe.JobResult{
+				Job:      &probe.Job{Protocol: v1.ProtocolTCP, ResolvedPort: 80},
+				Combined: probe.ConnectivityAllowed,
+			}))
+
+			records := udpDeliveryRecords(table)
+			Expect(records).To(Equal([]*UDPDeliveryRecord{{From: "x/a", To: "y/b", Port: 80, Sent: 20, Delivered: 15, Rate: 0.75}}))
+			Expect(table.CountLossyUDP()).To(Equal(1))
+		})
+
+		It("should record probes which connected, but whose HTTP request wasn't answered", func() {
+			table := probe.NewTable([]string{"x/a", "y/b"})
+			utils.DoOrDie(table.Get("x/a", "y/b").AddJobResult(&probe.JobResult{
+				Job:       &probe.Job{Protocol: v1.ProtocolTCP, ResolvedPort: 80},
+				Combined:  probe.ConnectivityBlocked,
+				HTTPCheck: &probe.HTTPCheck{Connected: true, StatusCode: 503, Body: "no healthy upstream", ExpectedBody: "b"},
+			}))
+			utils.DoOrDie(table.Get("y/b", "x/a").AddJobResult(&probe.JobResult{
+				Job:       &probe.Job{Protocol: v1.ProtocolTCP, ResolvedPort: 80},
+				Combined:  probe.ConnectivityAllowed,
+				HTTPCheck: &probe.HTTPCheck{Connected: true, StatusCode: 200, Body: "a", ExpectedBody: "a"},
+			}))
+
+			records := partialHTTPCheckRecords(table)
+			Expect(records).To(Equal([]*HTTPCheckRecord{{From: "x/a", To: "y/b", Port: 80, StatusCode: 503, Body: "no healthy upstream"}}))
+			Expect(table.CountPartialHTTP()).To(Equal(1))
+		})
+
+		It("should record probes which connected, but whose TLS handshake failed", func() {
+			table := probe.NewTable([]string{"x/a", "y/b"})
+			utils.DoOrDie(table.Get("x/a", "y/b").AddJobResult(&probe.JobResult{
+				Job:      &probe.Job{Protocol: v1.ProtocolTCP, ResolvedPort: 80},
+				Combined: probe.ConnectivityBlocked,
+				TLSCheck: &probe.TLSCheck{Connected: true, Error: "curl: (35) wrong version number", ExpectedBody: "b"},
+			}))
+			utils.DoOrDie(table.Get("y/b", "x/a").AddJobResult(&probe.JobResult{
+				Job:      &probe.Job{Protocol: v1.ProtocolTCP, ResolvedPort: 80},
+				Combined: probe.ConnectivityAllowed,
+				TLSCheck: &probe.TLSCheck{Connected: true, Body: "a", ExpectedBody: "a"},
+			}))
+
+			Expect(partialTLSCheckRecords(table)).To(Equal([]*TLSCheckRecord{{From: "x/a", To: "y/b", Port: 80, Error: "curl: (35) wrong version number"}}))
+			Expect(table.CountPartialTLS()).To(Equal(1))
+		})
+
+		It("should record long-lived connections, and fail test cases whose connections didn't do as expected", func() {
+			severed := &probe.LongLivedConnectionResult{From: "x/b", To: "x/a", Port: 80, Requests: 5, Answered: 4, Failure: "no response"}
+			step := NewStepResult(probe.NewTable([]string{"x/a"}), nil, nil)
+			step.AddKubeProbe(probe.NewTable([]string{"x/a"}))
+			step.ConnectionResults = []*ConnectionResult{{Result: severed}}
+			result := &Result{TestCase: testCase("connection"), Steps: []*StepResult{step}}
+			Expect(result.Passed(false)).To(BeTrue())
+			Expect(connectionRecords(step.ConnectionResults)).To(Equal([]*ConnectionRecord{{From: "x/b", To: "x/a", Port: 80, Fate: probe.ConnectionSevered, Requests: 5, Answered: 4, Failure: "no response"}}))
+
+			step.ConnectionResults[0].Expected = probe.ConnectionSurvived
+			Expect(step.ConnectionCounts()).To(Equal(map[Comparison]int{DifferentComparison: 1}))
+			Expect(result.Passed(false)).To(BeFalse())
+		})
+
+		It("should record DNS lookups which differ from what the policies allow, and fail their test cases", func() {
+			lookup := func(combined probe.Connectivity, expected probe.Connectivity) *DNSResult {
+				return &DNSResult{JobResult: &probe.JobResult{Job: &probe.Job{FromKey: "x/a", ToKey: probe.DNSKey}, Combined: combined}, Expected: expected}
+			}
+			step := NewStepResult(probe.NewTable([]string{"x/a"}), nil, nil)
+			step.AddKubeProbe(probe.NewTable([]string{"x/a"}))
+			step.DNSResults = []*DNSResult{lookup(probe.ConnectivityBlocked, probe.ConnectivityBlocked), lookup(probe.ConnectivityBlocked, "")}
+			result := &Result{TestCase: testCase("dns"), Steps: []*StepResult{step}}
+			Expect(step.DNSCounts()).To(Equal(map[Comparison]int{SameComparison: 1, IgnoredComparison: 1}))
+			Expect(result.Passed(false)).To(BeTrue())
+
+			step.DNSResults = append(step.DNSResults, lookup(probe.ConnectivityBlocked, probe.ConnectivityAllowed))
+			Expect(result.Passed(false)).To(BeFalse())
+			Expect(result.FailureClass(false)).To(Equal(FailureClassVerification))
+			Expect(newTestCaseRecord(1, result, false).Steps[0].DNSDifferences).To(Equal(1))
+
+			step.DNSResults = append(step.DNSResults, lookup(probe.ConnectivityCheckFailed, probe.ConnectivityAllowed))
+			Expect(result.FailureClass(false)).To(Equal(FailureClassInfrastructure))
+		})
+
+		It("should record latency percentiles of allowed probes only", func() {
+			table := probe.NewTable([]string{"x/a", "y/b"})
+			utils.DoOrDie(table.Get("x/a", "y/b").AddJobResult(&probe.JobResult{
+				Job:      &probe.Job{Protocol: v1.ProtocolTCP, ResolvedPort: 80},
+				Combined: probe.ConnectivityAllowed,
+				Latency:  4 * time.Millisecond,
+			}))
+			utils.DoOrDie(table.Get("y/b", "x/a").AddJobResult(&probe.JobResult{
+				Job:      &probe.Job{Protocol: v1.ProtocolTCP, ResolvedPort: 80},
+				Combined: probe.ConnectivityAllowed,
+				Latency:  2 * time.Millisecond,
+			}))
+			utils.DoOrDie(table.Get("x/a", "x/a").AddJobResult(&probe.JobResult{
+				Job:      &probe.Job{Protocol: v1.ProtocolTCP, ResolvedPort: 80},
+				Combined: probe.ConnectivityBlocked,
+			}))
+
+			Expect(latencyRecord(table)).To(Equal(&LatencyRecord{Probes: 2, P50Ms: 2, P90Ms: 4, P99Ms: 4, MaxMs: 4}))
+			Expect(latencyRecord(probe.NewTable([]string{"x/a"}))).To(BeNil())
+		})
+
+		It("should record expected and actual results of every probe", func() {
+			items := []string{"x/a", "y/a"}
+			kubeProbe, simulatedProbe := probe.NewTable(items), probe.NewTable(items)
+			for _, fr := range items {
+				for _, to := range items {
+					job := &probe.Job{FromKey: fr, ToKey: to, Protocol: v1.ProtocolTCP, ResolvedPort: 80}
+					actual := probe.ConnectivityAllowed
+					if to == "x/a" {
+						actual = probe.ConnectivityBlocked
+					}
+					utils.DoOrDie(kubeProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: actual}))
+					utils.DoOrDie(simulatedProbe.Get(fr, to).AddJobResult(&probe.JobResult{Job: job, Combined: probe.ConnectivityAllowed}))
+				}
+			}
+
+			records := probeRecords(NewComparisonTableFrom(kubeProbe, simulatedProbe), true)
+			Expect(records).To(Equal([]*ProbeRecord{
+				{From: "x/a", To: "x/a", Job: "TCP/80", Expected: probe.ConnectivityAllowed, Actual: probe.ConnectivityBlocked, Ignored: true},
+				{From: "x/a", To: "y/a", Job: "TCP/80", Expected: probe.ConnectivityAllowed, Actual: probe.ConnectivityAllowed},
+				{From: "y/a", To: "x/a", Job: "TCP/80", Expected: probe.ConnectivityAllowed, Actual: probe.ConnectivityBlocked, Wrong: true},
+				{From: "y/a", To: "y/a", Job: "TCP/80", Expected: probe.ConnectivityAllowed, Actual: probe.ConnectivityAllowed, Ignored: true},
+			}))
+		})
+
+		It("should read documents from before versioning, but not from newer versions", func() {
+			doc, err := ParseResultsDocument([]byte(`{"Passed": 1, "Failed": 0, "Partial": false, "Tests": [{"Number": 1, "Description": "a", "Tags": ["ingress"], "Passed": true, "Steps": [{"Tries": 1, "Wrong": 0, "Right": 81, "Ignored": 0}]}]}`), "old.json")
+			Expect(err).To(Succeed())
+			Expect(doc).To(Equal(&ResultsDocument{
+				SchemaVersion: ResultsDocumentSchemaVersion,
+				Passed:        1,
+				Tests: []*TestCaseRecord{{
+					Number:      1,
+					Description: "a",
+					Tags:        []string{"ingress"},
+					Passed:      true,
+					Steps:       []*StepRecord{{Tries: 1, Right: 81}},
+				}},
+			}))
+
+			_, err = ParseResultsDocument([]byte(`{"SchemaVersion": 1000, "Tests": []}`), "new.json")
+			Expect(err).To(MatchError(ContainSubstring("schema version 1000 is newer")))
+		})
+	})
+}
@@ -2,8 +2,11 @@ package connectivity
 
 import (
 	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
 	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	v1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"sort"
 )
 
 type StepResult struct {
@@ -12,6 +15,56 @@ type StepResult struct {
 	Policy         *matcher.Policy
 	KubePolicies   []*networkingv1.NetworkPolicy
 	comparisons    []*ComparisonTable
+
+	// CrossModeProbes are kube probes of the same step using different destination types; only filled in if
+	// cross-mode checking is enabled
+	CrossModeProbes map[generator.ProbeMode]*probe.Table
+
+	// NetworkProbes are kube probes of the same step over Multus secondary networks, by network name; only filled
+	// in if secondary networks were selected
+	NetworkProbes map[string]*probe.Table
+
+	// FamilyProbes are probes of the same step by pod IP over each IP family, by family; only filled in if dual-stack
+	// probing was enabled, and all the pods have both IPv4 and IPv6 addresses
+	FamilyProbes map[v1.IPFamily]*FamilyProbe
+
+	// RouteProbe is a kube probe of the same step through the pods' OpenShift Routes; only filled in if route probing
+	// was enabled
+	RouteProbe *probe.Table
+
+	// EgressPathResults are kube probes of the egress path from every pod, sorted by source; only filled in if an
+	// egress path was configured.  EgressPathRequired means that results which differ from what the policies allow
+	// fail the test case, as for an external endpoint.
+	EgressPathResults  []*EgressPathResult
+	EgressPathRequired bool
+
+	// DNSResults are DNS lookups from every pod, sorted by source; only filled in if DNS checks were enabled
+	DNSResults []*DNSResult
+
+	// ConnectionResults are what happened to the long-lived connections the step's actions closed
+	ConnectionResults []*ConnectionResult
+
+	// CorroboratedBy names the corroborator which read the dataplane's state after the step, and
+	// CorroborationFindings are where that state disagreed with the policies or the last kube probe; only filled in
+	// if a corroborator was configured, and it was able to read the state
+	CorroboratedBy        string
+	CorroborationFindings []*CorroborationFinding
+
+	// IgnoredJobs picks out job results which are left out of comparisons, so that they're reported but not verified
+	IgnoredJobs *probe.JobFilter
+
+	// WaivedProbe is SimulatedProbe with the step's waivers applied, which kube probes are compared to instead; nil
+	// if no waivers apply to the step
+	WaivedProbe *probe.Table
+
+	// PacketCaptures are captures of re-runs of the step's mismatched probes; only filled in if packet capture was
+	// enabled, and the step had mismatches
+	PacketCaptures []*PacketCapture
+
+	// Resources are the pods and namespaces the step was probed with
+	Resources *probe.Resources
+
+	Timing StepTiming
 }
 
 func NewStepResult(simulated *probe.Table, policy *matcher.Policy, kubePolicies []*networkingv1.NetworkPolicy) *StepResult {
@@ -29,15 +82,203 @@ func (s *StepResult) AddKubeProbe(kubeProbe *probe.Table) {
 
 func (s *StepResult) Comparison(i int) *ComparisonTable {
 	if s.comparisons[i] == nil {
-		s.comparisons[i] = NewComparisonTableFrom(s.KubeProbes[i], s.SimulatedProbe)
+		s.comparisons[i] = NewComparisonTableFrom(s.KubeProbes[i].Without(s.IgnoredJobs), s.ExpectedProbe().Without(s.IgnoredJobs))
 	}
 	return s.comparisons[i]
 }
 
+// ExpectedProbe is what kube probes are compared to: the simulated probe, with waivers applied
+func (s *StepResult) ExpectedProbe() *probe.Table {
+	if s.WaivedProbe != nil {
+		return s.WaivedProbe
+	}
+	return s.SimulatedProbe
+}
+
+// WaivedDifferences counts the pairs whose last results differ from the simulated probe, but match once waivers are
+// applied
+func (s *StepResult) WaivedDifferences(ignoreLoopback bool) int {
+	if s.WaivedProbe == nil {
+		return 0
+	}
+	unwaived := NewComparisonTableFrom(s.LastKubeProbe().Without(s.IgnoredJobs), s.SimulatedProbe.Without(s.IgnoredJobs))
+	waived := s.LastComparison()
+	count := 0
+	for _, key := range waived.Wrapped.Keys() {
+		if ignoreLoopback && key.From == key.To {
+			continue
+		}
+		if !unwaived.Get(key.From, key.To).IsSuccess() && waived.Get(key.From, key.To).IsSuccess() {
+			count++
+		}
+	}
+	return count
+}
+
 func (s *StepResult) LastComparison() *ComparisonTable {
 	return s.Comparison(len(s.KubeProbes) - 1)
 }
 
+// CrossModeComparison compares the pod IP probe (as 'Kube') to the service IP probe (as 'Simulated').  Returns nil
+// if cross-mode probes weren't run.
+func (s *StepResult) CrossModeComparison() *ComparisonTable {
+	podIP, serviceIP := s.CrossModeProbes[generator.ProbeModePodIP], s.CrossModeProbes[generator.ProbeModeServiceIP]
+	if podIP == nil || serviceIP == nil {
+		return nil
+	}
+	return NewComparisonTableFrom(podIP.Without(s.IgnoredJobs), serviceIP.Without(s.IgnoredJobs))
+}
+
+// NetworkComparison compares a secondary network's probe (as 'Kube') to the simulated probe.  Policies usually only
+// apply to the primary network, so discrepancies are to be expected; they aren't counted as failures.
+func (s *StepResult) NetworkComparison(network string) *ComparisonTable {
+	networkProbe := s.NetworkProbes[network]
+	if networkProbe == nil {
+		return nil
+	}
+	return NewComparisonTableFrom(networkProbe.Without(s.IgnoredJobs), s.SimulatedProbe.Without(s.IgnoredJobs))
+}
+
+// Networks returns the secondary networks which were probed, sorted
+func (s *StepResult) Networks() []string {
+	var networks []string
+	for network := range s.NetworkProbes {
+		networks = append(networks, network)
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+// FamilyProbe is a probe by pod IP over one IP family: what the policies allow between the pods' addresses of that
+// family -- ipBlocks of the other family don't match them -- and the kube probe of every try
+type FamilyProbe struct {
+	SimulatedProbe *probe.Table
+	KubeProbes     []*probe.Table
+}
+
+func (f *FamilyProbe) LastKubeProbe() *probe.Table {
+	return f.KubeProbes[len(f.KubeProbes)-1]
+}
+
+// FamilyComparison compares the last try of an IP family's probe to what the policies allow over that family.
+// Policies apply to both families, so discrepancies are counted as failures.  Returns nil if the family wasn't
+// probed.
+func (s *StepResult) FamilyComparison(family v1.IPFamily) *ComparisonTable {
+	familyProbe := s.FamilyProbes[family]
+	if familyProbe == nil {
+		return nil
+	}
+	return NewComparisonTableFrom(familyProbe.LastKubeProbe().Without(s.IgnoredJobs), familyProbe.SimulatedProbe.Without(s.IgnoredJobs))
+}
+
+// Families returns the IP families which were probed, sorted
+func (s *StepResult) Families() []v1.IPFamily {
+	var families []v1.IPFamily
+	for family := range s.FamilyProbes {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i] < families[j]
+	})
+	return families
+}
+
+// FamilyDifferences counts results, over all IP families, which differ from what the policies allow
+func (s *StepResult) FamilyDifferences(ignoreLoopback bool) int {
+	differences := 0
+	for _, family := range s.Families() {
+		differences += s.FamilyComparison(family).ValueCounts(ignoreLoopback)[DifferentComparison]
+	}
+	return differences
+}
+
 func (s *StepResult) LastKubeProbe() *probe.Table {
 	return s.KubeProbes[len(s.KubeProbes)-1]
 }
+
+// EgressPathResult is a probe of the egress path from a pod; Expected is empty if the path can't be checked against
+// policies
+type EgressPathResult struct {
+	JobResult *probe.JobResult
+	Expected  probe.Connectivity
+}
+
+// Comparison is IgnoredComparison if the result can't be checked
+func (e *EgressPathResult) Comparison() Comparison {
+	if e.Expected == "" {
+		return IgnoredComparison
+	}
+	if e.Expected == e.JobResult.Combined {
+		return SameComparison
+	}
+	return DifferentComparison
+}
+
+// EgressPathDifferences counts egress path results which differ from what the policies allow, if they're required
+// to match; otherwise, it's 0
+func (s *StepResult) EgressPathDifferences() int {
+	if !s.EgressPathRequired {
+		return 0
+	}
+	return s.EgressPathCounts()[DifferentComparison]
+}
+
+func (s *StepResult) EgressPathCounts() map[Comparison]int {
+	counts := map[Comparison]int{}
+	for _, result := range s.EgressPathResults {
+		counts[result.Comparison()]++
+	}
+	return counts
+}
+
+// DNSResult is a DNS lookup from a pod; Expected is empty if the policies allow egress to some of the DNS pods but
+// not others, so that whether the lookup works is up to which one it goes to
+type DNSResult struct {
+	JobResult *probe.JobResult
+	Expected  probe.Connectivity
+}
+
+// Comparison is IgnoredComparison if the result can't be checked
+func (d *DNSResult) Comparison() Comparison {
+	if d.Expected == "" {
+		return IgnoredComparison
+	}
+	if d.Expected == d.JobResult.Combined {
+		return SameComparison
+	}
+	return DifferentComparison
+}
+
+func (s *StepResult) DNSCounts() map[Comparison]int {
+	counts := map[Comparison]int{}
+	for _, result := range s.DNSResults {
+		counts[result.Comparison()]++
+	}
+	return counts
+}
+
+// ConnectionResult is what happened to a long-lived connection by the time it was closed; Expected is empty if what
+// should have happened wasn't configured
+type ConnectionResult struct {
+	Result   *probe.LongLivedConnectionResult
+	Expected probe.ConnectionFate
+}
+
+// Comparison is IgnoredComparison if the result can't be checked
+func (c *ConnectionResult) Comparison() Comparison {
+	if c.Expected == "" {
+		return IgnoredComparison
+	}
+	if c.Expected == c.Result.Fate() {
+		return SameComparison
+	}
+	return DifferentComparison
+}
+
+func (s *StepResult) ConnectionCounts() map[Comparison]int {
+	counts := map[Comparison]int{}
+	for _, result := range s.ConnectionResults {
+		counts[result.Comparison()]++
+	}
+	return counts
+}
@@ -10,5 +10,30 @@ import (
 func TestConnectivity(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunTestCaseStateTests()
+	RunLeftoverResourcesTests()
+	RunComparisonTableTests()
+	RunFailureClassTests()
+	RunFeaturesTests()
+	RunResultsDocumentTests()
+	RunResultTests()
+	RunChaosTests()
+	RunCanonicalTests()
+	RunHeatmapTests()
+	RunCorroboratorTests()
+	RunJUnitTests()
+	RunHTMLReportTests()
+	RunMetricsTests()
+	RunParallelTests()
+	RunCheckpointTests()
+	RunExportTests()
+	RunFlakeTests()
+	RunFailureArtifactsTests()
+	RunPacketCaptureTests()
+	RunReproductionTests()
+	RunMinimizerTests()
+	RunDivergenceTests()
+	RunReachabilityDiffTests()
+	RunPolicyCoverageTests()
+	RunInterpreterTests()
 	RunSpecs(t, "connectivity suite")
 }
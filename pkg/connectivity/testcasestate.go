@@ -1,8 +1,10 @@
 package connectivity
 
 import (
+	"fmt"
 	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
 	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
@@ -10,17 +12,28 @@ import (
 	"time"
 )
 
+// AdminNetworkPolicyManagedLabel marks the AdminNetworkPolicies, and BaselineAdminNetworkPolicy, cyclonus creates.
+// Unlike NetworkPolicies, they're cluster-scoped, so they can't be cleaned up by namespace.
+const AdminNetworkPolicyManagedLabel = "cyclonus-managed"
+
 type TestCaseState struct {
-	Kubernetes kube.IKubernetes
-	Resources  *probe.Resources
-	Policies   []*networkingv1.NetworkPolicy
+	Kubernetes     kube.IKubernetes
+	Resources      *probe.Resources
+	Policies       []*networkingv1.NetworkPolicy
+	AdminPolicies  []*anp.AdminNetworkPolicy
+	BaselinePolicy *anp.BaselineAdminNetworkPolicy
+	// Connections are the long-lived connections which are open, by their String()
+	Connections map[string]*probe.LongLivedConnection
 }
 
+// longLivedConnectionOpenTimeout is how long opening a long-lived connection waits for its first response
+const longLivedConnectionOpenTimeout = 30 * time.Second
+
 func (t *TestCaseState) CreatePolicy(policy *networkingv1.NetworkPolicy) error {
 	// do we already have this policy?
 	for _, kubePol := range t.Policies {
 		if kubePol.Namespace == policy.Namespace && kubePol.Name == policy.Name {
-			return errors.Errorf("cannot create policy %s/%s: already exists", policy.Namespace, policy.Name)
+			return NewSetupInvalidError(errors.Errorf("cannot create policy %s/%s: already exists", policy.Namespace, policy.Name))
 		}
 	}
 	t.Policies = append(t.Policies, policy)
@@ -41,7 +54,7 @@ func (t *TestCaseState) UpdatePolicy(policy *networkingv1.NetworkPolicy) error {
 		}
 	}
 	if !found {
-		return errors.Errorf("cannot update policy %s/%s: not found", policy.Namespace, policy.Name)
+		return NewSetupInvalidError(errors.Errorf("cannot update policy %s/%s: not found", policy.Namespace, policy.Name))
 	}
 
 	t.Policies[index] = policy
@@ -49,6 +62,76 @@ func (t *TestCaseState) UpdatePolicy(policy *networkingv1.NetworkPolicy) error {
 	return err
 }
 
+func (t *TestCaseState) CreateAdminNetworkPolicy(policy *anp.AdminNetworkPolicy) error {
+	for _, adminPolicy := range t.AdminPolicies {
+		if adminPolicy.Name == policy.Name {
+			return NewSetupInvalidError(errors.Errorf("cannot create admin network policy %s: already exists", policy.Name))
+		}
+	}
+	t.AdminPolicies = append(t.AdminPolicies, policy)
+
+	// label a copy, so that the label doesn't leak into the test case
+	labeledPolicy := *policy
+	labeledPolicy.Labels = map[string]string{AdminNetworkPolicyManagedLabel: "true"}
+	for key, value := range policy.Labels {
+		labeledPolicy.Labels[key] = value
+	}
+	_, err := t.Kubernetes.CreateAdminNetworkPolicy(&labeledPolicy)
+	return err
+}
+
+func (t *TestCaseState) DeleteAdminNetworkPolicy(name string) error {
+	var newAdminPolicies []*anp.AdminNetworkPolicy
+	for _, adminPolicy := range t.AdminPolicies {
+		if adminPolicy.Name != name {
+			newAdminPolicies = append(newAdminPolicies, adminPolicy)
+		}
+	}
+	if len(newAdminPolicies) == len(t.AdminPolicies) {
+		return NewSetupInvalidError(errors.Errorf("cannot delete admin network policy %s: not found", name))
+	}
+	t.AdminPolicies = newAdminPolicies
+	return t.Kubernetes.DeleteAdminNetworkPolicy(name)
+}
+
+func (t *TestCaseState) CreateBaselineAdminNetworkPolicy(policy *anp.BaselineAdminNetworkPolicy) error {
+	if t.BaselinePolicy != nil {
+		return NewSetupInvalidError(errors.Errorf("cannot create baseline admin network policy: already exists"))
+	}
+	t.BaselinePolicy = policy
+
+	labeledPolicy := *policy
+	labeledPolicy.Labels = map[string]string{AdminNetworkPolicyManagedLabel: "true"}
+	for key, value := range policy.Labels {
+		labeledPolicy.Labels[key] = value
+	}
+	_, err := t.Kubernetes.CreateBaselineAdminNetworkPolicy(&labeledPolicy)
+	return err
+}
+
+func (t *TestCaseState) DeleteBaselineAdminNetworkPolicy() error {
+	if t.BaselinePolicy == nil {
+		return NewSetupInvalidError(errors.Errorf("cannot delete baseline admin network policy: not found"))
+	}
+	t.BaselinePolicy = nil
+	return t.Kubernetes.DeleteBaselineAdminNetworkPolicy()
+}
+
+// getManagedAdminPolicies returns the names of the AdminNetworkPolicies created by cyclonus
+func getManagedAdminPolicies(kubernetes kube.IKubernetes) ([]string, error) {
+	adminPolicies, err := kubernetes.GetAllAdminNetworkPolicies()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, adminPolicy := range adminPolicies {
+		if _, ok := adminPolicy.Labels[AdminNetworkPolicyManagedLabel]; ok {
+			names = append(names, adminPolicy.Name)
+		}
+	}
+	return names, nil
+}
+
 func (t *TestCaseState) CreateNamespace(ns string, labels map[string]string) error {
 	newResources, err := t.Resources.CreateNamespace(ns, labels)
 	if err != nil {
@@ -78,8 +161,8 @@ func (t *TestCaseState) DeleteNamespace(ns string) error {
 	return t.Kubernetes.DeleteNamespace(ns)
 }
 
-func (t *TestCaseState) CreatePod(ns string, pod string, labels map[string]string) error {
-	newResources, err := t.Resources.CreatePod(ns, pod, labels)
+func (t *TestCaseState) CreatePod(ns string, pod string, labels map[string]string, portNames map[string]string) error {
+	newResources, err := t.Resources.CreatePod(ns, pod, labels, portNames)
 	if err != nil {
 		return err
 	}
@@ -104,7 +187,9 @@ func (t *TestCaseState) CreatePod(ns string, pod string, labels map[string]strin
 		}
 		if kubePod.Status.Phase == "Running" && kubePod.Status.PodIP != "" {
 			newPod.IP = kubePod.Status.PodIP
-			return nil
+			newPod.FamilyIPs = probe.FamilyIPs(kubePod.Status.PodIPs)
+			newPod.SecondaryIPs, err = probe.SecondaryNetworkIPs(kubePod.Annotations)
+			return err
 		}
 		time.Sleep(5 * time.Second)
 	}
@@ -138,13 +223,80 @@ func (t *TestCaseState) DeletePod(ns string, pod string) error {
 	return t.Kubernetes.DeletePod(ns, pod)
 }
 
+func (t *TestCaseState) OpenConnection(fromNs string, fromPod string, toNs string, toPod string, port int) error {
+	from, err := t.Resources.GetPod(fromNs, fromPod)
+	if err != nil {
+		return NewSetupInvalidError(err)
+	}
+	to, err := t.Resources.GetPod(toNs, toPod)
+	if err != nil {
+		return NewSetupInvalidError(err)
+	}
+	if !to.IsServingPortProtocol(port, v1.ProtocolTCP) {
+		return NewSetupInvalidError(errors.Errorf("cannot open connection to %s/%s: not serving TCP on port %d", toNs, toPod, port))
+	}
+	connection := probe.NewLongLivedConnection(from, to, port)
+	if _, ok := t.Connections[connection.String()]; ok {
+		return NewSetupInvalidError(errors.Errorf("cannot open connection %s: already open", connection.String()))
+	}
+	if err := connection.Open(t.Kubernetes, longLivedConnectionOpenTimeout); err != nil {
+		return err
+	}
+	if t.Connections == nil {
+		t.Connections = map[string]*probe.LongLivedConnection{}
+	}
+	t.Connections[connection.String()] = connection
+	return nil
+}
+
+func (t *TestCaseState) CloseConnection(fromNs string, fromPod string, toNs string, toPod string, port int) (*probe.LongLivedConnectionResult, error) {
+	key := fmt.Sprintf("%s/%s -> %s/%s:%d", fromNs, fromPod, toNs, toPod, port)
+	connection, ok := t.Connections[key]
+	if !ok {
+		return nil, NewSetupInvalidError(errors.Errorf("cannot close connection %s: not open", key))
+	}
+	delete(t.Connections, key)
+	return connection.Close(t.Kubernetes)
+}
+
+// CloseAllConnections closes the connections a test case left open, i.e. because it hit an error; their results are
+// thrown away
+func (t *TestCaseState) CloseAllConnections() {
+	for key, connection := range t.Connections {
+		if _, err := connection.Close(t.Kubernetes); err != nil {
+			logrus.Warnf("unable to close connection %s: %+v", key, err)
+		}
+		delete(t.Connections, key)
+	}
+}
+
+// ReadPolicies picks up the NetworkPolicies already in the namespaces, and the cluster's AdminNetworkPolicies and
+// BaselineAdminNetworkPolicy, which aren't namespaced, so that expected connectivity takes them into account
 func (t *TestCaseState) ReadPolicies(namespaces []string) error {
 	policies, err := kube.GetNetworkPoliciesInNamespaces(t.Kubernetes, namespaces)
 	if err != nil {
 		return err
 	}
 	t.Policies = append(t.Policies, getSliceOfPointers(policies)...)
-	return nil
+
+	adminPolicies, err := t.Kubernetes.GetAllAdminNetworkPolicies()
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, adminPolicy := range t.AdminPolicies {
+		known[adminPolicy.Name] = true
+	}
+	for i := range adminPolicies {
+		if !known[adminPolicies[i].Name] {
+			t.AdminPolicies = append(t.AdminPolicies, &adminPolicies[i])
+		}
+	}
+
+	if t.BaselinePolicy == nil {
+		t.BaselinePolicy, err = t.Kubernetes.GetBaselineAdminNetworkPolicy()
+	}
+	return err
 }
 
 func (t *TestCaseState) DeletePolicy(ns string, name string) error {
@@ -158,7 +310,7 @@ func (t *TestCaseState) DeletePolicy(ns string, name string) error {
 		}
 	}
 	if !found {
-		return errors.Errorf("cannot delete policy %s/%s: not found", ns, name)
+		return NewSetupInvalidError(errors.Errorf("cannot delete policy %s/%s: not found", ns, name))
 	}
 
 	var newPolicies []*networkingv1.NetworkPolicy
@@ -311,9 +463,40 @@ func (t *TestCaseState) ResetClusterState() error {
 		return err
 	}
 
+	err = DeleteManagedAdminNetworkPolicies(t.Kubernetes)
+	if err != nil {
+		return err
+	}
+
 	return t.resetLabelsInKubeHelper()
 }
 
+// DeleteManagedAdminNetworkPolicies deletes the AdminNetworkPolicies, and BaselineAdminNetworkPolicy, created by
+// cyclonus, leaving any others alone
+func DeleteManagedAdminNetworkPolicies(kubernetes kube.IKubernetes) error {
+	adminPolicies, err := getManagedAdminPolicies(kubernetes)
+	if err != nil {
+		return err
+	}
+	for _, name := range adminPolicies {
+		err = kubernetes.DeleteAdminNetworkPolicy(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	baselinePolicy, err := kubernetes.GetBaselineAdminNetworkPolicy()
+	if err != nil {
+		return err
+	}
+	if baselinePolicy != nil {
+		if _, ok := baselinePolicy.Labels[AdminNetworkPolicyManagedLabel]; ok {
+			return kubernetes.DeleteBaselineAdminNetworkPolicy()
+		}
+	}
+	return nil
+}
+
 func (t *TestCaseState) VerifyClusterState() error {
 	err := t.verifyClusterStateHelper()
 	if err != nil {
@@ -327,5 +510,13 @@ func (t *TestCaseState) VerifyClusterState() error {
 	if len(policies) > 0 {
 		return errors.Errorf("expected 0 policies in namespaces %+v, found %d", t.Resources.NamespacesSlice(), len(policies))
 	}
+
+	adminPolicies, err := getManagedAdminPolicies(t.Kubernetes)
+	if err != nil {
+		return err
+	}
+	if len(adminPolicies) > 0 {
+		return errors.Errorf("expected 0 cyclonus-managed admin network policies, found %+v", adminPolicies)
+	}
 	return nil
 }
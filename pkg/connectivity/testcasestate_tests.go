@@ -2,8 +2,13 @@ package connectivity
 
 import (
 	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type buildLabelDiffCase struct {
@@ -19,6 +24,65 @@ type labelDiffMethodsCase struct {
 }
 
 func RunTestCaseStateTests() {
+	Describe("ReadPolicies", func() {
+		It("should read the cluster's AdminNetworkPolicies and BaselineAdminNetworkPolicy along with NetworkPolicies", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			_, err := kubernetes.CreateNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "x"}})
+			Expect(err).To(Succeed())
+			_, err = kubernetes.CreateNetworkPolicy(&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "deny-all"}})
+			Expect(err).To(Succeed())
+			_, err = kubernetes.CreateAdminNetworkPolicy(&anp.AdminNetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "existing"}})
+			Expect(err).To(Succeed())
+			_, err = kubernetes.CreateBaselineAdminNetworkPolicy(&anp.BaselineAdminNetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: anp.BaselineAdminNetworkPolicyName}})
+			Expect(err).To(Succeed())
+
+			state := &TestCaseState{Kubernetes: kubernetes}
+			Expect(state.CreateAdminNetworkPolicy(&anp.AdminNetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "created"}})).To(Succeed())
+			Expect(state.ReadPolicies([]string{"x"})).To(Succeed())
+
+			Expect(state.Policies).To(HaveLen(1))
+			var names []string
+			for _, adminPolicy := range state.AdminPolicies {
+				names = append(names, adminPolicy.Name)
+			}
+			Expect(names).To(ConsistOf("created", "existing"))
+			Expect(state.BaselinePolicy).NotTo(BeNil())
+		})
+
+		It("should track the BaselineAdminNetworkPolicy it creates and deletes", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			state := &TestCaseState{Kubernetes: kubernetes}
+			baseline := &anp.BaselineAdminNetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: anp.BaselineAdminNetworkPolicyName}}
+
+			Expect(state.CreateBaselineAdminNetworkPolicy(baseline)).To(Succeed())
+			Expect(state.BaselinePolicy).To(Equal(baseline))
+			Expect(kubernetes.BaselinePolicy.Labels).To(HaveKey(AdminNetworkPolicyManagedLabel))
+			Expect(state.CreateBaselineAdminNetworkPolicy(baseline)).NotTo(Succeed())
+
+			Expect(state.DeleteBaselineAdminNetworkPolicy()).To(Succeed())
+			Expect(state.BaselinePolicy).To(BeNil())
+			Expect(kubernetes.BaselinePolicy).To(BeNil())
+			Expect(state.DeleteBaselineAdminNetworkPolicy()).NotTo(Succeed())
+		})
+	})
+
+	Describe("DeleteManagedAdminNetworkPolicies", func() {
+		It("should only delete the admin network policies cyclonus created", func() {
+			kubernetes := kube.NewMockKubernetes(1.0)
+			_, err := kubernetes.CreateAdminNetworkPolicy(&anp.AdminNetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "existing"}})
+			Expect(err).To(Succeed())
+
+			state := &TestCaseState{Kubernetes: kubernetes}
+			Expect(state.CreateAdminNetworkPolicy(&anp.AdminNetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "created"}})).To(Succeed())
+			Expect(state.CreateBaselineAdminNetworkPolicy(&anp.BaselineAdminNetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: anp.BaselineAdminNetworkPolicyName}})).To(Succeed())
+
+			Expect(DeleteManagedAdminNetworkPolicies(kubernetes)).To(Succeed())
+			Expect(kubernetes.AdminPolicies).To(HaveLen(1))
+			Expect(kubernetes.AdminPolicies).To(HaveKey("existing"))
+			Expect(kubernetes.BaselinePolicy).To(BeNil())
+		})
+	})
+
 	Describe("LabelDiff", func() {
 		empty := map[string]string{}
 		ab := map[string]string{"a": "b"}
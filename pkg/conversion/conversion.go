@@ -0,0 +1,422 @@
+package conversion
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sort"
+	"strings"
+)
+
+const (
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+
+	// MaxPriority is the highest -- that is, lowest precedence -- priority an AdminNetworkPolicy may have
+	MaxPriority = 1000
+
+	maxPort = 65535
+)
+
+// Issue is a construct of a NetworkPolicy which AdminNetworkPolicies can't express, and which was dropped or
+// approximated
+type Issue struct {
+	Policy string
+	Rule   string
+	Reason string
+}
+
+// Conversion is a set of NetworkPolicies converted to AdminNetworkPolicies and, if their isolation can be expressed
+// as one, a BaselineAdminNetworkPolicy
+type Conversion struct {
+	AdminPolicies  []*anp.AdminNetworkPolicy
+	BaselinePolicy *anp.BaselineAdminNetworkPolicy
+	Issues         []*Issue
+}
+
+func (c *Conversion) addIssue(policy *networkingv1.NetworkPolicy, rule string, reason string, args ...interface{}) {
+	c.Issues = append(c.Issues, &Issue{Policy: policyName(policy), Rule: rule, Reason: fmt.Sprintf(reason, args...)})
+}
+
+func policyName(policy *networkingv1.NetworkPolicy) string {
+	return fmt.Sprintf("%s/%s", policyNamespace(policy), policy.Name)
+}
+
+func policyNamespace(policy *networkingv1.NetworkPolicy) string {
+	if policy.Namespace == "" {
+		return v1.NamespaceDefault
+	}
+	return policy.Namespace
+}
+
+func isolates(policy *networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	for _, t := range policy.Spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert builds an AdminNetworkPolicy for each of policies, in order of namespace and name, with an Allow rule for
+// each of its rules; their priorities count up from priority.  A NetworkPolicy's isolation -- denying its pods'
+// traffic which no NetworkPolicy allows -- is expressed as Deny rules, which must come after all the Allow rules:
+//   - if every isolating policy selects whole namespaces, and the namespaces isolated for ingress and for egress are
+//     the same -- or only one direction is isolated -- by a BaselineAdminNetworkPolicy
+//   - otherwise, by an AdminNetworkPolicy per isolating policy, after all the others
+//
+// AdminNetworkPolicy peers are only namespaces and pods, so ipBlock peers are dropped, and isolation doesn't extend
+// to traffic from or to outside the cluster; these are reported as issues.
+func Convert(policies []*networkingv1.NetworkPolicy, priority int32) (*Conversion, error) {
+	sorted := append([]*networkingv1.NetworkPolicy{}, policies...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return policyName(sorted[i]) < policyName(sorted[j])
+	})
+
+	conversion := &Conversion{}
+	first := priority
+	for _, policy := range sorted {
+		if adminPolicy := conversion.convertRules(policy); adminPolicy != nil {
+			adminPolicy.Spec.Priority = priority
+			conversion.AdminPolicies = append(conversion.AdminPolicies, adminPolicy)
+			priority++
+		}
+		for _, policyType := range []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress} {
+			if isolates(policy, policyType) {
+				direction, outside := "ingress", "from"
+				if policyType == networkingv1.PolicyTypeEgress {
+					direction, outside = "egress", "to"
+				}
+				conversion.addIssue(policy, direction+" isolation", "traffic %s outside the cluster isn't denied: AdminNetworkPolicies can't select it", outside)
+			}
+		}
+	}
+
+	// every NetworkPolicy isolates its pods, for each of its policy types
+	if baseline := buildBaselineIsolation(sorted); baseline != nil {
+		conversion.BaselinePolicy = baseline
+	} else {
+		for _, policy := range sorted {
+			conversion.AdminPolicies = append(conversion.AdminPolicies, buildIsolation(policy, priority))
+			priority++
+		}
+	}
+	if priority-1 > MaxPriority {
+		return nil, errors.Errorf("%d AdminNetworkPolicies don't fit in priorities %d to %d", len(conversion.AdminPolicies), first, MaxPriority)
+	}
+	return conversion, nil
+}
+
+func newAdminPolicy(name string, policy *networkingv1.NetworkPolicy) *anp.AdminNetworkPolicy {
+	return &anp.AdminNetworkPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: anp.GroupVersion.String(), Kind: anp.AdminNetworkPolicyKind},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: anp.AdminNetworkPolicySpec{
+			Subject: anp.AdminNetworkPolicySubject{Pods: &anp.NamespacedPod{
+				NamespaceSelector: namespaceNameSelector(policyNamespace(policy)),
+				PodSelector:       policy.Spec.PodSelector,
+			}},
+		},
+	}
+}
+
+func namespaceNameSelector(namespace string) metav1.LabelSelector {
+	return metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: namespace}}
+}
+
+// convertRules builds an AdminNetworkPolicy with an Allow rule for each of policy's rules, or nil if it has none
+func (c *Conversion) convertRules(policy *networkingv1.NetworkPolicy) *anp.AdminNetworkPolicy {
+	adminPolicy := newAdminPolicy(fmt.Sprintf("%s.%s", policyNamespace(policy), policy.Name), policy)
+	if isolates(policy, networkingv1.PolicyTypeIngress) {
+		for i, rule := range policy.Spec.Ingress {
+			name := fmt.Sprintf("ingress-rule-%d", i+1)
+			if peers, ok := c.convertPeers(policy, name, rule.From); ok {
+				adminPolicy.Spec.Ingress = append(adminPolicy.Spec.Ingress, anp.AdminNetworkPolicyIngressRule{
+					Name:   name,
+					Action: anp.AdminNetworkPolicyRuleActionAllow,
+					From:   peers,
+					Ports:  convertPorts(rule.Ports),
+				})
+			}
+		}
+	}
+	if isolates(policy, networkingv1.PolicyTypeEgress) {
+		for i, rule := range policy.Spec.Egress {
+			name := fmt.Sprintf("egress-rule-%d", i+1)
+			if peers, ok := c.convertPeers(policy, name, rule.To); ok {
+				adminPolicy.Spec.Egress = append(adminPolicy.Spec.Egress, anp.AdminNetworkPolicyEgressRule{
+					Name:   name,
+					Action: anp.AdminNetworkPolicyRuleActionAllow,
+					To:     peers,
+					Ports:  convertPorts(rule.Ports),
+				})
+			}
+		}
+	}
+	if len(adminPolicy.Spec.Ingress) == 0 && len(adminPolicy.Spec.Egress) == 0 {
+		return nil
+	}
+	return adminPolicy
+}
+
+// convertPeers: a rule without peers matches all pods; ipBlock peers are dropped.  If all of a rule's peers are
+// dropped, so is the rule, which ok is false for.
+func (c *Conversion) convertPeers(policy *networkingv1.NetworkPolicy, rule string, peers []networkingv1.NetworkPolicyPeer) ([]anp.AdminNetworkPolicyPeer, bool) {
+	if len(peers) == 0 {
+		return []anp.AdminNetworkPolicyPeer{{Namespaces: &metav1.LabelSelector{}}}, true
+	}
+	var adminPeers []anp.AdminNetworkPolicyPeer
+	for _, peer := range peers {
+		switch {
+		case peer.IPBlock != nil:
+			c.addIssue(policy, rule, "ipBlock %s is dropped: AdminNetworkPolicy peers are only namespaces and pods", peer.IPBlock.CIDR)
+		case peer.PodSelector == nil && peer.NamespaceSelector == nil:
+			c.addIssue(policy, rule, "empty peer is dropped")
+		case peer.PodSelector == nil:
+			selector := *peer.NamespaceSelector
+			adminPeers = append(adminPeers, anp.AdminNetworkPolicyPeer{Namespaces: &selector})
+		default:
+			namespaces := namespaceNameSelector(policyNamespace(policy))
+			if peer.NamespaceSelector != nil {
+				namespaces = *peer.NamespaceSelector
+			}
+			adminPeers = append(adminPeers, anp.AdminNetworkPolicyPeer{Pods: &anp.NamespacedPod{NamespaceSelector: namespaces, PodSelector: *peer.PodSelector}})
+		}
+	}
+	if len(adminPeers) == 0 {
+		c.addIssue(policy, rule, "rule is dropped, since all of its peers are")
+		return nil, false
+	}
+	return adminPeers, true
+}
+
+// convertPorts: a port without a number is every port of its protocol, which is a range of all ports
+func convertPorts(ports []networkingv1.NetworkPolicyPort) *[]anp.AdminNetworkPolicyPort {
+	if len(ports) == 0 {
+		return nil
+	}
+	var adminPorts []anp.AdminNetworkPolicyPort
+	for _, port := range ports {
+		protocol := v1.ProtocolTCP
+		if port.Protocol != nil {
+			protocol = *port.Protocol
+		}
+		switch {
+		case port.Port == nil:
+			adminPorts = append(adminPorts, anp.AdminNetworkPolicyPort{PortRange: &anp.PortRange{Protocol: protocol, Start: 1, End: maxPort}})
+		case port.Port.Type == intstr.String:
+			name := port.Port.StrVal
+			adminPorts = append(adminPorts, anp.AdminNetworkPolicyPort{NamedPort: &name})
+		case port.EndPort != nil:
+			adminPorts = append(adminPorts, anp.AdminNetworkPolicyPort{PortRange: &anp.PortRange{Protocol: protocol, Start: port.Port.IntVal, End: *port.EndPort}})
+		default:
+			adminPorts = append(adminPorts, anp.AdminNetworkPolicyPort{PortNumber: &anp.Port{Protocol: protocol, Port: port.Port.IntVal}})
+		}
+	}
+	return &adminPorts
+}
+
+// isolationPeers deny traffic from, or to, every pod
+var isolationPeers = []anp.AdminNetworkPolicyPeer{{Namespaces: &metav1.LabelSelector{}}}
+
+// buildIsolation is an AdminNetworkPolicy denying the traffic of policy's pods, for each direction it isolates
+func buildIsolation(policy *networkingv1.NetworkPolicy, priority int32) *anp.AdminNetworkPolicy {
+	adminPolicy := newAdminPolicy(fmt.Sprintf("%s.%s.isolation", policyNamespace(policy), policy.Name), policy)
+	adminPolicy.Spec.Priority = priority
+	if isolates(policy, networkingv1.PolicyTypeIngress) {
+		adminPolicy.Spec.Ingress = []anp.AdminNetworkPolicyIngressRule{{Name: "isolate-ingress", Action: anp.AdminNetworkPolicyRuleActionDeny, From: isolationPeers}}
+	}
+	if isolates(policy, networkingv1.PolicyTypeEgress) {
+		adminPolicy.Spec.Egress = []anp.AdminNetworkPolicyEgressRule{{Name: "isolate-egress", Action: anp.AdminNetworkPolicyRuleActionDeny, To: isolationPeers}}
+	}
+	return adminPolicy
+}
+
+// buildBaselineIsolation is a BaselineAdminNetworkPolicy expressing the isolation of all of policies, or nil if
+// it can't: it has a single subject, so the isolated pods must be the same for both directions, and whole namespaces
+// -- which a single selector can pick out by name
+func buildBaselineIsolation(policies []*networkingv1.NetworkPolicy) *anp.BaselineAdminNetworkPolicy {
+	isolated := map[networkingv1.PolicyType]map[string]bool{networkingv1.PolicyTypeIngress: {}, networkingv1.PolicyTypeEgress: {}}
+	for _, policy := range policies {
+		if !kube.IsLabelSelectorEmpty(policy.Spec.PodSelector) {
+			return nil
+		}
+		for policyType, namespaces := range isolated {
+			if isolates(policy, policyType) {
+				namespaces[policyNamespace(policy)] = true
+			}
+		}
+	}
+	ingress, egress := sortedKeys(isolated[networkingv1.PolicyTypeIngress]), sortedKeys(isolated[networkingv1.PolicyTypeEgress])
+	namespaces := ingress
+	if len(ingress) == 0 {
+		namespaces = egress
+	} else if len(egress) > 0 && strings.Join(ingress, ",") != strings.Join(egress, ",") {
+		return nil
+	}
+	if len(namespaces) == 0 {
+		return nil
+	}
+
+	selector := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: namespaceNameLabel, Operator: metav1.LabelSelectorOpIn, Values: namespaces},
+	}}
+	baseline := &anp.BaselineAdminNetworkPolicy{
+		TypeMeta:   metav1.TypeMeta{APIVersion: anp.GroupVersion.String(), Kind: anp.BaselineAdminNetworkPolicyKind},
+		ObjectMeta: metav1.ObjectMeta{Name: anp.BaselineAdminNetworkPolicyName},
+		Spec:       anp.BaselineAdminNetworkPolicySpec{Subject: anp.AdminNetworkPolicySubject{Namespaces: selector}},
+	}
+	if len(ingress) > 0 {
+		baseline.Spec.Ingress = []anp.BaselineAdminNetworkPolicyIngressRule{{Name: "isolate-ingress", Action: anp.BaselineAdminNetworkPolicyRuleActionDeny, From: isolationPeers}}
+	}
+	if len(egress) > 0 {
+		baseline.Spec.Egress = []anp.BaselineAdminNetworkPolicyEgressRule{{Name: "isolate-egress", Action: anp.BaselineAdminNetworkPolicyRuleActionDeny, To: isolationPeers}}
+	}
+	return baseline
+}
+
+func sortedKeys(set map[string]bool) []string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// IssuesTable lists the constructs which couldn't be expressed
+func (c *Conversion) IssuesTable() string {
+	str := &strings.Builder{}
+	if len(c.Issues) > 0 {
+		table := tablewriter.NewWriter(str)
+		table.SetAutoWrapText(false)
+		table.SetHeader([]string{"Policy", "Rule", "Issue"})
+		for _, issue := range c.Issues {
+			table.Append([]string{issue.Policy, issue.Rule, issue.Reason})
+		}
+		table.Render()
+	}
+	str.WriteString(fmt.Sprintf("%d constructs couldn't be expressed exactly\n", len(c.Issues)))
+	return str.String()
+}
+
+// Verify simulates probes between all of resources' pods, on all their ports, against policies and their
+// conversion, to find traffic which they decide differently
+func Verify(policies []*networkingv1.NetworkPolicy, conversion *Conversion, resources *probe.Resources) *probe.SimulatedDiff {
+	converted := matcher.NewPolicy()
+	converted.AddAdminPolicies(matcher.BuildAdminNetworkPolicies(conversion.AdminPolicies))
+	if conversion.BaselinePolicy != nil {
+		converted.BaselinePolicy = matcher.BuildBaselineAdminNetworkPolicy(conversion.BaselinePolicy)
+	}
+	return probe.NewSimulatedDiff(matcher.BuildNetworkPolicies(true, policies), converted, resources)
+}
+
+// SampleResources models pods to verify a conversion with, when there aren't real ones to: in each namespace -- the
+// policies', one labelled for each namespace selector, and one labelled for none -- a pod labelled for each pod
+// selector, and one labelled for none.  Every pod serves every port the policies name, and TCP/80.  Only selectors'
+// matchLabels are modeled, not their matchExpressions.
+func SampleResources(policies []*networkingv1.NetworkPolicy) *probe.Resources {
+	var namespaces []string
+	var namespaceLabels, podLabels []map[string]string
+	seen := map[string]bool{}
+	addLabels := func(labelSets *[]map[string]string, kind string, selector *metav1.LabelSelector) {
+		if selector == nil {
+			return
+		}
+		key := kind + "/" + labels.Set(selector.MatchLabels).String()
+		if !seen[key] {
+			seen[key] = true
+			*labelSets = append(*labelSets, selector.MatchLabels)
+		}
+	}
+	containers := []*probe.Container{probe.NewDefaultContainer(80, v1.ProtocolTCP, false)}
+	addContainer := func(port int, protocol v1.Protocol, name string) {
+		key := fmt.Sprintf("port/%s/%d/%s", protocol, port, name)
+		if !seen[key] {
+			seen[key] = true
+			container := probe.NewDefaultContainer(port, protocol, false)
+			if name != "" {
+				container.Name, container.PortName = fmt.Sprintf("cont-%s-%s", name, strings.ToLower(string(protocol))), name
+			}
+			containers = append(containers, container)
+		}
+	}
+	seen["port/TCP/80/"] = true
+	addPorts := func(ports []networkingv1.NetworkPolicyPort) {
+		for _, port := range ports {
+			protocol := v1.ProtocolTCP
+			if port.Protocol != nil {
+				protocol = *port.Protocol
+			}
+			switch {
+			case port.Port == nil:
+				addContainer(80, protocol, "")
+			case port.Port.Type == intstr.String:
+				// named ports are served on ports no rule names by number
+				addContainer(40000+len(containers), protocol, port.Port.StrVal)
+			default:
+				addContainer(int(port.Port.IntVal), protocol, "")
+				if port.EndPort != nil {
+					addContainer(int(*port.EndPort), protocol, "")
+				}
+			}
+		}
+	}
+	addPeers := func(peers []networkingv1.NetworkPolicyPeer) {
+		for _, peer := range peers {
+			addLabels(&namespaceLabels, "namespace", peer.NamespaceSelector)
+			addLabels(&podLabels, "pod", peer.PodSelector)
+		}
+	}
+
+	addLabels(&podLabels, "pod", &metav1.LabelSelector{})
+	for _, policy := range policies {
+		if !seen["namespace:"+policyNamespace(policy)] {
+			seen["namespace:"+policyNamespace(policy)] = true
+			namespaces = append(namespaces, policyNamespace(policy))
+		}
+		addLabels(&podLabels, "pod", &policy.Spec.PodSelector)
+		for _, rule := range policy.Spec.Ingress {
+			addPeers(rule.From)
+			addPorts(rule.Ports)
+		}
+		for _, rule := range policy.Spec.Egress {
+			addPeers(rule.To)
+			addPorts(rule.Ports)
+		}
+	}
+
+	resources := &probe.Resources{Namespaces: map[string]map[string]string{}}
+	for _, namespace := range namespaces {
+		resources.Namespaces[namespace] = map[string]string{namespaceNameLabel: namespace}
+	}
+	for i, nsLabels := range append(namespaceLabels, map[string]string{}) {
+		namespace := fmt.Sprintf("sample-%d", i+1)
+		resources.Namespaces[namespace] = map[string]string{namespaceNameLabel: namespace}
+		for key, value := range nsLabels {
+			resources.Namespaces[namespace][key] = value
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	for _, namespace := range namespaces {
+		for i, labelSet := range podLabels {
+			ip := len(resources.Pods)
+			resources.Pods = append(resources.Pods, &probe.Pod{
+				Namespace:  namespace,
+				Name:       fmt.Sprintf("pod-%d", i+1),
+				Labels:     labelSet,
+				IP:         fmt.Sprintf("100.64.%d.%d", ip/250, ip%250+1),
+				Containers: containers,
+			})
+		}
+	}
+	return resources
+}
@@ -0,0 +1,128 @@
+package conversion
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func RunConversionTests() {
+	ingress := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	tcp, udp := v1.ProtocolTCP, v1.ProtocolUDP
+	port80 := intstr.FromInt(80)
+
+	// allowA allows ingress to x/a from x/b on TCP/80, and from namespaces labelled ns: y
+	allowA := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "allow-a"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"pod": "a"}},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pod": "b"}}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}},
+				},
+				{From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "y"}}}}},
+			},
+			PolicyTypes: ingress,
+		},
+	}
+	denyAll := func(namespace string, policyTypes []networkingv1.PolicyType) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "deny-all"},
+			Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: policyTypes},
+		}
+	}
+
+	Describe("Convert", func() {
+		It("Should convert rules to Allow rules, and isolation to Deny rules after them", func() {
+			policies := []*networkingv1.NetworkPolicy{denyAll("y", []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}), allowA}
+			conversion, err := Convert(policies, 100)
+			Expect(err).To(Succeed())
+
+			Expect(conversion.BaselinePolicy).To(BeNil())
+			Expect(conversion.AdminPolicies).To(HaveLen(3))
+			allow := conversion.AdminPolicies[0]
+			Expect(allow.Name).To(Equal("x.allow-a"))
+			Expect(allow.Spec.Priority).To(Equal(int32(100)))
+			Expect(allow.Spec.Subject.Pods.NamespaceSelector).To(Equal(metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "x"}}))
+			Expect(allow.Spec.Ingress).To(HaveLen(2))
+			Expect(allow.Spec.Ingress[0].From[0].Pods.NamespaceSelector.MatchLabels).To(Equal(map[string]string{namespaceNameLabel: "x"}))
+			Expect(*allow.Spec.Ingress[0].Ports).To(Equal([]anp.AdminNetworkPolicyPort{{PortNumber: &anp.Port{Protocol: tcp, Port: 80}}}))
+			Expect(allow.Spec.Ingress[1].From[0].Namespaces.MatchLabels).To(Equal(map[string]string{"ns": "y"}))
+			Expect(allow.Spec.Ingress[1].Ports).To(BeNil())
+
+			Expect(conversion.AdminPolicies[1].Name).To(Equal("x.allow-a.isolation"))
+			Expect(conversion.AdminPolicies[1].Spec.Egress).To(BeEmpty())
+			Expect(conversion.AdminPolicies[2].Name).To(Equal("y.deny-all.isolation"))
+			Expect(conversion.AdminPolicies[2].Spec.Priority).To(Equal(int32(102)))
+			Expect(conversion.AdminPolicies[2].Spec.Egress[0].Action).To(Equal(anp.AdminNetworkPolicyRuleActionDeny))
+			Expect(conversion.Issues).To(HaveLen(3))
+
+			diff := Verify(policies, conversion, SampleResources(policies))
+			Expect(diff.Probes).To(BeNumerically(">", 0))
+			Expect(diff.Changes).To(BeEmpty())
+		})
+
+		It("Should express isolation of whole namespaces as a BaselineAdminNetworkPolicy", func() {
+			allowAll := denyAll("x", ingress)
+			allowAll.Name = "allow-from-y"
+			allowAll.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{allowA.Spec.Ingress[1]}
+			policies := []*networkingv1.NetworkPolicy{allowAll, denyAll("z", ingress)}
+			conversion, err := Convert(policies, 1)
+			Expect(err).To(Succeed())
+
+			Expect(conversion.AdminPolicies).To(HaveLen(1))
+			baseline := conversion.BaselinePolicy
+			Expect(baseline.Name).To(Equal(anp.BaselineAdminNetworkPolicyName))
+			Expect(baseline.Spec.Subject.Namespaces.MatchExpressions[0].Values).To(Equal([]string{"x", "z"}))
+			Expect(baseline.Spec.Ingress).To(HaveLen(1))
+			Expect(baseline.Spec.Egress).To(BeEmpty())
+
+			Expect(Verify(policies, conversion, SampleResources(policies)).Changes).To(BeEmpty())
+
+			// namespaces isolated for ingress and for egress differ, so the BaselineAdminNetworkPolicy can't be used
+			conversion, err = Convert(append(policies, denyAll("w", []networkingv1.PolicyType{networkingv1.PolicyTypeEgress})), 1)
+			Expect(err).To(Succeed())
+			Expect(conversion.BaselinePolicy).To(BeNil())
+			Expect(conversion.AdminPolicies).To(HaveLen(4))
+		})
+
+		It("Should drop ipBlocks, and convert ports without numbers, with end ports, and named", func() {
+			endPort := int32(90)
+			named := intstr.FromString("dns")
+			policy := denyAll("x", []networkingv1.PolicyType{networkingv1.PolicyTypeEgress})
+			policy.Spec.Egress = []networkingv1.NetworkPolicyEgressRule{
+				{To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}}}},
+				{Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp}, {Port: &port80, EndPort: &endPort}, {Protocol: &udp, Port: &named}}},
+			}
+			conversion, err := Convert([]*networkingv1.NetworkPolicy{policy}, 1)
+			Expect(err).To(Succeed())
+
+			Expect(conversion.Issues).To(HaveLen(3))
+			Expect(conversion.Issues[0].Reason).To(ContainSubstring("ipBlock 10.0.0.0/8 is dropped"))
+			Expect(conversion.Issues[1].Reason).To(Equal("rule is dropped, since all of its peers are"))
+			Expect(conversion.IssuesTable()).To(ContainSubstring("3 constructs couldn't be expressed exactly"))
+
+			egress := conversion.AdminPolicies[0].Spec.Egress
+			Expect(egress).To(HaveLen(1))
+			Expect(egress[0].Name).To(Equal("egress-rule-2"))
+			Expect(egress[0].To).To(Equal([]anp.AdminNetworkPolicyPeer{{Namespaces: &metav1.LabelSelector{}}}))
+			dns := "dns"
+			Expect(*egress[0].Ports).To(Equal([]anp.AdminNetworkPolicyPort{
+				{PortRange: &anp.PortRange{Protocol: udp, Start: 1, End: 65535}},
+				{PortRange: &anp.PortRange{Protocol: tcp, Start: 80, End: 90}},
+				{NamedPort: &dns},
+			}))
+			Expect(Verify([]*networkingv1.NetworkPolicy{policy}, conversion, SampleResources([]*networkingv1.NetworkPolicy{policy})).Changes).To(BeEmpty())
+		})
+
+		It("Should fail if the policies don't fit in AdminNetworkPolicy priorities", func() {
+			_, err := Convert([]*networkingv1.NetworkPolicy{allowA}, MaxPriority)
+			Expect(err).To(MatchError("2 AdminNetworkPolicies don't fit in priorities 1000 to 1000"))
+		})
+	})
+}
@@ -0,0 +1,14 @@
+package conversion
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestConversion(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunConversionTests()
+	RunSpecs(t, "network policy conversion suite")
+}
@@ -1,6 +1,9 @@
 package generator
 
-import networkingv1 "k8s.io/api/networking/v1"
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	networkingv1 "k8s.io/api/networking/v1"
+)
 
 // Action: exactly one field must be non-null.  This models a discriminated union (sum type).
 type Action struct {
@@ -17,6 +20,18 @@ type Action struct {
 	CreatePod    *CreatePodAction
 	SetPodLabels *SetPodLabelsAction
 	DeletePod    *DeletePodAction
+
+	CreateAdminNetworkPolicy *CreateAdminNetworkPolicyAction
+	DeleteAdminNetworkPolicy *DeleteAdminNetworkPolicyAction
+
+	CreateBaselineAdminNetworkPolicy *CreateBaselineAdminNetworkPolicyAction
+	DeleteBaselineAdminNetworkPolicy *DeleteBaselineAdminNetworkPolicyAction
+
+	RestartCNI         *RestartCNIAction
+	WaitForCNIRecovery *WaitForCNIRecoveryAction
+
+	OpenConnection  *OpenConnectionAction
+	CloseConnection *CloseConnectionAction
 }
 
 type CreatePolicyAction struct {
@@ -82,6 +97,9 @@ type CreatePodAction struct {
 	Namespace string
 	Pod       string
 	Labels    map[string]string
+	// PortNames renames the new pod's container ports, from their usual names -- i.e. serve-80-tcp -- to new ones,
+	// so that the same name can stand for different ports on different pods
+	PortNames map[string]string `json:",omitempty"`
 }
 
 func CreatePod(namespace string, pod string, labels map[string]string) *Action {
@@ -92,6 +110,15 @@ func CreatePod(namespace string, pod string, labels map[string]string) *Action {
 	}}
 }
 
+func CreatePodWithPortNames(namespace string, pod string, labels map[string]string, portNames map[string]string) *Action {
+	return &Action{CreatePod: &CreatePodAction{
+		Namespace: namespace,
+		Pod:       pod,
+		Labels:    labels,
+		PortNames: portNames,
+	}}
+}
+
 type SetPodLabelsAction struct {
 	Namespace string
 	Pod       string
@@ -117,3 +144,88 @@ func DeletePod(namespace string, pod string) *Action {
 		Pod:       pod,
 	}}
 }
+
+type CreateAdminNetworkPolicyAction struct {
+	Policy *anp.AdminNetworkPolicy
+}
+
+func CreateAdminNetworkPolicy(policy *anp.AdminNetworkPolicy) *Action {
+	return &Action{CreateAdminNetworkPolicy: &CreateAdminNetworkPolicyAction{Policy: policy}}
+}
+
+type DeleteAdminNetworkPolicyAction struct {
+	Name string
+}
+
+func DeleteAdminNetworkPolicy(name string) *Action {
+	return &Action{DeleteAdminNetworkPolicy: &DeleteAdminNetworkPolicyAction{Name: name}}
+}
+
+type CreateBaselineAdminNetworkPolicyAction struct {
+	Policy *anp.BaselineAdminNetworkPolicy
+}
+
+func CreateBaselineAdminNetworkPolicy(policy *anp.BaselineAdminNetworkPolicy) *Action {
+	return &Action{CreateBaselineAdminNetworkPolicy: &CreateBaselineAdminNetworkPolicyAction{Policy: policy}}
+}
+
+// DeleteBaselineAdminNetworkPolicyAction has no name, since there's only ever one BaselineAdminNetworkPolicy
+type DeleteBaselineAdminNetworkPolicyAction struct{}
+
+func DeleteBaselineAdminNetworkPolicy() *Action {
+	return &Action{DeleteBaselineAdminNetworkPolicy: &DeleteBaselineAdminNetworkPolicyAction{}}
+}
+
+// RestartCNIAction restarts the CNI's pods, without waiting for them to come back.  Which CNI, and on which nodes,
+// is up to the interpreter's configuration.
+type RestartCNIAction struct{}
+
+func RestartCNI() *Action {
+	return &Action{RestartCNI: &RestartCNIAction{}}
+}
+
+type WaitForCNIRecoveryAction struct{}
+
+func WaitForCNIRecovery() *Action {
+	return &Action{WaitForCNIRecovery: &WaitForCNIRecoveryAction{}}
+}
+
+// OpenConnectionAction opens a TCP connection from one pod to another on Port, which is kept open -- with a request
+// made over it every second -- until a CloseConnectionAction for the same pods and port, so that what happens to an
+// established connection, when policies change, can be checked.  The destination pod has to answer HTTP on Port.
+type OpenConnectionAction struct {
+	FromNamespace string
+	FromPod       string
+	ToNamespace   string
+	ToPod         string
+	Port          int
+}
+
+func OpenConnection(fromNamespace string, fromPod string, toNamespace string, toPod string, port int) *Action {
+	return &Action{OpenConnection: &OpenConnectionAction{
+		FromNamespace: fromNamespace,
+		FromPod:       fromPod,
+		ToNamespace:   toNamespace,
+		ToPod:         toPod,
+		Port:          port,
+	}}
+}
+
+// CloseConnectionAction closes a connection opened by an OpenConnectionAction, and records whether it survived
+type CloseConnectionAction struct {
+	FromNamespace string
+	FromPod       string
+	ToNamespace   string
+	ToPod         string
+	Port          int
+}
+
+func CloseConnection(fromNamespace string, fromPod string, toNamespace string, toPod string, port int) *Action {
+	return &Action{CloseConnection: &CloseConnectionAction{
+		FromNamespace: fromNamespace,
+		FromPod:       fromPod,
+		ToNamespace:   toNamespace,
+		ToPod:         toPod,
+		Port:          port,
+	}}
+}
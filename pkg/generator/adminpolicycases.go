@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+var adminPolicyActionTags = map[anp.AdminNetworkPolicyRuleAction]string{
+	anp.AdminNetworkPolicyRuleActionAllow: TagANPAllow,
+	anp.AdminNetworkPolicyRuleActionDeny:  TagANPDeny,
+	anp.AdminNetworkPolicyRuleActionPass:  TagANPPass,
+}
+
+// adminPolicyFromYToX builds an AdminNetworkPolicy applying action to all ingress from namespace y into namespace x
+func adminPolicyFromYToX(priority int32, action anp.AdminNetworkPolicyRuleAction) *anp.AdminNetworkPolicy {
+	return &anp.AdminNetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       anp.AdminNetworkPolicyKind,
+			APIVersion: anp.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s-y-to-x-%d", strings.ToLower(string(action)), priority),
+		},
+		Spec: anp.AdminNetworkPolicySpec{
+			Priority: priority,
+			Subject: anp.AdminNetworkPolicySubject{
+				Namespaces: &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "x"}},
+			},
+			Ingress: []anp.AdminNetworkPolicyIngressRule{{
+				Name:   fmt.Sprintf("%s-from-y", strings.ToLower(string(action))),
+				Action: action,
+				From: []anp.AdminNetworkPolicyPeer{{
+					Namespaces: &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "y"}},
+				}},
+			}},
+		},
+	}
+}
+
+// AdminNetworkPolicyTestCases pits a Pass against an Allow or Deny, for the same traffic, at two different
+// priorities.  Whichever takes precedence decides: the Allow or Deny directly, or the Pass by deferring to a
+// NetworkPolicy which disagrees with the Allow or Deny.  So swapping the priorities flips the verdict.
+func (t *TestCaseGenerator) AdminNetworkPolicyTestCases() []*TestCase {
+	denyAllIngressToX := (&Netpol{
+		Name:    "deny-all-ingress",
+		Target:  &NetpolTarget{Namespace: "x"},
+		Ingress: DenyAll,
+	}).NetworkPolicy()
+	allowAllIngressToX := (&Netpol{
+		Name:    "allow-all-ingress",
+		Target:  &NetpolTarget{Namespace: "x"},
+		Ingress: ExplicitAllowAll,
+	}).NetworkPolicy()
+
+	var cases []*TestCase
+	for _, c := range []struct {
+		Action    anp.AdminNetworkPolicyRuleAction
+		Netpol    *networkingv1.NetworkPolicy
+		NetpolTag string
+	}{
+		{Action: anp.AdminNetworkPolicyRuleActionAllow, Netpol: denyAllIngressToX, NetpolTag: TagDenyAll},
+		{Action: anp.AdminNetworkPolicyRuleActionDeny, Netpol: allowAllIngressToX, NetpolTag: TagAllowAll},
+	} {
+		for _, passFirst := range []bool{true, false} {
+			passPriority, otherPriority, winner := int32(10), int32(20), "Pass"
+			if !passFirst {
+				passPriority, otherPriority, winner = 20, 10, string(c.Action)
+			}
+			cases = append(cases, NewSingleStepTestCase(
+				fmt.Sprintf("AdminNetworkPolicy Pass at priority %d, %s at priority %d, with %s NetworkPolicy: %s wins", passPriority, c.Action, otherPriority, c.NetpolTag, winner),
+				NewStringSet(TagANPPass, adminPolicyActionTags[c.Action], c.NetpolTag, TagIngress),
+				ProbeAllAvailable,
+				CreatePolicy(c.Netpol),
+				CreateAdminNetworkPolicy(adminPolicyFromYToX(passPriority, anp.AdminNetworkPolicyRuleActionPass)),
+				CreateAdminNetworkPolicy(adminPolicyFromYToX(otherPriority, c.Action))))
+		}
+	}
+
+	pass := adminPolicyFromYToX(10, anp.AdminNetworkPolicyRuleActionPass)
+	cases = append(cases, NewTestCase(
+		"Delete a higher-precedence AdminNetworkPolicy Pass, so that a lower-precedence Allow overrides a NetworkPolicy",
+		NewStringSet(TagANPPass, TagANPAllow, TagDenyAll, TagIngress),
+		NewTestStep(ProbeAllAvailable,
+			CreatePolicy(denyAllIngressToX),
+			CreateAdminNetworkPolicy(pass),
+			CreateAdminNetworkPolicy(adminPolicyFromYToX(20, anp.AdminNetworkPolicyRuleActionAllow))),
+		NewTestStep(ProbeAllAvailable, DeleteAdminNetworkPolicy(pass.Name))))
+
+	return cases
+}
@@ -0,0 +1,88 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+var baselinePolicyActionTags = map[anp.BaselineAdminNetworkPolicyRuleAction]string{
+	anp.BaselineAdminNetworkPolicyRuleActionAllow: TagBANPAllow,
+	anp.BaselineAdminNetworkPolicyRuleActionDeny:  TagBANPDeny,
+}
+
+// baselinePolicyFromYToX builds a BaselineAdminNetworkPolicy applying action to all ingress from namespace y into
+// namespace x
+func baselinePolicyFromYToX(action anp.BaselineAdminNetworkPolicyRuleAction) *anp.BaselineAdminNetworkPolicy {
+	return &anp.BaselineAdminNetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       anp.BaselineAdminNetworkPolicyKind,
+			APIVersion: anp.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: anp.BaselineAdminNetworkPolicyName,
+		},
+		Spec: anp.BaselineAdminNetworkPolicySpec{
+			Subject: anp.AdminNetworkPolicySubject{
+				Namespaces: &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "x"}},
+			},
+			Ingress: []anp.BaselineAdminNetworkPolicyIngressRule{{
+				Name:   fmt.Sprintf("%s-from-y", strings.ToLower(string(action))),
+				Action: action,
+				From: []anp.AdminNetworkPolicyPeer{{
+					Namespaces: &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "y"}},
+				}},
+			}},
+		},
+	}
+}
+
+// BaselineAdminNetworkPolicyTestCases check that the BaselineAdminNetworkPolicy only decides traffic which nothing
+// else does: on its own, it decides; a NetworkPolicy selecting the same pods overrides it, whether it allows or
+// denies; an AdminNetworkPolicy Allow overrides it, while a Pass falls through to it.
+func (t *TestCaseGenerator) BaselineAdminNetworkPolicyTestCases() []*TestCase {
+	denyAllIngressToX := (&Netpol{
+		Name:    "deny-all-ingress",
+		Target:  &NetpolTarget{Namespace: "x"},
+		Ingress: DenyAll,
+	}).NetworkPolicy()
+	allowAllIngressToX := (&Netpol{
+		Name:    "allow-all-ingress",
+		Target:  &NetpolTarget{Namespace: "x"},
+		Ingress: ExplicitAllowAll,
+	}).NetworkPolicy()
+	allow := baselinePolicyFromYToX(anp.BaselineAdminNetworkPolicyRuleActionAllow)
+	deny := baselinePolicyFromYToX(anp.BaselineAdminNetworkPolicyRuleActionDeny)
+
+	return []*TestCase{
+		NewSingleStepTestCase("BaselineAdminNetworkPolicy Deny, with nothing else: Deny wins",
+			NewStringSet(TagBANPDeny, TagIngress),
+			ProbeAllAvailable,
+			CreateBaselineAdminNetworkPolicy(deny)),
+		NewSingleStepTestCase("BaselineAdminNetworkPolicy Deny, with allow-all NetworkPolicy: NetworkPolicy wins",
+			NewStringSet(TagBANPDeny, TagAllowAll, TagIngress),
+			ProbeAllAvailable,
+			CreatePolicy(allowAllIngressToX),
+			CreateBaselineAdminNetworkPolicy(deny)),
+		NewSingleStepTestCase("BaselineAdminNetworkPolicy Allow, with deny-all NetworkPolicy: NetworkPolicy wins",
+			NewStringSet(TagBANPAllow, TagDenyAll, TagIngress),
+			ProbeAllAvailable,
+			CreatePolicy(denyAllIngressToX),
+			CreateBaselineAdminNetworkPolicy(allow)),
+		NewSingleStepTestCase("BaselineAdminNetworkPolicy Deny, with AdminNetworkPolicy Allow: Allow wins",
+			NewStringSet(TagBANPDeny, TagANPAllow, TagIngress),
+			ProbeAllAvailable,
+			CreateAdminNetworkPolicy(adminPolicyFromYToX(10, anp.AdminNetworkPolicyRuleActionAllow)),
+			CreateBaselineAdminNetworkPolicy(deny)),
+		NewSingleStepTestCase("BaselineAdminNetworkPolicy Deny, with AdminNetworkPolicy Pass: Deny wins",
+			NewStringSet(TagBANPDeny, TagANPPass, TagIngress),
+			ProbeAllAvailable,
+			CreateAdminNetworkPolicy(adminPolicyFromYToX(10, anp.AdminNetworkPolicyRuleActionPass)),
+			CreateBaselineAdminNetworkPolicy(deny)),
+		NewTestCase("Delete a BaselineAdminNetworkPolicy Deny, so that traffic is allowed again",
+			NewStringSet(TagBANPDeny, TagIngress),
+			NewTestStep(ProbeAllAvailable, CreateBaselineAdminNetworkPolicy(deny)),
+			NewTestStep(ProbeAllAvailable, DeleteBaselineAdminNetworkPolicy())),
+	}
+}
@@ -0,0 +1,31 @@
+package generator
+
+import networkingv1 "k8s.io/api/networking/v1"
+
+// ChaosTestCases restart the CNI while a policy is in place, and check that it's enforced throughout: right after
+// the restart, while the CNI may still be recovering, and once it has recovered
+func (t *TestCaseGenerator) ChaosTestCases() []*TestCase {
+	denyAllIngressToX := (&Netpol{
+		Name:    "deny-all-ingress",
+		Target:  &NetpolTarget{Namespace: "x"},
+		Ingress: DenyAll,
+	}).NetworkPolicy()
+
+	var cases []*TestCase
+	for _, c := range []struct {
+		Description string
+		Tags        []string
+		Policy      *networkingv1.NetworkPolicy
+	}{
+		{Description: "deny all ingress to namespace x", Tags: []string{TagDenyAll, TagIngress}, Policy: denyAllIngressToX},
+		{Description: "allow ingress to and egress from x/a on port 80", Tags: []string{TagNumberedPort, TagIngress, TagEgress}, Policy: baseTestPolicy().NetworkPolicy()},
+	} {
+		cases = append(cases, NewTestCase(
+			"Restart CNI, with policy: "+c.Description,
+			NewStringSet(append([]string{TagRestartCNI}, c.Tags...)...),
+			NewTestStep(ProbeAllAvailable, CreatePolicy(c.Policy)),
+			NewTestStep(ProbeAllAvailable, RestartCNI()),
+			NewTestStep(ProbeAllAvailable, WaitForCNIRecovery())))
+	}
+	return cases
+}
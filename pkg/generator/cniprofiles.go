@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"sort"
+)
+
+// CNIProfile records a CNI's known deviations from what cyclonus expects, so that everyone running cyclonus against
+// the CNI doesn't have to keep their own list of test cases to exclude
+type CNIProfile struct {
+	Name string
+	// ServerProtocols, if set, are the only protocols the CNI supports well enough to run servers for
+	ServerProtocols []v1.Protocol
+	// IgnoreLoopback is set for CNIs which don't apply policies to traffic from a pod to itself
+	IgnoreLoopback bool
+	// Excluded test cases aren't run at all
+	Excluded []*KnownDeviation
+	// Waived test cases are run and reported as usual, but their failures don't count as failures
+	Waived []*KnownDeviation
+}
+
+// KnownDeviation picks out the test cases affected by one of a CNI's deviations with a tag expression, along with why
+type KnownDeviation struct {
+	Tags   string
+	Reason string
+}
+
+var CNIProfiles = map[string]*CNIProfile{
+	"antrea": {
+		Name:            "antrea",
+		ServerProtocols: []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP},
+		Excluded: []*KnownDeviation{
+			{Tags: TagSCTPProtocol, Reason: "SCTP isn't supported by antrea's kind deployment"},
+		},
+	},
+	"calico": {
+		Name: "calico",
+		Waived: []*KnownDeviation{
+			{Tags: TagIPBlockNodeIP, Reason: "traffic to services may be SNATed to node IPs, so ipBlocks of node IPs match traffic they otherwise wouldn't"},
+		},
+	},
+	"cilium": {
+		Name:            "cilium",
+		ServerProtocols: []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP},
+		IgnoreLoopback:  true,
+		Excluded: []*KnownDeviation{
+			{Tags: TagSCTPProtocol, Reason: "SCTP support is off by default"},
+			{Tags: TagAdminNetworkPolicy + " || " + TagBaselineAdminNetworkPolicy, Reason: "AdminNetworkPolicies aren't supported"},
+		},
+	},
+	"ovn-kubernetes": {
+		Name:           "ovn-kubernetes",
+		IgnoreLoopback: true,
+		Excluded: []*KnownDeviation{
+			{Tags: TagNamedPort, Reason: "known failures with named ports"},
+			{Tags: TagMultiPeer, Reason: "known failures with rules with multiple peers"},
+			{Tags: TagUpstreamE2E + " || " + TagExample, Reason: "known failures among the upstream e2e and example test cases"},
+		},
+	},
+}
+
+func AllCNIProfileNames() []string {
+	var names []string
+	for name := range CNIProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func GetCNIProfile(name string) (*CNIProfile, error) {
+	profile, ok := CNIProfiles[name]
+	if !ok {
+		return nil, errors.Errorf("no cni profile %s; must be one of %+v", name, AllCNIProfileNames())
+	}
+	return profile, nil
+}
+
+// DeviationCount is how many test cases a known deviation applied to
+type DeviationCount struct {
+	Deviation *KnownDeviation
+	Count     int
+}
+
+// Apply drops the profile's excluded test cases, and sets the Waiver of its waived test cases, unless they're already
+// waived -- i.e. by a more specific waivers file.  Along with the test cases to run, it returns how many test cases
+// each exclusion, then each waiver, applied to.
+func (p *CNIProfile) Apply(testCases []*TestCase) ([]*TestCase, []*DeviationCount, []*DeviationCount, error) {
+	excluded, err := parseDeviations(p.Excluded)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	waived, err := parseDeviations(p.Waived)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	excludedCounts := newDeviationCounts(p.Excluded)
+	waivedCounts := newDeviationCounts(p.Waived)
+
+	var kept []*TestCase
+TestCases:
+	for _, testCase := range testCases {
+		for i, expression := range excluded {
+			if expression.Matches(testCase.Tags) {
+				excludedCounts[i].Count++
+				continue TestCases
+			}
+		}
+		for i, expression := range waived {
+			if expression.Matches(testCase.Tags) {
+				waivedCounts[i].Count++
+				if testCase.Waiver == "" {
+					testCase.Waiver = p.Name + ": " + p.Waived[i].Reason
+				}
+				break
+			}
+		}
+		kept = append(kept, testCase)
+	}
+	return kept, excludedCounts, waivedCounts, nil
+}
+
+func parseDeviations(deviations []*KnownDeviation) ([]TagExpression, error) {
+	var expressions []TagExpression
+	for _, deviation := range deviations {
+		expression, err := ParseTagExpression(deviation.Tags)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid known deviation '%s'", deviation.Tags)
+		}
+		expressions = append(expressions, expression)
+	}
+	return expressions, nil
+}
+
+func newDeviationCounts(deviations []*KnownDeviation) []*DeviationCount {
+	var counts []*DeviationCount
+	for _, deviation := range deviations {
+		counts = append(counts, &DeviationCount{Deviation: deviation})
+	}
+	return counts
+}
@@ -0,0 +1,44 @@
+package generator
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunCNIProfileTests() {
+	Describe("CNI profiles", func() {
+		It("should only refer to valid tags", func() {
+			for _, name := range AllCNIProfileNames() {
+				_, _, _, err := CNIProfiles[name].Apply(nil)
+				Expect(err).To(Succeed(), name)
+			}
+		})
+
+		It("should exclude and waive test cases by tags, counting how many each deviation applied to", func() {
+			profile := &CNIProfile{
+				Name:     "example-cni",
+				Excluded: []*KnownDeviation{{Tags: TagSCTPProtocol, Reason: "no SCTP"}},
+				Waived:   []*KnownDeviation{{Tags: TagEgress + " && " + TagUDPProtocol, Reason: "egress UDP is flaky"}},
+			}
+			sctp := NewTestCase("sctp", NewStringSet(TagIngress, TagSCTPProtocol))
+			egressUDP := NewTestCase("egress udp", NewStringSet(TagEgress, TagUDPProtocol))
+			ingressUDP := NewTestCase("ingress udp", NewStringSet(TagIngress, TagUDPProtocol))
+
+			kept, excluded, waived, err := profile.Apply([]*TestCase{sctp, egressUDP, ingressUDP})
+			Expect(err).To(Succeed())
+			Expect(kept).To(Equal([]*TestCase{egressUDP, ingressUDP}))
+			Expect(excluded).To(Equal([]*DeviationCount{{Deviation: profile.Excluded[0], Count: 1}}))
+			Expect(waived).To(Equal([]*DeviationCount{{Deviation: profile.Waived[0], Count: 1}}))
+			Expect(egressUDP.Waiver).To(Equal("example-cni: egress UDP is flaky"))
+			Expect(ingressUDP.Waiver).To(Equal(""))
+		})
+
+		It("should reject unknown profiles", func() {
+			_, err := GetCNIProfile("weave")
+			Expect(err).ToNot(Succeed())
+			profile, err := GetCNIProfile("calico")
+			Expect(err).To(Succeed())
+			Expect(profile.Name).To(Equal("calico"))
+		})
+	})
+}
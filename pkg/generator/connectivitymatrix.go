@@ -0,0 +1,128 @@
+package generator
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+const (
+	matrixAllowed   = "."
+	matrixBlocked   = "X"
+	matrixUnchecked = "-"
+)
+
+// ConnectivityMatrix is the expected connectivity between pods, written as a grid in the same style as the probe
+// tables cyclonus prints: a header row of destination pods, then one row per source pod, where '.' is allowed,
+// 'X' is blocked, and '-' means no expectation.
+//
+// Example:
+//
+//	    x/a x/b y/a
+//	x/a .   X   .
+//	y/a X   X   -
+type ConnectivityMatrix struct {
+	Froms []string
+	Tos   []string
+	// Cells maps from, then to, to whether traffic is expected to be allowed; pairs without expectations are absent
+	Cells map[string]map[string]bool
+}
+
+func ParseConnectivityMatrix(text string) (*ConnectivityMatrix, error) {
+	var rows [][]string
+	for _, line := range strings.Split(text, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			rows = append(rows, fields)
+		}
+	}
+	if len(rows) < 2 {
+		return nil, errors.Errorf("connectivity matrix needs a header row of destinations and at least one source row")
+	}
+
+	matrix := &ConnectivityMatrix{Tos: rows[0], Cells: map[string]map[string]bool{}}
+	for _, row := range rows[1:] {
+		from, cells := row[0], row[1:]
+		if len(cells) != len(matrix.Tos) {
+			return nil, errors.Errorf("connectivity matrix row for %s has %d cells, expected %d", from, len(cells), len(matrix.Tos))
+		}
+		if _, ok := matrix.Cells[from]; ok {
+			return nil, errors.Errorf("connectivity matrix has duplicate row for %s", from)
+		}
+		matrix.Froms = append(matrix.Froms, from)
+		matrix.Cells[from] = map[string]bool{}
+		for i, cell := range cells {
+			switch cell {
+			case matrixAllowed:
+				matrix.Cells[from][matrix.Tos[i]] = true
+			case matrixBlocked:
+				matrix.Cells[from][matrix.Tos[i]] = false
+			case matrixUnchecked:
+			default:
+				return nil, errors.Errorf("invalid connectivity matrix cell '%s' from %s to %s: expected one of '%s', '%s', '%s'", cell, from, matrix.Tos[i], matrixAllowed, matrixBlocked, matrixUnchecked)
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// Expectation returns whether traffic from one pod to another is expected to be allowed, and whether there's an
+// expectation at all
+func (m *ConnectivityMatrix) Expectation(from string, to string) (bool, bool) {
+	allowed, ok := m.Cells[from][to]
+	return allowed, ok
+}
+
+// CheckPods makes sure that every pod in the matrix is one of pods, to catch typos which would otherwise silently
+// leave pairs unchecked.  A nil matrix is always fine.
+func (m *ConnectivityMatrix) CheckPods(pods []string) error {
+	if m == nil {
+		return nil
+	}
+	known := map[string]bool{}
+	for _, pod := range pods {
+		known[pod] = true
+	}
+	for _, pod := range append(append([]string{}, m.Froms...), m.Tos...) {
+		if !known[pod] {
+			return errors.Errorf("connectivity matrix refers to unknown pod %s", pod)
+		}
+	}
+	return nil
+}
+
+func (m *ConnectivityMatrix) String() string {
+	// the header row is indented, to line up with the source rows' cells
+	lines := []string{"  " + strings.Join(m.Tos, " ")}
+	for _, from := range m.Froms {
+		cells := []string{from}
+		for _, to := range m.Tos {
+			allowed, ok := m.Expectation(from, to)
+			if !ok {
+				cells = append(cells, matrixUnchecked)
+			} else if allowed {
+				cells = append(cells, matrixAllowed)
+			} else {
+				cells = append(cells, matrixBlocked)
+			}
+		}
+		lines = append(lines, strings.Join(cells, " "))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (m *ConnectivityMatrix) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *ConnectivityMatrix) UnmarshalJSON(bs []byte) error {
+	var text string
+	if err := json.Unmarshal(bs, &text); err != nil {
+		return errors.Wrapf(err, "connectivity matrix must be a string")
+	}
+	parsed, err := ParseConnectivityMatrix(text)
+	if err != nil {
+		return err
+	}
+	*m = *parsed
+	return nil
+}
@@ -96,6 +96,7 @@ var (
 	}
 
 	nsXMatchLabelsSelector       = &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "x"}}
+	nsZMatchLabelsSelector       = &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "z"}}
 	nsXYMatchExpressionsSelector = &metav1.LabelSelector{
 		MatchExpressions: []metav1.LabelSelectorRequirement{
 			{
@@ -0,0 +1,174 @@
+package generator
+
+import (
+	v1 "k8s.io/api/core/v1"
+	. "k8s.io/api/networking/v1"
+	"reflect"
+)
+
+// DerivedTags are the tags implied by what a test case's actions and policies actually use, found by looking through
+// them rather than trusting whoever wrote the test case to tag it.  Creating policies isn't tagged, since nearly every
+// test case does it, and neither is the DNS rule added for egress cases, which isn't what's being tested.
+func (t *TestCase) DerivedTags() StringSet {
+	tags := NewStringSet()
+	for _, step := range t.Steps {
+		for _, action := range step.Actions {
+			deriveActionTags(tags, action)
+		}
+	}
+	return tags
+}
+
+// AddDerivedTags adds the derived tags to the test case's own, so that filtering by tag takes them into account
+func (t *TestCase) AddDerivedTags() {
+	if t.Tags == nil {
+		t.Tags = NewStringSet()
+	}
+	for tag := range t.DerivedTags() {
+		t.Tags[tag] = true
+	}
+}
+
+func deriveActionTags(tags StringSet, action *Action) {
+	switch {
+	case action.CreatePolicy != nil:
+		derivePolicyTags(tags, action.CreatePolicy.Policy)
+	case action.UpdatePolicy != nil:
+		tags.Add(TagUpdatePolicy)
+		derivePolicyTags(tags, action.UpdatePolicy.Policy)
+	case action.DeletePolicy != nil:
+		tags.Add(TagDeletePolicy)
+	case action.CreateNamespace != nil:
+		tags.Add(TagCreateNamespace)
+	case action.SetNamespaceLabels != nil:
+		tags.Add(TagSetNamespaceLabels)
+	case action.DeleteNamespace != nil:
+		tags.Add(TagDeleteNamespace)
+	case action.CreatePod != nil:
+		tags.Add(TagCreatePod)
+	case action.SetPodLabels != nil:
+		tags.Add(TagSetPodLabels)
+	case action.DeletePod != nil:
+		tags.Add(TagDeletePod)
+	case action.CreateAdminNetworkPolicy != nil:
+		spec := action.CreateAdminNetworkPolicy.Policy.Spec
+		for _, rule := range spec.Ingress {
+			if tag, ok := adminPolicyActionTags[rule.Action]; ok {
+				tags.Add(tag)
+			}
+		}
+		for _, rule := range spec.Egress {
+			if tag, ok := adminPolicyActionTags[rule.Action]; ok {
+				tags.Add(tag)
+			}
+		}
+	case action.CreateBaselineAdminNetworkPolicy != nil:
+		spec := action.CreateBaselineAdminNetworkPolicy.Policy.Spec
+		for _, rule := range spec.Ingress {
+			if tag, ok := baselinePolicyActionTags[rule.Action]; ok {
+				tags.Add(tag)
+			}
+		}
+		for _, rule := range spec.Egress {
+			if tag, ok := baselinePolicyActionTags[rule.Action]; ok {
+				tags.Add(tag)
+			}
+		}
+	case action.RestartCNI != nil:
+		tags.Add(TagRestartCNI)
+	case action.OpenConnection != nil:
+		tags.Add(TagLongLivedConnection)
+	}
+}
+
+var protocolTags = map[v1.Protocol]string{
+	v1.ProtocolTCP:  TagTCPProtocol,
+	v1.ProtocolUDP:  TagUDPProtocol,
+	v1.ProtocolSCTP: TagSCTPProtocol,
+}
+
+// policyDirections follows the api server's defaulting: without policy types, a policy is for ingress, and also for
+// egress if it has egress rules
+func policyDirections(policy *NetworkPolicy) (bool, bool) {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return true, len(policy.Spec.Egress) > 0
+	}
+	isIngress, isEgress := false, false
+	for _, policyType := range policy.Spec.PolicyTypes {
+		switch policyType {
+		case PolicyTypeIngress:
+			isIngress = true
+		case PolicyTypeEgress:
+			isEgress = true
+		}
+	}
+	return isIngress, isEgress
+}
+
+func derivePolicyTags(tags StringSet, policy *NetworkPolicy) {
+	isIngress, isEgress := policyDirections(policy)
+	netpol := NewNetpol(policy)
+	if isIngress {
+		tags.Add(TagIngress)
+		deriveRulesTags(tags, netpol.Ingress.Rules)
+	}
+	if isEgress {
+		tags.Add(TagEgress)
+		var rules []*Rule
+		for _, rule := range netpol.Egress.Rules {
+			if !reflect.DeepEqual(rule.Egress(), AllowDNSRule.Egress()) {
+				rules = append(rules, rule)
+			}
+		}
+		// a policy which only allows DNS still isolates egress
+		if len(rules) > 0 || len(netpol.Egress.Rules) == 0 {
+			deriveRulesTags(tags, rules)
+		}
+	}
+	if isIngress && !isEgress {
+		tags.Add(TagIngressOnly)
+	} else if isEgress && !isIngress {
+		tags.Add(TagEgressOnly)
+	}
+}
+
+func deriveRulesTags(tags StringSet, rules []*Rule) {
+	if len(rules) == 0 {
+		tags.Add(TagDenyAll)
+	} else if len(rules) > 1 {
+		tags.Add(TagMultiRule)
+	}
+	for _, rule := range rules {
+		switch {
+		case len(rule.Peers) == 0 && len(rule.Ports) == 0:
+			tags.Add(TagAllowAll)
+		case len(rule.Peers) == 0:
+			tags.Add(TagAnyPeer)
+		case len(rule.Ports) == 0:
+			tags.Add(TagAnyPortProtocol)
+		}
+		if len(rule.Peers) > 1 {
+			tags.Add(TagMultiPeer)
+		}
+		if len(rule.Ports) > 1 {
+			tags.Add(TagMultiPortProtocol)
+		}
+		for _, peer := range rule.Peers {
+			for _, tag := range describePeer(peer) {
+				tags.Add(tag)
+			}
+		}
+		for _, port := range rule.Ports {
+			if port.EndPort != nil {
+				tags.Add(TagPortRange)
+			} else {
+				tags.Add(describePort(port.Port))
+			}
+			if port.Protocol != nil {
+				if tag, ok := protocolTags[*port.Protocol]; ok {
+					tags.Add(tag)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package generator
+
+import (
+	. "k8s.io/api/networking/v1"
+)
+
+// DNSTestCases isolate egress from x/a, with and without a DNS carve-out, so that a broken carve-out shows up as
+// such.  The pods' own probes don't do DNS lookups, so they're only useful if lookups are checked as well, which is
+// what the interpreter does at every step when DNS checks are enabled.  They're only generated if DNS is allowed.
+func (t *TestCaseGenerator) DNSTestCases() []*TestCase {
+	if !t.AllowDNS {
+		return nil
+	}
+	xa := &NetpolTarget{Namespace: "x", PodSelector: *podAMatchLabelsSelector}
+	allowDNSOverTCP := &Netpol{
+		Name:   "allow-dns-tcp",
+		Target: xa,
+		Egress: &NetpolPeers{Rules: []*Rule{{Ports: []NetworkPolicyPort{{Protocol: &tcp, Port: &port53}}}}},
+	}
+
+	var cases []*TestCase
+	for _, c := range []struct {
+		Description string
+		Tags        []string
+		Carveout    *Netpol
+	}{
+		{
+			Description: "deny all egress from x/a, allowing DNS: lookups from x/a work",
+			Tags:        []string{TagUDPProtocol},
+			Carveout:    AllowDNSPolicy(xa),
+		},
+		{
+			Description: "deny all egress from x/a, without allowing DNS: lookups from x/a fail",
+		},
+		{
+			Description: "deny all egress from x/a, allowing DNS over TCP only: lookups from x/a, over UDP, fail",
+			Tags:        []string{TagTCPProtocol},
+			Carveout:    allowDNSOverTCP,
+		},
+	} {
+		actions := []*Action{CreatePolicy((&Netpol{Name: "deny-egress-x-a", Target: xa, Egress: DenyAll}).NetworkPolicy())}
+		if c.Carveout != nil {
+			actions = append(actions, CreatePolicy(c.Carveout.NetworkPolicy()))
+		}
+		tags := append([]string{TagDNS, TagEgress, TagDenyAll}, c.Tags...)
+		cases = append(cases, NewSingleStepTestCase("DNS: "+c.Description, NewStringSet(tags...), ProbeAllAvailable, actions...))
+	}
+	return cases
+}
@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"sigs.k8s.io/yaml"
+	"sort"
+)
+
+// ExpectationOverrides replace the expected results of test cases for particular CNIs, whose behavior legitimately
+// differs from what the policies alone would allow -- i.e. because they SNAT some traffic to node IPs.  It maps CNI
+// names to test case descriptions to one expected connectivity matrix per step; a null step keeps the policies'
+// expected results.
+//
+// Example:
+//
+//	calico:
+//	  "allow ingress to x/a from ipBlock of z/c's node IP, probe by service-ip":
+//	  - |
+//	    x/a
+//	    z/c .
+type ExpectationOverrides map[string]map[string][]*ConnectivityMatrix
+
+func LoadExpectationOverrides(path string) (ExpectationOverrides, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read expectation overrides %s", path)
+	}
+	overrides := ExpectationOverrides{}
+	err = yaml.UnmarshalStrict(bs, &overrides)
+	return overrides, errors.Wrapf(err, "unable to unmarshal expectation overrides %s", path)
+}
+
+// Apply sets the expected connectivity of the CNI's overridden test cases.  Overrides for test cases which aren't
+// among testCases are an error, to catch typos in descriptions -- so apply them before filtering test cases.
+func (e ExpectationOverrides) Apply(testCases []*TestCase, cni string) error {
+	overrides, ok := e[cni]
+	if !ok {
+		var cnis []string
+		for name := range e {
+			cnis = append(cnis, name)
+		}
+		sort.Strings(cnis)
+		return errors.Errorf("no expectation overrides for CNI %s; found %+v", cni, cnis)
+	}
+	found := map[string]bool{}
+	for _, testCase := range testCases {
+		matrices, ok := overrides[testCase.Description]
+		if !ok {
+			continue
+		}
+		if len(matrices) != len(testCase.Steps) {
+			return errors.Errorf("expectation overrides for test case '%s' have %d steps, expected %d", testCase.Description, len(matrices), len(testCase.Steps))
+		}
+		for i, matrix := range matrices {
+			if matrix != nil {
+				testCase.Steps[i].Expected = matrix
+			}
+		}
+		found[testCase.Description] = true
+	}
+	for description := range overrides {
+		if !found[description] {
+			return errors.Errorf("expectation overrides for CNI %s refer to unknown test case '%s'", cni, description)
+		}
+	}
+	return nil
+}
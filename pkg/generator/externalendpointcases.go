@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	v1 "k8s.io/api/core/v1"
+	. "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ExternalEndpointTestCases open and close egress from x/a to an endpoint outside the pod network with ipBlocks of
+// its IP.  The pods' own probes don't go there, so they're only useful if the endpoint is probed as well, which is
+// what the interpreter does at every step when it has one.  They're only generated if the endpoint's IPv4 address
+// and port are known.
+func (t *TestCaseGenerator) ExternalEndpointTestCases() []*TestCase {
+	if t.ExternalIP == "" {
+		return nil
+	}
+	xa := &NetpolTarget{Namespace: "x", PodSelector: *podAMatchLabelsSelector}
+	ip32 := kube.MakeIPV4CIDR(t.ExternalIP, 32)
+	ip24 := kube.MakeIPV4CIDR(t.ExternalIP, 24)
+	endpointPort := intstr.FromInt(t.ExternalPort)
+	otherPort := intstr.FromInt(t.ExternalPort + 1)
+	ipBlockPeers := func(cidr string, except ...string) []NetworkPolicyPeer {
+		return []NetworkPolicyPeer{{IPBlock: &IPBlock{CIDR: cidr, Except: except}}}
+	}
+	ports := func(protocol v1.Protocol, port intstr.IntOrString) []NetworkPolicyPort {
+		return []NetworkPolicyPort{{Protocol: &protocol, Port: &port}}
+	}
+
+	var cases []*TestCase
+	for _, c := range []struct {
+		Description string
+		Tags        []string
+		Rules       []*Rule
+	}{
+		{
+			Description: "deny all egress from x/a: external endpoint blocked",
+			Tags:        []string{TagDenyAll},
+		},
+		{
+			Description: "allow egress from x/a to external endpoint's /32",
+			Tags:        []string{TagIPBlockNoExcept},
+			Rules:       []*Rule{{Peers: ipBlockPeers(ip32)}},
+		},
+		{
+			Description: "allow egress from x/a to external endpoint's /24, except its /32",
+			Tags:        []string{TagIPBlockWithExcept},
+			Rules:       []*Rule{{Peers: ipBlockPeers(ip24, ip32)}},
+		},
+		{
+			Description: "allow egress from x/a to 0.0.0.0/0, except external endpoint's /24",
+			Tags:        []string{TagIPBlockWithExcept},
+			Rules:       []*Rule{{Peers: ipBlockPeers("0.0.0.0/0", ip24)}},
+		},
+		{
+			Description: "allow egress from x/a to external endpoint's /32 on its port on TCP",
+			Tags:        []string{TagIPBlockNoExcept, TagNumberedPort, TagTCPProtocol},
+			Rules:       []*Rule{{Peers: ipBlockPeers(ip32), Ports: ports(tcp, endpointPort)}},
+		},
+		{
+			Description: "allow egress from x/a to external endpoint's /32 on another port on TCP",
+			Tags:        []string{TagIPBlockNoExcept, TagNumberedPort, TagTCPProtocol},
+			Rules:       []*Rule{{Peers: ipBlockPeers(ip32), Ports: ports(tcp, otherPort)}},
+		},
+		{
+			Description: "allow egress from x/a to external endpoint's /32 on its port on UDP",
+			Tags:        []string{TagIPBlockNoExcept, TagNumberedPort, TagUDPProtocol},
+			Rules:       []*Rule{{Peers: ipBlockPeers(ip32), Ports: ports(udp, endpointPort)}},
+		},
+	} {
+		actions := []*Action{CreatePolicy((&Netpol{Name: "egress-x-a-external", Target: xa, Egress: &NetpolPeers{Rules: c.Rules}}).NetworkPolicy())}
+		if t.AllowDNS {
+			actions = append(actions, CreatePolicy(AllowDNSPolicy(xa).NetworkPolicy()))
+		}
+		tags := append([]string{TagEgress, TagIPBlockExternalEndpoint}, c.Tags...)
+		cases = append(cases, NewSingleStepTestCase(c.Description, NewStringSet(tags...), ProbeAllAvailable, actions...))
+	}
+	return cases
+}
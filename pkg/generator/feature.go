@@ -20,6 +20,18 @@ const (
 	ActionFeatureCreatePod    = "action: create pod"
 	ActionFeatureSetPodLabels = "action: set pod labels"
 	ActionFeatureDeletePod    = "action: delete pod"
+
+	ActionFeatureCreateAdminNetworkPolicy = "action: create admin network policy"
+	ActionFeatureDeleteAdminNetworkPolicy = "action: delete admin network policy"
+
+	ActionFeatureCreateBaselineAdminNetworkPolicy = "action: create baseline admin network policy"
+	ActionFeatureDeleteBaselineAdminNetworkPolicy = "action: delete baseline admin network policy"
+
+	ActionFeatureRestartCNI         = "action: restart CNI"
+	ActionFeatureWaitForCNIRecovery = "action: wait for CNI recovery"
+
+	ActionFeatureOpenConnection  = "action: open connection"
+	ActionFeatureCloseConnection = "action: close connection"
 )
 
 const (
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "k8s.io/api/networking/v1"
+)
+
+const (
+	FeatureSCTP            = "SCTP"
+	FeatureEndPort         = "endPort"
+	FeatureNamedPort       = "named ports"
+	FeatureIPBlockForPodIP = "ipBlock matching pod IPs"
+	FeatureIPv6            = "IPv6"
+	FeatureANP             = "AdminNetworkPolicy"
+)
+
+// FeatureCheck is a test case whose outcome says whether a CNI supports a feature: a CNI which doesn't support
+// the feature will produce results which don't match the expected results
+type FeatureCheck struct {
+	Feature  string
+	TestCase *TestCase
+}
+
+func allowIngressToXAPolicy(name string, ports []NetworkPolicyPort, peers []NetworkPolicyPeer) *NetworkPolicy {
+	return (&Netpol{
+		Name:    name,
+		Target:  &NetpolTarget{Namespace: "x", PodSelector: *podAMatchLabelsSelector},
+		Ingress: &NetpolPeers{Rules: []*Rule{{Ports: ports, Peers: peers}}},
+	}).NetworkPolicy()
+}
+
+// FeatureCheckTestCases builds one single-step test case per feature which can be checked by probing.  Each opens
+// ingress to x/a using just that feature, and probes a port which is only allowed if the feature works.
+func FeatureCheckTestCases(zcPodIP string) []*FeatureCheck {
+	endPort := port81.IntVal
+	return []*FeatureCheck{
+		{
+			Feature: FeatureSCTP,
+			TestCase: NewSingleStepTestCase("feature check: allow SCTP on port 80",
+				NewStringSet(TagIngress, TagNumberedPort, TagSCTPProtocol),
+				NewProbeConfig(port80, sctp, ProbeModeServiceName),
+				CreatePolicy(allowIngressToXAPolicy("feature-sctp", []NetworkPolicyPort{{Protocol: &sctp, Port: &port80}}, nil))),
+		},
+		{
+			Feature: FeatureEndPort,
+			TestCase: NewSingleStepTestCase("feature check: allow TCP on port range 80-81, probe port 81",
+				NewStringSet(TagIngress, TagNumberedPort, TagTCPProtocol),
+				NewProbeConfig(port81, tcp, ProbeModeServiceName),
+				CreatePolicy(allowIngressToXAPolicy("feature-endport", []NetworkPolicyPort{{Protocol: &tcp, Port: &port80, EndPort: &endPort}}, nil))),
+		},
+		{
+			Feature: FeatureNamedPort,
+			TestCase: NewSingleStepTestCase("feature check: allow named port serve-81-tcp, probe port 81",
+				NewStringSet(TagIngress, TagNamedPort, TagTCPProtocol),
+				NewProbeConfig(port81, tcp, ProbeModeServiceName),
+				CreatePolicy(allowIngressToXAPolicy("feature-named-port", []NetworkPolicyPort{{Protocol: &tcp, Port: &portServe81TCP}}, nil))),
+		},
+		{
+			Feature: FeatureIPBlockForPodIP,
+			TestCase: NewSingleStepTestCase("feature check: allow ipBlock of z/c's pod IP, probe by pod IP",
+				NewStringSet(TagIngress, TagIPBlockNoExcept),
+				NewProbeConfig(port80, tcp, ProbeModePodIP).WithPinnedMode(ProbeModePodIP),
+				CreatePolicy(allowIngressToXAPolicy("feature-ipblock-pod-ip", nil, []NetworkPolicyPeer{{IPBlock: &IPBlock{CIDR: kube.MakeIPV4CIDR(zcPodIP, 32)}}}))),
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package generator
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	. "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"math/rand"
+	"strings"
+)
+
+// PolicyFuzzer generates random but valid test cases, each of which creates a few network policies -- with random
+// targets, rules, peers and ports, picked from the namespaces, pods, ports and protocols cyclonus creates -- and
+// probes all available ports.  The same seed always generates the same test cases.
+type PolicyFuzzer struct {
+	AllowDNS   bool
+	Namespaces []string
+	Pods       []string
+	Ports      []int
+	Protocols  []v1.Protocol
+	rand       *rand.Rand
+}
+
+func NewPolicyFuzzer(seed int64, allowDNS bool, namespaces []string, pods []string, ports []int, protocols []v1.Protocol) *PolicyFuzzer {
+	return &PolicyFuzzer{
+		AllowDNS:   allowDNS,
+		Namespaces: namespaces,
+		Pods:       pods,
+		Ports:      ports,
+		Protocols:  protocols,
+		rand:       rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Next generates the next test case, from a seed of its own, which its description includes -- so that one test
+// case can be generated again, with TestCase, without generating the ones before it
+func (f *PolicyFuzzer) Next() *TestCase {
+	return f.TestCase(f.rand.Int63())
+}
+
+// TestCase generates the test case for seed
+func (f *PolicyFuzzer) TestCase(seed int64) *TestCase {
+	r := rand.New(rand.NewSource(seed))
+	var actions []*Action
+	policyCount := 1 + r.Intn(3)
+	for i := 0; i < policyCount; i++ {
+		actions = append(actions, CreatePolicy(f.policy(r, fmt.Sprintf("fuzz-%d", i+1)).NetworkPolicy()))
+	}
+	testCase := NewSingleStepTestCase(fmt.Sprintf("fuzz case %d", seed), NewStringSet(TagFuzz), ProbeAllAvailable, actions...)
+	testCase.AddDerivedTags()
+	return testCase
+}
+
+func (f *PolicyFuzzer) policy(r *rand.Rand, name string) *Netpol {
+	policy := &Netpol{
+		Name:        name,
+		Description: "generated by the fuzzer",
+		Target:      &NetpolTarget{Namespace: f.Namespaces[r.Intn(len(f.Namespaces))], PodSelector: *f.labelSelector(r, "pod", f.Pods)},
+	}
+	// ingress, egress or both
+	switch r.Intn(3) {
+	case 0:
+		policy.Ingress = f.peers(r)
+	case 1:
+		policy.Egress = f.peers(r)
+	default:
+		policy.Ingress = f.peers(r)
+		policy.Egress = f.peers(r)
+	}
+	if policy.Egress != nil && f.AllowDNS {
+		policy.Egress.Rules = append(policy.Egress.Rules, AllowDNSRule)
+	}
+	return policy
+}
+
+func (f *PolicyFuzzer) peers(r *rand.Rand) *NetpolPeers {
+	peers := &NetpolPeers{}
+	ruleCount := r.Intn(3)
+	for i := 0; i < ruleCount; i++ {
+		rule := &Rule{}
+		peerCount, portCount := r.Intn(3), r.Intn(3)
+		for j := 0; j < peerCount; j++ {
+			rule.Peers = append(rule.Peers, f.peer(r))
+		}
+		for j := 0; j < portCount; j++ {
+			rule.Ports = append(rule.Ports, f.port(r))
+		}
+		peers.Rules = append(peers.Rules, rule)
+	}
+	return peers
+}
+
+func (f *PolicyFuzzer) peer(r *rand.Rand) NetworkPolicyPeer {
+	switch r.Intn(4) {
+	case 0:
+		return NetworkPolicyPeer{PodSelector: f.labelSelector(r, "pod", f.Pods)}
+	case 1:
+		return NetworkPolicyPeer{NamespaceSelector: f.labelSelector(r, "ns", f.Namespaces)}
+	case 2:
+		return NetworkPolicyPeer{PodSelector: f.labelSelector(r, "pod", f.Pods), NamespaceSelector: f.labelSelector(r, "ns", f.Namespaces)}
+	default:
+		// the pods' IPs aren't known, so ipBlocks are all-or-nothing
+		if r.Intn(2) == 0 {
+			return NetworkPolicyPeer{IPBlock: &IPBlock{CIDR: "0.0.0.0/0"}}
+		}
+		return NetworkPolicyPeer{IPBlock: &IPBlock{CIDR: "0.0.0.0/0", Except: []string{"0.0.0.0/1", "128.0.0.0/1"}}}
+	}
+}
+
+// labelSelector selects everything, one value of key, or some values of key -- or everything but them
+func (f *PolicyFuzzer) labelSelector(r *rand.Rand, key string, values []string) *metav1.LabelSelector {
+	switch r.Intn(4) {
+	case 0:
+		return &metav1.LabelSelector{}
+	case 1:
+		return &metav1.LabelSelector{MatchLabels: map[string]string{key: values[r.Intn(len(values))]}}
+	default:
+		operator := metav1.LabelSelectorOpIn
+		if r.Intn(2) == 0 {
+			operator = metav1.LabelSelectorOpNotIn
+		}
+		var picked []string
+		for _, i := range r.Perm(len(values))[:1+r.Intn(len(values))] {
+			picked = append(picked, values[i])
+		}
+		return &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: key, Operator: operator, Values: picked}}}
+	}
+}
+
+// port is a numbered or named port, or all ports, of a protocol -- which is left out, defaulting to TCP, sometimes
+func (f *PolicyFuzzer) port(r *rand.Rand) NetworkPolicyPort {
+	protocol := f.Protocols[r.Intn(len(f.Protocols))]
+	port := NetworkPolicyPort{Protocol: &protocol}
+	number := f.Ports[r.Intn(len(f.Ports))]
+	switch r.Intn(3) {
+	case 0:
+		portNumber := intstr.FromInt(number)
+		port.Port = &portNumber
+	case 1:
+		portName := intstr.FromString(fmt.Sprintf("serve-%d-%s", number, strings.ToLower(string(protocol))))
+		port.Port = &portName
+	}
+	if protocol == v1.ProtocolTCP && r.Intn(2) == 0 {
+		port.Protocol = nil
+	}
+	return port
+}
@@ -0,0 +1,52 @@
+package generator
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+func RunPolicyFuzzerTests() {
+	Describe("PolicyFuzzer", func() {
+		newFuzzer := func(seed int64) *PolicyFuzzer {
+			return NewPolicyFuzzer(seed, true, []string{"x", "y", "z"}, []string{"a", "b", "c"}, []int{80, 81}, []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP})
+		}
+
+		It("should generate the same test cases from the same seed", func() {
+			first, second, other := newFuzzer(12), newFuzzer(12), newFuzzer(13)
+			for i := 0; i < 20; i++ {
+				testCase := first.Next()
+				Expect(second.Next()).To(Equal(testCase))
+				Expect(other.Next().Description).ToNot(Equal(testCase.Description))
+			}
+		})
+
+		It("should regenerate a single test case from the seed in its description", func() {
+			fuzzer := newFuzzer(12)
+			fuzzer.Next()
+			testCase := fuzzer.Next()
+			var seed int64
+			_, err := fmt.Sscanf(testCase.Description, "fuzz case %d", &seed)
+			Expect(err).To(Succeed())
+			Expect(newFuzzer(99).TestCase(seed)).To(Equal(testCase))
+		})
+
+		It("should generate valid policies, in cyclonus's namespaces", func() {
+			fuzzer := newFuzzer(34)
+			for i := 0; i < 50; i++ {
+				testCase := fuzzer.Next()
+				Expect(testCase.Tags).To(HaveKey(TagFuzz))
+				// tags are derived from the policies
+				Expect(testCase.Tags.ContainsAny([]string{TagIngress, TagEgress})).To(BeTrue())
+				Expect(testCase.Steps).To(HaveLen(1))
+				Expect(len(testCase.Steps[0].Actions)).To(BeNumerically(">=", 1))
+				for _, action := range testCase.Steps[0].Actions {
+					policy := action.CreatePolicy.Policy
+					Expect([]string{"x", "y", "z"}).To(ContainElement(policy.Namespace))
+					Expect(policy.Spec.PolicyTypes).ToNot(BeEmpty())
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,48 @@
+package generator
+
+// longLivedConnectionPort is the port long-lived connections are opened on; it has to be served over TCP
+const longLivedConnectionPort = 80
+
+// LongLivedConnectionTestCases open a connection, then create a policy which would deny it, and check whether the
+// connection is severed or survives.  Policies only have to apply to new connections -- what happens to existing ones
+// is up to the CNI -- so each connection's fate is reported on its own, rather than as a probe result.
+func (t *TestCaseGenerator) LongLivedConnectionTestCases() []*TestCase {
+	xa := &NetpolTarget{Namespace: "x", PodSelector: *podAMatchLabelsSelector}
+
+	var cases []*TestCase
+	for _, c := range []struct {
+		Description string
+		Tags        []string
+		Policy      *Netpol
+		// From and To are the pods in namespace x the connection goes between
+		From string
+		To   string
+	}{
+		{
+			Description: "deny all ingress to x/a, with a connection from x/b to x/a",
+			Tags:        []string{TagIngress, TagDenyAll},
+			Policy:      &Netpol{Name: "deny-ingress-x-a", Target: xa, Ingress: DenyAll},
+			From:        "b",
+			To:          "a",
+		},
+		{
+			Description: "deny all egress from x/a, with a connection from x/a to x/b",
+			Tags:        []string{TagEgress, TagDenyAll},
+			Policy:      &Netpol{Name: "deny-egress-x-a", Target: xa, Egress: DenyAll},
+			From:        "a",
+			To:          "b",
+		},
+	} {
+		actions := []*Action{CreatePolicy(c.Policy.NetworkPolicy())}
+		if c.Policy.Egress != nil && t.AllowDNS {
+			actions = append(actions, CreatePolicy(AllowDNSPolicy(xa).NetworkPolicy()))
+		}
+		cases = append(cases, NewTestCase(
+			"long-lived connection: "+c.Description,
+			NewStringSet(append([]string{TagLongLivedConnection}, c.Tags...)...),
+			NewTestStep(ProbeAllAvailable, OpenConnection("x", c.From, "x", c.To, longLivedConnectionPort)),
+			NewTestStep(ProbeAllAvailable, actions...),
+			NewTestStep(ProbeAllAvailable, CloseConnection("x", c.From, "x", c.To, longLivedConnectionPort))))
+	}
+	return cases
+}
@@ -0,0 +1,36 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	. "k8s.io/api/networking/v1"
+)
+
+// NodeIPBlockTestCases come in pairs: the same ingress ipBlock rule for x/a, once with a CIDR covering z/c's pod IP,
+// and once with a CIDR covering the IP of z/c's node.  Policies only see pod IPs, so the node IP variant shouldn't
+// let anything in -- but CNIs which SNAT traffic to the node's IP, i.e. for service traffic, may behave differently,
+// which is what these cases bring out.  Probing by service IP as well as pod IP catches SNAT done by kube-proxy.
+// They're only generated if the node IP is known.
+func (t *TestCaseGenerator) NodeIPBlockTestCases() []*TestCase {
+	if t.NodeIP == "" {
+		return nil
+	}
+	var cases []*TestCase
+	for _, variant := range []struct {
+		Name string
+		IP   string
+	}{
+		{Name: "pod IP", IP: t.PodIP},
+		{Name: "node IP", IP: t.NodeIP},
+	} {
+		policy := allowIngressToXAPolicy("allow-ipblock-z-c", nil, []NetworkPolicyPeer{{IPBlock: &IPBlock{CIDR: kube.MakeIPV4CIDR(variant.IP, 32)}}})
+		for _, mode := range []ProbeMode{ProbeModePodIP, ProbeModeServiceIP} {
+			cases = append(cases, NewSingleStepTestCase(
+				fmt.Sprintf("allow ingress to x/a from ipBlock of z/c's %s, probe by %s", variant.Name, mode),
+				NewStringSet(TagIngress, TagIPBlockNoExcept, TagIPBlockNodeIP),
+				ProbeAllAvailable.WithPinnedMode(mode),
+				CreatePolicy(policy)))
+		}
+	}
+	return cases
+}
@@ -0,0 +1,76 @@
+package generator
+
+import (
+	. "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	noPodsMatchLabelsSelector  = &metav1.LabelSelector{MatchLabels: map[string]string{"pod": "does-not-exist"}}
+	noPodsDoesNotExistSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      "pod",
+				Operator: metav1.LabelSelectorOpDoesNotExist,
+			},
+		},
+	}
+	noNamespacesMatchLabelsSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"ns": "does-not-exist"}}
+	// noPodsCIDR is TEST-NET-1, which is reserved for documentation, and so never holds pods
+	noPodsCIDR = "192.0.2.0/24"
+)
+
+// noPeers are peers which match nothing, each in a different way
+var noPeers = []NetworkPolicyPeer{
+	{PodSelector: noPodsMatchLabelsSelector},
+	{PodSelector: noPodsDoesNotExistSelector, NamespaceSelector: emptySelector},
+	{NamespaceSelector: noNamespacesMatchLabelsSelector},
+	{IPBlock: &IPBlock{CIDR: noPodsCIDR}},
+}
+
+// NoOpTestCases check that policies which don't change connectivity really don't: policies whose pod selectors match
+// no pods, and rules whose peers match nothing added on top of pods which are already isolated.  Though harmless on
+// paper, these have crashed CNIs and caused them to block traffic they shouldn't.
+func (t *TestCaseGenerator) NoOpTestCases() []*TestCase {
+	xNoPods := &NetpolTarget{Namespace: "x", PodSelector: *noPodsMatchLabelsSelector}
+	xNoPodsDoesNotExist := &NetpolTarget{Namespace: "x", PodSelector: *noPodsDoesNotExistSelector}
+	xa := &NetpolTarget{Namespace: "x", PodSelector: *podAMatchLabelsSelector}
+	allPeers := &NetpolPeers{Rules: []*Rule{{}}}
+	nothing := &NetpolPeers{Rules: []*Rule{{Peers: noPeers}}}
+
+	cases := []*TestCase{
+		NewSingleStepTestCase("no-op: deny all ingress to pods matching no labels",
+			NewStringSet(TagNoOp, TagTargetPodSelector, TagIngress, TagDenyAll),
+			ProbeAllAvailable,
+			CreatePolicy((&Netpol{Name: "no-op-deny-ingress", Target: xNoPods, Ingress: DenyAll}).NetworkPolicy())),
+		NewSingleStepTestCase("no-op: deny all egress from pods matching no labels",
+			NewStringSet(TagNoOp, TagTargetPodSelector, TagEgress, TagDenyAll),
+			ProbeAllAvailable,
+			CreatePolicy((&Netpol{Name: "no-op-deny-egress", Target: xNoPods, Egress: DenyAll}).NetworkPolicy())),
+		NewSingleStepTestCase("no-op: allow all ingress and egress for pods without a 'pod' label",
+			NewStringSet(TagNoOp, TagTargetPodSelector, TagIngress, TagEgress, TagAllowAll),
+			ProbeAllAvailable,
+			CreatePolicy((&Netpol{Name: "no-op-allow-all", Target: xNoPodsDoesNotExist, Ingress: allPeers, Egress: allPeers}).NetworkPolicy())),
+		NewSingleStepTestCase("no-op: allow ingress and egress to and from nothing, for pods matching no labels",
+			NewStringSet(TagNoOp, TagTargetPodSelector, TagIngress, TagEgress, TagMultiPeer, TagPodsByLabel, TagNamespacesByLabel, TagIPBlockNoExcept),
+			ProbeAllAvailable,
+			CreatePolicy((&Netpol{Name: "no-op-allow-nothing", Target: xNoPods, Ingress: nothing, Egress: nothing}).NetworkPolicy())),
+	}
+
+	ingressActions := []*Action{CreatePolicy((&Netpol{Name: "deny-ingress-x-a", Target: xa, Ingress: DenyAll}).NetworkPolicy())}
+	cases = append(cases, NewTestCase("no-op: allow ingress from nothing to x/a, which is already isolated",
+		NewStringSet(TagNoOp, TagIngress, TagDenyAll, TagMultiPeer, TagPodsByLabel, TagNamespacesByLabel, TagIPBlockNoExcept),
+		NewTestStep(ProbeAllAvailable, ingressActions...),
+		NewTestStep(ProbeAllAvailable, CreatePolicy((&Netpol{Name: "allow-ingress-x-a-from-nothing", Target: xa, Ingress: nothing}).NetworkPolicy()))))
+
+	egressActions := []*Action{CreatePolicy((&Netpol{Name: "deny-egress-x-a", Target: xa, Egress: DenyAll}).NetworkPolicy())}
+	if t.AllowDNS {
+		egressActions = append(egressActions, CreatePolicy(AllowDNSPolicy(xa).NetworkPolicy()))
+	}
+	cases = append(cases, NewTestCase("no-op: allow egress from x/a to nothing, which is already isolated",
+		NewStringSet(TagNoOp, TagEgress, TagDenyAll, TagMultiPeer, TagPodsByLabel, TagNamespacesByLabel, TagIPBlockNoExcept),
+		NewTestStep(ProbeAllAvailable, egressActions...),
+		NewTestStep(ProbeAllAvailable, CreatePolicy((&Netpol{Name: "allow-egress-x-a-to-nothing", Target: xa, Egress: nothing}).NetworkPolicy()))))
+
+	return cases
+}
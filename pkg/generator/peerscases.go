@@ -83,13 +83,24 @@ func describePeer(peer NetworkPolicyPeer) []string {
 	}
 }
 
+// probeForPeers pins ipblock cases to pod IPs: the ipblocks are built from a pod IP, and going through a service
+// would mean relying on the CNI to see the post-DNAT address
+func probeForPeers(peers ...NetworkPolicyPeer) *ProbeConfig {
+	for _, p := range peers {
+		if p.IPBlock != nil {
+			return ProbeAllAvailable.WithPinnedMode(ProbeModePodIP)
+		}
+	}
+	return ProbeAllAvailable
+}
+
 func (t *TestCaseGenerator) SinglePeersTestCases() []*TestCase {
 	var cases []*TestCase
 	for _, isIngress := range []bool{true, false} {
 		for _, p := range makePeers(t.PodIP) {
 			tags := append(describePeer(p.Peer), describeDirectionality(isIngress))
 			cases = append(cases,
-				NewSingleStepTestCase(p.Description, NewStringSet(tags...), ProbeAllAvailable,
+				NewSingleStepTestCase(p.Description, NewStringSet(tags...), probeForPeers(p.Peer),
 					CreatePolicy(BuildPolicy(SetPeers(isIngress, []NetworkPolicyPeer{p.Peer})).NetworkPolicy())))
 		}
 	}
@@ -106,7 +117,7 @@ func (t *TestCaseGenerator) TwoPeersTestCases() []*TestCase {
 					tags := append(describePeer(p1.Peer), TagMultiPeer, dir)
 					tags = append(tags, describePeer(p2.Peer)...)
 					cases = append(cases,
-						NewSingleStepTestCase(fmt.Sprintf("%s, 2-peer: %s, %s", dir, p1.Description, p2.Description), NewStringSet(tags...), ProbeAllAvailable,
+						NewSingleStepTestCase(fmt.Sprintf("%s, 2-peer: %s, %s", dir, p1.Description, p2.Description), NewStringSet(tags...), probeForPeers(p1.Peer, p2.Peer),
 							CreatePolicy(BuildPolicy(SetPeers(isIngress, []NetworkPolicyPeer{p1.Peer, p2.Peer})).NetworkPolicy())))
 				}
 			}
@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	. "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
@@ -168,10 +169,114 @@ func (t *TestCaseGenerator) TwoPortProtocolTestCases() []*TestCase {
 	return cases
 }
 
+// portRange is a port range from port to endPort, described relative to the servers' ports, 80 and 81
+type portRange struct {
+	Description string
+	Protocol    *v1.Protocol
+	Port        int
+	EndPort     int32
+}
+
+func (p *portRange) NetworkPolicyPort() NetworkPolicyPort {
+	port := intstr.FromInt(p.Port)
+	endPort := p.EndPort
+	return NetworkPolicyPort{Protocol: p.Protocol, Port: &port, EndPort: &endPort}
+}
+
+var portRanges = []*portRange{
+	{Description: "spanning both served ports", Protocol: &tcp, Port: 80, EndPort: 81},
+	{Description: "spanning only port 80", Protocol: &tcp, Port: 79, EndPort: 80},
+	{Description: "spanning only port 81", Protocol: &tcp, Port: 81, EndPort: 90},
+	{Description: "of a single port", Protocol: &tcp, Port: 81, EndPort: 81},
+	{Description: "excluding the served ports", Protocol: &tcp, Port: 82, EndPort: 7980},
+	{Description: "of every port", Protocol: &tcp, Port: 1, EndPort: 65535},
+	{Description: "without a protocol, so TCP", Port: 80, EndPort: 81},
+	{Description: "spanning both served ports", Protocol: &udp, Port: 80, EndPort: 81},
+	{Description: "spanning both served ports", Protocol: &sctp, Port: 80, EndPort: 81},
+}
+
+// PortRangeTestCases open ranges of ports with endPort: ranges which do and don't span the servers' ports, for each
+// protocol, and ranges alongside single ports
+func (t *TestCaseGenerator) PortRangeTestCases() []*TestCase {
+	var cases []*TestCase
+	for _, isIngress := range []bool{false, true} {
+		dir := describeDirectionality(isIngress)
+		for _, portRange := range portRanges {
+			npp := portRange.NetworkPolicyPort()
+			tags := NewStringSet(dir, TagPortRange)
+			description := fmt.Sprintf("%s: port range %d-%d, %s", dir, portRange.Port, portRange.EndPort, portRange.Description)
+			if tag := describeProtocol(npp.Protocol); tag != nil {
+				tags.Add(*tag)
+				description = fmt.Sprintf("%s: port range %d-%d on %s, %s", dir, portRange.Port, portRange.EndPort, *npp.Protocol, portRange.Description)
+			}
+			cases = append(cases, NewSingleStepTestCase(description, tags, ProbeAllAvailable,
+				CreatePolicy(BuildPolicy(SetPorts(isIngress, []NetworkPolicyPort{npp})).NetworkPolicy())))
+		}
+
+		port80Range := (&portRange{Protocol: &tcp, Port: 80, EndPort: 80}).NetworkPolicyPort()
+		cases = append(cases,
+			NewSingleStepTestCase(fmt.Sprintf("%s: port range 80-80 on TCP, and port 81 on UDP", dir),
+				NewStringSet(TagMultiPortProtocol, dir, TagPortRange, TagNumberedPort, TagTCPProtocol, TagUDPProtocol),
+				ProbeAllAvailable,
+				CreatePolicy(BuildPolicy(SetPorts(isIngress, []NetworkPolicyPort{port80Range, {Protocol: &udp, Port: &port81}})).NetworkPolicy())),
+			NewSingleStepTestCase(fmt.Sprintf("%s: port range 80-80 on TCP, and named port serve-81-tcp", dir),
+				NewStringSet(TagMultiPortProtocol, dir, TagPortRange, TagNamedPort, TagTCPProtocol),
+				ProbeAllAvailable,
+				CreatePolicy(BuildPolicy(SetPorts(isIngress, []NetworkPolicyPort{port80Range, {Protocol: &tcp, Port: &portServe81TCP}})).NetworkPolicy())))
+	}
+	return cases
+}
+
+// NamedPortTestCases create pods which give the same port name to different ports, and open that name: each pod's own
+// ports have to be used to resolve it.  The pods are deleted afterwards, since resetting the cluster doesn't.
+func (t *TestCaseGenerator) NamedPortTestCases() []*TestCase {
+	portWeb := intstr.FromString("web")
+	podD := map[string]string{"pod": "d"}
+	targetPodD := SetPodSelector(metav1.LabelSelector{MatchLabels: podD})
+	allPeers := []NetworkPolicyPeer{{PodSelector: emptySelector, NamespaceSelector: emptySelector}}
+	webPorts := []NetworkPolicyPort{{Protocol: &tcp, Port: &portWeb}}
+
+	// ingress is opened to x/d and y/d, egress from x/a to anywhere
+	policies := func(isIngress bool) []*Action {
+		if isIngress {
+			return []*Action{
+				CreatePolicy(BuildPolicy(SetNamespace("x"), targetPodD, SetPeers(true, allPeers), SetPorts(true, webPorts)).NetworkPolicy()),
+				CreatePolicy(BuildPolicy(SetNamespace("y"), targetPodD, SetPeers(true, allPeers), SetPorts(true, webPorts)).NetworkPolicy()),
+			}
+		}
+		return []*Action{CreatePolicy(BuildPolicy(SetPeers(false, allPeers), SetPorts(false, webPorts)).NetworkPolicy())}
+	}
+	namedPortTestCase := func(description string, isIngress bool, xPortName string, yPortName string) *TestCase {
+		dir := describeDirectionality(isIngress)
+		actions := append([]*Action{
+			CreatePodWithPortNames("x", "d", podD, map[string]string{xPortName: portWeb.StrVal}),
+			CreatePodWithPortNames("y", "d", podD, map[string]string{yPortName: portWeb.StrVal}),
+		}, policies(isIngress)...)
+		return &TestCase{
+			Description: fmt.Sprintf("%s: %s", dir, description),
+			Tags:        NewStringSet(dir, TagNamedPort, TagTCPProtocol, TagCreatePod, TagDeletePod),
+			Steps: []*TestStep{
+				NewTestStep(ProbeAllAvailable, actions...),
+				NewTestStep(ProbeAllAvailable, DeletePod("x", "d"), DeletePod("y", "d")),
+			},
+		}
+	}
+
+	var cases []*TestCase
+	for _, isIngress := range []bool{false, true} {
+		cases = append(cases,
+			namedPortTestCase("named port web on TCP, which x/d maps to port 80 and y/d to port 81", isIngress, portServe80TCP.StrVal, portServe81TCP.StrVal),
+			namedPortTestCase("named port web on TCP, which x/d maps to port 80 on UDP and y/d to port 81 on TCP", isIngress, portServe80UDP.StrVal, portServe81TCP.StrVal))
+	}
+	return cases
+}
+
 func (t *TestCaseGenerator) PortProtocolTestCases() []*TestCase {
 	var cases []*TestCase
 	cases = append(cases, t.ZeroPortProtocolTestCases()...)
 	cases = append(cases, t.SinglePortProtocolTestCases()...)
 	cases = append(cases, t.TwoPortProtocolTestCases()...)
+	cases = append(cases, t.PortRangeTestCases()...)
+	cases = append(cases, t.NamedPortTestCases()...)
 	return cases
 }
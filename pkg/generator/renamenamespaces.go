@@ -0,0 +1,116 @@
+package generator
+
+import (
+	"strings"
+)
+
+// CanRunInRenamedNamespaces is true for test cases which only touch their own namespaces, so that copies of them can
+// run at the same time in different sets of namespaces.  AdminNetworkPolicies and CNI restarts affect every
+// namespace, and ipBlock peers are built from the IPs of the original namespaces' pods.
+func (t *TestCase) CanRunInRenamedNamespaces() bool {
+	return !t.Tags.ContainsAny([]string{TagAdminNetworkPolicy, TagBaselineAdminNetworkPolicy, TagChaos, TagPeerIPBlock})
+}
+
+// RenameNamespaces returns a copy of the test case, with the namespaces it refers to -- in its actions, policies, and
+// expected connectivity -- renamed by names.  Namespaces not in names are left alone.  Namespace labels aren't
+// touched, so policies selecting namespaces by label select the renamed namespaces, as long as they're labeled the
+// same as the originals.
+func (t *TestCase) RenameNamespaces(names map[string]string) *TestCase {
+	rename := func(ns string) string {
+		if renamed, ok := names[ns]; ok {
+			return renamed
+		}
+		return ns
+	}
+	var steps []*TestStep
+	for _, step := range t.Steps {
+		var actions []*Action
+		for _, action := range step.Actions {
+			actions = append(actions, action.renameNamespaces(rename))
+		}
+		var waivers []*Waiver
+		for _, waiver := range step.Waivers {
+			waivers = append(waivers, waiver.renameNamespaces(rename))
+		}
+		steps = append(steps, &TestStep{
+			Probe:    step.Probe,
+			Actions:  actions,
+			Expected: step.Expected.renameNamespaces(rename),
+			Waivers:  waivers,
+		})
+	}
+	return &TestCase{Description: t.Description, Tags: t.Tags, Steps: steps, Waiver: t.Waiver}
+}
+
+func (a *Action) renameNamespaces(rename func(string) string) *Action {
+	renamed := *a
+	switch {
+	case a.CreatePolicy != nil:
+		policy := a.CreatePolicy.Policy.DeepCopy()
+		policy.Namespace = rename(policy.Namespace)
+		renamed.CreatePolicy = &CreatePolicyAction{Policy: policy}
+	case a.UpdatePolicy != nil:
+		policy := a.UpdatePolicy.Policy.DeepCopy()
+		policy.Namespace = rename(policy.Namespace)
+		renamed.UpdatePolicy = &UpdatePolicyAction{Policy: policy}
+	case a.DeletePolicy != nil:
+		renamed.DeletePolicy = &DeletePolicyAction{Namespace: rename(a.DeletePolicy.Namespace), Name: a.DeletePolicy.Name}
+	case a.CreateNamespace != nil:
+		renamed.CreateNamespace = &CreateNamespaceAction{Namespace: rename(a.CreateNamespace.Namespace), Labels: a.CreateNamespace.Labels}
+	case a.SetNamespaceLabels != nil:
+		renamed.SetNamespaceLabels = &SetNamespaceLabelsAction{Namespace: rename(a.SetNamespaceLabels.Namespace), Labels: a.SetNamespaceLabels.Labels}
+	case a.DeleteNamespace != nil:
+		renamed.DeleteNamespace = &DeleteNamespaceAction{Namespace: rename(a.DeleteNamespace.Namespace)}
+	case a.ReadNetworkPolicies != nil:
+		var namespaces []string
+		for _, ns := range a.ReadNetworkPolicies.Namespaces {
+			namespaces = append(namespaces, rename(ns))
+		}
+		renamed.ReadNetworkPolicies = &ReadNetworkPoliciesAction{Namespaces: namespaces}
+	case a.CreatePod != nil:
+		renamed.CreatePod = &CreatePodAction{Namespace: rename(a.CreatePod.Namespace), Pod: a.CreatePod.Pod, Labels: a.CreatePod.Labels, PortNames: a.CreatePod.PortNames}
+	case a.SetPodLabels != nil:
+		renamed.SetPodLabels = &SetPodLabelsAction{Namespace: rename(a.SetPodLabels.Namespace), Pod: a.SetPodLabels.Pod, Labels: a.SetPodLabels.Labels}
+	case a.DeletePod != nil:
+		renamed.DeletePod = &DeletePodAction{Namespace: rename(a.DeletePod.Namespace), Pod: a.DeletePod.Pod}
+	case a.OpenConnection != nil:
+		c := a.OpenConnection
+		renamed.OpenConnection = &OpenConnectionAction{FromNamespace: rename(c.FromNamespace), FromPod: c.FromPod, ToNamespace: rename(c.ToNamespace), ToPod: c.ToPod, Port: c.Port}
+	case a.CloseConnection != nil:
+		c := a.CloseConnection
+		renamed.CloseConnection = &CloseConnectionAction{FromNamespace: rename(c.FromNamespace), FromPod: c.FromPod, ToNamespace: rename(c.ToNamespace), ToPod: c.ToPod, Port: c.Port}
+	}
+	return &renamed
+}
+
+func (m *ConnectivityMatrix) renameNamespaces(rename func(string) string) *ConnectivityMatrix {
+	if m == nil {
+		return nil
+	}
+	renamePod := func(pod string) string {
+		return renamePodNamespace(pod, rename)
+	}
+	renamed := &ConnectivityMatrix{Cells: map[string]map[string]bool{}}
+	for _, from := range m.Froms {
+		renamed.Froms = append(renamed.Froms, renamePod(from))
+	}
+	for _, to := range m.Tos {
+		renamed.Tos = append(renamed.Tos, renamePod(to))
+	}
+	for from, cells := range m.Cells {
+		renamed.Cells[renamePod(from)] = map[string]bool{}
+		for to, allowed := range cells {
+			renamed.Cells[renamePod(from)][renamePod(to)] = allowed
+		}
+	}
+	return renamed
+}
+
+// renamePodNamespace renames the namespace of a pod such as x/a
+func renamePodNamespace(pod string, rename func(string) string) string {
+	pieces := strings.SplitN(pod, "/", 2)
+	if len(pieces) != 2 {
+		return pod
+	}
+	return rename(pieces[0]) + "/" + pieces[1]
+}
@@ -0,0 +1,70 @@
+package generator
+
+import (
+	"fmt"
+	. "k8s.io/api/networking/v1"
+)
+
+// returnTrafficExchanges is how many exchanges the long variant of each return traffic case makes per job
+const returnTrafficExchanges = 20
+
+// ReturnTrafficTestCases check that policies are stateful: the responses to an allowed connection get through,
+// even though a policy on the other direction would block new connections which look just like them.  Each case is
+// run once with a single exchange per job, and once with a long series of exchanges, which catches CNIs whose
+// connection tracking lets the first few responses through but then loses track of them.
+func (t *TestCaseGenerator) ReturnTrafficTestCases() []*TestCase {
+	xa := &NetpolTarget{Namespace: "x", PodSelector: *podAMatchLabelsSelector}
+	toOrFromZC := []NetworkPolicyPeer{{PodSelector: podCMatchLabelsSelector, NamespaceSelector: nsZMatchLabelsSelector}}
+
+	var cases []*TestCase
+	for _, c := range []struct {
+		Description string
+		Tags        []string
+		Policy      *Netpol
+	}{
+		{
+			Description: "deny all ingress to x/a: responses to x/a's connections get in",
+			Tags:        []string{TagIngress, TagDenyAll},
+			Policy:      &Netpol{Name: "deny-ingress-x-a", Target: xa, Ingress: DenyAll},
+		},
+		{
+			Description: "deny all egress from x/a: responses to connections to x/a get out",
+			Tags:        []string{TagEgress, TagDenyAll},
+			Policy:      &Netpol{Name: "deny-egress-x-a", Target: xa, Egress: DenyAll},
+		},
+		{
+			Description: "allow egress from x/a to z/c, deny all ingress to x/a: responses from z/c get in",
+			Tags:        []string{TagIngress, TagEgress, TagDenyAll, TagPodsByLabel},
+			Policy: &Netpol{
+				Name:    "allow-egress-x-a-to-z-c",
+				Target:  xa,
+				Ingress: DenyAll,
+				Egress:  &NetpolPeers{Rules: []*Rule{{Peers: toOrFromZC}}},
+			},
+		},
+		{
+			Description: "allow ingress to x/a from z/c, deny all egress from x/a: responses to z/c get out",
+			Tags:        []string{TagIngress, TagEgress, TagDenyAll, TagPodsByLabel},
+			Policy: &Netpol{
+				Name:    "allow-ingress-x-a-from-z-c",
+				Target:  xa,
+				Ingress: &NetpolPeers{Rules: []*Rule{{Peers: toOrFromZC}}},
+				Egress:  DenyAll,
+			},
+		},
+	} {
+		actions := []*Action{CreatePolicy(c.Policy.NetworkPolicy())}
+		if c.Policy.Egress != nil && t.AllowDNS {
+			actions = append(actions, CreatePolicy(AllowDNSPolicy(xa).NetworkPolicy()))
+		}
+		tags := append([]string{TagReturnTraffic}, c.Tags...)
+		cases = append(cases,
+			NewSingleStepTestCase("return traffic: "+c.Description, NewStringSet(tags...), ProbeAllAvailable, actions...),
+			NewSingleStepTestCase(
+				fmt.Sprintf("return traffic, %d exchanges: %s", returnTrafficExchanges, c.Description),
+				NewStringSet(tags...),
+				ProbeAllAvailable.WithExchanges(returnTrafficExchanges),
+				actions...))
+	}
+	return cases
+}
@@ -10,5 +10,10 @@ import (
 func TestGenerator(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunTestCaseGeneratorTests()
+	RunYamlTestCaseTests()
+	RunTagExpressionTests()
+	RunCNIProfileTests()
+	RunWaiverTests()
+	RunPolicyFuzzerTests()
 	RunSpecs(t, "generator suite")
 }
@@ -0,0 +1,185 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+	"unicode"
+)
+
+// TagExpression is a boolean expression over tags, such as `(ingress && ip-block-with-except) || !udp`, which selects
+// the test cases whose tags it matches.  `!` binds tightest, then `&&`, then `||`; parentheses group.
+type TagExpression interface {
+	Matches(tags StringSet) bool
+	String() string
+}
+
+type tagTerm struct {
+	Tag string
+}
+
+func (t *tagTerm) Matches(tags StringSet) bool {
+	return tags.ContainsAny([]string{t.Tag})
+}
+
+func (t *tagTerm) String() string {
+	return t.Tag
+}
+
+type notExpression struct {
+	Operand TagExpression
+}
+
+func (n *notExpression) Matches(tags StringSet) bool {
+	return !n.Operand.Matches(tags)
+}
+
+func (n *notExpression) String() string {
+	return "!" + n.Operand.String()
+}
+
+type binaryExpression struct {
+	Operator string
+	Left     TagExpression
+	Right    TagExpression
+}
+
+func (b *binaryExpression) Matches(tags StringSet) bool {
+	if b.Operator == "&&" {
+		return b.Left.Matches(tags) && b.Right.Matches(tags)
+	}
+	return b.Left.Matches(tags) || b.Right.Matches(tags)
+}
+
+func (b *binaryExpression) String() string {
+	return fmt.Sprintf("(%s %s %s)", b.Left.String(), b.Operator, b.Right.String())
+}
+
+// ParseTagExpression parses a TagExpression; every tag in it must be a valid tag
+func ParseTagExpression(expression string) (TagExpression, error) {
+	tokens, err := tokenizeTagExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	parser := &tagExpressionParser{Tokens: tokens}
+	parsed, err := parser.parseOr()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to parse tag expression '%s'", expression)
+	}
+	if parser.Position < len(tokens) {
+		return nil, errors.Errorf("unable to parse tag expression '%s': unexpected '%s'", expression, tokens[parser.Position])
+	}
+	return parsed, nil
+}
+
+func isTagCharacter(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '/'
+}
+
+func tokenizeTagExpression(expression string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '(' || runes[i] == ')' || runes[i] == '!':
+			tokens = append(tokens, string(runes[i]))
+			i++
+		case strings.HasPrefix(string(runes[i:]), "&&") || strings.HasPrefix(string(runes[i:]), "||"):
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		case isTagCharacter(runes[i]):
+			start := i
+			for i < len(runes) && isTagCharacter(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, errors.Errorf("unable to parse tag expression '%s': unexpected '%c' at position %d", expression, runes[i], i+1)
+		}
+	}
+	return tokens, nil
+}
+
+type tagExpressionParser struct {
+	Tokens   []string
+	Position int
+}
+
+func (p *tagExpressionParser) peek() string {
+	if p.Position < len(p.Tokens) {
+		return p.Tokens[p.Position]
+	}
+	return ""
+}
+
+func (p *tagExpressionParser) parseOr() (TagExpression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.Position++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpression{Operator: "||", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *tagExpressionParser) parseAnd() (TagExpression, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.Position++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpression{Operator: "&&", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *tagExpressionParser) parseNot() (TagExpression, error) {
+	if p.peek() == "!" {
+		p.Position++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpression{Operand: operand}, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *tagExpressionParser) parseTerm() (TagExpression, error) {
+	token := p.peek()
+	switch token {
+	case "":
+		return nil, errors.Errorf("unexpected end of expression")
+	case "(":
+		p.Position++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.Errorf("missing ')'")
+		}
+		p.Position++
+		return inner, nil
+	case ")", "&&", "||":
+		return nil, errors.Errorf("unexpected '%s'", token)
+	}
+	if err := ValidateTags([]string{token}); err != nil {
+		return nil, err
+	}
+	p.Position++
+	return &tagTerm{Tag: token}, nil
+}
@@ -0,0 +1,59 @@
+package generator
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunTagExpressionTests() {
+	Describe("Tag expressions", func() {
+		ingressWithExcept := NewStringSet(TagIngress, TagIPBlockWithExcept, TagTCPProtocol)
+		egressUDP := NewStringSet(TagEgress, TagUDPProtocol)
+		ingressUDP := NewStringSet(TagIngress, TagUDPProtocol)
+
+		It("should match tags, with ! binding tighter than &&, and && tighter than ||", func() {
+			for _, c := range []struct {
+				Expression string
+				String     string
+				Matches    []bool
+			}{
+				{Expression: "ingress", String: "ingress", Matches: []bool{true, false, true}},
+				{Expression: "!udp", String: "!udp", Matches: []bool{true, false, false}},
+				{Expression: "ingress && udp", String: "(ingress && udp)", Matches: []bool{false, false, true}},
+				{Expression: "egress || ip-block-with-except && tcp", String: "(egress || (ip-block-with-except && tcp))", Matches: []bool{true, true, false}},
+				{Expression: "(ingress && ip-block-with-except) || !udp", String: "((ingress && ip-block-with-except) || !udp)", Matches: []bool{true, false, false}},
+				{Expression: "!(ingress||egress)", String: "!(ingress || egress)", Matches: []bool{false, false, false}},
+				{Expression: "!!ingress && !egress", String: "(!!ingress && !egress)", Matches: []bool{true, false, true}},
+			} {
+				expression, err := ParseTagExpression(c.Expression)
+				Expect(err).To(Succeed())
+				Expect(expression.String()).To(Equal(c.String))
+				var matches []bool
+				for _, tags := range []StringSet{ingressWithExcept, egressUDP, ingressUDP} {
+					matches = append(matches, expression.Matches(tags))
+				}
+				Expect(matches).To(Equal(c.Matches), c.Expression)
+			}
+		})
+
+		It("should reject malformed expressions and unknown tags", func() {
+			for _, expression := range []string{"", "ingress &&", "(ingress", "ingress)", "ingress egress", "&& ingress", "ingress & egress", "cidr", "!"} {
+				_, err := ParseTagExpression(expression)
+				Expect(err).To(HaveOccurred(), expression)
+			}
+		})
+
+		It("should select test cases in place of included and excluded tags", func() {
+			filter, err := ParseTagExpression("ingress && !udp")
+			Expect(err).To(Succeed())
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{TagEgress}, []string{TagIngress})
+			gen.Filter = filter
+			testCases := []*TestCase{
+				NewTestCase("ingress with except", ingressWithExcept),
+				NewTestCase("egress udp", egressUDP),
+				NewTestCase("ingress udp", ingressUDP),
+			}
+			Expect(gen.FilterTestCases(testCases)).To(Equal(testCases[:1]))
+		})
+	})
+}
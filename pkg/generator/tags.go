@@ -17,6 +17,10 @@ const (
 	TagPeerIPBlock   = "peer-ipblock"
 	TagPeerPods      = "peer-pods"
 	TagMiscellaneous = "miscellaneous"
+
+	TagAdminNetworkPolicy         = "admin-network-policy"
+	TagBaselineAdminNetworkPolicy = "baseline-admin-network-policy"
+	TagChaos                      = "chaos"
 )
 
 const (
@@ -37,8 +41,10 @@ const (
 )
 
 const (
-	TagIngress = "ingress"
-	TagEgress  = "egress"
+	TagIngress     = "ingress"
+	TagEgress      = "egress"
+	TagIngressOnly = "ingress-only"
+	TagEgressOnly  = "egress-only"
 )
 
 const (
@@ -48,6 +54,7 @@ const (
 	TagAnyPortProtocol   = "any-port-protocol"
 	TagMultiPeer         = "multi-peer"
 	TagMultiPortProtocol = "multi-port/protocol"
+	TagMultiRule         = "multi-rule"
 )
 
 const (
@@ -61,12 +68,16 @@ const (
 const (
 	TagIPBlockNoExcept   = "ip-block-no-except"
 	TagIPBlockWithExcept = "ip-block-with-except"
+	TagIPBlockNodeIP     = "ip-block-node-ip"
+	// TagIPBlockExternalEndpoint cases have ipBlocks of an endpoint outside the pod network, which is probed as well
+	TagIPBlockExternalEndpoint = "ip-block-external-endpoint"
 )
 
 const (
 	TagAnyPort      = "any-port"
 	TagNumberedPort = "numbered-port"
 	TagNamedPort    = "named-port"
+	TagPortRange    = "port-range"
 )
 
 const (
@@ -76,10 +87,35 @@ const (
 )
 
 const (
-	TagPathological = "pathological"
-	TagConflict     = "conflict"
-	TagExample      = "example"
-	TagUpstreamE2E  = "upstream-e2e"
+	TagPathological  = "pathological"
+	TagConflict      = "conflict"
+	TagExample       = "example"
+	TagUpstreamE2E   = "upstream-e2e"
+	TagTemplate      = "template"
+	TagUserDefined   = "user-defined"
+	TagReturnTraffic = "return-traffic"
+	TagNoOp          = "no-op"
+	TagFuzz          = "fuzz"
+)
+
+const (
+	TagLongLivedConnection = "long-lived-connection"
+	TagDNS                 = "dns"
+)
+
+const (
+	TagANPAllow = "anp-allow"
+	TagANPDeny  = "anp-deny"
+	TagANPPass  = "anp-pass"
+)
+
+const (
+	TagBANPAllow = "banp-allow"
+	TagBANPDeny  = "banp-deny"
+)
+
+const (
+	TagRestartCNI = "restart-cni"
 )
 
 var AllTags = map[string][]string{
@@ -101,6 +137,8 @@ var AllTags = map[string][]string{
 	TagDirection: {
 		TagIngress,
 		TagEgress,
+		TagIngressOnly,
+		TagEgressOnly,
 	},
 	TagPolicyStack: {},
 	TagRule: {
@@ -110,6 +148,7 @@ var AllTags = map[string][]string{
 		TagAnyPortProtocol,
 		TagMultiPeer,
 		TagMultiPortProtocol,
+		TagMultiRule,
 	},
 	TagPeerPods: {
 		TagAllPods,
@@ -121,11 +160,14 @@ var AllTags = map[string][]string{
 	TagPeerIPBlock: {
 		TagIPBlockNoExcept,
 		TagIPBlockWithExcept,
+		TagIPBlockNodeIP,
+		TagIPBlockExternalEndpoint,
 	},
 	TagPort: {
 		TagAnyPort,
 		TagNumberedPort,
 		TagNamedPort,
+		TagPortRange,
 	},
 	TagProtocol: {
 		TagTCPProtocol,
@@ -137,6 +179,25 @@ var AllTags = map[string][]string{
 		TagConflict,
 		TagExample,
 		TagUpstreamE2E,
+		TagTemplate,
+		TagUserDefined,
+		TagReturnTraffic,
+		TagNoOp,
+		TagFuzz,
+		TagLongLivedConnection,
+		TagDNS,
+	},
+	TagAdminNetworkPolicy: {
+		TagANPAllow,
+		TagANPDeny,
+		TagANPPass,
+	},
+	TagBaselineAdminNetworkPolicy: {
+		TagBANPAllow,
+		TagBANPDeny,
+	},
+	TagChaos: {
+		TagRestartCNI,
 	},
 }
 
@@ -0,0 +1,114 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	networkingv1 "k8s.io/api/networking/v1"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// TemplateValues drives expansion of a policy template.  Matrix maps each template variable to the values it
+// should take; one test case is generated for every combination.
+//
+// Example:
+//
+//	matrix:
+//	  port: [80, 81]
+//	  namespace: [x, y]
+type TemplateValues struct {
+	Matrix map[string][]interface{} `json:"matrix"`
+}
+
+// Combinations returns the cartesian product of the matrix, in a stable order
+func (v *TemplateValues) Combinations() []map[string]interface{} {
+	var keys []string
+	for key := range v.Matrix {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]interface{}{{}}
+	for _, key := range keys {
+		var next []map[string]interface{}
+		for _, combination := range combinations {
+			for _, value := range v.Matrix[key] {
+				extended := map[string]interface{}{key: value}
+				for k, val := range combination {
+					extended[k] = val
+				}
+				next = append(next, extended)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+func describeCombination(combination map[string]interface{}) string {
+	var pieces []string
+	for key, value := range combination {
+		pieces = append(pieces, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(pieces)
+	return strings.Join(pieces, ", ")
+}
+
+// TemplateTestCases renders the template -- which should produce one or more yaml NetworkPolicy documents -- for
+// each combination of values, and makes a single-step test case which creates the resulting policies.
+func TemplateTestCases(name string, templateText string, values *TemplateValues) ([]*TestCase, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(templateText)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse template %s", name)
+	}
+
+	var cases []*TestCase
+	for _, combination := range values.Combinations() {
+		description := describeCombination(combination)
+		rendered := &bytes.Buffer{}
+		if err := tmpl.Execute(rendered, combination); err != nil {
+			return nil, errors.Wrapf(err, "unable to render template %s with %s", name, description)
+		}
+
+		var actions []*Action
+		for _, doc := range utils.SplitYamlDocuments(rendered.String()) {
+			policy := &networkingv1.NetworkPolicy{}
+			if err := yaml.UnmarshalStrict([]byte(doc), policy); err != nil {
+				return nil, errors.Wrapf(err, "unable to unmarshal policy rendered from template %s with %s:\n%s", name, description, doc)
+			}
+			actions = append(actions, CreatePolicy(policy))
+		}
+		if len(actions) == 0 {
+			return nil, errors.Errorf("template %s rendered no policies with %s", name, description)
+		}
+
+		cases = append(cases, NewSingleStepTestCase(fmt.Sprintf("template %s: %s", name, description), NewStringSet(TagTemplate), ProbeAllAvailable, actions...))
+	}
+	return cases, nil
+}
+
+func LoadTemplateTestCases(templatePath string, valuesPath string) ([]*TestCase, error) {
+	templateBytes, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read template %s", templatePath)
+	}
+
+	values := &TemplateValues{}
+	if valuesPath != "" {
+		valuesBytes, err := ioutil.ReadFile(valuesPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read template values %s", valuesPath)
+		}
+		if err := yaml.UnmarshalStrict(valuesBytes, values); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmarshal template values %s", valuesPath)
+		}
+	}
+
+	return TemplateTestCases(filepath.Base(templatePath), string(templateBytes), values)
+}
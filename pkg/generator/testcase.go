@@ -13,6 +13,8 @@ type TestCase struct {
 	Description string
 	Tags        StringSet
 	Steps       []*TestStep
+	// Waiver, if set, is why the test case is known to fail -- see CNIProfile -- so its failures don't count
+	Waiver string
 }
 
 func NewSingleStepTestCase(description string, tags StringSet, pp *ProbeConfig, actions ...*Action) *TestCase {
@@ -64,6 +66,22 @@ func (t *TestCase) collectActionsAndPolicies() (map[string]bool, []*networkingv1
 				features[ActionFeatureSetPodLabels] = true
 			} else if action.DeletePod != nil {
 				features[ActionFeatureDeletePod] = true
+			} else if action.CreateAdminNetworkPolicy != nil {
+				features[ActionFeatureCreateAdminNetworkPolicy] = true
+			} else if action.DeleteAdminNetworkPolicy != nil {
+				features[ActionFeatureDeleteAdminNetworkPolicy] = true
+			} else if action.CreateBaselineAdminNetworkPolicy != nil {
+				features[ActionFeatureCreateBaselineAdminNetworkPolicy] = true
+			} else if action.DeleteBaselineAdminNetworkPolicy != nil {
+				features[ActionFeatureDeleteBaselineAdminNetworkPolicy] = true
+			} else if action.RestartCNI != nil {
+				features[ActionFeatureRestartCNI] = true
+			} else if action.WaitForCNIRecovery != nil {
+				features[ActionFeatureWaitForCNIRecovery] = true
+			} else if action.OpenConnection != nil {
+				features[ActionFeatureOpenConnection] = true
+			} else if action.CloseConnection != nil {
+				features[ActionFeatureCloseConnection] = true
 			} else {
 				panic("invalid Action")
 			}
@@ -114,6 +132,9 @@ const (
 	ProbeModeServiceName = "service-name"
 	ProbeModeServiceIP   = "service-ip"
 	ProbeModePodIP       = "pod-ip"
+	// ProbeModeRoute goes through OpenShift Routes; it's only used for supplementary route probes, so it isn't one of
+	// AllProbeModes
+	ProbeModeRoute = "route"
 )
 
 var AllProbeModes = []string{
@@ -140,6 +161,52 @@ type ProbeConfig struct {
 	AllAvailable bool
 	PortProtocol *PortProtocol
 	Mode         ProbeMode
+	// PinMode means that this step needs this specific Mode, so a global destination type override won't touch it
+	PinMode bool
+	// Exchanges is how many request/response exchanges each probe job makes, one after another; a job is only
+	// allowed if all of them succeed.  0 is the same as 1.
+	Exchanges int
+}
+
+// WithPinnedMode returns a copy of the ProbeConfig, with its Mode set to mode and pinned
+func (p *ProbeConfig) WithPinnedMode(mode ProbeMode) *ProbeConfig {
+	return &ProbeConfig{
+		AllAvailable: p.AllAvailable,
+		PortProtocol: p.PortProtocol,
+		Mode:         mode,
+		PinMode:      true,
+		Exchanges:    p.Exchanges,
+	}
+}
+
+// WithExchanges returns a copy of the ProbeConfig, which makes exchanges request/response exchanges per job
+func (p *ProbeConfig) WithExchanges(exchanges int) *ProbeConfig {
+	return &ProbeConfig{
+		AllAvailable: p.AllAvailable,
+		PortProtocol: p.PortProtocol,
+		Mode:         p.Mode,
+		PinMode:      p.PinMode,
+		Exchanges:    exchanges,
+	}
+}
+
+// OverrideProbeMode sets the probe mode of every step which hasn't pinned its mode.  Pinned steps are left
+// alone, so that suites with steps which need a specific mode can still be run with an override.
+func OverrideProbeMode(testCases []*TestCase, mode ProbeMode) {
+	for _, testCase := range testCases {
+		for _, step := range testCase.Steps {
+			if step.Probe.PinMode {
+				continue
+			}
+			// copy instead of mutating, since ProbeConfigs are shared between test cases
+			step.Probe = &ProbeConfig{
+				AllAvailable: step.Probe.AllAvailable,
+				PortProtocol: step.Probe.PortProtocol,
+				Mode:         mode,
+				Exchanges:    step.Probe.Exchanges,
+			}
+		}
+	}
 }
 
 func NewAllAvailable(mode ProbeMode) *ProbeConfig {
@@ -158,6 +225,11 @@ type PortProtocol struct {
 type TestStep struct {
 	Probe   *ProbeConfig
 	Actions []*Action
+	// Expected, if set, is the connectivity to check the probe against, instead of what the policies would allow,
+	// for the pairs it has expectations for
+	Expected *ConnectivityMatrix
+	// Waivers override the expected results of some probes, which are known to be wrong; see Waivers
+	Waivers []*Waiver
 }
 
 func NewTestStep(pp *ProbeConfig, actions ...*Action) *TestStep {
@@ -1,5 +1,11 @@
 package generator
 
+import (
+	"github.com/pkg/errors"
+	"math/rand"
+	"regexp"
+)
+
 /*
 TODO
 Test cases:
@@ -36,11 +42,23 @@ Test cases:
 2 policies with both ingress and egress
 */
 type TestCaseGenerator struct {
-	PodIP        string
+	PodIP string
+	// NodeIP is the IP of the node which PodIP's pod runs on; test cases which need it are left out if it's empty
+	NodeIP string
+	// ExternalIP and ExternalPort are the IPv4 address and port of an endpoint outside the pod network, which is probed
+	// at every step; test cases which need it are left out if ExternalIP is empty
+	ExternalIP   string
+	ExternalPort int
 	AllowDNS     bool
 	Namespaces   []string
 	Tags         []string
 	ExcludedTags []string
+	// Filter, if set, selects test cases in place of Tags and ExcludedTags
+	Filter TagExpression
+	// IncludedNames and ExcludedNames further select test cases by description: if there are any IncludedNames, one
+	// of them has to match, and none of the ExcludedNames may
+	IncludedNames []*regexp.Regexp
+	ExcludedNames []*regexp.Regexp
 }
 
 func NewTestCaseGenerator(allowDNS bool, podIP string, namespaces []string, tags []string, excludedTags []string) *TestCaseGenerator {
@@ -62,7 +80,7 @@ func flatten(caseSlices ...[]*TestCase) []*TestCase {
 }
 
 func (t *TestCaseGenerator) GenerateAllTestCases() []*TestCase {
-	return flatten(
+	cases := flatten(
 		t.TargetTestCases(),
 		t.RulesTestCases(),
 		t.PeersTestCases(),
@@ -70,15 +88,86 @@ func (t *TestCaseGenerator) GenerateAllTestCases() []*TestCase {
 		t.ExampleTestCases(),
 		t.ActionTestCases(),
 		t.ConflictTestCases(),
-		t.UpstreamE2ETestCases())
+		t.UpstreamE2ETestCases(),
+		t.AdminNetworkPolicyTestCases(),
+		t.BaselineAdminNetworkPolicyTestCases(),
+		t.ChaosTestCases(),
+		t.NodeIPBlockTestCases(),
+		t.ExternalEndpointTestCases(),
+		t.ReturnTrafficTestCases(),
+		t.LongLivedConnectionTestCases(),
+		t.DNSTestCases(),
+		t.NoOpTestCases())
+	for _, testCase := range cases {
+		testCase.AddDerivedTags()
+	}
+	return cases
 }
 
 func (t *TestCaseGenerator) GenerateTestCases() []*TestCase {
+	return t.FilterTestCases(t.GenerateAllTestCases())
+}
+
+// FilterTestCases applies the generator's included and excluded tags to test cases, which may come from elsewhere
+// CompileNamePatterns compiles regular expressions for IncludedNames and ExcludedNames
+func CompileNamePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid test case name pattern '%s'", pattern)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, description string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(description) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *TestCaseGenerator) FilterTestCases(testCases []*TestCase) []*TestCase {
 	var cases []*TestCase
-	for _, testcase := range t.GenerateAllTestCases() {
-		if (len(t.Tags) == 0 || testcase.Tags.ContainsAny(t.Tags)) && !testcase.Tags.ContainsAny(t.ExcludedTags) {
+	for _, testcase := range testCases {
+		if len(t.IncludedNames) > 0 && !matchesAny(t.IncludedNames, testcase.Description) {
+			continue
+		}
+		if matchesAny(t.ExcludedNames, testcase.Description) {
+			continue
+		}
+		if t.Filter != nil {
+			if t.Filter.Matches(testcase.Tags) {
+				cases = append(cases, testcase)
+			}
+		} else if (len(t.Tags) == 0 || testcase.Tags.ContainsAny(t.Tags)) && !testcase.Tags.ContainsAny(t.ExcludedTags) {
 			cases = append(cases, testcase)
 		}
 	}
 	return cases
 }
+
+// ShuffleTestCases returns a copy of testCases in a random order, which is determined by seed
+func ShuffleTestCases(testCases []*TestCase, seed int64) []*TestCase {
+	shuffled := append([]*TestCase{}, testCases...)
+	rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
+
+// RepeatTestCases returns testCases with each one repeated times times in a row, i.e. to tell test cases which fail
+// sometimes from those which always fail
+func RepeatTestCases(testCases []*TestCase, times int) []*TestCase {
+	var repeated []*TestCase
+	for _, testCase := range testCases {
+		for i := 0; i < times; i++ {
+			repeated = append(repeated, testCase)
+		}
+	}
+	return repeated
+}
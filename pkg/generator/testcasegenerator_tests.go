@@ -3,6 +3,7 @@ package generator
 import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	. "k8s.io/api/networking/v1"
 )
 
 func RunTestCaseGeneratorTests() {
@@ -16,10 +17,261 @@ func RunTestCaseGeneratorTests() {
 			Expect(len(gen.UpstreamE2ETestCases())).To(Equal(13))
 			Expect(len(gen.TargetTestCases())).To(Equal(6))
 			Expect(len(gen.ExampleTestCases())).To(Equal(1))
-			Expect(len(gen.PortProtocolTestCases())).To(Equal(58))
+			Expect(len(gen.PortProtocolTestCases())).To(Equal(84))
+			Expect(len(gen.PortRangeTestCases())).To(Equal(22))
+			Expect(len(gen.NamedPortTestCases())).To(Equal(4))
 			Expect(len(gen.ConflictTestCases())).To(Equal(16))
+			Expect(len(gen.AdminNetworkPolicyTestCases())).To(Equal(5))
+			Expect(len(gen.BaselineAdminNetworkPolicyTestCases())).To(Equal(6))
+			Expect(len(gen.ChaosTestCases())).To(Equal(2))
+			Expect(len(gen.NodeIPBlockTestCases())).To(Equal(0))
+			Expect(len(gen.ExternalEndpointTestCases())).To(Equal(0))
+			Expect(len(gen.ReturnTrafficTestCases())).To(Equal(8))
+			Expect(len(gen.LongLivedConnectionTestCases())).To(Equal(2))
+			Expect(len(gen.DNSTestCases())).To(Equal(3))
+			Expect(len(gen.NoOpTestCases())).To(Equal(6))
 
-			Expect(len(gen.GenerateTestCases())).To(Equal(216))
+			Expect(len(gen.GenerateTestCases())).To(Equal(274))
+		})
+
+		It("Derived tags", func() {
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{})
+			for _, testCase := range gen.GenerateAllTestCases() {
+				Expect(testCase.Tags).To(HaveKey(TagDirection), testCase.Description)
+			}
+
+			allowDNS := NewSingleStepTestCase("", NewStringSet(), ProbeAllAvailable,
+				CreatePolicy((&Netpol{Name: "deny-egress", Target: NewNetpolTarget("x", nil, nil), Egress: DenyAll}).NetworkPolicy()),
+				CreatePolicy(AllowDNSPolicy(NewNetpolTarget("x", nil, nil)).NetworkPolicy()))
+			Expect(allowDNS.DerivedTags().Keys()).To(Equal([]string{TagDenyAll, TagDirection, TagEgress, TagEgressOnly, TagRule}))
+
+			peers := NewSingleStepTestCase("", NewStringSet(), ProbeAllAvailable,
+				CreatePolicy(BuildPolicy(SetPeers(true, []NetworkPolicyPeer{
+					{PodSelector: podAMatchLabelsSelector},
+					{NamespaceSelector: emptySelector},
+				})).NetworkPolicy()))
+			Expect(peers.DerivedTags()).To(HaveKey(TagMultiPeer))
+			Expect(peers.DerivedTags()).To(HaveKey(TagPodsByLabel))
+			Expect(peers.DerivedTags()).To(HaveKey(TagPolicyNamespace))
+			Expect(peers.DerivedTags()).To(HaveKey(TagAllNamespaces))
+			Expect(peers.DerivedTags()).To(HaveKey(TagAllPods))
+			Expect(peers.DerivedTags()).NotTo(HaveKey(TagIngressOnly))
+
+			endPort := int32(81)
+			portRange := NewSingleStepTestCase("", NewStringSet(), ProbeAllAvailable,
+				CreatePolicy(BuildPolicy(SetPorts(false, []NetworkPolicyPort{{Protocol: &udp, Port: &port80, EndPort: &endPort}})).NetworkPolicy()))
+			Expect(portRange.DerivedTags()).To(HaveKey(TagPortRange))
+			Expect(portRange.DerivedTags()).To(HaveKey(TagUDPProtocol))
+		})
+
+		It("Template test cases", func() {
+			values := &TemplateValues{Matrix: map[string][]interface{}{
+				"port":      {80, 81},
+				"namespace": {"x", "y", "z"},
+			}}
+			templateText := `
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow-{{.port}}
+  namespace: {{.namespace}}
+spec:
+  podSelector: {}
+  ingress:
+    - ports:
+        - port: {{.port}}
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-egress
+  namespace: {{.namespace}}
+spec:
+  podSelector: {}
+  policyTypes: [Egress]
+`
+			testCases, err := TemplateTestCases("test", templateText, values)
+			Expect(err).To(BeNil())
+			Expect(testCases).To(HaveLen(6))
+			Expect(testCases[0].Description).To(Equal("template test: namespace=x, port=80"))
+			Expect(testCases[0].Steps[0].Actions).To(HaveLen(2))
+			Expect(testCases[0].Steps[0].Actions[0].CreatePolicy.Policy.Name).To(Equal("allow-80"))
+			Expect(testCases[5].Steps[0].Actions[1].CreatePolicy.Policy.Namespace).To(Equal("z"))
+
+			_, err = TemplateTestCases("test", "name: {{.missing}}", values)
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("Override probe mode, except for pinned steps", func() {
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{})
+			testCases := gen.SinglePeersTestCases()
+
+			OverrideProbeMode(testCases, ProbeModeServiceIP)
+
+			for _, testCase := range testCases {
+				for _, step := range testCase.Steps {
+					if testCase.Tags.ContainsAny([]string{TagIPBlockNoExcept, TagIPBlockWithExcept}) {
+						Expect(step.Probe.Mode).To(Equal(ProbeMode(ProbeModePodIP)))
+					} else {
+						Expect(step.Probe.Mode).To(Equal(ProbeMode(ProbeModeServiceIP)))
+					}
+				}
+			}
+			Expect(ProbeAllAvailable.Mode).To(Equal(ProbeMode(ProbeModeServiceName)))
+		})
+
+		It("Rename namespaces", func() {
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{})
+			names := map[string]string{"x": "x-1", "y": "y-1", "z": "z-1"}
+
+			for _, testCase := range gen.GenerateAllTestCases() {
+				if !testCase.CanRunInRenamedNamespaces() {
+					Expect(testCase.Tags.ContainsAny([]string{TagAdminNetworkPolicy, TagBaselineAdminNetworkPolicy, TagChaos, TagPeerIPBlock})).To(BeTrue())
+					continue
+				}
+				renamed := testCase.RenameNamespaces(names)
+				Expect(renamed.Description).To(Equal(testCase.Description))
+				for i, step := range renamed.Steps {
+					for j, action := range step.Actions {
+						if action.CreatePolicy != nil {
+							original := testCase.Steps[i].Actions[j].CreatePolicy.Policy
+							Expect(action.CreatePolicy.Policy.Namespace).To(Equal(names[original.Namespace]))
+							Expect(action.CreatePolicy.Policy.Spec).To(Equal(original.Spec))
+						}
+					}
+				}
+			}
+
+			matrix, err := ParseConnectivityMatrix(`
+    x/a y/a
+x/a .   X
+y/a -   .`)
+			Expect(err).To(Succeed())
+			testCase := NewTestCase("pods", NewStringSet(TagCreatePod),
+				NewTestStep(ProbeAllAvailable, CreatePod("y", "d", map[string]string{"pod": "d"}), DeletePolicy("x", "deny-all")))
+			testCase.Steps[0].Expected = matrix
+			renamed := testCase.RenameNamespaces(names)
+			Expect(renamed.Steps[0].Actions[0].CreatePod).To(Equal(&CreatePodAction{Namespace: "y-1", Pod: "d", Labels: map[string]string{"pod": "d"}}))
+			Expect(renamed.Steps[0].Actions[1].DeletePolicy).To(Equal(&DeletePolicyAction{Namespace: "x-1", Name: "deny-all"}))
+			Expect(renamed.Steps[0].Expected.Froms).To(Equal([]string{"x-1/a", "y-1/a"}))
+			allowed, ok := renamed.Steps[0].Expected.Expectation("x-1/a", "y-1/a")
+			Expect(ok).To(BeTrue())
+			Expect(allowed).To(BeFalse())
+			Expect(testCase.Steps[0].Actions[0].CreatePod.Namespace).To(Equal("y"))
+		})
+
+		It("Node IP ipBlock test cases, with per-CNI expectation overrides", func() {
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{})
+			gen.NodeIP = "10.0.0.7"
+			testCases := gen.NodeIPBlockTestCases()
+			Expect(testCases).To(HaveLen(4))
+			Expect(testCases[2].Steps[0].Actions[0].CreatePolicy.Policy.Spec.Ingress[0].From[0].IPBlock.CIDR).To(Equal("10.0.0.7/32"))
+
+			description := "allow ingress to x/a from ipBlock of z/c's node IP, probe by service-ip"
+			matrix, err := ParseConnectivityMatrix("x/a\nz/c .")
+			Expect(err).To(Succeed())
+			overrides := ExpectationOverrides{"calico": {description: {matrix}}}
+			Expect(overrides.Apply(testCases, "calico")).To(Succeed())
+			Expect(testCases[3].Description).To(Equal(description))
+			Expect(testCases[3].Steps[0].Expected).To(Equal(matrix))
+			Expect(testCases[2].Steps[0].Expected).To(BeNil())
+
+			Expect(overrides.Apply(testCases, "cilium")).NotTo(Succeed())
+			Expect(overrides.Apply(testCases[:2], "calico")).NotTo(Succeed())
+		})
+
+		It("External endpoint ipBlock test cases", func() {
+			gen := NewTestCaseGenerator(false, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{})
+			gen.ExternalIP = "172.18.0.5"
+			gen.ExternalPort = 8080
+			testCases := gen.ExternalEndpointTestCases()
+			Expect(testCases).To(HaveLen(7))
+			for _, testCase := range testCases {
+				Expect(testCase.Tags.ContainsAny([]string{TagIPBlockExternalEndpoint})).To(BeTrue())
+				Expect(testCase.CanRunInRenamedNamespaces()).To(BeFalse())
+			}
+			Expect(testCases[0].Steps[0].Actions[0].CreatePolicy.Policy.Spec.Egress).To(BeEmpty())
+			ipBlock := testCases[2].Steps[0].Actions[0].CreatePolicy.Policy.Spec.Egress[0].To[0].IPBlock
+			Expect(ipBlock.CIDR).To(Equal("172.18.0.0/24"))
+			Expect(ipBlock.Except).To(Equal([]string{"172.18.0.5/32"}))
+			Expect(testCases[5].Steps[0].Actions[0].CreatePolicy.Policy.Spec.Egress[0].Ports[0].Port.IntVal).To(Equal(int32(8081)))
+		})
+
+		It("DNS test cases", func() {
+			Expect(NewTestCaseGenerator(false, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{}).DNSTestCases()).To(BeEmpty())
+
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{})
+			testCases := gen.DNSTestCases()
+			Expect(testCases).To(HaveLen(3))
+			for _, testCase := range testCases {
+				Expect(testCase.Tags.ContainsAny([]string{TagDNS})).To(BeTrue())
+				Expect(testCase.Steps[0].Actions[0].CreatePolicy.Policy.Spec.Egress).To(BeEmpty())
+			}
+			Expect(testCases[0].Steps[0].Actions).To(HaveLen(2))
+			Expect(testCases[1].Steps[0].Actions).To(HaveLen(1))
+			Expect(*testCases[2].Steps[0].Actions[1].CreatePolicy.Policy.Spec.Egress[0].Ports[0].Protocol).To(Equal(tcp))
+		})
+
+		It("Long-lived connection test cases", func() {
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{})
+			testCases := gen.LongLivedConnectionTestCases()
+			Expect(testCases).To(HaveLen(2))
+			for _, testCase := range testCases {
+				testCase.AddDerivedTags()
+				Expect(testCase.Tags.ContainsAny([]string{TagLongLivedConnection})).To(BeTrue())
+				Expect(testCase.Steps).To(HaveLen(3))
+				Expect(testCase.Steps[0].Actions[0].OpenConnection).NotTo(BeNil())
+				Expect(testCase.Steps[2].Actions[0].CloseConnection).NotTo(BeNil())
+			}
+			// egress gets DNS, since it's allowed
+			Expect(testCases[1].Steps[1].Actions).To(HaveLen(2))
+
+			renamed := testCases[0].RenameNamespaces(map[string]string{"x": "x-1"})
+			Expect(renamed.Steps[0].Actions[0].OpenConnection).To(Equal(&OpenConnectionAction{FromNamespace: "x-1", FromPod: "b", ToNamespace: "x-1", ToPod: "a", Port: 80}))
+			Expect(renamed.Steps[2].Actions[0].CloseConnection).To(Equal(&CloseConnectionAction{FromNamespace: "x-1", FromPod: "b", ToNamespace: "x-1", ToPod: "a", Port: 80}))
+		})
+
+		It("Filter test cases by description", func() {
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{TagUDPProtocol})
+			testCases := []*TestCase{
+				NewTestCase("deny all ingress to x", NewStringSet(TagIngress)),
+				NewTestCase("deny all egress from x", NewStringSet(TagEgress)),
+				NewTestCase("allow ingress to x on port 80", NewStringSet(TagIngress)),
+				NewTestCase("deny all ingress to x on UDP", NewStringSet(TagIngress, TagUDPProtocol)),
+			}
+			var err error
+			gen.IncludedNames, err = CompileNamePatterns([]string{"^deny all", "port 8[01]$"})
+			Expect(err).To(Succeed())
+			gen.ExcludedNames, err = CompileNamePatterns([]string{"egress"})
+			Expect(err).To(Succeed())
+			Expect(gen.FilterTestCases(testCases)).To(Equal([]*TestCase{testCases[0], testCases[2]}))
+
+			gen.IncludedNames = nil
+			Expect(gen.FilterTestCases(testCases)).To(Equal([]*TestCase{testCases[0], testCases[2]}))
+			gen.ExcludedNames = nil
+			Expect(gen.FilterTestCases(testCases)).To(Equal(testCases[:3]))
+
+			_, err = CompileNamePatterns([]string{"deny (all"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Shuffle test cases deterministically", func() {
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{})
+			testCases := gen.GenerateTestCases()
+
+			shuffled := ShuffleTestCases(testCases, 42)
+			Expect(shuffled).To(ConsistOf(testCases))
+			Expect(shuffled).ToNot(Equal(testCases))
+			Expect(ShuffleTestCases(testCases, 42)).To(Equal(shuffled))
+			Expect(ShuffleTestCases(testCases, 43)).ToNot(Equal(shuffled))
+		})
+
+		It("Repeat test cases", func() {
+			gen := NewTestCaseGenerator(true, "1.2.3.4", []string{"x", "y", "z"}, []string{}, []string{})
+			testCases := gen.GenerateTestCases()[:2]
+
+			Expect(RepeatTestCases(testCases, 1)).To(Equal(testCases))
+			Expect(RepeatTestCases(testCases, 3)).To(Equal([]*TestCase{testCases[0], testCases[0], testCases[0], testCases[1], testCases[1], testCases[1]}))
 		})
 	})
 }
@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"github.com/pkg/errors"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// Waivers record results which are known to differ from what the policies allow -- i.e. because of a CNI bug being
+// tracked -- along with why, so that runs can pass in spite of them, while still reporting them separately from
+// real failures.
+//
+// A waiver with an Expected result overrides the expected result of the matching probes of its test case: of one
+// step, or of every step if Step is 0, from From to To -- pods, such as x/a -- on Port and Protocol; empty fields
+// match anything.  A waiver without an Expected result waives every failure of its test case.
+//
+// Example:
+//
+//   - testCase: "named port web on TCP, which x/d maps to port 80 on UDP and y/d to port 81 on TCP"
+//     reason: "example-cni#123: named ports are resolved on the wrong pod"
+//   - testCase: "deny all ingress: in x"
+//     from: y/a
+//     to: x/a
+//     protocol: UDP
+//     expected: allowed
+//     reason: "example-cni#456: UDP policies aren't applied to established flows"
+type Waivers []*Waiver
+
+type Waiver struct {
+	TestCase string
+	Step     int         `json:",omitempty"`
+	From     string      `json:",omitempty"`
+	To       string      `json:",omitempty"`
+	Port     int         `json:",omitempty"`
+	Protocol v1.Protocol `json:",omitempty"`
+	// Expected is "allowed" or "blocked"
+	Expected string `json:",omitempty"`
+	Reason   string
+}
+
+const (
+	waiverExpectedAllowed = "allowed"
+	waiverExpectedBlocked = "blocked"
+)
+
+func LoadWaivers(path string) (Waivers, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read waivers %s", path)
+	}
+	waivers := Waivers{}
+	if err := yaml.UnmarshalStrict(bs, &waivers); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal waivers %s", path)
+	}
+	for i, waiver := range waivers {
+		if err := waiver.validate(); err != nil {
+			return nil, errors.WithMessagef(err, "invalid waiver #%d in %s", i+1, path)
+		}
+	}
+	return waivers, nil
+}
+
+func (w *Waiver) validate() error {
+	if w.TestCase == "" {
+		return errors.Errorf("waiver has no test case")
+	}
+	if w.Reason == "" {
+		return errors.Errorf("waiver of test case '%s' has no reason", w.TestCase)
+	}
+	if w.Step < 0 {
+		return errors.Errorf("waiver of test case '%s' has invalid step %d", w.TestCase, w.Step)
+	}
+	switch w.Expected {
+	case waiverExpectedAllowed, waiverExpectedBlocked:
+		return nil
+	case "":
+		if w.Step != 0 || w.From != "" || w.To != "" || w.Port != 0 || w.Protocol != "" {
+			return errors.Errorf("waiver of test case '%s' picks out probes, but has no expected result", w.TestCase)
+		}
+		return nil
+	default:
+		return errors.Errorf("waiver of test case '%s' has invalid expected result '%s'; must be %s or %s", w.TestCase, w.Expected, waiverExpectedAllowed, waiverExpectedBlocked)
+	}
+}
+
+// IsAllowed is the waiver's expected result
+func (w *Waiver) IsAllowed() bool {
+	return w.Expected == waiverExpectedAllowed
+}
+
+// Matches is true for probes the waiver overrides the expected result of
+func (w *Waiver) Matches(from string, to string, port int, protocol v1.Protocol) bool {
+	return (w.From == "" || w.From == from) &&
+		(w.To == "" || w.To == to) &&
+		(w.Port == 0 || w.Port == port) &&
+		(w.Protocol == "" || strings.EqualFold(string(w.Protocol), string(protocol)))
+}
+
+// Apply attaches each waiver to its test case -- or to the steps of its test case whose probes it overrides.
+// Waivers of test cases which aren't among testCases are an error, to catch typos in descriptions -- so apply them
+// before filtering test cases.
+func (w Waivers) Apply(testCases []*TestCase) error {
+	byDescription := map[string]*TestCase{}
+	for _, testCase := range testCases {
+		byDescription[testCase.Description] = testCase
+	}
+	for _, waiver := range w {
+		testCase, ok := byDescription[waiver.TestCase]
+		if !ok {
+			return errors.Errorf("waiver refers to unknown test case '%s'", waiver.TestCase)
+		}
+		if waiver.Expected == "" {
+			testCase.Waiver = waiver.Reason
+			continue
+		}
+		if waiver.Step > len(testCase.Steps) {
+			return errors.Errorf("waiver refers to step %d of test case '%s', which has %d steps", waiver.Step, waiver.TestCase, len(testCase.Steps))
+		}
+		for i, step := range testCase.Steps {
+			if waiver.Step == 0 || waiver.Step == i+1 {
+				step.Waivers = append(step.Waivers, waiver)
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Waiver) renameNamespaces(rename func(string) string) *Waiver {
+	renamed := *w
+	renamed.From = renamePodNamespace(w.From, rename)
+	renamed.To = renamePodNamespace(w.To, rename)
+	return &renamed
+}
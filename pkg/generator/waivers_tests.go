@@ -0,0 +1,80 @@
+package generator
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	"os"
+	"path/filepath"
+)
+
+func RunWaiverTests() {
+	Describe("Waivers", func() {
+		writeWaivers := func(contents string) string {
+			dir, err := ioutil.TempDir("", "cyclonus-waivers")
+			Expect(err).To(Succeed())
+			path := filepath.Join(dir, "waivers.yaml")
+			Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+			return path
+		}
+
+		It("should attach waivers to test cases, and to the steps whose probes they override", func() {
+			path := writeWaivers(`
+- testCase: one step
+  reason: "example-cni#1: always fails"
+- testCase: two steps
+  step: 2
+  from: y/a
+  to: x/a
+  protocol: UDP
+  expected: allowed
+  reason: "example-cni#2: UDP is let through"
+`)
+			defer os.RemoveAll(filepath.Dir(path))
+			waivers, err := LoadWaivers(path)
+			Expect(err).To(Succeed())
+			Expect(waivers).To(HaveLen(2))
+
+			oneStep := NewTestCase("one step", NewStringSet(TagIngress), NewTestStep(ProbeAllAvailable))
+			twoSteps := NewTestCase("two steps", NewStringSet(TagIngress), NewTestStep(ProbeAllAvailable), NewTestStep(ProbeAllAvailable))
+			Expect(waivers.Apply([]*TestCase{oneStep, twoSteps})).To(Succeed())
+
+			Expect(oneStep.Waiver).To(Equal("example-cni#1: always fails"))
+			Expect(oneStep.Steps[0].Waivers).To(BeEmpty())
+			Expect(twoSteps.Waiver).To(Equal(""))
+			Expect(twoSteps.Steps[0].Waivers).To(BeEmpty())
+			Expect(twoSteps.Steps[1].Waivers).To(Equal([]*Waiver{waivers[1]}))
+
+			waiver := waivers[1]
+			Expect(waiver.IsAllowed()).To(BeTrue())
+			Expect(waiver.Matches("y/a", "x/a", 80, v1.ProtocolUDP)).To(BeTrue())
+			Expect(waiver.Matches("y/a", "x/a", 80, v1.ProtocolTCP)).To(BeFalse())
+			Expect(waiver.Matches("y/b", "x/a", 80, v1.ProtocolUDP)).To(BeFalse())
+
+			renamed := twoSteps.RenameNamespaces(map[string]string{"x": "x-1", "y": "y-1"})
+			Expect(renamed.Steps[1].Waivers[0].From).To(Equal("y-1/a"))
+			Expect(renamed.Steps[1].Waivers[0].To).To(Equal("x-1/a"))
+		})
+
+		It("should reject invalid waivers, and waivers of unknown test cases", func() {
+			for _, contents := range []string{
+				"- testCase: a\n",
+				"- reason: b\n",
+				"- testCase: a\n  reason: b\n  from: x/a\n",
+				"- testCase: a\n  reason: b\n  expected: maybe\n",
+				"- testCase: a\n  reason: b\n  unknownField: c\n",
+			} {
+				path := writeWaivers(contents)
+				_, err := LoadWaivers(path)
+				Expect(err).ToNot(Succeed(), contents)
+				os.RemoveAll(filepath.Dir(path))
+			}
+
+			waivers := Waivers{{TestCase: "missing", Reason: "typo"}}
+			Expect(waivers.Apply([]*TestCase{NewTestCase("present", NewStringSet(TagIngress))})).ToNot(Succeed())
+			waivers = Waivers{{TestCase: "present", Step: 2, Expected: "allowed", Reason: "no such step"}}
+			Expect(waivers.Apply([]*TestCase{NewTestCase("present", NewStringSet(TagIngress), NewTestStep(ProbeAllAvailable))})).ToNot(Succeed())
+		})
+	})
+}
@@ -0,0 +1,154 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+// YamlTestCase is a hand-written test case.  Each step's actions are Actions in yaml, and its probe defaults to all
+// available ports and protocols.  A step may include an expected connectivity matrix, which takes precedence over
+// what the policies would allow, so that connectivity requirements can be asserted directly.
+//
+// Example:
+//
+//	description: only y/a may reach x/a
+//	tags: [ingress]
+//	steps:
+//	- actions:
+//	  - createPolicy:
+//	      policy: {apiVersion: networking.k8s.io/v1, kind: NetworkPolicy, ...}
+//	  expected: |
+//	    x/a
+//	    y/a .
+//	    y/b X
+type YamlTestCase struct {
+	Description string          `json:"description"`
+	Tags        []string        `json:"tags,omitempty"`
+	Steps       []*YamlTestStep `json:"steps"`
+}
+
+type YamlTestStep struct {
+	Probe    *ProbeConfig        `json:"probe,omitempty"`
+	Actions  []*Action           `json:"actions"`
+	Expected *ConnectivityMatrix `json:"expected,omitempty"`
+}
+
+func (y *YamlTestCase) TestCase() (*TestCase, error) {
+	if len(y.Steps) == 0 {
+		return nil, errors.Errorf("test case '%s' has no steps", y.Description)
+	}
+	tags := NewStringSet(TagUserDefined)
+	for _, tag := range y.Tags {
+		if _, ok := TagSubToPrimary[tag]; !ok {
+			return nil, errors.Errorf("test case '%s': invalid tag %s; only non-primary tags may be used", y.Description, tag)
+		}
+		tags.Add(tag)
+	}
+
+	testCase := &TestCase{Description: y.Description, Tags: tags}
+	for stepIndex, step := range y.Steps {
+		for actionIndex, action := range step.Actions {
+			if !action.isValid() {
+				return nil, errors.Errorf("test case '%s': step %d, action %d must set exactly one action", y.Description, stepIndex+1, actionIndex+1)
+			}
+		}
+		probe := step.Probe
+		if probe == nil {
+			probe = ProbeAllAvailable
+		}
+		testCase.Steps = append(testCase.Steps, &TestStep{Probe: probe, Actions: step.Actions, Expected: step.Expected})
+	}
+	testCase.AddDerivedTags()
+	return testCase, nil
+}
+
+// isValid checks that exactly one of the Action's fields is set
+func (a *Action) isValid() bool {
+	if a == nil {
+		return false
+	}
+	set := 0
+	value := reflect.ValueOf(*a)
+	for i := 0; i < value.NumField(); i++ {
+		if !value.Field(i).IsNil() {
+			set++
+		}
+	}
+	return set == 1
+}
+
+// ParseYamlTestCases parses one test case from each yaml document
+func ParseYamlTestCases(yamlString string) ([]*TestCase, error) {
+	var cases []*TestCase
+	for i, doc := range utils.SplitYamlDocuments(yamlString) {
+		yamlCase := &YamlTestCase{}
+		if err := yaml.UnmarshalStrict([]byte(doc), yamlCase); err != nil {
+			return nil, errors.Wrapf(err, "unable to unmarshal test case from document %d", i+1)
+		}
+		if yamlCase.Description == "" {
+			yamlCase.Description = fmt.Sprintf("user-defined test case %d", i+1)
+		}
+		testCase, err := yamlCase.TestCase()
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, testCase)
+	}
+	return cases, nil
+}
+
+func LoadYamlTestCases(path string) ([]*TestCase, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read test cases %s", path)
+	}
+	cases, err := ParseYamlTestCases(string(bs))
+	return cases, errors.WithMessagef(err, "unable to load test cases from %s", path)
+}
+
+// LoadYamlTestSuite loads test cases from path: a yaml file, or a directory, whose .yaml and .yml files -- including
+// those in subdirectories -- are loaded in lexical order, so that a suite can be split across files
+func LoadYamlTestSuite(path string) ([]*TestCase, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read test suite %s", path)
+	}
+	if !info.IsDir() {
+		return LoadYamlTestCases(path)
+	}
+	var files []string
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		extension := strings.ToLower(filepath.Ext(file))
+		if !info.IsDir() && (extension == ".yaml" || extension == ".yml") {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list test suite %s", path)
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("test suite %s has no .yaml or .yml files", path)
+	}
+	sort.Strings(files)
+	var cases []*TestCase
+	for _, file := range files {
+		fileCases, err := LoadYamlTestCases(file)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, fileCases...)
+	}
+	return cases, nil
+}
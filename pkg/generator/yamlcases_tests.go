@@ -0,0 +1,182 @@
+package generator
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func RunYamlTestCaseTests() {
+	Describe("Connectivity matrices", func() {
+		It("should parse allowed, blocked, and unchecked cells", func() {
+			matrix, err := ParseConnectivityMatrix(`
+    x/a x/b
+x/a .   X
+y/a -   .
+`)
+			Expect(err).To(Succeed())
+			Expect(matrix.Froms).To(Equal([]string{"x/a", "y/a"}))
+			Expect(matrix.Tos).To(Equal([]string{"x/a", "x/b"}))
+
+			allowed, ok := matrix.Expectation("x/a", "x/b")
+			Expect(ok).To(BeTrue())
+			Expect(allowed).To(BeFalse())
+			allowed, ok = matrix.Expectation("y/a", "x/b")
+			Expect(ok).To(BeTrue())
+			Expect(allowed).To(BeTrue())
+			_, ok = matrix.Expectation("y/a", "x/a")
+			Expect(ok).To(BeFalse())
+
+			reparsed, err := ParseConnectivityMatrix(matrix.String())
+			Expect(err).To(Succeed())
+			Expect(reparsed).To(Equal(matrix))
+
+			Expect(matrix.CheckPods([]string{"x/a", "x/b", "y/a"})).To(Succeed())
+			Expect(matrix.CheckPods([]string{"x/a", "x/b"})).NotTo(Succeed())
+		})
+
+		It("should reject malformed matrices", func() {
+			_, err := ParseConnectivityMatrix("x/a x/b\nx/a .")
+			Expect(err).NotTo(Succeed())
+			_, err = ParseConnectivityMatrix("x/a\nx/a ?")
+			Expect(err).NotTo(Succeed())
+			_, err = ParseConnectivityMatrix("x/a")
+			Expect(err).NotTo(Succeed())
+		})
+	})
+
+	Describe("Yaml test cases", func() {
+		It("should load test cases with expected connectivity", func() {
+			cases, err := ParseYamlTestCases(`
+description: deny all ingress to x
+tags: [deny-all]
+steps:
+- actions:
+  - createPolicy:
+      policy:
+        apiVersion: networking.k8s.io/v1
+        kind: NetworkPolicy
+        metadata:
+          name: deny-all
+          namespace: x
+        spec:
+          podSelector: {}
+          policyTypes: [Ingress]
+  expected: |
+    x/a
+    y/a X
+---
+steps:
+- actions:
+  - deletePolicy:
+      namespace: x
+      name: deny-all
+`)
+			Expect(err).To(Succeed())
+			Expect(cases).To(HaveLen(2))
+
+			Expect(cases[0].Description).To(Equal("deny all ingress to x"))
+			Expect(cases[0].Tags.ContainsAny([]string{TagUserDefined, TagDenyAll})).To(BeTrue())
+			Expect(cases[0].Steps[0].Probe).To(Equal(ProbeAllAvailable))
+			Expect(cases[0].Steps[0].Actions[0].CreatePolicy.Policy.Name).To(Equal("deny-all"))
+			allowed, ok := cases[0].Steps[0].Expected.Expectation("y/a", "x/a")
+			Expect(ok).To(BeTrue())
+			Expect(allowed).To(BeFalse())
+
+			Expect(cases[1].Description).To(Equal("user-defined test case 2"))
+			Expect(cases[1].Steps[0].Expected).To(BeNil())
+			Expect(cases[1].Steps[0].Actions[0].DeletePolicy).To(Equal(&DeletePolicyAction{Namespace: "x", Name: "deny-all"}))
+		})
+
+		It("should load a suite from every yaml file in a directory, in order", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-suite")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+			Expect(os.MkdirAll(filepath.Join(dir, "nested"), 0755)).To(Succeed())
+			deletePolicy := func(name string) string {
+				return "description: delete " + name + "\nsteps:\n- actions:\n  - deletePolicy: {namespace: x, name: " + name + "}\n"
+			}
+			for file, contents := range map[string]string{
+				"b.yml":           deletePolicy("b"),
+				"a.yaml":          deletePolicy("a1") + "---\n" + deletePolicy("a2"),
+				"nested/c.yaml":   deletePolicy("c"),
+				"README.md":       "not a test case",
+				"nested/notes.md": "not a test case either",
+			} {
+				Expect(ioutil.WriteFile(filepath.Join(dir, file), []byte(contents), 0644)).To(Succeed())
+			}
+
+			cases, err := LoadYamlTestSuite(dir)
+			Expect(err).To(Succeed())
+			var descriptions []string
+			for _, testCase := range cases {
+				descriptions = append(descriptions, testCase.Description)
+			}
+			Expect(descriptions).To(Equal([]string{"delete a1", "delete a2", "delete b", "delete c"}))
+
+			cases, err = LoadYamlTestSuite(filepath.Join(dir, "b.yml"))
+			Expect(err).To(Succeed())
+			Expect(cases).To(HaveLen(1))
+
+			_, err = LoadYamlTestSuite(filepath.Join(dir, "missing"))
+			Expect(err).NotTo(Succeed())
+			empty, err := ioutil.TempDir("", "cyclonus-suite")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(empty)
+			_, err = LoadYamlTestSuite(empty)
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("should derive tags from the policies and actions", func() {
+			cases, err := ParseYamlTestCases(`
+description: allow named and numbered ports to x/a
+steps:
+- actions:
+  - createPolicy:
+      policy:
+        apiVersion: networking.k8s.io/v1
+        kind: NetworkPolicy
+        metadata:
+          name: allow-ports
+          namespace: x
+        spec:
+          podSelector: {matchLabels: {pod: a}}
+          policyTypes: [Egress]
+          egress:
+          - to:
+            - ipBlock: {cidr: 10.0.0.0/8, except: [10.1.0.0/16]}
+            ports:
+            - {port: serve-80-tcp}
+          - ports:
+            - {port: 81, protocol: UDP}
+  - setPodLabels: {namespace: y, pod: a, labels: {pod: b}}
+`)
+			Expect(err).To(Succeed())
+			Expect(cases).To(HaveLen(1))
+			Expect(cases[0].Tags.Keys()).To(Equal([]string{
+				TagAction, TagAnyPeer, TagDirection, TagEgress, TagEgressOnly, TagIPBlockWithExcept, TagMiscellaneous,
+				TagMultiRule, TagNamedPort, TagNumberedPort, TagPeerIPBlock, TagPort, TagProtocol, TagRule,
+				TagSetPodLabels, TagUDPProtocol, TagUserDefined,
+			}))
+		})
+
+		It("should reject invalid actions and tags", func() {
+			_, err := ParseYamlTestCases(`
+steps:
+- actions:
+  - createPolicy: {}
+    deletePolicy: {namespace: x, name: y}
+`)
+			Expect(err).NotTo(Succeed())
+
+			_, err = ParseYamlTestCases(`
+tags: [not-a-tag]
+steps:
+- actions: []
+`)
+			Expect(err).NotTo(Succeed())
+		})
+	})
+}
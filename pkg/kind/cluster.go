@@ -0,0 +1,155 @@
+package kind
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+const (
+	CNIKindnet = "kindnet"
+	CNICalico  = "calico"
+	CNICilium  = "cilium"
+	CNIAntrea  = "antrea"
+	// CNIDefault is an alias of CNIKindnet, kind's default CNI
+	CNIDefault = "default"
+	// CNIManifest is for clusters whose CNI is installed from a manifest, rather than one of the CNIs above
+	CNIManifest = "manifest"
+
+	agnhostImage = "k8s.gcr.io/e2e-test-images/agnhost:2.28"
+)
+
+type Cluster struct {
+	Name      string
+	CNI       string
+	NodeImage string
+	// ManifestPath is the path or URL of the manifest to apply to install the CNI, for CNIManifest
+	ManifestPath string
+}
+
+func NewCluster(name string, cni string, nodeImage string) (*Cluster, error) {
+	cni = CanonicalCNI(cni)
+	if _, ok := cniInstallers[cni]; !ok {
+		return nil, errors.Errorf("unsupported cni '%s'; must be one of %+v", cni, AllCNIs())
+	}
+	return &Cluster{Name: name, CNI: cni, NodeImage: nodeImage}, nil
+}
+
+// NewManifestCluster is a cluster whose CNI is installed by applying a manifest, i.e. for CNIs, or versions of CNIs,
+// which cyclonus doesn't know how to install
+func NewManifestCluster(name string, manifestPath string, nodeImage string) *Cluster {
+	return &Cluster{Name: name, CNI: CNIManifest, NodeImage: nodeImage, ManifestPath: manifestPath}
+}
+
+func AllCNIs() []string {
+	var cnis []string
+	for cni := range cniInstallers {
+		cnis = append(cnis, cni)
+	}
+	for alias := range cniAliases {
+		cnis = append(cnis, alias)
+	}
+	sort.Strings(cnis)
+	return cnis
+}
+
+// KubeContext is the kube context that kind writes to the kubeconfig for this cluster
+func (c *Cluster) KubeContext() string {
+	return "kind-" + c.Name
+}
+
+// Create creates the kind cluster, installs the CNI, waits for the nodes to become ready, and preloads the
+// images that cyclonus uses for its server pods.
+func (c *Cluster) Create() error {
+	configFile, err := ioutil.TempFile("", "cyclonus-kind-config-*.yaml")
+	if err != nil {
+		return errors.Wrapf(err, "unable to create kind config file")
+	}
+	defer os.Remove(configFile.Name())
+
+	if _, err := configFile.WriteString(kindConfig(c.CNI != CNIKindnet, cniPodSubnets[c.CNI])); err != nil {
+		return errors.Wrapf(err, "unable to write kind config")
+	}
+	if err := configFile.Close(); err != nil {
+		return errors.Wrapf(err, "unable to close kind config file")
+	}
+
+	args := []string{"create", "cluster", "--name", c.Name, "--config", configFile.Name()}
+	if c.NodeImage != "" {
+		args = append(args, "--image", c.NodeImage)
+	}
+	logrus.Infof("creating kind cluster %s with cni %s", c.Name, c.CNI)
+	if err := utils.CommandRunAndCaptureProgress(exec.Command("kind", args...)); err != nil {
+		return err
+	}
+
+	if c.CNI == CNIManifest {
+		logrus.Infof("installing cni from manifest %s", c.ManifestPath)
+		if err := c.kubectl("apply", "-f", c.ManifestPath); err != nil {
+			return err
+		}
+	} else if err := cniInstallers[c.CNI](c); err != nil {
+		return err
+	}
+
+	if err := c.kubectl("wait", "--for=condition=ready", "nodes", "--all", "--timeout=5m"); err != nil {
+		return err
+	}
+
+	return c.loadImage(agnhostImage)
+}
+
+func (c *Cluster) Delete() error {
+	logrus.Infof("deleting kind cluster %s", c.Name)
+	_, err := utils.CommandRun(exec.Command("kind", "delete", "cluster", "--name", c.Name))
+	return err
+}
+
+func (c *Cluster) kubectl(args ...string) error {
+	return utils.CommandRunAndCaptureProgress(exec.Command("kubectl", append([]string{"--context", c.KubeContext()}, args...)...))
+}
+
+func (c *Cluster) loadImage(image string) error {
+	if _, err := utils.CommandRun(exec.Command("docker", "pull", image)); err != nil {
+		return err
+	}
+	_, err := utils.CommandRun(exec.Command("kind", "load", "docker-image", image, "--name", c.Name))
+	return err
+}
+
+// waitForPods retries `kubectl wait` since the pods may not exist yet right after a manifest is applied
+func (c *Cluster) waitForPods(namespace string, selector string) error {
+	var err error
+	for i := 0; i < 30; i++ {
+		err = c.kubectl("wait", "--for=condition=ready", "pod", "-l", selector, "-n", namespace, "--timeout=5m")
+		if err == nil {
+			return nil
+		}
+		logrus.Infof("waiting for pods %s in namespace %s", selector, namespace)
+		time.Sleep(10 * time.Second)
+	}
+	return err
+}
+
+func kindConfig(disableDefaultCNI bool, podSubnet string) string {
+	networking := ""
+	if disableDefaultCNI {
+		networking = "networking:\n  disableDefaultCNI: true\n"
+		if podSubnet != "" {
+			networking += fmt.Sprintf("  podSubnet: %s\n", podSubnet)
+		}
+	}
+	return `kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+nodes:
+  - role: control-plane
+  - role: worker
+  - role: worker
+` + networking
+}
@@ -0,0 +1,77 @@
+package kind
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"os/exec"
+)
+
+const (
+	calicoManifest  = "https://docs.projectcalico.org/manifests/calico.yaml"
+	antreaManifest  = "https://github.com/antrea-io/antrea/releases/download/v0.13.1/antrea-kind.yml"
+	ciliumVersion   = "1.9.5"
+	ciliumHelmRepo  = "https://helm.cilium.io/"
+	calicoPodSubnet = "192.168.0.0/16"
+)
+
+var cniPodSubnets = map[string]string{
+	CNICalico: calicoPodSubnet,
+}
+
+var cniAliases = map[string]string{
+	CNIDefault: CNIKindnet,
+}
+
+// CanonicalCNI resolves aliases, such as CNIDefault, to the CNI they stand for
+func CanonicalCNI(cni string) string {
+	if canonical, ok := cniAliases[cni]; ok {
+		return canonical
+	}
+	return cni
+}
+
+// these mirror the setup scripts under hack/kind
+var cniInstallers = map[string]func(c *Cluster) error{
+	CNIKindnet: func(c *Cluster) error {
+		return nil
+	},
+	CNICalico: func(c *Cluster) error {
+		if err := c.kubectl("apply", "-f", calicoManifest); err != nil {
+			return err
+		}
+		if err := c.kubectl("-n", "kube-system", "set", "env", "daemonset/calico-node", "FELIX_IGNORELOOSERPF=true", "FELIX_XDPENABLED=false"); err != nil {
+			return err
+		}
+		return c.waitForPods("kube-system", "k8s-app=calico-node")
+	},
+	CNICilium: func(c *Cluster) error {
+		if _, err := utils.CommandRun(exec.Command("helm", "repo", "add", "cilium", ciliumHelmRepo)); err != nil {
+			return err
+		}
+		if err := c.loadImage("quay.io/cilium/cilium:v" + ciliumVersion); err != nil {
+			return err
+		}
+		err := utils.CommandRunAndCaptureProgress(exec.Command("helm", "install", "cilium", "cilium/cilium",
+			"--kube-context", c.KubeContext(),
+			"--version", ciliumVersion,
+			"--namespace", "kube-system",
+			"--set", "nodeinit.enabled=true",
+			"--set", "kubeProxyReplacement=partial",
+			"--set", "hostServices.enabled=false",
+			"--set", "externalIPs.enabled=true",
+			"--set", "nodePort.enabled=true",
+			"--set", "hostPort.enabled=true",
+			"--set", "bpf.masquerade=false",
+			"--set", "image.pullPolicy=IfNotPresent",
+			"--set", "ipam.mode=kubernetes"))
+		if err != nil {
+			return err
+		}
+		return c.waitForPods("kube-system", "k8s-app=cilium")
+	},
+	CNIAntrea: func(c *Cluster) error {
+		if err := c.kubectl("apply", "-f", antreaManifest); err != nil {
+			return err
+		}
+		return c.waitForPods("kube-system", "app=antrea")
+	},
+}
@@ -0,0 +1,146 @@
+package anp
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// These types mirror the policy.networking.k8s.io/v1alpha1 AdminNetworkPolicy and BaselineAdminNetworkPolicy APIs
+// from sigs.k8s.io/network-policy-api, as far as cyclonus uses them.  Like the upstream types, they're meant to be
+// serialized to and from unstructured objects.
+
+const (
+	Group   = "policy.networking.k8s.io"
+	Version = "v1alpha1"
+
+	AdminNetworkPolicyKind         = "AdminNetworkPolicy"
+	BaselineAdminNetworkPolicyKind = "BaselineAdminNetworkPolicy"
+
+	// BaselineAdminNetworkPolicyName is the only name a BaselineAdminNetworkPolicy may have: it's a singleton
+	BaselineAdminNetworkPolicyName = "default"
+)
+
+var (
+	GroupVersion = schema.GroupVersion{Group: Group, Version: Version}
+
+	AdminNetworkPolicyResource         = GroupVersion.WithResource("adminnetworkpolicies")
+	BaselineAdminNetworkPolicyResource = GroupVersion.WithResource("baselineadminnetworkpolicies")
+)
+
+// AdminNetworkPolicy is cluster-scoped.  Lower priority numbers take precedence.
+type AdminNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              AdminNetworkPolicySpec `json:"spec"`
+}
+
+type AdminNetworkPolicySpec struct {
+	Priority int32                           `json:"priority"`
+	Subject  AdminNetworkPolicySubject       `json:"subject"`
+	Ingress  []AdminNetworkPolicyIngressRule `json:"ingress,omitempty"`
+	Egress   []AdminNetworkPolicyEgressRule  `json:"egress,omitempty"`
+}
+
+// AdminNetworkPolicySubject: exactly one field must be non-null
+type AdminNetworkPolicySubject struct {
+	Namespaces *metav1.LabelSelector `json:"namespaces,omitempty"`
+	Pods       *NamespacedPod        `json:"pods,omitempty"`
+}
+
+type NamespacedPod struct {
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+	PodSelector       metav1.LabelSelector `json:"podSelector"`
+}
+
+type AdminNetworkPolicyRuleAction string
+
+const (
+	AdminNetworkPolicyRuleActionAllow AdminNetworkPolicyRuleAction = "Allow"
+	AdminNetworkPolicyRuleActionDeny  AdminNetworkPolicyRuleAction = "Deny"
+	// AdminNetworkPolicyRuleActionPass skips any lower-priority AdminNetworkPolicies, and leaves the decision to
+	// NetworkPolicies -- and, failing those, the BaselineAdminNetworkPolicy
+	AdminNetworkPolicyRuleActionPass AdminNetworkPolicyRuleAction = "Pass"
+)
+
+type AdminNetworkPolicyIngressRule struct {
+	Name   string                       `json:"name,omitempty"`
+	Action AdminNetworkPolicyRuleAction `json:"action"`
+	From   []AdminNetworkPolicyPeer     `json:"from"`
+	// Ports: nil means all ports
+	Ports *[]AdminNetworkPolicyPort `json:"ports,omitempty"`
+}
+
+type AdminNetworkPolicyEgressRule struct {
+	Name   string                       `json:"name,omitempty"`
+	Action AdminNetworkPolicyRuleAction `json:"action"`
+	To     []AdminNetworkPolicyPeer     `json:"to"`
+	// Ports: nil means all ports
+	Ports *[]AdminNetworkPolicyPort `json:"ports,omitempty"`
+}
+
+// AdminNetworkPolicyPeer: exactly one field must be non-null.  Nodes and Networks are only allowed in egress rules.
+type AdminNetworkPolicyPeer struct {
+	Namespaces *metav1.LabelSelector `json:"namespaces,omitempty"`
+	Pods       *NamespacedPod        `json:"pods,omitempty"`
+	// Nodes selects nodes by label
+	Nodes *metav1.LabelSelector `json:"nodes,omitempty"`
+	// Networks selects IPs by CIDR, i.e. of destinations outside the cluster
+	Networks []CIDR `json:"networks,omitempty"`
+}
+
+// CIDR is an IPv4 or IPv6 CIDR, i.e. 10.0.0.0/8
+type CIDR string
+
+// AdminNetworkPolicyPort: exactly one field must be non-null
+type AdminNetworkPolicyPort struct {
+	PortNumber *Port      `json:"portNumber,omitempty"`
+	NamedPort  *string    `json:"namedPort,omitempty"`
+	PortRange  *PortRange `json:"portRange,omitempty"`
+}
+
+type Port struct {
+	Protocol v1.Protocol `json:"protocol"`
+	Port     int32       `json:"port"`
+}
+
+type PortRange struct {
+	Protocol v1.Protocol `json:"protocol,omitempty"`
+	Start    int32       `json:"start"`
+	End      int32       `json:"end"`
+}
+
+// BaselineAdminNetworkPolicy is a cluster-scoped singleton, which only applies to traffic not decided by an
+// AdminNetworkPolicy or a NetworkPolicy
+type BaselineAdminNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              BaselineAdminNetworkPolicySpec `json:"spec"`
+}
+
+type BaselineAdminNetworkPolicySpec struct {
+	Subject AdminNetworkPolicySubject               `json:"subject"`
+	Ingress []BaselineAdminNetworkPolicyIngressRule `json:"ingress,omitempty"`
+	Egress  []BaselineAdminNetworkPolicyEgressRule  `json:"egress,omitempty"`
+}
+
+type BaselineAdminNetworkPolicyRuleAction string
+
+const (
+	BaselineAdminNetworkPolicyRuleActionAllow BaselineAdminNetworkPolicyRuleAction = "Allow"
+	BaselineAdminNetworkPolicyRuleActionDeny  BaselineAdminNetworkPolicyRuleAction = "Deny"
+)
+
+type BaselineAdminNetworkPolicyIngressRule struct {
+	Name   string                               `json:"name,omitempty"`
+	Action BaselineAdminNetworkPolicyRuleAction `json:"action"`
+	From   []AdminNetworkPolicyPeer             `json:"from"`
+	Ports  *[]AdminNetworkPolicyPort            `json:"ports,omitempty"`
+}
+
+type BaselineAdminNetworkPolicyEgressRule struct {
+	Name   string                               `json:"name,omitempty"`
+	Action BaselineAdminNetworkPolicyRuleAction `json:"action"`
+	To     []AdminNetworkPolicyPeer             `json:"to"`
+	Ports  *[]AdminNetworkPolicyPort            `json:"ports,omitempty"`
+}
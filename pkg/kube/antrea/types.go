@@ -0,0 +1,152 @@
+package antrea
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"strings"
+)
+
+// These types mirror the crd.antrea.io/v1beta1 ClusterNetworkPolicy, NetworkPolicy and Tier APIs, as far as cyclonus
+// uses them: their L3/L4 rules.  Peers other than pods, namespaces and IP blocks -- groups, service accounts, nodes,
+// FQDNs -- are read only to warn that they aren't modeled.
+
+const (
+	Group   = "crd.antrea.io"
+	Version = "v1beta1"
+
+	ClusterNetworkPolicyKind = "ClusterNetworkPolicy"
+	NetworkPolicyKind        = "NetworkPolicy"
+	TierKind                 = "Tier"
+
+	// DefaultTierName is the tier of policies which don't name one
+	DefaultTierName = "application"
+	// BaselineTierName is the tier evaluated after Kubernetes NetworkPolicies; the others are evaluated before them
+	BaselineTierName = "baseline"
+)
+
+// StaticTiers are the tiers Antrea creates, by priority; lower priorities are evaluated first
+var StaticTiers = map[string]int32{
+	"emergency":      50,
+	"securityops":    100,
+	"networkops":     150,
+	"platform":       200,
+	DefaultTierName:  250,
+	BaselineTierName: 253,
+}
+
+var (
+	GroupVersion = schema.GroupVersion{Group: Group, Version: Version}
+
+	ClusterNetworkPolicyResource = GroupVersion.WithResource("clusternetworkpolicies")
+	NetworkPolicyResource        = GroupVersion.WithResource("networkpolicies")
+	TierResource                 = GroupVersion.WithResource("tiers")
+)
+
+// IsAntreaAPIVersion is whether apiVersion is crd.antrea.io's, of any version -- to tell Antrea's NetworkPolicy apart
+// from Kubernetes'
+func IsAntreaAPIVersion(apiVersion string) bool {
+	return strings.Split(apiVersion, "/")[0] == Group
+}
+
+// ClusterNetworkPolicy is cluster-scoped: its selectors select pods in all namespaces, unless they have a namespace
+// selector
+type ClusterNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              NetworkPolicySpec `json:"spec"`
+}
+
+// NetworkPolicy is namespaced: its pod selectors select pods in its namespace, unless they have a namespace
+// selector
+type NetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              NetworkPolicySpec `json:"spec"`
+}
+
+type NetworkPolicySpec struct {
+	// Tier defaults to DefaultTierName
+	Tier string `json:"tier,omitempty"`
+	// Priority sorts policies within a tier, lowest first
+	Priority float64 `json:"priority"`
+	// AppliedTo picks the pods the policy applies to; if it's empty, each rule has its own
+	AppliedTo []AppliedTo `json:"appliedTo,omitempty"`
+	Ingress   []Rule      `json:"ingress,omitempty"`
+	Egress    []Rule      `json:"egress,omitempty"`
+}
+
+type AppliedTo struct {
+	PodSelector       *metav1.LabelSelector `json:"podSelector,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	Group          string                `json:"group,omitempty"`
+	ServiceAccount *NamespacedName       `json:"serviceAccount,omitempty"`
+	Service        *NamespacedName       `json:"service,omitempty"`
+	NodeSelector   *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+}
+
+type NamespacedName struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type RuleAction string
+
+const (
+	RuleActionAllow RuleAction = "Allow"
+	RuleActionDrop  RuleAction = "Drop"
+	// RuleActionReject denies traffic, like Drop, but answers it with a TCP reset or ICMP unreachable
+	RuleActionReject RuleAction = "Reject"
+	// RuleActionPass skips the rest of the tiers, on to Kubernetes NetworkPolicies
+	RuleActionPass RuleAction = "Pass"
+)
+
+// Rule matches traffic from -- or to -- any of its peers, on any of its ports; without peers or ports, it matches all
+type Rule struct {
+	Name   string     `json:"name,omitempty"`
+	Action RuleAction `json:"action"`
+	// Ports are like NetworkPolicy ports
+	Ports []networkingv1.NetworkPolicyPort `json:"ports,omitempty"`
+	From  []NetworkPolicyPeer              `json:"from,omitempty"`
+	To    []NetworkPolicyPeer              `json:"to,omitempty"`
+	// AppliedTo is only allowed if the policy has none
+	AppliedTo []AppliedTo `json:"appliedTo,omitempty"`
+}
+
+type NetworkPolicyPeer struct {
+	PodSelector       *metav1.LabelSelector `json:"podSelector,omitempty"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	Namespaces        *PeerNamespaces       `json:"namespaces,omitempty"`
+	IPBlock           *IPBlock              `json:"ipBlock,omitempty"`
+
+	Group          string                `json:"group,omitempty"`
+	FQDN           string                `json:"fqdn,omitempty"`
+	ServiceAccount *NamespacedName       `json:"serviceAccount,omitempty"`
+	NodeSelector   *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+}
+
+type NamespaceMatchType string
+
+// NamespaceMatchSelf matches pods in the same namespace as the pod the policy applies to
+const NamespaceMatchSelf NamespaceMatchType = "Self"
+
+type PeerNamespaces struct {
+	Match NamespaceMatchType `json:"match,omitempty"`
+}
+
+type IPBlock struct {
+	CIDR string `json:"cidr"`
+}
+
+// Tier groups policies; tiers are evaluated in order of priority, lowest first
+type Tier struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TierSpec `json:"spec"`
+}
+
+type TierSpec struct {
+	Priority    int32  `json:"priority"`
+	Description string `json:"description,omitempty"`
+}
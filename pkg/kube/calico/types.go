@@ -0,0 +1,164 @@
+package calico
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"strings"
+)
+
+// These types mirror the projectcalico.org/v3 NetworkPolicy, GlobalNetworkPolicy and Tier APIs -- which are also
+// stored as crd.projectcalico.org/v1 custom resources -- as far as cyclonus uses them: their L3/L4 rules.  HTTP
+// matches, ICMP matches, service accounts and services are dropped when unmarshalling.
+
+const (
+	Group    = "projectcalico.org"
+	Version  = "v3"
+	CRDGroup = "crd.projectcalico.org"
+
+	NetworkPolicyKind       = "NetworkPolicy"
+	GlobalNetworkPolicyKind = "GlobalNetworkPolicy"
+	TierKind                = "Tier"
+
+	// DefaultTierName is the tier of policies which don't name one; it's also where Calico evaluates Kubernetes
+	// NetworkPolicies, at order KubernetesNetworkPolicyOrder
+	DefaultTierName              = "default"
+	DefaultTierOrder             = 1000000
+	KubernetesNetworkPolicyOrder = 1000
+
+	// NamespaceLabel is the label, on every workload endpoint, of its namespace's name
+	NamespaceLabel = "projectcalico.org/namespace"
+	// NamespaceNameLabel is the label, on every namespace, of its name
+	NamespaceNameLabel = "projectcalico.org/name"
+	// NamespaceLabelsPrefix prefixes the labels, on every workload endpoint, of its namespace's labels
+	NamespaceLabelsPrefix = "pcns."
+)
+
+var (
+	GroupVersion    = schema.GroupVersion{Group: Group, Version: Version}
+	CRDGroupVersion = schema.GroupVersion{Group: CRDGroup, Version: "v1"}
+
+	// The custom resources are read, rather than the projectcalico.org API, since they're there whether or not the
+	// Calico API server is installed
+	NetworkPolicyResource       = CRDGroupVersion.WithResource("networkpolicies")
+	GlobalNetworkPolicyResource = CRDGroupVersion.WithResource("globalnetworkpolicies")
+	TierResource                = CRDGroupVersion.WithResource("tiers")
+)
+
+// IsCalicoAPIVersion is whether apiVersion is projectcalico.org's or crd.projectcalico.org's -- to tell Calico's
+// NetworkPolicy apart from Kubernetes'
+func IsCalicoAPIVersion(apiVersion string) bool {
+	group := strings.Split(apiVersion, "/")[0]
+	return group == Group || group == CRDGroup
+}
+
+// NetworkPolicy is namespaced: its selectors select endpoints in its namespace, unless a rule's namespaceSelector
+// says otherwise
+type NetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              PolicySpec `json:"spec"`
+}
+
+// GlobalNetworkPolicy is cluster-scoped: its selectors select endpoints in all namespaces
+type GlobalNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              PolicySpec `json:"spec"`
+}
+
+// PolicySpec is shared by NetworkPolicy and GlobalNetworkPolicy; NamespaceSelector is only allowed on the latter
+type PolicySpec struct {
+	// Tier defaults to DefaultTierName
+	Tier string `json:"tier,omitempty"`
+	// Order sorts policies within a tier, lowest first; policies without one come last
+	Order *float64 `json:"order,omitempty"`
+	// Selector is a Calico selector expression, such as "app == 'web' && has(tier)"; empty selects all endpoints
+	Selector          string       `json:"selector,omitempty"`
+	NamespaceSelector string       `json:"namespaceSelector,omitempty"`
+	Types             []PolicyType `json:"types,omitempty"`
+	Ingress           []Rule       `json:"ingress,omitempty"`
+	Egress            []Rule       `json:"egress,omitempty"`
+}
+
+type PolicyType string
+
+const (
+	PolicyTypeIngress PolicyType = "Ingress"
+	PolicyTypeEgress  PolicyType = "Egress"
+)
+
+// PolicyTypes are a policy's Types or, if it has none, as Calico defaults them: Ingress, plus Egress if it has
+// egress rules -- or only Egress, if it has only egress rules
+func (s *PolicySpec) PolicyTypes() []PolicyType {
+	if len(s.Types) > 0 {
+		return s.Types
+	}
+	if len(s.Egress) == 0 {
+		return []PolicyType{PolicyTypeIngress}
+	} else if len(s.Ingress) == 0 {
+		return []PolicyType{PolicyTypeEgress}
+	}
+	return []PolicyType{PolicyTypeIngress, PolicyTypeEgress}
+}
+
+type Action string
+
+const (
+	ActionAllow Action = "Allow"
+	ActionDeny  Action = "Deny"
+	// ActionLog doesn't decide traffic: evaluation carries on with the next rule
+	ActionLog Action = "Log"
+	// ActionPass skips the rest of the tier's policies, on to the next tier
+	ActionPass Action = "Pass"
+)
+
+// Rule matches traffic if its protocol, source and destination all do
+type Rule struct {
+	Action Action `json:"action"`
+	// Protocol and NotProtocol are names such as TCP, UDP, SCTP or ICMP, or numbers
+	Protocol    *intstr.IntOrString `json:"protocol,omitempty"`
+	NotProtocol *intstr.IntOrString `json:"notProtocol,omitempty"`
+	Source      EntityRule          `json:"source,omitempty"`
+	Destination EntityRule          `json:"destination,omitempty"`
+}
+
+// EntityRule matches one end of the traffic; it matches everything if it's empty
+type EntityRule struct {
+	Nets              []string `json:"nets,omitempty"`
+	NotNets           []string `json:"notNets,omitempty"`
+	Selector          string   `json:"selector,omitempty"`
+	NotSelector       string   `json:"notSelector,omitempty"`
+	NamespaceSelector string   `json:"namespaceSelector,omitempty"`
+	// Ports are numbers, "min:max" ranges or names
+	Ports    []intstr.IntOrString `json:"ports,omitempty"`
+	NotPorts []intstr.IntOrString `json:"notPorts,omitempty"`
+
+	ServiceAccounts *ServiceAccountMatch `json:"serviceAccounts,omitempty"`
+	Services        *ServiceMatch        `json:"services,omitempty"`
+}
+
+// ServiceAccountMatch and ServiceMatch aren't modeled; they're only read to warn about rules which use them
+type ServiceAccountMatch struct {
+	Names    []string `json:"names,omitempty"`
+	Selector string   `json:"selector,omitempty"`
+}
+
+type ServiceMatch struct {
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Tier groups policies; tiers are evaluated in order, lowest first, and tiers without one come last
+type Tier struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TierSpec `json:"spec"`
+}
+
+type TierSpec struct {
+	Order *float64 `json:"order,omitempty"`
+	// DefaultAction decides traffic to or from endpoints which the tier's policies select, but whose rules don't
+	// match it: Deny, the default, or Pass
+	DefaultAction Action `json:"defaultAction,omitempty"`
+}
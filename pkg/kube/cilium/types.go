@@ -0,0 +1,117 @@
+package cilium
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// These types mirror the cilium.io/v2 CiliumNetworkPolicy and CiliumClusterwideNetworkPolicy APIs, as far as
+// cyclonus uses them: their L3/L4 rules.  L7 rules, and peers other than endpoints, CIDRs and entities, are
+// dropped when unmarshalling.
+
+const (
+	Group   = "cilium.io"
+	Version = "v2"
+
+	CiliumNetworkPolicyKind            = "CiliumNetworkPolicy"
+	CiliumClusterwideNetworkPolicyKind = "CiliumClusterwideNetworkPolicy"
+
+	// NamespaceLabel is the label, on every endpoint, of its namespace's name
+	NamespaceLabel = "io.kubernetes.pod.namespace"
+	// NamespaceLabelsPrefix prefixes the labels, on every endpoint, of its namespace's labels
+	NamespaceLabelsPrefix = "io.cilium.k8s.namespace.labels."
+)
+
+var (
+	GroupVersion = schema.GroupVersion{Group: Group, Version: Version}
+
+	CiliumNetworkPolicyResource            = GroupVersion.WithResource("ciliumnetworkpolicies")
+	CiliumClusterwideNetworkPolicyResource = GroupVersion.WithResource("ciliumclusterwidenetworkpolicies")
+)
+
+// CiliumNetworkPolicy is namespaced: its endpoint selectors select pods in its namespace, unless they name another
+// one.  It has a single rule in Spec, or several in Specs.
+type CiliumNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              *Rule  `json:"spec,omitempty"`
+	Specs             []Rule `json:"specs,omitempty"`
+}
+
+// CiliumClusterwideNetworkPolicy is cluster-scoped: its endpoint selectors select pods in all namespaces
+type CiliumClusterwideNetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              *Rule  `json:"spec,omitempty"`
+	Specs             []Rule `json:"specs,omitempty"`
+}
+
+// Rules returns a policy's Spec and Specs together
+func Rules(spec *Rule, specs []Rule) []Rule {
+	var rules []Rule
+	if spec != nil {
+		rules = append(rules, *spec)
+	}
+	return append(rules, specs...)
+}
+
+// Rule applies to the endpoints its EndpointSelector selects.  An endpoint selected by a rule with any ingress --
+// or egress -- rules, allowing or denying, only allows traffic in that direction which an allowing rule matches,
+// and no denying rule does.
+type Rule struct {
+	EndpointSelector metav1.LabelSelector `json:"endpointSelector"`
+	Ingress          []IngressRule        `json:"ingress,omitempty"`
+	IngressDeny      []IngressRule        `json:"ingressDeny,omitempty"`
+	Egress           []EgressRule         `json:"egress,omitempty"`
+	EgressDeny       []EgressRule         `json:"egressDeny,omitempty"`
+	Description      string               `json:"description,omitempty"`
+}
+
+// IngressRule matches traffic from any of its peers, on any of its ports.  A rule with ports but no peers matches
+// all peers; a rule with neither matches nothing.
+type IngressRule struct {
+	FromEndpoints []metav1.LabelSelector `json:"fromEndpoints,omitempty"`
+	FromCIDR      []string               `json:"fromCIDR,omitempty"`
+	FromCIDRSet   []CIDRRule             `json:"fromCIDRSet,omitempty"`
+	FromEntities  []Entity               `json:"fromEntities,omitempty"`
+	ToPorts       []PortRule             `json:"toPorts,omitempty"`
+}
+
+type EgressRule struct {
+	ToEndpoints []metav1.LabelSelector `json:"toEndpoints,omitempty"`
+	ToCIDR      []string               `json:"toCIDR,omitempty"`
+	ToCIDRSet   []CIDRRule             `json:"toCIDRSet,omitempty"`
+	ToEntities  []Entity               `json:"toEntities,omitempty"`
+	ToPorts     []PortRule             `json:"toPorts,omitempty"`
+}
+
+type CIDRRule struct {
+	Cidr        string   `json:"cidr"`
+	ExceptCIDRs []string `json:"except,omitempty"`
+}
+
+type Entity string
+
+const (
+	// EntityAll is every peer, in or outside the cluster
+	EntityAll Entity = "all"
+	// EntityWorld is every peer outside the cluster
+	EntityWorld Entity = "world"
+	// EntityCluster is every pod -- and node -- in the cluster
+	EntityCluster Entity = "cluster"
+)
+
+// PortRule: its L7 rules aren't modeled
+type PortRule struct {
+	Ports []PortProtocol `json:"ports,omitempty"`
+}
+
+// PortProtocol's Port is a number or a name; an empty or "0" port is all ports.  Protocol is TCP, UDP, SCTP or ANY,
+// the default.
+type PortProtocol struct {
+	Port     string `json:"port,omitempty"`
+	EndPort  int32  `json:"endPort,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+const ProtocolAny = "ANY"
@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	. "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const EffectivePolicySourcesAnnotation = "cyclonus.mattfenwick.github.io/source-policies"
+
+// PoliciesSelectingPod are the policies in pod's namespace whose pod selector matches its labels, sorted by name
+func PoliciesSelectingPod(pod v1.Pod, policies []*NetworkPolicy) []*NetworkPolicy {
+	var selecting []*NetworkPolicy
+	for _, policy := range policies {
+		namespace := policy.Namespace
+		if namespace == "" {
+			namespace = v1.NamespaceDefault
+		}
+		if namespace == pod.Namespace && IsLabelsMatchLabelSelector(pod.Labels, policy.Spec.PodSelector) {
+			selecting = append(selecting, policy)
+		}
+	}
+	sort.SliceStable(selecting, func(i, j int) bool {
+		return selecting[i].Name < selecting[j].Name
+	})
+	return selecting
+}
+
+// EffectivePolicy synthesizes a single policy which allows exactly what all the policies selecting pod allow,
+// together: since policies only ever add allowed traffic, that's every rule of theirs -- with duplicates removed --
+// for each direction which at least one of them isolates the pod for.  Peers without a namespace selector are
+// still relative to the pod's namespace, which is the namespace of all the policies.  The policy selects pod by
+// all of its labels, and is annotated with the names of the policies it was synthesized from; it's nil if no
+// policy selects pod.
+func EffectivePolicy(pod v1.Pod, policies []*NetworkPolicy) *NetworkPolicy {
+	selecting := PoliciesSelectingPod(pod, policies)
+	if len(selecting) == 0 {
+		return nil
+	}
+
+	effective := &NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pod.Namespace,
+			Name:      fmt.Sprintf("effective-%s", pod.Name),
+		},
+		Spec: NetworkPolicySpec{PodSelector: metav1.LabelSelector{MatchLabels: pod.Labels}},
+	}
+	var sources []string
+	isIngress, isEgress := false, false
+	for _, policy := range selecting {
+		sources = append(sources, fmt.Sprintf("%s/%s", pod.Namespace, policy.Name))
+		// a policy's rules only apply to the directions of its policy types
+		for _, policyType := range defaultedPolicyTypes(policy) {
+			switch policyType {
+			case PolicyTypeIngress:
+				isIngress = true
+				for _, rule := range policy.Spec.Ingress {
+					if !containsIngressRule(effective.Spec.Ingress, rule) {
+						effective.Spec.Ingress = append(effective.Spec.Ingress, *rule.DeepCopy())
+					}
+				}
+			case PolicyTypeEgress:
+				isEgress = true
+				for _, rule := range policy.Spec.Egress {
+					if !containsEgressRule(effective.Spec.Egress, rule) {
+						effective.Spec.Egress = append(effective.Spec.Egress, *rule.DeepCopy())
+					}
+				}
+			}
+		}
+	}
+	effective.Annotations = map[string]string{EffectivePolicySourcesAnnotation: strings.Join(sources, ",")}
+	if isIngress {
+		effective.Spec.PolicyTypes = append(effective.Spec.PolicyTypes, PolicyTypeIngress)
+	}
+	if isEgress {
+		effective.Spec.PolicyTypes = append(effective.Spec.PolicyTypes, PolicyTypeEgress)
+	}
+	return effective
+}
+
+// defaultedPolicyTypes are policy's types as the API server defaults them, if they're not set: ingress, and egress
+// if the policy has any egress rules
+func defaultedPolicyTypes(policy *NetworkPolicy) []PolicyType {
+	if len(policy.Spec.PolicyTypes) > 0 {
+		return policy.Spec.PolicyTypes
+	}
+	if len(policy.Spec.Egress) > 0 {
+		return []PolicyType{PolicyTypeIngress, PolicyTypeEgress}
+	}
+	return []PolicyType{PolicyTypeIngress}
+}
+
+func containsIngressRule(rules []NetworkPolicyIngressRule, rule NetworkPolicyIngressRule) bool {
+	for _, r := range rules {
+		if reflect.DeepEqual(r, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsEgressRule(rules []NetworkPolicyEgressRule, rule NetworkPolicyEgressRule) bool {
+	for _, r := range rules {
+		if reflect.DeepEqual(r, rule) {
+			return true
+		}
+	}
+	return false
+}
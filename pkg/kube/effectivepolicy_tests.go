@@ -0,0 +1,71 @@
+package kube
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	. "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func RunEffectivePolicyTests() {
+	Describe("EffectivePolicy", func() {
+		port80 := intstr.FromInt(80)
+		fromB := NetworkPolicyIngressRule{From: []NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pod": "b"}}}}}
+		onPort80 := NetworkPolicyIngressRule{Ports: []NetworkPolicyPort{{Port: &port80}}}
+		toDNS := NetworkPolicyEgressRule{To: []NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}}}
+		policy := func(namespace string, name string, selector map[string]string, types []PolicyType, ingress []NetworkPolicyIngressRule, egress []NetworkPolicyEgressRule) *NetworkPolicy {
+			return &NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Spec:       NetworkPolicySpec{PodSelector: metav1.LabelSelector{MatchLabels: selector}, PolicyTypes: types, Ingress: ingress, Egress: egress},
+			}
+		}
+		policies := []*NetworkPolicy{
+			policy("x", "allow-port-80", map[string]string{}, []PolicyType{PolicyTypeIngress}, []NetworkPolicyIngressRule{onPort80}, nil),
+			policy("x", "allow-b", map[string]string{"pod": "a"}, []PolicyType{PolicyTypeIngress}, []NetworkPolicyIngressRule{fromB, onPort80}, nil),
+			// egress rules of a policy which isn't for egress don't apply
+			policy("x", "ingress-only", map[string]string{"pod": "a"}, []PolicyType{PolicyTypeIngress}, nil, []NetworkPolicyEgressRule{toDNS}),
+			policy("x", "other-pod", map[string]string{"pod": "c"}, []PolicyType{PolicyTypeEgress}, nil, []NetworkPolicyEgressRule{toDNS}),
+			policy("y", "other-namespace", map[string]string{}, []PolicyType{PolicyTypeEgress}, nil, nil),
+		}
+		podA := v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "a", Labels: map[string]string{"pod": "a"}}}
+
+		It("Should combine the rules of all policies selecting a pod, without duplicates", func() {
+			effective := EffectivePolicy(podA, policies)
+
+			Expect(effective.Namespace).To(Equal("x"))
+			Expect(effective.Name).To(Equal("effective-a"))
+			Expect(effective.Annotations[EffectivePolicySourcesAnnotation]).To(Equal("x/allow-b,x/allow-port-80,x/ingress-only"))
+			Expect(effective.Spec.PodSelector).To(Equal(metav1.LabelSelector{MatchLabels: map[string]string{"pod": "a"}}))
+			Expect(effective.Spec.PolicyTypes).To(Equal([]PolicyType{PolicyTypeIngress}))
+			Expect(effective.Spec.Ingress).To(Equal([]NetworkPolicyIngressRule{fromB, onPort80}))
+			Expect(effective.Spec.Egress).To(BeEmpty())
+		})
+
+		It("Should isolate a direction with no rules, denying it", func() {
+			pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "y", Name: "a"}}
+			effective := EffectivePolicy(pod, policies)
+
+			Expect(effective.Spec.PolicyTypes).To(Equal([]PolicyType{PolicyTypeEgress}))
+			Expect(effective.Spec.Egress).To(BeEmpty())
+		})
+
+		It("Should default unset policy types the way the API server does", func() {
+			unset := []*NetworkPolicy{
+				policy("x", "ingress", map[string]string{}, nil, []NetworkPolicyIngressRule{fromB}, nil),
+				policy("x", "egress", map[string]string{}, nil, nil, []NetworkPolicyEgressRule{toDNS}),
+			}
+			effective := EffectivePolicy(podA, unset)
+
+			Expect(effective.Spec.PolicyTypes).To(Equal([]PolicyType{PolicyTypeIngress, PolicyTypeEgress}))
+			Expect(effective.Spec.Ingress).To(Equal([]NetworkPolicyIngressRule{fromB}))
+			Expect(effective.Spec.Egress).To(Equal([]NetworkPolicyEgressRule{toDNS}))
+		})
+
+		It("Should be nil for pods no policy selects", func() {
+			pod := v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "z", Name: "a"}}
+			Expect(EffectivePolicy(pod, policies)).To(BeNil())
+		})
+	})
+}
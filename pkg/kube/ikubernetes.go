@@ -1,16 +1,25 @@
 package kube
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/kube/openshift"
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
 )
 
 type IKubernetes interface {
 	CreateNamespace(kubeNamespace *v1.Namespace) (*v1.Namespace, error)
 	GetNamespace(namespace string) (*v1.Namespace, error)
+	GetAllNamespaces() (*v1.NamespaceList, error)
 	SetNamespaceLabels(namespace string, labels map[string]string) (*v1.Namespace, error)
 	DeleteNamespace(namespace string) error
 
@@ -20,6 +29,14 @@ type IKubernetes interface {
 	DeleteNetworkPolicy(namespace string, name string) error
 	DeleteAllNetworkPoliciesInNamespace(namespace string) error
 
+	CreateAdminNetworkPolicy(policy *anp.AdminNetworkPolicy) (*anp.AdminNetworkPolicy, error)
+	GetAllAdminNetworkPolicies() ([]anp.AdminNetworkPolicy, error)
+	DeleteAdminNetworkPolicy(name string) error
+
+	CreateBaselineAdminNetworkPolicy(policy *anp.BaselineAdminNetworkPolicy) (*anp.BaselineAdminNetworkPolicy, error)
+	GetBaselineAdminNetworkPolicy() (*anp.BaselineAdminNetworkPolicy, error)
+	DeleteBaselineAdminNetworkPolicy() error
+
 	CreateService(kubeService *v1.Service) (*v1.Service, error)
 	GetService(namespace string, name string) (*v1.Service, error)
 	DeleteService(namespace string, name string) error
@@ -30,8 +47,25 @@ type IKubernetes interface {
 	DeletePod(namespace string, pod string) error
 	SetPodLabels(namespace string, pod string, labels map[string]string) (*v1.Pod, error)
 	GetPodsInNamespace(namespace string) ([]v1.Pod, error)
+	CreateEphemeralContainer(namespace string, pod string, container v1.EphemeralContainer) error
+
+	GetEventsInNamespace(namespace string) ([]v1.Event, error)
 
 	ExecuteRemoteCommand(namespace string, pod string, container string, command []string) (string, string, error, error)
+
+	// ProxyPodRequest POSTs body to path on a pod's port, through the API server's pod proxy, and returns the response
+	ProxyPodRequest(namespace string, pod string, port int, path string, body []byte) ([]byte, error)
+
+	CreateDaemonSet(daemonSet *appsv1.DaemonSet) (*appsv1.DaemonSet, error)
+	GetDaemonSet(namespace string, name string) (*appsv1.DaemonSet, error)
+	DeleteDaemonSet(namespace string, name string) error
+	CreateSecret(secret *v1.Secret) (*v1.Secret, error)
+	GetSecret(namespace string, name string) (*v1.Secret, error)
+	DeleteSecret(namespace string, name string) error
+	GetNode(name string) (*v1.Node, error)
+
+	CreateRoute(route *openshift.Route) (*openshift.Route, error)
+	GetRoute(namespace string, name string) (*openshift.Route, error)
 }
 
 func GetNetworkPoliciesInNamespaces(kubernetes IKubernetes, namespaces []string) ([]networkingv1.NetworkPolicy, error) {
@@ -68,6 +102,18 @@ func GetPodsInNamespaces(kubernetes IKubernetes, namespaces []string) ([]v1.Pod,
 	return allPods, nil
 }
 
+func GetEventsInNamespaces(kubernetes IKubernetes, namespaces []string) ([]v1.Event, error) {
+	var allEvents []v1.Event
+	for _, ns := range namespaces {
+		events, err := kubernetes.GetEventsInNamespace(ns)
+		if err != nil {
+			return nil, err
+		}
+		allEvents = append(allEvents, events...)
+	}
+	return allEvents, nil
+}
+
 func GetServicesInNamespaces(kubernetes IKubernetes, namespaces []string) ([]v1.Service, error) {
 	var allServices []v1.Service
 	for _, ns := range namespaces {
@@ -85,19 +131,33 @@ type MockNamespace struct {
 	Netpols         map[string]*networkingv1.NetworkPolicy
 	Pods            map[string]*v1.Pod
 	Services        map[string]*v1.Service
+	DaemonSets      map[string]*appsv1.DaemonSet
+	Secrets         map[string]*v1.Secret
+	Routes          map[string]*openshift.Route
 }
 
 type MockKubernetes struct {
-	Namespaces map[string]*MockNamespace
-	passRate   float64
-	podID      int
+	Namespaces     map[string]*MockNamespace
+	AdminPolicies  map[string]*anp.AdminNetworkPolicy
+	BaselinePolicy *anp.BaselineAdminNetworkPolicy
+	Nodes          map[string]*v1.Node
+	// DualStack gives pods IPv6 addresses as well as IPv4 addresses
+	DualStack bool
+	// ProxyHandler, if set, answers pod proxy requests, as if it were running in every pod; otherwise they fail
+	ProxyHandler http.Handler
+	passRate     float64
+	podID        int
+	// lock serializes calls, since test cases may run in parallel against the same mock
+	lock sync.Mutex
 }
 
 func NewMockKubernetes(passRate float64) *MockKubernetes {
 	return &MockKubernetes{
-		Namespaces: map[string]*MockNamespace{},
-		passRate:   passRate,
-		podID:      1,
+		Namespaces:    map[string]*MockNamespace{},
+		AdminPolicies: map[string]*anp.AdminNetworkPolicy{},
+		Nodes:         map[string]*v1.Node{},
+		passRate:      passRate,
+		podID:         1,
 	}
 }
 
@@ -109,22 +169,38 @@ func (m *MockKubernetes) getNamespaceObject(namespace string) (*MockNamespace, e
 }
 
 func (m *MockKubernetes) GetNamespace(namespace string) (*v1.Namespace, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	if ns, ok := m.Namespaces[namespace]; ok {
 		return ns.NamespaceObject, nil
 	}
 	return nil, errors.Errorf("namespace %s not found", namespace)
 }
 
+func (m *MockKubernetes) GetAllNamespaces() (*v1.NamespaceList, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsList := &v1.NamespaceList{}
+	for _, ns := range m.Namespaces {
+		nsList.Items = append(nsList.Items, *ns.NamespaceObject)
+	}
+	return nsList, nil
+}
+
 func (m *MockKubernetes) SetNamespaceLabels(namespace string, labels map[string]string) (*v1.Namespace, error) {
-	ns, err := m.GetNamespace(namespace)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsObject, err := m.getNamespaceObject(namespace)
 	if err != nil {
 		return nil, err
 	}
-	ns.Labels = labels
-	return ns, nil
+	nsObject.NamespaceObject.Labels = labels
+	return nsObject.NamespaceObject, nil
 }
 
 func (m *MockKubernetes) DeleteNamespace(ns string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	if _, ok := m.Namespaces[ns]; !ok {
 		return errors.Errorf("namespace %s not found", ns)
 	}
@@ -133,6 +209,8 @@ func (m *MockKubernetes) DeleteNamespace(ns string) error {
 }
 
 func (m *MockKubernetes) CreateNamespace(ns *v1.Namespace) (*v1.Namespace, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	if _, ok := m.Namespaces[ns.Name]; ok {
 		return nil, errors.Errorf("namespace %s already present", ns.Name)
 	}
@@ -141,11 +219,16 @@ func (m *MockKubernetes) CreateNamespace(ns *v1.Namespace) (*v1.Namespace, error
 		Netpols:         map[string]*networkingv1.NetworkPolicy{},
 		Pods:            map[string]*v1.Pod{},
 		Services:        map[string]*v1.Service{},
+		DaemonSets:      map[string]*appsv1.DaemonSet{},
+		Secrets:         map[string]*v1.Secret{},
+		Routes:          map[string]*openshift.Route{},
 	}
 	return ns, nil
 }
 
 func (m *MockKubernetes) DeleteAllNetworkPoliciesInNamespace(ns string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(ns)
 	if err != nil {
 		return err
@@ -155,6 +238,8 @@ func (m *MockKubernetes) DeleteAllNetworkPoliciesInNamespace(ns string) error {
 }
 
 func (m *MockKubernetes) DeleteNetworkPolicy(ns string, name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(ns)
 	if err != nil {
 		return err
@@ -167,6 +252,8 @@ func (m *MockKubernetes) DeleteNetworkPolicy(ns string, name string) error {
 }
 
 func (m *MockKubernetes) GetNetworkPoliciesInNamespace(namespace string) ([]networkingv1.NetworkPolicy, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(namespace)
 	if err != nil {
 		return nil, err
@@ -179,6 +266,8 @@ func (m *MockKubernetes) GetNetworkPoliciesInNamespace(namespace string) ([]netw
 }
 
 func (m *MockKubernetes) UpdateNetworkPolicy(policy *networkingv1.NetworkPolicy) (*networkingv1.NetworkPolicy, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(policy.Namespace)
 	if err != nil {
 		return nil, err
@@ -191,6 +280,8 @@ func (m *MockKubernetes) UpdateNetworkPolicy(policy *networkingv1.NetworkPolicy)
 }
 
 func (m *MockKubernetes) CreateNetworkPolicy(policy *networkingv1.NetworkPolicy) (*networkingv1.NetworkPolicy, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(policy.Namespace)
 	if err != nil {
 		return nil, err
@@ -202,7 +293,197 @@ func (m *MockKubernetes) CreateNetworkPolicy(policy *networkingv1.NetworkPolicy)
 	return policy, nil
 }
 
+func (m *MockKubernetes) CreateAdminNetworkPolicy(policy *anp.AdminNetworkPolicy) (*anp.AdminNetworkPolicy, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.AdminPolicies[policy.Name]; ok {
+		return nil, errors.Errorf("admin network policy %s already present", policy.Name)
+	}
+	m.AdminPolicies[policy.Name] = policy
+	return policy, nil
+}
+
+func (m *MockKubernetes) GetAllAdminNetworkPolicies() ([]anp.AdminNetworkPolicy, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var policies []anp.AdminNetworkPolicy
+	for _, policy := range m.AdminPolicies {
+		policies = append(policies, *policy)
+	}
+	return policies, nil
+}
+
+func (m *MockKubernetes) DeleteAdminNetworkPolicy(name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.AdminPolicies[name]; !ok {
+		return errors.Errorf("admin network policy %s not found", name)
+	}
+	delete(m.AdminPolicies, name)
+	return nil
+}
+
+func (m *MockKubernetes) CreateBaselineAdminNetworkPolicy(policy *anp.BaselineAdminNetworkPolicy) (*anp.BaselineAdminNetworkPolicy, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.BaselinePolicy != nil {
+		return nil, errors.Errorf("baseline admin network policy already present")
+	}
+	m.BaselinePolicy = policy
+	return policy, nil
+}
+
+func (m *MockKubernetes) GetBaselineAdminNetworkPolicy() (*anp.BaselineAdminNetworkPolicy, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.BaselinePolicy, nil
+}
+
+func (m *MockKubernetes) DeleteBaselineAdminNetworkPolicy() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.BaselinePolicy == nil {
+		return errors.Errorf("baseline admin network policy not found")
+	}
+	m.BaselinePolicy = nil
+	return nil
+}
+
+// GetEventsInNamespace returns no events, since nothing happens in a mock namespace
+func (m *MockKubernetes) GetEventsInNamespace(namespace string) ([]v1.Event, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, err := m.getNamespaceObject(namespace); err != nil {
+		return nil, err
+	}
+	return []v1.Event{}, nil
+}
+
+func (m *MockKubernetes) CreateDaemonSet(daemonSet *appsv1.DaemonSet) (*appsv1.DaemonSet, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsObject, err := m.getNamespaceObject(daemonSet.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := nsObject.DaemonSets[daemonSet.Name]; ok {
+		return nil, errors.Errorf("daemonset %s/%s already exists", daemonSet.Namespace, daemonSet.Name)
+	}
+	nsObject.DaemonSets[daemonSet.Name] = daemonSet
+	return daemonSet, nil
+}
+
+func (m *MockKubernetes) GetDaemonSet(namespace string, name string) (*appsv1.DaemonSet, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsObject, err := m.getNamespaceObject(namespace)
+	if err != nil {
+		return nil, err
+	}
+	if ds, ok := nsObject.DaemonSets[name]; ok {
+		return ds, nil
+	}
+	return nil, errors.Errorf("daemonset %s/%s not found", namespace, name)
+}
+
+func (m *MockKubernetes) DeleteDaemonSet(namespace string, name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsObject, err := m.getNamespaceObject(namespace)
+	if err != nil {
+		return err
+	}
+	if _, ok := nsObject.DaemonSets[name]; !ok {
+		return errors.Errorf("daemonset %s/%s not found", namespace, name)
+	}
+	delete(nsObject.DaemonSets, name)
+	return nil
+}
+
+func (m *MockKubernetes) CreateSecret(secret *v1.Secret) (*v1.Secret, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsObject, err := m.getNamespaceObject(secret.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := nsObject.Secrets[secret.Name]; ok {
+		return nil, errors.Errorf("secret %s/%s already exists", secret.Namespace, secret.Name)
+	}
+	nsObject.Secrets[secret.Name] = secret
+	return secret, nil
+}
+
+func (m *MockKubernetes) GetSecret(namespace string, name string) (*v1.Secret, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsObject, err := m.getNamespaceObject(namespace)
+	if err != nil {
+		return nil, err
+	}
+	if secret, ok := nsObject.Secrets[name]; ok {
+		return secret, nil
+	}
+	return nil, errors.Errorf("secret %s/%s not found", namespace, name)
+}
+
+func (m *MockKubernetes) DeleteSecret(namespace string, name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsObject, err := m.getNamespaceObject(namespace)
+	if err != nil {
+		return err
+	}
+	if _, ok := nsObject.Secrets[name]; !ok {
+		return errors.Errorf("secret %s/%s not found", namespace, name)
+	}
+	delete(nsObject.Secrets, name)
+	return nil
+}
+
+func (m *MockKubernetes) GetNode(name string) (*v1.Node, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if node, ok := m.Nodes[name]; ok {
+		return node, nil
+	}
+	return nil, errors.Errorf("node %s not found", name)
+}
+
+func (m *MockKubernetes) CreateRoute(route *openshift.Route) (*openshift.Route, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsObject, err := m.getNamespaceObject(route.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := nsObject.Routes[route.Name]; ok {
+		return nil, errors.Errorf("route %s/%s already present", route.Namespace, route.Name)
+	}
+	created := *route
+	if created.Spec.Host == "" {
+		created.Spec.Host = fmt.Sprintf("%s-%s.apps.example.com", route.Name, route.Namespace)
+	}
+	nsObject.Routes[route.Name] = &created
+	return &created, nil
+}
+
+func (m *MockKubernetes) GetRoute(namespace string, name string) (*openshift.Route, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	nsObject, err := m.getNamespaceObject(namespace)
+	if err != nil {
+		return nil, err
+	}
+	if route, ok := nsObject.Routes[name]; ok {
+		return route, nil
+	}
+	return nil, errors.Errorf("route %s/%s not found", namespace, name)
+}
+
 func (m *MockKubernetes) GetService(namespace string, name string) (*v1.Service, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(namespace)
 	if err != nil {
 		return nil, err
@@ -214,6 +495,8 @@ func (m *MockKubernetes) GetService(namespace string, name string) (*v1.Service,
 }
 
 func (m *MockKubernetes) CreateService(svc *v1.Service) (*v1.Service, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(svc.Namespace)
 	if err != nil {
 		return nil, err
@@ -226,6 +509,8 @@ func (m *MockKubernetes) CreateService(svc *v1.Service) (*v1.Service, error) {
 }
 
 func (m *MockKubernetes) DeleteService(namespace string, name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(namespace)
 	if err != nil {
 		return err
@@ -238,6 +523,8 @@ func (m *MockKubernetes) DeleteService(namespace string, name string) error {
 }
 
 func (m *MockKubernetes) GetServicesInNamespace(namespace string) ([]v1.Service, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(namespace)
 	if err != nil {
 		return nil, err
@@ -250,6 +537,8 @@ func (m *MockKubernetes) GetServicesInNamespace(namespace string) ([]v1.Service,
 }
 
 func (m *MockKubernetes) GetPodsInNamespace(namespace string) ([]v1.Pod, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	var pods []v1.Pod
 	nsObject, err := m.getNamespaceObject(namespace)
 	if err != nil {
@@ -262,6 +551,12 @@ func (m *MockKubernetes) GetPodsInNamespace(namespace string) ([]v1.Pod, error)
 }
 
 func (m *MockKubernetes) GetPod(namespace string, podName string) (*v1.Pod, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.getPod(namespace, podName)
+}
+
+func (m *MockKubernetes) getPod(namespace string, podName string) (*v1.Pod, error) {
 	nsObject, err := m.getNamespaceObject(namespace)
 	if err != nil {
 		return nil, err
@@ -273,7 +568,9 @@ func (m *MockKubernetes) GetPod(namespace string, podName string) (*v1.Pod, erro
 }
 
 func (m *MockKubernetes) SetPodLabels(namespace string, podName string, labels map[string]string) (*v1.Pod, error) {
-	pod, err := m.GetPod(namespace, podName)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	pod, err := m.getPod(namespace, podName)
 	if err != nil {
 		return nil, err
 	}
@@ -282,6 +579,8 @@ func (m *MockKubernetes) SetPodLabels(namespace string, podName string, labels m
 }
 
 func (m *MockKubernetes) CreatePod(pod *v1.Pod) (*v1.Pod, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(pod.Namespace)
 	if err != nil {
 		return nil, err
@@ -294,12 +593,18 @@ func (m *MockKubernetes) CreatePod(pod *v1.Pod) (*v1.Pod, error) {
 	}
 	pod.Status.Phase = v1.PodRunning
 	pod.Status.PodIP = fmt.Sprintf("192.168.1.%d", m.podID)
+	pod.Status.PodIPs = []v1.PodIP{{IP: pod.Status.PodIP}}
+	if m.DualStack {
+		pod.Status.PodIPs = append(pod.Status.PodIPs, v1.PodIP{IP: fmt.Sprintf("fd00:10:244::%x", m.podID)})
+	}
 	m.podID++
 	nsObject.Pods[pod.Name] = pod
 	return pod, nil
 }
 
 func (m *MockKubernetes) DeletePod(namespace string, podName string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(namespace)
 	if err != nil {
 		return err
@@ -311,7 +616,29 @@ func (m *MockKubernetes) DeletePod(namespace string, podName string) error {
 	return nil
 }
 
+func (m *MockKubernetes) CreateEphemeralContainer(namespace string, podName string, container v1.EphemeralContainer) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	pod, err := m.getPod(namespace, podName)
+	if err != nil {
+		return err
+	}
+	for _, existing := range pod.Spec.EphemeralContainers {
+		if existing.Name == container.Name {
+			return errors.Errorf("ephemeral container %s/%s/%s already exists", namespace, podName, container.Name)
+		}
+	}
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, container)
+	pod.Status.EphemeralContainerStatuses = append(pod.Status.EphemeralContainerStatuses, v1.ContainerStatus{
+		Name:  container.Name,
+		State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+	})
+	return nil
+}
+
 func (m *MockKubernetes) ExecuteRemoteCommand(namespace string, pod string, container string, command []string) (string, string, error, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	nsObject, err := m.getNamespaceObject(namespace)
 	if err != nil {
 		return "", "", nil, err
@@ -320,14 +647,20 @@ func (m *MockKubernetes) ExecuteRemoteCommand(namespace string, pod string, cont
 	if !ok {
 		return "", "", nil, errors.Errorf("pod %s/%s not found", namespace, pod)
 	}
-	var containerObject *v1.Container
+	found := false
 	for _, cont := range podObject.Spec.Containers {
 		if cont.Name == container {
-			containerObject = &cont
+			found = true
+			break
+		}
+	}
+	for _, cont := range podObject.Spec.EphemeralContainers {
+		if cont.Name == container {
+			found = true
 			break
 		}
 	}
-	if containerObject == nil {
+	if !found {
 		return "", "", nil, errors.Errorf("container %s/%s/%s not found", namespace, pod, container)
 	}
 
@@ -338,3 +671,22 @@ func (m *MockKubernetes) ExecuteRemoteCommand(namespace string, pod string, cont
 	}
 	return "", "", nil, nil
 }
+
+func (m *MockKubernetes) ProxyPodRequest(namespace string, pod string, port int, path string, body []byte) ([]byte, error) {
+	m.lock.Lock()
+	_, err := m.getPod(namespace, pod)
+	m.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if m.ProxyHandler == nil {
+		return nil, errors.Errorf("unable to proxy to pod %s/%s: no proxy handler", namespace, pod)
+	}
+	// the handler is called without the lock, since it may call back into the mock
+	recorder := httptest.NewRecorder()
+	m.ProxyHandler.ServeHTTP(recorder, httptest.NewRequest("POST", "http://"+pod+":"+strconv.Itoa(port)+path, bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		return nil, errors.Errorf("proxy request to pod %s/%s port %d failed with status %d: %s", namespace, pod, port, recorder.Code, recorder.Body.String())
+	}
+	return recorder.Body.Bytes(), nil
+}
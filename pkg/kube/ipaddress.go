@@ -3,6 +3,7 @@ package kube
 import (
 	"fmt"
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	"net"
 )
@@ -44,3 +45,13 @@ func MakeIPV4CIDR(ipString string, bits int) string {
 	ip := net.ParseIP(ipString)
 	return fmt.Sprintf("%s/%d", ip.Mask(mask).String(), bits)
 }
+
+// NodeInternalIP returns a node's first internal IP, or an empty string if it has none
+func NodeInternalIP(node *v1.Node) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			return address.Address
+		}
+	}
+	return ""
+}
@@ -3,11 +3,22 @@ package kube
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/kube/antrea"
+	"github.com/mattfenwick/cyclonus/pkg/kube/calico"
+	"github.com/mattfenwick/cyclonus/pkg/kube/cilium"
+	"github.com/mattfenwick/cyclonus/pkg/kube/openshift"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -17,8 +28,19 @@ import (
 )
 
 type Kubernetes struct {
-	ClientSet  *kubernetes.Clientset
-	RestConfig *rest.Config
+	ClientSet     *kubernetes.Clientset
+	DynamicClient dynamic.Interface
+	RestConfig    *rest.Config
+	// Context is used for every API call and exec; cancelling it aborts those in flight.  If nil, calls are never
+	// cancelled.
+	Context context.Context
+}
+
+func (k *Kubernetes) ctx() context.Context {
+	if k.Context == nil {
+		return context.Background()
+	}
+	return k.Context
 }
 
 func NewKubernetesForContext(context string) (*Kubernetes, error) {
@@ -33,45 +55,59 @@ func NewKubernetesForContext(context string) (*Kubernetes, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to instantiate Clientset")
 	}
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to instantiate dynamic client")
+	}
 	return &Kubernetes{
-		ClientSet:  clientset,
-		RestConfig: kubeConfig,
+		ClientSet:     clientset,
+		DynamicClient: dynamicClient,
+		RestConfig:    kubeConfig,
 	}, nil
 }
 
 func (k *Kubernetes) GetNamespace(namespace string) (*v1.Namespace, error) {
-	ns, err := k.ClientSet.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	ns, err := k.ClientSet.CoreV1().Namespaces().Get(k.ctx(), namespace, metav1.GetOptions{})
 	return ns, errors.Wrapf(err, "unable to get namespace %s", namespace)
 }
 
 func (k *Kubernetes) GetAllNamespaces() (*v1.NamespaceList, error) {
-	nsList, err := k.ClientSet.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	nsList, err := k.ClientSet.CoreV1().Namespaces().List(k.ctx(), metav1.ListOptions{})
 	return nsList, errors.Wrapf(err, "unable to list namespaces")
 }
 
+// IsGroupVersionServed asks the API server whether it serves a group version, i.e. "policy.networking.k8s.io/v1alpha1"
+func (k *Kubernetes) IsGroupVersionServed(groupVersion string) (bool, error) {
+	_, err := k.ClientSet.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if kerrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, errors.Wrapf(err, "unable to discover resources for %s", groupVersion)
+}
+
 func (k *Kubernetes) SetNamespaceLabels(namespace string, labels map[string]string) (*v1.Namespace, error) {
 	ns, err := k.GetNamespace(namespace)
 	if err != nil {
 		return nil, err
 	}
 	ns.Labels = labels
-	_, err = k.ClientSet.CoreV1().Namespaces().Update(context.TODO(), ns, metav1.UpdateOptions{})
+	_, err = k.ClientSet.CoreV1().Namespaces().Update(k.ctx(), ns, metav1.UpdateOptions{})
 	return ns, errors.Wrapf(err, "unable to update namespace %s", namespace)
 }
 
 func (k *Kubernetes) DeleteNamespace(ns string) error {
-	err := k.ClientSet.CoreV1().Namespaces().Delete(context.TODO(), ns, metav1.DeleteOptions{})
+	err := k.ClientSet.CoreV1().Namespaces().Delete(k.ctx(), ns, metav1.DeleteOptions{})
 	return errors.Wrapf(err, "unable to delete namespace %s", ns)
 }
 
 func (k *Kubernetes) CreateNamespace(ns *v1.Namespace) (*v1.Namespace, error) {
-	nsr, err := k.ClientSet.CoreV1().Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{})
+	nsr, err := k.ClientSet.CoreV1().Namespaces().Create(k.ctx(), ns, metav1.CreateOptions{})
 	return nsr, errors.Wrapf(err, "unable to create namespace %s", ns.Name)
 }
 
 func (k *Kubernetes) DeleteAllNetworkPoliciesInNamespace(ns string) error {
 	log.Debugf("deleting all network policies in namespace %s", ns)
-	netpols, err := k.ClientSet.NetworkingV1().NetworkPolicies(ns).List(context.TODO(), metav1.ListOptions{})
+	netpols, err := k.ClientSet.NetworkingV1().NetworkPolicies(ns).List(k.ctx(), metav1.ListOptions{})
 	if err != nil {
 		return errors.Wrapf(err, "unable to list network policies in ns %s", ns)
 	}
@@ -86,12 +122,12 @@ func (k *Kubernetes) DeleteAllNetworkPoliciesInNamespace(ns string) error {
 }
 
 func (k *Kubernetes) DeleteNetworkPolicy(ns string, name string) error {
-	err := k.ClientSet.NetworkingV1().NetworkPolicies(ns).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	err := k.ClientSet.NetworkingV1().NetworkPolicies(ns).Delete(k.ctx(), name, metav1.DeleteOptions{})
 	return errors.Wrapf(err, "unable to delete network policy %s/%s", ns, name)
 }
 
 func (k *Kubernetes) GetNetworkPoliciesInNamespace(namespace string) ([]networkingv1.NetworkPolicy, error) {
-	netpolList, err := k.ClientSet.NetworkingV1().NetworkPolicies(namespace).List(context.TODO(), metav1.ListOptions{})
+	netpolList, err := k.ClientSet.NetworkingV1().NetworkPolicies(namespace).List(k.ctx(), metav1.ListOptions{})
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to get netpols in namespace %s", namespace)
 	}
@@ -100,26 +136,273 @@ func (k *Kubernetes) GetNetworkPoliciesInNamespace(namespace string) ([]networki
 
 func (k *Kubernetes) UpdateNetworkPolicy(policy *networkingv1.NetworkPolicy) (*networkingv1.NetworkPolicy, error) {
 	log.Debugf("updating network policy %s/%s", policy.Namespace, policy.Name)
-	np, err := k.ClientSet.NetworkingV1().NetworkPolicies(policy.Namespace).Update(context.TODO(), policy, metav1.UpdateOptions{})
+	np, err := k.ClientSet.NetworkingV1().NetworkPolicies(policy.Namespace).Update(k.ctx(), policy, metav1.UpdateOptions{})
 	return np, errors.Wrapf(err, "unable to update network policy %s/%s", policy.Namespace, policy.Name)
 }
 
 func (k *Kubernetes) CreateNetworkPolicy(policy *networkingv1.NetworkPolicy) (*networkingv1.NetworkPolicy, error) {
 	log.Debugf("creating network policy %s/%s", policy.Namespace, policy.Name)
 
-	createdPolicy, err := k.ClientSet.NetworkingV1().NetworkPolicies(policy.Namespace).Create(context.TODO(), policy, metav1.CreateOptions{})
+	createdPolicy, err := k.ClientSet.NetworkingV1().NetworkPolicies(policy.Namespace).Create(k.ctx(), policy, metav1.CreateOptions{})
 	return createdPolicy, errors.Wrapf(err, "unable to create network policy %s/%s", policy.Namespace, policy.Name)
 }
 
+// AdminNetworkPolicies aren't in client-go, so they're handled through the dynamic client
+
+func (k *Kubernetes) CreateAdminNetworkPolicy(policy *anp.AdminNetworkPolicy) (*anp.AdminNetworkPolicy, error) {
+	log.Debugf("creating admin network policy %s", policy.Name)
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(policy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert admin network policy %s to unstructured", policy.Name)
+	}
+	created, err := k.DynamicClient.Resource(anp.AdminNetworkPolicyResource).Create(k.ctx(), &unstructured.Unstructured{Object: object}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create admin network policy %s", policy.Name)
+	}
+	var createdPolicy anp.AdminNetworkPolicy
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(created.Object, &createdPolicy)
+	return &createdPolicy, errors.Wrapf(err, "unable to convert admin network policy %s from unstructured", policy.Name)
+}
+
+// GetAllAdminNetworkPolicies returns no policies, rather than an error, if the cluster doesn't serve AdminNetworkPolicies
+func (k *Kubernetes) GetAllAdminNetworkPolicies() ([]anp.AdminNetworkPolicy, error) {
+	list, err := k.DynamicClient.Resource(anp.AdminNetworkPolicyResource).List(k.ctx(), metav1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to list admin network policies")
+	}
+	var policies []anp.AdminNetworkPolicy
+	for _, item := range list.Items {
+		var policy anp.AdminNetworkPolicy
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &policy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert admin network policy %s from unstructured", item.GetName())
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// GetCiliumNetworkPolicies returns no policies, rather than an error, if the cluster doesn't serve
+// CiliumNetworkPolicies.  Namespace may be v1.NamespaceAll.
+func (k *Kubernetes) GetCiliumNetworkPolicies(namespace string) ([]cilium.CiliumNetworkPolicy, error) {
+	list, err := k.DynamicClient.Resource(cilium.CiliumNetworkPolicyResource).Namespace(namespace).List(k.ctx(), metav1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to list cilium network policies in namespace %s", namespace)
+	}
+	var policies []cilium.CiliumNetworkPolicy
+	for _, item := range list.Items {
+		var policy cilium.CiliumNetworkPolicy
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &policy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert cilium network policy %s/%s from unstructured", item.GetNamespace(), item.GetName())
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// GetCiliumClusterwideNetworkPolicies returns no policies, rather than an error, if the cluster doesn't serve
+// CiliumClusterwideNetworkPolicies
+func (k *Kubernetes) GetCiliumClusterwideNetworkPolicies() ([]cilium.CiliumClusterwideNetworkPolicy, error) {
+	list, err := k.DynamicClient.Resource(cilium.CiliumClusterwideNetworkPolicyResource).List(k.ctx(), metav1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to list cilium clusterwide network policies")
+	}
+	var policies []cilium.CiliumClusterwideNetworkPolicy
+	for _, item := range list.Items {
+		var policy cilium.CiliumClusterwideNetworkPolicy
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &policy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert cilium clusterwide network policy %s from unstructured", item.GetName())
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// GetCalicoNetworkPolicies returns no policies, rather than an error, if the cluster doesn't serve Calico
+// NetworkPolicies.  Namespace may be v1.NamespaceAll.
+func (k *Kubernetes) GetCalicoNetworkPolicies(namespace string) ([]calico.NetworkPolicy, error) {
+	list, err := k.DynamicClient.Resource(calico.NetworkPolicyResource).Namespace(namespace).List(k.ctx(), metav1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to list calico network policies in namespace %s", namespace)
+	}
+	var policies []calico.NetworkPolicy
+	for _, item := range list.Items {
+		var policy calico.NetworkPolicy
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &policy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert calico network policy %s/%s from unstructured", item.GetNamespace(), item.GetName())
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// GetCalicoGlobalNetworkPolicies returns no policies, rather than an error, if the cluster doesn't serve Calico
+// GlobalNetworkPolicies
+func (k *Kubernetes) GetCalicoGlobalNetworkPolicies() ([]calico.GlobalNetworkPolicy, error) {
+	list, err := k.DynamicClient.Resource(calico.GlobalNetworkPolicyResource).List(k.ctx(), metav1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to list calico global network policies")
+	}
+	var policies []calico.GlobalNetworkPolicy
+	for _, item := range list.Items {
+		var policy calico.GlobalNetworkPolicy
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &policy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert calico global network policy %s from unstructured", item.GetName())
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// GetCalicoTiers returns no tiers, rather than an error, if the cluster doesn't serve Calico Tiers
+func (k *Kubernetes) GetCalicoTiers() ([]calico.Tier, error) {
+	list, err := k.DynamicClient.Resource(calico.TierResource).List(k.ctx(), metav1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to list calico tiers")
+	}
+	var tiers []calico.Tier
+	for _, item := range list.Items {
+		var tier calico.Tier
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &tier)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert calico tier %s from unstructured", item.GetName())
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+// GetAntreaClusterNetworkPolicies returns no policies, rather than an error, if the cluster doesn't serve Antrea
+// ClusterNetworkPolicies
+func (k *Kubernetes) GetAntreaClusterNetworkPolicies() ([]antrea.ClusterNetworkPolicy, error) {
+	list, err := k.DynamicClient.Resource(antrea.ClusterNetworkPolicyResource).List(k.ctx(), metav1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to list antrea cluster network policies")
+	}
+	var policies []antrea.ClusterNetworkPolicy
+	for _, item := range list.Items {
+		var policy antrea.ClusterNetworkPolicy
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &policy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert antrea cluster network policy %s from unstructured", item.GetName())
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// GetAntreaNetworkPolicies returns no policies, rather than an error, if the cluster doesn't serve Antrea
+// NetworkPolicies.  Namespace may be v1.NamespaceAll.
+func (k *Kubernetes) GetAntreaNetworkPolicies(namespace string) ([]antrea.NetworkPolicy, error) {
+	list, err := k.DynamicClient.Resource(antrea.NetworkPolicyResource).Namespace(namespace).List(k.ctx(), metav1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to list antrea network policies in namespace %s", namespace)
+	}
+	var policies []antrea.NetworkPolicy
+	for _, item := range list.Items {
+		var policy antrea.NetworkPolicy
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &policy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert antrea network policy %s/%s from unstructured", item.GetNamespace(), item.GetName())
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// GetAntreaTiers returns no tiers, rather than an error, if the cluster doesn't serve Antrea Tiers
+func (k *Kubernetes) GetAntreaTiers() ([]antrea.Tier, error) {
+	list, err := k.DynamicClient.Resource(antrea.TierResource).List(k.ctx(), metav1.ListOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to list antrea tiers")
+	}
+	var tiers []antrea.Tier
+	for _, item := range list.Items {
+		var tier antrea.Tier
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &tier)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to convert antrea tier %s from unstructured", item.GetName())
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+func (k *Kubernetes) DeleteAdminNetworkPolicy(name string) error {
+	err := k.DynamicClient.Resource(anp.AdminNetworkPolicyResource).Delete(k.ctx(), name, metav1.DeleteOptions{})
+	return errors.Wrapf(err, "unable to delete admin network policy %s", name)
+}
+
+func (k *Kubernetes) CreateBaselineAdminNetworkPolicy(policy *anp.BaselineAdminNetworkPolicy) (*anp.BaselineAdminNetworkPolicy, error) {
+	log.Debugf("creating baseline admin network policy")
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(policy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert baseline admin network policy to unstructured")
+	}
+	created, err := k.DynamicClient.Resource(anp.BaselineAdminNetworkPolicyResource).Create(k.ctx(), &unstructured.Unstructured{Object: object}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create baseline admin network policy")
+	}
+	var createdPolicy anp.BaselineAdminNetworkPolicy
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(created.Object, &createdPolicy)
+	return &createdPolicy, errors.Wrapf(err, "unable to convert baseline admin network policy from unstructured")
+}
+
+// GetBaselineAdminNetworkPolicy returns nil, rather than an error, if there's no BaselineAdminNetworkPolicy or the
+// cluster doesn't serve them
+func (k *Kubernetes) GetBaselineAdminNetworkPolicy() (*anp.BaselineAdminNetworkPolicy, error) {
+	object, err := k.DynamicClient.Resource(anp.BaselineAdminNetworkPolicyResource).Get(k.ctx(), anp.BaselineAdminNetworkPolicyName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to get baseline admin network policy")
+	}
+	var policy anp.BaselineAdminNetworkPolicy
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, &policy)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert baseline admin network policy from unstructured")
+	}
+	return &policy, nil
+}
+
+func (k *Kubernetes) DeleteBaselineAdminNetworkPolicy() error {
+	err := k.DynamicClient.Resource(anp.BaselineAdminNetworkPolicyResource).Delete(k.ctx(), anp.BaselineAdminNetworkPolicyName, metav1.DeleteOptions{})
+	return errors.Wrapf(err, "unable to delete baseline admin network policy")
+}
+
 func (k *Kubernetes) GetService(namespace string, name string) (*v1.Service, error) {
-	service, err := k.ClientSet.CoreV1().Services(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	service, err := k.ClientSet.CoreV1().Services(namespace).Get(k.ctx(), name, metav1.GetOptions{})
 	return service, errors.Wrapf(err, "unable to get service %s/%s", namespace, name)
 }
 
 func (k *Kubernetes) CreateService(svc *v1.Service) (*v1.Service, error) {
 	ns := svc.Namespace
 	log.Debugf("creating service %s/%s", ns, svc.Name)
-	createdService, err := k.ClientSet.CoreV1().Services(ns).Create(context.TODO(), svc, metav1.CreateOptions{})
+	createdService, err := k.ClientSet.CoreV1().Services(ns).Create(k.ctx(), svc, metav1.CreateOptions{})
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to create service %s/%s", ns, svc.Name)
 	}
@@ -128,12 +411,12 @@ func (k *Kubernetes) CreateService(svc *v1.Service) (*v1.Service, error) {
 
 func (k *Kubernetes) DeleteService(namespace string, name string) error {
 	log.Debugf("deleting service %s/%s", namespace, name)
-	err := k.ClientSet.CoreV1().Services(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{})
+	err := k.ClientSet.CoreV1().Services(namespace).Delete(k.ctx(), name, metav1.DeleteOptions{})
 	return errors.Wrapf(err, "unable to delete service %s/%s", namespace, name)
 }
 
 func (k *Kubernetes) GetServicesInNamespace(namespace string) ([]v1.Service, error) {
-	serviceList, err := k.ClientSet.CoreV1().Services(namespace).List(context.TODO(), metav1.ListOptions{})
+	serviceList, err := k.ClientSet.CoreV1().Services(namespace).List(k.ctx(), metav1.ListOptions{})
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to get services in namespace %s", namespace)
 	}
@@ -141,15 +424,90 @@ func (k *Kubernetes) GetServicesInNamespace(namespace string) ([]v1.Service, err
 }
 
 func (k *Kubernetes) GetPodsInNamespace(namespace string) ([]v1.Pod, error) {
-	podList, err := k.ClientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	podList, err := k.ClientSet.CoreV1().Pods(namespace).List(k.ctx(), metav1.ListOptions{})
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to get pods in namespace %s", namespace)
 	}
 	return podList.Items, nil
 }
 
+func (k *Kubernetes) GetEventsInNamespace(namespace string) ([]v1.Event, error) {
+	eventList, err := k.ClientSet.CoreV1().Events(namespace).List(k.ctx(), metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get events in namespace %s", namespace)
+	}
+	return eventList.Items, nil
+}
+
+func (k *Kubernetes) CreateDaemonSet(daemonSet *appsv1.DaemonSet) (*appsv1.DaemonSet, error) {
+	log.Debugf("creating daemonset %s/%s", daemonSet.Namespace, daemonSet.Name)
+	ds, err := k.ClientSet.AppsV1().DaemonSets(daemonSet.Namespace).Create(k.ctx(), daemonSet, metav1.CreateOptions{})
+	return ds, errors.Wrapf(err, "unable to create daemonset %s/%s", daemonSet.Namespace, daemonSet.Name)
+}
+
+func (k *Kubernetes) GetDaemonSet(namespace string, name string) (*appsv1.DaemonSet, error) {
+	ds, err := k.ClientSet.AppsV1().DaemonSets(namespace).Get(k.ctx(), name, metav1.GetOptions{})
+	return ds, errors.Wrapf(err, "unable to get daemonset %s/%s", namespace, name)
+}
+
+// DeleteDaemonSet deletes a daemonset along with its pods, waiting for the pods to be gone before the daemonset is
+func (k *Kubernetes) DeleteDaemonSet(namespace string, name string) error {
+	log.Debugf("deleting daemonset %s/%s", namespace, name)
+	propagation := metav1.DeletePropagationForeground
+	err := k.ClientSet.AppsV1().DaemonSets(namespace).Delete(k.ctx(), name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	return errors.Wrapf(err, "unable to delete daemonset %s/%s", namespace, name)
+}
+
+func (k *Kubernetes) CreateSecret(secret *v1.Secret) (*v1.Secret, error) {
+	log.Debugf("creating secret %s/%s", secret.Namespace, secret.Name)
+	created, err := k.ClientSet.CoreV1().Secrets(secret.Namespace).Create(k.ctx(), secret, metav1.CreateOptions{})
+	return created, errors.Wrapf(err, "unable to create secret %s/%s", secret.Namespace, secret.Name)
+}
+
+func (k *Kubernetes) GetSecret(namespace string, name string) (*v1.Secret, error) {
+	secret, err := k.ClientSet.CoreV1().Secrets(namespace).Get(k.ctx(), name, metav1.GetOptions{})
+	return secret, errors.Wrapf(err, "unable to get secret %s/%s", namespace, name)
+}
+
+func (k *Kubernetes) DeleteSecret(namespace string, name string) error {
+	log.Debugf("deleting secret %s/%s", namespace, name)
+	err := k.ClientSet.CoreV1().Secrets(namespace).Delete(k.ctx(), name, metav1.DeleteOptions{})
+	return errors.Wrapf(err, "unable to delete secret %s/%s", namespace, name)
+}
+
+func (k *Kubernetes) GetNode(name string) (*v1.Node, error) {
+	node, err := k.ClientSet.CoreV1().Nodes().Get(k.ctx(), name, metav1.GetOptions{})
+	return node, errors.Wrapf(err, "unable to get node %s", name)
+}
+
+func (k *Kubernetes) CreateRoute(route *openshift.Route) (*openshift.Route, error) {
+	log.Debugf("creating route %s/%s", route.Namespace, route.Name)
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(route)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to convert route %s/%s to unstructured", route.Namespace, route.Name)
+	}
+	created, err := k.DynamicClient.Resource(openshift.RouteResource).Namespace(route.Namespace).Create(k.ctx(), &unstructured.Unstructured{Object: object}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create route %s/%s", route.Namespace, route.Name)
+	}
+	var createdRoute openshift.Route
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(created.Object, &createdRoute)
+	return &createdRoute, errors.Wrapf(err, "unable to convert route %s/%s from unstructured", route.Namespace, route.Name)
+}
+
+func (k *Kubernetes) GetRoute(namespace string, name string) (*openshift.Route, error) {
+	object, err := k.DynamicClient.Resource(openshift.RouteResource).Namespace(namespace).Get(k.ctx(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get route %s/%s", namespace, name)
+	}
+	var route openshift.Route
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, &route)
+	return &route, errors.Wrapf(err, "unable to convert route %s/%s from unstructured", namespace, name)
+}
+
 func (k *Kubernetes) GetPod(namespace string, podName string) (*v1.Pod, error) {
-	pod, err := k.ClientSet.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+	pod, err := k.ClientSet.CoreV1().Pods(namespace).Get(k.ctx(), podName, metav1.GetOptions{})
 	return pod, errors.Wrapf(err, "unable to get pod %s/%s", namespace, podName)
 }
 
@@ -159,7 +517,7 @@ func (k *Kubernetes) SetPodLabels(namespace string, podName string, labels map[s
 		return nil, err
 	}
 	pod.Labels = labels
-	updatedPod, err := k.ClientSet.CoreV1().Pods(namespace).Update(context.TODO(), pod, metav1.UpdateOptions{})
+	updatedPod, err := k.ClientSet.CoreV1().Pods(namespace).Update(k.ctx(), pod, metav1.UpdateOptions{})
 	return updatedPod, errors.Wrapf(err, "unable to update pod %s/%s", namespace, podName)
 }
 
@@ -167,16 +525,45 @@ func (k *Kubernetes) CreatePod(pod *v1.Pod) (*v1.Pod, error) {
 	ns := pod.Namespace
 	log.Debugf("creating pod %s/%s", ns, pod.Name)
 
-	createdPod, err := k.ClientSet.CoreV1().Pods(ns).Create(context.TODO(), pod, metav1.CreateOptions{})
+	createdPod, err := k.ClientSet.CoreV1().Pods(ns).Create(k.ctx(), pod, metav1.CreateOptions{})
 	return createdPod, errors.Wrapf(err, "unable to create pod %s/%s", ns, pod.Name)
 }
 
 func (k *Kubernetes) DeletePod(namespace string, podName string) error {
 	log.Debugf("deleting pod %s/%s", namespace, podName)
-	err := k.ClientSet.CoreV1().Pods(namespace).Delete(context.TODO(), podName, metav1.DeleteOptions{})
+	err := k.ClientSet.CoreV1().Pods(namespace).Delete(k.ctx(), podName, metav1.DeleteOptions{})
 	return errors.Wrapf(err, "unable to delete pod %s/%s", namespace, podName)
 }
 
+// CreateEphemeralContainer adds an ephemeral container to a running pod.  The cluster must have the
+// EphemeralContainers feature gate enabled.
+func (k *Kubernetes) CreateEphemeralContainer(namespace string, podName string, container v1.EphemeralContainer) error {
+	log.Debugf("creating ephemeral container %s in pod %s/%s", container.Name, namespace, podName)
+	pods := k.ClientSet.CoreV1().Pods(namespace)
+	ephemeralContainers, err := pods.GetEphemeralContainers(k.ctx(), podName, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "unable to get ephemeral containers for pod %s/%s", namespace, podName)
+	}
+	ephemeralContainers.EphemeralContainers = append(ephemeralContainers.EphemeralContainers, container)
+	_, err = pods.UpdateEphemeralContainers(k.ctx(), podName, ephemeralContainers, metav1.UpdateOptions{})
+	return errors.Wrapf(err, "unable to create ephemeral container %s in pod %s/%s", container.Name, namespace, podName)
+}
+
+func (k *Kubernetes) ProxyPodRequest(namespace string, pod string, port int, path string, body []byte) ([]byte, error) {
+	response, err := k.ClientSet.
+		CoreV1().
+		RESTClient().
+		Post().
+		Namespace(namespace).
+		Resource("pods").
+		Name(fmt.Sprintf("%s:%d", pod, port)).
+		SubResource("proxy").
+		Suffix(path).
+		Body(body).
+		DoRaw(k.ctx())
+	return response, errors.Wrapf(err, "unable to proxy request to %s on pod %s/%s port %d", path, namespace, pod, port)
+}
+
 // ExecuteRemoteCommand executes a remote shell command on the given pod
 // returns the output from stdout and stderr
 func (k *Kubernetes) ExecuteRemoteCommand(namespace string, pod string, container string, command []string) (string, string, error, error) {
@@ -206,12 +593,21 @@ func (k *Kubernetes) ExecuteRemoteCommand(namespace string, pod string, containe
 		return "", "", nil, errors.Wrapf(err, "unable to instantiate SPDYExecutor")
 	}
 
+	// this version of client-go can't cancel a stream, so a cancelled exec is abandoned to finish in the background
 	buf := &bytes.Buffer{}
 	errBuf := &bytes.Buffer{}
-	err = exec.Stream(remotecommand.StreamOptions{
-		Stdout: buf,
-		Stderr: errBuf,
-	})
+	done := make(chan error, 1)
+	go func() {
+		done <- exec.Stream(remotecommand.StreamOptions{
+			Stdout: buf,
+			Stderr: errBuf,
+		})
+	}()
+	select {
+	case err = <-done:
+	case <-k.ctx().Done():
+		return "", "", nil, errors.Wrapf(k.ctx().Err(), "exec of %+v in %s/%s cancelled", command, namespace, pod)
+	}
 
 	out, errOut := buf.String(), errBuf.String()
 	return out, errOut, errors.Wrapf(err, "unable to stream command"), nil
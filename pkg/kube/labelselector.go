@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sort"
 	"strings"
@@ -130,3 +131,33 @@ func LabelSelectorTableLines(selector metav1.LabelSelector) string {
 	}
 	return strings.Join(lines, "\n")
 }
+
+// ParseNodeSelector parses a label selector string, i.e. 'kubernetes.io/os=linux,cni notin (legacy)', into a node
+// affinity term.  Unlike a pod's nodeSelector, which only takes exact labels, this supports set-based requirements.
+func ParseNodeSelector(selector string) (*v1.NodeSelector, error) {
+	labelSelector, err := metav1.ParseToLabelSelector(selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse node selector '%s'", selector)
+	}
+	term := v1.NodeSelectorTerm{}
+	var keys []string
+	for key := range labelSelector.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		term.MatchExpressions = append(term.MatchExpressions, v1.NodeSelectorRequirement{
+			Key:      key,
+			Operator: v1.NodeSelectorOpIn,
+			Values:   []string{labelSelector.MatchLabels[key]},
+		})
+	}
+	for _, exp := range labelSelector.MatchExpressions {
+		requirement := v1.NodeSelectorRequirement{Key: exp.Key, Operator: v1.NodeSelectorOperator(exp.Operator)}
+		if len(exp.Values) > 0 {
+			requirement.Values = exp.Values
+		}
+		term.MatchExpressions = append(term.MatchExpressions, requirement)
+	}
+	return &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{term}}, nil
+}
@@ -3,6 +3,7 @@ package kube
 import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -13,5 +14,19 @@ func RunLabelSelectorTests() {
 				MatchLabels: map[string]string{"pod": "b"},
 			})).To(BeFalse())
 		})
+
+		It("Should parse node selectors into node affinity terms", func() {
+			nodeSelector, err := ParseNodeSelector("kubernetes.io/os=linux,cni notin (legacy),!windows")
+			Expect(err).To(Succeed())
+			Expect(nodeSelector.NodeSelectorTerms).To(HaveLen(1))
+			Expect(nodeSelector.NodeSelectorTerms[0].MatchExpressions).To(ConsistOf(
+				v1.NodeSelectorRequirement{Key: "kubernetes.io/os", Operator: v1.NodeSelectorOpIn, Values: []string{"linux"}},
+				v1.NodeSelectorRequirement{Key: "cni", Operator: v1.NodeSelectorOpNotIn, Values: []string{"legacy"}},
+				v1.NodeSelectorRequirement{Key: "windows", Operator: v1.NodeSelectorOpDoesNotExist},
+			))
+
+			_, err = ParseNodeSelector("os in linux")
+			Expect(err).NotTo(Succeed())
+		})
 	})
 }
@@ -0,0 +1,59 @@
+package openshift
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// These types mirror the route.openshift.io/v1 Route API from github.com/openshift/api, as far as cyclonus uses
+// them.  Like the upstream types, they're meant to be serialized to and from unstructured objects.
+
+const (
+	RouteGroup   = "route.openshift.io"
+	RouteVersion = "v1"
+	RouteKind    = "Route"
+
+	// NodeSelectorAnnotation is a project's node selector; an empty value opts the project out of the cluster's
+	// default project node selector
+	NodeSelectorAnnotation = "openshift.io/node-selector"
+)
+
+var (
+	RouteGroupVersion = schema.GroupVersion{Group: RouteGroup, Version: RouteVersion}
+
+	RouteResource = RouteGroupVersion.WithResource("routes")
+)
+
+// Route exposes a service at a host name, through the cluster's ingress routers
+type Route struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              RouteSpec   `json:"spec"`
+	Status            RouteStatus `json:"status,omitempty"`
+}
+
+type RouteSpec struct {
+	// Host is generated by the cluster if left empty
+	Host string               `json:"host,omitempty"`
+	To   RouteTargetReference `json:"to"`
+	Port *RoutePort           `json:"port,omitempty"`
+}
+
+type RouteTargetReference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type RoutePort struct {
+	TargetPort intstr.IntOrString `json:"targetPort"`
+}
+
+type RouteStatus struct {
+	Ingress []RouteIngress `json:"ingress,omitempty"`
+}
+
+type RouteIngress struct {
+	Host       string `json:"host,omitempty"`
+	RouterName string `json:"routerName,omitempty"`
+}
@@ -0,0 +1,115 @@
+package kube
+
+import (
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	networkingv1 "k8s.io/api/networking/v1"
+	"time"
+)
+
+// PolicyApplier creates and deletes network policies in bulk, for scale tests which need hundreds of them.  Requests
+// go out in batches of BatchSize, with a pause of Pacing between batches, so that the API server isn't flooded; a
+// batch's requests are issued one after another.
+type PolicyApplier struct {
+	Kubernetes IKubernetes
+	// BatchSize is how many requests to issue before pausing; 0 or less means no batching, so no pauses
+	BatchSize int
+	Pacing    time.Duration
+	// AcceptanceTimeout, if positive, is how long to wait after a bulk operation for every policy to be listed -- or,
+	// after deleting, to no longer be listed -- by the API server
+	AcceptanceTimeout time.Duration
+	// AcceptancePollInterval is how long to wait between checks for acceptance
+	AcceptancePollInterval time.Duration
+}
+
+func NewPolicyApplier(kubernetes IKubernetes, batchSize int, pacing time.Duration, acceptanceTimeout time.Duration) *PolicyApplier {
+	return &PolicyApplier{
+		Kubernetes:             kubernetes,
+		BatchSize:              batchSize,
+		Pacing:                 pacing,
+		AcceptanceTimeout:      acceptanceTimeout,
+		AcceptancePollInterval: time.Second,
+	}
+}
+
+func (p *PolicyApplier) inBatches(count int, description string, f func(i int) error) error {
+	for i := 0; i < count; i++ {
+		if i > 0 && p.BatchSize > 0 && i%p.BatchSize == 0 && p.Pacing > 0 {
+			log.Debugf("%s: %d of %d done, pausing for %s", description, i, count, p.Pacing)
+			time.Sleep(p.Pacing)
+		}
+		if err := f(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreatePolicies creates policies in order, then waits for acceptance if there's an AcceptanceTimeout
+func (p *PolicyApplier) CreatePolicies(policies []*networkingv1.NetworkPolicy) error {
+	err := p.inBatches(len(policies), "creating network policies", func(i int) error {
+		_, err := p.Kubernetes.CreateNetworkPolicy(policies[i])
+		return errors.WithMessagef(err, "unable to create network policy %d of %d", i+1, len(policies))
+	})
+	if err != nil {
+		return err
+	}
+	return p.waitForAcceptance(policies, true)
+}
+
+// DeletePolicies deletes policies in order, then waits for acceptance if there's an AcceptanceTimeout
+func (p *PolicyApplier) DeletePolicies(policies []*networkingv1.NetworkPolicy) error {
+	err := p.inBatches(len(policies), "deleting network policies", func(i int) error {
+		err := p.Kubernetes.DeleteNetworkPolicy(policies[i].Namespace, policies[i].Name)
+		return errors.WithMessagef(err, "unable to delete network policy %d of %d", i+1, len(policies))
+	})
+	if err != nil {
+		return err
+	}
+	return p.waitForAcceptance(policies, false)
+}
+
+func (p *PolicyApplier) waitForAcceptance(policies []*networkingv1.NetworkPolicy, shouldExist bool) error {
+	if p.AcceptanceTimeout <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(p.AcceptanceTimeout)
+	for {
+		pending, err := p.countPending(policies, shouldExist)
+		if err != nil {
+			return err
+		}
+		if pending == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("%d of %d network policies still not accepted after %s", pending, len(policies), p.AcceptanceTimeout)
+		}
+		log.Debugf("waiting for %d of %d network policies to be accepted", pending, len(policies))
+		time.Sleep(p.AcceptancePollInterval)
+	}
+}
+
+// countPending lists each namespace once, and counts the policies which aren't yet in the state they should be in
+func (p *PolicyApplier) countPending(policies []*networkingv1.NetworkPolicy, shouldExist bool) (int, error) {
+	listed := map[string]map[string]bool{}
+	pending := 0
+	for _, policy := range policies {
+		names, ok := listed[policy.Namespace]
+		if !ok {
+			netpols, err := p.Kubernetes.GetNetworkPoliciesInNamespace(policy.Namespace)
+			if err != nil {
+				return 0, err
+			}
+			names = map[string]bool{}
+			for _, netpol := range netpols {
+				names[netpol.Name] = true
+			}
+			listed[policy.Namespace] = names
+		}
+		if names[policy.Name] != shouldExist {
+			pending++
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,48 @@
+package kube
+
+import (
+	"fmt"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+func RunPolicyApplierTests() {
+	Describe("PolicyApplier", func() {
+		makePolicies := func(count int) []*networkingv1.NetworkPolicy {
+			var policies []*networkingv1.NetworkPolicy
+			for i := 0; i < count; i++ {
+				policies = append(policies, &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: fmt.Sprintf("policy-%d", i)}})
+			}
+			return policies
+		}
+
+		It("should create and delete policies in batches, waiting for acceptance", func() {
+			mock := NewMockKubernetes(1.0)
+			_, err := mock.CreateNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "x"}})
+			Expect(err).To(Succeed())
+
+			applier := NewPolicyApplier(mock, 4, time.Millisecond, time.Second)
+			policies := makePolicies(10)
+			Expect(applier.CreatePolicies(policies)).To(Succeed())
+			Expect(mock.Namespaces["x"].Netpols).To(HaveLen(10))
+
+			Expect(applier.DeletePolicies(policies[:6])).To(Succeed())
+			Expect(mock.Namespaces["x"].Netpols).To(HaveLen(4))
+		})
+
+		It("should stop at the first failure", func() {
+			mock := NewMockKubernetes(1.0)
+			_, err := mock.CreateNamespace(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "x"}})
+			Expect(err).To(Succeed())
+
+			policies := makePolicies(3)
+			policies[1].Namespace = "missing"
+			Expect(NewPolicyApplier(mock, 0, 0, 0).CreatePolicies(policies)).NotTo(Succeed())
+			Expect(mock.Namespaces["x"].Netpols).To(HaveLen(1))
+		})
+	})
+}
@@ -0,0 +1,631 @@
+package kube
+
+import (
+	"encoding/json"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/kube/openshift"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync"
+)
+
+// Interaction is a single recorded IKubernetes call
+type Interaction struct {
+	Method string
+	// Args is the json of the call's arguments; replays are matched on Method and Args
+	Args   string
+	Result json.RawMessage `json:",omitempty"`
+	Error  *RecordedError  `json:",omitempty"`
+	// Stdout, Stderr and CommandError are only used for ExecuteRemoteCommand
+	Stdout       string         `json:",omitempty"`
+	Stderr       string         `json:",omitempty"`
+	CommandError *RecordedError `json:",omitempty"`
+}
+
+// RecordedError keeps an error's message and, for API errors, its status -- so that replayed errors can still be
+// inspected with i.e. kerrors.IsNotFound
+type RecordedError struct {
+	Message string
+	Status  *metav1.Status `json:",omitempty"`
+}
+
+func newRecordedError(err error) *RecordedError {
+	if err == nil {
+		return nil
+	}
+	recorded := &RecordedError{Message: err.Error()}
+	if apiStatus, ok := errors.Cause(err).(kerrors.APIStatus); ok {
+		status := apiStatus.Status()
+		recorded.Status = &status
+	}
+	return recorded
+}
+
+type replayedError struct {
+	message string
+	cause   error
+}
+
+func (r *replayedError) Error() string {
+	return r.message
+}
+
+func (r *replayedError) Cause() error {
+	return r.cause
+}
+
+func (r *replayedError) Unwrap() error {
+	return r.cause
+}
+
+func (r *RecordedError) Err() error {
+	if r == nil {
+		return nil
+	}
+	if r.Status == nil {
+		return errors.New(r.Message)
+	}
+	return &replayedError{message: r.Message, cause: &kerrors.StatusError{ErrStatus: *r.Status}}
+}
+
+func marshalArgs(args ...interface{}) string {
+	bytes, err := json.Marshal(args)
+	if err != nil {
+		panic(errors.Wrapf(err, "unable to marshal args"))
+	}
+	return string(bytes)
+}
+
+// CassetteSchemaVersion is bumped whenever a change to Cassette or Interaction would keep older recordings from
+// being replayed correctly, along with a migration from the previous version in cassetteMigrations
+const CassetteSchemaVersion = 2
+
+// cassetteMigrations upgrade recordings by version
+var cassetteMigrations = map[int]utils.SchemaMigration{
+	// version 1 recordings -- from before versioning -- have the same fields as version 2
+	1: func(doc map[string]interface{}) error { return nil },
+}
+
+// Cassette is a recording of all the IKubernetes calls made during a run
+type Cassette struct {
+	SchemaVersion int
+	Interactions  []*Interaction
+}
+
+func ReadCassette(path string) (*Cassette, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read kube recording %s", path)
+	}
+	bytes, err = utils.MigrateSchema(bytes, CassetteSchemaVersion, cassetteMigrations)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to migrate kube recording %s", path)
+	}
+	cassette := &Cassette{}
+	if err := json.Unmarshal(bytes, cassette); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal kube recording %s", path)
+	}
+	return cassette, nil
+}
+
+func (c *Cassette) Write(path string) error {
+	bytes, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal kube recording")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, bytes, 0644), "unable to write kube recording to %s", path)
+}
+
+// RecordingKubernetes passes every call through to the wrapped IKubernetes, and records it in a Cassette
+type RecordingKubernetes struct {
+	IKubernetes
+	lock     sync.Mutex
+	cassette *Cassette
+}
+
+func NewRecordingKubernetes(kubernetes IKubernetes) *RecordingKubernetes {
+	return &RecordingKubernetes{IKubernetes: kubernetes, cassette: &Cassette{SchemaVersion: CassetteSchemaVersion}}
+}
+
+func (r *RecordingKubernetes) record(method string, args string, result interface{}, err error) {
+	bytes, marshalErr := json.Marshal(result)
+	if marshalErr != nil {
+		panic(errors.Wrapf(marshalErr, "unable to marshal result of %s", method))
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Method: method,
+		Args:   args,
+		Result: bytes,
+		Error:  newRecordedError(err),
+	})
+}
+
+// Cassette returns a copy of what's been recorded so far
+func (r *RecordingKubernetes) Cassette() *Cassette {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return &Cassette{SchemaVersion: r.cassette.SchemaVersion, Interactions: append([]*Interaction{}, r.cassette.Interactions...)}
+}
+
+func (r *RecordingKubernetes) CreateNamespace(kubeNamespace *v1.Namespace) (*v1.Namespace, error) {
+	ns, err := r.IKubernetes.CreateNamespace(kubeNamespace)
+	r.record("CreateNamespace", marshalArgs(kubeNamespace), ns, err)
+	return ns, err
+}
+
+func (r *RecordingKubernetes) GetNamespace(namespace string) (*v1.Namespace, error) {
+	ns, err := r.IKubernetes.GetNamespace(namespace)
+	r.record("GetNamespace", marshalArgs(namespace), ns, err)
+	return ns, err
+}
+
+func (r *RecordingKubernetes) GetAllNamespaces() (*v1.NamespaceList, error) {
+	nsList, err := r.IKubernetes.GetAllNamespaces()
+	r.record("GetAllNamespaces", marshalArgs(), nsList, err)
+	return nsList, err
+}
+
+func (r *RecordingKubernetes) SetNamespaceLabels(namespace string, labels map[string]string) (*v1.Namespace, error) {
+	ns, err := r.IKubernetes.SetNamespaceLabels(namespace, labels)
+	r.record("SetNamespaceLabels", marshalArgs(namespace, labels), ns, err)
+	return ns, err
+}
+
+func (r *RecordingKubernetes) DeleteNamespace(namespace string) error {
+	err := r.IKubernetes.DeleteNamespace(namespace)
+	r.record("DeleteNamespace", marshalArgs(namespace), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) CreateNetworkPolicy(kubePolicy *networkingv1.NetworkPolicy) (*networkingv1.NetworkPolicy, error) {
+	policy, err := r.IKubernetes.CreateNetworkPolicy(kubePolicy)
+	r.record("CreateNetworkPolicy", marshalArgs(kubePolicy), policy, err)
+	return policy, err
+}
+
+func (r *RecordingKubernetes) GetNetworkPoliciesInNamespace(namespace string) ([]networkingv1.NetworkPolicy, error) {
+	policies, err := r.IKubernetes.GetNetworkPoliciesInNamespace(namespace)
+	r.record("GetNetworkPoliciesInNamespace", marshalArgs(namespace), policies, err)
+	return policies, err
+}
+
+func (r *RecordingKubernetes) UpdateNetworkPolicy(kubePolicy *networkingv1.NetworkPolicy) (*networkingv1.NetworkPolicy, error) {
+	policy, err := r.IKubernetes.UpdateNetworkPolicy(kubePolicy)
+	r.record("UpdateNetworkPolicy", marshalArgs(kubePolicy), policy, err)
+	return policy, err
+}
+
+func (r *RecordingKubernetes) DeleteNetworkPolicy(namespace string, name string) error {
+	err := r.IKubernetes.DeleteNetworkPolicy(namespace, name)
+	r.record("DeleteNetworkPolicy", marshalArgs(namespace, name), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) DeleteAllNetworkPoliciesInNamespace(namespace string) error {
+	err := r.IKubernetes.DeleteAllNetworkPoliciesInNamespace(namespace)
+	r.record("DeleteAllNetworkPoliciesInNamespace", marshalArgs(namespace), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) CreateAdminNetworkPolicy(kubePolicy *anp.AdminNetworkPolicy) (*anp.AdminNetworkPolicy, error) {
+	policy, err := r.IKubernetes.CreateAdminNetworkPolicy(kubePolicy)
+	r.record("CreateAdminNetworkPolicy", marshalArgs(kubePolicy), policy, err)
+	return policy, err
+}
+
+func (r *RecordingKubernetes) GetAllAdminNetworkPolicies() ([]anp.AdminNetworkPolicy, error) {
+	policies, err := r.IKubernetes.GetAllAdminNetworkPolicies()
+	r.record("GetAllAdminNetworkPolicies", marshalArgs(), policies, err)
+	return policies, err
+}
+
+func (r *RecordingKubernetes) DeleteAdminNetworkPolicy(name string) error {
+	err := r.IKubernetes.DeleteAdminNetworkPolicy(name)
+	r.record("DeleteAdminNetworkPolicy", marshalArgs(name), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) CreateBaselineAdminNetworkPolicy(kubePolicy *anp.BaselineAdminNetworkPolicy) (*anp.BaselineAdminNetworkPolicy, error) {
+	policy, err := r.IKubernetes.CreateBaselineAdminNetworkPolicy(kubePolicy)
+	r.record("CreateBaselineAdminNetworkPolicy", marshalArgs(kubePolicy), policy, err)
+	return policy, err
+}
+
+func (r *RecordingKubernetes) GetBaselineAdminNetworkPolicy() (*anp.BaselineAdminNetworkPolicy, error) {
+	policy, err := r.IKubernetes.GetBaselineAdminNetworkPolicy()
+	r.record("GetBaselineAdminNetworkPolicy", marshalArgs(), policy, err)
+	return policy, err
+}
+
+func (r *RecordingKubernetes) DeleteBaselineAdminNetworkPolicy() error {
+	err := r.IKubernetes.DeleteBaselineAdminNetworkPolicy()
+	r.record("DeleteBaselineAdminNetworkPolicy", marshalArgs(), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) CreateService(kubeService *v1.Service) (*v1.Service, error) {
+	svc, err := r.IKubernetes.CreateService(kubeService)
+	r.record("CreateService", marshalArgs(kubeService), svc, err)
+	return svc, err
+}
+
+func (r *RecordingKubernetes) GetService(namespace string, name string) (*v1.Service, error) {
+	svc, err := r.IKubernetes.GetService(namespace, name)
+	r.record("GetService", marshalArgs(namespace, name), svc, err)
+	return svc, err
+}
+
+func (r *RecordingKubernetes) DeleteService(namespace string, name string) error {
+	err := r.IKubernetes.DeleteService(namespace, name)
+	r.record("DeleteService", marshalArgs(namespace, name), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) GetServicesInNamespace(namespace string) ([]v1.Service, error) {
+	svcs, err := r.IKubernetes.GetServicesInNamespace(namespace)
+	r.record("GetServicesInNamespace", marshalArgs(namespace), svcs, err)
+	return svcs, err
+}
+
+func (r *RecordingKubernetes) CreatePod(kubePod *v1.Pod) (*v1.Pod, error) {
+	pod, err := r.IKubernetes.CreatePod(kubePod)
+	r.record("CreatePod", marshalArgs(kubePod), pod, err)
+	return pod, err
+}
+
+func (r *RecordingKubernetes) GetPod(namespace string, podName string) (*v1.Pod, error) {
+	pod, err := r.IKubernetes.GetPod(namespace, podName)
+	r.record("GetPod", marshalArgs(namespace, podName), pod, err)
+	return pod, err
+}
+
+func (r *RecordingKubernetes) DeletePod(namespace string, podName string) error {
+	err := r.IKubernetes.DeletePod(namespace, podName)
+	r.record("DeletePod", marshalArgs(namespace, podName), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) SetPodLabels(namespace string, podName string, labels map[string]string) (*v1.Pod, error) {
+	pod, err := r.IKubernetes.SetPodLabels(namespace, podName, labels)
+	r.record("SetPodLabels", marshalArgs(namespace, podName, labels), pod, err)
+	return pod, err
+}
+
+func (r *RecordingKubernetes) GetPodsInNamespace(namespace string) ([]v1.Pod, error) {
+	pods, err := r.IKubernetes.GetPodsInNamespace(namespace)
+	r.record("GetPodsInNamespace", marshalArgs(namespace), pods, err)
+	return pods, err
+}
+
+func (r *RecordingKubernetes) GetEventsInNamespace(namespace string) ([]v1.Event, error) {
+	events, err := r.IKubernetes.GetEventsInNamespace(namespace)
+	r.record("GetEventsInNamespace", marshalArgs(namespace), events, err)
+	return events, err
+}
+
+func (r *RecordingKubernetes) CreateDaemonSet(daemonSet *appsv1.DaemonSet) (*appsv1.DaemonSet, error) {
+	ds, err := r.IKubernetes.CreateDaemonSet(daemonSet)
+	r.record("CreateDaemonSet", marshalArgs(daemonSet), ds, err)
+	return ds, err
+}
+
+func (r *RecordingKubernetes) GetDaemonSet(namespace string, name string) (*appsv1.DaemonSet, error) {
+	ds, err := r.IKubernetes.GetDaemonSet(namespace, name)
+	r.record("GetDaemonSet", marshalArgs(namespace, name), ds, err)
+	return ds, err
+}
+
+func (r *RecordingKubernetes) DeleteDaemonSet(namespace string, name string) error {
+	err := r.IKubernetes.DeleteDaemonSet(namespace, name)
+	r.record("DeleteDaemonSet", marshalArgs(namespace, name), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) CreateSecret(secret *v1.Secret) (*v1.Secret, error) {
+	created, err := r.IKubernetes.CreateSecret(secret)
+	r.record("CreateSecret", marshalArgs(secret), created, err)
+	return created, err
+}
+
+func (r *RecordingKubernetes) GetSecret(namespace string, name string) (*v1.Secret, error) {
+	secret, err := r.IKubernetes.GetSecret(namespace, name)
+	r.record("GetSecret", marshalArgs(namespace, name), secret, err)
+	return secret, err
+}
+
+func (r *RecordingKubernetes) DeleteSecret(namespace string, name string) error {
+	err := r.IKubernetes.DeleteSecret(namespace, name)
+	r.record("DeleteSecret", marshalArgs(namespace, name), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) GetNode(name string) (*v1.Node, error) {
+	node, err := r.IKubernetes.GetNode(name)
+	r.record("GetNode", marshalArgs(name), node, err)
+	return node, err
+}
+
+func (r *RecordingKubernetes) CreateRoute(route *openshift.Route) (*openshift.Route, error) {
+	created, err := r.IKubernetes.CreateRoute(route)
+	r.record("CreateRoute", marshalArgs(route), created, err)
+	return created, err
+}
+
+func (r *RecordingKubernetes) GetRoute(namespace string, name string) (*openshift.Route, error) {
+	route, err := r.IKubernetes.GetRoute(namespace, name)
+	r.record("GetRoute", marshalArgs(namespace, name), route, err)
+	return route, err
+}
+
+func (r *RecordingKubernetes) CreateEphemeralContainer(namespace string, podName string, container v1.EphemeralContainer) error {
+	err := r.IKubernetes.CreateEphemeralContainer(namespace, podName, container)
+	r.record("CreateEphemeralContainer", marshalArgs(namespace, podName, container), nil, err)
+	return err
+}
+
+func (r *RecordingKubernetes) ProxyPodRequest(namespace string, podName string, port int, path string, body []byte) ([]byte, error) {
+	response, err := r.IKubernetes.ProxyPodRequest(namespace, podName, port, path, body)
+	r.record("ProxyPodRequest", marshalArgs(namespace, podName, port, path, body), response, err)
+	return response, err
+}
+
+func (r *RecordingKubernetes) ExecuteRemoteCommand(namespace string, podName string, container string, command []string) (string, string, error, error) {
+	stdout, stderr, commandErr, err := r.IKubernetes.ExecuteRemoteCommand(namespace, podName, container, command)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, &Interaction{
+		Method:       "ExecuteRemoteCommand",
+		Args:         marshalArgs(namespace, podName, container, command),
+		Error:        newRecordedError(err),
+		Stdout:       stdout,
+		Stderr:       stderr,
+		CommandError: newRecordedError(commandErr),
+	})
+	return stdout, stderr, commandErr, err
+}
+
+// ReplayKubernetes serves the interactions from a Cassette instead of talking to a cluster.  Calls are matched to
+// interactions by method and arguments, and each interaction is served once, in the order recorded -- so
+// concurrent calls, such as probes, replay correctly even if they happen in a different order than when recorded.
+type ReplayKubernetes struct {
+	lock         sync.Mutex
+	interactions map[string][]*Interaction
+}
+
+func NewReplayKubernetes(cassette *Cassette) *ReplayKubernetes {
+	interactions := map[string][]*Interaction{}
+	for _, interaction := range cassette.Interactions {
+		key := interaction.Method + interaction.Args
+		interactions[key] = append(interactions[key], interaction)
+	}
+	return &ReplayKubernetes{interactions: interactions}
+}
+
+func (r *ReplayKubernetes) next(method string, args string) (*Interaction, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := method + args
+	remaining := r.interactions[key]
+	if len(remaining) == 0 {
+		return nil, errors.Errorf("no recorded interaction left for %s with args %s", method, args)
+	}
+	r.interactions[key] = remaining[1:]
+	return remaining[0], nil
+}
+
+// replay finds the next matching interaction and unmarshals its result into result, which should be a pointer
+func (r *ReplayKubernetes) replay(method string, args string, result interface{}) error {
+	interaction, err := r.next(method, args)
+	if err != nil {
+		return err
+	}
+	if result != nil && len(interaction.Result) > 0 {
+		if err := json.Unmarshal(interaction.Result, result); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal recorded result of %s", method)
+		}
+	}
+	return interaction.Error.Err()
+}
+
+// Remaining counts interactions which haven't been served, which is useful for checking that a replay
+// was faithful
+func (r *ReplayKubernetes) Remaining() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	count := 0
+	for _, interactions := range r.interactions {
+		count += len(interactions)
+	}
+	return count
+}
+
+func (r *ReplayKubernetes) CreateNamespace(kubeNamespace *v1.Namespace) (ns *v1.Namespace, err error) {
+	err = r.replay("CreateNamespace", marshalArgs(kubeNamespace), &ns)
+	return ns, err
+}
+
+func (r *ReplayKubernetes) GetNamespace(namespace string) (ns *v1.Namespace, err error) {
+	err = r.replay("GetNamespace", marshalArgs(namespace), &ns)
+	return ns, err
+}
+
+func (r *ReplayKubernetes) GetAllNamespaces() (nsList *v1.NamespaceList, err error) {
+	err = r.replay("GetAllNamespaces", marshalArgs(), &nsList)
+	return nsList, err
+}
+
+func (r *ReplayKubernetes) SetNamespaceLabels(namespace string, labels map[string]string) (ns *v1.Namespace, err error) {
+	err = r.replay("SetNamespaceLabels", marshalArgs(namespace, labels), &ns)
+	return ns, err
+}
+
+func (r *ReplayKubernetes) DeleteNamespace(namespace string) error {
+	return r.replay("DeleteNamespace", marshalArgs(namespace), nil)
+}
+
+func (r *ReplayKubernetes) CreateNetworkPolicy(kubePolicy *networkingv1.NetworkPolicy) (policy *networkingv1.NetworkPolicy, err error) {
+	err = r.replay("CreateNetworkPolicy", marshalArgs(kubePolicy), &policy)
+	return policy, err
+}
+
+func (r *ReplayKubernetes) GetNetworkPoliciesInNamespace(namespace string) (policies []networkingv1.NetworkPolicy, err error) {
+	err = r.replay("GetNetworkPoliciesInNamespace", marshalArgs(namespace), &policies)
+	return policies, err
+}
+
+func (r *ReplayKubernetes) UpdateNetworkPolicy(kubePolicy *networkingv1.NetworkPolicy) (policy *networkingv1.NetworkPolicy, err error) {
+	err = r.replay("UpdateNetworkPolicy", marshalArgs(kubePolicy), &policy)
+	return policy, err
+}
+
+func (r *ReplayKubernetes) DeleteNetworkPolicy(namespace string, name string) error {
+	return r.replay("DeleteNetworkPolicy", marshalArgs(namespace, name), nil)
+}
+
+func (r *ReplayKubernetes) DeleteAllNetworkPoliciesInNamespace(namespace string) error {
+	return r.replay("DeleteAllNetworkPoliciesInNamespace", marshalArgs(namespace), nil)
+}
+
+func (r *ReplayKubernetes) CreateAdminNetworkPolicy(kubePolicy *anp.AdminNetworkPolicy) (policy *anp.AdminNetworkPolicy, err error) {
+	err = r.replay("CreateAdminNetworkPolicy", marshalArgs(kubePolicy), &policy)
+	return policy, err
+}
+
+func (r *ReplayKubernetes) GetAllAdminNetworkPolicies() (policies []anp.AdminNetworkPolicy, err error) {
+	err = r.replay("GetAllAdminNetworkPolicies", marshalArgs(), &policies)
+	return policies, err
+}
+
+func (r *ReplayKubernetes) DeleteAdminNetworkPolicy(name string) error {
+	return r.replay("DeleteAdminNetworkPolicy", marshalArgs(name), nil)
+}
+
+func (r *ReplayKubernetes) CreateBaselineAdminNetworkPolicy(kubePolicy *anp.BaselineAdminNetworkPolicy) (policy *anp.BaselineAdminNetworkPolicy, err error) {
+	err = r.replay("CreateBaselineAdminNetworkPolicy", marshalArgs(kubePolicy), &policy)
+	return policy, err
+}
+
+func (r *ReplayKubernetes) GetBaselineAdminNetworkPolicy() (policy *anp.BaselineAdminNetworkPolicy, err error) {
+	err = r.replay("GetBaselineAdminNetworkPolicy", marshalArgs(), &policy)
+	return policy, err
+}
+
+func (r *ReplayKubernetes) DeleteBaselineAdminNetworkPolicy() error {
+	return r.replay("DeleteBaselineAdminNetworkPolicy", marshalArgs(), nil)
+}
+
+func (r *ReplayKubernetes) CreateService(kubeService *v1.Service) (svc *v1.Service, err error) {
+	err = r.replay("CreateService", marshalArgs(kubeService), &svc)
+	return svc, err
+}
+
+func (r *ReplayKubernetes) GetService(namespace string, name string) (svc *v1.Service, err error) {
+	err = r.replay("GetService", marshalArgs(namespace, name), &svc)
+	return svc, err
+}
+
+func (r *ReplayKubernetes) DeleteService(namespace string, name string) error {
+	return r.replay("DeleteService", marshalArgs(namespace, name), nil)
+}
+
+func (r *ReplayKubernetes) GetServicesInNamespace(namespace string) (svcs []v1.Service, err error) {
+	err = r.replay("GetServicesInNamespace", marshalArgs(namespace), &svcs)
+	return svcs, err
+}
+
+func (r *ReplayKubernetes) CreatePod(kubePod *v1.Pod) (pod *v1.Pod, err error) {
+	err = r.replay("CreatePod", marshalArgs(kubePod), &pod)
+	return pod, err
+}
+
+func (r *ReplayKubernetes) GetPod(namespace string, podName string) (pod *v1.Pod, err error) {
+	err = r.replay("GetPod", marshalArgs(namespace, podName), &pod)
+	return pod, err
+}
+
+func (r *ReplayKubernetes) DeletePod(namespace string, podName string) error {
+	return r.replay("DeletePod", marshalArgs(namespace, podName), nil)
+}
+
+func (r *ReplayKubernetes) SetPodLabels(namespace string, podName string, labels map[string]string) (pod *v1.Pod, err error) {
+	err = r.replay("SetPodLabels", marshalArgs(namespace, podName, labels), &pod)
+	return pod, err
+}
+
+func (r *ReplayKubernetes) GetPodsInNamespace(namespace string) (pods []v1.Pod, err error) {
+	err = r.replay("GetPodsInNamespace", marshalArgs(namespace), &pods)
+	return pods, err
+}
+
+func (r *ReplayKubernetes) GetEventsInNamespace(namespace string) (events []v1.Event, err error) {
+	err = r.replay("GetEventsInNamespace", marshalArgs(namespace), &events)
+	return events, err
+}
+
+func (r *ReplayKubernetes) CreateDaemonSet(daemonSet *appsv1.DaemonSet) (ds *appsv1.DaemonSet, err error) {
+	err = r.replay("CreateDaemonSet", marshalArgs(daemonSet), &ds)
+	return ds, err
+}
+
+func (r *ReplayKubernetes) GetDaemonSet(namespace string, name string) (ds *appsv1.DaemonSet, err error) {
+	err = r.replay("GetDaemonSet", marshalArgs(namespace, name), &ds)
+	return ds, err
+}
+
+func (r *ReplayKubernetes) DeleteDaemonSet(namespace string, name string) error {
+	return r.replay("DeleteDaemonSet", marshalArgs(namespace, name), nil)
+}
+
+func (r *ReplayKubernetes) CreateSecret(secret *v1.Secret) (created *v1.Secret, err error) {
+	err = r.replay("CreateSecret", marshalArgs(secret), &created)
+	return created, err
+}
+
+func (r *ReplayKubernetes) GetSecret(namespace string, name string) (secret *v1.Secret, err error) {
+	err = r.replay("GetSecret", marshalArgs(namespace, name), &secret)
+	return secret, err
+}
+
+func (r *ReplayKubernetes) DeleteSecret(namespace string, name string) error {
+	return r.replay("DeleteSecret", marshalArgs(namespace, name), nil)
+}
+
+func (r *ReplayKubernetes) GetNode(name string) (node *v1.Node, err error) {
+	err = r.replay("GetNode", marshalArgs(name), &node)
+	return node, err
+}
+
+func (r *ReplayKubernetes) CreateRoute(route *openshift.Route) (created *openshift.Route, err error) {
+	err = r.replay("CreateRoute", marshalArgs(route), &created)
+	return created, err
+}
+
+func (r *ReplayKubernetes) GetRoute(namespace string, name string) (route *openshift.Route, err error) {
+	err = r.replay("GetRoute", marshalArgs(namespace, name), &route)
+	return route, err
+}
+
+func (r *ReplayKubernetes) CreateEphemeralContainer(namespace string, podName string, container v1.EphemeralContainer) error {
+	return r.replay("CreateEphemeralContainer", marshalArgs(namespace, podName, container), nil)
+}
+
+func (r *ReplayKubernetes) ProxyPodRequest(namespace string, podName string, port int, path string, body []byte) (response []byte, err error) {
+	err = r.replay("ProxyPodRequest", marshalArgs(namespace, podName, port, path, body), &response)
+	return response, err
+}
+
+func (r *ReplayKubernetes) ExecuteRemoteCommand(namespace string, podName string, container string, command []string) (string, string, error, error) {
+	interaction, err := r.next("ExecuteRemoteCommand", marshalArgs(namespace, podName, container, command))
+	if err != nil {
+		return "", "", nil, err
+	}
+	return interaction.Stdout, interaction.Stderr, interaction.CommandError.Err(), interaction.Error.Err()
+}
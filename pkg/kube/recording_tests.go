@@ -0,0 +1,89 @@
+package kube
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"os"
+	"path/filepath"
+)
+
+func RunRecordingTests() {
+	Describe("Kube recording and replay", func() {
+		namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "x", Labels: map[string]string{"ns": "x"}}}
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "a"},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "cont-80-tcp"}}},
+		}
+
+		It("should replay a recorded run", func() {
+			alreadyExists := kerrors.NewAlreadyExists(schema.GroupResource{Resource: "namespaces"}, "x")
+			flaky := &flakyKubernetes{IKubernetes: NewMockKubernetes(1.0), failures: 1, err: alreadyExists}
+			recorder := NewRecordingKubernetes(flaky)
+
+			_, err := recorder.CreateNamespace(namespace)
+			Expect(kerrors.IsAlreadyExists(errors.Cause(err))).To(BeTrue())
+			created, err := recorder.CreateNamespace(namespace)
+			Expect(err).To(Succeed())
+			_, err = recorder.CreatePod(pod)
+			Expect(err).To(Succeed())
+			stdout, _, commandErr, err := recorder.ExecuteRemoteCommand("x", "a", "cont-80-tcp", []string{"echo", "hi"})
+			Expect(err).To(Succeed())
+			_, err = recorder.GetNamespace("missing")
+			Expect(err).NotTo(Succeed())
+
+			dir, err := ioutil.TempDir("", "cyclonus-recording-")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "recording.json")
+			Expect(recorder.Cassette().Write(path)).To(Succeed())
+			cassette, err := ReadCassette(path)
+			Expect(err).To(Succeed())
+			Expect(cassette.Interactions).To(HaveLen(5))
+
+			replayer := NewReplayKubernetes(cassette)
+
+			// calls don't have to be made in the recorded order, unless they're identical
+			_, err = replayer.GetNamespace("missing")
+			Expect(err).NotTo(Succeed())
+			_, err = replayer.CreateNamespace(namespace)
+			Expect(kerrors.IsAlreadyExists(errors.Cause(err))).To(BeTrue())
+			replayedNamespace, err := replayer.CreateNamespace(namespace)
+			Expect(err).To(Succeed())
+			Expect(replayedNamespace.Labels).To(Equal(created.Labels))
+			_, err = replayer.CreatePod(pod)
+			Expect(err).To(Succeed())
+			replayedStdout, _, replayedCommandErr, err := replayer.ExecuteRemoteCommand("x", "a", "cont-80-tcp", []string{"echo", "hi"})
+			Expect(err).To(Succeed())
+			Expect(replayedStdout).To(Equal(stdout))
+			Expect(replayedCommandErr == nil).To(Equal(commandErr == nil))
+			Expect(replayer.Remaining()).To(Equal(0))
+
+			_, err = replayer.CreateNamespace(namespace)
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("should read recordings from before versioning, but not from newer versions", func() {
+			dir, err := ioutil.TempDir("", "cyclonus-recording-")
+			Expect(err).To(Succeed())
+			defer os.RemoveAll(dir)
+
+			unversioned := filepath.Join(dir, "unversioned.json")
+			Expect(ioutil.WriteFile(unversioned, []byte(`{"Interactions": [{"Method": "GetNamespace", "Args": "[\"x\"]"}]}`), 0644)).To(Succeed())
+			cassette, err := ReadCassette(unversioned)
+			Expect(err).To(Succeed())
+			Expect(cassette.SchemaVersion).To(Equal(CassetteSchemaVersion))
+			Expect(cassette.Interactions).To(Equal([]*Interaction{{Method: "GetNamespace", Args: `["x"]`}}))
+
+			newer := filepath.Join(dir, "newer.json")
+			Expect(ioutil.WriteFile(newer, []byte(`{"SchemaVersion": 1000, "Interactions": []}`), 0644)).To(Succeed())
+			_, err = ReadCassette(newer)
+			Expect(err).To(MatchError(ContainSubstring("schema version 1000 is newer")))
+		})
+	})
+}
@@ -0,0 +1,339 @@
+package kube
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/kube/openshift"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"time"
+)
+
+// RetryPolicy is a number of retries, and how long to wait before the first one; the wait doubles after every retry
+type RetryPolicy struct {
+	Retries int
+	Backoff time.Duration
+}
+
+// BackoffForRetry returns how long to wait before the given retry, counting from 1
+func (r RetryPolicy) BackoffForRetry(retry int) time.Duration {
+	if retry < 1 {
+		return 0
+	}
+	return r.Backoff * time.Duration(1<<uint(retry-1))
+}
+
+// IsThrottlingError is true for errors that say the API server is overloaded, rather than that the request was bad
+func IsThrottlingError(err error) bool {
+	cause := errors.Cause(err)
+	return kerrors.IsTooManyRequests(cause) || kerrors.IsServerTimeout(cause)
+}
+
+// ThrottleRetryingKubernetes retries API calls which fail due to API server throttling.  Exec and pod proxy calls are
+// passed through untouched, since they run probes, and probe failures have their own retry policy.
+type ThrottleRetryingKubernetes struct {
+	IKubernetes
+	Policy RetryPolicy
+	// OnError, if set, is called with the error of every failed attempt, including those which are retried
+	OnError func(err error)
+}
+
+func NewThrottleRetryingKubernetes(kubernetes IKubernetes, policy RetryPolicy) *ThrottleRetryingKubernetes {
+	return &ThrottleRetryingKubernetes{IKubernetes: kubernetes, Policy: policy}
+}
+
+func (t *ThrottleRetryingKubernetes) retry(description string, f func() error) error {
+	if t.OnError != nil {
+		attempt := f
+		f = func() error {
+			err := attempt()
+			if err != nil {
+				t.OnError(err)
+			}
+			return err
+		}
+	}
+	err := f()
+	for retry := 1; retry <= t.Policy.Retries && err != nil && IsThrottlingError(err); retry++ {
+		backoff := t.Policy.BackoffForRetry(retry)
+		// respect the server's Retry-After, if it's longer than our own backoff
+		if delay, ok := kerrors.SuggestsClientDelay(errors.Cause(err)); ok && time.Duration(delay)*time.Second > backoff {
+			backoff = time.Duration(delay) * time.Second
+		}
+		log.Warnf("throttled by API server on %s, retry %d of %d in %s: %+v", description, retry, t.Policy.Retries, backoff, err)
+		time.Sleep(backoff)
+		err = f()
+	}
+	return err
+}
+
+func (t *ThrottleRetryingKubernetes) CreateNamespace(kubeNamespace *v1.Namespace) (ns *v1.Namespace, err error) {
+	err = t.retry("create namespace "+kubeNamespace.Name, func() error {
+		ns, err = t.IKubernetes.CreateNamespace(kubeNamespace)
+		return err
+	})
+	return ns, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetNamespace(namespace string) (ns *v1.Namespace, err error) {
+	err = t.retry("get namespace "+namespace, func() error {
+		ns, err = t.IKubernetes.GetNamespace(namespace)
+		return err
+	})
+	return ns, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetAllNamespaces() (nsList *v1.NamespaceList, err error) {
+	err = t.retry("list namespaces", func() error {
+		nsList, err = t.IKubernetes.GetAllNamespaces()
+		return err
+	})
+	return nsList, err
+}
+
+func (t *ThrottleRetryingKubernetes) SetNamespaceLabels(namespace string, labels map[string]string) (ns *v1.Namespace, err error) {
+	err = t.retry("set labels on namespace "+namespace, func() error {
+		ns, err = t.IKubernetes.SetNamespaceLabels(namespace, labels)
+		return err
+	})
+	return ns, err
+}
+
+func (t *ThrottleRetryingKubernetes) DeleteNamespace(namespace string) error {
+	return t.retry("delete namespace "+namespace, func() error {
+		return t.IKubernetes.DeleteNamespace(namespace)
+	})
+}
+
+func (t *ThrottleRetryingKubernetes) CreateNetworkPolicy(kubePolicy *networkingv1.NetworkPolicy) (policy *networkingv1.NetworkPolicy, err error) {
+	err = t.retry("create network policy "+kubePolicy.Namespace+"/"+kubePolicy.Name, func() error {
+		policy, err = t.IKubernetes.CreateNetworkPolicy(kubePolicy)
+		return err
+	})
+	return policy, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetNetworkPoliciesInNamespace(namespace string) (policies []networkingv1.NetworkPolicy, err error) {
+	err = t.retry("list network policies in "+namespace, func() error {
+		policies, err = t.IKubernetes.GetNetworkPoliciesInNamespace(namespace)
+		return err
+	})
+	return policies, err
+}
+
+func (t *ThrottleRetryingKubernetes) UpdateNetworkPolicy(kubePolicy *networkingv1.NetworkPolicy) (policy *networkingv1.NetworkPolicy, err error) {
+	err = t.retry("update network policy "+kubePolicy.Namespace+"/"+kubePolicy.Name, func() error {
+		policy, err = t.IKubernetes.UpdateNetworkPolicy(kubePolicy)
+		return err
+	})
+	return policy, err
+}
+
+func (t *ThrottleRetryingKubernetes) DeleteNetworkPolicy(namespace string, name string) error {
+	return t.retry("delete network policy "+namespace+"/"+name, func() error {
+		return t.IKubernetes.DeleteNetworkPolicy(namespace, name)
+	})
+}
+
+func (t *ThrottleRetryingKubernetes) DeleteAllNetworkPoliciesInNamespace(namespace string) error {
+	return t.retry("delete network policies in "+namespace, func() error {
+		return t.IKubernetes.DeleteAllNetworkPoliciesInNamespace(namespace)
+	})
+}
+
+func (t *ThrottleRetryingKubernetes) CreateAdminNetworkPolicy(kubePolicy *anp.AdminNetworkPolicy) (policy *anp.AdminNetworkPolicy, err error) {
+	err = t.retry("create admin network policy "+kubePolicy.Name, func() error {
+		policy, err = t.IKubernetes.CreateAdminNetworkPolicy(kubePolicy)
+		return err
+	})
+	return policy, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetAllAdminNetworkPolicies() (policies []anp.AdminNetworkPolicy, err error) {
+	err = t.retry("get admin network policies", func() error {
+		policies, err = t.IKubernetes.GetAllAdminNetworkPolicies()
+		return err
+	})
+	return policies, err
+}
+
+func (t *ThrottleRetryingKubernetes) DeleteAdminNetworkPolicy(name string) error {
+	return t.retry("delete admin network policy "+name, func() error {
+		return t.IKubernetes.DeleteAdminNetworkPolicy(name)
+	})
+}
+
+func (t *ThrottleRetryingKubernetes) CreateBaselineAdminNetworkPolicy(kubePolicy *anp.BaselineAdminNetworkPolicy) (policy *anp.BaselineAdminNetworkPolicy, err error) {
+	err = t.retry("create baseline admin network policy", func() error {
+		policy, err = t.IKubernetes.CreateBaselineAdminNetworkPolicy(kubePolicy)
+		return err
+	})
+	return policy, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetBaselineAdminNetworkPolicy() (policy *anp.BaselineAdminNetworkPolicy, err error) {
+	err = t.retry("get baseline admin network policy", func() error {
+		policy, err = t.IKubernetes.GetBaselineAdminNetworkPolicy()
+		return err
+	})
+	return policy, err
+}
+
+func (t *ThrottleRetryingKubernetes) DeleteBaselineAdminNetworkPolicy() error {
+	return t.retry("delete baseline admin network policy", func() error {
+		return t.IKubernetes.DeleteBaselineAdminNetworkPolicy()
+	})
+}
+
+func (t *ThrottleRetryingKubernetes) CreateService(kubeService *v1.Service) (svc *v1.Service, err error) {
+	err = t.retry("create service "+kubeService.Namespace+"/"+kubeService.Name, func() error {
+		svc, err = t.IKubernetes.CreateService(kubeService)
+		return err
+	})
+	return svc, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetService(namespace string, name string) (svc *v1.Service, err error) {
+	err = t.retry("get service "+namespace+"/"+name, func() error {
+		svc, err = t.IKubernetes.GetService(namespace, name)
+		return err
+	})
+	return svc, err
+}
+
+func (t *ThrottleRetryingKubernetes) DeleteService(namespace string, name string) error {
+	return t.retry("delete service "+namespace+"/"+name, func() error {
+		return t.IKubernetes.DeleteService(namespace, name)
+	})
+}
+
+func (t *ThrottleRetryingKubernetes) GetServicesInNamespace(namespace string) (svcs []v1.Service, err error) {
+	err = t.retry("list services in "+namespace, func() error {
+		svcs, err = t.IKubernetes.GetServicesInNamespace(namespace)
+		return err
+	})
+	return svcs, err
+}
+
+func (t *ThrottleRetryingKubernetes) CreatePod(kubePod *v1.Pod) (pod *v1.Pod, err error) {
+	err = t.retry("create pod "+kubePod.Namespace+"/"+kubePod.Name, func() error {
+		pod, err = t.IKubernetes.CreatePod(kubePod)
+		return err
+	})
+	return pod, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetPod(namespace string, podName string) (pod *v1.Pod, err error) {
+	err = t.retry("get pod "+namespace+"/"+podName, func() error {
+		pod, err = t.IKubernetes.GetPod(namespace, podName)
+		return err
+	})
+	return pod, err
+}
+
+func (t *ThrottleRetryingKubernetes) DeletePod(namespace string, podName string) error {
+	return t.retry("delete pod "+namespace+"/"+podName, func() error {
+		return t.IKubernetes.DeletePod(namespace, podName)
+	})
+}
+
+func (t *ThrottleRetryingKubernetes) SetPodLabels(namespace string, podName string, labels map[string]string) (pod *v1.Pod, err error) {
+	err = t.retry("set labels on pod "+namespace+"/"+podName, func() error {
+		pod, err = t.IKubernetes.SetPodLabels(namespace, podName, labels)
+		return err
+	})
+	return pod, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetPodsInNamespace(namespace string) (pods []v1.Pod, err error) {
+	err = t.retry("list pods in "+namespace, func() error {
+		pods, err = t.IKubernetes.GetPodsInNamespace(namespace)
+		return err
+	})
+	return pods, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetEventsInNamespace(namespace string) (events []v1.Event, err error) {
+	err = t.retry("list events in "+namespace, func() error {
+		events, err = t.IKubernetes.GetEventsInNamespace(namespace)
+		return err
+	})
+	return events, err
+}
+
+func (t *ThrottleRetryingKubernetes) CreateDaemonSet(daemonSet *appsv1.DaemonSet) (ds *appsv1.DaemonSet, err error) {
+	err = t.retry("create daemonset "+daemonSet.Namespace+"/"+daemonSet.Name, func() error {
+		ds, err = t.IKubernetes.CreateDaemonSet(daemonSet)
+		return err
+	})
+	return ds, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetDaemonSet(namespace string, name string) (ds *appsv1.DaemonSet, err error) {
+	err = t.retry("get daemonset "+namespace+"/"+name, func() error {
+		ds, err = t.IKubernetes.GetDaemonSet(namespace, name)
+		return err
+	})
+	return ds, err
+}
+
+func (t *ThrottleRetryingKubernetes) DeleteDaemonSet(namespace string, name string) error {
+	return t.retry("delete daemonset "+namespace+"/"+name, func() error {
+		return t.IKubernetes.DeleteDaemonSet(namespace, name)
+	})
+}
+
+func (t *ThrottleRetryingKubernetes) CreateSecret(secret *v1.Secret) (created *v1.Secret, err error) {
+	err = t.retry("create secret "+secret.Namespace+"/"+secret.Name, func() error {
+		created, err = t.IKubernetes.CreateSecret(secret)
+		return err
+	})
+	return created, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetSecret(namespace string, name string) (secret *v1.Secret, err error) {
+	err = t.retry("get secret "+namespace+"/"+name, func() error {
+		secret, err = t.IKubernetes.GetSecret(namespace, name)
+		return err
+	})
+	return secret, err
+}
+
+func (t *ThrottleRetryingKubernetes) DeleteSecret(namespace string, name string) error {
+	return t.retry("delete secret "+namespace+"/"+name, func() error {
+		return t.IKubernetes.DeleteSecret(namespace, name)
+	})
+}
+
+func (t *ThrottleRetryingKubernetes) GetNode(name string) (node *v1.Node, err error) {
+	err = t.retry("get node "+name, func() error {
+		node, err = t.IKubernetes.GetNode(name)
+		return err
+	})
+	return node, err
+}
+
+func (t *ThrottleRetryingKubernetes) CreateRoute(route *openshift.Route) (created *openshift.Route, err error) {
+	err = t.retry("create route "+route.Namespace+"/"+route.Name, func() error {
+		created, err = t.IKubernetes.CreateRoute(route)
+		return err
+	})
+	return created, err
+}
+
+func (t *ThrottleRetryingKubernetes) GetRoute(namespace string, name string) (route *openshift.Route, err error) {
+	err = t.retry("get route "+namespace+"/"+name, func() error {
+		route, err = t.IKubernetes.GetRoute(namespace, name)
+		return err
+	})
+	return route, err
+}
+
+func (t *ThrottleRetryingKubernetes) CreateEphemeralContainer(namespace string, podName string, container v1.EphemeralContainer) error {
+	return t.retry("create ephemeral container in pod "+namespace+"/"+podName, func() error {
+		return t.IKubernetes.CreateEphemeralContainer(namespace, podName, container)
+	})
+}
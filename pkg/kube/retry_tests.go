@@ -0,0 +1,73 @@
+package kube
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+type flakyKubernetes struct {
+	IKubernetes
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyKubernetes) CreateNamespace(kubeNamespace *v1.Namespace) (*v1.Namespace, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, errors.Wrapf(f.err, "unable to create namespace %s", kubeNamespace.Name)
+	}
+	return f.IKubernetes.CreateNamespace(kubeNamespace)
+}
+
+func RunRetryTests() {
+	Describe("RetryPolicy", func() {
+		It("should double the backoff with each retry", func() {
+			policy := RetryPolicy{Retries: 3, Backoff: time.Second}
+			Expect(policy.BackoffForRetry(0)).To(Equal(time.Duration(0)))
+			Expect(policy.BackoffForRetry(1)).To(Equal(time.Second))
+			Expect(policy.BackoffForRetry(3)).To(Equal(4 * time.Second))
+		})
+	})
+
+	Describe("ThrottleRetryingKubernetes", func() {
+		namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "x"}}
+
+		It("should retry throttled calls", func() {
+			flaky := &flakyKubernetes{IKubernetes: NewMockKubernetes(1.0), failures: 2, err: kerrors.NewTooManyRequests("slow down", 0)}
+			_, err := NewThrottleRetryingKubernetes(flaky, RetryPolicy{Retries: 2}).CreateNamespace(namespace)
+			Expect(err).To(Succeed())
+			Expect(flaky.calls).To(Equal(3))
+		})
+
+		It("should give up after running out of retries", func() {
+			flaky := &flakyKubernetes{IKubernetes: NewMockKubernetes(1.0), failures: 3, err: kerrors.NewTooManyRequests("slow down", 0)}
+			_, err := NewThrottleRetryingKubernetes(flaky, RetryPolicy{Retries: 2}).CreateNamespace(namespace)
+			Expect(err).NotTo(Succeed())
+			Expect(flaky.calls).To(Equal(3))
+		})
+
+		It("should not retry other errors", func() {
+			flaky := &flakyKubernetes{IKubernetes: NewMockKubernetes(1.0), failures: 1, err: errors.Errorf("connection refused")}
+			_, err := NewThrottleRetryingKubernetes(flaky, RetryPolicy{Retries: 2}).CreateNamespace(namespace)
+			Expect(err).NotTo(Succeed())
+			Expect(flaky.calls).To(Equal(1))
+		})
+
+		It("should report every failed attempt", func() {
+			flaky := &flakyKubernetes{IKubernetes: NewMockKubernetes(1.0), failures: 2, err: kerrors.NewTooManyRequests("slow down", 0)}
+			retrying := NewThrottleRetryingKubernetes(flaky, RetryPolicy{Retries: 2})
+			var reported []error
+			retrying.OnError = func(err error) { reported = append(reported, err) }
+			_, err := retrying.CreateNamespace(namespace)
+			Expect(err).To(Succeed())
+			Expect(reported).To(HaveLen(2))
+			Expect(IsThrottlingError(reported[0])).To(BeTrue())
+		})
+	})
+}
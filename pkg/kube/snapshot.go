@@ -0,0 +1,282 @@
+package kube
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/antrea"
+	"github.com/mattfenwick/cyclonus/pkg/kube/calico"
+	"github.com/mattfenwick/cyclonus/pkg/kube/cilium"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"strings"
+)
+
+// Snapshot is an offline copy of the cluster resources that matter for policy analysis, as read from a
+// cluster dump -- for example the output of `kubectl get -o yaml`, or a must-gather directory.
+type Snapshot struct {
+	Namespaces      []v1.Namespace
+	Pods            []v1.Pod
+	NetworkPolicies []networkingv1.NetworkPolicy
+	// WorkloadPods are pods built from the pod templates of workload controllers -- Deployments, StatefulSets,
+	// DaemonSets, ReplicaSets and Jobs -- one per controller, for manifests which don't include the pods themselves
+	WorkloadPods []v1.Pod
+	// CiliumNetworkPolicies and CiliumClusterwideNetworkPolicies are simulated along with NetworkPolicies
+	CiliumNetworkPolicies            []cilium.CiliumNetworkPolicy
+	CiliumClusterwideNetworkPolicies []cilium.CiliumClusterwideNetworkPolicy
+	// CalicoNetworkPolicies, CalicoGlobalNetworkPolicies and CalicoTiers are simulated along with NetworkPolicies
+	CalicoNetworkPolicies       []calico.NetworkPolicy
+	CalicoGlobalNetworkPolicies []calico.GlobalNetworkPolicy
+	CalicoTiers                 []calico.Tier
+	// AntreaClusterNetworkPolicies, AntreaNetworkPolicies and AntreaTiers are simulated along with NetworkPolicies
+	AntreaClusterNetworkPolicies []antrea.ClusterNetworkPolicy
+	AntreaNetworkPolicies        []antrea.NetworkPolicy
+	AntreaTiers                  []antrea.Tier
+}
+
+var workloadKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+	"ReplicaSet":  true,
+	"Job":         true,
+}
+
+// workload is the part of a workload controller that describes its pods
+type workload struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		Template v1.PodTemplateSpec `json:"template"`
+	} `json:"spec"`
+}
+
+type snapshotList struct {
+	Items []interface{} `json:"items"`
+}
+
+// ReadSnapshot walks dir, reading every yaml or json file.  Files may contain multiple documents and
+// `kind: List` (or `NamespaceList`, etc.) wrappers; resources of kinds other than Namespace, Pod, NetworkPolicy,
+// Cilium, Calico and Antrea policies and workload controllers are ignored.
+func ReadSnapshot(dir string) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrapf(err, "unable to walk path %s", path)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			log.Debugf("skipping non-yaml, non-json file %s", path)
+			return nil
+		}
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read file %s", path)
+		}
+		return errors.Wrapf(snapshot.AddDocuments(string(bytes)), "unable to read snapshot file %s", path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("read snapshot from %s: %d namespaces, %d pods, %d network policies", dir, len(snapshot.Namespaces), len(snapshot.Pods), len(snapshot.NetworkPolicies))
+	return snapshot, nil
+}
+
+// AddDocuments parses a (possibly multi-document) yaml or json stream into the snapshot
+func (s *Snapshot) AddDocuments(documents string) error {
+	for _, doc := range utils.SplitYamlDocuments(documents) {
+		if err := s.addObject([]byte(doc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) addObject(bytes []byte) error {
+	typeMeta := &metav1.TypeMeta{}
+	if err := yaml.Unmarshal(bytes, typeMeta); err != nil {
+		return errors.Wrapf(err, "unable to unmarshal kind")
+	}
+
+	if calico.IsCalicoAPIVersion(typeMeta.APIVersion) {
+		return s.addCalicoObject(typeMeta.Kind, bytes)
+	}
+	if antrea.IsAntreaAPIVersion(typeMeta.APIVersion) {
+		return s.addAntreaObject(typeMeta.Kind, bytes)
+	}
+
+	switch typeMeta.Kind {
+	case "Namespace":
+		ns := v1.Namespace{}
+		if err := yaml.Unmarshal(bytes, &ns); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal namespace")
+		}
+		s.Namespaces = append(s.Namespaces, ns)
+	case "Pod":
+		pod := v1.Pod{}
+		if err := yaml.Unmarshal(bytes, &pod); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal pod")
+		}
+		s.Pods = append(s.Pods, pod)
+	case "NetworkPolicy":
+		policy := networkingv1.NetworkPolicy{}
+		if err := yaml.Unmarshal(bytes, &policy); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal network policy")
+		}
+		s.NetworkPolicies = append(s.NetworkPolicies, policy)
+	case cilium.CiliumNetworkPolicyKind:
+		policy := cilium.CiliumNetworkPolicy{}
+		if err := yaml.Unmarshal(bytes, &policy); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal cilium network policy")
+		}
+		s.CiliumNetworkPolicies = append(s.CiliumNetworkPolicies, policy)
+	case cilium.CiliumClusterwideNetworkPolicyKind:
+		policy := cilium.CiliumClusterwideNetworkPolicy{}
+		if err := yaml.Unmarshal(bytes, &policy); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal cilium clusterwide network policy")
+		}
+		s.CiliumClusterwideNetworkPolicies = append(s.CiliumClusterwideNetworkPolicies, policy)
+	default:
+		if workloadKinds[typeMeta.Kind] {
+			w := workload{}
+			if err := yaml.Unmarshal(bytes, &w); err != nil {
+				return errors.Wrapf(err, "unable to unmarshal %s", typeMeta.Kind)
+			}
+			s.WorkloadPods = append(s.WorkloadPods, v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: w.Namespace, Name: w.Name, Labels: w.Spec.Template.Labels},
+				Spec:       w.Spec.Template.Spec,
+			})
+			return nil
+		}
+		if !strings.HasSuffix(typeMeta.Kind, "List") {
+			log.Debugf("ignoring snapshot object of kind '%s'", typeMeta.Kind)
+			return nil
+		}
+		list := &snapshotList{}
+		if err := yaml.Unmarshal(bytes, list); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal %s", typeMeta.Kind)
+		}
+		for _, item := range list.Items {
+			itemBytes, err := yaml.Marshal(item)
+			if err != nil {
+				return errors.Wrapf(err, "unable to marshal %s item", typeMeta.Kind)
+			}
+			if err := s.addObject(itemBytes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addCalicoObject adds a Calico object, whose NetworkPolicy kind shares its name with Kubernetes'
+func (s *Snapshot) addCalicoObject(kind string, bytes []byte) error {
+	switch kind {
+	case calico.NetworkPolicyKind:
+		policy := calico.NetworkPolicy{}
+		if err := yaml.Unmarshal(bytes, &policy); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal calico network policy")
+		}
+		s.CalicoNetworkPolicies = append(s.CalicoNetworkPolicies, policy)
+	case calico.GlobalNetworkPolicyKind:
+		policy := calico.GlobalNetworkPolicy{}
+		if err := yaml.Unmarshal(bytes, &policy); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal calico global network policy")
+		}
+		s.CalicoGlobalNetworkPolicies = append(s.CalicoGlobalNetworkPolicies, policy)
+	case calico.TierKind:
+		tier := calico.Tier{}
+		if err := yaml.Unmarshal(bytes, &tier); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal calico tier")
+		}
+		s.CalicoTiers = append(s.CalicoTiers, tier)
+	default:
+		log.Debugf("ignoring snapshot object of calico kind '%s'", kind)
+	}
+	return nil
+}
+
+// addAntreaObject adds an Antrea object, whose NetworkPolicy kind shares its name with Kubernetes'
+func (s *Snapshot) addAntreaObject(kind string, bytes []byte) error {
+	switch kind {
+	case antrea.ClusterNetworkPolicyKind:
+		policy := antrea.ClusterNetworkPolicy{}
+		if err := yaml.Unmarshal(bytes, &policy); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal antrea cluster network policy")
+		}
+		s.AntreaClusterNetworkPolicies = append(s.AntreaClusterNetworkPolicies, policy)
+	case antrea.NetworkPolicyKind:
+		policy := antrea.NetworkPolicy{}
+		if err := yaml.Unmarshal(bytes, &policy); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal antrea network policy")
+		}
+		s.AntreaNetworkPolicies = append(s.AntreaNetworkPolicies, policy)
+	case antrea.TierKind:
+		tier := antrea.Tier{}
+		if err := yaml.Unmarshal(bytes, &tier); err != nil {
+			return errors.Wrapf(err, "unable to unmarshal antrea tier")
+		}
+		s.AntreaTiers = append(s.AntreaTiers, tier)
+	default:
+		log.Debugf("ignoring snapshot object of antrea kind '%s'", kind)
+	}
+	return nil
+}
+
+// InNamespaces returns a snapshot with only the resources in the given namespaces, and the cluster-scoped ones
+func (s *Snapshot) InNamespaces(namespaces []string) *Snapshot {
+	allowed := map[string]bool{}
+	for _, ns := range namespaces {
+		allowed[ns] = true
+	}
+	filtered := &Snapshot{}
+	for _, ns := range s.Namespaces {
+		if allowed[ns.Name] {
+			filtered.Namespaces = append(filtered.Namespaces, ns)
+		}
+	}
+	for _, pod := range s.Pods {
+		if allowed[pod.Namespace] {
+			filtered.Pods = append(filtered.Pods, pod)
+		}
+	}
+	for _, policy := range s.NetworkPolicies {
+		if allowed[policy.Namespace] {
+			filtered.NetworkPolicies = append(filtered.NetworkPolicies, policy)
+		}
+	}
+	for _, pod := range s.WorkloadPods {
+		if allowed[pod.Namespace] {
+			filtered.WorkloadPods = append(filtered.WorkloadPods, pod)
+		}
+	}
+	for _, policy := range s.CiliumNetworkPolicies {
+		if allowed[policy.Namespace] {
+			filtered.CiliumNetworkPolicies = append(filtered.CiliumNetworkPolicies, policy)
+		}
+	}
+	filtered.CiliumClusterwideNetworkPolicies = s.CiliumClusterwideNetworkPolicies
+	for _, policy := range s.CalicoNetworkPolicies {
+		if allowed[policy.Namespace] {
+			filtered.CalicoNetworkPolicies = append(filtered.CalicoNetworkPolicies, policy)
+		}
+	}
+	filtered.CalicoGlobalNetworkPolicies = s.CalicoGlobalNetworkPolicies
+	filtered.CalicoTiers = s.CalicoTiers
+	filtered.AntreaClusterNetworkPolicies = s.AntreaClusterNetworkPolicies
+	for _, policy := range s.AntreaNetworkPolicies {
+		if allowed[policy.Namespace] {
+			filtered.AntreaNetworkPolicies = append(filtered.AntreaNetworkPolicies, policy)
+		}
+	}
+	filtered.AntreaTiers = s.AntreaTiers
+	return filtered
+}
@@ -0,0 +1,227 @@
+package kube
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/antrea"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunSnapshotTests() {
+	Describe("Snapshot", func() {
+		It("should read namespaces, pods, and policies out of lists and multi-document yaml", func() {
+			snapshot := &Snapshot{}
+			err := snapshot.AddDocuments(`
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Namespace
+  metadata:
+    name: x
+    labels:
+      ns: x
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    namespace: x
+    name: a
+    labels:
+      pod: a
+  status:
+    podIP: 10.0.0.1
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  namespace: y
+  name: deny-all
+spec:
+  podSelector: {}
+  policyTypes: [Ingress]
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  namespace: x
+  name: ignored
+`)
+			Expect(err).To(Succeed())
+
+			Expect(snapshot.Namespaces).To(HaveLen(1))
+			Expect(snapshot.Namespaces[0].Labels).To(Equal(map[string]string{"ns": "x"}))
+			Expect(snapshot.Pods).To(HaveLen(1))
+			Expect(snapshot.Pods[0].Status.PodIP).To(Equal("10.0.0.1"))
+			Expect(snapshot.NetworkPolicies).To(HaveLen(1))
+			Expect(snapshot.NetworkPolicies[0].Name).To(Equal("deny-all"))
+
+			filtered := snapshot.InNamespaces([]string{"x"})
+			Expect(filtered.Namespaces).To(HaveLen(1))
+			Expect(filtered.Pods).To(HaveLen(1))
+			Expect(filtered.NetworkPolicies).To(BeEmpty())
+		})
+
+		It("should build a pod out of each workload controller's pod template", func() {
+			snapshot := &Snapshot{}
+			err := snapshot.AddDocuments(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  namespace: web
+  name: frontend
+spec:
+  template:
+    metadata:
+      labels:
+        app: frontend
+    spec:
+      containers:
+      - name: nginx
+        ports:
+        - containerPort: 80
+`)
+			Expect(err).To(Succeed())
+
+			Expect(snapshot.Pods).To(BeEmpty())
+			Expect(snapshot.WorkloadPods).To(HaveLen(1))
+			Expect(snapshot.WorkloadPods[0].Namespace).To(Equal("web"))
+			Expect(snapshot.WorkloadPods[0].Labels).To(Equal(map[string]string{"app": "frontend"}))
+			Expect(snapshot.WorkloadPods[0].Spec.Containers[0].Ports[0].ContainerPort).To(Equal(int32(80)))
+		})
+
+		It("should read cilium policies, keeping clusterwide ones when filtering by namespace", func() {
+			snapshot := &Snapshot{}
+			err := snapshot.AddDocuments(`
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  namespace: y
+  name: allow-from-x
+spec:
+  endpointSelector: {}
+  ingress:
+  - fromEndpoints:
+    - matchLabels:
+        k8s:io.kubernetes.pod.namespace: x
+---
+apiVersion: cilium.io/v2
+kind: CiliumClusterwideNetworkPolicy
+metadata:
+  name: deny-world
+specs:
+- endpointSelector: {}
+  egressDeny:
+  - toEntities: [world]
+`)
+			Expect(err).To(Succeed())
+
+			Expect(snapshot.CiliumNetworkPolicies).To(HaveLen(1))
+			Expect(snapshot.CiliumNetworkPolicies[0].Spec.Ingress[0].FromEndpoints[0].MatchLabels).To(HaveKeyWithValue("k8s:io.kubernetes.pod.namespace", "x"))
+			Expect(snapshot.CiliumClusterwideNetworkPolicies).To(HaveLen(1))
+			Expect(snapshot.CiliumClusterwideNetworkPolicies[0].Specs).To(HaveLen(1))
+
+			filtered := snapshot.InNamespaces([]string{"x"})
+			Expect(filtered.CiliumNetworkPolicies).To(BeEmpty())
+			Expect(filtered.CiliumClusterwideNetworkPolicies).To(HaveLen(1))
+		})
+
+		It("should tell calico network policies apart from kubernetes ones", func() {
+			snapshot := &Snapshot{}
+			err := snapshot.AddDocuments(`
+apiVersion: projectcalico.org/v3
+kind: NetworkPolicy
+metadata:
+  namespace: y
+  name: allow-web
+spec:
+  order: 100
+  selector: app == 'web'
+  ingress:
+  - action: Allow
+---
+apiVersion: crd.projectcalico.org/v1
+kind: GlobalNetworkPolicy
+metadata:
+  name: default.deny-all
+spec:
+  selector: all()
+  types: [Ingress, Egress]
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  namespace: y
+  name: deny-all
+spec:
+  podSelector: {}
+`)
+			Expect(err).To(Succeed())
+
+			Expect(snapshot.NetworkPolicies).To(HaveLen(1))
+			Expect(snapshot.CalicoNetworkPolicies).To(HaveLen(1))
+			Expect(*snapshot.CalicoNetworkPolicies[0].Spec.Order).To(Equal(float64(100)))
+			Expect(snapshot.CalicoGlobalNetworkPolicies).To(HaveLen(1))
+			Expect(snapshot.CalicoGlobalNetworkPolicies[0].Spec.PolicyTypes()).To(HaveLen(2))
+
+			filtered := snapshot.InNamespaces([]string{"x"})
+			Expect(filtered.CalicoNetworkPolicies).To(BeEmpty())
+			Expect(filtered.CalicoGlobalNetworkPolicies).To(HaveLen(1))
+		})
+
+		It("should tell antrea network policies apart from kubernetes ones", func() {
+			snapshot := &Snapshot{}
+			err := snapshot.AddDocuments(`
+apiVersion: crd.antrea.io/v1beta1
+kind: NetworkPolicy
+metadata:
+  namespace: y
+  name: allow-web
+spec:
+  tier: securityops
+  priority: 5
+  appliedTo:
+  - podSelector: {}
+  ingress:
+  - action: Allow
+---
+apiVersion: crd.antrea.io/v1beta1
+kind: ClusterNetworkPolicy
+metadata:
+  name: baseline-deny
+spec:
+  tier: baseline
+  priority: 1
+  appliedTo:
+  - namespaceSelector: {}
+  egress:
+  - action: Drop
+---
+apiVersion: crd.antrea.io/v1beta1
+kind: Tier
+metadata:
+  name: custom
+spec:
+  priority: 10
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  namespace: y
+  name: deny-all
+spec:
+  podSelector: {}
+`)
+			Expect(err).To(Succeed())
+
+			Expect(snapshot.NetworkPolicies).To(HaveLen(1))
+			Expect(snapshot.AntreaNetworkPolicies).To(HaveLen(1))
+			Expect(snapshot.AntreaNetworkPolicies[0].Spec.Ingress[0].Action).To(Equal(antrea.RuleActionAllow))
+			Expect(snapshot.AntreaClusterNetworkPolicies).To(HaveLen(1))
+			Expect(snapshot.AntreaTiers).To(HaveLen(1))
+			Expect(snapshot.AntreaTiers[0].Spec.Priority).To(Equal(int32(10)))
+
+			filtered := snapshot.InNamespaces([]string{"x"})
+			Expect(filtered.AntreaNetworkPolicies).To(BeEmpty())
+			Expect(filtered.AntreaClusterNetworkPolicies).To(HaveLen(1))
+		})
+	})
+}
@@ -11,5 +11,10 @@ func TestModel(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunIPAddressTests()
 	RunLabelSelectorTests()
+	RunSnapshotTests()
+	RunRetryTests()
+	RunRecordingTests()
+	RunPolicyApplierTests()
+	RunEffectivePolicyTests()
 	RunSpecs(t, "network policy matcher suite")
 }
@@ -2,6 +2,7 @@ package linter
 
 import (
 	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
 	"github.com/mattfenwick/cyclonus/pkg/matcher"
 	"github.com/mattfenwick/cyclonus/pkg/utils"
 	"github.com/olekukonko/tablewriter"
@@ -32,6 +33,14 @@ const (
 	CheckSourceMissingPolicyTypeEgress  Check = "CheckSourceMissingPolicyTypeEgress"
 	// duplicate names
 	CheckSourceDuplicatePolicyName Check = "CheckSourceDuplicatePolicyName"
+	// a rule or peer allows nothing that another of the policy's rules or peers doesn't
+	CheckSourceRedundantRule Check = "CheckSourceRedundantRule"
+	CheckSourceRedundantPeer Check = "CheckSourceRedundantPeer"
+	// an ingress rule's named port isn't a container port of any of the pods the policy selects
+	CheckSourceNamedPortNotExposed Check = "CheckSourceNamedPortNotExposed"
+	// an AdminNetworkPolicy rule or peer can never match, because an earlier rule matches all its traffic
+	CheckSourceShadowedAdminRule Check = "CheckSourceShadowedAdminRule"
+	CheckSourceShadowedAdminPeer Check = "CheckSourceShadowedAdminPeer"
 
 	CheckDNSBlockedOnTCP         Check = "CheckDNSBlockedOnTCP"
 	CheckDNSBlockedOnUDP         Check = "CheckDNSBlockedOnUDP"
@@ -39,28 +48,52 @@ const (
 	CheckTargetAllEgressBlocked  Check = "CheckTargetAllEgressBlocked"
 	CheckTargetAllIngressAllowed Check = "CheckTargetAllIngressAllowed"
 	CheckTargetAllEgressAllowed  Check = "CheckTargetAllEgressAllowed"
-
-	// TODO add check that rule is unnecessary b/c another rule exactly supercedes it
+	// some of a namespace's policies allow traffic, but none isolates all of its pods
+	CheckNamespaceMissingDefaultDenyIngress Check = "CheckNamespaceMissingDefaultDenyIngress"
+	CheckNamespaceMissingDefaultDenyEgress  Check = "CheckNamespaceMissingDefaultDenyEgress"
+	// pods are isolated for one direction, but not the other
+	CheckTargetIsolatedForIngressOnly Check = "CheckTargetIsolatedForIngressOnly"
+	CheckTargetIsolatedForEgressOnly  Check = "CheckTargetIsolatedForEgressOnly"
+	// policies select some of the same pods, but allow different traffic
+	CheckTargetOverlappingIngressPolicies Check = "CheckTargetOverlappingIngressPolicies"
+	CheckTargetOverlappingEgressPolicies  Check = "CheckTargetOverlappingEgressPolicies"
 )
 
 type Warning struct {
-	Check        Check
-	Target       *matcher.Target
-	SourcePolicy *networkingv1.NetworkPolicy
+	Check             Check
+	Target            *matcher.Target
+	SourcePolicy      *networkingv1.NetworkPolicy
+	SourceAdminPolicy *anp.AdminNetworkPolicy
+	// Details explains the warning, for checks about a particular rule, peer, port or namespace
+	Details string
+	// Severity is set by Lint, from its config
+	Severity Severity
+}
+
+// sourceName names a warning's source policy; admin policies are cluster-scoped, so they're named by kind
+func (w *Warning) sourceName() string {
+	if w.SourceAdminPolicy != nil {
+		return "adminnetworkpolicy/" + w.SourceAdminPolicy.Name
+	}
+	return w.SourcePolicy.Namespace + "/" + w.SourcePolicy.Name
 }
 
 func WarningsTable(warnings []*Warning) string {
 	str := &strings.Builder{}
 	table := tablewriter.NewWriter(str)
-	table.SetHeader([]string{"Source/Resolved", "Type", "Target", "Source Policies"})
+	table.SetHeader([]string{"Source/Resolved", "Type", "Target", "Source Policies", "Details"})
 	table.SetRowLine(true)
 	table.SetReflowDuringAutoWrap(false)
 	table.SetAutoWrapText(false)
 
 	for _, warning := range warnings {
-		if warning.SourcePolicy != nil {
-			p := warning.SourcePolicy
-			table.Append([]string{"Source", string(warning.Check), "", p.Namespace + "/" + p.Name})
+		// only severities other than the default are shown
+		check := string(warning.Check)
+		if warning.Severity != "" && warning.Severity != SeverityWarning {
+			check += fmt.Sprintf(" (%s)", warning.Severity)
+		}
+		if warning.SourcePolicy != nil || warning.SourceAdminPolicy != nil {
+			table.Append([]string{"Source", check, "", warning.sourceName(), warning.Details})
 		} else {
 			t := warning.Target
 			var source []string
@@ -68,7 +101,7 @@ func WarningsTable(warnings []*Warning) string {
 				source = append(source, policy.Namespace+"/"+policy.Name)
 			}
 			target := fmt.Sprintf("namespace: %s\n\npod selector:\n%s", t.Namespace, utils.YamlString(t.PodSelector))
-			table.Append([]string{"Resolved", string(warning.Check), target, strings.Join(source, "\n")})
+			table.Append([]string{"Resolved", check, target, strings.Join(source, "\n"), warning.Details})
 		}
 	}
 
@@ -76,21 +109,6 @@ func WarningsTable(warnings []*Warning) string {
 	return str.String()
 }
 
-func Lint(kubePolicies []*networkingv1.NetworkPolicy, skip map[Check]bool) []*Warning {
-	policies := matcher.BuildNetworkPolicies(false, kubePolicies)
-	warnings := append(LintSourcePolicies(kubePolicies), LintResolvedPolicies(policies)...)
-
-	// TODO do some stuff with comparing simplified to non-simplified policies
-
-	var filtered []*Warning
-	for _, warning := range warnings {
-		if _, ok := skip[warning.Check]; !ok {
-			filtered = append(filtered, warning)
-		}
-	}
-	return filtered
-}
-
 func LintSourcePolicies(kubePolicies []*networkingv1.NetworkPolicy) []*Warning {
 	var ws []*Warning
 	names := map[string]map[string]bool{}
@@ -0,0 +1,96 @@
+package linter
+
+import (
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"sigs.k8s.io/yaml"
+)
+
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// sarifLevel is the SARIF result level of a severity
+func (s Severity) sarifLevel() string {
+	switch s {
+	case SeverityInfo:
+		return "note"
+	case SeverityError:
+		return "error"
+	}
+	return "warning"
+}
+
+// Config enables or disables checks, and sets their severities; checks not listed are enabled, with severity
+// warning.
+//
+// Example:
+//
+//	checks:
+//	  CheckSourceMissingPolicyTypes:
+//	    enabled: false
+//	  CheckNamespaceMissingDefaultDenyIngress:
+//	    severity: error
+//	  CheckTargetAllEgressAllowed:
+//	    severity: info
+type Config struct {
+	Checks map[Check]*CheckConfig
+}
+
+type CheckConfig struct {
+	// Enabled defaults to true
+	Enabled *bool `json:",omitempty"`
+	// Severity is info, warning or error; it defaults to warning
+	Severity Severity `json:",omitempty"`
+}
+
+// LoadConfig reads a config from a yaml file; its checks must be those of registered rules, so custom rules must be
+// registered first
+func LoadConfig(path string) (*Config, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read lint config %s", path)
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(bs, config); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal lint config %s", path)
+	}
+	descriptions := CheckDescriptions()
+	for check, checkConfig := range config.Checks {
+		if _, ok := descriptions[check]; !ok {
+			return nil, errors.Errorf("invalid lint config %s: unknown check %s", path, check)
+		}
+		if checkConfig == nil {
+			continue
+		}
+		switch checkConfig.Severity {
+		case "", SeverityInfo, SeverityWarning, SeverityError:
+		default:
+			return nil, errors.Errorf("invalid lint config %s: check %s has invalid severity '%s'; allowed values are %s, %s and %s", path, check, checkConfig.Severity, SeverityInfo, SeverityWarning, SeverityError)
+		}
+	}
+	return config, nil
+}
+
+func (c *Config) checkConfig(check Check) *CheckConfig {
+	if c == nil || c.Checks[check] == nil {
+		return &CheckConfig{}
+	}
+	return c.Checks[check]
+}
+
+func (c *Config) IsEnabled(check Check) bool {
+	enabled := c.checkConfig(check).Enabled
+	return enabled == nil || *enabled
+}
+
+func (c *Config) Severity(check Check) Severity {
+	if severity := c.checkConfig(check).Severity; severity != "" {
+		return severity
+	}
+	return SeverityWarning
+}
@@ -0,0 +1,103 @@
+package linter
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path/filepath"
+)
+
+func RunConfigTests() {
+	writeConfig := func(contents string) string {
+		dir, err := ioutil.TempDir("", "cyclonus-lint-config")
+		Expect(err).To(Succeed())
+		path := filepath.Join(dir, "lint.yaml")
+		Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+		return path
+	}
+	// missing a namespace, and isolating all ingress -- but not egress
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-all"},
+		Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}},
+	}
+	checks := func(warnings []*Warning) map[Check]Severity {
+		severities := map[Check]Severity{}
+		for _, warning := range warnings {
+			severities[warning.Check] = warning.Severity
+		}
+		return severities
+	}
+
+	Describe("Lint config", func() {
+		It("should disable checks and set severities", func() {
+			path := writeConfig(`
+checks:
+  CheckTargetIsolatedForIngressOnly:
+    enabled: false
+  CheckSourceMissingNamespace:
+    severity: error
+`)
+			defer os.RemoveAll(filepath.Dir(path))
+			config, err := LoadConfig(path)
+			Expect(err).To(Succeed())
+
+			severities := checks(Lint(&Input{Policies: []*networkingv1.NetworkPolicy{policy}}, config))
+			Expect(severities).To(HaveKeyWithValue(CheckSourceMissingNamespace, SeverityError))
+			Expect(severities).To(HaveKeyWithValue(CheckTargetAllIngressBlocked, SeverityWarning))
+			Expect(severities).ToNot(HaveKey(CheckTargetIsolatedForIngressOnly))
+		})
+
+		It("should disable the missing policy types check", func() {
+			path := writeConfig(`
+checks:
+  CheckSourceMissingPolicyTypes:
+    enabled: false
+`)
+			defer os.RemoveAll(filepath.Dir(path))
+			config, err := LoadConfig(path)
+			Expect(err).To(Succeed())
+
+			// missing policy types, which are defaulted to Ingress for resolving it
+			untyped := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "deny-all", Namespace: "x"}}
+			Expect(checks(Lint(&Input{Policies: []*networkingv1.NetworkPolicy{untyped}}, nil))).To(HaveKey(CheckSourceMissingPolicyTypes))
+
+			severities := checks(Lint(&Input{Policies: []*networkingv1.NetworkPolicy{untyped}}, config))
+			Expect(severities).ToNot(HaveKey(CheckSourceMissingPolicyTypes))
+			Expect(severities).To(HaveKey(CheckTargetAllIngressBlocked))
+			Expect(untyped.Spec.PolicyTypes).To(BeEmpty())
+		})
+
+		It("should reject unknown checks and severities", func() {
+			for _, contents := range []string{"checks:\n  CheckNoSuchThing: {}\n", "checks:\n  CheckSourceMissingNamespace:\n    severity: fatal\n"} {
+				path := writeConfig(contents)
+				_, err := LoadConfig(path)
+				Expect(err).ToNot(Succeed())
+				os.RemoveAll(filepath.Dir(path))
+			}
+		})
+	})
+
+	Describe("RegisterRule", func() {
+		It("should run custom rules, and reject checks which are already registered", func() {
+			checkTeamLabel := Check("CheckExampleMissingTeamLabel")
+			RegisterRule(NewRule(map[Check]string{checkTeamLabel: "policy has no team label"}, func(input *Input) []*Warning {
+				var ws []*Warning
+				for _, policy := range input.Policies {
+					if _, ok := policy.Labels["team"]; !ok {
+						ws = append(ws, &Warning{Check: checkTeamLabel, SourcePolicy: policy})
+					}
+				}
+				return ws
+			}))
+			Expect(CheckDescriptions()).To(HaveKeyWithValue(checkTeamLabel, "policy has no team label"))
+			Expect(checks(Lint(&Input{Policies: []*networkingv1.NetworkPolicy{policy}}, nil))).To(HaveKey(checkTeamLabel))
+
+			Expect(func() {
+				RegisterRule(NewRule(map[Check]string{CheckSourceMissingNamespace: "duplicate"}, func(input *Input) []*Warning { return nil }))
+			}).To(Panic())
+		})
+	})
+}
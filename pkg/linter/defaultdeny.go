@@ -0,0 +1,99 @@
+package linter
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+)
+
+// hasPolicyType defaults policy's types the way the API server does: Ingress, and Egress if there are egress rules
+func hasPolicyType(policy *networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return policyType == networkingv1.PolicyTypeIngress || len(policy.Spec.Egress) > 0
+	}
+	for _, t := range policy.Spec.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// LintDefaultDeny finds namespaces with policies allowing traffic in a direction, but no policy isolating all of
+// their pods for it: pods which the allowing policies don't select are left open.  The warning's target is all of
+// the namespace's pods, and its source policies are the allowing ones.
+func LintDefaultDeny(kubePolicies []*networkingv1.NetworkPolicy) []*Warning {
+	byNamespace := map[string][]*networkingv1.NetworkPolicy{}
+	var namespaces []string
+	for _, policy := range kubePolicies {
+		if _, ok := byNamespace[policy.Namespace]; !ok {
+			namespaces = append(namespaces, policy.Namespace)
+		}
+		byNamespace[policy.Namespace] = append(byNamespace[policy.Namespace], policy)
+	}
+	sort.Strings(namespaces)
+
+	var ws []*Warning
+	for _, namespace := range namespaces {
+		for _, isIngress := range []bool{true, false} {
+			policyType, check := networkingv1.PolicyTypeEgress, CheckNamespaceMissingDefaultDenyEgress
+			if isIngress {
+				policyType, check = networkingv1.PolicyTypeIngress, CheckNamespaceMissingDefaultDenyIngress
+			}
+			var allowing []*networkingv1.NetworkPolicy
+			isolated := false
+			for _, policy := range byNamespace[namespace] {
+				if !hasPolicyType(policy, policyType) {
+					continue
+				}
+				if kube.IsLabelSelectorEmpty(policy.Spec.PodSelector) {
+					isolated = true
+				}
+				if (isIngress && len(policy.Spec.Ingress) > 0) || (!isIngress && len(policy.Spec.Egress) > 0) {
+					allowing = append(allowing, policy)
+				}
+			}
+			if len(allowing) > 0 && !isolated {
+				ws = append(ws, &Warning{
+					Check:   check,
+					Target:  &matcher.Target{Namespace: namespace, PodSelector: metav1.LabelSelector{}, SourceRules: allowing},
+					Details: fmt.Sprintf("no policy in namespace %s selects all of its pods for %s, so pods the %d allowing policies don't select aren't isolated", namespace, policyType, len(allowing)),
+				})
+			}
+		}
+	}
+	return ws
+}
+
+// LintIsolationDirections finds targets isolated for one direction, but not all of whose pods are selected by a
+// target for the other direction -- i.e. pods whose ingress is restricted, but egress isn't, or vice versa
+func LintIsolationDirections(policies *matcher.Policy) []*Warning {
+	ingresses, egresses := policies.SortedTargets()
+	var ws []*Warning
+	for _, isIngress := range []bool{true, false} {
+		targets, others, check, other := ingresses, egresses, CheckTargetIsolatedForIngressOnly, "egress"
+		if !isIngress {
+			targets, others, check, other = egresses, ingresses, CheckTargetIsolatedForEgressOnly, "ingress"
+		}
+		for _, target := range targets {
+			covered := false
+			for _, otherTarget := range others {
+				if otherTarget.Namespace == target.Namespace && selectorCovers(otherTarget.PodSelector, target.PodSelector) {
+					covered = true
+					break
+				}
+			}
+			if !covered {
+				ws = append(ws, &Warning{
+					Check:   check,
+					Target:  target,
+					Details: fmt.Sprintf("no policy isolating pods for %s selects all of these pods", other),
+				})
+			}
+		}
+	}
+	return ws
+}
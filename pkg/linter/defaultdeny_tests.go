@@ -0,0 +1,65 @@
+package linter
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func RunDefaultDenyTests() {
+	policy := func(name string, selector map[string]string, policyTypes []networkingv1.PolicyType, ingress []networkingv1.NetworkPolicyIngressRule, egress []networkingv1.NetworkPolicyEgressRule) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: name},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: selector},
+				PolicyTypes: policyTypes,
+				Ingress:     ingress,
+				Egress:      egress,
+			},
+		}
+	}
+	ingressOnly := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	egressOnly := []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}
+	allowWeb := policy("allow-web", map[string]string{"app": "web"}, ingressOnly, []networkingv1.NetworkPolicyIngressRule{{}}, nil)
+	allowWebEgress := policy("allow-web-egress", map[string]string{"app": "web"}, egressOnly, nil, []networkingv1.NetworkPolicyEgressRule{{}})
+	denyAll := policy("deny-all", nil, ingressOnly, nil, nil)
+
+	Describe("LintDefaultDeny", func() {
+		It("Should find namespaces with allowing policies but no default deny, per direction", func() {
+			warnings := LintDefaultDeny([]*networkingv1.NetworkPolicy{allowWeb, allowWebEgress, denyAll})
+			Expect(len(warnings)).To(Equal(1))
+			Expect(warnings[0].Check).To(Equal(CheckNamespaceMissingDefaultDenyEgress))
+			Expect(warnings[0].Target.Namespace).To(Equal("x"))
+			Expect(warnings[0].Target.SourceRules).To(Equal([]*networkingv1.NetworkPolicy{allowWebEgress}))
+		})
+
+		It("Should default policy types the way the API server does", func() {
+			implicit := policy("implicit", map[string]string{"app": "web"}, nil, []networkingv1.NetworkPolicyIngressRule{{}}, nil)
+			warnings := LintDefaultDeny([]*networkingv1.NetworkPolicy{implicit})
+			Expect(len(warnings)).To(Equal(1))
+			Expect(warnings[0].Check).To(Equal(CheckNamespaceMissingDefaultDenyIngress))
+
+			Expect(LintDefaultDeny([]*networkingv1.NetworkPolicy{implicit, policy("deny-all", nil, nil, nil, nil)})).To(BeEmpty())
+		})
+	})
+
+	Describe("LintIsolationDirections", func() {
+		It("Should find pods isolated for one direction but not the other", func() {
+			warnings := LintIsolationDirections(matcher.BuildNetworkPolicies(false, []*networkingv1.NetworkPolicy{allowWeb, denyAll}))
+			Expect(len(warnings)).To(Equal(2))
+			for _, warning := range warnings {
+				Expect(warning.Check).To(Equal(CheckTargetIsolatedForIngressOnly))
+			}
+		})
+
+		It("Should not flag pods covered by a broader selector for the other direction", func() {
+			denyAllEgress := policy("deny-all-egress", nil, egressOnly, nil, nil)
+			warnings := LintIsolationDirections(matcher.BuildNetworkPolicies(false, []*networkingv1.NetworkPolicy{allowWeb, denyAllEgress}))
+			Expect(len(warnings)).To(Equal(1))
+			Expect(warnings[0].Check).To(Equal(CheckTargetIsolatedForEgressOnly))
+			Expect(warnings[0].Target.SourceRules).To(Equal([]*networkingv1.NetworkPolicy{denyAllEgress}))
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package linter
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+	"strings"
+)
+
+// rulesCover: every peer of others' rules is covered by a peer of one of rules, on all of its rule's ports
+func rulesCover(rules []*lintRule, others []*lintRule) bool {
+	for _, other := range others {
+		for _, otherPeer := range other.peers {
+			covered := false
+			for _, rule := range rules {
+				if !portsCover(rule.ports, other.ports) {
+					continue
+				}
+				for _, peer := range rule.peers {
+					if peerCovers(peer, otherPeer) {
+						covered = true
+						break
+					}
+				}
+				if covered {
+					break
+				}
+			}
+			if !covered {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// intersectSelectors is a selector matching exactly the labels both selector and other match; ok is false if
+// their match labels conflict, so that nothing matches both
+func intersectSelectors(selector metav1.LabelSelector, other metav1.LabelSelector) (metav1.LabelSelector, bool) {
+	intersection := metav1.LabelSelector{}
+	for _, s := range []metav1.LabelSelector{selector, other} {
+		for key, value := range s.MatchLabels {
+			if existing, ok := intersection.MatchLabels[key]; ok && existing != value {
+				return metav1.LabelSelector{}, false
+			}
+			if intersection.MatchLabels == nil {
+				intersection.MatchLabels = map[string]string{}
+			}
+			intersection.MatchLabels[key] = value
+		}
+		intersection.MatchExpressions = append(intersection.MatchExpressions, s.MatchExpressions...)
+	}
+	for _, requirement := range intersection.MatchExpressions {
+		value, ok := intersection.MatchLabels[requirement.Key]
+		if ok && !kube.IsMatchExpressionMatchForLabels(map[string]string{requirement.Key: value}, requirement) {
+			return metav1.LabelSelector{}, false
+		}
+	}
+	return intersection, true
+}
+
+func rulesString(policy *networkingv1.NetworkPolicy, rules []*lintRule) string {
+	var descriptions []string
+	for _, rule := range rules {
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s; %s)", rule.description, peersString(rule.peers), portsString(rule.ports)))
+	}
+	return fmt.Sprintf("%s/%s allows %s", policy.Namespace, policy.Name, strings.Join(descriptions, ", "))
+}
+
+// LintOverlappingPolicies finds pairs of policies in a namespace which select some of the same pods for a
+// direction, but allow materially different traffic -- i.e. neither's rules cover the other's.  Since policies
+// are a union, for the pods they both select, each widens what the other allows, which is often unintended.
+// Policies allowing nothing -- default denies -- are meant to be combined with others, so they're skipped.  The
+// warning's target is the intersection of the policies' pod selectors.  If pods are given, only pairs which both
+// select some pods are reported, and the pods are listed; otherwise, pairs whose selectors can't be shown to be
+// disjoint are.
+func LintOverlappingPolicies(kubePolicies []*networkingv1.NetworkPolicy, pods []v1.Pod) []*Warning {
+	var ws []*Warning
+	for _, isIngress := range []bool{true, false} {
+		policyType, check, direction := networkingv1.PolicyTypeEgress, CheckTargetOverlappingEgressPolicies, "egress"
+		if isIngress {
+			policyType, check, direction = networkingv1.PolicyTypeIngress, CheckTargetOverlappingIngressPolicies, "ingress"
+		}
+		for i, policy := range kubePolicies {
+			if !hasPolicyType(policy, policyType) {
+				continue
+			}
+			for _, other := range kubePolicies[i+1:] {
+				if other.Namespace != policy.Namespace || !hasPolicyType(other, policyType) {
+					continue
+				}
+				rules, otherRules := networkPolicyRules(policy, isIngress), networkPolicyRules(other, isIngress)
+				if len(rules) == 0 || len(otherRules) == 0 || (rulesCover(rules, otherRules) && rulesCover(otherRules, rules)) {
+					continue
+				}
+				selector, ok := intersectSelectors(policy.Spec.PodSelector, other.Spec.PodSelector)
+				if !ok {
+					continue
+				}
+				affected := "pods matching both selectors"
+				if len(pods) > 0 {
+					var names []string
+					for _, pod := range pods {
+						if pod.Namespace == policy.Namespace && kube.IsLabelsMatchLabelSelector(pod.Labels, selector) {
+							names = append(names, pod.Namespace+"/"+pod.Name)
+						}
+					}
+					if len(names) == 0 {
+						continue
+					}
+					sort.Strings(names)
+					affected = "pods " + strings.Join(names, ", ")
+				}
+				ws = append(ws, &Warning{
+					Check:   check,
+					Target:  &matcher.Target{Namespace: policy.Namespace, PodSelector: selector, SourceRules: []*networkingv1.NetworkPolicy{policy, other}},
+					Details: fmt.Sprintf("%s are selected by both policies, so their %s is allowed by the union of: %s; and %s", affected, direction, rulesString(policy, rules), rulesString(other, otherRules)),
+				})
+			}
+		}
+	}
+	return ws
+}
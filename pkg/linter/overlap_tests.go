@@ -0,0 +1,68 @@
+package linter
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func RunOverlapTests() {
+	tcp := v1.ProtocolTCP
+	port80 := intstr.FromInt(80)
+	port443 := intstr.FromInt(443)
+	port8443 := intstr.FromInt(8443)
+	policy := func(name string, selector map[string]string, port *intstr.IntOrString) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: name},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: selector},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: port}},
+				}},
+			},
+		}
+	}
+	web := policy("web", map[string]string{"app": "web"}, &port80)
+	frontend := policy("frontend", map[string]string{"tier": "frontend"}, &port443)
+	api := policy("api", map[string]string{"app": "api"}, &port8443)
+	pod := func(name string, labels map[string]string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: name, Labels: labels}}
+	}
+
+	Describe("LintOverlappingPolicies", func() {
+		It("Should find policies whose selectors may overlap, with the intersection of their selectors", func() {
+			warnings := LintOverlappingPolicies([]*networkingv1.NetworkPolicy{web, frontend, api}, nil)
+			Expect(len(warnings)).To(Equal(2))
+			Expect(warnings[0].Check).To(Equal(CheckTargetOverlappingIngressPolicies))
+			Expect(warnings[0].Target.PodSelector).To(Equal(metav1.LabelSelector{MatchLabels: map[string]string{"app": "web", "tier": "frontend"}}))
+			Expect(warnings[0].Target.SourceRules).To(Equal([]*networkingv1.NetworkPolicy{web, frontend}))
+			Expect(warnings[0].Details).To(Equal("pods matching both selectors are selected by both policies, so their ingress is allowed by the union of: " +
+				"x/web allows ingress rule 1 (pod selector all; ports TCP/80); and x/frontend allows ingress rule 1 (pod selector all; ports TCP/443)"))
+			Expect(warnings[1].Target.SourceRules).To(Equal([]*networkingv1.NetworkPolicy{frontend, api}))
+		})
+
+		It("Should only report pairs selecting some of the same pods, when given pods", func() {
+			pods := []v1.Pod{
+				pod("web-1", map[string]string{"app": "web", "tier": "frontend"}),
+				pod("api-1", map[string]string{"app": "api", "tier": "backend"}),
+			}
+			warnings := LintOverlappingPolicies([]*networkingv1.NetworkPolicy{web, frontend, api}, pods)
+			Expect(len(warnings)).To(Equal(1))
+			Expect(warnings[0].Details).To(HavePrefix("pods x/web-1 are selected by both policies"))
+		})
+
+		It("Should not flag policies allowing the same traffic, or default denies", func() {
+			same := policy("same", map[string]string{"tier": "frontend"}, &port80)
+			deny := &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "deny-all"},
+				Spec:       networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}},
+			}
+			Expect(LintOverlappingPolicies([]*networkingv1.NetworkPolicy{web, same, deny}, nil)).To(BeEmpty())
+		})
+	})
+}
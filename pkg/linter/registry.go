@@ -0,0 +1,180 @@
+package linter
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// Input is what rules check
+type Input struct {
+	Policies      []*networkingv1.NetworkPolicy
+	AdminPolicies []*anp.AdminNetworkPolicy
+	// Pods may be empty, if none were read from a snapshot or kube
+	Pods []v1.Pod
+	// Resolved is Policies, unsimplified; Lint builds it if it's nil
+	Resolved *matcher.Policy
+}
+
+// Rule finds problems in policies, reporting each as a warning of one of its checks.  Programs embedding cyclonus
+// can add their own rules -- i.e. for an organization's conventions -- with RegisterRule; their checks are then
+// configured, and reported in tables and SARIF, like the built-in ones.
+type Rule interface {
+	// Checks describes each check the rule reports, in a sentence fragment such as "policy has no namespace"
+	Checks() map[Check]string
+	Lint(input *Input) []*Warning
+}
+
+type funcRule struct {
+	checks map[Check]string
+	lint   func(input *Input) []*Warning
+}
+
+// NewRule makes a Rule of a function reporting warnings of checks
+func NewRule(checks map[Check]string, lint func(input *Input) []*Warning) Rule {
+	return &funcRule{checks: checks, lint: lint}
+}
+
+func (f *funcRule) Checks() map[Check]string {
+	return f.checks
+}
+
+func (f *funcRule) Lint(input *Input) []*Warning {
+	return f.lint(input)
+}
+
+var rules []Rule
+
+// RegisterRule adds rule to those Lint runs.  It panics if another rule already reports one of its checks, so it's
+// meant to be called from init functions.
+func RegisterRule(rule Rule) {
+	descriptions := CheckDescriptions()
+	for check := range rule.Checks() {
+		if _, ok := descriptions[check]; ok {
+			panic(errors.Errorf("unable to register rule: check %s is already registered", check))
+		}
+	}
+	rules = append(rules, rule)
+}
+
+// Rules are the registered rules, built-in ones first
+func Rules() []Rule {
+	return append([]Rule{}, rules...)
+}
+
+// CheckDescriptions describes every check of the registered rules
+func CheckDescriptions() map[Check]string {
+	descriptions := map[Check]string{}
+	for _, rule := range rules {
+		for check, description := range rule.Checks() {
+			descriptions[check] = description
+		}
+	}
+	return descriptions
+}
+
+// Lint runs the registered rules on input, dropping the warnings of checks config disables, and setting the
+// severity of the rest; config may be nil, for the defaults
+func Lint(input *Input, config *Config) []*Warning {
+	if input.Resolved == nil {
+		input.Resolved = matcher.BuildNetworkPolicies(false, withDefaultPolicyTypes(input.Policies))
+	}
+	var warnings []*Warning
+	for _, rule := range rules {
+		enabled := false
+		for check := range rule.Checks() {
+			if config.IsEnabled(check) {
+				enabled = true
+				break
+			}
+		}
+		if !enabled {
+			continue
+		}
+		for _, warning := range rule.Lint(input) {
+			if config.IsEnabled(warning.Check) {
+				warning.Severity = config.Severity(warning.Check)
+				warnings = append(warnings, warning)
+			}
+		}
+	}
+	return warnings
+}
+
+// withDefaultPolicyTypes fills in policyTypes, for policies missing them, the same way the apiserver does -- Ingress
+// always, and Egress if there are egress rules -- since policies can't be resolved without them.  Policies which are
+// missing them are copied, rather than changed, so that CheckSourceMissingPolicyTypes still reports them.
+func withDefaultPolicyTypes(policies []*networkingv1.NetworkPolicy) []*networkingv1.NetworkPolicy {
+	var defaulted []*networkingv1.NetworkPolicy
+	for _, policy := range policies {
+		if len(policy.Spec.PolicyTypes) == 0 {
+			policy = policy.DeepCopy()
+			policy.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+			if len(policy.Spec.Egress) > 0 {
+				policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+			}
+		}
+		defaulted = append(defaulted, policy)
+	}
+	return defaulted
+}
+
+func init() {
+	RegisterRule(NewRule(map[Check]string{
+		CheckSourceMissingNamespace:         "policy has no namespace, so it will be created in the default namespace",
+		CheckSourcePortMissingProtocol:      "port has no protocol, so it defaults to TCP",
+		CheckSourceMissingPolicyTypes:       "policy has no policy types; it's better to list them explicitly",
+		CheckSourceMissingPolicyTypeIngress: "policy has ingress rules, but not the Ingress policy type, so they're ignored",
+		CheckSourceMissingPolicyTypeEgress:  "policy has egress rules, but not the Egress policy type, so they're ignored",
+		CheckSourceDuplicatePolicyName:      "another policy in the same namespace has the same name",
+	}, func(input *Input) []*Warning {
+		return LintSourcePolicies(input.Policies)
+	}))
+	RegisterRule(NewRule(map[Check]string{
+		CheckDNSBlockedOnTCP:         "egress to DNS on TCP port 53 is blocked",
+		CheckDNSBlockedOnUDP:         "egress to DNS on UDP port 53 is blocked",
+		CheckTargetAllIngressBlocked: "all ingress to the selected pods is blocked",
+		CheckTargetAllEgressBlocked:  "all egress from the selected pods is blocked",
+		CheckTargetAllIngressAllowed: "all ingress to the selected pods is allowed",
+		CheckTargetAllEgressAllowed:  "all egress from the selected pods is allowed",
+	}, func(input *Input) []*Warning {
+		return LintResolvedPolicies(input.Resolved)
+	}))
+	RegisterRule(NewRule(map[Check]string{
+		CheckSourceRedundantRule: "rule allows nothing that the policy's other rules don't",
+		CheckSourceRedundantPeer: "peer allows nothing that the policy's other peers don't",
+	}, func(input *Input) []*Warning {
+		return LintRedundantRules(input.Policies)
+	}))
+	RegisterRule(NewRule(map[Check]string{
+		CheckSourceNamedPortNotExposed: "named port isn't a container port of any pod the policy selects, so it matches no traffic",
+	}, func(input *Input) []*Warning {
+		return LintNamedPortsNotExposed(input.Policies, input.Pods)
+	}))
+	RegisterRule(NewRule(map[Check]string{
+		CheckSourceShadowedAdminRule: "admin network policy rule can never match, because an earlier rule matches all its traffic",
+		CheckSourceShadowedAdminPeer: "admin network policy peer can never match, because an earlier rule matches all its traffic",
+	}, func(input *Input) []*Warning {
+		return LintAdminPolicies(input.AdminPolicies)
+	}))
+	RegisterRule(NewRule(map[Check]string{
+		CheckNamespaceMissingDefaultDenyIngress: "namespace has policies allowing ingress, but none isolating all of its pods for ingress",
+		CheckNamespaceMissingDefaultDenyEgress:  "namespace has policies allowing egress, but none isolating all of its pods for egress",
+	}, func(input *Input) []*Warning {
+		return LintDefaultDeny(input.Policies)
+	}))
+	RegisterRule(NewRule(map[Check]string{
+		CheckTargetIsolatedForIngressOnly: "pods are isolated for ingress, but not all of them for egress",
+		CheckTargetIsolatedForEgressOnly:  "pods are isolated for egress, but not all of them for ingress",
+	}, func(input *Input) []*Warning {
+		return LintIsolationDirections(input.Resolved)
+	}))
+	RegisterRule(NewRule(map[Check]string{
+		CheckTargetOverlappingIngressPolicies: "policies select some of the same pods, but allow different ingress, which they combine",
+		CheckTargetOverlappingEgressPolicies:  "policies select some of the same pods, but allow different egress, which they combine",
+	}, func(input *Input) []*Warning {
+		return LintOverlappingPolicies(input.Policies, input.Pods)
+	}))
+}
@@ -0,0 +1,606 @@
+package linter
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"net"
+	"strings"
+)
+
+// lintPeer is a NetworkPolicy or AdminNetworkPolicy peer, in a form in which they can be compared
+type lintPeer struct {
+	description string
+	// all is every peer, in or outside the cluster: a NetworkPolicy rule without peers
+	all     bool
+	ipBlock *networkingv1.IPBlock
+	// namespaces: nil is the policy's own namespace
+	namespaces *metav1.LabelSelector
+	// pods: nil is all pods
+	pods *metav1.LabelSelector
+	// networks: the CIDRs of an AdminNetworkPolicy networks peer
+	networks []*networkingv1.IPBlock
+	// nodes: the selector of an AdminNetworkPolicy nodes peer
+	nodes *metav1.LabelSelector
+	// invalid is a peer which matches nothing, so neither covers nor is covered by anything
+	invalid bool
+}
+
+// lintPort is a named port -- on any protocol, if protocol is empty -- or a range of numbered ports
+type lintPort struct {
+	protocol v1.Protocol
+	name     string
+	from     int
+	to       int
+}
+
+type lintRule struct {
+	description string
+	peers       []*lintPeer
+	// ports: nil is all ports
+	ports []*lintPort
+}
+
+// shadowedPeer is a peer whose traffic is all matched by another rule's -- or the same rule's -- peer
+type shadowedPeer struct {
+	rule   int
+	peer   int
+	byRule int
+	byPeer int
+}
+
+// findShadowedPeers finds, for each rule's peers, another peer which matches all of its traffic, on all of its
+// rule's ports.  If rules are ordered -- the first matching rule wins -- peers can only be shadowed by the same or
+// earlier rules; if not, identical peers are only shadowed by earlier ones, so that one of them is left.  This is
+// conservative: a peer matched piecewise by several others isn't found.
+func findShadowedPeers(rules []*lintRule, ordered bool) []*shadowedPeer {
+	var shadowed []*shadowedPeer
+	for i, rule := range rules {
+		for j, peer := range rule.peers {
+			for k, other := range rules {
+				if (ordered && k > i) || !portsCover(other.ports, rule.ports) {
+					continue
+				}
+				found := false
+				for l, otherPeer := range other.peers {
+					if k == i && l == j {
+						continue
+					}
+					if !peerCovers(otherPeer, peer) {
+						continue
+					}
+					// identical peers on identical ports: only the later one is shadowed
+					isEarlier := k < i || (k == i && l < j)
+					if !isEarlier && peerCovers(peer, otherPeer) && portsCover(rule.ports, other.ports) {
+						continue
+					}
+					shadowed = append(shadowed, &shadowedPeer{rule: i, peer: j, byRule: k, byPeer: l})
+					found = true
+					break
+				}
+				if found {
+					break
+				}
+			}
+		}
+	}
+	return shadowed
+}
+
+// shadowWarnings reports a rule all of whose peers are shadowed with ruleCheck, and otherwise each shadowed peer
+// with peerCheck, explaining what shadows them
+func shadowWarnings(rules []*lintRule, ordered bool, ruleCheck Check, peerCheck Check, verb string, newWarning func(Check, string) *Warning) []*Warning {
+	byRule := map[int][]*shadowedPeer{}
+	for _, shadowed := range findShadowedPeers(rules, ordered) {
+		byRule[shadowed.rule] = append(byRule[shadowed.rule], shadowed)
+	}
+	var ws []*Warning
+	for i, rule := range rules {
+		shadows := byRule[i]
+		if len(shadows) == 0 {
+			continue
+		}
+		if len(shadows) == len(rule.peers) {
+			var by []string
+			seen := map[int]bool{}
+			for _, shadowed := range shadows {
+				if !seen[shadowed.byRule] {
+					seen[shadowed.byRule] = true
+					by = append(by, rules[shadowed.byRule].description)
+				}
+			}
+			ws = append(ws, newWarning(ruleCheck, fmt.Sprintf("%s (%s; %s): all its traffic is %s by %s", rule.description, peersString(rule.peers), portsString(rule.ports), verb, strings.Join(by, ", "))))
+			continue
+		}
+		for _, shadowed := range shadows {
+			peer, by := rule.peers[shadowed.peer], rules[shadowed.byRule].peers[shadowed.byPeer]
+			ws = append(ws, newWarning(peerCheck, fmt.Sprintf("%s peer %d (%s): all its traffic is %s by %s peer %d (%s; %s)",
+				rule.description, shadowed.peer+1, peer.description, verb, rules[shadowed.byRule].description, shadowed.byPeer+1, by.description, portsString(rules[shadowed.byRule].ports))))
+		}
+	}
+	return ws
+}
+
+func peersString(peers []*lintPeer) string {
+	var descriptions []string
+	for _, peer := range peers {
+		descriptions = append(descriptions, peer.description)
+	}
+	return strings.Join(descriptions, " | ")
+}
+
+func portsString(ports []*lintPort) string {
+	if ports == nil {
+		return "all ports"
+	}
+	var descriptions []string
+	for _, port := range ports {
+		protocol := string(port.protocol)
+		if protocol == "" {
+			protocol = "any protocol"
+		}
+		if port.name != "" {
+			descriptions = append(descriptions, fmt.Sprintf("%s/%s", protocol, port.name))
+		} else if port.from == 1 && port.to == 65535 {
+			descriptions = append(descriptions, fmt.Sprintf("%s/all ports", protocol))
+		} else if port.from == port.to {
+			descriptions = append(descriptions, fmt.Sprintf("%s/%d", protocol, port.from))
+		} else {
+			descriptions = append(descriptions, fmt.Sprintf("%s/%d-%d", protocol, port.from, port.to))
+		}
+	}
+	return "ports " + strings.Join(descriptions, ", ")
+}
+
+func portsCover(ports []*lintPort, others []*lintPort) bool {
+	if ports == nil {
+		return true
+	} else if others == nil {
+		return false
+	}
+	for _, other := range others {
+		covered := false
+		for _, port := range ports {
+			if portCovers(port, other) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// portCovers: a named port could resolve to any number, so it's only covered by the same name
+func portCovers(port *lintPort, other *lintPort) bool {
+	if port.protocol != "" && port.protocol != other.protocol {
+		return false
+	}
+	if port.name != "" || other.name != "" {
+		return port.name == other.name
+	}
+	return port.from <= other.from && other.to <= port.to
+}
+
+func peerCovers(peer *lintPeer, other *lintPeer) bool {
+	if peer.invalid || other.invalid {
+		return false
+	} else if peer.all {
+		return true
+	} else if other.all {
+		return false
+	}
+	if peer.nodes != nil || other.nodes != nil {
+		return peer.nodes != nil && other.nodes != nil && selectorCovers(*peer.nodes, *other.nodes)
+	}
+	if peer.networks != nil || other.networks != nil {
+		return peer.networks != nil && other.networks != nil && networksCover(peer.networks, other.networks)
+	}
+	if peer.ipBlock != nil || other.ipBlock != nil {
+		return peer.ipBlock != nil && other.ipBlock != nil && ipBlockCovers(peer.ipBlock, other.ipBlock)
+	}
+	if peer.namespaces == nil {
+		if other.namespaces != nil {
+			return false
+		}
+	} else if other.namespaces == nil {
+		if !kube.IsLabelSelectorEmpty(*peer.namespaces) {
+			return false
+		}
+	} else if !selectorCovers(*peer.namespaces, *other.namespaces) {
+		return false
+	}
+	if peer.pods == nil {
+		return true
+	} else if other.pods == nil {
+		return kube.IsLabelSelectorEmpty(*peer.pods)
+	}
+	return selectorCovers(*peer.pods, *other.pods)
+}
+
+// networksCover: each of other's CIDRs is within one of networks'
+func networksCover(networks []*networkingv1.IPBlock, other []*networkingv1.IPBlock) bool {
+	for _, otherBlock := range other {
+		covered := false
+		for _, block := range networks {
+			if ipBlockCovers(block, otherBlock) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// ipBlockCovers: block's range contains other's, and each of block's exceptions is either outside other's range or
+// within one of other's exceptions
+func ipBlockCovers(block *networkingv1.IPBlock, other *networkingv1.IPBlock) bool {
+	_, blockNet, err := net.ParseCIDR(block.CIDR)
+	if err != nil {
+		return false
+	}
+	_, otherNet, err := net.ParseCIDR(other.CIDR)
+	if err != nil || !cidrContains(blockNet, otherNet) {
+		return false
+	}
+	for _, except := range block.Except {
+		_, exceptNet, err := net.ParseCIDR(except)
+		if err != nil {
+			return false
+		}
+		if !exceptNet.Contains(otherNet.IP) && !otherNet.Contains(exceptNet.IP) {
+			continue
+		}
+		excepted := false
+		for _, otherExcept := range other.Except {
+			_, otherExceptNet, err := net.ParseCIDR(otherExcept)
+			if err == nil && cidrContains(otherExceptNet, exceptNet) {
+				excepted = true
+				break
+			}
+		}
+		if !excepted {
+			return false
+		}
+	}
+	return true
+}
+
+func cidrContains(outer *net.IPNet, inner *net.IPNet) bool {
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(inner.IP)
+}
+
+// selectorCovers: every set of labels other selects, selector does too -- i.e. each of selector's requirements is
+// implied by other's
+func selectorCovers(selector metav1.LabelSelector, other metav1.LabelSelector) bool {
+	for key, value := range selector.MatchLabels {
+		if !requirementImplied(metav1.LabelSelectorRequirement{Key: key, Operator: metav1.LabelSelectorOpIn, Values: []string{value}}, other) {
+			return false
+		}
+	}
+	for _, requirement := range selector.MatchExpressions {
+		if !requirementImplied(requirement, other) {
+			return false
+		}
+	}
+	return true
+}
+
+func requirementImplied(requirement metav1.LabelSelectorRequirement, selector metav1.LabelSelector) bool {
+	values := map[string]bool{}
+	for _, value := range requirement.Values {
+		values[value] = true
+	}
+	if value, ok := selector.MatchLabels[requirement.Key]; ok {
+		switch requirement.Operator {
+		case metav1.LabelSelectorOpIn:
+			return values[value]
+		case metav1.LabelSelectorOpNotIn:
+			return !values[value]
+		case metav1.LabelSelectorOpExists:
+			return true
+		}
+		return false
+	}
+	for _, expression := range selector.MatchExpressions {
+		if expression.Key != requirement.Key {
+			continue
+		}
+		switch {
+		case requirement.Operator == metav1.LabelSelectorOpIn && expression.Operator == metav1.LabelSelectorOpIn:
+			if allIn(expression.Values, values) {
+				return true
+			}
+		case requirement.Operator == metav1.LabelSelectorOpNotIn && expression.Operator == metav1.LabelSelectorOpIn:
+			if noneIn(expression.Values, values) {
+				return true
+			}
+		case requirement.Operator == metav1.LabelSelectorOpNotIn && expression.Operator == metav1.LabelSelectorOpNotIn:
+			expressionValues := map[string]bool{}
+			for _, value := range expression.Values {
+				expressionValues[value] = true
+			}
+			if allIn(requirement.Values, expressionValues) {
+				return true
+			}
+		case requirement.Operator == metav1.LabelSelectorOpNotIn && expression.Operator == metav1.LabelSelectorOpDoesNotExist:
+			return true
+		case requirement.Operator == metav1.LabelSelectorOpExists && (expression.Operator == metav1.LabelSelectorOpIn || expression.Operator == metav1.LabelSelectorOpExists):
+			return true
+		case requirement.Operator == metav1.LabelSelectorOpDoesNotExist && expression.Operator == metav1.LabelSelectorOpDoesNotExist:
+			return true
+		}
+	}
+	return false
+}
+
+func allIn(values []string, set map[string]bool) bool {
+	for _, value := range values {
+		if !set[value] {
+			return false
+		}
+	}
+	return true
+}
+
+func noneIn(values []string, set map[string]bool) bool {
+	for _, value := range values {
+		if set[value] {
+			return false
+		}
+	}
+	return true
+}
+
+func selectorString(selector metav1.LabelSelector) string {
+	if kube.IsLabelSelectorEmpty(selector) {
+		return "all"
+	}
+	return metav1.FormatLabelSelector(&selector)
+}
+
+func networkPolicyRules(policy *networkingv1.NetworkPolicy, isIngress bool) []*lintRule {
+	direction := "egress"
+	var peerLists [][]networkingv1.NetworkPolicyPeer
+	var portLists [][]networkingv1.NetworkPolicyPort
+	if isIngress {
+		direction = "ingress"
+		for _, rule := range policy.Spec.Ingress {
+			peerLists = append(peerLists, rule.From)
+			portLists = append(portLists, rule.Ports)
+		}
+	} else {
+		for _, rule := range policy.Spec.Egress {
+			peerLists = append(peerLists, rule.To)
+			portLists = append(portLists, rule.Ports)
+		}
+	}
+	var rules []*lintRule
+	for i, peers := range peerLists {
+		rule := &lintRule{description: fmt.Sprintf("%s rule %d", direction, i+1), ports: networkPolicyPorts(portLists[i])}
+		if len(peers) == 0 {
+			rule.peers = []*lintPeer{{description: "all peers", all: true}}
+		}
+		for _, peer := range peers {
+			rule.peers = append(rule.peers, networkPolicyPeer(peer))
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func networkPolicyPeer(peer networkingv1.NetworkPolicyPeer) *lintPeer {
+	if peer.IPBlock != nil {
+		description := "ipBlock " + peer.IPBlock.CIDR
+		if len(peer.IPBlock.Except) > 0 {
+			description += " except " + strings.Join(peer.IPBlock.Except, ", ")
+		}
+		return &lintPeer{description: description, ipBlock: peer.IPBlock}
+	}
+	var parts []string
+	if peer.NamespaceSelector != nil {
+		parts = append(parts, "namespace selector "+selectorString(*peer.NamespaceSelector))
+	}
+	if peer.PodSelector != nil {
+		parts = append(parts, "pod selector "+selectorString(*peer.PodSelector))
+	}
+	return &lintPeer{description: strings.Join(parts, ", "), namespaces: peer.NamespaceSelector, pods: peer.PodSelector}
+}
+
+func networkPolicyPorts(ports []networkingv1.NetworkPolicyPort) []*lintPort {
+	var lintPorts []*lintPort
+	for _, port := range ports {
+		protocol := v1.ProtocolTCP
+		if port.Protocol != nil {
+			protocol = *port.Protocol
+		}
+		if port.Port == nil {
+			lintPorts = append(lintPorts, &lintPort{protocol: protocol, from: 1, to: 65535})
+		} else if port.Port.Type == intstr.String {
+			lintPorts = append(lintPorts, &lintPort{protocol: protocol, name: port.Port.StrVal})
+		} else if port.EndPort != nil {
+			lintPorts = append(lintPorts, &lintPort{protocol: protocol, from: int(port.Port.IntVal), to: int(*port.EndPort)})
+		} else {
+			lintPorts = append(lintPorts, &lintPort{protocol: protocol, from: int(port.Port.IntVal), to: int(port.Port.IntVal)})
+		}
+	}
+	return lintPorts
+}
+
+// LintRedundantRules finds NetworkPolicy rules and peers which allow nothing that the policy's other rules and
+// peers don't: since a policy's rules are a union, they could be removed without changing what it allows
+func LintRedundantRules(kubePolicies []*networkingv1.NetworkPolicy) []*Warning {
+	var ws []*Warning
+	for _, policy := range kubePolicies {
+		policy := policy
+		newWarning := func(check Check, details string) *Warning {
+			return &Warning{Check: check, SourcePolicy: policy, Details: details}
+		}
+		for _, isIngress := range []bool{true, false} {
+			ws = append(ws, shadowWarnings(networkPolicyRules(policy, isIngress), false, CheckSourceRedundantRule, CheckSourceRedundantPeer, "also allowed", newWarning)...)
+		}
+	}
+	return ws
+}
+
+// LintNamedPortsNotExposed finds ingress rules' named ports which no pod the policy selects has a container port
+// of, so that they match no traffic.  Policies selecting none of pods are skipped, as are numbered ports, since pods
+// may listen on ports they don't declare.
+func LintNamedPortsNotExposed(kubePolicies []*networkingv1.NetworkPolicy, pods []v1.Pod) []*Warning {
+	var ws []*Warning
+	for _, policy := range kubePolicies {
+		var selected []v1.Pod
+		for _, pod := range pods {
+			if pod.Namespace == policy.Namespace && kube.IsLabelsMatchLabelSelector(pod.Labels, policy.Spec.PodSelector) {
+				selected = append(selected, pod)
+			}
+		}
+		if len(selected) == 0 {
+			continue
+		}
+		for _, rule := range networkPolicyRules(policy, true) {
+			for _, port := range rule.ports {
+				if port.name == "" || isPortExposed(selected, port) {
+					continue
+				}
+				ws = append(ws, &Warning{
+					Check:        CheckSourceNamedPortNotExposed,
+					SourcePolicy: policy,
+					Details:      fmt.Sprintf("%s port %s/%s: none of the %d pods the policy selects has a container port of that name and protocol", rule.description, port.protocol, port.name, len(selected)),
+				})
+			}
+		}
+	}
+	return ws
+}
+
+func isPortExposed(pods []v1.Pod, port *lintPort) bool {
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			for _, containerPort := range container.Ports {
+				protocol := containerPort.Protocol
+				if protocol == "" {
+					protocol = v1.ProtocolTCP
+				}
+				if containerPort.Name == port.name && protocol == port.protocol {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func adminPolicyRules(policy *anp.AdminNetworkPolicy, isIngress bool) []*lintRule {
+	direction := "egress"
+	var names []string
+	var peerLists [][]anp.AdminNetworkPolicyPeer
+	var portLists []*[]anp.AdminNetworkPolicyPort
+	if isIngress {
+		direction = "ingress"
+		for _, rule := range policy.Spec.Ingress {
+			names = append(names, rule.Name)
+			peerLists = append(peerLists, rule.From)
+			portLists = append(portLists, rule.Ports)
+		}
+	} else {
+		for _, rule := range policy.Spec.Egress {
+			names = append(names, rule.Name)
+			peerLists = append(peerLists, rule.To)
+			portLists = append(portLists, rule.Ports)
+		}
+	}
+	var rules []*lintRule
+	for i, peers := range peerLists {
+		description := fmt.Sprintf("%s rule %d", direction, i+1)
+		if names[i] != "" {
+			description += fmt.Sprintf(" '%s'", names[i])
+		}
+		rule := &lintRule{description: description, ports: adminPolicyPorts(portLists[i])}
+		for _, peer := range peers {
+			rule.peers = append(rule.peers, adminPolicyPeer(peer))
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func adminPolicyPeer(peer anp.AdminNetworkPolicyPeer) *lintPeer {
+	set := 0
+	for _, isSet := range []bool{peer.Namespaces != nil, peer.Pods != nil, peer.Nodes != nil, peer.Networks != nil} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return &lintPeer{description: "invalid peer", invalid: true}
+	}
+	if peer.Nodes != nil {
+		return &lintPeer{description: "node selector " + selectorString(*peer.Nodes), nodes: peer.Nodes}
+	}
+	if peer.Networks != nil {
+		var cidrs []string
+		networks := []*networkingv1.IPBlock{}
+		for _, cidr := range peer.Networks {
+			cidrs = append(cidrs, string(cidr))
+			networks = append(networks, &networkingv1.IPBlock{CIDR: string(cidr)})
+		}
+		return &lintPeer{description: "networks " + strings.Join(cidrs, ", "), networks: networks}
+	}
+	if peer.Pods != nil {
+		return &lintPeer{
+			description: fmt.Sprintf("namespace selector %s, pod selector %s", selectorString(peer.Pods.NamespaceSelector), selectorString(peer.Pods.PodSelector)),
+			namespaces:  &peer.Pods.NamespaceSelector,
+			pods:        &peer.Pods.PodSelector,
+		}
+	}
+	return &lintPeer{description: "namespace selector " + selectorString(*peer.Namespaces), namespaces: peer.Namespaces}
+}
+
+func adminPolicyPorts(ports *[]anp.AdminNetworkPolicyPort) []*lintPort {
+	if ports == nil {
+		return nil
+	}
+	lintPorts := []*lintPort{}
+	for _, port := range *ports {
+		if port.PortNumber != nil {
+			lintPorts = append(lintPorts, &lintPort{protocol: port.PortNumber.Protocol, from: int(port.PortNumber.Port), to: int(port.PortNumber.Port)})
+		} else if port.NamedPort != nil {
+			lintPorts = append(lintPorts, &lintPort{name: *port.NamedPort})
+		} else if port.PortRange != nil {
+			protocol := port.PortRange.Protocol
+			if protocol == "" {
+				protocol = v1.ProtocolTCP
+			}
+			lintPorts = append(lintPorts, &lintPort{protocol: protocol, from: int(port.PortRange.Start), to: int(port.PortRange.End)})
+		}
+	}
+	return lintPorts
+}
+
+// LintAdminPolicies finds AdminNetworkPolicy rules and peers which can never match, because an earlier rule of the
+// same policy matches all of their traffic: the first matching rule decides, whatever its action
+func LintAdminPolicies(adminPolicies []*anp.AdminNetworkPolicy) []*Warning {
+	var ws []*Warning
+	for _, policy := range adminPolicies {
+		policy := policy
+		newWarning := func(check Check, details string) *Warning {
+			return &Warning{Check: check, SourceAdminPolicy: policy, Details: details}
+		}
+		for _, isIngress := range []bool{true, false} {
+			ws = append(ws, shadowWarnings(adminPolicyRules(policy, isIngress), true, CheckSourceShadowedAdminRule, CheckSourceShadowedAdminPeer, "already matched", newWarning)...)
+		}
+	}
+	return ws
+}
@@ -0,0 +1,167 @@
+package linter
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func RunRulesTests() {
+	tcp := v1.ProtocolTCP
+	port80 := intstr.FromInt(80)
+	port443 := intstr.FromInt(443)
+	portHTTP := intstr.FromString("http")
+
+	ingressPolicy := func(rules ...networkingv1.NetworkPolicyIngressRule) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "web"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress:     rules,
+			},
+		}
+	}
+	checks := func(warnings []*Warning) []Check {
+		var cs []Check
+		for _, warning := range warnings {
+			cs = append(cs, warning.Check)
+		}
+		return cs
+	}
+
+	Describe("LintRedundantRules", func() {
+		It("Should find a rule whose peers and ports are covered by another rule", func() {
+			policy := ingressPolicy(
+				networkingv1.NetworkPolicyIngressRule{
+					From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api", "tier": "backend"}}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}},
+				},
+				networkingv1.NetworkPolicyIngressRule{
+					From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}, {Protocol: &tcp, Port: &port443}},
+				})
+
+			warnings := LintRedundantRules([]*networkingv1.NetworkPolicy{policy})
+			Expect(checks(warnings)).To(Equal([]Check{CheckSourceRedundantRule}))
+			Expect(warnings[0].SourcePolicy).To(Equal(policy))
+			Expect(warnings[0].Details).To(Equal("ingress rule 1 (pod selector app=api,tier=backend; ports TCP/80): all its traffic is also allowed by ingress rule 2"))
+		})
+
+		It("Should find a peer covered by another peer, but only the later of identical peers", func() {
+			policy := ingressPolicy(networkingv1.NetworkPolicyIngressRule{
+				From: []networkingv1.NetworkPolicyPeer{
+					{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}},
+					{IPBlock: &networkingv1.IPBlock{CIDR: "10.2.0.0/16"}},
+					{IPBlock: &networkingv1.IPBlock{CIDR: "10.1.2.0/24"}},
+					{NamespaceSelector: &metav1.LabelSelector{}},
+					{NamespaceSelector: &metav1.LabelSelector{}},
+				},
+			})
+
+			warnings := LintRedundantRules([]*networkingv1.NetworkPolicy{policy})
+			Expect(checks(warnings)).To(Equal([]Check{CheckSourceRedundantPeer, CheckSourceRedundantPeer}))
+			Expect(warnings[0].Details).To(Equal("ingress rule 1 peer 2 (ipBlock 10.2.0.0/16): all its traffic is also allowed by ingress rule 1 peer 1 (ipBlock 10.0.0.0/8 except 10.1.0.0/16; all ports)"))
+			Expect(warnings[1].Details).To(HavePrefix("ingress rule 1 peer 5 (namespace selector all)"))
+		})
+
+		It("Should not flag rules which allow something others don't", func() {
+			policy := ingressPolicy(
+				networkingv1.NetworkPolicyIngressRule{
+					From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}},
+				},
+				networkingv1.NetworkPolicyIngressRule{
+					From:  []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}, NamespaceSelector: &metav1.LabelSelector{}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port443}},
+				},
+				networkingv1.NetworkPolicyIngressRule{
+					From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: metav1.LabelSelectorOpExists}}}}},
+				})
+
+			// rule 1's peer is covered by rule 3, which allows all ports
+			warnings := LintRedundantRules([]*networkingv1.NetworkPolicy{policy})
+			Expect(checks(warnings)).To(Equal([]Check{CheckSourceRedundantRule}))
+			Expect(warnings[0].Details).To(HavePrefix("ingress rule 1 "))
+		})
+	})
+
+	Describe("LintNamedPortsNotExposed", func() {
+		policy := ingressPolicy(networkingv1.NetworkPolicyIngressRule{Ports: []networkingv1.NetworkPolicyPort{{Port: &portHTTP}}})
+		pod := func(labels map[string]string, portName string) v1.Pod {
+			return v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "x", Labels: labels},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Ports: []v1.ContainerPort{{Name: portName, ContainerPort: 8080}}}}},
+			}
+		}
+
+		It("Should find a named port which no selected pod has", func() {
+			pods := []v1.Pod{pod(map[string]string{"app": "web"}, "metrics"), pod(map[string]string{"app": "api"}, "http")}
+			warnings := LintNamedPortsNotExposed([]*networkingv1.NetworkPolicy{policy}, pods)
+			Expect(checks(warnings)).To(Equal([]Check{CheckSourceNamedPortNotExposed}))
+			Expect(warnings[0].Details).To(Equal("ingress rule 1 port TCP/http: none of the 1 pods the policy selects has a container port of that name and protocol"))
+		})
+
+		It("Should not flag exposed ports, or policies which select no pods", func() {
+			Expect(LintNamedPortsNotExposed([]*networkingv1.NetworkPolicy{policy}, []v1.Pod{pod(map[string]string{"app": "web"}, "http")})).To(BeEmpty())
+			Expect(LintNamedPortsNotExposed([]*networkingv1.NetworkPolicy{policy}, []v1.Pod{pod(map[string]string{"app": "api"}, "metrics")})).To(BeEmpty())
+		})
+	})
+
+	Describe("LintAdminPolicies", func() {
+		monitoring := metav1.LabelSelector{MatchLabels: map[string]string{"team": "monitoring"}}
+		prometheus := metav1.LabelSelector{MatchLabels: map[string]string{"app": "prometheus"}}
+		ports := []anp.AdminNetworkPolicyPort{{PortNumber: &anp.Port{Protocol: v1.ProtocolTCP, Port: 9090}}}
+
+		It("Should find rules and peers matched by earlier rules, but not by later ones", func() {
+			policy := &anp.AdminNetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster-wide"},
+				Spec: anp.AdminNetworkPolicySpec{
+					Priority: 10,
+					Subject:  anp.AdminNetworkPolicySubject{Namespaces: &metav1.LabelSelector{}},
+					Ingress: []anp.AdminNetworkPolicyIngressRule{
+						{Name: "allow-monitoring", Action: anp.AdminNetworkPolicyRuleActionAllow, From: []anp.AdminNetworkPolicyPeer{{Namespaces: &monitoring}}},
+						{Name: "deny-prometheus", Action: anp.AdminNetworkPolicyRuleActionDeny, Ports: &ports, From: []anp.AdminNetworkPolicyPeer{
+							{Pods: &anp.NamespacedPod{NamespaceSelector: monitoring, PodSelector: prometheus}},
+							{Namespaces: &metav1.LabelSelector{}},
+						}},
+						{Name: "deny-monitoring-metrics", Action: anp.AdminNetworkPolicyRuleActionDeny, Ports: &ports, From: []anp.AdminNetworkPolicyPeer{{Namespaces: &monitoring}}},
+					},
+				},
+			}
+
+			warnings := LintAdminPolicies([]*anp.AdminNetworkPolicy{policy})
+			Expect(checks(warnings)).To(Equal([]Check{CheckSourceShadowedAdminPeer, CheckSourceShadowedAdminRule}))
+			Expect(warnings[0].SourceAdminPolicy).To(Equal(policy))
+			Expect(warnings[0].Details).To(Equal("ingress rule 2 'deny-prometheus' peer 1 (namespace selector team=monitoring, pod selector app=prometheus): all its traffic is already matched by ingress rule 1 'allow-monitoring' peer 1 (namespace selector team=monitoring; all ports)"))
+			Expect(warnings[1].Details).To(Equal("ingress rule 3 'deny-monitoring-metrics' (namespace selector team=monitoring; ports TCP/9090): all its traffic is already matched by ingress rule 1 'allow-monitoring'"))
+		})
+
+		It("Should compare nodes and networks peers only with peers of the same kind", func() {
+			policy := &anp.AdminNetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "egress"},
+				Spec: anp.AdminNetworkPolicySpec{
+					Priority: 10,
+					Subject:  anp.AdminNetworkPolicySubject{Namespaces: &metav1.LabelSelector{}},
+					Egress: []anp.AdminNetworkPolicyEgressRule{
+						{Name: "allow-all-namespaces", Action: anp.AdminNetworkPolicyRuleActionAllow, To: []anp.AdminNetworkPolicyPeer{{Namespaces: &metav1.LabelSelector{}}}},
+						{Name: "deny-nodes", Action: anp.AdminNetworkPolicyRuleActionDeny, To: []anp.AdminNetworkPolicyPeer{{Nodes: &metav1.LabelSelector{}}}},
+						{Name: "deny-networks", Action: anp.AdminNetworkPolicyRuleActionDeny, To: []anp.AdminNetworkPolicyPeer{{Networks: []anp.CIDR{"10.0.0.0/8"}}}},
+						{Name: "deny-subnet", Action: anp.AdminNetworkPolicyRuleActionDeny, To: []anp.AdminNetworkPolicyPeer{
+							{Networks: []anp.CIDR{"10.1.0.0/16", "10.2.0.0/16"}},
+							{},
+						}},
+					},
+				},
+			}
+
+			warnings := LintAdminPolicies([]*anp.AdminNetworkPolicy{policy})
+			Expect(checks(warnings)).To(Equal([]Check{CheckSourceShadowedAdminPeer}))
+			Expect(warnings[0].Details).To(Equal("egress rule 4 'deny-subnet' peer 1 (networks 10.1.0.0/16, 10.2.0.0/16): all its traffic is already matched by egress rule 3 'deny-networks' peer 1 (networks 10.0.0.0/8; all ports)"))
+		})
+	})
+}
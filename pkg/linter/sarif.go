@@ -0,0 +1,187 @@
+package linter
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	SARIFVersion = "2.1.0"
+	SARIFSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// SourceLocation is where a policy was read from: a path relative to the root of the repository the policies are
+// in, and the line of its name
+type SourceLocation struct {
+	Path string
+	Line int
+}
+
+// SARIFLog is the subset of the SARIF format which code scanning tools such as GitHub's need to annotate files
+type SARIFLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*SARIFRun `json:"runs"`
+}
+
+type SARIFRun struct {
+	Tool    *SARIFTool     `json:"tool"`
+	Results []*SARIFResult `json:"results"`
+}
+
+type SARIFTool struct {
+	Driver *SARIFDriver `json:"driver"`
+}
+
+type SARIFDriver struct {
+	Name           string       `json:"name"`
+	Version        string       `json:"version,omitempty"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*SARIFRule `json:"rules"`
+}
+
+type SARIFRule struct {
+	ID                   string                  `json:"id"`
+	ShortDescription     *SARIFMessage           `json:"shortDescription"`
+	DefaultConfiguration *SARIFRuleConfiguration `json:"defaultConfiguration"`
+}
+
+type SARIFRuleConfiguration struct {
+	Level string `json:"level"`
+}
+
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+type SARIFResult struct {
+	RuleID    string           `json:"ruleId"`
+	RuleIndex int              `json:"ruleIndex"`
+	Level     string           `json:"level"`
+	Message   *SARIFMessage    `json:"message"`
+	Locations []*SARIFLocation `json:"locations,omitempty"`
+}
+
+// SARIFLocation has a physical location for policies read from files, and always a logical location naming the
+// policy, for those read from kube
+type SARIFLocation struct {
+	PhysicalLocation *SARIFPhysicalLocation  `json:"physicalLocation,omitempty"`
+	LogicalLocations []*SARIFLogicalLocation `json:"logicalLocations"`
+}
+
+type SARIFPhysicalLocation struct {
+	ArtifactLocation *SARIFArtifactLocation `json:"artifactLocation"`
+	Region           *SARIFRegion           `json:"region"`
+}
+
+type SARIFArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type SARIFRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type SARIFLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// SARIF reports each warning as a result located at its policy -- or, for warnings about resolved targets, at each
+// of the policies the target came from.  Every check is listed as a rule, whether or not it was found, so that code
+// scanning can tell that a fixed warning has gone away.
+func SARIF(warnings []*Warning, locations map[*networkingv1.NetworkPolicy]*SourceLocation, toolVersion string) *SARIFLog {
+	checkDescriptions := CheckDescriptions()
+	var checks []string
+	for check := range checkDescriptions {
+		checks = append(checks, string(check))
+	}
+	sort.Strings(checks)
+	ruleIndexes := map[Check]int{}
+	var rules []*SARIFRule
+	for i, check := range checks {
+		ruleIndexes[Check(check)] = i
+		rules = append(rules, &SARIFRule{
+			ID:                   check,
+			ShortDescription:     &SARIFMessage{Text: checkDescriptions[Check(check)]},
+			DefaultConfiguration: &SARIFRuleConfiguration{Level: "warning"},
+		})
+	}
+
+	results := []*SARIFResult{}
+	for _, warning := range warnings {
+		var policies []*networkingv1.NetworkPolicy
+		var message string
+		var adminLocations []*SARIFLocation
+		if warning.SourceAdminPolicy != nil {
+			message = fmt.Sprintf("%s: %s", warning.sourceName(), checkDescriptions[warning.Check])
+			adminLocations = []*SARIFLocation{{LogicalLocations: []*SARIFLogicalLocation{{FullyQualifiedName: warning.sourceName(), Kind: "object"}}}}
+		} else if warning.SourcePolicy != nil {
+			policies = []*networkingv1.NetworkPolicy{warning.SourcePolicy}
+			message = fmt.Sprintf("%s: %s", warning.sourceName(), checkDescriptions[warning.Check])
+		} else {
+			policies = warning.Target.SourceRules
+			message = fmt.Sprintf("%s in namespace %s: %s", describePods(warning.Target.PodSelector), warning.Target.Namespace, checkDescriptions[warning.Check])
+		}
+		if warning.Details != "" {
+			message += " -- " + warning.Details
+		}
+		result := &SARIFResult{
+			RuleID:    string(warning.Check),
+			RuleIndex: ruleIndexes[warning.Check],
+			Level:     warning.Severity.sarifLevel(),
+			Message:   &SARIFMessage{Text: message},
+			Locations: adminLocations,
+		}
+		for _, policy := range policies {
+			location := &SARIFLocation{LogicalLocations: []*SARIFLogicalLocation{{
+				FullyQualifiedName: policy.Namespace + "/" + policy.Name,
+				Kind:               "object",
+			}}}
+			if source, ok := locations[policy]; ok {
+				location.PhysicalLocation = &SARIFPhysicalLocation{
+					ArtifactLocation: &SARIFArtifactLocation{URI: filepath.ToSlash(source.Path), URIBaseID: "%SRCROOT%"},
+					Region:           &SARIFRegion{StartLine: source.Line},
+				}
+			}
+			result.Locations = append(result.Locations, location)
+		}
+		results = append(results, result)
+	}
+
+	return &SARIFLog{
+		Schema:  SARIFSchema,
+		Version: SARIFVersion,
+		Runs: []*SARIFRun{{
+			Tool: &SARIFTool{Driver: &SARIFDriver{
+				Name:           "cyclonus",
+				Version:        toolVersion,
+				InformationURI: "https://github.com/mattfenwick/cyclonus",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+func describePods(selector metav1.LabelSelector) string {
+	if len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 {
+		return "all pods"
+	}
+	return "pods matching " + metav1.FormatLabelSelector(&selector)
+}
+
+func (s *SARIFLog) Write(path string) error {
+	bytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "unable to marshal sarif")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, bytes, 0644), "unable to write sarif to %s", path)
+}
@@ -0,0 +1,17 @@
+package linter
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLinter(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunRulesTests()
+	RunDefaultDenyTests()
+	RunOverlapTests()
+	RunConfigTests()
+	RunSpecs(t, "linter suite")
+}
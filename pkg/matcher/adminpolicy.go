@@ -0,0 +1,242 @@
+package matcher
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"net"
+	"sort"
+)
+
+// AdminSubject picks the pods an AdminNetworkPolicy or BaselineAdminNetworkPolicy applies to
+type AdminSubject struct {
+	Namespace NamespaceMatcher
+	Pod       PodMatcher
+}
+
+func (s *AdminSubject) IsMatch(namespace string, namespaceLabels map[string]string, podLabels map[string]string) bool {
+	return s.Namespace.Allows(namespace, namespaceLabels) && s.Pod.Allows(podLabels)
+}
+
+// AdminRule is a single AdminNetworkPolicy or BaselineAdminNetworkPolicy rule.  Unlike NetworkPolicy rules, which
+// only ever allow traffic, an AdminRule decides what happens to the traffic it matches.
+type AdminRule struct {
+	Name   string
+	Action anp.AdminNetworkPolicyRuleAction
+	Peers  []PeerMatcher
+}
+
+func (r *AdminRule) Matches(peer *TrafficPeer, portInt int, portName string, protocol v1.Protocol) bool {
+	for _, peerMatcher := range r.Peers {
+		if peerMatcher.Allows(peer, portInt, portName, protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminPolicy models an AdminNetworkPolicy, or -- if IsBaseline -- a BaselineAdminNetworkPolicy, whose rules
+// are evaluated in order
+type AdminPolicy struct {
+	Name       string
+	Priority   int32
+	IsBaseline bool
+	// Subject is nil if the policy's subject is invalid, in which case the policy applies to no pods
+	Subject *AdminSubject
+	Ingress []*AdminRule
+	Egress  []*AdminRule
+}
+
+func (a *AdminPolicy) String() string {
+	if a.IsBaseline {
+		return fmt.Sprintf("BaselineAdminNetworkPolicy %s", a.Name)
+	}
+	return fmt.Sprintf("AdminNetworkPolicy %s (priority %d)", a.Name, a.Priority)
+}
+
+// AdminRuleMatch is an AdminPolicy rule which matched some traffic
+type AdminRuleMatch struct {
+	Policy *AdminPolicy
+	Rule   *AdminRule
+}
+
+func (a *AdminRuleMatch) String() string {
+	return fmt.Sprintf("%s, rule '%s': %s", a.Policy, a.Rule.Name, a.Rule.Action)
+}
+
+// FirstMatchingRule returns the policy's first rule matching the traffic, or nil if the policy doesn't apply to
+// the traffic's target or none of its rules match
+func (a *AdminPolicy) FirstMatchingRule(traffic *Traffic, isIngress bool) *AdminRuleMatch {
+	target, peer, rules := traffic.Source, traffic.Destination, a.Egress
+	if isIngress {
+		target, peer, rules = traffic.Destination, traffic.Source, a.Ingress
+	}
+	if target.Internal == nil || a.Subject == nil || !a.Subject.IsMatch(target.Internal.Namespace, target.Internal.NamespaceLabels, target.Internal.PodLabels) {
+		return nil
+	}
+	for _, rule := range rules {
+		if rule.Matches(peer, traffic.ResolvedPort, traffic.ResolvedPortName, traffic.Protocol) {
+			return &AdminRuleMatch{Policy: a, Rule: rule}
+		}
+	}
+	return nil
+}
+
+// SortAdminPolicies orders AdminNetworkPolicies by precedence: by priority, with lower numbers first.  Ties aren't
+// defined by the API; they're broken by name, to at least be deterministic.
+func SortAdminPolicies(policies []*AdminPolicy) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		if policies[i].Priority != policies[j].Priority {
+			return policies[i].Priority < policies[j].Priority
+		}
+		return policies[i].Name < policies[j].Name
+	})
+}
+
+func BuildAdminNetworkPolicies(policies []*anp.AdminNetworkPolicy) []*AdminPolicy {
+	var adminPolicies []*AdminPolicy
+	for _, policy := range policies {
+		adminPolicies = append(adminPolicies, BuildAdminNetworkPolicy(policy))
+	}
+	SortAdminPolicies(adminPolicies)
+	return adminPolicies
+}
+
+func BuildAdminNetworkPolicy(policy *anp.AdminNetworkPolicy) *AdminPolicy {
+	adminPolicy := &AdminPolicy{
+		Name:     policy.Name,
+		Priority: policy.Spec.Priority,
+		Subject:  BuildAdminSubject(policy.Spec.Subject),
+	}
+	for _, rule := range policy.Spec.Ingress {
+		adminPolicy.Ingress = append(adminPolicy.Ingress, &AdminRule{Name: rule.Name, Action: rule.Action, Peers: BuildAdminPeerMatchers(rule.From, rule.Ports)})
+	}
+	for _, rule := range policy.Spec.Egress {
+		adminPolicy.Egress = append(adminPolicy.Egress, &AdminRule{Name: rule.Name, Action: rule.Action, Peers: BuildAdminPeerMatchers(rule.To, rule.Ports)})
+	}
+	return adminPolicy
+}
+
+func BuildBaselineAdminNetworkPolicy(policy *anp.BaselineAdminNetworkPolicy) *AdminPolicy {
+	adminPolicy := &AdminPolicy{
+		Name:       policy.Name,
+		IsBaseline: true,
+		Subject:    BuildAdminSubject(policy.Spec.Subject),
+	}
+	for _, rule := range policy.Spec.Ingress {
+		adminPolicy.Ingress = append(adminPolicy.Ingress, &AdminRule{Name: rule.Name, Action: anp.AdminNetworkPolicyRuleAction(rule.Action), Peers: BuildAdminPeerMatchers(rule.From, rule.Ports)})
+	}
+	for _, rule := range policy.Spec.Egress {
+		adminPolicy.Egress = append(adminPolicy.Egress, &AdminRule{Name: rule.Name, Action: anp.AdminNetworkPolicyRuleAction(rule.Action), Peers: BuildAdminPeerMatchers(rule.To, rule.Ports)})
+	}
+	return adminPolicy
+}
+
+func buildAdminNamespaceMatcher(selector metav1.LabelSelector) NamespaceMatcher {
+	if kube.IsLabelSelectorEmpty(selector) {
+		return &AllNamespaceMatcher{}
+	}
+	return &LabelSelectorNamespaceMatcher{Selector: selector}
+}
+
+func buildAdminPodMatcher(selector metav1.LabelSelector) PodMatcher {
+	if kube.IsLabelSelectorEmpty(selector) {
+		return &AllPodMatcher{}
+	}
+	return &LabelSelectorPodMatcher{Selector: selector}
+}
+
+// BuildAdminSubject returns nil -- a subject which applies to no pods -- if exactly one of Namespaces and Pods
+// isn't set
+func BuildAdminSubject(subject anp.AdminNetworkPolicySubject) *AdminSubject {
+	if subject.Namespaces != nil && subject.Pods == nil {
+		return &AdminSubject{Namespace: buildAdminNamespaceMatcher(*subject.Namespaces), Pod: &AllPodMatcher{}}
+	} else if subject.Pods != nil && subject.Namespaces == nil {
+		return &AdminSubject{
+			Namespace: buildAdminNamespaceMatcher(subject.Pods.NamespaceSelector),
+			Pod:       buildAdminPodMatcher(subject.Pods.PodSelector),
+		}
+	}
+	logrus.Warnf("skipping invalid AdminNetworkPolicy subject: exactly one of namespaces and pods must be set")
+	return nil
+}
+
+// BuildAdminPeerMatchers skips peers which can't be simulated -- nodes, since cyclonus can't tell which node
+// traffic is from or to -- and invalid peers, rather than failing on policies which the cluster accepted
+func BuildAdminPeerMatchers(peers []anp.AdminNetworkPolicyPeer, ports *[]anp.AdminNetworkPolicyPort) []PeerMatcher {
+	port := BuildAdminPortMatcher(ports)
+	var matchers []PeerMatcher
+	for _, peer := range peers {
+		fields := 0
+		for _, isSet := range []bool{peer.Namespaces != nil, peer.Pods != nil, peer.Nodes != nil, peer.Networks != nil} {
+			if isSet {
+				fields++
+			}
+		}
+		switch {
+		case fields != 1:
+			logrus.Warnf("skipping invalid AdminNetworkPolicy peer: exactly one of namespaces, pods, nodes and networks must be set")
+		case peer.Namespaces != nil:
+			matchers = append(matchers, &PodPeerMatcher{Namespace: buildAdminNamespaceMatcher(*peer.Namespaces), Pod: &AllPodMatcher{}, Port: port})
+		case peer.Pods != nil:
+			matchers = append(matchers, &PodPeerMatcher{
+				Namespace: buildAdminNamespaceMatcher(peer.Pods.NamespaceSelector),
+				Pod:       buildAdminPodMatcher(peer.Pods.PodSelector),
+				Port:      port,
+			})
+		case peer.Nodes != nil:
+			logrus.Warnf("skipping AdminNetworkPolicy nodes peer %s: traffic to and from nodes isn't simulated", kube.SerializeLabelSelector(*peer.Nodes))
+		default:
+			for _, cidr := range peer.Networks {
+				if _, _, err := net.ParseCIDR(string(cidr)); err != nil {
+					logrus.Warnf("skipping AdminNetworkPolicy networks peer %s: %+v", cidr, err)
+					continue
+				}
+				matchers = append(matchers, &IPPeerMatcher{IPBlock: &networkingv1.IPBlock{CIDR: string(cidr)}, Port: port})
+			}
+		}
+	}
+	return matchers
+}
+
+// BuildAdminPortMatcher translates AdminNetworkPolicy ports into NetworkPolicy ports.  A named port doesn't specify
+// a protocol -- it matches whatever protocol the pod's port uses -- so it's translated into one port per protocol.
+func BuildAdminPortMatcher(ports *[]anp.AdminNetworkPolicyPort) PortMatcher {
+	if ports == nil {
+		return &AllPortMatcher{}
+	}
+	var npPorts []networkingv1.NetworkPolicyPort
+	for _, port := range *ports {
+		if port.PortNumber != nil {
+			protocol := port.PortNumber.Protocol
+			portNumber := intstr.FromInt(int(port.PortNumber.Port))
+			npPorts = append(npPorts, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &portNumber})
+		} else if port.NamedPort != nil {
+			for _, protocol := range []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP, v1.ProtocolSCTP} {
+				protocol := protocol
+				namedPort := intstr.FromString(*port.NamedPort)
+				npPorts = append(npPorts, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &namedPort})
+			}
+		} else if port.PortRange != nil {
+			protocol := v1.ProtocolTCP
+			if port.PortRange.Protocol != "" {
+				protocol = port.PortRange.Protocol
+			}
+			start := intstr.FromInt(int(port.PortRange.Start))
+			end := port.PortRange.End
+			npPorts = append(npPorts, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &start, EndPort: &end})
+		} else {
+			logrus.Warnf("skipping invalid AdminNetworkPolicy port: exactly one of portNumber, namedPort and portRange must be set")
+		}
+	}
+	if len(npPorts) == 0 && len(*ports) > 0 {
+		// every port was skipped: match none of them, rather than all
+		return &SpecificPortMatcher{}
+	}
+	return BuildPortMatcher(npPorts)
+}
@@ -0,0 +1,175 @@
+package matcher
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func RunAdminPolicyTests() {
+	adminPolicy := func(name string, priority int, action string) *anp.AdminNetworkPolicy {
+		serialized := `
+apiVersion: policy.networking.k8s.io/v1alpha1
+kind: AdminNetworkPolicy
+metadata:
+  name: ` + name + `
+spec:
+  priority: ` + fmt.Sprintf("%d", priority) + `
+  subject:
+    namespaces:
+      matchLabels:
+        ns: x
+  ingress:
+  - name: from-y
+    action: ` + action + `
+    from:
+    - namespaces:
+        matchLabels:
+          ns: "y"`
+		var policy *anp.AdminNetworkPolicy
+		utils.DoOrDie(yaml.Unmarshal([]byte(serialized), &policy))
+		return policy
+	}
+
+	denyAllIngressSerialized := `
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-all
+  namespace: x
+spec:
+  podSelector: {}
+  policyTypes:
+  - Ingress`
+	var denyAllIngress *networkingv1.NetworkPolicy
+	utils.DoOrDie(yaml.Unmarshal([]byte(denyAllIngressSerialized), &denyAllIngress))
+
+	baselineSerialized := `
+apiVersion: policy.networking.k8s.io/v1alpha1
+kind: BaselineAdminNetworkPolicy
+metadata:
+  name: default
+spec:
+  subject:
+    namespaces: {}
+  ingress:
+  - name: deny-from-y
+    action: Deny
+    from:
+    - namespaces:
+        matchLabels:
+          ns: "y"`
+	var baseline *anp.BaselineAdminNetworkPolicy
+	utils.DoOrDie(yaml.Unmarshal([]byte(baselineSerialized), &baseline))
+
+	yToX := &Traffic{
+		Source: &TrafficPeer{
+			Internal: &InternalPeer{PodLabels: map[string]string{"pod": "a"}, NamespaceLabels: map[string]string{"ns": "y"}, Namespace: "y"},
+			IP:       "1.2.3.4",
+		},
+		Destination: &TrafficPeer{
+			Internal: &InternalPeer{PodLabels: map[string]string{"pod": "a"}, NamespaceLabels: map[string]string{"ns": "x"}, Namespace: "x"},
+			IP:       "1.2.3.5",
+		},
+		ResolvedPort:     80,
+		ResolvedPortName: "serve-80-tcp",
+		Protocol:         v1.ProtocolTCP,
+	}
+
+	Describe("AdminNetworkPolicy actions", func() {
+		It("should let the highest-precedence matching rule decide", func() {
+			policy := BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{denyAllIngress})
+			policy.AddAdminPolicies(BuildAdminNetworkPolicies([]*anp.AdminNetworkPolicy{adminPolicy("allow-y", 20, "Allow"), adminPolicy("deny-y", 10, "Deny")}))
+			result := policy.IsTrafficAllowed(yToX)
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.AdminRule.Policy.Name).To(Equal("deny-y"))
+		})
+
+		It("should let an Allow override NetworkPolicies", func() {
+			policy := BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{denyAllIngress})
+			policy.AddAdminPolicies(BuildAdminNetworkPolicies([]*anp.AdminNetworkPolicy{adminPolicy("allow-y", 20, "Allow"), adminPolicy("pass-y", 30, "Pass")}))
+			Expect(policy.IsTrafficAllowed(yToX).IsAllowed()).To(BeTrue())
+		})
+
+		It("should let a Pass hand the decision to NetworkPolicies, skipping lower-priority AdminNetworkPolicies", func() {
+			policy := BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{denyAllIngress})
+			policy.AddAdminPolicies(BuildAdminNetworkPolicies([]*anp.AdminNetworkPolicy{adminPolicy("allow-y", 20, "Allow"), adminPolicy("pass-y", 10, "Pass")}))
+			result := policy.IsTrafficAllowed(yToX)
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.AdminRule.Rule.Action).To(Equal(anp.AdminNetworkPolicyRuleActionPass))
+			Expect(result.Ingress.DenyingTargets).To(HaveLen(1))
+		})
+
+		It("should let a Pass hand the decision to the baseline, if no NetworkPolicies apply", func() {
+			policy := NewPolicy()
+			policy.AddAdminPolicies(BuildAdminNetworkPolicies([]*anp.AdminNetworkPolicy{adminPolicy("pass-y", 10, "Pass")}))
+			Expect(policy.IsTrafficAllowed(yToX).IsAllowed()).To(BeTrue())
+
+			policy.BaselinePolicy = BuildBaselineAdminNetworkPolicy(baseline)
+			result := policy.IsTrafficAllowed(yToX)
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.BaselineRule.Rule.Name).To(Equal("deny-from-y"))
+		})
+
+		It("should not apply the baseline if a NetworkPolicy applies", func() {
+			allowAll := denyAllIngress.DeepCopy()
+			allowAll.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{}}
+			policy := BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{allowAll})
+			policy.BaselinePolicy = BuildBaselineAdminNetworkPolicy(baseline)
+			Expect(policy.IsTrafficAllowed(yToX).IsAllowed()).To(BeTrue())
+		})
+
+		It("should match named ports on any protocol", func() {
+			namedPort := "serve-80-udp"
+			matcher := BuildAdminPortMatcher(&[]anp.AdminNetworkPolicyPort{{NamedPort: &namedPort}})
+			Expect(matcher.Allows(80, "serve-80-udp", v1.ProtocolUDP)).To(BeTrue())
+			Expect(matcher.Allows(80, "serve-80-tcp", v1.ProtocolTCP)).To(BeFalse())
+		})
+
+		It("should match port ranges, defaulting to TCP", func() {
+			matcher := BuildAdminPortMatcher(&[]anp.AdminNetworkPolicyPort{{PortRange: &anp.PortRange{Start: 80, End: 81}}})
+			Expect(matcher.Allows(81, "serve-81-tcp", v1.ProtocolTCP)).To(BeTrue())
+			Expect(matcher.Allows(82, "serve-82-tcp", v1.ProtocolTCP)).To(BeFalse())
+			Expect(matcher.Allows(80, "serve-80-udp", v1.ProtocolUDP)).To(BeFalse())
+		})
+
+		It("should select pods within namespaces", func() {
+			subject := BuildAdminSubject(anp.AdminNetworkPolicySubject{Pods: &anp.NamespacedPod{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"ns": "x"}},
+				PodSelector:       metav1.LabelSelector{MatchLabels: map[string]string{"pod": "a"}},
+			}})
+			Expect(subject.IsMatch("x", map[string]string{"ns": "x"}, map[string]string{"pod": "a"})).To(BeTrue())
+			Expect(subject.IsMatch("x", map[string]string{"ns": "x"}, map[string]string{"pod": "b"})).To(BeFalse())
+			Expect(subject.IsMatch("y", map[string]string{"ns": "y"}, map[string]string{"pod": "a"})).To(BeFalse())
+		})
+
+		It("should match networks peers by CIDR, and skip nodes and invalid peers", func() {
+			matchers := BuildAdminPeerMatchers([]anp.AdminNetworkPolicyPeer{
+				{Networks: []anp.CIDR{"10.0.0.0/8", "not-a-cidr"}},
+				{Nodes: &metav1.LabelSelector{}},
+				{},
+				{Namespaces: &metav1.LabelSelector{}, Pods: &anp.NamespacedPod{}},
+			}, nil)
+			Expect(matchers).To(HaveLen(1))
+			Expect(matchers[0].Allows(&TrafficPeer{IP: "10.1.2.3"}, 80, "serve-80-tcp", v1.ProtocolTCP)).To(BeTrue())
+			Expect(matchers[0].Allows(&TrafficPeer{IP: "192.168.1.1"}, 80, "serve-80-tcp", v1.ProtocolTCP)).To(BeFalse())
+		})
+
+		It("should skip invalid subjects and ports instead of panicking", func() {
+			invalid := adminPolicy("invalid", 10, "Deny")
+			invalid.Spec.Subject = anp.AdminNetworkPolicySubject{}
+			invalid.Spec.Ingress[0].Ports = &[]anp.AdminNetworkPolicyPort{{}}
+			var built []*AdminPolicy
+			Expect(func() { built = BuildAdminNetworkPolicies([]*anp.AdminNetworkPolicy{invalid}) }).NotTo(Panic())
+			Expect(built[0].Subject).To(BeNil())
+			Expect(built[0].FirstMatchingRule(yToX, true)).To(BeNil())
+			Expect(BuildAdminPortMatcher(&[]anp.AdminNetworkPolicyPort{{}}).Allows(80, "serve-80-tcp", v1.ProtocolTCP)).To(BeFalse())
+		})
+	})
+}
@@ -0,0 +1,257 @@
+package matcher
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube/antrea"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sort"
+)
+
+// AntreaRule is an ingress or egress rule of an Antrea ClusterNetworkPolicy or NetworkPolicy.  Like an AdminRule,
+// its action decides the traffic it matches.
+type AntreaRule struct {
+	// Name is the rule's name, if it has one, or i.e. "ingress rule 1"
+	Name   string
+	Action antrea.RuleAction
+	// AppliedTo is the rule's own appliedTo, for policies which don't have one
+	AppliedTo []*AdminSubject
+	Peers     []PeerMatcher
+	// SameNamespacePeers are namespaces: {match: Self} peers: their namespace is ignored, since they match pods in
+	// the namespace of the pod the rule applies to
+	SameNamespacePeers []*PodPeerMatcher
+}
+
+func (r *AntreaRule) Matches(target *InternalPeer, peer *TrafficPeer, portInt int, portName string, protocol v1.Protocol) bool {
+	for _, peerMatcher := range r.Peers {
+		if peerMatcher.Allows(peer, portInt, portName, protocol) {
+			return true
+		}
+	}
+	for _, sameNamespace := range r.SameNamespacePeers {
+		peerMatcher := &PodPeerMatcher{Namespace: &ExactNamespaceMatcher{Namespace: target.Namespace}, Pod: sameNamespace.Pod, Port: sameNamespace.Port}
+		if peerMatcher.Allows(peer, portInt, portName, protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// AntreaPolicy models an Antrea ClusterNetworkPolicy or NetworkPolicy.  Its tier's priority, and then its own,
+// order it among the others; policies in the baseline tier are evaluated after NetworkPolicies, and the rest before.
+type AntreaPolicy struct {
+	// Name is i.e. "namespace/name" for a NetworkPolicy, and "name" for a ClusterNetworkPolicy
+	Name          string
+	IsClusterwide bool
+	Tier          string
+	TierPriority  int32
+	Priority      float64
+	// AppliedTo picks the pods the policy applies to; if it's empty, each of its rules has its own
+	AppliedTo []*AdminSubject
+	Ingress   []*AntreaRule
+	Egress    []*AntreaRule
+}
+
+func (a *AntreaPolicy) String() string {
+	kind := antrea.NetworkPolicyKind
+	if a.IsClusterwide {
+		kind = antrea.ClusterNetworkPolicyKind
+	}
+	return fmt.Sprintf("Antrea %s %s (tier %s, priority %g)", kind, a.Name, a.Tier, a.Priority)
+}
+
+func (a *AntreaPolicy) IsBaseline() bool {
+	return a.Tier == antrea.BaselineTierName
+}
+
+// AntreaRuleMatch is an AntreaPolicy rule which matched some traffic
+type AntreaRuleMatch struct {
+	Policy *AntreaPolicy
+	Rule   *AntreaRule
+}
+
+func (a *AntreaRuleMatch) String() string {
+	return fmt.Sprintf("%s, %s: %s", a.Policy, a.Rule.Name, a.Rule.Action)
+}
+
+func isAntreaAppliedTo(subjects []*AdminSubject, target *InternalPeer) bool {
+	for _, subject := range subjects {
+		if subject.IsMatch(target.Namespace, target.NamespaceLabels, target.PodLabels) {
+			return true
+		}
+	}
+	return false
+}
+
+// FirstMatchingRule returns the policy's first rule which applies to the traffic's target and matches the traffic,
+// or nil if there isn't one
+func (a *AntreaPolicy) FirstMatchingRule(traffic *Traffic, isIngress bool) *AntreaRuleMatch {
+	target, peer, rules := traffic.Source, traffic.Destination, a.Egress
+	if isIngress {
+		target, peer, rules = traffic.Destination, traffic.Source, a.Ingress
+	}
+	if target.Internal == nil {
+		return nil
+	}
+	for _, rule := range rules {
+		appliedTo := a.AppliedTo
+		if len(appliedTo) == 0 {
+			appliedTo = rule.AppliedTo
+		}
+		if isAntreaAppliedTo(appliedTo, target.Internal) && rule.Matches(target.Internal, peer, traffic.ResolvedPort, traffic.ResolvedPortName, traffic.Protocol) {
+			return &AntreaRuleMatch{Policy: a, Rule: rule}
+		}
+	}
+	return nil
+}
+
+// SortAntreaPolicies orders policies as Antrea evaluates them: by tier priority, then by policy priority, with lower
+// numbers first.  Within a priority, ClusterNetworkPolicies come before NetworkPolicies; further ties are broken by
+// name, to at least be deterministic.
+func SortAntreaPolicies(policies []*AntreaPolicy) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		a, b := policies[i], policies[j]
+		if a.TierPriority != b.TierPriority {
+			return a.TierPriority < b.TierPriority
+		}
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if a.IsClusterwide != b.IsClusterwide {
+			return a.IsClusterwide
+		}
+		return a.Name < b.Name
+	})
+}
+
+// BuildAntreaPolicies models clusterPolicies and policies, in order.  Their tiers are looked up in tiers, and among
+// Antrea's static tiers; a policy whose tier isn't found is skipped, since Antrea wouldn't enforce it either.
+func BuildAntreaPolicies(clusterPolicies []*antrea.ClusterNetworkPolicy, policies []*antrea.NetworkPolicy, tiers []*antrea.Tier) []*AntreaPolicy {
+	tierPriorities := map[string]int32{}
+	for name, priority := range antrea.StaticTiers {
+		tierPriorities[name] = priority
+	}
+	for _, tier := range tiers {
+		tierPriorities[tier.Name] = tier.Spec.Priority
+	}
+
+	var antreaPolicies []*AntreaPolicy
+	addPolicy := func(policy *AntreaPolicy, spec antrea.NetworkPolicySpec, namespace string) {
+		if policy.Tier == "" {
+			policy.Tier = antrea.DefaultTierName
+		}
+		tierPriority, ok := tierPriorities[policy.Tier]
+		if !ok {
+			logrus.Warnf("skipping %s: tier %s not found", policy, policy.Tier)
+			return
+		}
+		policy.TierPriority = tierPriority
+		policy.AppliedTo = buildAntreaAppliedTo(policy.Name, namespace, spec.AppliedTo)
+		for i, rule := range spec.Ingress {
+			policy.Ingress = append(policy.Ingress, buildAntreaRule(policy, fmt.Sprintf("ingress rule %d", i+1), namespace, rule, rule.From))
+		}
+		for i, rule := range spec.Egress {
+			policy.Egress = append(policy.Egress, buildAntreaRule(policy, fmt.Sprintf("egress rule %d", i+1), namespace, rule, rule.To))
+		}
+		antreaPolicies = append(antreaPolicies, policy)
+	}
+
+	for _, policy := range clusterPolicies {
+		addPolicy(&AntreaPolicy{Name: policy.Name, IsClusterwide: true, Tier: policy.Spec.Tier, Priority: policy.Spec.Priority}, policy.Spec, "")
+	}
+	for _, policy := range policies {
+		namespace := policy.Namespace
+		if namespace == "" {
+			namespace = v1.NamespaceDefault
+		}
+		addPolicy(&AntreaPolicy{Name: namespace + "/" + policy.Name, Tier: policy.Spec.Tier, Priority: policy.Spec.Priority}, policy.Spec, namespace)
+	}
+	SortAntreaPolicies(antreaPolicies)
+	return antreaPolicies
+}
+
+// buildAntreaSelector matches pods by podSelector and namespaceSelector; either may be nil, in which case it matches
+// all pods, or -- for namespaceSelector -- pods in namespace, or in all namespaces for a ClusterNetworkPolicy
+func buildAntreaSelector(namespace string, podSelector *metav1.LabelSelector, namespaceSelector *metav1.LabelSelector) (NamespaceMatcher, PodMatcher) {
+	var namespaceMatcher NamespaceMatcher
+	if namespaceSelector != nil {
+		namespaceMatcher = buildAdminNamespaceMatcher(*namespaceSelector)
+	} else if namespace != "" {
+		namespaceMatcher = &ExactNamespaceMatcher{Namespace: namespace}
+	} else {
+		namespaceMatcher = &AllNamespaceMatcher{}
+	}
+	podMatcher := PodMatcher(&AllPodMatcher{})
+	if podSelector != nil {
+		podMatcher = buildAdminPodMatcher(*podSelector)
+	}
+	return namespaceMatcher, podMatcher
+}
+
+// buildAntreaAppliedTo: groups, service accounts, services and node selectors aren't modeled, and are skipped
+func buildAntreaAppliedTo(name string, namespace string, appliedTo []antrea.AppliedTo) []*AdminSubject {
+	var subjects []*AdminSubject
+	for _, a := range appliedTo {
+		if a.PodSelector == nil && a.NamespaceSelector == nil {
+			logrus.Warnf("skipping appliedTo of antrea policy %s: only podSelector and namespaceSelector are modeled", name)
+			continue
+		}
+		namespaceMatcher, podMatcher := buildAntreaSelector(namespace, a.PodSelector, a.NamespaceSelector)
+		subjects = append(subjects, &AdminSubject{Namespace: namespaceMatcher, Pod: podMatcher})
+	}
+	return subjects
+}
+
+// buildAntreaRule: a rule without peers matches all peers on its ports.  Groups, FQDNs, service accounts and node
+// selectors aren't modeled, and are skipped; a rule with only those matches nothing.
+func buildAntreaRule(policy *AntreaPolicy, name string, namespace string, rule antrea.Rule, peers []antrea.NetworkPolicyPeer) *AntreaRule {
+	if rule.Name != "" {
+		name = rule.Name
+	}
+	antreaRule := &AntreaRule{
+		Name:      name,
+		Action:    rule.Action,
+		AppliedTo: buildAntreaAppliedTo(policy.Name, namespace, rule.AppliedTo),
+	}
+	if policy.IsBaseline() && rule.Action == antrea.RuleActionPass {
+		logrus.Warnf("skipping %s of %s: %s isn't allowed in the %s tier", name, policy, antrea.RuleActionPass, antrea.BaselineTierName)
+		return antreaRule
+	}
+
+	port := BuildPortMatcher(rule.Ports)
+	if len(peers) == 0 {
+		antreaRule.Peers = []PeerMatcher{&PortsForAllPeersMatcher{Port: port}}
+		return antreaRule
+	}
+	for _, peer := range peers {
+		switch {
+		case peer.IPBlock != nil:
+			antreaRule.Peers = append(antreaRule.Peers, &IPPeerMatcher{IPBlock: &networkingv1.IPBlock{CIDR: peer.IPBlock.CIDR}, Port: port})
+		case peer.Namespaces != nil && peer.Namespaces.Match == antrea.NamespaceMatchSelf:
+			_, podMatcher := buildAntreaSelector(namespace, peer.PodSelector, nil)
+			antreaRule.SameNamespacePeers = append(antreaRule.SameNamespacePeers, &PodPeerMatcher{Pod: podMatcher, Port: port})
+		case peer.PodSelector != nil || peer.NamespaceSelector != nil:
+			namespaceMatcher, podMatcher := buildAntreaSelector(namespace, peer.PodSelector, peer.NamespaceSelector)
+			antreaRule.Peers = append(antreaRule.Peers, &PodPeerMatcher{Namespace: namespaceMatcher, Pod: podMatcher, Port: port})
+		default:
+			logrus.Warnf("skipping peer of %s of %s: only podSelector, namespaceSelector, namespaces and ipBlock are modeled", name, policy)
+		}
+	}
+	return antreaRule
+}
+
+// firstMatchingAntreaRule returns the first matching rule of the Antrea policies in -- or, if not isBaseline, not
+// in -- the baseline tier
+func (p *Policy) firstMatchingAntreaRule(traffic *Traffic, isIngress bool, isBaseline bool) *AntreaRuleMatch {
+	for _, policy := range p.AntreaPolicies {
+		if policy.IsBaseline() != isBaseline {
+			continue
+		}
+		if match := policy.FirstMatchingRule(traffic, isIngress); match != nil {
+			return match
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package matcher
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/antrea"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func RunAntreaPolicyTests() {
+	clusterPolicy := func(serialized string) *antrea.ClusterNetworkPolicy {
+		var policy *antrea.ClusterNetworkPolicy
+		utils.DoOrDie(yaml.Unmarshal([]byte(serialized), &policy))
+		return policy
+	}
+	namespacedPolicy := func(serialized string) *antrea.NetworkPolicy {
+		var policy *antrea.NetworkPolicy
+		utils.DoOrDie(yaml.Unmarshal([]byte(serialized), &policy))
+		return policy
+	}
+
+	dropFromYPodB := clusterPolicy(`
+apiVersion: crd.antrea.io/v1beta1
+kind: ClusterNetworkPolicy
+metadata:
+  name: drop-from-y-b
+spec:
+  tier: securityops
+  priority: 5
+  appliedTo:
+  - namespaceSelector:
+      matchLabels:
+        ns: x
+  ingress:
+  - action: Drop
+    from:
+    - namespaceSelector:
+        matchLabels:
+          ns: "y"
+      podSelector:
+        matchLabels:
+          pod: b
+  - action: Pass
+    from:
+    - namespaces:
+        match: Self
+  - action: Reject
+    ports:
+    - protocol: TCP
+      port: 81`)
+	allowFromY := namespacedPolicy(`
+apiVersion: crd.antrea.io/v1beta1
+kind: NetworkPolicy
+metadata:
+  name: allow-from-y
+  namespace: x
+spec:
+  priority: 1
+  ingress:
+  - name: from-y
+    action: Allow
+    appliedTo:
+    - podSelector:
+        matchLabels:
+          pod: a
+    from:
+    - namespaceSelector:
+        matchLabels:
+          ns: "y"`)
+	baselineDeny := clusterPolicy(`
+apiVersion: crd.antrea.io/v1beta1
+kind: ClusterNetworkPolicy
+metadata:
+  name: baseline-deny
+spec:
+  tier: baseline
+  priority: 1
+  appliedTo:
+  - podSelector: {}
+  ingress:
+  - action: Drop`)
+	unknownTier := clusterPolicy(`
+apiVersion: crd.antrea.io/v1beta1
+kind: ClusterNetworkPolicy
+metadata:
+  name: unknown-tier
+spec:
+  tier: custom
+  priority: 1
+  appliedTo:
+  - podSelector: {}
+  ingress:
+  - action: Drop`)
+
+	traffic := func(sourceNamespace string, sourcePod string, destinationPod string, port int) *Traffic {
+		return &Traffic{
+			Source: &TrafficPeer{
+				Internal: &InternalPeer{PodLabels: map[string]string{"pod": sourcePod}, NamespaceLabels: map[string]string{"ns": sourceNamespace}, Namespace: sourceNamespace},
+				IP:       "1.2.3.4",
+			},
+			Destination: &TrafficPeer{
+				Internal: &InternalPeer{PodLabels: map[string]string{"pod": destinationPod}, NamespaceLabels: map[string]string{"ns": "x"}, Namespace: "x"},
+				IP:       "10.0.0.1",
+			},
+			ResolvedPort: port,
+			Protocol:     v1.ProtocolTCP,
+		}
+	}
+
+	Describe("Antrea policies", func() {
+		It("should evaluate tiers by priority, letting the first matching rule decide", func() {
+			policy := NewPolicy()
+			policy.AddAntreaPolicies(BuildAntreaPolicies([]*antrea.ClusterNetworkPolicy{dropFromYPodB}, []*antrea.NetworkPolicy{allowFromY}, nil))
+			Expect(policy.AntreaPolicies[0].Tier).To(Equal("securityops"))
+			Expect(policy.AntreaPolicies[1].Tier).To(Equal(antrea.DefaultTierName))
+
+			// securityops comes before application
+			result := policy.IsTrafficAllowed(traffic("y", "b", "a", 80))
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.AntreaRule.String()).To(Equal("Antrea ClusterNetworkPolicy drop-from-y-b (tier securityops, priority 5), ingress rule 1: Drop"))
+
+			result = policy.IsTrafficAllowed(traffic("y", "a", "a", 80))
+			Expect(result.IsAllowed()).To(BeTrue())
+			Expect(result.Ingress.AntreaRule.Rule.Name).To(Equal("from-y"))
+
+			// the rule's own appliedTo doesn't apply to pod c
+			result = policy.IsTrafficAllowed(traffic("y", "a", "c", 80))
+			Expect(result.IsAllowed()).To(BeTrue())
+			Expect(result.Ingress.AntreaRule).To(BeNil())
+
+			Expect(policy.TraceTraffic(traffic("z", "a", "a", 81)).Ingress.Decision).To(Equal("rejected by Antrea ClusterNetworkPolicy drop-from-y-b (tier securityops, priority 5), ingress rule 3: Reject"))
+		})
+
+		It("should pass traffic on to NetworkPolicies, and then to the baseline tier", func() {
+			denyAll := &networkingv1.NetworkPolicy{}
+			denyAll.Namespace, denyAll.Name = "x", "deny-all"
+			denyAll.Spec.PodSelector.MatchLabels = map[string]string{"pod": "a"}
+			denyAll.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+			policy := BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{denyAll})
+			policy.AddAntreaPolicies(BuildAntreaPolicies([]*antrea.ClusterNetworkPolicy{dropFromYPodB, baselineDeny}, []*antrea.NetworkPolicy{allowFromY}, nil))
+
+			// passed by the Self rule, before allow-from-y, so the NetworkPolicy decides
+			result := policy.IsTrafficAllowed(traffic("x", "b", "a", 80))
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.AntreaRule.Rule.Action).To(Equal(antrea.RuleActionPass))
+			Expect(result.Ingress.DenyingTargets).To(HaveLen(1))
+			Expect(policy.TraceTraffic(traffic("x", "b", "a", 80)).Ingress.Decision).To(Equal("passed by Antrea ClusterNetworkPolicy drop-from-y-b (tier securityops, priority 5), ingress rule 2: Pass; denied: selected by x/deny-all, but none of their ingress rules match"))
+
+			// no NetworkPolicy selects pod c, so the baseline tier decides
+			result = policy.IsTrafficAllowed(traffic("x", "b", "c", 80))
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.AntreaBaselineRule.Policy.Name).To(Equal("baseline-deny"))
+
+			// egress isn't subject to any policy
+			Expect(result.Egress.IsAllowed()).To(BeTrue())
+		})
+
+		It("should look up tiers, skipping policies whose tier isn't found", func() {
+			Expect(BuildAntreaPolicies([]*antrea.ClusterNetworkPolicy{unknownTier}, nil, nil)).To(BeEmpty())
+
+			custom := &antrea.Tier{Spec: antrea.TierSpec{Priority: 10}}
+			custom.Name = "custom"
+			policies := BuildAntreaPolicies([]*antrea.ClusterNetworkPolicy{dropFromYPodB, unknownTier}, nil, []*antrea.Tier{custom})
+			Expect(policies).To(HaveLen(2))
+			Expect(policies[0].Name).To(Equal("unknown-tier"))
+			Expect(policies[0].TierPriority).To(Equal(int32(10)))
+		})
+	})
+}
@@ -0,0 +1,514 @@
+package matcher
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/calico"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// calicoEndpointLabels are the labels Calico selectors see on a pod: its own, its namespace's name, and its
+// namespace's labels, prefixed with pcns.
+func calicoEndpointLabels(peer *InternalPeer) map[string]string {
+	labels := map[string]string{calico.NamespaceLabel: peer.Namespace}
+	for key, value := range peer.NamespaceLabels {
+		labels[calico.NamespaceLabelsPrefix+key] = value
+	}
+	for key, value := range peer.PodLabels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// calicoNamespaceLabels are the labels Calico namespace selectors see on a namespace: its own, and its name
+func calicoNamespaceLabels(peer *InternalPeer) map[string]string {
+	labels := map[string]string{calico.NamespaceNameLabel: peer.Namespace}
+	for key, value := range peer.NamespaceLabels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// CalicoEntityMatcher matches one end -- source or destination -- of traffic, as a Calico EntityRule does.  Each of
+// its fields which is set must match.
+type CalicoEntityMatcher struct {
+	Nets    []string `json:",omitempty"`
+	NotNets []string `json:",omitempty"`
+	// Selector and NamespaceSelector only match pods; without a NamespaceSelector, Selector only matches pods in
+	// Namespace -- that of a namespaced NetworkPolicy -- if it's set
+	Selector          *CalicoSelector `json:",omitempty"`
+	NotSelector       *CalicoSelector `json:",omitempty"`
+	NamespaceSelector *CalicoSelector `json:",omitempty"`
+	Namespace         string          `json:",omitempty"`
+	// Ports and NotPorts are only checked for destinations
+	Ports    PortMatcher `json:",omitempty"`
+	NotPorts PortMatcher `json:",omitempty"`
+}
+
+func (c *CalicoEntityMatcher) Allows(peer *TrafficPeer, portInt int, portName string, protocol v1.Protocol) bool {
+	if len(c.Nets) > 0 && !isIPInAnyCIDR(peer.IP, c.Nets) {
+		return false
+	}
+	if len(c.NotNets) > 0 && isIPInAnyCIDR(peer.IP, c.NotNets) {
+		return false
+	}
+	if c.Selector != nil || c.NamespaceSelector != nil {
+		if peer.Internal == nil {
+			return false
+		}
+		if c.NamespaceSelector != nil {
+			if !c.NamespaceSelector.Matches(calicoNamespaceLabels(peer.Internal)) {
+				return false
+			}
+		} else if c.Namespace != "" && c.Namespace != peer.Internal.Namespace {
+			return false
+		}
+		if c.Selector != nil && !c.Selector.Matches(calicoEndpointLabels(peer.Internal)) {
+			return false
+		}
+	}
+	if c.NotSelector != nil && peer.Internal != nil && c.NotSelector.Matches(calicoEndpointLabels(peer.Internal)) {
+		return false
+	}
+	if c.Ports != nil && !c.Ports.Allows(portInt, portName, protocol) {
+		return false
+	}
+	return c.NotPorts == nil || !c.NotPorts.Allows(portInt, portName, protocol)
+}
+
+func isIPInAnyCIDR(ip string, cidrs []string) bool {
+	for _, cidr := range cidrs {
+		if isIn, err := kube.IsIPInCIDR(ip, cidr); err == nil && isIn {
+			return true
+		}
+	}
+	return false
+}
+
+// CalicoRule is a rule of a Calico NetworkPolicy or GlobalNetworkPolicy; the first of a policy's rules which matches
+// some traffic -- other than Log rules -- decides it
+type CalicoRule struct {
+	// Name is i.e. "ingress rule 1"
+	Name   string
+	Action calico.Action
+	// Protocol and NotProtocol are protocol names, such as TCP or ICMP; Protocol is empty for any protocol
+	Protocol    string `json:",omitempty"`
+	NotProtocol string `json:",omitempty"`
+	Source      *CalicoEntityMatcher
+	Destination *CalicoEntityMatcher
+}
+
+func (r *CalicoRule) Matches(traffic *Traffic) bool {
+	if r.Protocol != "" && r.Protocol != string(traffic.Protocol) {
+		return false
+	}
+	if r.NotProtocol != "" && r.NotProtocol == string(traffic.Protocol) {
+		return false
+	}
+	return r.Source.Allows(traffic.Source, traffic.ResolvedPort, traffic.ResolvedPortName, traffic.Protocol) &&
+		r.Destination.Allows(traffic.Destination, traffic.ResolvedPort, traffic.ResolvedPortName, traffic.Protocol)
+}
+
+// CalicoPolicy models a Calico NetworkPolicy or GlobalNetworkPolicy
+type CalicoPolicy struct {
+	Name     string
+	IsGlobal bool
+	Order    *float64
+	// Namespace is that of a NetworkPolicy, whose Selector only selects pods in it; it's empty for a
+	// GlobalNetworkPolicy, whose NamespaceSelector -- if it has one -- picks namespaces instead
+	Namespace         string
+	Selector          *CalicoSelector
+	NamespaceSelector *CalicoSelector `json:",omitempty"`
+	// AppliesToIngress and AppliesToEgress are from the policy's types; rules of other directions are dropped
+	AppliesToIngress bool
+	AppliesToEgress  bool
+	Ingress          []*CalicoRule
+	Egress           []*CalicoRule
+}
+
+func (c *CalicoPolicy) String() string {
+	if c.IsGlobal {
+		return fmt.Sprintf("Calico %s %s", calico.GlobalNetworkPolicyKind, c.Name)
+	}
+	return fmt.Sprintf("Calico %s %s", calico.NetworkPolicyKind, c.Name)
+}
+
+// Selects is whether the policy applies to the traffic's target for the direction
+func (c *CalicoPolicy) Selects(traffic *Traffic, isIngress bool) bool {
+	target, appliesToDirection := traffic.Source, c.AppliesToEgress
+	if isIngress {
+		target, appliesToDirection = traffic.Destination, c.AppliesToIngress
+	}
+	if !appliesToDirection || target.Internal == nil {
+		return false
+	}
+	if c.Namespace != "" && c.Namespace != target.Internal.Namespace {
+		return false
+	}
+	if c.NamespaceSelector != nil && !c.NamespaceSelector.Matches(calicoNamespaceLabels(target.Internal)) {
+		return false
+	}
+	return c.Selector.Matches(calicoEndpointLabels(target.Internal))
+}
+
+// FirstMatchingRule returns the policy's first Allow, Deny or Pass rule matching the traffic, or nil if the policy
+// doesn't select the traffic's target or none of its rules match
+func (c *CalicoPolicy) FirstMatchingRule(traffic *Traffic, isIngress bool) *CalicoRuleMatch {
+	if !c.Selects(traffic, isIngress) {
+		return nil
+	}
+	rules := c.Egress
+	if isIngress {
+		rules = c.Ingress
+	}
+	for _, rule := range rules {
+		if rule.Action != calico.ActionLog && rule.Matches(traffic) {
+			return &CalicoRuleMatch{Policy: c, Rule: rule}
+		}
+	}
+	return nil
+}
+
+// CalicoRuleMatch is a CalicoPolicy rule which matched some traffic
+type CalicoRuleMatch struct {
+	Policy *CalicoPolicy
+	Rule   *CalicoRule
+}
+
+func (c *CalicoRuleMatch) String() string {
+	return fmt.Sprintf("%s, %s: %s", c.Policy, c.Rule.Name, c.Rule.Action)
+}
+
+// CalicoTier is a group of Calico policies, in order.  If a tier's policies select a pod but none of their rules
+// match its traffic, its DefaultAction -- Deny, unless it's Pass -- decides the traffic.
+type CalicoTier struct {
+	Name          string
+	Order         *float64
+	DefaultAction calico.Action
+	Policies      []*CalicoPolicy
+}
+
+func (c *CalicoTier) String() string {
+	return fmt.Sprintf("Calico tier %s", c.Name)
+}
+
+// isCalicoOrderBefore sorts Calico orders, lowest first; an order which isn't set comes last
+func isCalicoOrderBefore(a *float64, b *float64) bool {
+	if a == nil || b == nil {
+		return a != nil && b == nil
+	}
+	return *a < *b
+}
+
+// SortCalicoTiers sorts tiers, and the policies in each, by order; ties are broken by name, as Calico does
+func SortCalicoTiers(tiers []*CalicoTier) {
+	sort.SliceStable(tiers, func(i, j int) bool {
+		if isCalicoOrderBefore(tiers[i].Order, tiers[j].Order) || isCalicoOrderBefore(tiers[j].Order, tiers[i].Order) {
+			return isCalicoOrderBefore(tiers[i].Order, tiers[j].Order)
+		}
+		return tiers[i].Name < tiers[j].Name
+	})
+	for _, tier := range tiers {
+		policies := tier.Policies
+		sort.SliceStable(policies, func(i, j int) bool {
+			if isCalicoOrderBefore(policies[i].Order, policies[j].Order) || isCalicoOrderBefore(policies[j].Order, policies[i].Order) {
+				return isCalicoOrderBefore(policies[i].Order, policies[j].Order)
+			}
+			return policies[i].Name < policies[j].Name
+		})
+	}
+}
+
+// BuildCalicoTiers models policies, grouped into their tiers.  The default tier is always included, since
+// NetworkPolicies are evaluated in it; tiers which policies name, but which aren't in tiers, come last.
+func BuildCalicoTiers(policies []*calico.NetworkPolicy, globalPolicies []*calico.GlobalNetworkPolicy, tiers []*calico.Tier) ([]*CalicoTier, error) {
+	defaultOrder := float64(calico.DefaultTierOrder)
+	tiersByName := map[string]*CalicoTier{
+		calico.DefaultTierName: {Name: calico.DefaultTierName, Order: &defaultOrder, DefaultAction: calico.ActionDeny},
+	}
+	for _, tier := range tiers {
+		defaultAction := tier.Spec.DefaultAction
+		if defaultAction == "" {
+			defaultAction = calico.ActionDeny
+		}
+		tiersByName[tier.Name] = &CalicoTier{Name: tier.Name, Order: tier.Spec.Order, DefaultAction: defaultAction}
+	}
+	addPolicy := func(tierName string, policy *CalicoPolicy) {
+		if tierName == "" {
+			tierName = calico.DefaultTierName
+		}
+		tier, ok := tiersByName[tierName]
+		if !ok {
+			logrus.Warnf("calico tier %s of %s not found; evaluating it after all other tiers", tierName, policy)
+			tier = &CalicoTier{Name: tierName, DefaultAction: calico.ActionDeny}
+			tiersByName[tierName] = tier
+		}
+		tier.Policies = append(tier.Policies, policy)
+	}
+
+	for _, policy := range policies {
+		namespace := policy.Namespace
+		if namespace == "" {
+			namespace = v1.NamespaceDefault
+		}
+		calicoPolicy, err := buildCalicoPolicy(namespace+"/"+policy.Name, false, namespace, policy.Spec)
+		if err != nil {
+			return nil, err
+		}
+		addPolicy(policy.Spec.Tier, calicoPolicy)
+	}
+	for _, policy := range globalPolicies {
+		calicoPolicy, err := buildCalicoPolicy(policy.Name, true, "", policy.Spec)
+		if err != nil {
+			return nil, err
+		}
+		addPolicy(policy.Spec.Tier, calicoPolicy)
+	}
+
+	var calicoTiers []*CalicoTier
+	for _, tier := range tiersByName {
+		calicoTiers = append(calicoTiers, tier)
+	}
+	SortCalicoTiers(calicoTiers)
+	return calicoTiers, nil
+}
+
+func parseOptionalCalicoSelector(expression string) (*CalicoSelector, error) {
+	if expression == "" {
+		return nil, nil
+	}
+	return ParseCalicoSelector(expression)
+}
+
+func buildCalicoPolicy(name string, isGlobal bool, namespace string, spec calico.PolicySpec) (*CalicoPolicy, error) {
+	policy := &CalicoPolicy{Name: name, IsGlobal: isGlobal, Order: spec.Order, Namespace: namespace}
+	var err error
+	if policy.Selector, err = ParseCalicoSelector(spec.Selector); err != nil {
+		return nil, errors.Wrapf(err, "unable to build calico policy %s", name)
+	}
+	if policy.NamespaceSelector, err = parseOptionalCalicoSelector(spec.NamespaceSelector); err != nil {
+		return nil, errors.Wrapf(err, "unable to build calico policy %s", name)
+	}
+	for _, policyType := range spec.PolicyTypes() {
+		switch policyType {
+		case calico.PolicyTypeIngress:
+			policy.AppliesToIngress = true
+		case calico.PolicyTypeEgress:
+			policy.AppliesToEgress = true
+		}
+	}
+	if policy.AppliesToIngress {
+		for i, rule := range spec.Ingress {
+			calicoRule, err := buildCalicoRule(fmt.Sprintf("ingress rule %d", i+1), namespace, rule)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to build calico policy %s", name)
+			}
+			policy.Ingress = append(policy.Ingress, calicoRule)
+		}
+	}
+	if policy.AppliesToEgress {
+		for i, rule := range spec.Egress {
+			calicoRule, err := buildCalicoRule(fmt.Sprintf("egress rule %d", i+1), namespace, rule)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to build calico policy %s", name)
+			}
+			policy.Egress = append(policy.Egress, calicoRule)
+		}
+	}
+	return policy, nil
+}
+
+func buildCalicoRule(name string, namespace string, rule calico.Rule) (*CalicoRule, error) {
+	calicoRule := &CalicoRule{
+		Name:        name,
+		Action:      rule.Action,
+		Protocol:    calicoProtocolName(rule.Protocol),
+		NotProtocol: calicoProtocolName(rule.NotProtocol),
+	}
+	switch rule.Action {
+	case calico.ActionAllow, calico.ActionDeny, calico.ActionLog, calico.ActionPass:
+	default:
+		return nil, errors.Errorf("%s has invalid action '%s'", name, rule.Action)
+	}
+	if len(rule.Source.Ports) > 0 || len(rule.Source.NotPorts) > 0 {
+		logrus.Warnf("ignoring source ports of calico %s: only destination ports are modeled", name)
+	}
+	var err error
+	if calicoRule.Source, err = buildCalicoEntityMatcher(name, namespace, calicoRule.Protocol, rule.Source, false); err != nil {
+		return nil, err
+	}
+	if calicoRule.Destination, err = buildCalicoEntityMatcher(name, namespace, calicoRule.Protocol, rule.Destination, true); err != nil {
+		return nil, err
+	}
+	return calicoRule, nil
+}
+
+func buildCalicoEntityMatcher(name string, namespace string, protocol string, entity calico.EntityRule, isDestination bool) (*CalicoEntityMatcher, error) {
+	if entity.ServiceAccounts != nil || entity.Services != nil {
+		logrus.Warnf("ignoring service accounts and services of calico %s: they aren't modeled", name)
+	}
+	matcher := &CalicoEntityMatcher{Nets: entity.Nets, NotNets: entity.NotNets, Namespace: namespace}
+	var err error
+	if matcher.Selector, err = parseOptionalCalicoSelector(entity.Selector); err != nil {
+		return nil, errors.Wrapf(err, "invalid selector of %s", name)
+	}
+	if matcher.NotSelector, err = parseOptionalCalicoSelector(entity.NotSelector); err != nil {
+		return nil, errors.Wrapf(err, "invalid notSelector of %s", name)
+	}
+	if matcher.NamespaceSelector, err = parseOptionalCalicoSelector(entity.NamespaceSelector); err != nil {
+		return nil, errors.Wrapf(err, "invalid namespaceSelector of %s", name)
+	}
+	if isDestination {
+		if matcher.Ports, err = buildCalicoPortMatcher(protocol, entity.Ports); err != nil {
+			return nil, errors.Wrapf(err, "invalid ports of %s", name)
+		}
+		if matcher.NotPorts, err = buildCalicoPortMatcher(protocol, entity.NotPorts); err != nil {
+			return nil, errors.Wrapf(err, "invalid notPorts of %s", name)
+		}
+	}
+	return matcher, nil
+}
+
+// calicoProtocolNumbers names the protocols Calico rules may give by number
+var calicoProtocolNumbers = map[int]string{
+	1:   "ICMP",
+	6:   string(v1.ProtocolTCP),
+	17:  string(v1.ProtocolUDP),
+	58:  "ICMPv6",
+	132: string(v1.ProtocolSCTP),
+	136: "UDPLite",
+}
+
+// calicoProtocolName normalizes a Calico protocol, which may be a number, or a name in any case
+func calicoProtocolName(protocol *intstr.IntOrString) string {
+	if protocol == nil {
+		return ""
+	}
+	if protocol.Type == intstr.Int {
+		if name, ok := calicoProtocolNumbers[protocol.IntValue()]; ok {
+			return name
+		}
+		return protocol.String()
+	}
+	for _, name := range calicoProtocolNumbers {
+		if strings.EqualFold(name, protocol.StrVal) {
+			return name
+		}
+	}
+	return protocol.StrVal
+}
+
+// buildCalicoPortMatcher translates Calico ports -- numbers, "min:max" ranges and names -- into NetworkPolicy ports
+// of protocol, or of TCP, UDP and SCTP if it's empty; it returns nil if there are no ports
+func buildCalicoPortMatcher(protocol string, ports []intstr.IntOrString) (PortMatcher, error) {
+	if len(ports) == 0 {
+		return nil, nil
+	}
+	protocols := []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP, v1.ProtocolSCTP}
+	if protocol != "" {
+		protocols = []v1.Protocol{v1.Protocol(protocol)}
+	}
+	var npPorts []networkingv1.NetworkPolicyPort
+	for _, port := range ports {
+		for _, protocol := range protocols {
+			protocol := protocol
+			npPort := networkingv1.NetworkPolicyPort{Protocol: &protocol}
+			if port.Type == intstr.Int {
+				numbered := port
+				npPort.Port = &numbered
+			} else if bounds := strings.Split(port.StrVal, ":"); len(bounds) == 2 {
+				from, fromErr := strconv.Atoi(bounds[0])
+				to, toErr := strconv.Atoi(bounds[1])
+				if fromErr != nil || toErr != nil || from > to {
+					return nil, errors.Errorf("invalid port range '%s'", port.StrVal)
+				}
+				fromPort := intstr.FromInt(from)
+				endPort := int32(to)
+				npPort.Port, npPort.EndPort = &fromPort, &endPort
+			} else if number, err := strconv.Atoi(port.StrVal); err == nil {
+				numbered := intstr.FromInt(number)
+				npPort.Port = &numbered
+			} else {
+				named := port
+				npPort.Port = &named
+			}
+			npPorts = append(npPorts, npPort)
+		}
+	}
+	return BuildPortMatcher(npPorts), nil
+}
+
+// calicoDirectionResult evaluates Calico tiers in order, for traffic which no AdminNetworkPolicy decided.  In each,
+// the first matching Allow or Deny rule, of the policies selecting the traffic's target, decides the traffic; a Pass
+// rule skips to the next tier.  NetworkPolicies -- matchingTargets -- are evaluated in the default tier, as if they
+// had order 1000.  If a tier's policies select the target, but none of their rules match, its default action
+// applies.  Traffic which no tier decides is left to the baseline policies.
+func (p *Policy) calicoDirectionResult(traffic *Traffic, isIngress bool, adminRule *AdminRuleMatch, matchingTargets []*Target) *DirectionResult {
+	peer := traffic.Destination
+	if isIngress {
+		peer = traffic.Source
+	}
+	npOrder := float64(calico.KubernetesNetworkPolicyOrder)
+	var passingRules []*CalicoRuleMatch
+	for _, tier := range p.CalicoTiers {
+		isDefaultTier := tier.Name == calico.DefaultTierName
+		targetsEvaluated := false
+		var selecting []*CalicoPolicy
+		var deniers []*Target
+		// evaluateTargets returns a result if NetworkPolicies allow the traffic
+		evaluateTargets := func() *DirectionResult {
+			targetsEvaluated = true
+			var allowers []*Target
+			allowers, deniers = targetsAllowing(matchingTargets, peer, traffic)
+			if len(allowers) > 0 {
+				return &DirectionResult{AllowingTargets: allowers, DenyingTargets: deniers, AdminRule: adminRule, CalicoPassingRules: passingRules}
+			}
+			return nil
+		}
+
+		var passingRule *CalicoRuleMatch
+		for _, policy := range tier.Policies {
+			if isDefaultTier && !targetsEvaluated && isCalicoOrderBefore(&npOrder, policy.Order) {
+				if result := evaluateTargets(); result != nil {
+					return result
+				}
+			}
+			if !policy.Selects(traffic, isIngress) {
+				continue
+			}
+			selecting = append(selecting, policy)
+			match := policy.FirstMatchingRule(traffic, isIngress)
+			if match == nil {
+				continue
+			}
+			if match.Rule.Action == calico.ActionPass {
+				passingRule = match
+				break
+			}
+			return &DirectionResult{AdminRule: adminRule, CalicoRule: match, CalicoPassingRules: passingRules}
+		}
+		if passingRule != nil {
+			passingRules = append(passingRules, passingRule)
+			continue
+		}
+		if isDefaultTier && !targetsEvaluated {
+			if result := evaluateTargets(); result != nil {
+				return result
+			}
+		}
+		if (len(selecting) > 0 || len(deniers) > 0) && tier.DefaultAction != calico.ActionPass {
+			return &DirectionResult{DenyingTargets: deniers, AdminRule: adminRule, CalicoPassingRules: passingRules, CalicoDenyingTier: tier, CalicoDenyingPolicies: selecting}
+		}
+	}
+
+	result := p.baselineDirectionResult(traffic, isIngress, adminRule, nil)
+	result.CalicoPassingRules = passingRules
+	return result
+}
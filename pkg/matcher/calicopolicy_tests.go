@@ -0,0 +1,194 @@
+package matcher
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/calico"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+func RunCalicoPolicyTests() {
+	calicoPolicy := func(serialized string) *calico.NetworkPolicy {
+		var policy *calico.NetworkPolicy
+		utils.DoOrDie(yaml.Unmarshal([]byte(serialized), &policy))
+		return policy
+	}
+	globalPolicy := func(serialized string) *calico.GlobalNetworkPolicy {
+		var policy *calico.GlobalNetworkPolicy
+		utils.DoOrDie(yaml.Unmarshal([]byte(serialized), &policy))
+		return policy
+	}
+	buildTiers := func(policies []*calico.NetworkPolicy, globalPolicies []*calico.GlobalNetworkPolicy, tiers []*calico.Tier) []*CalicoTier {
+		calicoTiers, err := BuildCalicoTiers(policies, globalPolicies, tiers)
+		Expect(err).To(Succeed())
+		return calicoTiers
+	}
+
+	denyFromYPodB := calicoPolicy(`
+apiVersion: projectcalico.org/v3
+kind: NetworkPolicy
+metadata:
+  name: deny-from-y-b
+  namespace: x
+spec:
+  order: 10
+  selector: all()
+  ingress:
+  - action: Deny
+    source:
+      namespaceSelector: projectcalico.org/name == 'y'
+      selector: pod == 'b'
+  - action: Allow
+    protocol: TCP
+    source:
+      namespaceSelector: ns == 'y'
+    destination:
+      ports: [80, "8000:8080"]`)
+	passFromZ := globalPolicy(`
+apiVersion: projectcalico.org/v3
+kind: GlobalNetworkPolicy
+metadata:
+  name: security.pass-from-z
+spec:
+  tier: security
+  order: 1
+  selector: pcns.ns == 'x'
+  types: [Ingress]
+  ingress:
+  - action: Log
+  - action: Pass
+    source:
+      selector: projectcalico.org/namespace == 'z'
+  - action: Deny
+    source:
+      nets: [1.2.3.0/24]`)
+	securityOrder := float64(100)
+	security := &calico.Tier{Spec: calico.TierSpec{Order: &securityOrder}}
+	security.Name = "security"
+
+	traffic := func(sourceNamespace string, sourcePod string, sourceIP string, destinationPod string, port int) *Traffic {
+		return &Traffic{
+			Source: &TrafficPeer{
+				Internal: &InternalPeer{PodLabels: map[string]string{"pod": sourcePod}, NamespaceLabels: map[string]string{"ns": sourceNamespace}, Namespace: sourceNamespace},
+				IP:       sourceIP,
+			},
+			Destination: &TrafficPeer{
+				Internal: &InternalPeer{PodLabels: map[string]string{"pod": destinationPod}, NamespaceLabels: map[string]string{"ns": "x"}, Namespace: "x"},
+				IP:       "10.0.0.1",
+			},
+			ResolvedPort: port,
+			Protocol:     v1.ProtocolTCP,
+		}
+	}
+
+	Describe("Calico selectors", func() {
+		It("should parse and evaluate operators", func() {
+			labels := map[string]string{"app": "web", "tier": "frontend-1"}
+			for expression, expected := range map[string]bool{
+				"":                                   true,
+				"all()":                              true,
+				"global()":                           false,
+				"app == 'web'":                       true,
+				"app != 'web'":                       false,
+				"missing != 'web'":                   true,
+				"has(tier) && !has(missing)":         true,
+				"app in {'api', 'web'}":              true,
+				"app not in {'api', \"web\"}":        false,
+				"tier starts with 'front'":           true,
+				"tier ends with '-2' || app == 'db'": false,
+				"tier contains 'end' && (app == 'db' || app == 'web')": true,
+			} {
+				selector, err := ParseCalicoSelector(expression)
+				Expect(err).To(Succeed())
+				Expect(selector.Matches(labels)).To(Equal(expected), expression)
+			}
+		})
+
+		It("should reject invalid expressions", func() {
+			for _, expression := range []string{"app ==", "app = 'web'", "has(app", "app == 'web' &&", "app in {'a' 'b'}", "'web'"} {
+				_, err := ParseCalicoSelector(expression)
+				Expect(err).ToNot(Succeed(), expression)
+			}
+		})
+	})
+
+	Describe("Calico policies", func() {
+		It("should let the first matching rule decide, and deny unmatched traffic at the end of the tier", func() {
+			policy := NewPolicy()
+			policy.AddCalicoTiers(buildTiers([]*calico.NetworkPolicy{denyFromYPodB}, nil, nil))
+
+			result := policy.IsTrafficAllowed(traffic("y", "a", "1.2.4.1", "a", 80))
+			Expect(result.IsAllowed()).To(BeTrue())
+			Expect(result.Ingress.CalicoRule.String()).To(Equal("Calico NetworkPolicy x/deny-from-y-b, ingress rule 2: Allow"))
+			Expect(policy.IsTrafficAllowed(traffic("y", "a", "1.2.4.1", "a", 8080)).IsAllowed()).To(BeTrue())
+
+			result = policy.IsTrafficAllowed(traffic("y", "b", "1.2.4.1", "a", 80))
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.CalicoRule.Rule.Action).To(Equal(calico.ActionDeny))
+
+			result = policy.IsTrafficAllowed(traffic("y", "a", "1.2.4.1", "a", 81))
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.CalicoDenyingTier.Name).To(Equal(calico.DefaultTierName))
+			Expect(policy.TraceTraffic(traffic("y", "a", "1.2.4.1", "a", 81)).Ingress.Decision).To(Equal("denied at the end of Calico tier default: selected by Calico NetworkPolicy x/deny-from-y-b, but none of their ingress rules match"))
+
+			// egress isn't isolated, since the policy's types default to Ingress
+			Expect(result.Egress.IsAllowed()).To(BeTrue())
+		})
+
+		It("should evaluate NetworkPolicies in the default tier, after policies with lower orders", func() {
+			allowAll := &networkingv1.NetworkPolicy{}
+			allowAll.Namespace, allowAll.Name = "x", "allow-all"
+			allowAll.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+			allowAll.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{}}
+			policy := BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{allowAll})
+			policy.AddCalicoTiers(buildTiers([]*calico.NetworkPolicy{denyFromYPodB}, nil, nil))
+
+			// order 10 comes before NetworkPolicies' 1000
+			Expect(policy.IsTrafficAllowed(traffic("y", "b", "1.2.4.1", "a", 80)).IsAllowed()).To(BeFalse())
+			result := policy.IsTrafficAllowed(traffic("y", "a", "1.2.4.1", "a", 81))
+			Expect(result.IsAllowed()).To(BeTrue())
+			Expect(result.Ingress.AllowingTargets).To(HaveLen(1))
+		})
+
+		It("should evaluate tiers in order, passing traffic on to the next tier", func() {
+			policy := NewPolicy()
+			policy.AddCalicoTiers(buildTiers([]*calico.NetworkPolicy{denyFromYPodB}, []*calico.GlobalNetworkPolicy{passFromZ}, []*calico.Tier{security}))
+			Expect(policy.CalicoTiers[0].Name).To(Equal("security"))
+
+			// denied by the security tier, before the default tier's allow
+			result := policy.IsTrafficAllowed(traffic("y", "a", "1.2.3.4", "a", 80))
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.CalicoRule.String()).To(Equal("Calico GlobalNetworkPolicy security.pass-from-z, ingress rule 3: Deny"))
+
+			// passed on to the default tier, which doesn't allow z
+			result = policy.IsTrafficAllowed(traffic("z", "a", "1.2.3.4", "a", 80))
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.CalicoPassingRules).To(HaveLen(1))
+			Expect(result.Ingress.CalicoDenyingTier.Name).To(Equal(calico.DefaultTierName))
+
+			// unmatched by the security tier, which denies it
+			result = policy.IsTrafficAllowed(traffic("y", "a", "1.2.4.1", "a", 80))
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.CalicoDenyingTier.Name).To(Equal("security"))
+		})
+
+		It("should translate ports, port ranges and protocol numbers", func() {
+			Expect(calicoProtocolName(&intstr.IntOrString{Type: intstr.Int, IntVal: 17})).To(Equal("UDP"))
+			Expect(calicoProtocolName(&intstr.IntOrString{Type: intstr.String, StrVal: "sctp"})).To(Equal("SCTP"))
+
+			port, err := buildCalicoPortMatcher("", []intstr.IntOrString{intstr.FromInt(53), intstr.FromString("1000:1002"), intstr.FromString("http")})
+			Expect(err).To(Succeed())
+			Expect(port.Allows(53, "", v1.ProtocolUDP)).To(BeTrue())
+			Expect(port.Allows(1002, "", v1.ProtocolTCP)).To(BeTrue())
+			Expect(port.Allows(1003, "", v1.ProtocolTCP)).To(BeFalse())
+			Expect(port.Allows(8080, "http", v1.ProtocolSCTP)).To(BeTrue())
+
+			_, err = buildCalicoPortMatcher("TCP", []intstr.IntOrString{intstr.FromString("9:1")})
+			Expect(err).ToNot(Succeed())
+		})
+	})
+}
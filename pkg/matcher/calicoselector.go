@@ -0,0 +1,290 @@
+package matcher
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strings"
+	"unicode"
+)
+
+// CalicoSelector is a parsed Calico selector expression, such as "app == 'web' && !has(canary)".  It supports
+// all(), global(), has(k), ==, !=, in, not in, contains, starts with, ends with, !, && and ||, and parentheses.
+type CalicoSelector struct {
+	Expression string
+	matches    func(labels map[string]string) bool
+}
+
+// ParseCalicoSelector parses expression; an empty expression selects everything, like all()
+func ParseCalicoSelector(expression string) (*CalicoSelector, error) {
+	if strings.TrimSpace(expression) == "" {
+		return &CalicoSelector{Expression: "all()", matches: func(map[string]string) bool { return true }}, nil
+	}
+	tokens, err := tokenizeCalicoSelector(expression)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse calico selector '%s'", expression)
+	}
+	parser := &calicoSelectorParser{tokens: tokens}
+	matches, err := parser.parseOr()
+	if err == nil && parser.position < len(tokens) {
+		err = errors.Errorf("unexpected '%s'", tokens[parser.position].text)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse calico selector '%s'", expression)
+	}
+	return &CalicoSelector{Expression: expression, matches: matches}, nil
+}
+
+func (c *CalicoSelector) Matches(labels map[string]string) bool {
+	return c.matches(labels)
+}
+
+func (c *CalicoSelector) MarshalJSON() (b []byte, e error) {
+	return json.Marshal(c.Expression)
+}
+
+var calicoSelectorOperators = map[string]bool{"==": true, "!=": true, "&&": true, "||": true}
+
+type calicoSelectorToken struct {
+	// text is a string's contents, without quotes
+	text     string
+	isString bool
+}
+
+func tokenizeCalicoSelector(expression string) ([]calicoSelectorToken, error) {
+	var tokens []calicoSelectorToken
+	runes := []rune(expression)
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-./", r)
+	}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, calicoSelectorToken{text: string(runes[i+1 : end]), isString: true})
+			i = end + 1
+		case i+1 < len(runes) && calicoSelectorOperators[string(runes[i:i+2])]:
+			tokens = append(tokens, calicoSelectorToken{text: string(runes[i : i+2])})
+			i += 2
+		case strings.ContainsRune("!(){},", r):
+			tokens = append(tokens, calicoSelectorToken{text: string(r)})
+			i++
+		case isWordRune(r):
+			end := i
+			for end < len(runes) && isWordRune(runes[end]) {
+				end++
+			}
+			tokens = append(tokens, calicoSelectorToken{text: string(runes[i:end])})
+			i = end
+		default:
+			return nil, errors.Errorf("unexpected character '%c' at position %d", r, i)
+		}
+	}
+	return tokens, nil
+}
+
+type calicoSelectorParser struct {
+	tokens   []calicoSelectorToken
+	position int
+}
+
+func (p *calicoSelectorParser) peek() string {
+	if p.position >= len(p.tokens) || p.tokens[p.position].isString {
+		return ""
+	}
+	return p.tokens[p.position].text
+}
+
+func (p *calicoSelectorParser) expect(text string) error {
+	if p.peek() != text {
+		if p.position >= len(p.tokens) {
+			return errors.Errorf("expected '%s', found end of selector", text)
+		}
+		return errors.Errorf("expected '%s', found '%s'", text, p.tokens[p.position].text)
+	}
+	p.position++
+	return nil
+}
+
+func (p *calicoSelectorParser) expectString() (string, error) {
+	if p.position >= len(p.tokens) || !p.tokens[p.position].isString {
+		return "", errors.Errorf("expected a quoted string")
+	}
+	p.position++
+	return p.tokens[p.position-1].text, nil
+}
+
+func (p *calicoSelectorParser) parseOr() (func(map[string]string) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.position++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(labels map[string]string) bool { return l(labels) || right(labels) }
+	}
+	return left, nil
+}
+
+func (p *calicoSelectorParser) parseAnd() (func(map[string]string) bool, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.position++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(labels map[string]string) bool { return l(labels) && right(labels) }
+	}
+	return left, nil
+}
+
+func (p *calicoSelectorParser) parseNot() (func(map[string]string) bool, error) {
+	if p.peek() != "!" {
+		return p.parseTerm()
+	}
+	p.position++
+	inner, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return func(labels map[string]string) bool { return !inner(labels) }, nil
+}
+
+func (p *calicoSelectorParser) parseTerm() (func(map[string]string) bool, error) {
+	word := p.peek()
+	switch word {
+	case "":
+		if p.position >= len(p.tokens) {
+			return nil, errors.Errorf("unexpected end of selector")
+		}
+		return nil, errors.Errorf("unexpected string '%s'", p.tokens[p.position].text)
+	case "(":
+		p.position++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case "all", "global":
+		p.position++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		// global() selects endpoints which aren't namespaced, such as host endpoints, which aren't modeled
+		isAll := word == "all"
+		return func(map[string]string) bool { return isAll }, p.expect(")")
+	case "has":
+		p.position++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		key := p.peek()
+		if key == "" || strings.ContainsAny(key, "!(){},") {
+			return nil, errors.Errorf("expected a label key in has()")
+		}
+		p.position++
+		return func(labels map[string]string) bool {
+			_, ok := labels[key]
+			return ok
+		}, p.expect(")")
+	case "!", ")", "{", "}", ",", "==", "!=", "&&", "||":
+		return nil, errors.Errorf("unexpected '%s'", word)
+	}
+
+	key := word
+	p.position++
+	operator := p.peek()
+	p.position++
+	switch operator {
+	case "==", "!=":
+		value, err := p.expectString()
+		if err != nil {
+			return nil, err
+		}
+		isEqual := operator == "=="
+		return func(labels map[string]string) bool {
+			actual, ok := labels[key]
+			return (ok && actual == value) == isEqual
+		}, nil
+	case "in", "not":
+		if operator == "not" {
+			if err := p.expect("in"); err != nil {
+				return nil, err
+			}
+		}
+		values, err := p.parseSet()
+		if err != nil {
+			return nil, err
+		}
+		isIn := operator == "in"
+		return func(labels map[string]string) bool {
+			actual, ok := labels[key]
+			return (ok && values[actual]) == isIn
+		}, nil
+	case "contains":
+		value, err := p.expectString()
+		if err != nil {
+			return nil, err
+		}
+		return func(labels map[string]string) bool {
+			actual, ok := labels[key]
+			return ok && strings.Contains(actual, value)
+		}, nil
+	case "starts", "ends":
+		if err := p.expect("with"); err != nil {
+			return nil, err
+		}
+		value, err := p.expectString()
+		if err != nil {
+			return nil, err
+		}
+		hasAffix := strings.HasPrefix
+		if operator == "ends" {
+			hasAffix = strings.HasSuffix
+		}
+		return func(labels map[string]string) bool {
+			actual, ok := labels[key]
+			return ok && hasAffix(actual, value)
+		}, nil
+	}
+	return nil, errors.Errorf("expected an operator after label key '%s'", key)
+}
+
+func (p *calicoSelectorParser) parseSet() (map[string]bool, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	values := map[string]bool{}
+	for p.peek() != "}" {
+		value, err := p.expectString()
+		if err != nil {
+			return nil, err
+		}
+		values[value] = true
+		if p.peek() == "," {
+			p.position++
+		} else if p.peek() != "}" {
+			return nil, errors.Errorf("expected ',' or '}' in set")
+		}
+	}
+	p.position++
+	return values, nil
+}
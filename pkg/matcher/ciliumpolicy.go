@@ -0,0 +1,305 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/cilium"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"strconv"
+	"strings"
+)
+
+// CiliumRule is an allowing or denying L3/L4 rule of a CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy
+type CiliumRule struct {
+	// Name is i.e. "ingress rule 1" or "egressDeny rule 2"
+	Name  string
+	Deny  bool
+	Peers []PeerMatcher
+}
+
+func (r *CiliumRule) Matches(peer *TrafficPeer, portInt int, portName string, protocol v1.Protocol) bool {
+	for _, peerMatcher := range r.Peers {
+		if peerMatcher.Allows(peer, portInt, portName, protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// CiliumPolicy models one of the rules of a CiliumNetworkPolicy or CiliumClusterwideNetworkPolicy.  Unlike
+// NetworkPolicies, they may deny traffic: a matching deny rule overrides every allowing rule, of any policy.
+type CiliumPolicy struct {
+	Name          string
+	IsClusterwide bool
+	Subject       *AdminSubject
+	// Ingress and Egress are the allowing and denying rules; if there are any, the policy isolates the pods it
+	// selects for that direction
+	Ingress []*CiliumRule
+	Egress  []*CiliumRule
+}
+
+func (c *CiliumPolicy) String() string {
+	if c.IsClusterwide {
+		return fmt.Sprintf("%s %s", cilium.CiliumClusterwideNetworkPolicyKind, c.Name)
+	}
+	return fmt.Sprintf("%s %s", cilium.CiliumNetworkPolicyKind, c.Name)
+}
+
+// CiliumRuleMatch is a CiliumPolicy rule which matched some traffic
+type CiliumRuleMatch struct {
+	Policy *CiliumPolicy
+	Rule   *CiliumRule
+}
+
+func (c *CiliumRuleMatch) String() string {
+	return fmt.Sprintf("%s, %s", c.Policy, c.Rule.Name)
+}
+
+// Selects is whether the policy isolates the traffic's target for the direction
+func (c *CiliumPolicy) Selects(traffic *Traffic, isIngress bool) bool {
+	target, rules := traffic.Source, c.Egress
+	if isIngress {
+		target, rules = traffic.Destination, c.Ingress
+	}
+	return len(rules) > 0 && target.Internal != nil && c.Subject.IsMatch(target.Internal.Namespace, target.Internal.NamespaceLabels, target.Internal.PodLabels)
+}
+
+// FirstMatchingRule returns the policy's first deny -- or allow -- rule matching the traffic, or nil if the policy
+// doesn't select the traffic's target or none of those rules match
+func (c *CiliumPolicy) FirstMatchingRule(traffic *Traffic, isIngress bool, deny bool) *CiliumRuleMatch {
+	if !c.Selects(traffic, isIngress) {
+		return nil
+	}
+	peer, rules := traffic.Destination, c.Egress
+	if isIngress {
+		peer, rules = traffic.Source, c.Ingress
+	}
+	for _, rule := range rules {
+		if rule.Deny == deny && rule.Matches(peer, traffic.ResolvedPort, traffic.ResolvedPortName, traffic.Protocol) {
+			return &CiliumRuleMatch{Policy: c, Rule: rule}
+		}
+	}
+	return nil
+}
+
+// WorldPeerMatcher matches peers outside the cluster: Cilium's world entity
+type WorldPeerMatcher struct {
+	Port PortMatcher
+}
+
+func (w *WorldPeerMatcher) Allows(peer *TrafficPeer, portInt int, portName string, protocol v1.Protocol) bool {
+	return peer.IsExternal() && w.Port.Allows(portInt, portName, protocol)
+}
+
+func (w *WorldPeerMatcher) MarshalJSON() (b []byte, e error) {
+	return json.Marshal(map[string]interface{}{
+		"Type": "all peers outside the cluster",
+		"Port": w.Port,
+	})
+}
+
+// CiliumNamespaceMatcher matches namespaces by name, as Cilium endpoint selectors do with the
+// io.kubernetes.pod.namespace label, and by label
+type CiliumNamespaceMatcher struct {
+	// Names selects the namespace label cilium.NamespaceLabel, whose value is the namespace's name
+	Names    metav1.LabelSelector
+	Selector metav1.LabelSelector
+}
+
+func (c *CiliumNamespaceMatcher) Allows(namespace string, namespaceLabels map[string]string) bool {
+	return kube.IsLabelsMatchLabelSelector(map[string]string{cilium.NamespaceLabel: namespace}, c.Names) &&
+		kube.IsLabelsMatchLabelSelector(namespaceLabels, c.Selector)
+}
+
+func (c *CiliumNamespaceMatcher) MarshalJSON() (b []byte, e error) {
+	return json.Marshal(map[string]interface{}{
+		"Type":     "matching namespace by name and label",
+		"Names":    c.Names,
+		"Selector": c.Selector,
+	})
+}
+
+func (c *CiliumNamespaceMatcher) PrimaryKey() string {
+	return fmt.Sprintf(`{"type": "cilium-namespace", "names": "%s", "selector": "%s"}`, kube.SerializeLabelSelector(c.Names), kube.SerializeLabelSelector(c.Selector))
+}
+
+// BuildCiliumPolicies models each rule of policies and clusterwidePolicies as a CiliumPolicy
+func BuildCiliumPolicies(policies []*cilium.CiliumNetworkPolicy, clusterwidePolicies []*cilium.CiliumClusterwideNetworkPolicy) []*CiliumPolicy {
+	var ciliumPolicies []*CiliumPolicy
+	for _, policy := range policies {
+		namespace := policy.Namespace
+		if namespace == "" {
+			namespace = v1.NamespaceDefault
+		}
+		ciliumPolicies = append(ciliumPolicies, buildCiliumPolicies(namespace+"/"+policy.Name, false, namespace, cilium.Rules(policy.Spec, policy.Specs))...)
+	}
+	for _, policy := range clusterwidePolicies {
+		ciliumPolicies = append(ciliumPolicies, buildCiliumPolicies(policy.Name, true, "", cilium.Rules(policy.Spec, policy.Specs))...)
+	}
+	return ciliumPolicies
+}
+
+func buildCiliumPolicies(name string, isClusterwide bool, namespace string, rules []cilium.Rule) []*CiliumPolicy {
+	var ciliumPolicies []*CiliumPolicy
+	for i, rule := range rules {
+		policy := &CiliumPolicy{Name: name, IsClusterwide: isClusterwide}
+		if len(rules) > 1 {
+			policy.Name = fmt.Sprintf("%s spec %d", name, i+1)
+		}
+		namespaceMatcher, podMatcher := buildCiliumSelector(namespace, rule.EndpointSelector)
+		policy.Subject = &AdminSubject{Namespace: namespaceMatcher, Pod: podMatcher}
+		for j, ingress := range rule.Ingress {
+			policy.Ingress = append(policy.Ingress, &CiliumRule{
+				Name:  fmt.Sprintf("ingress rule %d", j+1),
+				Peers: buildCiliumPeers(namespace, ingress.FromEndpoints, ingress.FromCIDR, ingress.FromCIDRSet, ingress.FromEntities, ingress.ToPorts),
+			})
+		}
+		for j, ingress := range rule.IngressDeny {
+			policy.Ingress = append(policy.Ingress, &CiliumRule{
+				Name:  fmt.Sprintf("ingressDeny rule %d", j+1),
+				Deny:  true,
+				Peers: buildCiliumPeers(namespace, ingress.FromEndpoints, ingress.FromCIDR, ingress.FromCIDRSet, ingress.FromEntities, ingress.ToPorts),
+			})
+		}
+		for j, egress := range rule.Egress {
+			policy.Egress = append(policy.Egress, &CiliumRule{
+				Name:  fmt.Sprintf("egress rule %d", j+1),
+				Peers: buildCiliumPeers(namespace, egress.ToEndpoints, egress.ToCIDR, egress.ToCIDRSet, egress.ToEntities, egress.ToPorts),
+			})
+		}
+		for j, egress := range rule.EgressDeny {
+			policy.Egress = append(policy.Egress, &CiliumRule{
+				Name:  fmt.Sprintf("egressDeny rule %d", j+1),
+				Deny:  true,
+				Peers: buildCiliumPeers(namespace, egress.ToEndpoints, egress.ToCIDR, egress.ToCIDRSet, egress.ToEntities, egress.ToPorts),
+			})
+		}
+		ciliumPolicies = append(ciliumPolicies, policy)
+	}
+	return ciliumPolicies
+}
+
+// trimCiliumLabelSource drops a label key's k8s: or any: source prefix
+func trimCiliumLabelSource(key string) string {
+	for _, source := range []string{"k8s:", "any:"} {
+		if strings.HasPrefix(key, source) {
+			return strings.TrimPrefix(key, source)
+		}
+	}
+	return key
+}
+
+// buildCiliumSelector splits a Cilium endpoint selector into namespace and pod matchers.  The
+// io.kubernetes.pod.namespace label selects namespaces by name, and io.cilium.k8s.namespace.labels.<key> labels by
+// their labels.  A selector without a namespace name is restricted to defaultNamespace -- the namespace of a
+// CiliumNetworkPolicy -- unless it's empty, for a CiliumClusterwideNetworkPolicy.
+func buildCiliumSelector(defaultNamespace string, selector metav1.LabelSelector) (NamespaceMatcher, PodMatcher) {
+	names, namespaceSelector, podSelector := metav1.LabelSelector{}, metav1.LabelSelector{}, metav1.LabelSelector{}
+	route := func(key string) (*metav1.LabelSelector, string) {
+		key = trimCiliumLabelSource(key)
+		if key == cilium.NamespaceLabel {
+			return &names, key
+		} else if strings.HasPrefix(key, cilium.NamespaceLabelsPrefix) {
+			return &namespaceSelector, strings.TrimPrefix(key, cilium.NamespaceLabelsPrefix)
+		}
+		return &podSelector, key
+	}
+	for key, value := range selector.MatchLabels {
+		s, trimmed := route(key)
+		if s.MatchLabels == nil {
+			s.MatchLabels = map[string]string{}
+		}
+		s.MatchLabels[trimmed] = value
+	}
+	for _, requirement := range selector.MatchExpressions {
+		s, trimmed := route(requirement.Key)
+		requirement.Key = trimmed
+		s.MatchExpressions = append(s.MatchExpressions, requirement)
+	}
+
+	if kube.IsLabelSelectorEmpty(names) && defaultNamespace != "" {
+		names = metav1.LabelSelector{MatchLabels: map[string]string{cilium.NamespaceLabel: defaultNamespace}}
+	}
+	var namespaceMatcher NamespaceMatcher
+	if kube.IsLabelSelectorEmpty(names) && kube.IsLabelSelectorEmpty(namespaceSelector) {
+		namespaceMatcher = &AllNamespaceMatcher{}
+	} else if len(names.MatchLabels) == 1 && len(names.MatchExpressions) == 0 && kube.IsLabelSelectorEmpty(namespaceSelector) {
+		namespaceMatcher = &ExactNamespaceMatcher{Namespace: names.MatchLabels[cilium.NamespaceLabel]}
+	} else {
+		namespaceMatcher = &CiliumNamespaceMatcher{Names: names, Selector: namespaceSelector}
+	}
+	return namespaceMatcher, buildAdminPodMatcher(podSelector)
+}
+
+// buildCiliumPeers: a rule with ports but no peers matches all peers on those ports; a rule with neither matches
+// nothing.  Entities other than all, world and cluster -- such as host -- aren't modeled, and are skipped.
+func buildCiliumPeers(namespace string, endpoints []metav1.LabelSelector, cidrs []string, cidrSets []cilium.CIDRRule, entities []cilium.Entity, toPorts []cilium.PortRule) []PeerMatcher {
+	port := buildCiliumPortMatcher(toPorts)
+	if len(endpoints) == 0 && len(cidrs) == 0 && len(cidrSets) == 0 && len(entities) == 0 {
+		if len(toPorts) == 0 {
+			return nil
+		}
+		return []PeerMatcher{&PortsForAllPeersMatcher{Port: port}}
+	}
+
+	var matchers []PeerMatcher
+	for _, endpoint := range endpoints {
+		namespaceMatcher, podMatcher := buildCiliumSelector(namespace, endpoint)
+		matchers = append(matchers, &PodPeerMatcher{Namespace: namespaceMatcher, Pod: podMatcher, Port: port})
+	}
+	for _, cidr := range cidrs {
+		matchers = append(matchers, &IPPeerMatcher{IPBlock: &networkingv1.IPBlock{CIDR: cidr}, Port: port})
+	}
+	for _, cidrSet := range cidrSets {
+		matchers = append(matchers, &IPPeerMatcher{IPBlock: &networkingv1.IPBlock{CIDR: cidrSet.Cidr, Except: cidrSet.ExceptCIDRs}, Port: port})
+	}
+	for _, entity := range entities {
+		switch entity {
+		case cilium.EntityAll:
+			matchers = append(matchers, &PortsForAllPeersMatcher{Port: port})
+		case cilium.EntityWorld:
+			matchers = append(matchers, &WorldPeerMatcher{Port: port})
+		case cilium.EntityCluster:
+			matchers = append(matchers, &PodPeerMatcher{Namespace: &AllNamespaceMatcher{}, Pod: &AllPodMatcher{}, Port: port})
+		default:
+			logrus.Warnf("skipping cilium entity '%s': only %s, %s and %s are modeled", entity, cilium.EntityAll, cilium.EntityWorld, cilium.EntityCluster)
+		}
+	}
+	return matchers
+}
+
+// buildCiliumPortMatcher translates Cilium ports into NetworkPolicy ports; protocol ANY is translated into one
+// port per protocol
+func buildCiliumPortMatcher(toPorts []cilium.PortRule) PortMatcher {
+	var npPorts []networkingv1.NetworkPolicyPort
+	for _, portRule := range toPorts {
+		for _, port := range portRule.Ports {
+			protocols := []v1.Protocol{v1.ProtocolTCP, v1.ProtocolUDP, v1.ProtocolSCTP}
+			if port.Protocol != "" && port.Protocol != cilium.ProtocolAny {
+				protocols = []v1.Protocol{v1.Protocol(port.Protocol)}
+			}
+			for _, protocol := range protocols {
+				protocol := protocol
+				npPort := networkingv1.NetworkPolicyPort{Protocol: &protocol}
+				if number, err := strconv.Atoi(port.Port); port.Port != "" && err != nil {
+					named := intstr.FromString(port.Port)
+					npPort.Port = &named
+				} else if number != 0 {
+					numbered := intstr.FromInt(number)
+					npPort.Port = &numbered
+					if port.EndPort > int32(number) {
+						endPort := port.EndPort
+						npPort.EndPort = &endPort
+					}
+				}
+				npPorts = append(npPorts, npPort)
+			}
+		}
+	}
+	return BuildPortMatcher(npPorts)
+}
@@ -0,0 +1,127 @@
+package matcher
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube/cilium"
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func RunCiliumPolicyTests() {
+	ciliumPolicy := func(serialized string) *cilium.CiliumNetworkPolicy {
+		var policy *cilium.CiliumNetworkPolicy
+		utils.DoOrDie(yaml.Unmarshal([]byte(serialized), &policy))
+		return policy
+	}
+	allowFromY := ciliumPolicy(`
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: allow-from-y
+  namespace: x
+spec:
+  endpointSelector:
+    matchLabels:
+      k8s:pod: a
+  ingress:
+  - fromEndpoints:
+    - matchLabels:
+        k8s:io.kubernetes.pod.namespace: "y"
+    toPorts:
+    - ports:
+      - port: "80"
+        protocol: TCP`)
+	denyFromYPodB := ciliumPolicy(`
+apiVersion: cilium.io/v2
+kind: CiliumNetworkPolicy
+metadata:
+  name: deny-from-y-b
+  namespace: x
+spec:
+  endpointSelector: {}
+  ingressDeny:
+  - fromEndpoints:
+    - matchLabels:
+        io.kubernetes.pod.namespace: "y"
+        pod: b`)
+	var clusterwide *cilium.CiliumClusterwideNetworkPolicy
+	utils.DoOrDie(yaml.Unmarshal([]byte(`
+apiVersion: cilium.io/v2
+kind: CiliumClusterwideNetworkPolicy
+metadata:
+  name: egress-to-world
+spec:
+  endpointSelector:
+    matchLabels:
+      io.cilium.k8s.namespace.labels.ns: "y"
+  egress:
+  - toEntities:
+    - world`), &clusterwide))
+
+	traffic := func(sourceNamespace string, sourcePod string, destination *TrafficPeer, port int) *Traffic {
+		return &Traffic{
+			Source: &TrafficPeer{
+				Internal: &InternalPeer{PodLabels: map[string]string{"pod": sourcePod}, NamespaceLabels: map[string]string{"ns": sourceNamespace}, Namespace: sourceNamespace},
+				IP:       "1.2.3.4",
+			},
+			Destination:  destination,
+			ResolvedPort: port,
+			Protocol:     v1.ProtocolTCP,
+		}
+	}
+	podInX := func(pod string) *TrafficPeer {
+		return &TrafficPeer{Internal: &InternalPeer{PodLabels: map[string]string{"pod": pod}, NamespaceLabels: map[string]string{"ns": "x"}, Namespace: "x"}, IP: "1.2.3.5"}
+	}
+
+	Describe("Cilium policies", func() {
+		It("should isolate selected pods, allowing only what their rules match", func() {
+			policy := NewPolicy()
+			policy.AddCiliumPolicies(BuildCiliumPolicies([]*cilium.CiliumNetworkPolicy{allowFromY}, nil))
+
+			result := policy.IsTrafficAllowed(traffic("y", "a", podInX("a"), 80))
+			Expect(result.IsAllowed()).To(BeTrue())
+			Expect(result.Ingress.CiliumAllowingRules).To(HaveLen(1))
+			Expect(result.Ingress.CiliumAllowingRules[0].String()).To(Equal("CiliumNetworkPolicy x/allow-from-y, ingress rule 1"))
+
+			Expect(policy.IsTrafficAllowed(traffic("y", "a", podInX("a"), 81)).IsAllowed()).To(BeFalse())
+			Expect(policy.IsTrafficAllowed(traffic("z", "a", podInX("a"), 80)).IsAllowed()).To(BeFalse())
+			// pods the policy doesn't select aren't isolated
+			Expect(policy.IsTrafficAllowed(traffic("z", "a", podInX("b"), 80)).IsAllowed()).To(BeTrue())
+		})
+
+		It("should let deny rules override allowing rules of Cilium policies and NetworkPolicies", func() {
+			allowAll := &networkingv1.NetworkPolicy{}
+			allowAll.Namespace, allowAll.Name = "x", "allow-all"
+			allowAll.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+			allowAll.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{}}
+			policy := BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{allowAll})
+			policy.AddCiliumPolicies(BuildCiliumPolicies([]*cilium.CiliumNetworkPolicy{allowFromY, denyFromYPodB}, nil))
+
+			Expect(policy.IsTrafficAllowed(traffic("y", "a", podInX("a"), 80)).IsAllowed()).To(BeTrue())
+			result := policy.IsTrafficAllowed(traffic("y", "b", podInX("a"), 80))
+			Expect(result.IsAllowed()).To(BeFalse())
+			Expect(result.Ingress.CiliumDenyRule.String()).To(Equal("CiliumNetworkPolicy x/deny-from-y-b, ingressDeny rule 1"))
+			Expect(policy.TraceTraffic(traffic("y", "b", podInX("a"), 80)).Ingress.Decision).To(Equal("denied by CiliumNetworkPolicy x/deny-from-y-b, ingressDeny rule 1"))
+		})
+
+		It("should select pods in all namespaces for clusterwide policies, and model the world entity", func() {
+			policy := NewPolicy()
+			policy.AddCiliumPolicies(BuildCiliumPolicies(nil, []*cilium.CiliumClusterwideNetworkPolicy{clusterwide}))
+
+			external := &TrafficPeer{IP: "8.8.8.8"}
+			Expect(policy.IsTrafficAllowed(traffic("y", "a", external, 443)).IsAllowed()).To(BeTrue())
+			Expect(policy.IsTrafficAllowed(traffic("y", "a", podInX("a"), 443)).IsAllowed()).To(BeFalse())
+			Expect(policy.IsTrafficAllowed(traffic("z", "a", podInX("a"), 443)).IsAllowed()).To(BeTrue())
+		})
+
+		It("should translate ANY and port-less ports to all protocols and ports", func() {
+			port := buildCiliumPortMatcher([]cilium.PortRule{{Ports: []cilium.PortProtocol{{Port: "53", Protocol: cilium.ProtocolAny}, {Protocol: "UDP"}}}})
+			Expect(port.Allows(53, "", v1.ProtocolSCTP)).To(BeTrue())
+			Expect(port.Allows(54, "", v1.ProtocolTCP)).To(BeFalse())
+			Expect(port.Allows(54, "", v1.ProtocolUDP)).To(BeTrue())
+		})
+	})
+}
@@ -0,0 +1,238 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+// CoverageElement is a part of a network policy which traffic can exercise: its selection of pods, for a
+// direction, or one of its rules, or one of a rule's peers or ports.  Selection is exercised by traffic to -- or,
+// for egress, from -- a pod the policy selects; a rule by traffic it allows; and a peer or port by traffic it allows
+// through its rule.
+type CoverageElement struct {
+	Policy      string
+	Direction   string
+	Rule        int `json:",omitempty"`
+	Element     string
+	Description string
+	Hits        int
+}
+
+// Coverage counts how often the elements of a set of network policies are exercised by traffic.  Elements are
+// identified by their policy's name and contents, so that coverage of the same policy accumulates over many sets
+// of policies -- i.e. the steps of a run.
+type Coverage struct {
+	Elements   []*CoverageElement
+	index      map[string]*CoverageElement
+	policyIDs  map[string]int
+	policyKeys map[*networkingv1.NetworkPolicy]string
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{
+		index:      map[string]*CoverageElement{},
+		policyIDs:  map[string]int{},
+		policyKeys: map[*networkingv1.NetworkPolicy]string{},
+	}
+}
+
+// policyKey identifies policy by its name and contents
+func (c *Coverage) policyKey(policy *networkingv1.NetworkPolicy) string {
+	if key, ok := c.policyKeys[policy]; ok {
+		return key
+	}
+	contents, err := json.Marshal(policy.Spec)
+	if err != nil {
+		panic(err)
+	}
+	identity := fmt.Sprintf("%s %s", policyName(policy), contents)
+	id, ok := c.policyIDs[identity]
+	if !ok {
+		id = len(c.policyIDs)
+		c.policyIDs[identity] = id
+	}
+	c.policyKeys[policy] = fmt.Sprintf("%d", id)
+	return c.policyKeys[policy]
+}
+
+// element finds -- or adds -- the element numbered index, of kind, of a policy's rule.  Rule 0 is the policy's
+// selection.
+func (c *Coverage) element(policy *networkingv1.NetworkPolicy, isIngress bool, rule int, kind string, index int, description string) *CoverageElement {
+	name := kind
+	if index > 0 {
+		name = fmt.Sprintf("%s %d", kind, index)
+	}
+	key := fmt.Sprintf("%s/%s/%d/%s", c.policyKey(policy), directionName(isIngress), rule, name)
+	element, ok := c.index[key]
+	if !ok {
+		element = &CoverageElement{Policy: policyName(policy), Direction: directionName(isIngress), Rule: rule, Element: name, Description: description}
+		c.index[key] = element
+		c.Elements = append(c.Elements, element)
+	}
+	return element
+}
+
+// AddPolicies adds the elements of policies -- even those which aren't exercised -- so that they're reported
+func (c *Coverage) AddPolicies(policies []*networkingv1.NetworkPolicy) {
+	for _, policy := range policies {
+		if _, ok := c.policyKeys[policy]; ok {
+			continue
+		}
+		for _, isIngress := range []bool{true, false} {
+			if !policyTypeCheck(policy.Spec.PolicyTypes, isIngress).Matches {
+				continue
+			}
+			c.element(policy, isIngress, 0, "selects", 0, fmt.Sprintf("pod selector %s", selectorString(policy.Spec.PodSelector)))
+			for i, rule := range policyRules(policy, isIngress) {
+				c.element(policy, isIngress, i+1, "rule", 0, rule.String())
+				for j, peer := range rule.peers {
+					c.element(policy, isIngress, i+1, "peer", j+1, peerString(peer))
+				}
+				for j, port := range rule.ports {
+					c.element(policy, isIngress, i+1, "port", j+1, portString(port))
+				}
+			}
+		}
+	}
+}
+
+// AddTraffic traces traffic through policy, counting the elements of policy's network policies it exercises
+func (c *Coverage) AddTraffic(policy *Policy, traffic *Traffic) {
+	policies := policy.sourcePolicies()
+	c.AddPolicies(policies)
+	trace := policy.traceTraffic(policies, traffic)
+	for _, direction := range []*DirectionTrace{trace.Ingress, trace.Egress} {
+		isIngress := direction == trace.Ingress
+		// there are no traced policies for pods outside the cluster
+		for i, policyTrace := range direction.Policies {
+			if !policyTrace.Selected {
+				continue
+			}
+			networkPolicy := policies[i]
+			c.element(networkPolicy, isIngress, 0, "selects", 0, "").Hits++
+			for _, rule := range policyTrace.Rules {
+				if !rule.Matches {
+					continue
+				}
+				c.element(networkPolicy, isIngress, rule.Rule, "rule", 0, "").Hits++
+				for _, peer := range rule.Peers {
+					if peer.Matches {
+						c.element(networkPolicy, isIngress, rule.Rule, "peer", peer.Peer, "").Hits++
+					}
+				}
+				for j, port := range rule.Ports {
+					if port.Matches {
+						c.element(networkPolicy, isIngress, rule.Rule, "port", j+1, "").Hits++
+					}
+				}
+			}
+		}
+	}
+}
+
+// Uncovered are the elements which were never exercised
+func (c *Coverage) Uncovered() []*CoverageElement {
+	var uncovered []*CoverageElement
+	for _, element := range c.Elements {
+		if element.Hits == 0 {
+			uncovered = append(uncovered, element)
+		}
+	}
+	return uncovered
+}
+
+// Table lists each element and how often it was exercised -- or only those which never were -- followed by a
+// summary
+func (c *Coverage) Table(uncoveredOnly bool) string {
+	elements := c.Elements
+	if uncoveredOnly {
+		elements = c.Uncovered()
+	}
+	str := &strings.Builder{}
+	if len(elements) > 0 {
+		table := tablewriter.NewWriter(str)
+		table.SetHeader([]string{"Policy", "Direction", "Rule", "Element", "Description", "Hits"})
+		table.SetAutoWrapText(false)
+		for _, element := range elements {
+			rule := ""
+			if element.Rule > 0 {
+				rule = fmt.Sprintf("%d", element.Rule)
+			}
+			table.Append([]string{element.Policy, element.Direction, rule, element.Element, element.Description, fmt.Sprintf("%d", element.Hits)})
+		}
+		table.Render()
+	}
+	str.WriteString(fmt.Sprintf("%d of %d policy elements were exercised by at least one probe\n", len(c.Elements)-len(c.Uncovered()), len(c.Elements)))
+	return str.String()
+}
+
+type policyRule struct {
+	peers []networkingv1.NetworkPolicyPeer
+	ports []networkingv1.NetworkPolicyPort
+}
+
+func (r *policyRule) String() string {
+	count := func(n int, noun string) string {
+		if n == 0 {
+			return fmt.Sprintf("all %ss", noun)
+		} else if n == 1 {
+			return fmt.Sprintf("1 %s", noun)
+		}
+		return fmt.Sprintf("%d %ss", n, noun)
+	}
+	return fmt.Sprintf("%s, %s", count(len(r.peers), "peer"), count(len(r.ports), "port"))
+}
+
+func policyRules(policy *networkingv1.NetworkPolicy, isIngress bool) []*policyRule {
+	var rules []*policyRule
+	if isIngress {
+		for _, rule := range policy.Spec.Ingress {
+			rules = append(rules, &policyRule{peers: rule.From, ports: rule.Ports})
+		}
+	} else {
+		for _, rule := range policy.Spec.Egress {
+			rules = append(rules, &policyRule{peers: rule.To, ports: rule.Ports})
+		}
+	}
+	return rules
+}
+
+func selectorString(selector metav1.LabelSelector) string {
+	formatted := metav1.FormatLabelSelector(&selector)
+	if formatted == "<none>" {
+		return "all"
+	}
+	return formatted
+}
+
+func peerString(peer networkingv1.NetworkPolicyPeer) string {
+	if peer.IPBlock != nil {
+		description := fmt.Sprintf("ipBlock %s", peer.IPBlock.CIDR)
+		if len(peer.IPBlock.Except) > 0 {
+			description += fmt.Sprintf(" except %s", strings.Join(peer.IPBlock.Except, ", "))
+		}
+		return description
+	}
+	var parts []string
+	if peer.NamespaceSelector != nil {
+		parts = append(parts, fmt.Sprintf("namespace selector %s", selectorString(*peer.NamespaceSelector)))
+	}
+	if peer.PodSelector != nil {
+		parts = append(parts, fmt.Sprintf("pod selector %s", selectorString(*peer.PodSelector)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func portString(port networkingv1.NetworkPolicyPort) string {
+	singlePort, portRange := BuildSinglePortMatcher(port)
+	if singlePort == nil {
+		return fmt.Sprintf("%s/%d-%d", portRange.Protocol, portRange.From, portRange.To)
+	} else if singlePort.Port == nil {
+		return fmt.Sprintf("%s (all ports)", singlePort.Protocol)
+	}
+	return fmt.Sprintf("%s/%s", singlePort.Protocol, singlePort.Port.String())
+}
@@ -0,0 +1,85 @@
+package matcher
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func RunCoverageTests() {
+	serializedPolicies := `
+- metadata:
+    name: allow-b
+    namespace: x
+  spec:
+    podSelector:
+      matchLabels:
+        pod: a
+    ingress:
+    - from:
+      - podSelector:
+          matchLabels:
+            pod: b
+      - podSelector:
+          matchLabels:
+            pod: c
+      ports:
+      - port: 80
+      - port: 81
+    policyTypes: [Ingress]
+- metadata:
+    name: deny-egress
+    namespace: "y"
+  spec:
+    podSelector: {}
+    policyTypes: [Egress]`
+	var kubePolicies []*networkingv1.NetworkPolicy
+	utils.DoOrDie(yaml.Unmarshal([]byte(serializedPolicies), &kubePolicies))
+
+	traffic := func(fromPod string, port int) *Traffic {
+		return &Traffic{
+			Source:       &TrafficPeer{Internal: &InternalPeer{Namespace: "x", PodLabels: map[string]string{"pod": fromPod}}},
+			Destination:  &TrafficPeer{Internal: &InternalPeer{Namespace: "x", PodLabels: map[string]string{"pod": "a"}}},
+			ResolvedPort: port,
+			Protocol:     v1.ProtocolTCP,
+		}
+	}
+
+	Describe("Coverage", func() {
+		It("Should count the selections, rules, peers and ports exercised by traffic", func() {
+			coverage := NewCoverage()
+			policies := BuildNetworkPolicies(true, kubePolicies)
+			coverage.AddTraffic(policies, traffic("b", 80))
+			coverage.AddTraffic(policies, traffic("b", 82))
+
+			Expect(coverage.Elements).To(Equal([]*CoverageElement{
+				{Policy: "x/allow-b", Direction: "ingress", Element: "selects", Description: "pod selector pod=a", Hits: 2},
+				{Policy: "x/allow-b", Direction: "ingress", Rule: 1, Element: "rule", Description: "2 peers, 2 ports", Hits: 1},
+				{Policy: "x/allow-b", Direction: "ingress", Rule: 1, Element: "peer 1", Description: "pod selector pod=b", Hits: 1},
+				{Policy: "x/allow-b", Direction: "ingress", Rule: 1, Element: "peer 2", Description: "pod selector pod=c", Hits: 0},
+				{Policy: "x/allow-b", Direction: "ingress", Rule: 1, Element: "port 1", Description: "TCP/80", Hits: 1},
+				{Policy: "x/allow-b", Direction: "ingress", Rule: 1, Element: "port 2", Description: "TCP/81", Hits: 0},
+				{Policy: "y/deny-egress", Direction: "egress", Element: "selects", Description: "pod selector all", Hits: 0},
+			}))
+			Expect(coverage.Uncovered()).To(HaveLen(3))
+			Expect(coverage.Table(true)).To(ContainSubstring("4 of 7 policy elements were exercised by at least one probe"))
+		})
+
+		It("Should accumulate coverage of the same policy over different sets of policies", func() {
+			coverage := NewCoverage()
+			coverage.AddTraffic(BuildNetworkPolicies(true, kubePolicies), traffic("b", 80))
+			copied := kubePolicies[0].DeepCopy()
+			coverage.AddTraffic(BuildNetworkPolicies(true, []*networkingv1.NetworkPolicy{copied}), traffic("c", 81))
+			Expect(coverage.Elements).To(HaveLen(7))
+			Expect(coverage.Uncovered()).To(HaveLen(1))
+
+			changed := kubePolicies[0].DeepCopy()
+			changed.Spec.Ingress[0].Ports = nil
+			coverage.AddPolicies([]*networkingv1.NetworkPolicy{changed})
+			Expect(coverage.Elements).To(HaveLen(11))
+		})
+	})
+}
@@ -3,6 +3,9 @@ package matcher
 import (
 	"fmt"
 	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/kube/antrea"
+	"github.com/mattfenwick/cyclonus/pkg/kube/calico"
 	"github.com/olekukonko/tablewriter"
 	"sort"
 	"strings"
@@ -12,6 +15,20 @@ import (
 type Policy struct {
 	Ingress map[string]*Target
 	Egress  map[string]*Target
+	// AdminPolicies are AdminNetworkPolicies, in order of precedence; they're evaluated before NetworkPolicies
+	AdminPolicies []*AdminPolicy
+	// BaselinePolicy is the BaselineAdminNetworkPolicy, if there is one; it only decides traffic which no
+	// AdminNetworkPolicy or NetworkPolicy decided
+	BaselinePolicy *AdminPolicy
+	// CiliumPolicies are CiliumNetworkPolicies and CiliumClusterwideNetworkPolicies; their deny rules are evaluated
+	// after AdminNetworkPolicies, and their allow rules along with NetworkPolicies
+	CiliumPolicies []*CiliumPolicy
+	// CalicoTiers are Calico NetworkPolicies and GlobalNetworkPolicies, in their tiers, in order; they're evaluated
+	// after AdminNetworkPolicies, with NetworkPolicies in the default tier
+	CalicoTiers []*CalicoTier
+	// AntreaPolicies are Antrea ClusterNetworkPolicies and NetworkPolicies, in order; those in the baseline tier are
+	// evaluated after NetworkPolicies, and the rest after AdminNetworkPolicies
+	AntreaPolicies []*AntreaPolicy
 }
 
 func NewPolicy() *Policy {
@@ -65,6 +82,41 @@ func (p *Policy) AddTarget(isIngress bool, target *Target) *Target {
 	return dict[pk]
 }
 
+// AddAdminPolicies adds AdminNetworkPolicies, keeping them in order of precedence
+func (p *Policy) AddAdminPolicies(policies []*AdminPolicy) {
+	p.AdminPolicies = append(p.AdminPolicies, policies...)
+	SortAdminPolicies(p.AdminPolicies)
+}
+
+// AddCiliumPolicies adds CiliumNetworkPolicies and CiliumClusterwideNetworkPolicies
+func (p *Policy) AddCiliumPolicies(policies []*CiliumPolicy) {
+	p.CiliumPolicies = append(p.CiliumPolicies, policies...)
+}
+
+// AddCalicoTiers adds Calico tiers, merging the policies of tiers with the same name, and keeping them in order
+func (p *Policy) AddCalicoTiers(tiers []*CalicoTier) {
+	for _, tier := range tiers {
+		merged := false
+		for _, existing := range p.CalicoTiers {
+			if existing.Name == tier.Name {
+				existing.Policies = append(existing.Policies, tier.Policies...)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			p.CalicoTiers = append(p.CalicoTiers, tier)
+		}
+	}
+	SortCalicoTiers(p.CalicoTiers)
+}
+
+// AddAntreaPolicies adds Antrea ClusterNetworkPolicies and NetworkPolicies, keeping them in order
+func (p *Policy) AddAntreaPolicies(policies []*AntreaPolicy) {
+	p.AntreaPolicies = append(p.AntreaPolicies, policies...)
+	SortAntreaPolicies(p.AntreaPolicies)
+}
+
 func (p *Policy) TargetsApplyingToPod(isIngress bool, namespace string, podLabels map[string]string) []*Target {
 	var targets []*Target
 	var dict map[string]*Target
@@ -84,10 +136,62 @@ func (p *Policy) TargetsApplyingToPod(isIngress bool, namespace string, podLabel
 type DirectionResult struct {
 	AllowingTargets []*Target
 	DenyingTargets  []*Target
+	// AdminRule is the first AdminNetworkPolicy rule matching the traffic, if any.  An Allow or Deny rule decides
+	// the traffic; a Pass rule hands the decision down to NetworkPolicies.
+	AdminRule *AdminRuleMatch
+	// BaselineRule is the BaselineAdminNetworkPolicy rule which decided the traffic, if nothing else did
+	BaselineRule *AdminRuleMatch
+	// CiliumDenyRule is the first Cilium deny rule matching the traffic, if any; it overrides all allowing rules
+	CiliumDenyRule *CiliumRuleMatch
+	// CiliumAllowingRules are the first allow rule matching the traffic of each Cilium policy isolating its target
+	CiliumAllowingRules []*CiliumRuleMatch
+	// CiliumDenyingPolicies isolate the traffic's target, but none of their allow rules match it
+	CiliumDenyingPolicies []*CiliumPolicy
+	// CalicoRule is the Calico Allow or Deny rule which decided the traffic, if any
+	CalicoRule *CalicoRuleMatch
+	// CalicoPassingRules passed the traffic on from their tiers to the next ones
+	CalicoPassingRules []*CalicoRuleMatch
+	// CalicoDenyingTier selected the traffic's target, but none of its policies' rules -- nor, in the default tier,
+	// any NetworkPolicy -- matched the traffic, so the tier denied it; CalicoDenyingPolicies are its policies which
+	// selected the target
+	CalicoDenyingTier     *CalicoTier
+	CalicoDenyingPolicies []*CalicoPolicy
+	// AntreaRule is the first rule of the Antrea policies outside the baseline tier matching the traffic, if any.
+	// Like AdminRule, it decides the traffic, unless it passes.
+	AntreaRule *AntreaRuleMatch
+	// AntreaBaselineRule is the Antrea baseline tier rule which decided the traffic, if NetworkPolicies didn't
+	AntreaBaselineRule *AntreaRuleMatch
 }
 
+// IsAllowed checks, in order: AdminNetworkPolicies, Cilium deny rules, Antrea tiers, Calico tiers, NetworkPolicies
+// along with Cilium allow rules, Antrea's baseline tier, and the BaselineAdminNetworkPolicy.  Traffic which none of
+// them decides is allowed.
 func (d *DirectionResult) IsAllowed() bool {
-	return len(d.AllowingTargets) > 0 || len(d.DenyingTargets) == 0
+	if d.AdminRule != nil && d.AdminRule.Rule.Action != anp.AdminNetworkPolicyRuleActionPass {
+		return d.AdminRule.Rule.Action == anp.AdminNetworkPolicyRuleActionAllow
+	}
+	if d.CiliumDenyRule != nil {
+		return false
+	}
+	if d.AntreaRule != nil && d.AntreaRule.Rule.Action != antrea.RuleActionPass {
+		return d.AntreaRule.Rule.Action == antrea.RuleActionAllow
+	}
+	if d.CalicoRule != nil {
+		return d.CalicoRule.Rule.Action == calico.ActionAllow
+	}
+	if d.CalicoDenyingTier != nil {
+		return false
+	}
+	if len(d.AllowingTargets) > 0 || len(d.DenyingTargets) > 0 || len(d.CiliumAllowingRules) > 0 || len(d.CiliumDenyingPolicies) > 0 {
+		return len(d.AllowingTargets) > 0 || len(d.CiliumAllowingRules) > 0
+	}
+	if d.AntreaBaselineRule != nil {
+		return d.AntreaBaselineRule.Rule.Action == antrea.RuleActionAllow
+	}
+	if d.BaselineRule != nil {
+		return d.BaselineRule.Rule.Action == anp.AdminNetworkPolicyRuleActionAllow
+	}
+	return true
 }
 
 type AllowedResult struct {
@@ -102,11 +206,23 @@ func (ar *AllowedResult) Table() string {
 	table.SetAutoMergeCells(true)
 	table.SetHeader([]string{"Type", "Action", "Target"})
 
+	addAdminRuleToTable(table, "Ingress", ar.Ingress.AdminRule)
+	addCiliumToTable(table, "Ingress", ar.Ingress)
+	addAntreaRuleToTable(table, "Ingress", ar.Ingress.AntreaRule)
+	addCalicoToTable(table, "Ingress", ar.Ingress)
 	addTargetsToTable(table, "Ingress", "Allow", ar.Ingress.AllowingTargets)
 	addTargetsToTable(table, "Ingress", "Deny", ar.Ingress.DenyingTargets)
+	addAntreaRuleToTable(table, "Ingress", ar.Ingress.AntreaBaselineRule)
+	addAdminRuleToTable(table, "Ingress", ar.Ingress.BaselineRule)
 	table.Append([]string{"", "", ""})
+	addAdminRuleToTable(table, "Egress", ar.Egress.AdminRule)
+	addCiliumToTable(table, "Egress", ar.Egress)
+	addAntreaRuleToTable(table, "Egress", ar.Egress.AntreaRule)
+	addCalicoToTable(table, "Egress", ar.Egress)
 	addTargetsToTable(table, "Egress", "Allow", ar.Egress.AllowingTargets)
 	addTargetsToTable(table, "Egress", "Deny", ar.Egress.DenyingTargets)
+	addAntreaRuleToTable(table, "Egress", ar.Egress.AntreaBaselineRule)
+	addAdminRuleToTable(table, "Egress", ar.Egress.BaselineRule)
 	table.SetFooter([]string{"Is allowed?", fmt.Sprintf("%t", ar.IsAllowed()), ""})
 
 	table.Render()
@@ -120,6 +236,45 @@ func addTargetsToTable(table *tablewriter.Table, ruleType string, action string,
 	}
 }
 
+func addAdminRuleToTable(table *tablewriter.Table, ruleType string, match *AdminRuleMatch) {
+	if match != nil {
+		table.Append([]string{ruleType, string(match.Rule.Action), fmt.Sprintf("%s\nrule: %s", match.Policy, match.Rule.Name)})
+	}
+}
+
+func addCiliumToTable(table *tablewriter.Table, ruleType string, result *DirectionResult) {
+	if result.CiliumDenyRule != nil {
+		table.Append([]string{ruleType, "Deny", result.CiliumDenyRule.String()})
+	}
+	for _, match := range result.CiliumAllowingRules {
+		table.Append([]string{ruleType, "Allow", match.String()})
+	}
+	for _, policy := range result.CiliumDenyingPolicies {
+		table.Append([]string{ruleType, "Deny", policy.String()})
+	}
+}
+
+func addCalicoToTable(table *tablewriter.Table, ruleType string, result *DirectionResult) {
+	for _, match := range result.CalicoPassingRules {
+		table.Append([]string{ruleType, string(match.Rule.Action), match.String()})
+	}
+	if result.CalicoRule != nil {
+		table.Append([]string{ruleType, string(result.CalicoRule.Rule.Action), result.CalicoRule.String()})
+	}
+	if result.CalicoDenyingTier != nil {
+		table.Append([]string{ruleType, "Deny", fmt.Sprintf("end of %s", result.CalicoDenyingTier)})
+	}
+	for _, policy := range result.CalicoDenyingPolicies {
+		table.Append([]string{ruleType, "Deny", policy.String()})
+	}
+}
+
+func addAntreaRuleToTable(table *tablewriter.Table, ruleType string, match *AntreaRuleMatch) {
+	if match != nil {
+		table.Append([]string{ruleType, string(match.Rule.Action), fmt.Sprintf("%s\nrule: %s", match.Policy, match.Rule.Name)})
+	}
+}
+
 func (ar *AllowedResult) IsAllowed() bool {
 	return ar.Ingress.IsAllowed() && ar.Egress.IsAllowed()
 }
@@ -152,25 +307,95 @@ func (p *Policy) IsIngressOrEgressAllowed(traffic *Traffic, isIngress bool) *Dir
 		return &DirectionResult{AllowingTargets: nil, DenyingTargets: nil}
 	}
 
+	// 2. the first matching AdminNetworkPolicy rule decides -- unless it passes
+	var adminRule *AdminRuleMatch
+	for _, adminPolicy := range p.AdminPolicies {
+		if adminRule = adminPolicy.FirstMatchingRule(traffic, isIngress); adminRule != nil {
+			break
+		}
+	}
+	if adminRule != nil && adminRule.Rule.Action != anp.AdminNetworkPolicyRuleActionPass {
+		return &DirectionResult{AdminRule: adminRule}
+	}
+
+	// 3. a matching Cilium deny rule overrides everything allowing the traffic
+	var ciliumSelecting []*CiliumPolicy
+	for _, ciliumPolicy := range p.CiliumPolicies {
+		if denyRule := ciliumPolicy.FirstMatchingRule(traffic, isIngress, true); denyRule != nil {
+			return &DirectionResult{AdminRule: adminRule, CiliumDenyRule: denyRule}
+		}
+		if ciliumPolicy.Selects(traffic, isIngress) {
+			ciliumSelecting = append(ciliumSelecting, ciliumPolicy)
+		}
+	}
+
+	// 4. the first matching rule of the Antrea tiers, other than the baseline tier, decides -- unless it passes
+	antreaRule := p.firstMatchingAntreaRule(traffic, isIngress, false)
+	if antreaRule != nil && antreaRule.Rule.Action != antrea.RuleActionPass {
+		return &DirectionResult{AdminRule: adminRule, AntreaRule: antreaRule}
+	}
+
 	matchingTargets := p.TargetsApplyingToPod(isIngress, target.Internal.Namespace, target.Internal.PodLabels)
 
-	// 2. No targets match => automatic allow
-	if len(matchingTargets) == 0 {
-		return &DirectionResult{AllowingTargets: nil, DenyingTargets: nil}
+	// 5. Calico tiers are evaluated in order, with NetworkPolicies in the default tier
+	if len(p.CalicoTiers) > 0 {
+		result := p.calicoDirectionResult(traffic, isIngress, adminRule, matchingTargets)
+		result.AntreaRule = antreaRule
+		return result
 	}
 
-	// 3. Check if any matching targets allow this traffic
+	// 6. No targets match => baseline, or automatic allow
+	if len(matchingTargets) == 0 && len(ciliumSelecting) == 0 {
+		return p.baselineDirectionResult(traffic, isIngress, adminRule, antreaRule)
+	}
+
+	// 7. Check if any matching targets, or Cilium allow rules, allow this traffic
+	allowers, deniers := targetsAllowing(matchingTargets, peer, traffic)
+	var ciliumAllowers []*CiliumRuleMatch
+	var ciliumDeniers []*CiliumPolicy
+	for _, ciliumPolicy := range ciliumSelecting {
+		if allowRule := ciliumPolicy.FirstMatchingRule(traffic, isIngress, false); allowRule != nil {
+			ciliumAllowers = append(ciliumAllowers, allowRule)
+		} else {
+			ciliumDeniers = append(ciliumDeniers, ciliumPolicy)
+		}
+	}
+
+	return &DirectionResult{
+		AllowingTargets:       allowers,
+		DenyingTargets:        deniers,
+		AdminRule:             adminRule,
+		AntreaRule:            antreaRule,
+		CiliumAllowingRules:   ciliumAllowers,
+		CiliumDenyingPolicies: ciliumDeniers,
+	}
+}
+
+// baselineDirectionResult decides traffic which no policy before NetworkPolicies, nor any NetworkPolicy, did: by the
+// first matching rule of Antrea's baseline tier, then by the BaselineAdminNetworkPolicy
+func (p *Policy) baselineDirectionResult(traffic *Traffic, isIngress bool, adminRule *AdminRuleMatch, antreaRule *AntreaRuleMatch) *DirectionResult {
+	result := &DirectionResult{AdminRule: adminRule, AntreaRule: antreaRule}
+	if result.AntreaBaselineRule = p.firstMatchingAntreaRule(traffic, isIngress, true); result.AntreaBaselineRule != nil {
+		return result
+	}
+	if p.BaselinePolicy != nil {
+		result.BaselineRule = p.BaselinePolicy.FirstMatchingRule(traffic, isIngress)
+	}
+	return result
+}
+
+// targetsAllowing splits targets into those which allow traffic from -- or to -- peer, and those which don't
+func targetsAllowing(targets []*Target, peer *TrafficPeer, traffic *Traffic) ([]*Target, []*Target) {
 	var allowers []*Target
 	var deniers []*Target
-	for _, target := range matchingTargets {
+	for _, target := range targets {
 		if target.Allows(peer, traffic.ResolvedPort, traffic.ResolvedPortName, traffic.Protocol) {
 			allowers = append(allowers, target)
 		} else {
 			deniers = append(deniers, target)
 		}
 	}
-
-	return &DirectionResult{AllowingTargets: allowers, DenyingTargets: deniers}
+	return allowers, deniers
 }
 
 func (p *Policy) Simplify() {
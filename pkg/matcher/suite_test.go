@@ -11,6 +11,12 @@ func TestMatcher(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunBuilderTests()
 	RunPolicyTests()
+	RunAdminPolicyTests()
+	RunCiliumPolicyTests()
+	RunCalicoPolicyTests()
+	RunAntreaPolicyTests()
 	RunSimplifierTests()
+	RunTraceTests()
+	RunCoverageTests()
 	RunSpecs(t, "network policy matcher suite")
 }
@@ -0,0 +1,460 @@
+package matcher
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/kube/antrea"
+	"github.com/mattfenwick/cyclonus/pkg/kube/calico"
+	"github.com/olekukonko/tablewriter"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sort"
+	"strings"
+)
+
+// TraceCheck is one condition that traffic was checked against -- a namespace, a selector, an IP block, a port --
+// and, if it didn't match, why not
+type TraceCheck struct {
+	Description string
+	Matches     bool
+	Reason      string `json:",omitempty"`
+}
+
+// PeerTrace is one of a rule's peers: it matches if all of its checks do
+type PeerTrace struct {
+	Peer    int
+	Checks  []*TraceCheck
+	Matches bool
+}
+
+// RuleTrace is one of a policy's ingress or egress rules: it matches if any of its peers, and any of its ports, do.
+// A rule without peers matches all peers, and without ports, all ports.
+type RuleTrace struct {
+	Rule    int
+	Peers   []*PeerTrace
+	Ports   []*TraceCheck
+	Matches bool
+}
+
+// PolicyTrace is a network policy: it selects the traffic's pod if all of its Selects checks match, in which case
+// the traffic is allowed if any of its rules match
+type PolicyTrace struct {
+	Policy   string
+	Selects  []*TraceCheck
+	Selected bool
+	Rules    []*RuleTrace `json:",omitempty"`
+}
+
+func (p *PolicyTrace) MatchingRules() []int {
+	var rules []int
+	for _, rule := range p.Rules {
+		if rule.Matches {
+			rules = append(rules, rule.Rule)
+		}
+	}
+	return rules
+}
+
+// DirectionTrace is every network policy considered for ingress or egress, and what decided the traffic
+type DirectionTrace struct {
+	Policies []*PolicyTrace
+	Allowed  bool
+	Decision string
+}
+
+// TrafficTrace is the full reasoning behind a verdict from IsTrafficAllowed
+type TrafficTrace struct {
+	Ingress *DirectionTrace
+	Egress  *DirectionTrace
+	Allowed bool
+}
+
+// TraceTraffic decides traffic the way IsTrafficAllowed does, but also traces it through each network policy rule by
+// rule -- which policies select the traffic's pod, and which of their peers and ports match, selector by selector
+// and port by port -- and says what decided the traffic
+func (p *Policy) TraceTraffic(traffic *Traffic) *TrafficTrace {
+	return p.traceTraffic(p.sourcePolicies(), traffic)
+}
+
+// traceTraffic traces traffic through policies -- which must be p's source policies -- in order
+func (p *Policy) traceTraffic(policies []*networkingv1.NetworkPolicy, traffic *Traffic) *TrafficTrace {
+	result := p.IsTrafficAllowed(traffic)
+	return &TrafficTrace{
+		Ingress: traceDirection(policies, result.Ingress, traffic, true),
+		Egress:  traceDirection(policies, result.Egress, traffic, false),
+		Allowed: result.IsAllowed(),
+	}
+}
+
+// sourcePolicies are the network policies p was built from, sorted by namespace and name
+func (p *Policy) sourcePolicies() []*networkingv1.NetworkPolicy {
+	seen := map[*networkingv1.NetworkPolicy]bool{}
+	var policies []*networkingv1.NetworkPolicy
+	for _, targets := range []map[string]*Target{p.Ingress, p.Egress} {
+		for _, target := range targets {
+			for _, policy := range target.SourceRules {
+				if !seen[policy] {
+					seen[policy] = true
+					policies = append(policies, policy)
+				}
+			}
+		}
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		return policyName(policies[i]) < policyName(policies[j])
+	})
+	return policies
+}
+
+func policyName(policy *networkingv1.NetworkPolicy) string {
+	return fmt.Sprintf("%s/%s", getPolicyNamespace(policy), policy.Name)
+}
+
+func directionName(isIngress bool) string {
+	if isIngress {
+		return "ingress"
+	}
+	return "egress"
+}
+
+func traceDirection(policies []*networkingv1.NetworkPolicy, result *DirectionResult, traffic *Traffic, isIngress bool) *DirectionTrace {
+	target, peer := traffic.Source, traffic.Destination
+	if isIngress {
+		target, peer = traffic.Destination, traffic.Source
+	}
+	trace := &DirectionTrace{Allowed: result.IsAllowed()}
+	if target.Internal == nil {
+		trace.Decision = fmt.Sprintf("allowed: %s is outside the cluster, so isn't subject to %s policies", target.IP, directionName(isIngress))
+		return trace
+	}
+
+	var selecting, allowing []string
+	for _, policy := range policies {
+		policyTrace := tracePolicy(policy, target, peer, traffic, isIngress)
+		trace.Policies = append(trace.Policies, policyTrace)
+		if !policyTrace.Selected {
+			continue
+		}
+		selecting = append(selecting, policyTrace.Policy)
+		for _, rule := range policyTrace.MatchingRules() {
+			allowing = append(allowing, fmt.Sprintf("%s %s rule %d", policyTrace.Policy, directionName(isIngress), rule))
+		}
+	}
+
+	for _, match := range result.CiliumAllowingRules {
+		allowing = append(allowing, match.String())
+	}
+	for _, policy := range result.CiliumDenyingPolicies {
+		selecting = append(selecting, policy.String())
+	}
+
+	switch {
+	case result.AdminRule != nil && result.AdminRule.Rule.Action != anp.AdminNetworkPolicyRuleActionPass:
+		trace.Decision = fmt.Sprintf("%s by %s, rule '%s'", adminActionVerdict(result.AdminRule.Rule.Action), result.AdminRule.Policy, result.AdminRule.Rule.Name)
+	case result.CiliumDenyRule != nil:
+		trace.Decision = fmt.Sprintf("denied by %s", result.CiliumDenyRule)
+	case result.AntreaRule != nil && result.AntreaRule.Rule.Action != antrea.RuleActionPass:
+		trace.Decision = fmt.Sprintf("%s by %s", antreaActionVerdict(result.AntreaRule.Rule.Action), result.AntreaRule)
+	case result.CalicoRule != nil:
+		trace.Decision = fmt.Sprintf("%s by %s", calicoActionVerdict(result.CalicoRule.Rule.Action), result.CalicoRule)
+	case result.CalicoDenyingTier != nil:
+		var tierSelecting []string
+		for _, policy := range result.CalicoDenyingPolicies {
+			tierSelecting = append(tierSelecting, policy.String())
+		}
+		if len(result.DenyingTargets) > 0 {
+			tierSelecting = append(tierSelecting, selecting...)
+		}
+		trace.Decision = fmt.Sprintf("denied at the end of %s: selected by %s, but none of their %s rules match", result.CalicoDenyingTier, strings.Join(tierSelecting, ", "), directionName(isIngress))
+	case len(allowing) > 0:
+		trace.Decision = fmt.Sprintf("allowed by %s", strings.Join(allowing, ", "))
+	case len(selecting) > 0:
+		trace.Decision = fmt.Sprintf("denied: selected by %s, but none of their %s rules match", strings.Join(selecting, ", "), directionName(isIngress))
+	case result.AntreaBaselineRule != nil:
+		trace.Decision = fmt.Sprintf("%s by %s", antreaActionVerdict(result.AntreaBaselineRule.Rule.Action), result.AntreaBaselineRule)
+	case result.BaselineRule != nil:
+		trace.Decision = fmt.Sprintf("%s by %s, rule '%s'", adminActionVerdict(result.BaselineRule.Rule.Action), result.BaselineRule.Policy, result.BaselineRule.Rule.Name)
+	default:
+		trace.Decision = fmt.Sprintf("allowed: no %s policy selects the pod", directionName(isIngress))
+	}
+	for i := len(result.CalicoPassingRules) - 1; i >= 0; i-- {
+		trace.Decision = fmt.Sprintf("passed by %s; %s", result.CalicoPassingRules[i], trace.Decision)
+	}
+	if result.AntreaRule != nil && result.AntreaRule.Rule.Action == antrea.RuleActionPass {
+		trace.Decision = fmt.Sprintf("passed by %s; %s", result.AntreaRule, trace.Decision)
+	}
+	if result.AdminRule != nil && result.AdminRule.Rule.Action == anp.AdminNetworkPolicyRuleActionPass {
+		trace.Decision = fmt.Sprintf("passed by %s, rule '%s'; %s", result.AdminRule.Policy, result.AdminRule.Rule.Name, trace.Decision)
+	}
+	return trace
+}
+
+func adminActionVerdict(action anp.AdminNetworkPolicyRuleAction) string {
+	if action == anp.AdminNetworkPolicyRuleActionAllow {
+		return "allowed"
+	}
+	return "denied"
+}
+
+func calicoActionVerdict(action calico.Action) string {
+	if action == calico.ActionAllow {
+		return "allowed"
+	}
+	return "denied"
+}
+
+func antreaActionVerdict(action antrea.RuleAction) string {
+	switch action {
+	case antrea.RuleActionAllow:
+		return "allowed"
+	case antrea.RuleActionReject:
+		return "rejected"
+	default:
+		return "denied"
+	}
+}
+
+func tracePolicy(policy *networkingv1.NetworkPolicy, target *TrafficPeer, peer *TrafficPeer, traffic *Traffic, isIngress bool) *PolicyTrace {
+	policyNamespace := getPolicyNamespace(policy)
+	trace := &PolicyTrace{
+		Policy: policyName(policy),
+		Selects: []*TraceCheck{
+			namespaceCheck(policyNamespace, target.Internal.Namespace),
+			selectorCheck("pod selector", policy.Spec.PodSelector, target.Internal.PodLabels),
+			policyTypeCheck(policy.Spec.PolicyTypes, isIngress),
+		},
+		Selected: true,
+	}
+	for _, check := range trace.Selects {
+		trace.Selected = trace.Selected && check.Matches
+	}
+	if !trace.Selected {
+		return trace
+	}
+
+	if isIngress {
+		for i, rule := range policy.Spec.Ingress {
+			trace.Rules = append(trace.Rules, traceRule(i+1, policyNamespace, rule.From, rule.Ports, peer, traffic))
+		}
+	} else {
+		for i, rule := range policy.Spec.Egress {
+			trace.Rules = append(trace.Rules, traceRule(i+1, policyNamespace, rule.To, rule.Ports, peer, traffic))
+		}
+	}
+	return trace
+}
+
+func traceRule(number int, policyNamespace string, peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort, peer *TrafficPeer, traffic *Traffic) *RuleTrace {
+	rule := &RuleTrace{Rule: number}
+	peerMatches := len(peers) == 0
+	for i, policyPeer := range peers {
+		peerTrace := tracePeer(i+1, policyNamespace, policyPeer, peer)
+		rule.Peers = append(rule.Peers, peerTrace)
+		peerMatches = peerMatches || peerTrace.Matches
+	}
+	portMatches := len(ports) == 0
+	for _, port := range ports {
+		portCheck := tracePort(port, traffic)
+		rule.Ports = append(rule.Ports, portCheck)
+		portMatches = portMatches || portCheck.Matches
+	}
+	rule.Matches = peerMatches && portMatches
+	return rule
+}
+
+func tracePeer(number int, policyNamespace string, policyPeer networkingv1.NetworkPolicyPeer, peer *TrafficPeer) *PeerTrace {
+	trace := &PeerTrace{Peer: number}
+	if policyPeer.IPBlock != nil {
+		trace.Checks = []*TraceCheck{ipBlockCheck(policyPeer.IPBlock, peer.IP)}
+	} else if peer.Internal == nil {
+		trace.Checks = []*TraceCheck{{Description: "pods", Reason: fmt.Sprintf("%s is outside the cluster", peer.IP)}}
+	} else {
+		if policyPeer.NamespaceSelector == nil {
+			trace.Checks = append(trace.Checks, namespaceCheck(policyNamespace, peer.Internal.Namespace))
+		} else {
+			trace.Checks = append(trace.Checks, selectorCheck("namespace selector", *policyPeer.NamespaceSelector, peer.Internal.NamespaceLabels))
+		}
+		podSelector := metav1.LabelSelector{}
+		if policyPeer.PodSelector != nil {
+			podSelector = *policyPeer.PodSelector
+		}
+		trace.Checks = append(trace.Checks, selectorCheck("pod selector", podSelector, peer.Internal.PodLabels))
+	}
+	trace.Matches = true
+	for _, check := range trace.Checks {
+		trace.Matches = trace.Matches && check.Matches
+	}
+	return trace
+}
+
+func namespaceCheck(namespace string, actual string) *TraceCheck {
+	check := &TraceCheck{Description: fmt.Sprintf("namespace %s", namespace), Matches: namespace == actual}
+	if !check.Matches {
+		check.Reason = fmt.Sprintf("namespace is %s", actual)
+	}
+	return check
+}
+
+// selectorCheck checks labels against selector, one requirement at a time, so that the first requirement which isn't
+// met can be named
+func selectorCheck(name string, selector metav1.LabelSelector, labelSet map[string]string) *TraceCheck {
+	if kube.IsLabelSelectorEmpty(selector) {
+		return &TraceCheck{Description: fmt.Sprintf("%s: all", name), Matches: true}
+	}
+	check := &TraceCheck{Description: fmt.Sprintf("%s %s", name, metav1.FormatLabelSelector(&selector)), Matches: true}
+	var requirements []metav1.LabelSelector
+	var keys []string
+	for key := range selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		requirements = append(requirements, metav1.LabelSelector{MatchLabels: map[string]string{key: selector.MatchLabels[key]}})
+	}
+	for _, expression := range selector.MatchExpressions {
+		requirements = append(requirements, metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{expression}})
+	}
+	for _, requirement := range requirements {
+		if !kube.IsLabelsMatchLabelSelector(labelSet, requirement) {
+			check.Matches = false
+			check.Reason = fmt.Sprintf("%s isn't met by labels {%s}", metav1.FormatLabelSelector(&requirement), labels.Set(labelSet).String())
+			break
+		}
+	}
+	return check
+}
+
+func policyTypeCheck(policyTypes []networkingv1.PolicyType, isIngress bool) *TraceCheck {
+	policyType := networkingv1.PolicyTypeEgress
+	if isIngress {
+		policyType = networkingv1.PolicyTypeIngress
+	}
+	check := &TraceCheck{Description: fmt.Sprintf("policy type %s", policyType)}
+	for _, t := range policyTypes {
+		check.Matches = check.Matches || t == policyType
+	}
+	if !check.Matches {
+		check.Reason = fmt.Sprintf("policy types are %+v", policyTypes)
+	}
+	return check
+}
+
+func ipBlockCheck(ipBlock *networkingv1.IPBlock, ip string) *TraceCheck {
+	check := &TraceCheck{Description: fmt.Sprintf("ipBlock %s", ipBlock.CIDR)}
+	if len(ipBlock.Except) > 0 {
+		check.Description += fmt.Sprintf(" except %s", strings.Join(ipBlock.Except, ", "))
+	}
+	if ip == "" {
+		check.Reason = "peer's IP is unknown"
+		return check
+	}
+	matches, err := kube.IsIPAddressMatchForIPBlock(ip, ipBlock)
+	if err != nil {
+		check.Reason = err.Error()
+		return check
+	}
+	check.Matches = matches
+	if !matches {
+		check.Reason = fmt.Sprintf("IP %s isn't in the block", ip)
+	}
+	return check
+}
+
+func tracePort(port networkingv1.NetworkPolicyPort, traffic *Traffic) *TraceCheck {
+	singlePort, portRange := BuildSinglePortMatcher(port)
+	check := &TraceCheck{}
+	if singlePort != nil {
+		if singlePort.Port == nil {
+			check.Description = fmt.Sprintf("port %s (all ports)", singlePort.Protocol)
+		} else {
+			check.Description = fmt.Sprintf("port %s/%s", singlePort.Protocol, singlePort.Port.String())
+		}
+		check.Matches = singlePort.AllowsPortProtocol(traffic.ResolvedPort, traffic.ResolvedPortName, traffic.Protocol)
+	} else {
+		check.Description = fmt.Sprintf("port %s/%d-%d", portRange.Protocol, portRange.From, portRange.To)
+		check.Matches = portRange.AllowsPortProtocol(traffic.ResolvedPort, traffic.Protocol)
+	}
+	if !check.Matches {
+		check.Reason = fmt.Sprintf("traffic is %s", trafficPortString(traffic.ResolvedPort, traffic.ResolvedPortName, traffic.Protocol))
+	}
+	return check
+}
+
+func trafficPortString(port int, portName string, protocol v1.Protocol) string {
+	if portName == "" {
+		return fmt.Sprintf("%s/%d", protocol, port)
+	}
+	return fmt.Sprintf("%s/%d (%s)", protocol, port, portName)
+}
+
+// Table lists each policy's checks -- only selection, for policies which don't select the traffic's pod -- followed
+// by the decision, for ingress then egress
+func (t *TrafficTrace) Table() string {
+	tableString := &strings.Builder{}
+	table := tablewriter.NewWriter(tableString)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Type", "Policy", "Rule", "Check", "Result"})
+	// type, policy and rule are only shown when they change, like merged cells -- but without merging the results
+	var previous []string
+	appendRow := func(row []string) {
+		shown := append([]string{}, row...)
+		for i := 0; i < 3 && previous != nil && row[i] == previous[i]; i++ {
+			shown[i] = ""
+		}
+		previous = row
+		table.Append(shown)
+	}
+
+	for _, direction := range []struct {
+		name  string
+		trace *DirectionTrace
+	}{{"Ingress", t.Ingress}, {"Egress", t.Egress}} {
+		for _, policy := range direction.trace.Policies {
+			for _, check := range policy.Selects {
+				appendRow([]string{direction.name, policy.Policy, "selects pod", check.Description, checkResult(check)})
+			}
+			if policy.Selected && len(policy.Rules) == 0 {
+				appendRow([]string{direction.name, policy.Policy, "rules", "no rules", "no match"})
+			}
+			for _, rule := range policy.Rules {
+				ruleName := fmt.Sprintf("rule %d: %s", rule.Rule, matchString(rule.Matches))
+				if len(rule.Peers) == 0 {
+					appendRow([]string{direction.name, policy.Policy, ruleName, "all peers", "match"})
+				}
+				for _, peer := range rule.Peers {
+					for _, check := range peer.Checks {
+						appendRow([]string{direction.name, policy.Policy, ruleName, fmt.Sprintf("peer %d: %s", peer.Peer, check.Description), checkResult(check)})
+					}
+				}
+				if len(rule.Ports) == 0 {
+					appendRow([]string{direction.name, policy.Policy, ruleName, "all ports", "match"})
+				}
+				for _, check := range rule.Ports {
+					appendRow([]string{direction.name, policy.Policy, ruleName, check.Description, checkResult(check)})
+				}
+			}
+		}
+		appendRow([]string{direction.name, "", "decision", direction.trace.Decision, fmt.Sprintf("allowed: %t", direction.trace.Allowed)})
+	}
+	table.SetFooter([]string{"Is allowed?", fmt.Sprintf("%t", t.Allowed), "", "", ""})
+
+	table.Render()
+	return tableString.String()
+}
+
+func matchString(matches bool) string {
+	if matches {
+		return "match"
+	}
+	return "no match"
+}
+
+func checkResult(check *TraceCheck) string {
+	if check.Matches {
+		return "match"
+	}
+	return fmt.Sprintf("no match: %s", check.Reason)
+}
@@ -0,0 +1,115 @@
+package matcher
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func RunTraceTests() {
+	serializedPolicies := `
+- apiVersion: networking.k8s.io/v1
+  kind: NetworkPolicy
+  metadata:
+    name: allow-b
+    namespace: x
+  spec:
+    podSelector:
+      matchLabels:
+        pod: a
+    ingress:
+    - from:
+      - podSelector:
+          matchLabels:
+            pod: b
+      ports:
+      - port: 81
+      - port: 80
+    - from:
+      - ipBlock:
+          cidr: 10.0.0.0/8
+          except: [10.1.0.0/16]
+    policyTypes: [Ingress]
+- apiVersion: networking.k8s.io/v1
+  kind: NetworkPolicy
+  metadata:
+    name: deny-egress
+    namespace: "y"
+  spec:
+    podSelector: {}
+    policyTypes: [Egress]`
+	var kubePolicies []*networkingv1.NetworkPolicy
+	utils.DoOrDie(yaml.Unmarshal([]byte(serializedPolicies), &kubePolicies))
+	policies := BuildNetworkPolicies(true, kubePolicies)
+
+	traffic := func(fromNamespace string, fromPod string, fromIP string, port int) *Traffic {
+		return &Traffic{
+			Source:       &TrafficPeer{Internal: &InternalPeer{Namespace: fromNamespace, PodLabels: map[string]string{"pod": fromPod}}, IP: fromIP},
+			Destination:  &TrafficPeer{Internal: &InternalPeer{Namespace: "x", PodLabels: map[string]string{"pod": "a"}}, IP: "10.2.0.1"},
+			ResolvedPort: port,
+			Protocol:     v1.ProtocolTCP,
+		}
+	}
+
+	Describe("TraceTraffic", func() {
+		It("Should trace an allowed verdict to the rule which allowed it, port by port", func() {
+			trace := policies.TraceTraffic(traffic("x", "b", "10.1.0.1", 80))
+
+			Expect(trace.Allowed).To(BeTrue())
+			Expect(trace.Ingress.Decision).To(Equal("allowed by x/allow-b ingress rule 1"))
+			Expect(trace.Ingress.Policies).To(HaveLen(2))
+			allowB := trace.Ingress.Policies[0]
+			Expect(allowB.Selected).To(BeTrue())
+			Expect(allowB.Rules[0].Peers[0].Checks).To(Equal([]*TraceCheck{
+				{Description: "namespace x", Matches: true},
+				{Description: "pod selector pod=b", Matches: true},
+			}))
+			Expect(allowB.Rules[0].Ports).To(Equal([]*TraceCheck{
+				{Description: "port TCP/81", Reason: "traffic is TCP/80"},
+				{Description: "port TCP/80", Matches: true},
+			}))
+			Expect(allowB.Rules[1].Peers[0].Checks).To(Equal([]*TraceCheck{
+				{Description: "ipBlock 10.0.0.0/8 except 10.1.0.0/16", Reason: "IP 10.1.0.1 isn't in the block"},
+			}))
+
+			denyEgress := trace.Ingress.Policies[1]
+			Expect(denyEgress.Selected).To(BeFalse())
+			Expect(denyEgress.Selects[0]).To(Equal(&TraceCheck{Description: "namespace y", Reason: "namespace is x"}))
+			Expect(trace.Egress.Decision).To(Equal("allowed: no egress policy selects the pod"))
+		})
+
+		It("Should name the selector requirement which a denied peer didn't meet", func() {
+			trace := policies.TraceTraffic(traffic("x", "c", "192.168.0.1", 80))
+
+			Expect(trace.Allowed).To(BeFalse())
+			Expect(trace.Ingress.Decision).To(Equal("denied: selected by x/allow-b, but none of their ingress rules match"))
+			Expect(trace.Ingress.Policies[0].Rules[0].Peers[0].Checks[1]).To(Equal(&TraceCheck{
+				Description: "pod selector pod=b",
+				Reason:      "pod=b isn't met by labels {pod=c}",
+			}))
+			Expect(trace.Table()).To(ContainSubstring("no match: pod=b isn't met by labels {pod=c}"))
+		})
+
+		It("Should agree with IsTrafficAllowed", func() {
+			for _, t := range []*Traffic{traffic("x", "b", "10.1.0.1", 80), traffic("x", "b", "10.1.0.1", 82), traffic("z", "c", "10.3.0.1", 82), traffic("y", "a", "10.1.0.1", 80)} {
+				trace := policies.TraceTraffic(t)
+				result := policies.IsTrafficAllowed(t)
+				Expect(trace.Allowed).To(Equal(result.IsAllowed()))
+				Expect(trace.Ingress.Allowed).To(Equal(result.Ingress.IsAllowed()))
+				Expect(trace.Egress.Allowed).To(Equal(result.Egress.IsAllowed()))
+			}
+		})
+
+		It("Should say that pods outside the cluster aren't subject to policies", func() {
+			external := traffic("x", "b", "", 80)
+			external.Source = &TrafficPeer{IP: "8.8.8.8"}
+			trace := policies.TraceTraffic(external)
+
+			Expect(trace.Egress.Decision).To(Equal("allowed: 8.8.8.8 is outside the cluster, so isn't subject to egress policies"))
+			Expect(trace.Ingress.Policies[0].Rules[0].Peers[0].Checks[0].Reason).To(Equal("8.8.8.8 is outside the cluster"))
+		})
+	})
+}
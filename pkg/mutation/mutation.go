@@ -0,0 +1,268 @@
+package mutation
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	"github.com/mattfenwick/cyclonus/pkg/generator"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/olekukonko/tablewriter"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Kind is what sort of edit a mutant makes to its policy
+type Kind string
+
+const (
+	KindFlippedSelector Kind = "flipped-selector"
+	KindRemovedRule     Kind = "removed-rule"
+	KindChangedCIDR     Kind = "changed-cidr"
+)
+
+// Mutant is a set of policies with a single, small edit made to one of them
+type Mutant struct {
+	Kind        Kind
+	Policy      string
+	Description string
+	Policies    []*networkingv1.NetworkPolicy `json:"-"`
+}
+
+// Mutants makes every mutant of policies, policy by policy:
+//   - flipped selectors: each of a selector's labels becomes a NotIn, In and NotIn swap, and Exists and
+//     DoesNotExist swap.  Empty selectors, which select everything, aren't flipped.
+//   - removed rules: each ingress and egress rule is removed
+//   - changed CIDRs: each ipBlock's prefix is made one bit shorter and one bit longer, and each of its exceptions is
+//     removed
+func Mutants(policies []*networkingv1.NetworkPolicy) []*Mutant {
+	var mutants []*Mutant
+	for i, policy := range policies {
+		name := fmt.Sprintf("%s/%s", policy.Namespace, policy.Name)
+		mutate := func(kind Kind, description string, edit func(*networkingv1.NetworkPolicy)) {
+			mutated := policy.DeepCopy()
+			edit(mutated)
+			mutantPolicies := append([]*networkingv1.NetworkPolicy{}, policies...)
+			mutantPolicies[i] = mutated
+			mutants = append(mutants, &Mutant{Kind: kind, Policy: name, Description: description, Policies: mutantPolicies})
+		}
+
+		for j, selector := range selectors(policy) {
+			for k, flip := range selectorFlips(selector.Selector) {
+				j, k := j, k
+				mutate(KindFlippedSelector, fmt.Sprintf("%s: %s", selector.Description, flip), func(mutated *networkingv1.NetworkPolicy) {
+					flipSelector(selectors(mutated)[j].Selector, k)
+				})
+			}
+		}
+
+		for j := range policy.Spec.Ingress {
+			j := j
+			mutate(KindRemovedRule, fmt.Sprintf("removed ingress rule %d", j+1), func(mutated *networkingv1.NetworkPolicy) {
+				mutated.Spec.Ingress = append(mutated.Spec.Ingress[:j], mutated.Spec.Ingress[j+1:]...)
+			})
+		}
+		for j := range policy.Spec.Egress {
+			j := j
+			mutate(KindRemovedRule, fmt.Sprintf("removed egress rule %d", j+1), func(mutated *networkingv1.NetworkPolicy) {
+				mutated.Spec.Egress = append(mutated.Spec.Egress[:j], mutated.Spec.Egress[j+1:]...)
+			})
+		}
+
+		for j, peer := range peers(policy) {
+			if peer.Peer.IPBlock == nil {
+				continue
+			}
+			j := j
+			ipBlock := peer.Peer.IPBlock
+			for _, cidr := range []string{resizeCIDR(ipBlock.CIDR, -1), resizeCIDR(ipBlock.CIDR, 1)} {
+				if cidr == "" {
+					continue
+				}
+				cidr := cidr
+				mutate(KindChangedCIDR, fmt.Sprintf("%s: changed ipBlock %s to %s", peer.Description, ipBlock.CIDR, cidr), func(mutated *networkingv1.NetworkPolicy) {
+					peers(mutated)[j].Peer.IPBlock.CIDR = cidr
+				})
+			}
+			for k, except := range ipBlock.Except {
+				k := k
+				mutate(KindChangedCIDR, fmt.Sprintf("%s: removed exception %s from ipBlock %s", peer.Description, except, ipBlock.CIDR), func(mutated *networkingv1.NetworkPolicy) {
+					mutatedBlock := peers(mutated)[j].Peer.IPBlock
+					mutatedBlock.Except = append(mutatedBlock.Except[:k], mutatedBlock.Except[k+1:]...)
+				})
+			}
+		}
+	}
+	return mutants
+}
+
+type peerRef struct {
+	Description string
+	Peer        *networkingv1.NetworkPolicyPeer
+}
+
+// peers points into policy's ingress and egress peers, in order
+func peers(policy *networkingv1.NetworkPolicy) []*peerRef {
+	var refs []*peerRef
+	for i := range policy.Spec.Ingress {
+		for j := range policy.Spec.Ingress[i].From {
+			refs = append(refs, &peerRef{Description: fmt.Sprintf("ingress rule %d peer %d", i+1, j+1), Peer: &policy.Spec.Ingress[i].From[j]})
+		}
+	}
+	for i := range policy.Spec.Egress {
+		for j := range policy.Spec.Egress[i].To {
+			refs = append(refs, &peerRef{Description: fmt.Sprintf("egress rule %d peer %d", i+1, j+1), Peer: &policy.Spec.Egress[i].To[j]})
+		}
+	}
+	return refs
+}
+
+type selectorRef struct {
+	Description string
+	Selector    *metav1.LabelSelector
+}
+
+// selectors points into policy's target selector and its peers' selectors, in order
+func selectors(policy *networkingv1.NetworkPolicy) []*selectorRef {
+	refs := []*selectorRef{{Description: "target pod selector", Selector: &policy.Spec.PodSelector}}
+	for _, peer := range peers(policy) {
+		if peer.Peer.PodSelector != nil {
+			refs = append(refs, &selectorRef{Description: peer.Description + " pod selector", Selector: peer.Peer.PodSelector})
+		}
+		if peer.Peer.NamespaceSelector != nil {
+			refs = append(refs, &selectorRef{Description: peer.Description + " namespace selector", Selector: peer.Peer.NamespaceSelector})
+		}
+	}
+	return refs
+}
+
+var flippedOperators = map[metav1.LabelSelectorOperator]metav1.LabelSelectorOperator{
+	metav1.LabelSelectorOpIn:           metav1.LabelSelectorOpNotIn,
+	metav1.LabelSelectorOpNotIn:        metav1.LabelSelectorOpIn,
+	metav1.LabelSelectorOpExists:       metav1.LabelSelectorOpDoesNotExist,
+	metav1.LabelSelectorOpDoesNotExist: metav1.LabelSelectorOpExists,
+}
+
+func sortedLabelKeys(labels map[string]string) []string {
+	var keys []string
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// selectorFlips describes the ways selector can be flipped: each of its labels, in key order, then each of its
+// expressions
+func selectorFlips(selector *metav1.LabelSelector) []string {
+	var flips []string
+	for _, key := range sortedLabelKeys(selector.MatchLabels) {
+		flips = append(flips, fmt.Sprintf("flipped %s=%s to %s NotIn [%s]", key, selector.MatchLabels[key], key, selector.MatchLabels[key]))
+	}
+	for _, expression := range selector.MatchExpressions {
+		flipped, ok := flippedOperators[expression.Operator]
+		if !ok {
+			continue
+		}
+		flips = append(flips, fmt.Sprintf("flipped %s %s to %s", expression.Key, expression.Operator, flipped))
+	}
+	return flips
+}
+
+// flipSelector makes the flip numbered index by selectorFlips
+func flipSelector(selector *metav1.LabelSelector, index int) {
+	keys := sortedLabelKeys(selector.MatchLabels)
+	if index < len(keys) {
+		key := keys[index]
+		selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{Key: key, Operator: metav1.LabelSelectorOpNotIn, Values: []string{selector.MatchLabels[key]}})
+		delete(selector.MatchLabels, key)
+		return
+	}
+	index -= len(keys)
+	for i, expression := range selector.MatchExpressions {
+		flipped, ok := flippedOperators[expression.Operator]
+		if !ok {
+			continue
+		}
+		if index == 0 {
+			selector.MatchExpressions[i].Operator = flipped
+			return
+		}
+		index--
+	}
+}
+
+// resizeCIDR lengthens cidr's prefix by delta bits -- keeping the start of its range where it can -- or returns ""
+// if cidr can't be parsed or the prefix would be out of range
+func resizeCIDR(cidr string, delta int) string {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return ""
+	}
+	ones, bits := ipNet.Mask.Size()
+	if ones+delta < 0 || ones+delta > bits {
+		return ""
+	}
+	mask := net.CIDRMask(ones+delta, bits)
+	return (&net.IPNet{IP: ipNet.IP.Mask(mask), Mask: mask}).String()
+}
+
+// Result is how a mutant changed the simulated connectivity between resources' pods
+type Result struct {
+	*Mutant
+	// NowAllowed is how many probes the original policies block, but the mutant allows
+	NowAllowed int
+	// NowBlocked is how many probes the original policies allow, but the mutant blocks
+	NowBlocked int
+}
+
+func (r *Result) Changed() int {
+	return r.NowAllowed + r.NowBlocked
+}
+
+// Report is how each of a set of policies' mutants changed their simulated connectivity matrix
+type Report struct {
+	Probes  int
+	Results []*Result
+}
+
+// Analyze simulates probes between all of resources' pods, on all their ports, against policies and each of
+// their mutants, to find which edits to the policies would change what traffic they allow
+func Analyze(policies []*networkingv1.NetworkPolicy, resources *probe.Resources, simplify bool) *Report {
+	original := matcher.BuildNetworkPolicies(simplify, policies)
+	report := &Report{Probes: len(resources.GetJobsForProbeConfig(generator.ProbeAllAvailable).Valid)}
+	for _, mutant := range Mutants(policies) {
+		diff := probe.NewSimulatedDiff(original, matcher.BuildNetworkPolicies(simplify, mutant.Policies), resources)
+		result := &Result{Mutant: mutant}
+		result.NowAllowed, result.NowBlocked = diff.CountChanges()
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// Changed is how many mutants changed the connectivity matrix
+func (r *Report) Changed() int {
+	changed := 0
+	for _, result := range r.Results {
+		if result.Changed() > 0 {
+			changed++
+		}
+	}
+	return changed
+}
+
+// Table lists each mutant and how many probes it changed, followed by how many mutants changed anything.  Mutants
+// which changed nothing are edits the policies aren't sensitive to, at least for these pods.
+func (r *Report) Table() string {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetHeader([]string{"Policy", "Mutation", "Kind", "Now allowed", "Now blocked"})
+	table.SetAutoWrapText(false)
+	for _, result := range r.Results {
+		table.Append([]string{result.Policy, result.Description, string(result.Kind), fmt.Sprintf("%d", result.NowAllowed), fmt.Sprintf("%d", result.NowBlocked)})
+	}
+	table.Render()
+	str.WriteString(fmt.Sprintf("%d of %d mutants changed the simulated connectivity of %d probes\n", r.Changed(), len(r.Results), r.Probes))
+	return str.String()
+}
@@ -0,0 +1,115 @@
+package mutation
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/connectivity/probe"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func mutationTestResources() *probe.Resources {
+	resources := &probe.Resources{Namespaces: map[string]map[string]string{"x": {"ns": "x"}, "y": {"ns": "y"}}}
+	for _, pod := range []struct{ ns, name, ip string }{{"x", "a", "10.0.0.1"}, {"x", "b", "10.0.1.1"}, {"y", "a", "10.0.2.1"}} {
+		kubePod := probe.NewDefaultPod(pod.ns, pod.name, []int{80}, []v1.Protocol{v1.ProtocolTCP}, false, nil)
+		kubePod.IP = pod.ip
+		resources.Pods = append(resources.Pods, kubePod)
+	}
+	return resources
+}
+
+// mutationTestPolicy allows ingress to x/a from x/b, and from 10.0.2.0/24 -- except for y/a's IP
+func mutationTestPolicy() *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "x", Name: "allow-b"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"pod": "a"}},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pod": "b"}}}}},
+				{From: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.2.0/24", Except: []string{"10.0.2.0/30"}}}}},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+func RunMutationTests() {
+	Describe("Mutants", func() {
+		It("Should flip selectors, remove rules and change CIDRs, leaving the original policies alone", func() {
+			policy := mutationTestPolicy()
+			mutants := Mutants([]*networkingv1.NetworkPolicy{policy})
+
+			var descriptions []string
+			for _, mutant := range mutants {
+				Expect(mutant.Policy).To(Equal("x/allow-b"))
+				descriptions = append(descriptions, mutant.Description)
+			}
+			Expect(descriptions).To(Equal([]string{
+				"target pod selector: flipped pod=a to pod NotIn [a]",
+				"ingress rule 1 peer 1 pod selector: flipped pod=b to pod NotIn [b]",
+				"removed ingress rule 1",
+				"removed ingress rule 2",
+				"ingress rule 2 peer 1: changed ipBlock 10.0.2.0/24 to 10.0.2.0/23",
+				"ingress rule 2 peer 1: changed ipBlock 10.0.2.0/24 to 10.0.2.0/25",
+				"ingress rule 2 peer 1: removed exception 10.0.2.0/30 from ipBlock 10.0.2.0/24",
+			}))
+
+			Expect(mutants[0].Policies[0].Spec.PodSelector).To(Equal(metav1.LabelSelector{
+				MatchLabels:      map[string]string{},
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "pod", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"a"}}},
+			}))
+			Expect(mutants[3].Policies[0].Spec.Ingress).To(HaveLen(1))
+			Expect(mutants[6].Policies[0].Spec.Ingress[1].From[0].IPBlock.Except).To(BeEmpty())
+			Expect(policy).To(Equal(mutationTestPolicy()))
+		})
+
+		It("Should flip expressions' operators", func() {
+			selector := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "pod", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+				{Key: "app", Operator: metav1.LabelSelectorOpExists},
+			}}
+			Expect(selectorFlips(selector)).To(Equal([]string{"flipped pod In to NotIn", "flipped app Exists to DoesNotExist"}))
+			flipSelector(selector, 1)
+			Expect(selector.MatchExpressions[0].Operator).To(Equal(metav1.LabelSelectorOpIn))
+			Expect(selector.MatchExpressions[1].Operator).To(Equal(metav1.LabelSelectorOpDoesNotExist))
+			Expect(selectorFlips(&metav1.LabelSelector{})).To(BeEmpty())
+		})
+
+		It("Should resize CIDRs within their address family", func() {
+			Expect(resizeCIDR("10.0.2.0/24", -1)).To(Equal("10.0.2.0/23"))
+			Expect(resizeCIDR("10.0.3.0/24", -1)).To(Equal("10.0.2.0/23"))
+			Expect(resizeCIDR("10.0.2.0/24", 1)).To(Equal("10.0.2.0/25"))
+			Expect(resizeCIDR("0.0.0.0/0", -1)).To(Equal(""))
+			Expect(resizeCIDR("10.0.0.1/32", 1)).To(Equal(""))
+			Expect(resizeCIDR("fd00::/64", 1)).To(Equal("fd00::/65"))
+			Expect(resizeCIDR("not a cidr", 1)).To(Equal(""))
+		})
+	})
+
+	Describe("Analyze", func() {
+		It("Should count the probes each mutant allows or blocks that the original policies don't", func() {
+			report := Analyze([]*networkingv1.NetworkPolicy{mutationTestPolicy()}, mutationTestResources(), true)
+
+			Expect(report.Probes).To(Equal(9))
+			var changes [][2]int
+			for _, result := range report.Results {
+				changes = append(changes, [2]int{result.NowAllowed, result.NowBlocked})
+			}
+			Expect(changes).To(Equal([][2]int{
+				// x/a is no longer a target, so x/a and y/a can reach it; x/b is, so they can't reach x/b
+				{2, 2},
+				// x/a, instead of x/b, is allowed
+				{1, 1},
+				{0, 1},
+				// y/a is in the exception, so the rule allows nothing
+				{0, 0},
+				{0, 0},
+				{0, 0},
+				{1, 0},
+			}))
+			Expect(report.Changed()).To(Equal(4))
+			Expect(report.Table()).To(ContainSubstring("4 of 7 mutants changed the simulated connectivity of 9 probes"))
+		})
+	})
+}
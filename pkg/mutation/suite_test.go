@@ -0,0 +1,14 @@
+package mutation
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMutation(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunMutationTests()
+	RunSpecs(t, "policy mutation suite")
+}
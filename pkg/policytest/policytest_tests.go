@@ -0,0 +1,71 @@
+package policytest
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func RunPolicyTestTests() {
+	Describe("PolicyTest", func() {
+		It("should reject invalid cases", func() {
+			_, err := ParseTestFile([]byte(`
+cases:
+- name: no verdict
+  from: {namespace: web}
+  to: {namespace: db}
+  port: 5432
+`))
+			Expect(err).NotTo(Succeed())
+
+			_, err = ParseTestFile([]byte(`
+cases:
+- name: external pod labels
+  from: {ip: 8.8.8.8, podLabels: {app: frontend}}
+  to: {namespace: db}
+  port: 5432
+  expect: deny
+`))
+			Expect(err).NotTo(Succeed())
+		})
+
+		It("should check verdicts against the matcher", func() {
+			testFile, err := ParseTestFile([]byte(`
+namespaces:
+  web: {team: frontend}
+networkPolicies:
+- metadata: {namespace: db, name: allow-frontend}
+  spec:
+    podSelector: {}
+    ingress:
+    - from: [{namespaceSelector: {matchLabels: {team: frontend}}}]
+cases:
+- name: frontend reaches the database
+  from: {namespace: web, podLabels: {app: frontend}}
+  to: {namespace: db, podLabels: {app: postgres}}
+  port: 5432
+  expect: allow
+- name: other namespaces don't
+  from: {namespace: batch}
+  to: {namespace: db}
+  port: 5432
+  ingress: deny
+  egress: allow
+- name: wrong expectation
+  from: {ip: 8.8.8.8}
+  to: {namespace: db}
+  port: 5432
+  protocol: UDP
+  expect: allow
+`))
+			Expect(err).To(Succeed())
+
+			report := Run([]*TestFile{testFile})
+			Expect(report.Results).To(HaveLen(3))
+			Expect(report.Results[0].Passed()).To(BeTrue())
+			Expect(report.Results[1].Passed()).To(BeTrue())
+			Expect(report.Results[2].Passed()).To(BeFalse())
+			Expect(report.Results[2].Mismatches).To(Equal([]string{"combined: expected allow, got deny"}))
+			Expect(report.Failures()).To(HaveLen(1))
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package policytest
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/olekukonko/tablewriter"
+	"strings"
+)
+
+// Result is how a case fared: the matcher's verdict on its traffic, and which of its expectations that verdict
+// doesn't meet
+type Result struct {
+	TestFile   *TestFile
+	Case       *Case
+	Traffic    *matcher.Traffic
+	Allowed    *matcher.AllowedResult
+	Mismatches []string
+}
+
+func (r *Result) Passed() bool {
+	return len(r.Mismatches) == 0
+}
+
+func verdict(isAllowed bool) string {
+	if isAllowed {
+		return VerdictAllow
+	}
+	return VerdictDeny
+}
+
+func (r *Result) check(name string, expected string, isAllowed bool) {
+	if expected != "" && expected != verdict(isAllowed) {
+		r.Mismatches = append(r.Mismatches, fmt.Sprintf("%s: expected %s, got %s", name, expected, verdict(isAllowed)))
+	}
+}
+
+type Report struct {
+	Results []*Result
+}
+
+// Run checks every case of every test file against the matcher
+func Run(testFiles []*TestFile) *Report {
+	report := &Report{}
+	for _, testFile := range testFiles {
+		policy := testFile.Policy()
+		for _, c := range testFile.Cases {
+			traffic := c.Traffic(testFile.Namespaces)
+			allowed := policy.IsTrafficAllowed(traffic)
+			result := &Result{TestFile: testFile, Case: c, Traffic: traffic, Allowed: allowed}
+			result.check("ingress", c.Ingress, allowed.Ingress.IsAllowed())
+			result.check("egress", c.Egress, allowed.Egress.IsAllowed())
+			result.check("combined", c.Expect, allowed.IsAllowed())
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report
+}
+
+func (r *Report) Failures() []*Result {
+	var failures []*Result
+	for _, result := range r.Results {
+		if !result.Passed() {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+func (r *Report) Table() string {
+	str := &strings.Builder{}
+	table := tablewriter.NewWriter(str)
+	table.SetHeader([]string{"File", "Case", "Ingress", "Egress", "Combined", "Result"})
+	table.SetRowLine(true)
+	table.SetReflowDuringAutoWrap(false)
+	table.SetAutoWrapText(false)
+
+	for _, result := range r.Results {
+		status := "pass"
+		if !result.Passed() {
+			status = "FAIL\n" + strings.Join(result.Mismatches, "\n")
+		}
+		table.Append([]string{
+			result.TestFile.Path,
+			result.Case.Name,
+			verdict(result.Allowed.Ingress.IsAllowed()),
+			verdict(result.Allowed.Egress.IsAllowed()),
+			verdict(result.Allowed.IsAllowed()),
+			status,
+		})
+	}
+
+	table.Render()
+	return str.String()
+}
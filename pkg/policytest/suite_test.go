@@ -0,0 +1,14 @@
+package policytest
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestPolicyTest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunPolicyTestTests()
+	RunSpecs(t, "policytest suite")
+}
@@ -0,0 +1,229 @@
+package policytest
+
+import (
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/kube/anp"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/yaml"
+	"sort"
+)
+
+const (
+	VerdictAllow = "allow"
+	VerdictDeny  = "deny"
+
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+)
+
+// Endpoint is one end of a traffic tuple: a pod, given by its namespace and labels, or -- if there's no namespace --
+// an IP outside the cluster
+type Endpoint struct {
+	Namespace string            `json:"namespace,omitempty"`
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+}
+
+func (e *Endpoint) trafficPeer(namespaces map[string]map[string]string) *matcher.TrafficPeer {
+	if e.Namespace == "" {
+		return &matcher.TrafficPeer{IP: e.IP}
+	}
+	nsLabels, ok := namespaces[e.Namespace]
+	if !ok {
+		nsLabels = map[string]string{namespaceNameLabel: e.Namespace}
+	}
+	return &matcher.TrafficPeer{
+		Internal: &matcher.InternalPeer{
+			PodLabels:       e.PodLabels,
+			NamespaceLabels: nsLabels,
+			Namespace:       e.Namespace,
+		},
+		IP: e.IP,
+	}
+}
+
+func (e *Endpoint) validate() error {
+	if e.Namespace == "" && e.IP == "" {
+		return errors.Errorf("endpoint needs a namespace, or an ip if it's outside the cluster")
+	}
+	if e.Namespace == "" && len(e.PodLabels) > 0 {
+		return errors.Errorf("endpoint outside the cluster can't have pod labels")
+	}
+	return nil
+}
+
+// Case is a traffic tuple, and the verdict the policies should reach on it.  Expect is the overall verdict, while
+// Ingress and Egress are the verdicts of each direction; any of them may be left out, but not all of them.
+type Case struct {
+	Name     string      `json:"name"`
+	From     Endpoint    `json:"from"`
+	To       Endpoint    `json:"to"`
+	Port     int         `json:"port"`
+	PortName string      `json:"portName,omitempty"`
+	Protocol v1.Protocol `json:"protocol,omitempty"`
+	Expect   string      `json:"expect,omitempty"`
+	Ingress  string      `json:"ingress,omitempty"`
+	Egress   string      `json:"egress,omitempty"`
+}
+
+func (c *Case) protocol() v1.Protocol {
+	if c.Protocol == "" {
+		return v1.ProtocolTCP
+	}
+	return c.Protocol
+}
+
+func (c *Case) Traffic(namespaces map[string]map[string]string) *matcher.Traffic {
+	return &matcher.Traffic{
+		Source:           c.From.trafficPeer(namespaces),
+		Destination:      c.To.trafficPeer(namespaces),
+		ResolvedPort:     c.Port,
+		ResolvedPortName: c.PortName,
+		Protocol:         c.protocol(),
+	}
+}
+
+func (c *Case) Validate() error {
+	if c.Name == "" {
+		return errors.Errorf("case missing name")
+	}
+	if err := c.From.validate(); err != nil {
+		return errors.WithMessagef(err, "case '%s' from", c.Name)
+	}
+	if err := c.To.validate(); err != nil {
+		return errors.WithMessagef(err, "case '%s' to", c.Name)
+	}
+	if c.Port <= 0 {
+		return errors.Errorf("case '%s' missing port", c.Name)
+	}
+	if _, err := kube.ParseProtocol(string(c.protocol())); err != nil {
+		return errors.WithMessagef(err, "case '%s'", c.Name)
+	}
+	if c.Expect == "" && c.Ingress == "" && c.Egress == "" {
+		return errors.Errorf("case '%s' needs at least one of expect, ingress and egress", c.Name)
+	}
+	for _, verdict := range []string{c.Expect, c.Ingress, c.Egress} {
+		if verdict != "" && verdict != VerdictAllow && verdict != VerdictDeny {
+			return errors.Errorf("case '%s': verdicts must be %s or %s, found '%s'", c.Name, VerdictAllow, VerdictDeny, verdict)
+		}
+	}
+	return nil
+}
+
+// TestFile is a set of policies, and the cases to check them with.  Namespaces gives the labels of namespaces; those
+// not listed only get the automatic kubernetes.io/metadata.name label.
+type TestFile struct {
+	Path                       string                          `json:"-"`
+	Namespaces                 map[string]map[string]string    `json:"namespaces,omitempty"`
+	NetworkPolicies            []*networkingv1.NetworkPolicy   `json:"networkPolicies,omitempty"`
+	AdminNetworkPolicies       []*anp.AdminNetworkPolicy       `json:"adminNetworkPolicies,omitempty"`
+	BaselineAdminNetworkPolicy *anp.BaselineAdminNetworkPolicy `json:"baselineAdminNetworkPolicy,omitempty"`
+	Cases                      []*Case                         `json:"cases"`
+}
+
+func (t *TestFile) Policy() *matcher.Policy {
+	policy := matcher.BuildNetworkPolicies(true, t.NetworkPolicies)
+	policy.AddAdminPolicies(matcher.BuildAdminNetworkPolicies(t.AdminNetworkPolicies))
+	if t.BaselineAdminNetworkPolicy != nil {
+		policy.BaselinePolicy = matcher.BuildBaselineAdminNetworkPolicy(t.BaselineAdminNetworkPolicy)
+	}
+	return policy
+}
+
+func ParseTestFile(bytes []byte) (*TestFile, error) {
+	testFile := &TestFile{}
+	if err := yaml.UnmarshalStrict(bytes, testFile); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal test file")
+	}
+	if len(testFile.Cases) == 0 {
+		return nil, errors.Errorf("test file has no cases")
+	}
+	for _, c := range testFile.Cases {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	for _, policy := range testFile.NetworkPolicies {
+		defaultPolicyTypes(policy)
+	}
+	return testFile, nil
+}
+
+// defaultPolicyTypes fills in policyTypes, if missing, the same way the apiserver does: Ingress always, and Egress
+// if there are egress rules
+func defaultPolicyTypes(policy *networkingv1.NetworkPolicy) {
+	if len(policy.Spec.PolicyTypes) > 0 {
+		return
+	}
+	policy.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	if len(policy.Spec.Egress) > 0 {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+	}
+}
+
+// ReadTestFiles reads test files from each path, walking directories for .yaml, .yml and .json files, i.e.:
+//
+//	namespaces:
+//	  web: {team: frontend}
+//	networkPolicies:
+//	- metadata: {namespace: db, name: allow-frontend}
+//	  spec:
+//	    podSelector: {}
+//	    ingress:
+//	    - from: [{namespaceSelector: {matchLabels: {team: frontend}}}]
+//	cases:
+//	- name: frontend reaches the database
+//	  from: {namespace: web, podLabels: {app: frontend}}
+//	  to: {namespace: db, podLabels: {app: postgres}}
+//	  port: 5432
+//	  expect: allow
+//	- name: nothing outside the cluster does
+//	  from: {ip: 8.8.8.8}
+//	  to: {namespace: db, podLabels: {app: postgres}}
+//	  port: 5432
+//	  ingress: deny
+func ReadTestFiles(paths []string) ([]*TestFile, error) {
+	var testFiles []*TestFile
+	for _, root := range paths {
+		var filePaths []string
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return errors.Wrapf(err, "unable to walk path %s", path)
+			}
+			if info.IsDir() {
+				return nil
+			}
+			switch filepath.Ext(path) {
+			case ".yaml", ".yml", ".json":
+				filePaths = append(filePaths, path)
+			default:
+				if path == root {
+					filePaths = append(filePaths, path)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(filePaths)
+		for _, path := range filePaths {
+			bytes, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to read file %s", path)
+			}
+			testFile, err := ParseTestFile(bytes)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "invalid test file %s", path)
+			}
+			testFile.Path = path
+			testFiles = append(testFiles, testFile)
+		}
+	}
+	return testFiles, nil
+}
@@ -0,0 +1,14 @@
+package synthesis
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestSynthesis(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSynthesisTests()
+	RunSpecs(t, "policy synthesis suite")
+}
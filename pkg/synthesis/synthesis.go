@@ -0,0 +1,315 @@
+package synthesis
+
+import (
+	"fmt"
+	"github.com/mattfenwick/cyclonus/pkg/kube"
+	"github.com/mattfenwick/cyclonus/pkg/matcher"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+)
+
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// Workload is a set of pods, given by their namespace and labels
+type Workload struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Labels    map[string]string `json:"labels"`
+}
+
+type Port struct {
+	Port     int         `json:"port"`
+	Protocol v1.Protocol `json:"protocol,omitempty"`
+}
+
+func (p Port) protocol() v1.Protocol {
+	if p.Protocol == "" {
+		return v1.ProtocolTCP
+	}
+	return p.Protocol
+}
+
+func (p Port) String() string {
+	return fmt.Sprintf("%s/%d", p.protocol(), p.Port)
+}
+
+// Connection is traffic from one workload to another, by name: on the given ports or, if there are none, on all
+// ports
+type Connection struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Ports []Port `json:"ports,omitempty"`
+}
+
+func (c *Connection) covers(port Port) bool {
+	if len(c.Ports) == 0 {
+		return true
+	}
+	for _, p := range c.Ports {
+		if p.String() == port.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// Spec is the desired connectivity between workloads: traffic between them is allowed only if it's listed in Allow.
+// Deny lists traffic which is expected to be denied, to document it; it's an error for it to overlap Allow.
+type Spec struct {
+	Workloads []*Workload   `json:"workloads"`
+	Allow     []*Connection `json:"allow"`
+	Deny      []*Connection `json:"deny,omitempty"`
+}
+
+// ReadSpec reads a spec from yaml or json, i.e.:
+//
+//	workloads:
+//	- {name: frontend, namespace: web, labels: {app: frontend}}
+//	- {name: api, namespace: web, labels: {app: api}}
+//	- {name: db, namespace: data, labels: {app: postgres}}
+//	allow:
+//	- {from: frontend, to: api, ports: [{port: 8080}]}
+//	- {from: api, to: db, ports: [{port: 5432, protocol: TCP}]}
+//	deny:
+//	- {from: frontend, to: db}
+func ReadSpec(path string) (*Spec, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read file %s", path)
+	}
+	spec := &Spec{}
+	if err := yaml.UnmarshalStrict(bytes, spec); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal connectivity spec %s", path)
+	}
+	return spec, errors.WithMessagef(spec.Validate(), "invalid connectivity spec %s", path)
+}
+
+func (s *Spec) workloadsByName() map[string]*Workload {
+	workloads := map[string]*Workload{}
+	for _, workload := range s.Workloads {
+		workloads[workload.Name] = workload
+	}
+	return workloads
+}
+
+func (s *Spec) Validate() error {
+	workloads := map[string]*Workload{}
+	for _, workload := range s.Workloads {
+		if workload.Name == "" || workload.Namespace == "" {
+			return errors.Errorf("workload needs a name and a namespace")
+		}
+		if _, ok := workloads[workload.Name]; ok {
+			return errors.Errorf("duplicate workload %s", workload.Name)
+		}
+		workloads[workload.Name] = workload
+	}
+	for _, connection := range append(append([]*Connection{}, s.Allow...), s.Deny...) {
+		for _, name := range []string{connection.From, connection.To} {
+			if _, ok := workloads[name]; !ok {
+				return errors.Errorf("connection from %s to %s: unknown workload %s", connection.From, connection.To, name)
+			}
+		}
+		for _, port := range connection.Ports {
+			if port.Port <= 0 {
+				return errors.Errorf("connection from %s to %s: invalid port %d", connection.From, connection.To, port.Port)
+			}
+			if _, err := kube.ParseProtocol(string(port.protocol())); err != nil {
+				return errors.WithMessagef(err, "connection from %s to %s", connection.From, connection.To)
+			}
+		}
+	}
+	for _, deny := range s.Deny {
+		for _, port := range s.Ports() {
+			if deny.covers(port) && s.isAllowed(deny.From, deny.To, port) {
+				return errors.Errorf("connection from %s to %s on %s is both allowed and denied", deny.From, deny.To, port.String())
+			}
+		}
+	}
+	return nil
+}
+
+// Ports are all the ports connections are given on, sorted; if there are none, TCP/80 stands in for all ports
+func (s *Spec) Ports() []Port {
+	ports := map[string]Port{}
+	for _, connection := range append(append([]*Connection{}, s.Allow...), s.Deny...) {
+		for _, port := range connection.Ports {
+			ports[port.String()] = Port{Port: port.Port, Protocol: port.protocol()}
+		}
+	}
+	if len(ports) == 0 {
+		return []Port{{Port: 80, Protocol: v1.ProtocolTCP}}
+	}
+	var sorted []Port
+	for _, port := range ports {
+		sorted = append(sorted, port)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].protocol() != sorted[j].protocol() {
+			return sorted[i].protocol() < sorted[j].protocol()
+		}
+		return sorted[i].Port < sorted[j].Port
+	})
+	return sorted
+}
+
+func (s *Spec) isAllowed(from string, to string, port Port) bool {
+	for _, allow := range s.Allow {
+		if allow.From == from && allow.To == to && allow.covers(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// Synthesize builds a policy for each workload, isolating it for ingress, with a rule for each set of ports its
+// allowed sources are allowed on.  Egress isn't isolated: denying traffic at its destination is enough.
+func Synthesize(spec *Spec) []*networkingv1.NetworkPolicy {
+	workloads := spec.workloadsByName()
+	var policies []*networkingv1.NetworkPolicy
+	for _, workload := range spec.Workloads {
+		policy := &networkingv1.NetworkPolicy{
+			TypeMeta: metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: workload.Namespace,
+				Name:      fmt.Sprintf("%s-ingress", workload.Name),
+			},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: workload.Labels},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		}
+		// sources allowed on the same ports share a rule, in the order they're first allowed
+		var portKeys []string
+		rules := map[string]*networkingv1.NetworkPolicyIngressRule{}
+		sources := map[string]map[string]bool{}
+		for _, allow := range spec.Allow {
+			if allow.To != workload.Name {
+				continue
+			}
+			portKey := portsKey(allow.Ports)
+			rule, ok := rules[portKey]
+			if !ok {
+				rule = &networkingv1.NetworkPolicyIngressRule{Ports: policyPorts(allow.Ports)}
+				rules[portKey] = rule
+				sources[portKey] = map[string]bool{}
+				portKeys = append(portKeys, portKey)
+			}
+			if !sources[portKey][allow.From] {
+				sources[portKey][allow.From] = true
+				rule.From = append(rule.From, peer(workloads[allow.From], workload.Namespace))
+			}
+		}
+		for _, portKey := range portKeys {
+			policy.Spec.Ingress = append(policy.Spec.Ingress, *rules[portKey])
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+func portsKey(ports []Port) string {
+	var keys []string
+	for _, port := range ports {
+		keys = append(keys, port.String())
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func policyPorts(ports []Port) []networkingv1.NetworkPolicyPort {
+	var policyPorts []networkingv1.NetworkPolicyPort
+	for _, port := range ports {
+		protocol := port.protocol()
+		number := intstr.FromInt(port.Port)
+		policyPorts = append(policyPorts, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &number})
+	}
+	return policyPorts
+}
+
+// peer selects source's pods from a policy in namespace; sources in other namespaces are selected by namespace name
+func peer(source *Workload, namespace string) networkingv1.NetworkPolicyPeer {
+	peer := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: source.Labels}}
+	if source.Namespace != namespace {
+		peer.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: source.Namespace}}
+	}
+	return peer
+}
+
+// Mismatch is traffic between workloads whose verdict, according to pkg/matcher, isn't what the spec wants
+type Mismatch struct {
+	From    string
+	To      string
+	Port    string
+	Allowed bool
+}
+
+// Verification is the result of checking policies against a spec: traffic between every pair of workloads, on each
+// of the spec's ports
+type Verification struct {
+	Checked    int
+	Mismatches []*Mismatch
+}
+
+// Verify checks that policies realize spec.  Workloads whose labels overlap -- i.e. one's labels are a subset of
+// another's -- can't always be told apart by label selectors, which shows up here as mismatches.
+func Verify(spec *Spec, policies []*networkingv1.NetworkPolicy) *Verification {
+	explained := matcher.BuildNetworkPolicies(true, policies)
+	verification := &Verification{}
+	for _, from := range spec.Workloads {
+		for _, to := range spec.Workloads {
+			for _, port := range spec.Ports() {
+				verification.Checked++
+				traffic := &matcher.Traffic{
+					Source:       trafficPeer(from),
+					Destination:  trafficPeer(to),
+					ResolvedPort: port.Port,
+					Protocol:     port.protocol(),
+				}
+				allowed := explained.IsTrafficAllowed(traffic).IsAllowed()
+				if allowed != spec.isAllowed(from.Name, to.Name, port) {
+					verification.Mismatches = append(verification.Mismatches, &Mismatch{From: from.Name, To: to.Name, Port: port.String(), Allowed: allowed})
+				}
+			}
+		}
+	}
+	return verification
+}
+
+// trafficPeer is one of workload's pods; its namespace only has the automatic kubernetes.io/metadata.name label,
+// since that's all synthesized policies select namespaces by
+func trafficPeer(workload *Workload) *matcher.TrafficPeer {
+	return &matcher.TrafficPeer{
+		Internal: &matcher.InternalPeer{
+			PodLabels:       workload.Labels,
+			NamespaceLabels: map[string]string{namespaceNameLabel: workload.Namespace},
+			Namespace:       workload.Namespace,
+		},
+	}
+}
+
+// Table lists the mismatches, followed by a summary
+func (v *Verification) Table() string {
+	str := &strings.Builder{}
+	if len(v.Mismatches) > 0 {
+		table := tablewriter.NewWriter(str)
+		table.SetHeader([]string{"From", "To", "Port", "Wanted", "Policies"})
+		for _, mismatch := range v.Mismatches {
+			wanted, actual := "deny", "allow"
+			if !mismatch.Allowed {
+				wanted, actual = actual, wanted
+			}
+			table.Append([]string{mismatch.From, mismatch.To, mismatch.Port, wanted, actual})
+		}
+		table.Render()
+	}
+	str.WriteString(fmt.Sprintf("%d of %d workload pairs and ports don't match the spec\n", len(v.Mismatches), v.Checked))
+	return str.String()
+}
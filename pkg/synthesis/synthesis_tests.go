@@ -0,0 +1,93 @@
+package synthesis
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func RunSynthesisTests() {
+	Describe("Synthesize", func() {
+		spec := &Spec{
+			Workloads: []*Workload{
+				{Name: "frontend", Namespace: "web", Labels: map[string]string{"app": "frontend"}},
+				{Name: "api", Namespace: "web", Labels: map[string]string{"app": "api"}},
+				{Name: "db", Namespace: "data", Labels: map[string]string{"app": "postgres"}},
+			},
+			Allow: []*Connection{
+				{From: "frontend", To: "api", Ports: []Port{{Port: 8080}}},
+				{From: "api", To: "db", Ports: []Port{{Port: 5432, Protocol: v1.ProtocolTCP}}},
+				{From: "frontend", To: "db"},
+			},
+			Deny: []*Connection{
+				{From: "db", To: "api"},
+			},
+		}
+
+		It("Should build an ingress policy per workload, with a rule per set of ports", func() {
+			Expect(spec.Validate()).To(Succeed())
+			policies := Synthesize(spec)
+
+			Expect(policies).To(HaveLen(3))
+			Expect(policies[0].Name).To(Equal("frontend-ingress"))
+			Expect(policies[0].Spec.Ingress).To(BeEmpty())
+			Expect(policies[0].Spec.PolicyTypes).To(Equal([]networkingv1.PolicyType{networkingv1.PolicyTypeIngress}))
+
+			tcp := v1.ProtocolTCP
+			port5432 := intstr.FromInt(5432)
+			Expect(policies[2].Namespace).To(Equal("data"))
+			Expect(policies[2].Spec.PodSelector).To(Equal(metav1.LabelSelector{MatchLabels: map[string]string{"app": "postgres"}}))
+			Expect(policies[2].Spec.Ingress).To(Equal([]networkingv1.NetworkPolicyIngressRule{
+				{
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port5432}},
+					From: []networkingv1.NetworkPolicyPeer{{
+						PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "web"}},
+					}},
+				},
+				{
+					From: []networkingv1.NetworkPolicyPeer{{
+						PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "web"}},
+					}},
+				},
+			}))
+			// same namespace: no namespace selector
+			Expect(policies[1].Spec.Ingress[0].From[0].NamespaceSelector).To(BeNil())
+		})
+
+		It("Should verify that the policies realize the spec", func() {
+			verification := Verify(spec, Synthesize(spec))
+			Expect(verification.Checked).To(Equal(18))
+			Expect(verification.Mismatches).To(BeEmpty())
+		})
+
+		It("Should find mismatches of workloads with overlapping labels", func() {
+			overlapping := &Spec{
+				Workloads: []*Workload{
+					{Name: "api", Namespace: "web", Labels: map[string]string{"app": "api"}},
+					{Name: "api-canary", Namespace: "web", Labels: map[string]string{"app": "api", "track": "canary"}},
+					{Name: "frontend", Namespace: "web", Labels: map[string]string{"app": "frontend"}},
+				},
+				Allow: []*Connection{{From: "frontend", To: "api"}},
+			}
+			verification := Verify(overlapping, Synthesize(overlapping))
+			Expect(verification.Mismatches).To(Equal([]*Mismatch{{From: "frontend", To: "api-canary", Port: "TCP/80", Allowed: true}}))
+			Expect(verification.Table()).To(ContainSubstring("1 of 9 workload pairs and ports don't match the spec"))
+		})
+
+		It("Should reject specs which allow and deny the same traffic", func() {
+			conflicting := &Spec{
+				Workloads: spec.Workloads,
+				Allow:     []*Connection{{From: "frontend", To: "api", Ports: []Port{{Port: 8080}}}},
+				Deny:      []*Connection{{From: "frontend", To: "api"}},
+			}
+			Expect(conflicting.Validate()).To(MatchError("connection from frontend to api on TCP/8080 is both allowed and denied"))
+			unknown := &Spec{Workloads: spec.Workloads, Allow: []*Connection{{From: "frontend", To: "cache"}}}
+			Expect(unknown.Validate()).ToNot(Succeed())
+		})
+	})
+}
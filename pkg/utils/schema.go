@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+)
+
+// SchemaVersionField is the top-level json field holding a saved document's schema version.  Documents written
+// before versioning don't have it, and are version 1.
+const SchemaVersionField = "SchemaVersion"
+
+// SchemaMigration upgrades a document, decoded as generic json, from its version to the next one
+type SchemaMigration func(doc map[string]interface{}) error
+
+// MigrateSchema upgrades a json document to the current schema version, by running migrations[v] for each version v
+// from the document's up to current, so that it can be unmarshaled into the current structs.  Documents from a newer
+// version than current are rejected, rather than being read with fields silently missing.
+func MigrateSchema(bytes []byte, current int, migrations map[int]SchemaMigration) ([]byte, error) {
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(bytes, &doc); err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal document")
+	}
+	version := 1
+	if raw, ok := doc[SchemaVersionField]; ok {
+		number, ok := raw.(float64)
+		if !ok || number != float64(int(number)) || number < 1 {
+			return nil, errors.Errorf("invalid schema version %+v", raw)
+		}
+		version = int(number)
+	}
+	if version > current {
+		return nil, errors.Errorf("schema version %d is newer than the latest version this cyclonus supports, %d; upgrade cyclonus to read it", version, current)
+	}
+	if version == current {
+		return bytes, nil
+	}
+	for ; version < current; version++ {
+		migration, ok := migrations[version]
+		if !ok {
+			return nil, errors.Errorf("no migration from schema version %d", version)
+		}
+		if err := migration(doc); err != nil {
+			return nil, errors.WithMessagef(err, "unable to migrate from schema version %d", version)
+		}
+		doc[SchemaVersionField] = version + 1
+	}
+	migrated, err := json.Marshal(doc)
+	return migrated, errors.Wrapf(err, "unable to marshal migrated document")
+}
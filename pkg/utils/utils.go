@@ -1,10 +1,12 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"sigs.k8s.io/yaml"
+	"time"
 )
 
 func DoOrDie(err error) {
@@ -28,3 +30,15 @@ func YamlString(obj interface{}) string {
 func PrintJson(obj interface{}) {
 	fmt.Printf("%s\n", JsonString(obj))
 }
+
+// Sleep waits for duration, or until ctx is done, whichever comes first; it returns ctx's error if ctx is done
+func Sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var yamlDocumentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// SplitYamlDocuments splits a multi-document yaml stream on '---' lines, dropping documents which are empty
+func SplitYamlDocuments(yamlString string) []string {
+	var docs []string
+	for _, doc := range yamlDocumentSeparator.Split(yamlString, -1) {
+		if strings.TrimSpace(doc) != "" {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
@@ -21,6 +21,8 @@ type Args struct {
 	//Verbosity string
 	Jobs        string
 	Concurrency int
+	Port        int
+	BindAddress string
 }
 
 func SetupRootCommand() *cobra.Command {
@@ -37,8 +39,11 @@ func SetupRootCommand() *cobra.Command {
 
 	command.Flags().IntVar(&args.Concurrency, "concurrency", 10, "number of jobs to simultaneously run")
 
-	command.Flags().StringVar(&args.Jobs, "jobs", "", "JSON-formatted string of jobs")
-	utils.DoOrDie(command.MarkFlagRequired("jobs"))
+	command.Flags().StringVar(&args.Jobs, "jobs", "", "JSON-formatted string of jobs; required unless serving")
+
+	command.Flags().IntVar(&args.Port, "port", 0, "if non-zero, serve batches of jobs over HTTP on this port -- issuing each from inside its pod's network namespace -- instead of running --jobs; needs the host's pid namespace, and privileges to enter network namespaces.  Every batch must carry the token in $"+TokenEnvVar+"")
+
+	command.Flags().StringVar(&args.BindAddress, "bind-address", "", "address to serve on with --port; if empty, serves on every address")
 
 	return command
 }
@@ -46,6 +51,21 @@ func SetupRootCommand() *cobra.Command {
 func RunWorkerCommand(args *Args) {
 	//utils.DoOrDie(utils.SetUpLogger(args.Verbosity))
 
+	if args.Port != 0 {
+		if args.Jobs != "" {
+			utils.DoOrDie(errors.Errorf("--jobs can't be used with --port"))
+		}
+		token := os.Getenv(TokenEnvVar)
+		if token == "" {
+			utils.DoOrDie(errors.Errorf("$%s is required when serving with --port", TokenEnvVar))
+		}
+		utils.DoOrDie(NewServer(args.Concurrency, token).ListenAndServe(args.BindAddress, args.Port))
+		return
+	}
+	if args.Jobs == "" {
+		utils.DoOrDie(errors.Errorf("--jobs is required, unless serving with --port"))
+	}
+
 	out, err := RunWorker(args.Jobs, args.Concurrency)
 	utils.DoOrDie(err)
 	fmt.Printf("%s\n", out)
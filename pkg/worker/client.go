@@ -5,6 +5,7 @@ import (
 	"github.com/mattfenwick/cyclonus/pkg/kube"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
 )
 
 type Client struct {
@@ -40,6 +41,68 @@ func (c *Client) Batch(b *Batch) ([]*Result, error) {
 	return results, nil
 }
 
+// DaemonSetClient sends each batch to the worker on its pod's node -- one of a daemonset's pods, serving on Port --
+// through the API server's pod proxy, instead of exec'ing the worker in the pod
+type DaemonSetClient struct {
+	Kubernetes kube.IKubernetes
+	// Namespace is where the workers' pods are; nothing else may run in it
+	Namespace string
+	Port      int
+	// Token is what the workers were deployed with; see Server
+	Token string
+}
+
+func (c *DaemonSetClient) Batch(b *Batch) ([]*Result, error) {
+	pod, err := c.Kubernetes.GetPod(b.Namespace, b.Pod)
+	if err != nil {
+		return nil, err
+	}
+	workerPod, err := c.workerPod(pod.Spec.NodeName)
+	if err != nil {
+		return nil, err
+	}
+	batch := *b
+	batch.PodUID = string(pod.UID)
+	batch.Token = c.Token
+	bytes, err := json.Marshal(&batch)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to marshal json")
+	}
+	log.Infof("issuing %s batch with %d requests to worker %s/%s", b.Key(), len(b.Requests), c.Namespace, workerPod)
+	response, err := c.Kubernetes.ProxyPodRequest(c.Namespace, workerPod, c.Port, BatchPath, bytes)
+	if err != nil {
+		return nil, err
+	}
+	log.Tracef("%s worker response:\n%s\n", b.Key(), response)
+
+	var results []*Result
+	err = json.Unmarshal(response, &results)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to unmarshal json")
+	}
+
+	if len(results) != len(b.Requests) {
+		return results, errors.Errorf("expected %d results, but got only %d", len(b.Requests), len(results))
+	}
+
+	return results, nil
+}
+
+// workerPod finds the running worker on node
+func (c *DaemonSetClient) workerPod(node string) (string, error) {
+	pods, err := c.Kubernetes.GetPodsInNamespace(c.Namespace)
+	if err != nil {
+		return "", err
+	}
+	for _, pod := range pods {
+		// workers being deleted, i.e. replaced by a redeployment, don't have the current token
+		if pod.Spec.NodeName == node && pod.Status.Phase == v1.PodRunning && pod.DeletionTimestamp == nil {
+			return pod.Name, nil
+		}
+	}
+	return "", errors.Errorf("no running worker in namespace %s on node '%s'", c.Namespace, node)
+}
+
 /*
 type Client struct {
 	Resty *resty.Client
@@ -4,13 +4,20 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	"net"
+	"strconv"
 )
 
 type Batch struct {
 	Namespace string
 	Pod       string
 	Container string
-	Requests  []*Request
+	// PodUID is set for batches sent to the worker on the pod's node, which issues them from inside the pod's network
+	// namespace, rather than from the pod itself
+	PodUID string `json:",omitempty"`
+	// Token authenticates batches sent to a worker on a node; see Server
+	Token    string `json:",omitempty"`
+	Requests []*Request
 }
 
 func (b *Batch) Key() string {
@@ -41,10 +48,13 @@ type Request struct {
 	Protocol v1.Protocol
 	Host     string
 	Port     int
+	// Exchanges is how many times the request is issued, one after another; 0 is the same as 1
+	Exchanges int `json:",omitempty"`
 }
 
+// Address brackets IPv6 hosts, i.e. [fd00::1]:80
 func (r *Request) Address() string {
-	return fmt.Sprintf("%s:%d", r.Host, r.Port)
+	return net.JoinHostPort(r.Host, strconv.Itoa(r.Port))
 }
 
 func (r *Request) Command() []string {
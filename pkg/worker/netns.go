@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// findPodNetworkNamespaceInProc finds a process of the pod with podUID under procPath -- by the pod's cgroup, which
+// has the UID in its name, with dashes for cgroupfs and underscores for systemd -- and returns the path of its
+// network namespace
+func findPodNetworkNamespaceInProc(procPath string, podUID string) (string, error) {
+	if podUID == "" {
+		return "", errors.Errorf("unable to find network namespace: no pod UID")
+	}
+	names := []string{"pod" + podUID, "pod" + strings.ReplaceAll(podUID, "-", "_")}
+	cgroupPaths, err := filepath.Glob(filepath.Join(procPath, "[0-9]*", "cgroup"))
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to list processes")
+	}
+	for _, cgroupPath := range cgroupPaths {
+		// processes come and go while they're being listed, so ones which can't be read are skipped
+		cgroup, err := ioutil.ReadFile(cgroupPath)
+		if err != nil {
+			continue
+		}
+		for _, name := range names {
+			if strings.Contains(string(cgroup), name) {
+				return filepath.Join(filepath.Dir(cgroupPath), "ns", "net"), nil
+			}
+		}
+	}
+	return "", errors.Errorf("unable to find a process of pod %s; the worker must run in the host's pid namespace", podUID)
+}
@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+package worker
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+	"os"
+	"runtime"
+)
+
+func findPodNetworkNamespace(podUID string) (string, error) {
+	return findPodNetworkNamespaceInProc("/proc", podUID)
+}
+
+func setNetworkNamespace(file *os.File) error {
+	return unix.Setns(int(file.Fd()), unix.CLONE_NEWNET)
+}
+
+// inNetworkNamespace runs f on a thread which is in the network namespace at namespacePath; processes started by f
+// are in it too
+func inNetworkNamespace(namespacePath string, f func()) error {
+	target, err := os.Open(namespacePath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to open network namespace %s", namespacePath)
+	}
+	defer target.Close()
+
+	runtime.LockOSThread()
+	original, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return errors.Wrapf(err, "unable to open current network namespace")
+	}
+	defer original.Close()
+
+	if err := setNetworkNamespace(target); err != nil {
+		runtime.UnlockOSThread()
+		return errors.Wrapf(err, "unable to enter network namespace %s", namespacePath)
+	}
+	f()
+	if err := setNetworkNamespace(original); err != nil {
+		// the thread is stuck in the pod's network namespace: it's left locked, so that it's thrown away once this
+		// goroutine exits, instead of being reused
+		log.Errorf("unable to leave network namespace %s: %+v", namespacePath, err)
+		return errors.Wrapf(err, "unable to leave network namespace %s", namespacePath)
+	}
+	runtime.UnlockOSThread()
+	return nil
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func RunNetnsTests() {
+	Describe("Pod network namespace lookup", func() {
+		var proc string
+		BeforeEach(func() {
+			var err error
+			proc, err = ioutil.TempDir("", "cyclonus-worker-proc")
+			Expect(err).To(Succeed())
+			for pid, cgroup := range map[string]string{
+				"1":    "0::/init.scope\n",
+				"100":  "0::/kubepods/besteffort/pod1234-abcd/0123456789\n",
+				"200":  "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod5678_ef01.slice/cri-containerd-0123.scope\n",
+				"self": "0::/kubepods/besteffort/pod9999-abcd/0123456789\n",
+			} {
+				Expect(os.MkdirAll(filepath.Join(proc, pid), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(proc, pid, "cgroup"), []byte(cgroup), 0644)).To(Succeed())
+			}
+		})
+		AfterEach(func() {
+			Expect(os.RemoveAll(proc)).To(Succeed())
+		})
+
+		It("Should find a process of the pod by its cgroup, with cgroupfs or systemd naming", func() {
+			Expect(findPodNetworkNamespaceInProc(proc, "1234-abcd")).To(Equal(filepath.Join(proc, "100", "ns", "net")))
+			Expect(findPodNetworkNamespaceInProc(proc, "5678-ef01")).To(Equal(filepath.Join(proc, "200", "ns", "net")))
+		})
+
+		It("Should only look at processes, and fail for pods without any", func() {
+			_, err := findPodNetworkNamespaceInProc(proc, "9999-abcd")
+			Expect(err).NotTo(Succeed())
+			_, err = findPodNetworkNamespaceInProc(proc, "")
+			Expect(err).NotTo(Succeed())
+		})
+	})
+}
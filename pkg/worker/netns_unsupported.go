@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package worker
+
+import "github.com/pkg/errors"
+
+func findPodNetworkNamespace(podUID string) (string, error) {
+	return "", errors.Errorf("unable to find network namespace of pod %s: only supported on linux", podUID)
+}
+
+func inNetworkNamespace(namespacePath string, f func()) error {
+	return errors.Errorf("unable to enter network namespace %s: only supported on linux", namespacePath)
+}
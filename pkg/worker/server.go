@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// DefaultPort is where workers serve batches, when run as a daemon on every node
+	DefaultPort = 8090
+	BatchPath   = "/batch"
+	// TokenEnvVar is where a serving worker gets the token every batch must carry
+	TokenEnvVar = "CYCLONUS_WORKER_TOKEN"
+)
+
+// Server accepts batches over HTTP, and issues each from inside its pod's network namespace.  It runs in the host's
+// network on every node, so that it isn't caught up in the network policies being tested, and is reached through the
+// API server's pod proxy, which is a plain HTTP request per batch rather than an exec stream.
+type Server struct {
+	Concurrency int
+	// Token must match every batch's Token, so that only whoever deployed the worker can use it; batches are refused
+	// if it's empty
+	Token string
+	// Issue issues a batch's requests; it's IssueBatchInPodNetwork, unless overridden for testing
+	Issue func(batch *Batch, concurrency int) ([]*Result, error)
+}
+
+func NewServer(concurrency int, token string) *Server {
+	return &Server{Concurrency: concurrency, Token: token, Issue: IssueBatchInPodNetwork}
+}
+
+// ListenAndServe serves on address -- which, in the host's network, should be the node's IP, which is where the API
+// server's pod proxy sends requests -- or on every address, if it's empty
+func (s *Server) ListenAndServe(address string, port int) error {
+	hostPort := net.JoinHostPort(address, strconv.Itoa(port))
+	log.Infof("serving batches on %s", hostPort)
+	return errors.Wrapf(http.ListenAndServe(hostPort, s), "unable to serve on %s", hostPort)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Debugf("received request: %s to %s", r.Method, r.URL.String())
+	if r.URL.Path != BatchPath {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		handleError(w, r, errors.Errorf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		handleError(w, r, errors.Wrapf(err, "unable to read request body"), http.StatusBadRequest)
+		return
+	}
+	var batch Batch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		handleError(w, r, errors.Wrapf(err, "unable to unmarshal JSON"), http.StatusBadRequest)
+		return
+	}
+	if s.Token == "" || subtle.ConstantTimeCompare([]byte(batch.Token), []byte(s.Token)) != 1 {
+		handleError(w, r, errors.Errorf("batch %s has a missing or wrong token", batch.Key()), http.StatusUnauthorized)
+		return
+	}
+	if err := batch.IsValid(); err != nil {
+		handleError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if batch.PodUID == "" {
+		handleError(w, r, errors.Errorf("batch %s has no pod UID", batch.Key()), http.StatusBadRequest)
+		return
+	}
+
+	log.Infof("issuing %s batch with %d requests", batch.Key(), len(batch.Requests))
+	results, err := s.Issue(&batch, s.Concurrency)
+	if err != nil {
+		handleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	jsonBytes, err := json.Marshal(results)
+	if err != nil {
+		handleError(w, r, errors.Wrapf(err, "unable to marshal JSON"), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(jsonBytes); err != nil {
+		log.Errorf("unable to write response to %s: %+v", r.URL.String(), err)
+	}
+}
+
+func handleError(w http.ResponseWriter, r *http.Request, err error, statusCode int) {
+	log.Errorf("error %s with code %d from request to %s", err.Error(), statusCode, r.URL.String())
+	http.Error(w, err.Error(), statusCode)
+}
@@ -0,0 +1,73 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"net/http"
+	"net/http/httptest"
+)
+
+func RunServerTests() {
+	Describe("Server", func() {
+		var issued []*Batch
+		newServer := func(token string) *Server {
+			issued = nil
+			return &Server{Concurrency: 1, Token: token, Issue: func(batch *Batch, concurrency int) ([]*Result, error) {
+				issued = append(issued, batch)
+				var results []*Result
+				for _, r := range batch.Requests {
+					results = append(results, &Result{Request: r})
+				}
+				return results, nil
+			}}
+		}
+		serve := func(server *Server, method string, path string, batch *Batch) *httptest.ResponseRecorder {
+			body, err := json.Marshal(batch)
+			Expect(err).To(Succeed())
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(method, path, bytes.NewReader(body)))
+			return recorder
+		}
+		batch := func(token string) *Batch {
+			return &Batch{Namespace: "x", Pod: "a", Container: "cont-80-tcp", PodUID: "uid-a", Token: token, Requests: []*Request{
+				{Key: "x/b", Protocol: v1.ProtocolTCP, Host: "192.168.1.9", Port: 80},
+			}}
+		}
+
+		It("Should issue batches carrying its token", func() {
+			response := serve(newServer("token-1"), http.MethodPost, BatchPath, batch("token-1"))
+			Expect(response.Code).To(Equal(http.StatusOK))
+			var results []*Result
+			Expect(json.Unmarshal(response.Body.Bytes(), &results)).To(Succeed())
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].IsSuccess()).To(BeTrue())
+			Expect(issued).To(HaveLen(1))
+			Expect(issued[0].PodUID).To(Equal("uid-a"))
+		})
+
+		It("Should refuse batches with a missing or wrong token, without issuing them", func() {
+			Expect(serve(newServer("token-1"), http.MethodPost, BatchPath, batch("token-2")).Code).To(Equal(http.StatusUnauthorized))
+			Expect(serve(newServer("token-1"), http.MethodPost, BatchPath, batch("")).Code).To(Equal(http.StatusUnauthorized))
+			// a worker without a token can't be used by anyone
+			Expect(serve(newServer(""), http.MethodPost, BatchPath, batch("")).Code).To(Equal(http.StatusUnauthorized))
+			Expect(issued).To(BeEmpty())
+		})
+
+		It("Should refuse other paths and methods, and batches it can't issue", func() {
+			server := newServer("token-1")
+			Expect(serve(server, http.MethodPost, "/other", batch("token-1")).Code).To(Equal(http.StatusNotFound))
+			Expect(serve(server, http.MethodGet, BatchPath, batch("token-1")).Code).To(Equal(http.StatusMethodNotAllowed))
+
+			noUID := batch("token-1")
+			noUID.PodUID = ""
+			Expect(serve(server, http.MethodPost, BatchPath, noUID).Code).To(Equal(http.StatusBadRequest))
+			badProtocol := batch("token-1")
+			badProtocol.Requests[0].Protocol = "ICMP"
+			Expect(serve(server, http.MethodPost, BatchPath, badProtocol).Code).To(Equal(http.StatusBadRequest))
+			Expect(issued).To(BeEmpty())
+		})
+	})
+}
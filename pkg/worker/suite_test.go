@@ -0,0 +1,15 @@
+package worker
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestWorker(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunServerTests()
+	RunNetnsTests()
+	RunSpecs(t, "worker suite")
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	"os/exec"
+	"sync"
 )
 
 var (
@@ -36,10 +37,44 @@ func RunWorker(jobs string, concurrency int) (string, error) {
 }
 
 func IssueBatch(batch *Batch, concurrency int) []*Result {
+	return issueBatch(batch, concurrency, issueSingleRequest)
+}
+
+// IssueBatchInPodNetwork issues the batch's requests from inside the network namespace of the pod with the batch's
+// PodUID, so that they're treated -- by network policies, among others -- as if the pod had issued them.  The
+// worker has to be running in the host's pid namespace, with the privileges to enter other network namespaces.
+func IssueBatchInPodNetwork(batch *Batch, concurrency int) ([]*Result, error) {
+	namespacePath, err := findPodNetworkNamespace(batch.PodUID)
+	if err != nil {
+		return nil, err
+	}
+	var lock sync.Mutex
+	var namespaceErr error
+	results := issueBatch(batch, concurrency, func(r *Request) *Result {
+		var result *Result
+		err := inNetworkNamespace(namespacePath, func() {
+			result = issueSingleRequest(r)
+		})
+		if err != nil {
+			lock.Lock()
+			if namespaceErr == nil {
+				namespaceErr = err
+			}
+			lock.Unlock()
+		}
+		if result == nil {
+			result = &Result{Request: r, Error: errors.WithMessagef(err, "unable to issue request").Error()}
+		}
+		return result
+	})
+	return results, namespaceErr
+}
+
+func issueBatch(batch *Batch, concurrency int, issue func(*Request) *Result) []*Result {
 	requestChan := make(chan *Request)
 	resultChan := make(chan *Result, len(batch.Requests))
 	for i := 0; i < concurrency; i++ {
-		go worker(requestChan, resultChan)
+		go worker(requestChan, resultChan, issue)
 	}
 	for _, b := range batch.Requests {
 		requestChan <- b
@@ -53,21 +88,38 @@ func IssueBatch(batch *Batch, concurrency int) []*Result {
 	return resultSlice
 }
 
-func worker(requests <-chan *Request, results chan<- *Result) {
+func worker(requests <-chan *Request, results chan<- *Result, issue func(*Request) *Result) {
 	for request := range requests {
-		results <- IssueRequestWithRetries(request, 1)
+		results <- issueRequestWithRetries(request, 1, issue)
 	}
 }
 
 func IssueRequestWithRetries(r *Request, retries int) *Result {
-	result := IssueRequest(r)
+	return issueRequestWithRetries(r, retries, issueSingleRequest)
+}
+
+func issueRequestWithRetries(r *Request, retries int, issue func(*Request) *Result) *Result {
+	result := issueRequest(r, issue)
 	for i := 0; i < retries && !result.IsSuccess(); i++ {
-		result = IssueRequest(r)
+		result = issueRequest(r, issue)
 	}
 	return result
 }
 
+// IssueRequest issues the request as many times as it has exchanges, stopping at the first failure
 func IssueRequest(r *Request) *Result {
+	return issueRequest(r, issueSingleRequest)
+}
+
+func issueRequest(r *Request, issue func(*Request) *Result) *Result {
+	result := issue(r)
+	for i := 1; i < r.Exchanges && result.IsSuccess(); i++ {
+		result = issue(r)
+	}
+	return result
+}
+
+func issueSingleRequest(r *Request) *Result {
 	command := r.Command()
 	name, args := command[0], command[1:]
 	cmd := exec.Command(name, args...)
@@ -82,56 +134,3 @@ func IssueRequest(r *Request) *Result {
 		Error:   errString,
 	}
 }
-
-// Can't run as a server over http for two reasons:
-// 1. the container program needs to be /agnhost serving on a port/protocol
-// 2. the invocation needs to be a kubectl exec to avoid getting blocked as collateral damage by a network policy
-//func SetupHTTPServer() {
-//	http.HandleFunc("/batch", func(w http.ResponseWriter, r *http.Request) {
-//		log.Debugf("received request: %s to %s", r.Method, r.URL.String())
-//		if r.Method == "POST" {
-//			body, err := ioutil.ReadAll(r.Body)
-//			if err != nil {
-//				HandleError(w, r, errors.Wrapf(err, "unable to read request body"), 400)
-//				return
-//			}
-//			log.Tracef("received body: %s", body)
-//			var batch Batch
-//			err = json.Unmarshal(body, &batch)
-//			if err != nil {
-//				HandleError(w, r, errors.Wrapf(err, "unable to unmarshal JSON"), 400)
-//				return
-//			}
-//
-//			results, err := batch.Issue()
-//			if err != nil {
-//				HandleError(w, r, err, 400)
-//				return
-//			}
-//
-//			jsonBytes, err := json.MarshalIndent(results, "", "  ")
-//			if err != nil {
-//				HandleError(w, r, errors.Wrapf(err, "unable to marshal JSON"), 500)
-//				return
-//			}
-//			header := w.Header()
-//			header.Set(http.CanonicalHeaderKey("content-type"), "application/json")
-//			_, err = fmt.Fprint(w, string(jsonBytes))
-//			if err != nil {
-//				HandleError(w, r, errors.Wrapf(err, "unable to write response"), 500)
-//			}
-//		} else {
-//			NotFound(w, r)
-//		}
-//	})
-//}
-//
-//func NotFound(w http.ResponseWriter, r *http.Request) {
-//	log.Errorf("HTTPResponder not found from request %+v", r)
-//	http.NotFound(w, r)
-//}
-//
-//func HandleError(w http.ResponseWriter, r *http.Request, err error, statusCode int) {
-//	log.Errorf("HTTPResponder error %s with code %d from request %+v", err.Error(), statusCode, r)
-//	http.Error(w, err.Error(), statusCode)
-//}